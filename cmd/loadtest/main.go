@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/loadtest"
+)
+
+// The gateway is started against fake backends with APP_ENV=loadtest, which loads internal/config/config.loadtest.yml,
+// then the scenario is run against it:
+//
+//	APP_ENV=loadtest go run ./cmd/main.go
+//	go run ./cmd/loadtest -scenario internal/loadtest/scenarios/auth_heavy.yml
+func main() {
+	scenarioPath := flag.String("scenario", "internal/loadtest/scenarios/auth_heavy.yml", "path of the scenario yml file")
+	target := flag.String("target", "http://localhost:8080", "URL of the gateway under test")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	scenario, err := loadtest.LoadScenario(*scenarioPath)
+	if err != nil {
+		log.Fatalln("Failed loading the scenario: ", err)
+	}
+	generator, err := loadtest.NewGenerator(*target, scenario, nil)
+	if err != nil {
+		log.Fatalln("Failed to create the load generator: ", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "Running scenario %s against %s for %s\n", scenario.Name, *target, scenario.Duration)
+	report := generator.Run(ctx)
+
+	if *asJSON {
+		report.Summarize()
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalln("Failed printing the report: ", err)
+		}
+		return
+	}
+	if err := report.Print(os.Stdout); err != nil {
+		log.Fatalln("Failed printing the report: ", err)
+	}
+}
@@ -1,45 +1,211 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
-	"github.com/gin-gonic/gin"
-	commontConfig "github.com/quadev-ltd/qd-common/pkg/config"
-	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
-
-	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
-	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/replay"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/gateway"
 )
 
-// APIPath is the path of the API
-const APIPath = "/api/v1"
-
+// With -check the gateway loads its configuration, dials the upstream services, fetches the public key and
+// validates the TLS material, then prints a JSON report and exits with 1 when a check failed:
+//
+//	go run ./cmd/main.go -check -check-timeout 10s
+//
+// routes export prints the effective route table of the configuration as JSON or Markdown, or renders a route
+// table exported before with -from:
+//
+//	go run ./cmd/main.go routes export -format markdown -output routes.md
+//
+// replay sends the requests of journal segments or access logs to a gateway, authenticated with tokens minted by
+// its staging token route, and reports the responses differing from a baseline gateway or from the recorded
+// statuses, exiting with 1 when one differs:
+//
+//	REPLAY_ADMIN_KEY=... go run ./cmd/main.go replay -target http://localhost:8081 -baseline http://localhost:8080 journal/journal-2026101609.log
 func main() {
-	configuration := config.Config{}
-	err := configuration.Load("internal/config")
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		passed, err := replayTraffic(os.Args[2:])
+		if err != nil {
+			log.Fatalln("Failed replaying the traffic: ", err)
+		}
+		if !passed {
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 2 && os.Args[1] == "routes" && os.Args[2] == "export" {
+		if err := exportRoutes(os.Args[3:]); err != nil {
+			log.Fatalln("Failed exporting the route table: ", err)
+		}
+		return
+	}
+
+	check := flag.Bool("check", false, "check the configuration and the upstream services, then exit")
+	checkTimeout := flag.Duration("check-timeout", gateway.DefaultCheckTimeout, "timeout of every check dialing an upstream service")
+	flag.Parse()
+
+	if *check {
+		// The components print their progress to the standard output, which is kept for the report
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		report := gateway.CheckConfig("internal/config", *checkTimeout)
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalln("Failed printing the check report: ", err)
+		}
+		if !report.Passed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	configuration, err := gateway.LoadConfig("internal/config")
 	if err != nil {
 		log.Fatalln("Failed loading the configurations", err)
 	}
+	server, err := gateway.NewServer(configuration, gateway.WithConfigLoader(func() (*gateway.Config, error) {
+		return gateway.LoadConfig("internal/config")
+	}))
+	if err != nil {
+		log.Fatalln("Failed creating the gateway: ", err)
+	}
+	// SIGTERM drains the requests in flight before the gateway exits
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	if err := server.Run(ctx); err != nil {
+		log.Fatalln(err)
+	}
+}
 
-	var centralConfig commontConfig.Config
-	centralConfig.Load(
-		configuration.Environment,
-		configuration.AWS.Key,
-		configuration.AWS.Secret,
-	)
+// exportRoutes writes the route table of the gateway built from the configuration, without serving it
+func exportRoutes(arguments []string) error {
+	flags := flag.NewFlagSet("routes export", flag.ExitOnError)
+	format := flags.String("format", gateway.RouteTableJSON, "format of the route table, json or markdown")
+	from := flags.String("from", "", "route table exported as JSON to render instead of the configuration")
+	output := flags.String("output", "", "file the route table is written to, the standard output by default")
+	flags.Parse(arguments)
+	if *format != gateway.RouteTableJSON && *format != gateway.RouteTableMarkdown {
+		return fmt.Errorf("Unknown route table format %s", *format)
+	}
 
-	router := gin.Default()
-	router.Use(commonLogger.AddNewCorrelationIDToContext)
-	logger := commonLogger.NewLogFactory(configuration.Environment)
-	router.Use(commonLogger.CreateGinLoggerMiddleware(logger))
+	var writer io.Writer = os.Stdout
+	// The components print their progress to the standard output, which is kept for the route table
+	os.Stdout = os.Stderr
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
 
-	api := router.Group(APIPath)
+	var table *gateway.RouteTable
+	if *from != "" {
+		file, err := os.Open(*from)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if table, err = gateway.ImportRouteTable(file); err != nil {
+			return err
+		}
+	} else {
+		configuration, err := gateway.LoadConfig("internal/config")
+		if err != nil {
+			return fmt.Errorf("Failed loading the configurations: %v", err)
+		}
+		server, err := gateway.NewServer(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed creating the gateway: %v", err)
+		}
+		table = server.RouteTable()
+		if err := server.Stop(context.Background()); err != nil {
+			log.Println("Failed stopping the gateway: ", err)
+		}
+	}
+	return table.Write(writer, *format)
+}
 
-	_, err = authentication.RegisterRoutes(api, &centralConfig, &configuration)
+// replayTraffic replays the recorded requests of the files and prints the report, it returns whether every
+// response was the expected one
+func replayTraffic(arguments []string) (bool, error) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	source := flags.String("source", "journal", "format of the files, journal or access_log")
+	journalKey := flags.String("journal-key", "", "encryption key of the journal, the configured one by default")
+	options := replay.Options{TokenPath: gateway.APIPath + "/staging/tokens"}
+	flags.StringVar(&options.Target, "target", "http://localhost:8080", "URL of the gateway under test")
+	flags.StringVar(&options.Baseline, "baseline", "", "URL of the gateway the responses are compared with, the recorded statuses by default")
+	flags.StringVar(&options.AdminKey, "admin-key", os.Getenv("REPLAY_ADMIN_KEY"), "admin key of the token minting, REPLAY_ADMIN_KEY by default")
+	flags.StringVar(&options.User, "user", replay.DefaultUser, "synthetic user of the requests recorded without one")
+	methods := flags.String("methods", "GET,HEAD", "comma separated methods replayed, every method when empty")
+	ignore := flags.String("ignore", "", "comma separated fields of the response bodies left out of the comparison")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(arguments)
+	if flags.NArg() == 0 {
+		return false, fmt.Errorf("No journal segments or access logs to replay")
+	}
+	if *methods != "" {
+		options.Methods = strings.Split(*methods, ",")
+	}
+	if *ignore != "" {
+		options.IgnoredFields = strings.Split(*ignore, ",")
+	}
+
+	requests := []replay.Request{}
+	switch *source {
+	case "journal":
+		if *journalKey == "" {
+			configuration, err := gateway.LoadConfig("internal/config")
+			if err != nil {
+				return false, fmt.Errorf("Failed loading the configurations: %v", err)
+			}
+			*journalKey = configuration.Journal.EncryptionKey
+		}
+		journaled, err := replay.ReadJournal(flags.Args(), *journalKey)
+		if err != nil {
+			return false, err
+		}
+		requests = journaled
+	case "access_log":
+		for _, path := range flags.Args() {
+			file, err := os.Open(path)
+			if err != nil {
+				return false, err
+			}
+			logged, err := replay.ReadAccessLog(file)
+			file.Close()
+			if err != nil {
+				return false, err
+			}
+			requests = append(requests, logged...)
+		}
+	default:
+		return false, fmt.Errorf("Unknown replay source %s", *source)
+	}
+
+	replayer, err := replay.NewReplayer(options, nil)
 	if err != nil {
-		log.Fatalln("Failed to register authentication routes: ", err)
+		return false, err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "Replaying %d requests against %s\n", len(requests), options.Target)
+	report := replayer.Run(ctx, requests)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return report.Passed(), encoder.Encode(report)
 	}
-	fmt.Println("Listening API requests on URL: ", fmt.Sprintf("%s:%s%s", centralConfig.GatewayService.Host, centralConfig.GatewayService.Port, APIPath))
-	router.Run(fmt.Sprintf("%s:%s", centralConfig.GatewayService.Host, centralConfig.GatewayService.Port))
+	return report.Passed(), report.Print(os.Stdout)
 }
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/admin/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+)
+
+// AdminActionDetailType is the event bus detail type of the audit records
+const AdminActionDetailType = "admin.action.performed"
+
+const publishTimeout = 5 * time.Second
+
+// AuditRecord describes an administration request, whether it was allowed or not
+type AuditRecord struct {
+	Action        string                 `json:"action"`
+	ActorID       string                 `json:"actorID,omitempty"`
+	TargetUserID  string                 `json:"targetUserID,omitempty"`
+	Method        string                 `json:"method"`
+	Path          string                 `json:"path"`
+	Status        int                    `json:"status"`
+	Details       map[string]interface{} `json:"details,omitempty"`
+	ClientIP      string                 `json:"clientIP"`
+	CorrelationID string                 `json:"correlationID,omitempty"`
+	Timestamp     time.Time              `json:"timestamp"`
+}
+
+// Auditor records every administration request in the logs and on the event bus
+type Auditor struct {
+	publisher events.Publisherer
+}
+
+// NewAuditor creates an auditor publishing to the given event bus
+func NewAuditor(publisher events.Publisherer) *Auditor {
+	return &Auditor{publisher: publisher}
+}
+
+// Record returns a middleware auditing the outcome of the request as the given action
+func (auditor *Auditor) Record(action string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+		if err != nil {
+			return
+		}
+		record := NewAuditRecord(ctx, action)
+		serializedRecord, err := json.Marshal(record)
+		if err != nil {
+			logger.Error(err, "Could not serialize the admin audit record")
+			return
+		}
+		logger.Info(fmt.Sprintf("Admin audit: %s", serializedRecord))
+
+		go func() {
+			publishContext, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			defer cancel()
+			if err := auditor.publisher.Publish(publishContext, AdminActionDetailType, record); err != nil {
+				logger.Error(err, "Could not publish admin audit record")
+			}
+		}()
+	}
+}
+
+// NewAuditRecord builds the audit record of a handled request
+func NewAuditRecord(ctx *gin.Context, action string) AuditRecord {
+	record := AuditRecord{
+		Action:       action,
+		TargetUserID: ctx.Param("userID"),
+		Method:       ctx.Request.Method,
+		Path:         ctx.FullPath(),
+		Status:       ctx.Writer.Status(),
+		ClientIP:     ctx.ClientIP(),
+		Timestamp:    time.Now().UTC(),
+	}
+	if claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey)); exists {
+		if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+			record.ActorID = claims.UserID
+		}
+	}
+	if details, exists := ctx.Get(routes.AuditDetailsKey); exists {
+		record.Details, _ = details.(map[string]interface{})
+	}
+	if correlationID, err := commonLogger.GetCorrelationIDFromContext(ctx.Request.Context()); err == nil {
+		record.CorrelationID = *correlationID
+	}
+	return record
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/admin/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/contract"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+)
+
+// The audit log is parsed by the security dashboards, go test ./internal/admin -update rewrites the
+// golden file once they are migrated
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestAuditorGolden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	controller := gomock.NewController(t)
+	loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+	var logged string
+	loggerMock.EXPECT().Info(gomock.Any()).Do(func(message string) { logged = message })
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		requestContext := commonLogger.AddCorrelationIDToIncomingContext(ctx.Request.Context(), "correlation-id")
+		ctx.Request = ctx.Request.WithContext(context.WithValue(requestContext, commonLogger.LoggerKey, loggerMock))
+		ctx.Set(string(commonJWT.ClaimsContextKey), &commonJWT.TokenClaims{UserID: "admin-id"})
+	})
+	router.PUT("/admin/users/:userID/roles", NewAuditor(&events.NoopPublisher{}).Record("users.assign_roles"), func(ctx *gin.Context) {
+		ctx.Set(routes.AuditDetailsKey, map[string]interface{}{"roles": []string{"admin"}})
+		ctx.Status(http.StatusNoContent)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/admin/users/user-id/roles", nil))
+
+	prefix, record, found := strings.Cut(logged, "{")
+	assert.True(t, found)
+	shape, err := contract.ShapeOf([]byte("{" + record))
+	assert.NoError(t, err)
+	fields := []string{fmt.Sprintf("prefix %q", prefix)}
+	for path, valueType := range shape {
+		if path == "" {
+			path = "."
+		}
+		fields = append(fields, fmt.Sprintf("%s %s", path, valueType))
+	}
+	sort.Strings(fields)
+	actual := strings.Join(fields, "\n") + "\n"
+
+	path := filepath.Join("testdata", "audit_log.golden")
+	if *update {
+		assert.NoError(t, os.WriteFile(path, []byte(actual), 0o644))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), actual, "The fields of the audit log changed, update the dashboards then rerun with -update")
+}
+
+func TestAuditRecordIdentity(t *testing.T) {
+	t.Run("NewIdentity_Should_Not_Share_The_Event_ID_Of_Records_Sharing_The_Correlation_ID", func(t *testing.T) {
+		first := AuditRecord{Action: "users.suspend", ActorID: "admin-id", TargetUserID: "first-id", CorrelationID: "fixed"}
+		second := AuditRecord{Action: "users.suspend", ActorID: "admin-id", TargetUserID: "second-id", CorrelationID: "fixed"}
+		firstJSON, _ := json.Marshal(first)
+		secondJSON, _ := json.Marshal(second)
+
+		firstIdentity := events.NewIdentity("qd.api-gateway", AdminActionDetailType, first, firstJSON)
+		secondIdentity := events.NewIdentity("qd.api-gateway", AdminActionDetailType, second, secondJSON)
+
+		assert.NotEqual(t, firstIdentity.EventID, secondIdentity.EventID)
+	})
+}
@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/admin/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_admin"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// ServiceClienter is an interface for the user administration service client
+type ServiceClienter interface {
+	ListUsers(ctx *gin.Context)
+	SuspendUser(ctx *gin.Context)
+	UnsuspendUser(ctx *gin.Context)
+	AssignRoles(ctx *gin.Context)
+	ForcePasswordReset(ctx *gin.Context)
+}
+
+// ServiceClient is a struct for the user administration service client
+type ServiceClient struct {
+	client pb_admin.AdminServiceClient
+}
+
+var _ ServiceClienter = &ServiceClient{}
+
+// InitServiceClient initializes the user administration client, served by the authentication service
+func InitServiceClient(centralConfig *commonConfig.Config, configurations *config.Config) (pb_admin.AdminServiceClient, error) {
+	grpcServiceAddress := fmt.Sprintf("%s:%s", centralConfig.AuthenticationService.Host, centralConfig.AuthenticationService.Port)
+
+	fmt.Println("Connecting to user administration service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection("authentication", grpcServiceAddress, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc user administration service: %v", err)
+	}
+
+	regionalConnection, err := region.RouteConnection("authentication", clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConnection, err := tenancy.RouteConnection("authentication", regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	routedConnection, err := versioning.RouteConnection("authentication", tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tracedConnection := tracing.TraceConnection("authentication", routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection("authentication", tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection("authentication", instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection("authentication", retryingConnection, configurations)
+	hookedConnection := hooks.HookConnection("authentication", breakerConnection, configurations)
+
+	return pb_admin.NewAdminServiceClient(hookedConnection), nil
+}
+
+// ListUsers redirects request to the list users route
+func (service *ServiceClient) ListUsers(ctx *gin.Context) {
+	routes.ListUsers(ctx, service.client)
+}
+
+// SuspendUser redirects request to the suspend user route
+func (service *ServiceClient) SuspendUser(ctx *gin.Context) {
+	routes.SuspendUser(ctx, service.client)
+}
+
+// UnsuspendUser redirects request to the unsuspend user route
+func (service *ServiceClient) UnsuspendUser(ctx *gin.Context) {
+	routes.UnsuspendUser(ctx, service.client)
+}
+
+// AssignRoles redirects request to the assign roles route
+func (service *ServiceClient) AssignRoles(ctx *gin.Context) {
+	routes.AssignRoles(ctx, service.client)
+}
+
+// ForcePasswordReset redirects request to the force password reset route
+func (service *ServiceClient) ForcePasswordReset(ctx *gin.Context) {
+	routes.ForcePasswordReset(ctx, service.client)
+}
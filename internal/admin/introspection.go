@@ -0,0 +1,152 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+)
+
+// IntrospectionPath is the route group of the introspection routes, outside the API so they do not depend
+// on the user tokens the gateway verifies
+const IntrospectionPath = "/admin"
+
+// Route is a route of the route table
+type Route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+// RouteTableExporter answers with the effective route table
+type RouteTableExporter interface {
+	ExportHandler(ctx *gin.Context)
+}
+
+// Introspection answers the runtime state of the gateway as JSON to debug the production incidents: its route
+// table, the states of the upstream connections, the circuit breakers, the rate limit counters and the
+// metadata of the cached public key. The states of the components not enabled are left out
+type Introspection struct {
+	token      []byte
+	router     *gin.Engine
+	limiter    *ratelimit.Limiter
+	publicKey  authentication.PublicKeyDescriber
+	routeTable RouteTableExporter
+}
+
+// NewIntrospection creates the introspection of the router, served to the bearers of the configured token
+func NewIntrospection(router *gin.Engine, configurations *config.Config) (*Introspection, error) {
+	token := configurations.Admin.Introspection.Token
+	if token == "" {
+		return nil, fmt.Errorf("The admin introspection routes need an admin token")
+	}
+	return &Introspection{token: []byte("Bearer " + token), router: router}, nil
+}
+
+// UseRateLimiter answers the counters of the rate limiter
+func (introspection *Introspection) UseRateLimiter(limiter *ratelimit.Limiter) {
+	introspection.limiter = limiter
+}
+
+// UsePublicKey answers the metadata of the public key verifying the tokens
+func (introspection *Introspection) UsePublicKey(publicKey authentication.PublicKeyDescriber) {
+	introspection.publicKey = publicKey
+}
+
+// UseRouteTable exports the effective route table
+func (introspection *Introspection) UseRouteTable(routeTable RouteTableExporter) {
+	introspection.routeTable = routeTable
+}
+
+// Register registers the introspection routes behind the admin token
+func (introspection *Introspection) Register(router *gin.Engine) {
+	introspectionRoutes := router.Group(IntrospectionPath)
+	introspectionRoutes.Use(introspection.RequireToken)
+	introspectionRoutes.GET("", introspection.StateHandler)
+	introspectionRoutes.GET("/routes", introspection.RoutesHandler)
+	introspectionRoutes.GET("/upstreams", introspection.UpstreamsHandler)
+	introspectionRoutes.GET("/circuit-breakers", resilience.DefaultBreakers.StatsHandler)
+	introspectionRoutes.GET("/rate-limits", introspection.RateLimitsHandler)
+	introspectionRoutes.GET("/public-key", introspection.PublicKeyHandler)
+	if introspection.routeTable != nil {
+		introspectionRoutes.GET("/routes/export", introspection.routeTable.ExportHandler)
+	}
+}
+
+// RequireToken rejects the requests without the admin token
+func (introspection *Introspection) RequireToken(ctx *gin.Context) {
+	if subtle.ConstantTimeCompare([]byte(ctx.GetHeader("Authorization")), introspection.token) != 1 {
+		errors.Abort(ctx, errors.Unauthenticated)
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+}
+
+// Routes returns the route table of the router sorted by path and method
+func (introspection *Introspection) Routes() []Route {
+	routeInfos := introspection.router.Routes()
+	routes := make([]Route, 0, len(routeInfos))
+	for _, routeInfo := range routeInfos {
+		routes = append(routes, Route{Method: routeInfo.Method, Path: routeInfo.Path, Handler: routeInfo.Handler})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// StateHandler answers with every state the introspection knows
+func (introspection *Introspection) StateHandler(ctx *gin.Context) {
+	state := gin.H{
+		"routes":          introspection.Routes(),
+		"upstreams":       egress.ConnectionStates(),
+		"circuitBreakers": resilience.DefaultBreakers.Stats(),
+	}
+	if introspection.limiter != nil {
+		state["rateLimits"] = introspection.limiter.Stats()
+	}
+	if introspection.publicKey != nil {
+		state["publicKey"] = introspection.publicKey.PublicKeyInfo()
+	}
+	ctx.JSON(http.StatusOK, state)
+}
+
+// RoutesHandler answers with the route table
+func (introspection *Introspection) RoutesHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"routes": introspection.Routes()})
+}
+
+// UpstreamsHandler answers with the states of the upstream connections
+func (introspection *Introspection) UpstreamsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"upstreams": egress.ConnectionStates()})
+}
+
+// RateLimitsHandler answers with the counters of the rate limited groups
+func (introspection *Introspection) RateLimitsHandler(ctx *gin.Context) {
+	if introspection.limiter == nil {
+		errors.AbortWithMessage(ctx, errors.NotFound, "The rate limits are not enabled")
+		return
+	}
+	introspection.limiter.StatsHandler(ctx)
+}
+
+// PublicKeyHandler answers with the metadata of the cached public key
+func (introspection *Introspection) PublicKeyHandler(ctx *gin.Context) {
+	if introspection.publicKey == nil {
+		errors.AbortWithMessage(ctx, errors.NotFound, "The public key is not cached by the gateway")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"publicKey": introspection.publicKey.PublicKeyInfo()})
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type fakePublicKey struct{}
+
+func (fake *fakePublicKey) PublicKeyInfo() authentication.PublicKeyInfo {
+	return authentication.PublicKeyInfo{Fingerprint: "fingerprint", Algorithm: "RSA", Bits: 2048, FetchedAt: time.Unix(0, 0).UTC()}
+}
+
+func TestIntrospection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configurations := &config.Config{Admin: config.AdminConfig{Introspection: config.AdminIntrospectionConfig{Enabled: true, Token: "admin-token"}}}
+	newRouter := func(t *testing.T) (*gin.Engine, *Introspection) {
+		router := gin.New()
+		router.GET("/api/v1/user/profile", func(ctx *gin.Context) {})
+		introspection, err := NewIntrospection(router, configurations)
+		assert.NoError(t, err)
+		return router, introspection
+	}
+	serve := func(router *gin.Engine, path, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("RequireToken_Should_Reject_The_Requests_Without_The_Admin_Token", func(t *testing.T) {
+		router, introspection := newRouter(t)
+		introspection.Register(router)
+
+		assert.Equal(t, http.StatusUnauthorized, serve(router, "/admin/routes", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(router, "/admin/routes", "user-token").Code)
+	})
+
+	t.Run("RoutesHandler_Should_Answer_The_Route_Table", func(t *testing.T) {
+		router, introspection := newRouter(t)
+		introspection.Register(router)
+
+		recorder := serve(router, "/admin/routes", "admin-token")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+		body := struct{ Routes []Route }{}
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		routes := []string{}
+		for _, route := range body.Routes {
+			routes = append(routes, route.Method+" "+route.Path)
+		}
+		assert.Equal(t, []string{
+			"GET /admin",
+			"GET /admin/circuit-breakers",
+			"GET /admin/public-key",
+			"GET /admin/rate-limits",
+			"GET /admin/routes",
+			"GET /admin/upstreams",
+			"GET /api/v1/user/profile",
+		}, routes)
+	})
+
+	t.Run("StateHandler_Should_Leave_Out_The_Components_Not_Enabled", func(t *testing.T) {
+		router, introspection := newRouter(t)
+		introspection.UsePublicKey(&fakePublicKey{})
+		introspection.Register(router)
+
+		recorder := serve(router, "/admin", "admin-token")
+
+		state := map[string]json.RawMessage{}
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &state))
+		assert.Contains(t, state, "routes")
+		assert.Contains(t, state, "upstreams")
+		assert.Contains(t, state, "circuitBreakers")
+		assert.NotContains(t, state, "rateLimits")
+		assert.JSONEq(t, `{"fingerprint":"fingerprint","algorithm":"RSA","bits":2048,"fetchedAt":"1970-01-01T00:00:00Z","refreshing":false}`, string(state["publicKey"]))
+		assert.Equal(t, http.StatusNotFound, serve(router, "/admin/rate-limits", "admin-token").Code)
+	})
+
+	t.Run("NewIntrospection_Should_Require_A_Token", func(t *testing.T) {
+		_, err := NewIntrospection(gin.New(), &config.Config{Admin: config.AdminConfig{Introspection: config.AdminIntrospectionConfig{Enabled: true}}})
+
+		assert.ErrorContains(t, err, "need an admin token")
+	})
+}
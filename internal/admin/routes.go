@@ -0,0 +1,45 @@
+package admin
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+)
+
+// RegisterRoutes registers the user administration routes, audited before the role check so denied attempts are recorded too
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*ServiceClient, error) {
+	client, err := InitServiceClient(centralConfig, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not initialize user administration service client: %v", err)
+	}
+	service := &ServiceClient{
+		client: client,
+	}
+
+	eventPublisher, err := events.NewPublisher(configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initiate event publisher: %v", err)
+	}
+	auditor := NewAuditor(eventPublisher)
+	requireAdmin := authenticationMiddleware.RequireRole(configurations.Admin.Roles...)
+
+	adminRoutes := api.Group("/admin/users")
+	adminRoutes.Use(authenticationMiddleware.RequireAuthentication)
+	adminRoutes.GET("", auditor.Record("users.list"), requireAdmin, service.ListUsers)
+	adminRoutes.POST("/:userID/suspension", auditor.Record("users.suspend"), requireAdmin, service.SuspendUser)
+	adminRoutes.DELETE("/:userID/suspension", auditor.Record("users.unsuspend"), requireAdmin, service.UnsuspendUser)
+	adminRoutes.PUT("/:userID/roles", auditor.Record("users.assign_roles"), requireAdmin, service.AssignRoles)
+	adminRoutes.POST("/:userID/password/reset", auditor.Record("users.force_password_reset"), requireAdmin, service.ForcePasswordReset)
+
+	return service, nil
+}
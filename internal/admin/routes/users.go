@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_admin"
+)
+
+// AuditDetailsKey is the context key where routes leave the details recorded by the audit log
+const AuditDetailsKey = "admin_audit_details"
+
+const maxPageSize = 100
+
+// SuspendUserRequestBody is the request body for the SuspendUser route
+type SuspendUserRequestBody struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+	Until  int64  `json:"until" binding:"gte=0"`
+}
+
+// AssignRolesRequestBody is the request body for the AssignRoles route
+type AssignRolesRequestBody struct {
+	Roles []string `json:"roles" binding:"required,dive,required"`
+}
+
+// ListUsers lists the users matching the query filters
+func ListUsers(ctx *gin.Context, client pb_admin.AdminServiceClient) {
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize <= 0 || pageSize > maxPageSize {
+		errors.Abort(ctx, errors.InvalidArgument, errors.FieldViolation("pageSize", "pageSize must be a number between 1 and 100"))
+		return
+	}
+
+	res, err := client.ListUsers(
+		ctx.Request.Context(),
+		&pb_admin.ListUsersRequest{
+			Query:    ctx.Query("q"),
+			Status:   ctx.Query("status"),
+			Role:     ctx.Query("role"),
+			Cursor:   ctx.Query("cursor"),
+			PageSize: int32(pageSize),
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// SuspendUser suspends a user
+func SuspendUser(ctx *gin.Context, client pb_admin.AdminServiceClient) {
+	body := SuspendUserRequestBody{}
+
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+		return
+	}
+	ctx.Set(AuditDetailsKey, map[string]interface{}{"reason": body.Reason, "until": body.Until})
+
+	res, err := client.SuspendUser(
+		ctx.Request.Context(),
+		&pb_admin.SuspendUserRequest{
+			UserID: ctx.Param("userID"),
+			Reason: body.Reason,
+			Until:  body.Until,
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// UnsuspendUser lifts the suspension of a user
+func UnsuspendUser(ctx *gin.Context, client pb_admin.AdminServiceClient) {
+	res, err := client.UnsuspendUser(
+		ctx.Request.Context(),
+		&pb_admin.UnsuspendUserRequest{
+			UserID: ctx.Param("userID"),
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// AssignRoles replaces the roles of a user
+func AssignRoles(ctx *gin.Context, client pb_admin.AdminServiceClient) {
+	body := AssignRolesRequestBody{}
+
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+		return
+	}
+	ctx.Set(AuditDetailsKey, map[string]interface{}{"roles": body.Roles})
+
+	res, err := client.AssignRoles(
+		ctx.Request.Context(),
+		&pb_admin.AssignRolesRequest{
+			UserID: ctx.Param("userID"),
+			Roles:  body.Roles,
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// ForcePasswordReset invalidates the password of a user so it has to be reset
+func ForcePasswordReset(ctx *gin.Context, client pb_admin.AdminServiceClient) {
+	res, err := client.ForcePasswordReset(
+		ctx.Request.Context(),
+		&pb_admin.ForcePasswordResetRequest{
+			UserID: ctx.Param("userID"),
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
@@ -0,0 +1,118 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Conditioner is a condition evaluated periodically that may raise an alert
+type Conditioner interface {
+	Evaluate(now time.Time) *Alert
+}
+
+// Alerterer is the interface for the gateway alerter
+type Alerterer interface {
+	RegisterCondition(condition Conditioner)
+	Fire(ctx context.Context, alert Alert)
+	Job() scheduler.Job
+}
+
+// Alerter evaluates the registered conditions and notifies the configured channels
+type Alerter struct {
+	notifiers  []Notifierer
+	conditions []Conditioner
+	interval   time.Duration
+	cooldown   time.Duration
+	lastFired  map[string]time.Time
+	logger     commonLogger.Loggerer
+	mtx        sync.Mutex
+}
+
+var _ Alerterer = &Alerter{}
+
+// NewAlerter creates an alerter with the notifiers enabled in the configuration
+func NewAlerter(configurations *config.Config, client *http.Client) *Alerter {
+	alertingConfig := configurations.Alerting
+	notifiers := []Notifierer{}
+	if alertingConfig.SlackWebhookURL != "" {
+		notifiers = append(notifiers, NewSlackNotifier(alertingConfig.SlackWebhookURL, client))
+	}
+	if alertingConfig.PagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, NewPagerDutyNotifier(
+			alertingConfig.PagerDutyEventsURL,
+			alertingConfig.PagerDutyRoutingKey,
+			client,
+		))
+	}
+	return &Alerter{
+		notifiers: notifiers,
+		interval:  alertingConfig.EvaluationInterval,
+		cooldown:  alertingConfig.Cooldown,
+		lastFired: make(map[string]time.Time),
+		logger:    commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}
+}
+
+// RegisterCondition adds a condition to be evaluated on every tick
+func (alerter *Alerter) RegisterCondition(condition Conditioner) {
+	alerter.mtx.Lock()
+	defer alerter.mtx.Unlock()
+	alerter.conditions = append(alerter.conditions, condition)
+}
+
+// Fire sends the alert to all notifiers unless the same alert fired within the cooldown
+func (alerter *Alerter) Fire(ctx context.Context, alert Alert) {
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = time.Now()
+	}
+	if alert.Source == "" {
+		alert.Source = "qd-api-gateway"
+	}
+
+	alerter.mtx.Lock()
+	lastFired, exists := alerter.lastFired[alert.Name]
+	if exists && alert.Timestamp.Sub(lastFired) < alerter.cooldown {
+		alerter.mtx.Unlock()
+		return
+	}
+	alerter.lastFired[alert.Name] = alert.Timestamp
+	alerter.mtx.Unlock()
+
+	alerter.logger.Warn(fmt.Sprintf("Alert %s fired: %s", alert.Name, alert.Summary))
+	for _, notifier := range alerter.notifiers {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			alerter.logger.Error(err, fmt.Sprintf("Could not notify alert %s", alert.Name))
+		}
+	}
+}
+
+// Job evaluates the registered conditions on every interval
+func (alerter *Alerter) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "alerting_evaluate",
+		Schedule: scheduler.Every(alerter.interval),
+		Run: func(ctx context.Context, now time.Time) error {
+			alerter.evaluate(ctx, now)
+			return nil
+		},
+	}
+}
+
+func (alerter *Alerter) evaluate(ctx context.Context, now time.Time) {
+	alerter.mtx.Lock()
+	conditions := append([]Conditioner{}, alerter.conditions...)
+	alerter.mtx.Unlock()
+	for _, condition := range conditions {
+		if alert := condition.Evaluate(now); alert != nil {
+			alerter.Fire(ctx, *alert)
+		}
+	}
+}
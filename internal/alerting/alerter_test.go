@@ -0,0 +1,158 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+)
+
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (notifier *recordingNotifier) Notify(ctx context.Context, alert Alert) error {
+	notifier.alerts = append(notifier.alerts, alert)
+	return nil
+}
+
+func newTestAlerter(notifier Notifierer, cooldown time.Duration) *Alerter {
+	alerter := NewAlerter(&config.Config{Environment: "test"}, http.DefaultClient)
+	alerter.notifiers = []Notifierer{notifier}
+	alerter.cooldown = cooldown
+	return alerter
+}
+
+func TestAlerter(t *testing.T) {
+	t.Run("Fire_Should_Respect_Cooldown", func(t *testing.T) {
+		notifier := &recordingNotifier{}
+		alerter := newTestAlerter(notifier, time.Minute)
+		now := time.Now()
+
+		alerter.Fire(context.Background(), Alert{Name: "example", Timestamp: now})
+		alerter.Fire(context.Background(), Alert{Name: "example", Timestamp: now.Add(30 * time.Second)})
+		alerter.Fire(context.Background(), Alert{Name: "example", Timestamp: now.Add(2 * time.Minute)})
+
+		assert.Len(t, notifier.alerts, 2)
+		assert.Equal(t, "qd-api-gateway", notifier.alerts[0].Source)
+	})
+
+	t.Run("AuthErrorRateCondition_Should_Fire_Above_Threshold", func(t *testing.T) {
+		condition := NewAuthErrorRateCondition(2, time.Minute)
+		now := time.Now()
+		condition.RecordFailure(now.Add(-2 * time.Minute))
+		condition.RecordFailure(now.Add(-10 * time.Second))
+		condition.RecordFailure(now.Add(-5 * time.Second))
+
+		assert.Nil(t, condition.Evaluate(now))
+
+		condition.RecordFailure(now)
+		alert := condition.Evaluate(now)
+
+		assert.NotNil(t, alert)
+		assert.Equal(t, "auth_error_rate_spike", alert.Name)
+		assert.Equal(t, SeverityCritical, alert.Severity)
+	})
+
+	t.Run("AuthErrorRateCondition_Should_Count_A_Spike_In_Fixed_Buckets", func(t *testing.T) {
+		condition := NewAuthErrorRateCondition(100, time.Minute)
+		now := time.Now()
+		for index := 0; index < 10000; index++ {
+			condition.RecordFailure(now.Add(-time.Duration(index) * time.Millisecond))
+		}
+
+		alert := condition.Evaluate(now)
+
+		assert.NotNil(t, alert)
+		assert.Equal(t, "10000 authentication failures in the last 1m0s", alert.Summary)
+		assert.Len(t, condition.buckets, authErrorBuckets+1)
+		assert.Nil(t, condition.Evaluate(now.Add(2*time.Minute)))
+	})
+
+	t.Run("AuthFailureMonitorMiddleware_Should_Record_Unauthorized_Responses", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		condition := NewAuthErrorRateCondition(0, time.Minute)
+		router := gin.New()
+		router.Use(AuthFailureMonitorMiddleware(condition))
+		router.GET("/denied", func(ctx *gin.Context) { ctx.AbortWithStatus(http.StatusUnauthorized) })
+		router.GET("/allowed", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/allowed", nil))
+		assert.Nil(t, condition.Evaluate(time.Now()))
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/denied", nil))
+		assert.NotNil(t, condition.Evaluate(time.Now()))
+	})
+
+	t.Run("BreakerOpenCondition_Should_Fire_When_A_Breaker_Stays_Open", func(t *testing.T) {
+		breakers := resilience.NewBreakers()
+		settings := resilience.Settings{FailureThreshold: 1, ResetTimeout: time.Minute}
+		breakers.Breaker("users", settings).Failure()
+		breakers.Breaker("payments", settings)
+		condition := NewBreakerOpenCondition(breakers, 5*time.Minute)
+		now := time.Now()
+
+		assert.Nil(t, condition.Evaluate(now))
+
+		alert := condition.Evaluate(now.Add(6 * time.Minute))
+
+		assert.NotNil(t, alert)
+		assert.Equal(t, "circuit_breaker_open", alert.Name)
+		assert.Equal(t, SeverityCritical, alert.Severity)
+		assert.Equal(t, "Circuit breakers open for more than 5m0s: users", alert.Summary)
+		assert.Contains(t, alert.Details, "users")
+		assert.NotContains(t, alert.Details, "payments")
+	})
+
+	t.Run("BreakerOpenCondition_Should_Not_Fire_Once_The_Breaker_Closed", func(t *testing.T) {
+		breakers := resilience.NewBreakers()
+		breaker := breakers.Breaker("users", resilience.Settings{FailureThreshold: 1})
+		breaker.Failure()
+		assert.True(t, breaker.Allow())
+		breaker.Success()
+		condition := NewBreakerOpenCondition(breakers, 5*time.Minute)
+
+		assert.Nil(t, condition.Evaluate(time.Now().Add(time.Hour)))
+	})
+
+	t.Run("PagerDutyNotifier_Should_Post_Event", func(t *testing.T) {
+		var received map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&received)
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer server.Close()
+		notifier := NewPagerDutyNotifier(server.URL, "routing-key", server.Client())
+
+		err := notifier.Notify(context.Background(), Alert{
+			Name:     "example",
+			Severity: SeverityCritical,
+			Summary:  "example summary",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "routing-key", received["routing_key"])
+		assert.Equal(t, "trigger", received["event_action"])
+	})
+
+	t.Run("SlackNotifier_Should_Return_Error_On_Failure_Status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		notifier := NewSlackNotifier(server.URL, server.Client())
+
+		err := notifier.Notify(context.Background(), Alert{Name: "example"})
+
+		assert.Error(t, err)
+		assert.Equal(t, "Alert webhook responded with status 500", err.Error())
+	})
+}
@@ -0,0 +1,98 @@
+package alerting
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authErrorBuckets is the number of buckets the window of the failures is split into, the ring keeps one more
+// for the bucket the window starts in
+const authErrorBuckets = 60
+
+type failureBucket struct {
+	start time.Time
+	count int
+}
+
+// AuthErrorRateCondition raises an alert when authentication failures spike, the failures are counted in a
+// fixed ring of buckets so a spike does not grow the memory
+type AuthErrorRateCondition struct {
+	threshold   int
+	window      time.Duration
+	bucketWidth time.Duration
+	buckets     [authErrorBuckets + 1]failureBucket
+	mtx         sync.Mutex
+}
+
+var _ Conditioner = &AuthErrorRateCondition{}
+
+// NewAuthErrorRateCondition creates a condition firing when more than threshold failures happen within window
+func NewAuthErrorRateCondition(threshold int, window time.Duration) *AuthErrorRateCondition {
+	bucketWidth := window / authErrorBuckets
+	if bucketWidth <= 0 {
+		bucketWidth = time.Nanosecond
+	}
+	return &AuthErrorRateCondition{
+		threshold:   threshold,
+		window:      window,
+		bucketWidth: bucketWidth,
+	}
+}
+
+// RecordFailure records an authentication failure at the given time
+func (condition *AuthErrorRateCondition) RecordFailure(at time.Time) {
+	condition.mtx.Lock()
+	defer condition.mtx.Unlock()
+	start := at.Truncate(condition.bucketWidth)
+	bucket := &condition.buckets[(start.UnixNano()/int64(condition.bucketWidth))%(authErrorBuckets+1)]
+	if start.Before(bucket.start) {
+		return
+	}
+	if !start.Equal(bucket.start) {
+		*bucket = failureBucket{start: start}
+	}
+	bucket.count++
+}
+
+// Evaluate returns an alert if the failures within the window exceed the threshold
+func (condition *AuthErrorRateCondition) Evaluate(now time.Time) *Alert {
+	condition.mtx.Lock()
+	defer condition.mtx.Unlock()
+
+	windowStart := now.Add(-condition.window).Truncate(condition.bucketWidth)
+	count := 0
+	for _, bucket := range condition.buckets {
+		if !bucket.start.Before(windowStart) && !bucket.start.After(now) {
+			count += bucket.count
+		}
+	}
+	if count <= condition.threshold {
+		return nil
+	}
+	return &Alert{
+		Name:     "auth_error_rate_spike",
+		Severity: SeverityCritical,
+		Summary:  fmt.Sprintf("%d authentication failures in the last %s", count, condition.window),
+		Details: map[string]string{
+			"threshold": fmt.Sprintf("%d", condition.threshold),
+			"window":    condition.window.String(),
+		},
+		Timestamp: now,
+	}
+}
+
+// AuthFailureMonitorMiddleware records every 401/403 response in the condition
+func AuthFailureMonitorMiddleware(condition *AuthErrorRateCondition) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		if status == http.StatusUnauthorized || status == http.StatusForbidden {
+			condition.RecordFailure(time.Now())
+		}
+	}
+}
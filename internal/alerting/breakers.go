@@ -0,0 +1,54 @@
+package alerting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+)
+
+// BreakerOpenCondition raises an alert when circuit breakers stay open for too long
+type BreakerOpenCondition struct {
+	breakers *resilience.Breakers
+	duration time.Duration
+}
+
+var _ Conditioner = &BreakerOpenCondition{}
+
+// NewBreakerOpenCondition creates a condition firing when a breaker of the registry has not closed for more than
+// duration, the probes failing in between do not restart the count
+func NewBreakerOpenCondition(breakers *resilience.Breakers, duration time.Duration) *BreakerOpenCondition {
+	return &BreakerOpenCondition{
+		breakers: breakers,
+		duration: duration,
+	}
+}
+
+// Evaluate returns an alert naming the services whose breaker is open for more than the duration
+func (condition *BreakerOpenCondition) Evaluate(now time.Time) *Alert {
+	services := []string{}
+	details := map[string]string{"duration": condition.duration.String()}
+	for service, stats := range condition.breakers.Stats() {
+		if stats.State == resilience.StateClosed || stats.OpenSince.IsZero() {
+			continue
+		}
+		if now.Sub(stats.OpenSince) <= condition.duration {
+			continue
+		}
+		services = append(services, service)
+		details[service] = stats.OpenSince.UTC().Format(time.RFC3339)
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	sort.Strings(services)
+	return &Alert{
+		Name:      "circuit_breaker_open",
+		Severity:  SeverityCritical,
+		Summary:   fmt.Sprintf("Circuit breakers open for more than %s: %s", condition.duration, strings.Join(services, ", ")),
+		Details:   details,
+		Timestamp: now,
+	}
+}
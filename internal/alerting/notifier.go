@@ -0,0 +1,116 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Severity is the severity of an alert
+type Severity string
+
+// Alert severities
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// Alert is a critical gateway event to be notified
+type Alert struct {
+	Name      string
+	Severity  Severity
+	Summary   string
+	Source    string
+	Details   map[string]string
+	Timestamp time.Time
+}
+
+// Notifierer is the interface for sending alerts to an external channel
+type Notifierer interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// SlackNotifier sends alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+var _ Notifierer = &SlackNotifier{}
+
+// NewSlackNotifier creates a new Slack notifier
+func NewSlackNotifier(webhookURL string, client *http.Client) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		client:     client,
+	}
+}
+
+// Notify posts the alert to the Slack webhook
+func (notifier *SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	text := fmt.Sprintf("[%s] %s: %s", alert.Severity, alert.Name, alert.Summary)
+	for key, value := range alert.Details {
+		text += fmt.Sprintf("\n• %s: %s", key, value)
+	}
+	return postJSON(ctx, notifier.client, notifier.webhookURL, map[string]string{"text": text})
+}
+
+// PagerDutyNotifier sends alerts to the PagerDuty Events API v2
+type PagerDutyNotifier struct {
+	eventsURL  string
+	routingKey string
+	client     *http.Client
+}
+
+var _ Notifierer = &PagerDutyNotifier{}
+
+// NewPagerDutyNotifier creates a new PagerDuty notifier
+func NewPagerDutyNotifier(eventsURL, routingKey string, client *http.Client) *PagerDutyNotifier {
+	return &PagerDutyNotifier{
+		eventsURL:  eventsURL,
+		routingKey: routingKey,
+		client:     client,
+	}
+}
+
+// Notify triggers a PagerDuty event for the alert
+func (notifier *PagerDutyNotifier) Notify(ctx context.Context, alert Alert) error {
+	event := map[string]interface{}{
+		"routing_key":  notifier.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.Name,
+		"payload": map[string]interface{}{
+			"summary":        alert.Summary,
+			"source":         alert.Source,
+			"severity":       alert.Severity,
+			"timestamp":      alert.Timestamp.Format(time.RFC3339),
+			"custom_details": alert.Details,
+		},
+	}
+	return postJSON(ctx, notifier.client, notifier.eventsURL, event)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("Could not marshal alert payload: %v", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("Could not create alert request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Could not send alert: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("Alert webhook responded with status %d", response.StatusCode)
+	}
+	return nil
+}
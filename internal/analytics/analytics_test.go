@@ -0,0 +1,139 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+)
+
+type fakeStore struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (store *fakeStore) Put(ctx context.Context, key, contentType string, body []byte) error {
+	if store.err != nil {
+		return store.err
+	}
+	store.objects[key] = body
+	return nil
+}
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		Analytics: config.AnalyticsConfig{
+			Prefix:         "route-usage",
+			Formats:        []string{FormatJSON, FormatCSV},
+			ExportInterval: time.Hour,
+			CountryHeader:  "CloudFront-Viewer-Country",
+		},
+	}
+}
+
+func TestCollector(t *testing.T) {
+	t.Run("Middleware_Should_Aggregate_Route_Usage", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		collector := NewCollector(newTestConfig())
+		router := gin.New()
+		router.Use(collector.Middleware)
+		router.GET("/items/:id", func(ctx *gin.Context) {
+			ctx.Set(string(commonJWT.ClaimsContextKey), &commonJWT.TokenClaims{UserID: ctx.GetHeader("X-User")})
+			ctx.Request = ctx.Request.WithContext(versioning.ContextWithVersion(ctx.Request.Context(), versioning.Version{Major: 2, Minor: 1}))
+			ctx.Status(http.StatusOK)
+		})
+		for _, user := range []string{"user-1", "user-2", "user-1"} {
+			request := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+			request.Header.Set("X-User", user)
+			request.Header.Set(versioning.AppPlatformHeader, "iOS")
+			request.Header.Set("CloudFront-Viewer-Country", "es")
+			router.ServeHTTP(httptest.NewRecorder(), request)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+		reports := collector.Reports(time.Now().Add(24 * time.Hour))
+
+		assert.Len(t, reports, 1)
+		assert.Len(t, reports[0].Routes, 1)
+		assert.Equal(t, RouteUsage{
+			Method:         http.MethodGet,
+			Route:          "/items/:id",
+			Requests:       3,
+			UniqueUsers:    2,
+			ClientVersions: map[string]int{"ios/2.1.0": 3},
+			Countries:      map[string]int{"ES": 3},
+		}, *reports[0].Routes[0])
+	})
+
+	t.Run("Reports_Should_Keep_The_Current_Day", func(t *testing.T) {
+		collector := NewCollector(newTestConfig())
+		now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+		collector.Record(now.Add(-24*time.Hour), http.MethodGet, "/a", "", unknown, unknown, http.StatusInternalServerError)
+		collector.Record(now, http.MethodGet, "/a", "", unknown, unknown, http.StatusOK)
+
+		reports := collector.Reports(now)
+
+		assert.Len(t, reports, 1)
+		assert.Equal(t, "2026-03-01", reports[0].Date)
+		assert.Equal(t, 1, reports[0].Routes[0].Errors)
+		assert.Len(t, collector.Reports(now.Add(24*time.Hour)), 1)
+	})
+}
+
+func TestExporter(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Export_Should_Store_JSON_And_CSV_Reports", func(t *testing.T) {
+		collector := NewCollector(newTestConfig())
+		collector.Record(now.Add(-24*time.Hour), http.MethodPost, "/search", "user-1", "android/3.0.0", "GB", http.StatusOK)
+		store := &fakeStore{objects: make(map[string][]byte)}
+		exporter, err := NewExporter(collector, store, newTestConfig())
+		assert.NoError(t, err)
+
+		exporter.Export(context.Background(), now)
+
+		report := &Report{}
+		assert.NoError(t, json.Unmarshal(store.objects["route-usage/2026-03-01/route-usage.json"], report))
+		assert.Equal(t, 1, report.Routes[0].UniqueUsers)
+		assert.Equal(t,
+			"date,method,route,requests,errors,unique_users,client_versions,countries\n"+
+				"2026-03-01,POST,/search,1,0,1,android/3.0.0=1,GB=1\n",
+			string(store.objects["route-usage/2026-03-01/route-usage.csv"]),
+		)
+	})
+
+	t.Run("Export_Should_Retry_Failed_Reports", func(t *testing.T) {
+		collector := NewCollector(newTestConfig())
+		collector.Record(now.Add(-24*time.Hour), http.MethodGet, "/search", "user-1", unknown, unknown, http.StatusOK)
+		store := &fakeStore{objects: make(map[string][]byte), err: errors.New("unavailable")}
+		exporter, err := NewExporter(collector, store, newTestConfig())
+		assert.NoError(t, err)
+
+		exporter.Export(context.Background(), now)
+		store.err = nil
+		exporter.Export(context.Background(), now)
+
+		report := &Report{}
+		assert.NoError(t, json.Unmarshal(store.objects["route-usage/2026-03-01/route-usage.json"], report))
+		assert.Equal(t, 1, report.Routes[0].UniqueUsers)
+	})
+
+	t.Run("NewExporter_Should_Reject_Unknown_Formats", func(t *testing.T) {
+		configurations := newTestConfig()
+		configurations.Analytics.Formats = []string{"xml"}
+
+		_, err := NewExporter(NewCollector(configurations), &fakeStore{}, configurations)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,188 @@
+package analytics
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+)
+
+// DateLayout is the layout of the report dates
+const DateLayout = "2006-01-02"
+
+// unknown labels the requests whose client version or country could not be determined
+const unknown = "unknown"
+
+// RouteUsage is the usage of a route during a day
+type RouteUsage struct {
+	Method         string         `json:"method"`
+	Route          string         `json:"route"`
+	Requests       int            `json:"requests"`
+	Errors         int            `json:"errors"`
+	UniqueUsers    int            `json:"uniqueUsers"`
+	ClientVersions map[string]int `json:"clientVersions"`
+	Countries      map[string]int `json:"countries"`
+}
+
+// Report is the usage of every route during a day
+type Report struct {
+	Date   string        `json:"date"`
+	Routes []*RouteUsage `json:"routes"`
+}
+
+type routeCounter struct {
+	usage RouteUsage
+	users map[[sha256.Size]byte]bool
+}
+
+// Collector aggregates the usage of the routes per day
+type Collector struct {
+	countryHeader string
+	days          map[string]map[string]*routeCounter
+	mtx           sync.Mutex
+}
+
+// NewCollector creates a usage collector reading the country from the configured header
+func NewCollector(configurations *config.Config) *Collector {
+	return &Collector{
+		countryHeader: configurations.Analytics.CountryHeader,
+		days:          make(map[string]map[string]*routeCounter),
+	}
+}
+
+// Middleware records the request once the handlers have resolved the user and the response status
+func (collector *Collector) Middleware(ctx *gin.Context) {
+	ctx.Next()
+	route := ctx.FullPath()
+	if route == "" {
+		return
+	}
+	userID := ""
+	if claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey)); exists {
+		if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+			userID = claims.UserID
+		}
+	}
+	collector.Record(time.Now(), ctx.Request.Method, route, userID, clientVersion(ctx), collector.country(ctx), ctx.Writer.Status())
+}
+
+// Record adds a request to the usage of the route on the day of the given time
+func (collector *Collector) Record(now time.Time, method, route, userID, clientVersion, country string, status int) {
+	date := now.UTC().Format(DateLayout)
+	key := fmt.Sprintf("%s %s", method, route)
+
+	collector.mtx.Lock()
+	defer collector.mtx.Unlock()
+	routes, exists := collector.days[date]
+	if !exists {
+		routes = make(map[string]*routeCounter)
+		collector.days[date] = routes
+	}
+	counter, exists := routes[key]
+	if !exists {
+		counter = &routeCounter{
+			usage: RouteUsage{
+				Method:         method,
+				Route:          route,
+				ClientVersions: make(map[string]int),
+				Countries:      make(map[string]int),
+			},
+			users: make(map[[sha256.Size]byte]bool),
+		}
+		routes[key] = counter
+	}
+	counter.usage.Requests++
+	if status >= 500 {
+		counter.usage.Errors++
+	}
+	// Only a digest of the user is kept, the reports expose counts
+	if userID != "" {
+		counter.users[sha256.Sum256([]byte(userID))] = true
+	}
+	counter.usage.ClientVersions[clientVersion]++
+	counter.usage.Countries[country]++
+}
+
+// Reports removes and returns the reports of the days before the given time
+func (collector *Collector) Reports(now time.Time) []*Report {
+	today := now.UTC().Format(DateLayout)
+	collector.mtx.Lock()
+	defer collector.mtx.Unlock()
+	reports := []*Report{}
+	for date, routes := range collector.days {
+		if date >= today {
+			continue
+		}
+		reports = append(reports, newReport(date, routes))
+		delete(collector.days, date)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Date < reports[j].Date
+	})
+	return reports
+}
+
+// Restore puts back reports that could not be exported so they are retried on the next export
+func (collector *Collector) Restore(report *Report) {
+	collector.mtx.Lock()
+	defer collector.mtx.Unlock()
+	routes, exists := collector.days[report.Date]
+	if !exists {
+		routes = make(map[string]*routeCounter)
+		collector.days[report.Date] = routes
+	}
+	for _, usage := range report.Routes {
+		key := fmt.Sprintf("%s %s", usage.Method, usage.Route)
+		if _, exists := routes[key]; !exists {
+			routes[key] = &routeCounter{usage: *usage, users: make(map[[sha256.Size]byte]bool)}
+		}
+	}
+}
+
+func newReport(date string, routes map[string]*routeCounter) *Report {
+	report := &Report{Date: date, Routes: []*RouteUsage{}}
+	for _, counter := range routes {
+		usage := counter.usage
+		if len(counter.users) > 0 {
+			usage.UniqueUsers = len(counter.users)
+		}
+		report.Routes = append(report.Routes, &usage)
+	}
+	sort.Slice(report.Routes, func(i, j int) bool {
+		if report.Routes[i].Route != report.Routes[j].Route {
+			return report.Routes[i].Route < report.Routes[j].Route
+		}
+		return report.Routes[i].Method < report.Routes[j].Method
+	})
+	return report
+}
+
+func clientVersion(ctx *gin.Context) string {
+	version := versioning.VersionFromContext(ctx.Request.Context())
+	if version == nil {
+		return unknown
+	}
+	if platform := strings.ToLower(ctx.GetHeader(versioning.AppPlatformHeader)); platform != "" {
+		return fmt.Sprintf("%s/%s", platform, version.String())
+	}
+	return version.String()
+}
+
+func (collector *Collector) country(ctx *gin.Context) string {
+	if collector.countryHeader == "" {
+		return unknown
+	}
+	country := strings.ToUpper(strings.TrimSpace(ctx.GetHeader(collector.countryHeader)))
+	if len(country) != 2 {
+		return unknown
+	}
+	return country
+}
@@ -0,0 +1,188 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Report formats supported by the exporter
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Storer stores the exported reports
+type Storer interface {
+	Put(ctx context.Context, key, contentType string, body []byte) error
+}
+
+type s3API interface {
+	PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error)
+}
+
+// S3Store stores the reports in an S3 bucket
+type S3Store struct {
+	client s3API
+	bucket string
+}
+
+var _ Storer = &S3Store{}
+
+// NewS3Store creates the store of the bucket configured for the reports
+func NewS3Store(configurations *config.Config) (*S3Store, error) {
+	awsSession, err := session.NewSession(
+		&aws.Config{
+			Region: aws.String(configurations.Analytics.Region),
+			Credentials: credentials.NewStaticCredentials(
+				configurations.AWS.Key,
+				configurations.AWS.Secret,
+				"",
+			),
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create AWS session for the analytics reports: %v", err)
+	}
+	return &S3Store{client: s3.New(awsSession), bucket: configurations.Analytics.Bucket}, nil
+}
+
+// Put uploads the report to the bucket
+func (store *S3Store) Put(ctx context.Context, key, contentType string, body []byte) error {
+	_, err := store.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(store.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        bytes.NewReader(body),
+	})
+	if err != nil {
+		return fmt.Errorf("Could not upload analytics report %s: %v", key, err)
+	}
+	return nil
+}
+
+// Exporter periodically exports the reports of the finished days
+type Exporter struct {
+	collector *Collector
+	store     Storer
+	prefix    string
+	formats   []string
+	interval  time.Duration
+	logger    commonLogger.Loggerer
+}
+
+// NewExporter creates the exporter of the collected usage
+func NewExporter(collector *Collector, store Storer, configurations *config.Config) (*Exporter, error) {
+	for _, format := range configurations.Analytics.Formats {
+		if format != FormatJSON && format != FormatCSV {
+			return nil, fmt.Errorf("Unsupported analytics report format: %s", format)
+		}
+	}
+	return &Exporter{
+		collector: collector,
+		store:     store,
+		prefix:    configurations.Analytics.Prefix,
+		formats:   configurations.Analytics.Formats,
+		interval:  configurations.Analytics.ExportInterval,
+		logger:    commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}, nil
+}
+
+// Export stores the reports of the days before the given time, keeping the failed ones for the next export
+func (exporter *Exporter) Export(ctx context.Context, now time.Time) {
+	for _, report := range exporter.collector.Reports(now) {
+		if err := exporter.exportReport(ctx, report); err != nil {
+			exporter.logger.Error(err, "Could not export analytics report")
+			exporter.collector.Restore(report)
+			continue
+		}
+		exporter.logger.Info(fmt.Sprintf("Exported analytics report of %s", report.Date))
+	}
+}
+
+// Job exports the finished days on every interval
+func (exporter *Exporter) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "analytics_export",
+		Schedule: scheduler.Every(exporter.interval),
+		Run: func(ctx context.Context, now time.Time) error {
+			exporter.Export(ctx, now)
+			return nil
+		},
+	}
+}
+
+func (exporter *Exporter) exportReport(ctx context.Context, report *Report) error {
+	for _, format := range exporter.formats {
+		var body []byte
+		var err error
+		contentType := "application/json"
+		if format == FormatCSV {
+			body, err = EncodeCSV(report)
+			contentType = "text/csv"
+		} else {
+			body, err = json.Marshal(report)
+		}
+		if err != nil {
+			return fmt.Errorf("Could not encode analytics report: %v", err)
+		}
+		key := path.Join(exporter.prefix, report.Date, fmt.Sprintf("route-usage.%s", format))
+		if err := exporter.store.Put(ctx, key, contentType, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeCSV encodes the report with one row per route, breakdowns are written as key=count pairs
+func EncodeCSV(report *Report) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	rows := [][]string{{"date", "method", "route", "requests", "errors", "unique_users", "client_versions", "countries"}}
+	for _, usage := range report.Routes {
+		rows = append(rows, []string{
+			report.Date,
+			usage.Method,
+			usage.Route,
+			strconv.Itoa(usage.Requests),
+			strconv.Itoa(usage.Errors),
+			strconv.Itoa(usage.UniqueUsers),
+			encodeBreakdown(usage.ClientVersions),
+			encodeBreakdown(usage.Countries),
+		})
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func encodeBreakdown(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", key, counts[key]))
+	}
+	return strings.Join(pairs, ";")
+}
@@ -0,0 +1,74 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+const authenticatorDataMinLength = 37
+
+// AppAttestVerifier verifies App Attest assertions signed by keys registered at attestation time
+type AppAttestVerifier struct {
+	appIDHash [32]byte
+	keys      KeyStorer
+}
+
+var _ Verifierer = &AppAttestVerifier{}
+
+// NewAppAttestVerifier creates a verifier for the app id, the team id and bundle id joined by a dot
+func NewAppAttestVerifier(appID string, keys KeyStorer) *AppAttestVerifier {
+	return &AppAttestVerifier{
+		appIDHash: sha256.Sum256([]byte(appID)),
+		keys:      keys,
+	}
+}
+
+// Verify checks the assertion signature over the client data, the app id and that the counter increased
+func (verifier *AppAttestVerifier) Verify(ctx context.Context, request *Request) (*Verdict, error) {
+	if request.KeyID == "" {
+		return &Verdict{Reason: "missing_key_id"}, nil
+	}
+	key, err := verifier.keys.GetKey(ctx, request.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return &Verdict{Reason: "unknown_key"}, nil
+	}
+	rawAssertion, err := base64.StdEncoding.DecodeString(request.Token)
+	if err != nil {
+		return &Verdict{Reason: "malformed_assertion"}, nil
+	}
+	assertion, err := decodeCBORByteMap(rawAssertion)
+	if err != nil {
+		return &Verdict{Reason: "malformed_assertion"}, nil
+	}
+	authenticatorData := assertion["authenticatorData"]
+	signature := assertion["signature"]
+	if len(authenticatorData) < authenticatorDataMinLength || len(signature) == 0 {
+		return &Verdict{Reason: "malformed_assertion"}, nil
+	}
+
+	clientDataHash := sha256.Sum256(request.ClientData)
+	nonce := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+	digest := sha256.Sum256(nonce[:])
+	if !ecdsa.VerifyASN1(key.PublicKey, digest[:], signature) {
+		return &Verdict{Reason: "invalid_signature"}, nil
+	}
+	if !bytes.Equal(authenticatorData[:32], verifier.appIDHash[:]) {
+		return &Verdict{Reason: "app_id_mismatch"}, nil
+	}
+	counter := binary.BigEndian.Uint32(authenticatorData[33:37])
+	if counter <= key.Counter {
+		return &Verdict{Reason: "replayed_assertion"}, nil
+	}
+	if err := verifier.keys.UpdateCounter(ctx, request.KeyID, counter); err != nil {
+		return nil, fmt.Errorf("Could not record App Attest counter: %v", err)
+	}
+	return &Verdict{Trusted: true}, nil
+}
@@ -0,0 +1,214 @@
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Headers carrying the attestation of mobile clients
+const (
+	PlatformHeader  = "X-Attestation-Platform"
+	TokenHeader     = "X-Attestation-Token"
+	KeyIDHeader     = "X-Attestation-Key-ID"
+	TimestampHeader = "X-Attestation-Timestamp"
+	DeviceIDHeader  = "X-Device-ID"
+)
+
+// Supported platforms
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+)
+
+const maxTimestampSkew = 5 * time.Minute
+
+// Request is the attestation presented by a mobile client
+type Request struct {
+	Platform string
+	Token    string
+	KeyID    string
+	DeviceID string
+	// ClientData is what the app bound the attestation to: method, request URI and timestamp
+	ClientData []byte
+}
+
+// ClientDataHash returns the hex SHA-256 of the client data the app sends as the request hash
+func (request *Request) ClientDataHash() string {
+	sum := sha256.Sum256(request.ClientData)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verdict is the outcome of an attestation, with the reason it was not trusted
+type Verdict struct {
+	Trusted bool
+	Reason  string
+}
+
+// Verifierer verifies the attestation of a platform
+type Verifierer interface {
+	Verify(ctx context.Context, request *Request) (*Verdict, error)
+}
+
+// NewVerifiers creates the verifiers of the platforms configured
+func NewVerifiers(configurations *config.Config, client *http.Client) map[string]Verifierer {
+	verifiers := make(map[string]Verifierer)
+	playIntegrity := configurations.Attestation.PlayIntegrity
+	if playIntegrity.PackageName != "" {
+		verifiers[PlatformAndroid] = NewPlayIntegrityVerifier(
+			playIntegrity.DecodeURL,
+			playIntegrity.PackageName,
+			playIntegrity.CertificateDigests,
+			playIntegrity.AccessToken,
+			client,
+		)
+	}
+	if configurations.Attestation.AppAttest.AppID != "" {
+		verifiers[PlatformIOS] = NewAppAttestVerifier(configurations.Attestation.AppAttest.AppID, NewKeyStore(configurations))
+	}
+	return verifiers
+}
+
+type cachedVerdict struct {
+	verdict   Verdict
+	expiresAt time.Time
+}
+
+// Gate requires an attested app on the configured mobile routes, caching the verdicts per device
+type Gate struct {
+	verifiers  map[string]Verifierer
+	routes     map[string]bool
+	trustedTTL time.Duration
+	failedTTL  time.Duration
+	verdicts   map[string]cachedVerdict
+	mtx        sync.Mutex
+}
+
+// NewGate creates the attestation gate for the routes listed in the configuration
+func NewGate(verifiers map[string]Verifierer, configurations *config.Config) *Gate {
+	routes := make(map[string]bool)
+	for _, route := range configurations.Attestation.Routes {
+		routes[route] = true
+	}
+	return &Gate{
+		verifiers:  verifiers,
+		routes:     routes,
+		trustedTTL: configurations.Attestation.CacheTTL,
+		failedTTL:  configurations.Attestation.FailureCacheTTL,
+		verdicts:   make(map[string]cachedVerdict),
+	}
+}
+
+func (gate *Gate) isGated(ctx *gin.Context) bool {
+	return gate.routes[ctx.FullPath()] || gate.routes[fmt.Sprintf("%s %s", ctx.Request.Method, ctx.FullPath())]
+}
+
+// RequireAttestation blocks requests to the configured routes from emulators and tampered builds
+func (gate *Gate) RequireAttestation(ctx *gin.Context) {
+	if !gate.isGated(ctx) {
+		ctx.Next()
+		return
+	}
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	request := newRequest(ctx)
+	verifier, supported := gate.verifiers[request.Platform]
+	if !supported || request.DeviceID == "" {
+		logger.Error(nil, "No supported attestation was present in the request")
+		errors.Abort(ctx, errors.AttestationRequired)
+		return
+	}
+
+	cacheKey := request.Platform + ":" + request.DeviceID
+	verdict := gate.cachedVerdict(cacheKey)
+	if verdict == nil {
+		if request.Token == "" || !isRecent(ctx.GetHeader(TimestampHeader)) {
+			logger.Error(nil, "No fresh attestation was present in the request")
+			errors.Abort(ctx, errors.AttestationRequired)
+			return
+		}
+		verdict, err = verifier.Verify(ctx.Request.Context(), request)
+		if err != nil {
+			logger.Error(err, "Could not verify the app attestation")
+			errors.Abort(ctx, errors.ServiceUnavailable)
+			return
+		}
+		gate.cacheVerdict(cacheKey, *verdict)
+	}
+	if !verdict.Trusted {
+		logger.Error(nil, fmt.Sprintf("The app attestation was rejected: %s", verdict.Reason))
+		errors.Abort(ctx, errors.AttestationFailed)
+		return
+	}
+	ctx.Next()
+}
+
+func newRequest(ctx *gin.Context) *Request {
+	request := &Request{
+		Platform: strings.ToLower(ctx.GetHeader(PlatformHeader)),
+		Token:    ctx.GetHeader(TokenHeader),
+		KeyID:    ctx.GetHeader(KeyIDHeader),
+		DeviceID: ctx.GetHeader(DeviceIDHeader),
+		ClientData: []byte(fmt.Sprintf(
+			"%s %s %s",
+			ctx.Request.Method,
+			ctx.Request.URL.RequestURI(),
+			ctx.GetHeader(TimestampHeader),
+		)),
+	}
+	// App Attest keys are bound to the app instance so they identify the device better than the header
+	if request.Platform == PlatformIOS && request.KeyID != "" {
+		request.DeviceID = request.KeyID
+	}
+	return request
+}
+
+func isRecent(timestamp string) bool {
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return false
+	}
+	skew := time.Since(parsed)
+	return skew < maxTimestampSkew && skew > -maxTimestampSkew
+}
+
+func (gate *Gate) cachedVerdict(key string) *Verdict {
+	gate.mtx.Lock()
+	defer gate.mtx.Unlock()
+	cached, exists := gate.verdicts[key]
+	if !exists {
+		return nil
+	}
+	if time.Now().After(cached.expiresAt) {
+		delete(gate.verdicts, key)
+		return nil
+	}
+	return &cached.verdict
+}
+
+func (gate *Gate) cacheVerdict(key string, verdict Verdict) {
+	ttl := gate.trustedTTL
+	if !verdict.Trusted {
+		ttl = gate.failedTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	gate.mtx.Lock()
+	defer gate.mtx.Unlock()
+	gate.verdicts[key] = cachedVerdict{verdict: verdict, expiresAt: time.Now().Add(ttl)}
+}
@@ -0,0 +1,235 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+const testAppID = "TEAMID.com.example.app"
+
+type countingVerifier struct {
+	verdict Verdict
+	calls   int
+}
+
+func (verifier *countingVerifier) Verify(ctx context.Context, request *Request) (*Verdict, error) {
+	verifier.calls++
+	verdict := verifier.verdict
+	return &verdict, nil
+}
+
+func encodeCBORByteMap(values map[string][]byte) []byte {
+	head := func(majorType byte, length int) []byte {
+		if length < 24 {
+			return []byte{majorType<<5 | byte(length)}
+		}
+		encoded := []byte{majorType<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(encoded[1:], uint16(length))
+		return encoded
+	}
+	encoded := head(5, len(values))
+	for key, value := range values {
+		encoded = append(encoded, head(3, len(key))...)
+		encoded = append(encoded, key...)
+		encoded = append(encoded, head(2, len(value))...)
+		encoded = append(encoded, value...)
+	}
+	return encoded
+}
+
+func createAssertion(t *testing.T, privateKey *ecdsa.PrivateKey, appID string, counter uint32, clientData []byte) string {
+	appIDHash := sha256.Sum256([]byte(appID))
+	authenticatorData := append(appIDHash[:], 0x40, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(authenticatorData[33:], counter)
+	clientDataHash := sha256.Sum256(clientData)
+	nonce := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+	digest := sha256.Sum256(nonce[:])
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+	assert.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(encodeCBORByteMap(map[string][]byte{
+		"signature":         signature,
+		"authenticatorData": authenticatorData,
+	}))
+}
+
+func TestAppAttestVerifier(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	clientData := []byte("POST /api/v1/payments/charges 2024-01-01T00:00:00Z")
+
+	t.Run("Verify_Valid_Assertion_Success", func(t *testing.T) {
+		keys := NewMemoryKeyStore()
+		keys.Register("key-id", &privateKey.PublicKey)
+		verifier := NewAppAttestVerifier(testAppID, keys)
+
+		verdict, err := verifier.Verify(context.Background(), &Request{
+			KeyID:      "key-id",
+			Token:      createAssertion(t, privateKey, testAppID, 1, clientData),
+			ClientData: clientData,
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, verdict.Trusted)
+		key, _ := keys.GetKey(context.Background(), "key-id")
+		assert.Equal(t, uint32(1), key.Counter)
+	})
+
+	t.Run("Verify_Replayed_Counter_Error", func(t *testing.T) {
+		keys := NewMemoryKeyStore()
+		keys.Register("key-id", &privateKey.PublicKey)
+		verifier := NewAppAttestVerifier(testAppID, keys)
+		assertion := createAssertion(t, privateKey, testAppID, 1, clientData)
+		request := &Request{KeyID: "key-id", Token: assertion, ClientData: clientData}
+
+		_, err := verifier.Verify(context.Background(), request)
+		assert.NoError(t, err)
+		verdict, err := verifier.Verify(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, verdict.Trusted)
+		assert.Equal(t, "replayed_assertion", verdict.Reason)
+	})
+
+	t.Run("Verify_Tampered_Client_Data_Error", func(t *testing.T) {
+		keys := NewMemoryKeyStore()
+		keys.Register("key-id", &privateKey.PublicKey)
+		verifier := NewAppAttestVerifier(testAppID, keys)
+
+		verdict, err := verifier.Verify(context.Background(), &Request{
+			KeyID:      "key-id",
+			Token:      createAssertion(t, privateKey, testAppID, 1, clientData),
+			ClientData: []byte("GET /api/v1/other"),
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, verdict.Trusted)
+		assert.Equal(t, "invalid_signature", verdict.Reason)
+	})
+
+	t.Run("Verify_Other_App_Error", func(t *testing.T) {
+		keys := NewMemoryKeyStore()
+		keys.Register("key-id", &privateKey.PublicKey)
+		verifier := NewAppAttestVerifier(testAppID, keys)
+
+		verdict, err := verifier.Verify(context.Background(), &Request{
+			KeyID:      "key-id",
+			Token:      createAssertion(t, privateKey, "TEAMID.com.example.repackaged", 1, clientData),
+			ClientData: clientData,
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, verdict.Trusted)
+		assert.Equal(t, "app_id_mismatch", verdict.Reason)
+	})
+
+	t.Run("Verify_Unknown_Key_Error", func(t *testing.T) {
+		verifier := NewAppAttestVerifier(testAppID, NewMemoryKeyStore())
+
+		verdict, err := verifier.Verify(context.Background(), &Request{KeyID: "key-id", Token: "abc"})
+
+		assert.NoError(t, err)
+		assert.False(t, verdict.Trusted)
+		assert.Equal(t, "unknown_key", verdict.Reason)
+	})
+}
+
+func newGateRouter(gate *Gate, logger commonLogger.Loggerer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, logger))
+	}, gate.RequireAttestation)
+	router.POST("/charges", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	router.GET("/open", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	return router
+}
+
+func newAttestedRequest(method, path string) *http.Request {
+	request := httptest.NewRequest(method, path, nil)
+	request.Header.Set(PlatformHeader, PlatformAndroid)
+	request.Header.Set(DeviceIDHeader, "device-id")
+	request.Header.Set(TokenHeader, "integrity-token")
+	request.Header.Set(TimestampHeader, time.Now().UTC().Format(time.RFC3339))
+	return request
+}
+
+func TestGate(t *testing.T) {
+	configurations := &config.Config{Attestation: config.AttestationConfig{
+		Routes:          []string{"POST /charges"},
+		CacheTTL:        time.Hour,
+		FailureCacheTTL: time.Minute,
+	}}
+
+	t.Run("RequireAttestation_Should_Cache_Trusted_Verdict_Per_Device", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		verifier := &countingVerifier{verdict: Verdict{Trusted: true}}
+		router := newGateRouter(NewGate(map[string]Verifierer{PlatformAndroid: verifier}, configurations), commonLoggerMock.NewMockLoggerer(controller))
+
+		first := httptest.NewRecorder()
+		router.ServeHTTP(first, newAttestedRequest(http.MethodPost, "/charges"))
+		second := httptest.NewRecorder()
+		router.ServeHTTP(second, newAttestedRequest(http.MethodPost, "/charges"))
+
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, 1, verifier.calls)
+	})
+
+	t.Run("RequireAttestation_Untrusted_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		verifier := &countingVerifier{verdict: Verdict{Reason: "device_integrity"}}
+		router := newGateRouter(NewGate(map[string]Verifierer{PlatformAndroid: verifier}, configurations), loggerMock)
+
+		loggerMock.EXPECT().Error(nil, "The app attestation was rejected: device_integrity")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newAttestedRequest(http.MethodPost, "/charges"))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "attestation_failed")
+	})
+
+	t.Run("RequireAttestation_Missing_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		router := newGateRouter(NewGate(map[string]Verifierer{PlatformAndroid: &countingVerifier{}}, configurations), loggerMock)
+
+		loggerMock.EXPECT().Error(nil, "No supported attestation was present in the request")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/charges", nil))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "attestation_required")
+	})
+
+	t.Run("RequireAttestation_Not_Gated_Route_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		router := newGateRouter(NewGate(map[string]Verifierer{}, configurations), commonLoggerMock.NewMockLoggerer(controller))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/open", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,85 @@
+package attestation
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// decodeCBORByteMap decodes a CBOR map of text keys to byte strings, the shape of App Attest assertions
+func decodeCBORByteMap(data []byte) (map[string][]byte, error) {
+	reader := &cborReader{data: data}
+	majorType, length, err := reader.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if majorType != 5 {
+		return nil, fmt.Errorf("Expected a CBOR map but found major type %d", majorType)
+	}
+	values := make(map[string][]byte, length)
+	for index := uint64(0); index < length; index++ {
+		key, err := reader.readString(3)
+		if err != nil {
+			return nil, err
+		}
+		value, err := reader.readString(2)
+		if err != nil {
+			return nil, err
+		}
+		values[string(key)] = value
+	}
+	return values, nil
+}
+
+type cborReader struct {
+	data   []byte
+	offset int
+}
+
+func (reader *cborReader) readHead() (byte, uint64, error) {
+	if reader.offset >= len(reader.data) {
+		return 0, 0, fmt.Errorf("Unexpected end of CBOR data")
+	}
+	initial := reader.data[reader.offset]
+	reader.offset++
+	majorType := initial >> 5
+	additional := initial & 0x1f
+	switch {
+	case additional < 24:
+		return majorType, uint64(additional), nil
+	case additional <= 27:
+		size := 1 << (additional - 24)
+		if reader.offset+size > len(reader.data) {
+			return 0, 0, fmt.Errorf("Unexpected end of CBOR data")
+		}
+		bytes := reader.data[reader.offset : reader.offset+size]
+		reader.offset += size
+		switch size {
+		case 1:
+			return majorType, uint64(bytes[0]), nil
+		case 2:
+			return majorType, uint64(binary.BigEndian.Uint16(bytes)), nil
+		case 4:
+			return majorType, uint64(binary.BigEndian.Uint32(bytes)), nil
+		default:
+			return majorType, binary.BigEndian.Uint64(bytes), nil
+		}
+	default:
+		return 0, 0, fmt.Errorf("Unsupported CBOR length encoding %d", additional)
+	}
+}
+
+func (reader *cborReader) readString(expectedType byte) ([]byte, error) {
+	majorType, length, err := reader.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if majorType != expectedType {
+		return nil, fmt.Errorf("Expected CBOR major type %d but found %d", expectedType, majorType)
+	}
+	if length > uint64(len(reader.data)-reader.offset) {
+		return nil, fmt.Errorf("Unexpected end of CBOR data")
+	}
+	value := reader.data[reader.offset : reader.offset+int(length)]
+	reader.offset += int(length)
+	return value, nil
+}
@@ -0,0 +1,133 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+)
+
+const appAttestKeyPrefix = "attestation:app_attest:"
+
+// AppAttestKey is the public key of an attested iOS app instance and the last assertion counter it used
+type AppAttestKey struct {
+	PublicKey *ecdsa.PublicKey
+	Counter   uint32
+}
+
+// KeyStorer keeps the App Attest keys registered when the app instances were attested
+type KeyStorer interface {
+	GetKey(ctx context.Context, keyID string) (*AppAttestKey, error)
+	UpdateCounter(ctx context.Context, keyID string, counter uint32) error
+}
+
+// NewKeyStore creates a redis key store when redis is configured and an in memory one otherwise
+func NewKeyStore(configurations *config.Config) KeyStorer {
+	if configurations.Redis.Address == "" {
+		return NewMemoryKeyStore()
+	}
+	return NewRedisKeyStore(redis.NewClient(configurations))
+}
+
+// RedisKeyStore reads the keys stored by the attestation registration as "base64 PKIX key:counter"
+type RedisKeyStore struct {
+	client redis.Clienter
+}
+
+var _ KeyStorer = &RedisKeyStore{}
+
+// NewRedisKeyStore creates a key store backed by redis
+func NewRedisKeyStore(client redis.Clienter) *RedisKeyStore {
+	return &RedisKeyStore{client: client}
+}
+
+// GetKey returns the key registered with the id or nil when there is none
+func (store *RedisKeyStore) GetKey(ctx context.Context, keyID string) (*AppAttestKey, error) {
+	value, err := store.client.Get(ctx, appAttestKeyPrefix+keyID)
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not get App Attest key: %v", err)
+	}
+	encodedKey, encodedCounter, _ := strings.Cut(value, ":")
+	der, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid App Attest key encoding: %v", err)
+	}
+	parsedKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid App Attest key: %v", err)
+	}
+	publicKey, ok := parsedKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("The App Attest key is not an ECDSA key")
+	}
+	counter, _ := strconv.ParseUint(encodedCounter, 10, 32)
+	return &AppAttestKey{PublicKey: publicKey, Counter: uint32(counter)}, nil
+}
+
+// UpdateCounter stores the last counter used by the key
+func (store *RedisKeyStore) UpdateCounter(ctx context.Context, keyID string, counter uint32) error {
+	value, err := store.client.Get(ctx, appAttestKeyPrefix+keyID)
+	if err != nil {
+		return fmt.Errorf("Could not get App Attest key: %v", err)
+	}
+	encodedKey, _, _ := strings.Cut(value, ":")
+	_, err = store.client.Do(ctx, "SET", appAttestKeyPrefix+keyID, fmt.Sprintf("%s:%d", encodedKey, counter), "KEEPTTL")
+	if err != nil {
+		return fmt.Errorf("Could not update App Attest key counter: %v", err)
+	}
+	return nil
+}
+
+// MemoryKeyStore keeps the App Attest keys in process, used when redis is not configured
+type MemoryKeyStore struct {
+	keys map[string]AppAttestKey
+	mtx  sync.Mutex
+}
+
+var _ KeyStorer = &MemoryKeyStore{}
+
+// NewMemoryKeyStore creates an in memory key store
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: make(map[string]AppAttestKey)}
+}
+
+// Register stores the key of an attested app instance
+func (store *MemoryKeyStore) Register(keyID string, publicKey *ecdsa.PublicKey) {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	store.keys[keyID] = AppAttestKey{PublicKey: publicKey}
+}
+
+// GetKey returns the key registered with the id or nil when there is none
+func (store *MemoryKeyStore) GetKey(ctx context.Context, keyID string) (*AppAttestKey, error) {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	key, exists := store.keys[keyID]
+	if !exists {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+// UpdateCounter stores the last counter used by the key
+func (store *MemoryKeyStore) UpdateCounter(ctx context.Context, keyID string, counter uint32) error {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	key, exists := store.keys[keyID]
+	if !exists {
+		return fmt.Errorf("Unknown App Attest key %s", keyID)
+	}
+	key.Counter = counter
+	store.keys[keyID] = key
+	return nil
+}
@@ -0,0 +1,125 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const playIntegrityDecodeURL = "https://playintegrity.googleapis.com/v1/%s:decodeIntegrityToken"
+
+// Play Integrity verdicts required to trust a device
+const (
+	PlayRecognizedVerdict       = "PLAY_RECOGNIZED"
+	MeetsDeviceIntegrityVerdict = "MEETS_DEVICE_INTEGRITY"
+)
+
+// PlayIntegrityVerifier decodes Play Integrity tokens with the Google API and checks their verdicts
+type PlayIntegrityVerifier struct {
+	decodeURL          string
+	packageName        string
+	certificateDigests []string
+	accessToken        string
+	client             *http.Client
+}
+
+var _ Verifierer = &PlayIntegrityVerifier{}
+
+// NewPlayIntegrityVerifier creates a verifier for the package signed with one of the certificate digests
+func NewPlayIntegrityVerifier(
+	decodeURL,
+	packageName string,
+	certificateDigests []string,
+	accessToken string,
+	client *http.Client,
+) *PlayIntegrityVerifier {
+	if decodeURL == "" {
+		decodeURL = fmt.Sprintf(playIntegrityDecodeURL, packageName)
+	}
+	return &PlayIntegrityVerifier{
+		decodeURL:          decodeURL,
+		packageName:        packageName,
+		certificateDigests: certificateDigests,
+		accessToken:        accessToken,
+		client:             client,
+	}
+}
+
+type integrityPayload struct {
+	TokenPayloadExternal struct {
+		RequestDetails struct {
+			RequestPackageName string `json:"requestPackageName"`
+			Nonce              string `json:"nonce"`
+			RequestHash        string `json:"requestHash"`
+		} `json:"requestDetails"`
+		AppIntegrity struct {
+			AppRecognitionVerdict   string   `json:"appRecognitionVerdict"`
+			PackageName             string   `json:"packageName"`
+			CertificateSha256Digest []string `json:"certificateSha256Digest"`
+		} `json:"appIntegrity"`
+		DeviceIntegrity struct {
+			DeviceRecognitionVerdict []string `json:"deviceRecognitionVerdict"`
+		} `json:"deviceIntegrity"`
+	} `json:"tokenPayloadExternal"`
+}
+
+// Verify decodes the integrity token and requires a recognized app on a device meeting integrity
+func (verifier *PlayIntegrityVerifier) Verify(ctx context.Context, request *Request) (*Verdict, error) {
+	body, err := json.Marshal(map[string]string{"integrity_token": request.Token})
+	if err != nil {
+		return nil, fmt.Errorf("Could not encode integrity token: %v", err)
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, verifier.decodeURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Could not create integrity token request: %v", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Authorization", "Bearer "+verifier.accessToken)
+	response, err := verifier.client.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decode integrity token: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusBadRequest {
+		return &Verdict{Reason: "malformed_token"}, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Play Integrity responded with status %d", response.StatusCode)
+	}
+
+	payload := integrityPayload{}
+	if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("Could not decode integrity token response: %v", err)
+	}
+	external := payload.TokenPayloadExternal
+	if external.RequestDetails.RequestPackageName != verifier.packageName {
+		return &Verdict{Reason: "package_mismatch"}, nil
+	}
+	if external.RequestDetails.RequestHash != request.ClientDataHash() {
+		return &Verdict{Reason: "request_mismatch"}, nil
+	}
+	if external.AppIntegrity.AppRecognitionVerdict != PlayRecognizedVerdict {
+		return &Verdict{Reason: "unrecognized_app"}, nil
+	}
+	if len(verifier.certificateDigests) > 0 && !containsAny(external.AppIntegrity.CertificateSha256Digest, verifier.certificateDigests) {
+		return &Verdict{Reason: "certificate_mismatch"}, nil
+	}
+	if !containsAny(external.DeviceIntegrity.DeviceRecognitionVerdict, []string{MeetsDeviceIntegrityVerdict}) {
+		return &Verdict{Reason: "device_integrity"}, nil
+	}
+	return &Verdict{Trusted: true}, nil
+}
+
+func containsAny(values, expected []string) bool {
+	for _, value := range values {
+		for _, expectedValue := range expected {
+			if strings.EqualFold(value, expectedValue) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package authentication
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Token claims used by the age gate
+const (
+	DateOfBirthClaim     = "date_of_birth"
+	ParentalConsentClaim = "parental_consent"
+)
+
+// AgeGate enforces a minimum age or parental consent on the configured routes
+type AgeGate struct {
+	service            ServiceClienter
+	jwtTokenInspector  commonJWT.TokenInspectorer
+	minimumAge         int
+	consentMinimumAge  int
+	routes             map[string]bool
+	birthDates         map[string]time.Time
+	birthDatesCachedAt map[string]time.Time
+	cacheTTL           time.Duration
+	mtx                sync.Mutex
+}
+
+// NewAgeGate creates the age gate for the routes listed in the configuration
+func NewAgeGate(service ServiceClienter, jwtTokenInspector commonJWT.TokenInspectorer, configurations *config.Config) *AgeGate {
+	routes := make(map[string]bool)
+	for _, route := range configurations.AgeGate.Routes {
+		routes[route] = true
+	}
+	return &AgeGate{
+		service:            service,
+		jwtTokenInspector:  jwtTokenInspector,
+		minimumAge:         configurations.AgeGate.MinimumAge,
+		consentMinimumAge:  configurations.AgeGate.ConsentMinimumAge,
+		routes:             routes,
+		birthDates:         make(map[string]time.Time),
+		birthDatesCachedAt: make(map[string]time.Time),
+		cacheTTL:           configurations.Authentication.VerificationCacheTTL,
+	}
+}
+
+// AgeAt returns the age in full years at the given time
+func AgeAt(dateOfBirth, now time.Time) int {
+	years := now.Year() - dateOfBirth.Year()
+	if now.Month() < dateOfBirth.Month() || (now.Month() == dateOfBirth.Month() && now.Day() < dateOfBirth.Day()) {
+		years--
+	}
+	return years
+}
+
+func (ageGate *AgeGate) isGated(ctx *gin.Context) bool {
+	return ageGate.routes[ctx.FullPath()] || ageGate.routes[fmt.Sprintf("%s %s", ctx.Request.Method, ctx.FullPath())]
+}
+
+// RequireAge blocks users under the minimum age that have no parental consent on the configured routes
+func (ageGate *AgeGate) RequireAge(ctx *gin.Context) {
+	if ageGate.minimumAge <= 0 || !ageGate.isGated(ctx) {
+		ctx.Next()
+		return
+	}
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey))
+	claims, ok := claimsValue.(*commonJWT.TokenClaims)
+	if !exists || !ok {
+		logger.Error(nil, "No authenticated user claims were found in the request")
+		errors.Abort(ctx, errors.Unauthenticated)
+		return
+	}
+	tokenValue, _ := ctx.Get(string(commonJWT.JWTTokenKey))
+	token, _ := tokenValue.(*jwt.Token)
+
+	dateOfBirth, err := ageGate.getDateOfBirth(ctx, token, claims.UserID)
+	if err != nil {
+		logger.Error(err, "Could not obtain the user date of birth")
+		errors.HandleError(ctx, err)
+		return
+	}
+	age := -1
+	if dateOfBirth != nil {
+		age = AgeAt(*dateOfBirth, time.Now())
+	}
+	if age >= ageGate.minimumAge || ageGate.hasParentalConsent(token) {
+		ctx.Next()
+		return
+	}
+	if age >= ageGate.consentMinimumAge && ageGate.consentMinimumAge > 0 {
+		logger.Error(nil, "The user requires parental consent to access the route")
+		errors.Abort(ctx, errors.ParentalConsentRequired)
+		return
+	}
+	logger.Error(nil, "The user does not meet the minimum age to access the route")
+	errors.Abort(ctx, errors.MinimumAgeRequired)
+}
+
+func (ageGate *AgeGate) hasParentalConsent(token *jwt.Token) bool {
+	if token == nil {
+		return false
+	}
+	claim, err := ageGate.jwtTokenInspector.GetClaimFromToken(token, ParentalConsentClaim)
+	consent, ok := claim.(bool)
+	return err == nil && ok && consent
+}
+
+// getDateOfBirth reads the date of birth claim and falls back to a cached backend lookup
+func (ageGate *AgeGate) getDateOfBirth(ctx *gin.Context, token *jwt.Token, userID string) (*time.Time, error) {
+	if token != nil {
+		claim, err := ageGate.jwtTokenInspector.GetClaimFromToken(token, DateOfBirthClaim)
+		if err == nil {
+			if dateOfBirth := parseDateOfBirth(claim); dateOfBirth != nil {
+				return dateOfBirth, nil
+			}
+		}
+	}
+
+	now := time.Now()
+	ageGate.mtx.Lock()
+	dateOfBirth, cached := ageGate.birthDates[userID]
+	if cached && now.Sub(ageGate.birthDatesCachedAt[userID]) <= ageGate.cacheTTL {
+		ageGate.mtx.Unlock()
+		return &dateOfBirth, nil
+	}
+	ageGate.mtx.Unlock()
+
+	user, err := ageGate.service.GetUser(ctx.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	if user.GetDateOfBirth() == nil {
+		return nil, nil
+	}
+	dateOfBirth = user.GetDateOfBirth().AsTime()
+	ageGate.mtx.Lock()
+	ageGate.birthDates[userID] = dateOfBirth
+	ageGate.birthDatesCachedAt[userID] = now
+	ageGate.mtx.Unlock()
+	return &dateOfBirth, nil
+}
+
+func parseDateOfBirth(claim interface{}) *time.Time {
+	switch value := claim.(type) {
+	case float64:
+		dateOfBirth := time.Unix(int64(value), 0).UTC()
+		return &dateOfBirth
+	case string:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if dateOfBirth, err := time.Parse(layout, value); err == nil {
+				return &dateOfBirth
+			}
+		}
+	}
+	return nil
+}
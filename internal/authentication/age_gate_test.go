@@ -0,0 +1,133 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/mock"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func performGatedRequest(logger commonLogger.Loggerer, token *jwt.Token, ageGate *AgeGate) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(
+		"/gated",
+		func(ctx *gin.Context) {
+			newCtx := context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, logger)
+			ctx.Request = ctx.Request.WithContext(newCtx)
+			ctx.Set(string(commmonJWT.ClaimsContextKey), &commmonJWT.TokenClaims{UserID: "test-user-id"})
+			ctx.Set(string(commmonJWT.JWTTokenKey), token)
+		},
+		ageGate.RequireAge,
+		func(ctx *gin.Context) {
+			ctx.Status(http.StatusNoContent)
+		},
+	)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gated", nil))
+	return w
+}
+
+func TestAgeGate(t *testing.T) {
+	configurations := &config.Config{
+		Authentication: config.AuthenticationConfig{VerificationCacheTTL: time.Minute},
+		AgeGate: config.AgeGateConfig{
+			MinimumAge:        18,
+			ConsentMinimumAge: 13,
+			Routes:            []string{"GET /gated"},
+		},
+	}
+	now := time.Now()
+
+	t.Run("AgeAt_Before_Birthday", func(t *testing.T) {
+		dateOfBirth := time.Date(2000, time.June, 15, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, 23, AgeAt(dateOfBirth, time.Date(2024, time.June, 14, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(t, 24, AgeAt(dateOfBirth, time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("RequireAge_Adult_Claim_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		testToken := &jwt.Token{}
+		ageGate := NewAgeGate(nil, jwtTokenInspectorMock, configurations)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, DateOfBirthClaim).Return(now.AddDate(-30, 0, 0).Format("2006-01-02"), nil)
+
+		w := performGatedRequest(loggerMock, testToken, ageGate)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("RequireAge_Teen_Without_Consent_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		testToken := &jwt.Token{}
+		ageGate := NewAgeGate(nil, jwtTokenInspectorMock, configurations)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, DateOfBirthClaim).Return(float64(now.AddDate(-15, 0, 0).Unix()), nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, ParentalConsentClaim).Return(nil, nil)
+		loggerMock.EXPECT().Error(nil, "The user requires parental consent to access the route")
+
+		w := performGatedRequest(loggerMock, testToken, ageGate)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "parental_consent_required")
+	})
+
+	t.Run("RequireAge_Teen_With_Consent_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		testToken := &jwt.Token{}
+		ageGate := NewAgeGate(nil, jwtTokenInspectorMock, configurations)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, DateOfBirthClaim).Return(float64(now.AddDate(-15, 0, 0).Unix()), nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, ParentalConsentClaim).Return(true, nil)
+
+		w := performGatedRequest(loggerMock, testToken, ageGate)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("RequireAge_Child_Backend_Lookup_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		serviceMock := mock.NewMockServiceClienter(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		testToken := &jwt.Token{}
+		ageGate := NewAgeGate(serviceMock, jwtTokenInspectorMock, configurations)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, DateOfBirthClaim).Return(nil, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, ParentalConsentClaim).Return(nil, nil)
+		serviceMock.EXPECT().GetUser(gomock.Any()).Return(&pb_authentication.User{
+			DateOfBirth: timestamppb.New(now.AddDate(-10, 0, 0)),
+		}, nil)
+		loggerMock.EXPECT().Error(nil, "The user does not meet the minimum age to access the route")
+
+		w := performGatedRequest(loggerMock, testToken, ageGate)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "minimum_age_required")
+	})
+}
@@ -0,0 +1,218 @@
+package authentication
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+)
+
+// DefaultAPIKeyHeader is the request header carrying the API key when no header is configured
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// APIKeyIDMetadataKey is the gRPC metadata naming the API key of the request to the upstreams
+const APIKeyIDMetadataKey = "x-api-key-id"
+
+// APIKeyContextKey is the gin context key of the API key authenticating the request
+const APIKeyContextKey = "api_key"
+
+// APIKey is a key granted to a machine client, only the hex SHA-256 hash of the key is stored
+type APIKey struct {
+	ID        string    `json:"id"`
+	Client    string    `json:"client"`
+	Hash      string    `json:"hash"`
+	Roles     []string  `json:"roles"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HashAPIKey returns the hash under which the key is stored
+func HashAPIKey(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(digest[:])
+}
+
+func (apiKey *APIKey) hasRole(role string) bool {
+	for _, keyRole := range apiKey.Roles {
+		if strings.EqualFold(keyRole, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthenticatedAPIKey returns the API key authenticating the request, nil when it was not authenticated by one
+func AuthenticatedAPIKey(ctx *gin.Context) *APIKey {
+	apiKeyValue, exists := ctx.Get(APIKeyContextKey)
+	if !exists {
+		return nil
+	}
+	apiKey, _ := apiKeyValue.(*APIKey)
+	return apiKey
+}
+
+// APIKeyHook is run on every request authenticated with an API key, once the key is verified
+type APIKeyHook func(ctx *gin.Context, apiKey *APIKey)
+
+// APIKeyMiddlewarer verifies the API keys of the machine clients
+type APIKeyMiddlewarer interface {
+	OnAuthenticated(hook APIKeyHook)
+	RequireAPIKey(ctx *gin.Context)
+	RequireAuthenticationOrAPIKey(authenticationMiddleware AutheticationMiddlewarer) gin.HandlerFunc
+}
+
+// APIKeyMiddleware verifies the API keys of the machine clients against the store
+type APIKeyMiddleware struct {
+	store  APIKeyStorer
+	header string
+	routes map[string]bool
+	hooks  []APIKeyHook
+}
+
+var _ APIKeyMiddlewarer = &APIKeyMiddleware{}
+
+// NewAPIKeyMiddleware creates the API key middleware finding the keys in the store
+func NewAPIKeyMiddleware(store APIKeyStorer, configurations *config.Config) *APIKeyMiddleware {
+	apiKeyMiddleware := &APIKeyMiddleware{
+		store:  store,
+		header: configurations.APIKeys.Header,
+		routes: map[string]bool{},
+	}
+	if apiKeyMiddleware.header == "" {
+		apiKeyMiddleware.header = DefaultAPIKeyHeader
+	}
+	for _, route := range configurations.APIKeys.Routes {
+		apiKeyMiddleware.routes[route] = true
+	}
+	return apiKeyMiddleware
+}
+
+// OnAuthenticated registers a hook run on every request authenticated with an API key
+func (apiKeyMiddleware *APIKeyMiddleware) OnAuthenticated(hook APIKeyHook) {
+	apiKeyMiddleware.hooks = append(apiKeyMiddleware.hooks, hook)
+}
+
+// RequireAPIKey verifies the API key of the request and names it to the upstreams
+func (apiKeyMiddleware *APIKeyMiddleware) RequireAPIKey(ctx *gin.Context) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	requestContext := ctx.Request.Context()
+	key := ctx.GetHeader(apiKeyMiddleware.header)
+	if key == "" {
+		logger.Error(nil, fmt.Sprintf("No %s header was present in the request", apiKeyMiddleware.header))
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "missing_api_key")
+		errors.Abort(ctx, errors.Unauthenticated)
+		return
+	}
+	apiKey, err := apiKeyMiddleware.store.Find(requestContext, HashAPIKey(key))
+	if err != nil {
+		logger.Error(err, "Could not look up the API key")
+		errors.Abort(ctx, errors.ServiceUnavailable)
+		return
+	}
+	if apiKey == nil {
+		logger.Error(nil, "The API key was invalid")
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "invalid_api_key")
+		errors.Abort(ctx, errors.Unauthenticated)
+		return
+	}
+	if !apiKey.ExpiresAt.IsZero() && apiKey.ExpiresAt.Before(time.Now()) {
+		logger.Error(nil, fmt.Sprintf("The API key %s has expired", apiKey.ID))
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "expired_api_key")
+		errors.Abort(ctx, errors.Unauthenticated)
+		return
+	}
+
+	ctx.Request = ctx.Request.WithContext(metadata.AppendToOutgoingContext(requestContext, APIKeyIDMetadataKey, apiKey.ID))
+	ctx.Set(APIKeyContextKey, apiKey)
+	logger.Info(fmt.Sprintf("Successfully authenticated the client %s with an API key", apiKey.Client))
+	tracing.AddEvent(requestContext, "authentication.authenticated", "authentication.scheme", "api_key")
+	for _, hook := range apiKeyMiddleware.hooks {
+		hook(ctx, apiKey)
+		if ctx.IsAborted() {
+			return
+		}
+	}
+	ctx.Next()
+}
+
+// RequireAuthenticationOrAPIKey verifies the API key of the requests carrying one, otherwise their access token
+func (apiKeyMiddleware *APIKeyMiddleware) RequireAuthenticationOrAPIKey(authenticationMiddleware AutheticationMiddlewarer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetHeader(apiKeyMiddleware.header) != "" {
+			apiKeyMiddleware.RequireAPIKey(ctx)
+			return
+		}
+		authenticationMiddleware.RequireAuthentication(ctx)
+	}
+}
+
+// apiKeyAuthentication accepts an API key in place of the access token on the routes configured for the API keys,
+// the roles of the key then stand for the roles of the token
+type apiKeyAuthentication struct {
+	AutheticationMiddlewarer
+	apiKeyMiddleware *APIKeyMiddleware
+}
+
+// WithAPIKeys returns the authentication middleware accepting the API keys on the configured routes
+func WithAPIKeys(authenticationMiddleware AutheticationMiddlewarer, apiKeyMiddleware *APIKeyMiddleware) AutheticationMiddlewarer {
+	return &apiKeyAuthentication{AutheticationMiddlewarer: authenticationMiddleware, apiKeyMiddleware: apiKeyMiddleware}
+}
+
+// RequireAuthentication verifies the API key on the routes accepting them, otherwise the access token
+func (authentication *apiKeyAuthentication) RequireAuthentication(ctx *gin.Context) {
+	if authentication.apiKeyMiddleware.routes[ctx.FullPath()] {
+		authentication.apiKeyMiddleware.RequireAuthenticationOrAPIKey(authentication.AutheticationMiddlewarer)(ctx)
+		return
+	}
+	authentication.AutheticationMiddlewarer.RequireAuthentication(ctx)
+}
+
+// RequireRole allows the API keys holding one of the given roles, otherwise checks the access token
+func (authentication *apiKeyAuthentication) RequireRole(roles ...string) gin.HandlerFunc {
+	requireTokenRole := authentication.AutheticationMiddlewarer.RequireRole(roles...)
+	return func(ctx *gin.Context) {
+		apiKey := AuthenticatedAPIKey(ctx)
+		if apiKey == nil {
+			requireTokenRole(ctx)
+			return
+		}
+		for _, role := range roles {
+			if apiKey.hasRole(role) {
+				ctx.Next()
+				return
+			}
+		}
+		errors.Abort(ctx, errors.InsufficientRole)
+	}
+}
+
+// RequireRoles allows the API keys holding every one of the given roles, otherwise checks the access token
+func (authentication *apiKeyAuthentication) RequireRoles(roles ...string) gin.HandlerFunc {
+	requireTokenRoles := authentication.AutheticationMiddlewarer.RequireRoles(roles...)
+	return func(ctx *gin.Context) {
+		apiKey := AuthenticatedAPIKey(ctx)
+		if apiKey == nil {
+			requireTokenRoles(ctx)
+			return
+		}
+		for _, role := range roles {
+			if !apiKey.hasRole(role) {
+				errors.Abort(ctx, errors.InsufficientRole)
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,188 @@
+package authentication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// API key stores
+const (
+	APIKeyStoreStatic  = "static"
+	APIKeyStoreFile    = "file"
+	APIKeyStoreService = "service"
+)
+
+// APIKeyStorer finds the API keys by the hash of the key, nil when no key has the hash
+type APIKeyStorer interface {
+	Find(ctx context.Context, hash string) (*APIKey, error)
+}
+
+// NewAPIKeyStore creates the configured store, the HTTP client is only used by the service store
+func NewAPIKeyStore(configurations *config.Config, httpClient *http.Client) (APIKeyStorer, error) {
+	apiKeysConfig := configurations.APIKeys
+	switch apiKeysConfig.Store {
+	case APIKeyStoreStatic, "":
+		return NewStaticAPIKeyStore(apiKeysConfig.Keys), nil
+	case APIKeyStoreFile:
+		return NewFileAPIKeyStore(apiKeysConfig.File, apiKeysConfig.ReloadInterval)
+	case APIKeyStoreService:
+		if apiKeysConfig.ServiceURL == "" {
+			return nil, fmt.Errorf("The API key service store needs a service URL")
+		}
+		return NewServiceAPIKeyStore(apiKeysConfig.ServiceURL, apiKeysConfig.CacheTTL, httpClient), nil
+	}
+	return nil, fmt.Errorf("Unknown API key store: %s", apiKeysConfig.Store)
+}
+
+// StaticAPIKeyStore finds the keys listed in the configuration
+type StaticAPIKeyStore struct {
+	keys map[string]*APIKey
+}
+
+var _ APIKeyStorer = &StaticAPIKeyStore{}
+
+// NewStaticAPIKeyStore creates the store of the configured keys
+func NewStaticAPIKeyStore(keys []config.APIKeyConfig) *StaticAPIKeyStore {
+	store := &StaticAPIKeyStore{keys: map[string]*APIKey{}}
+	for _, key := range keys {
+		store.keys[key.Hash] = &APIKey{ID: key.ID, Client: key.Client, Hash: key.Hash, Roles: key.Roles}
+	}
+	return store
+}
+
+// Find returns the configured key with the hash
+func (store *StaticAPIKeyStore) Find(ctx context.Context, hash string) (*APIKey, error) {
+	return store.keys[hash], nil
+}
+
+// FileAPIKeyStore finds the keys listed in a JSON file, read again on every reload interval
+// so keys are issued and revoked without a restart
+type FileAPIKeyStore struct {
+	path           string
+	reloadInterval time.Duration
+	keys           atomic.Pointer[map[string]*APIKey]
+}
+
+var _ APIKeyStorer = &FileAPIKeyStore{}
+
+// NewFileAPIKeyStore creates the store of the keys of the file
+func NewFileAPIKeyStore(path string, reloadInterval time.Duration) (*FileAPIKeyStore, error) {
+	store := &FileAPIKeyStore{path: path, reloadInterval: reloadInterval}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Reload reads the keys of the file, the keys read before are kept when the file cannot be read
+func (store *FileAPIKeyStore) Reload() error {
+	document, err := os.ReadFile(store.path)
+	if err != nil {
+		return fmt.Errorf("Could not read the API keys file: %v", err)
+	}
+	apiKeys := []*APIKey{}
+	if err := json.Unmarshal(document, &apiKeys); err != nil {
+		return fmt.Errorf("Could not parse the API keys file: %v", err)
+	}
+	keys := make(map[string]*APIKey, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		keys[apiKey.Hash] = apiKey
+	}
+	store.keys.Store(&keys)
+	return nil
+}
+
+// Find returns the key of the file with the hash
+func (store *FileAPIKeyStore) Find(ctx context.Context, hash string) (*APIKey, error) {
+	return (*store.keys.Load())[hash], nil
+}
+
+// Job reloads the file on every reload interval
+func (store *FileAPIKeyStore) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "api_keys_reload",
+		Schedule: scheduler.Every(store.reloadInterval),
+		Run: func(ctx context.Context, now time.Time) error {
+			return store.Reload()
+		},
+	}
+}
+
+// cachedAPIKey is an answer of the service, the unknown hashes are cached as a nil key
+type cachedAPIKey struct {
+	apiKey    *APIKey
+	fetchedAt time.Time
+}
+
+// ServiceAPIKeyStore asks a backend service for the keys, caching its answers for the cache TTL
+type ServiceAPIKeyStore struct {
+	url        string
+	cacheTTL   time.Duration
+	httpClient *http.Client
+	cache      map[string]cachedAPIKey
+	mtx        sync.Mutex
+}
+
+var _ APIKeyStorer = &ServiceAPIKeyStore{}
+
+// NewServiceAPIKeyStore creates the store asking the service at the URL
+func NewServiceAPIKeyStore(url string, cacheTTL time.Duration, httpClient *http.Client) *ServiceAPIKeyStore {
+	return &ServiceAPIKeyStore{url: url, cacheTTL: cacheTTL, httpClient: httpClient, cache: map[string]cachedAPIKey{}}
+}
+
+// Find posts the hash to the service, which answers the key or 404 when no key has the hash
+func (store *ServiceAPIKeyStore) Find(ctx context.Context, hash string) (*APIKey, error) {
+	now := time.Now()
+	store.mtx.Lock()
+	cached, exists := store.cache[hash]
+	store.mtx.Unlock()
+	if exists && now.Sub(cached.fetchedAt) < store.cacheTTL {
+		return cached.apiKey, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"hash": hash})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, store.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating the API key request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := store.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Error requesting the API key: %v", err)
+	}
+	defer response.Body.Close()
+	var apiKey *APIKey
+	switch response.StatusCode {
+	case http.StatusOK:
+		apiKey = &APIKey{}
+		if err := json.NewDecoder(response.Body).Decode(apiKey); err != nil {
+			return nil, fmt.Errorf("Error decoding the API key: %v", err)
+		}
+	case http.StatusNotFound:
+	default:
+		return nil, fmt.Errorf("The API key service answered %d", response.StatusCode)
+	}
+
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	for cachedHash, cached := range store.cache {
+		if now.Sub(cached.fetchedAt) >= store.cacheTTL {
+			delete(store.cache, cachedHash)
+		}
+	}
+	store.cache[hash] = cachedAPIKey{apiKey: apiKey, fetchedAt: now}
+	return apiKey, nil
+}
@@ -0,0 +1,202 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// tokenAuthentication stands for the access token checks, recording that they ran
+type tokenAuthentication struct {
+	AutheticationMiddlewarer
+	checked []string
+}
+
+func (authentication *tokenAuthentication) RequireAuthentication(ctx *gin.Context) {
+	authentication.checked = append(authentication.checked, "token")
+	ctx.AbortWithStatus(http.StatusUnauthorized)
+}
+
+func (authentication *tokenAuthentication) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authentication.checked = append(authentication.checked, "token role")
+		ctx.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
+func (authentication *tokenAuthentication) RequireRoles(roles ...string) gin.HandlerFunc {
+	return authentication.RequireRole(roles...)
+}
+
+func newAPIKeyRouter(t *testing.T, authenticationMiddleware AutheticationMiddlewarer, handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	controller := gomock.NewController(t)
+	loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+	loggerMock.EXPECT().Info(gomock.Any()).AnyTimes()
+	loggerMock.EXPECT().Error(gomock.Any(), gomock.Any()).AnyTimes()
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+	})
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) }
+	router.GET("/search", append([]gin.HandlerFunc{authenticationMiddleware.RequireAuthentication}, append(handlers, ok)...)...)
+	router.GET("/profile", authenticationMiddleware.RequireAuthentication, ok)
+	return router
+}
+
+func serveWithAPIKey(router *gin.Engine, path, key string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		request.Header.Set(DefaultAPIKeyHeader, key)
+	}
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	configurations := &config.Config{APIKeys: config.APIKeysConfig{
+		Enabled: true,
+		Keys: []config.APIKeyConfig{
+			{ID: "reporting-key", Client: "reporting", Hash: HashAPIKey("reporting-secret"), Roles: []string{"reader"}},
+		},
+		Routes: []string{"/search"},
+	}}
+	store, err := NewAPIKeyStore(configurations, nil)
+	assert.NoError(t, err)
+	apiKeyMiddleware := NewAPIKeyMiddleware(store, configurations)
+
+	t.Run("RequireAuthentication_Should_Accept_A_Valid_API_Key_On_The_Configured_Routes", func(t *testing.T) {
+		tokenMiddleware := &tokenAuthentication{}
+		authenticatedKeyID := []string{}
+		router := newAPIKeyRouter(t, WithAPIKeys(tokenMiddleware, apiKeyMiddleware), func(ctx *gin.Context) {
+			outgoing, _ := metadata.FromOutgoingContext(ctx.Request.Context())
+			authenticatedKeyID = outgoing.Get(APIKeyIDMetadataKey)
+			assert.Equal(t, "reporting", AuthenticatedAPIKey(ctx).Client)
+		})
+
+		recorder := serveWithAPIKey(router, "/search", "reporting-secret")
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.Equal(t, []string{"reporting-key"}, authenticatedKeyID)
+		assert.Empty(t, tokenMiddleware.checked)
+	})
+
+	t.Run("RequireAuthentication_Should_Reject_An_Unknown_API_Key", func(t *testing.T) {
+		router := newAPIKeyRouter(t, WithAPIKeys(&tokenAuthentication{}, apiKeyMiddleware))
+
+		recorder := serveWithAPIKey(router, "/search", "unknown-secret")
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("RequireAuthentication_Should_Check_The_Token_Without_API_Key_Or_On_Other_Routes", func(t *testing.T) {
+		tokenMiddleware := &tokenAuthentication{}
+		router := newAPIKeyRouter(t, WithAPIKeys(tokenMiddleware, apiKeyMiddleware))
+
+		serveWithAPIKey(router, "/search", "")
+		serveWithAPIKey(router, "/profile", "reporting-secret")
+
+		assert.Equal(t, []string{"token", "token"}, tokenMiddleware.checked)
+	})
+
+	t.Run("RequireRole_Should_Check_The_Roles_Of_The_API_Key", func(t *testing.T) {
+		tokenMiddleware := &tokenAuthentication{}
+		authenticationMiddleware := WithAPIKeys(tokenMiddleware, apiKeyMiddleware)
+
+		allowed := serveWithAPIKey(newAPIKeyRouter(t, authenticationMiddleware, authenticationMiddleware.RequireRole("Reader")), "/search", "reporting-secret")
+		denied := serveWithAPIKey(newAPIKeyRouter(t, authenticationMiddleware, authenticationMiddleware.RequireRoles("reader", "admin")), "/search", "reporting-secret")
+
+		assert.Equal(t, http.StatusNoContent, allowed.Code)
+		assert.Equal(t, http.StatusForbidden, denied.Code)
+		assert.JSONEq(t, `{"error":{"code":"insufficient_role","message":"The user does not have the role the route requires"}}`, denied.Body.String())
+		assert.Empty(t, tokenMiddleware.checked)
+	})
+
+	t.Run("RequireAPIKey_Should_Reject_An_Expired_API_Key", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "api_keys.json")
+		document, _ := json.Marshal([]*APIKey{{ID: "expired-key", Hash: HashAPIKey("expired-secret"), ExpiresAt: time.Now().Add(-time.Minute)}})
+		assert.NoError(t, os.WriteFile(path, document, 0o644))
+		fileStore, err := NewFileAPIKeyStore(path, time.Minute)
+		assert.NoError(t, err)
+		router := newAPIKeyRouter(t, WithAPIKeys(&tokenAuthentication{}, NewAPIKeyMiddleware(fileStore, configurations)))
+
+		recorder := serveWithAPIKey(router, "/search", "expired-secret")
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+}
+
+func TestAPIKeyStore(t *testing.T) {
+	t.Run("NewAPIKeyStore_Should_Reject_An_Unknown_Store", func(t *testing.T) {
+		_, err := NewAPIKeyStore(&config.Config{APIKeys: config.APIKeysConfig{Store: "vault"}}, nil)
+
+		assert.EqualError(t, err, "Unknown API key store: vault")
+	})
+
+	t.Run("FileAPIKeyStore_Should_Keep_The_Keys_When_The_Reload_Fails", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "api_keys.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`[{"id":"key","hash":"hash"}]`), 0o644))
+		store, err := NewFileAPIKeyStore(path, time.Minute)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(path, []byte(`[{`), 0o644))
+
+		err = store.Job().Run(context.Background(), time.Now())
+
+		assert.ErrorContains(t, err, "Could not parse the API keys file")
+		apiKey, _ := store.Find(context.Background(), "hash")
+		assert.Equal(t, "key", apiKey.ID)
+	})
+
+	t.Run("ServiceAPIKeyStore_Should_Cache_The_Answers_Of_The_Service", func(t *testing.T) {
+		requests := 0
+		service := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			requests++
+			body := map[string]string{}
+			json.NewDecoder(request.Body).Decode(&body)
+			if body["hash"] != "known-hash" {
+				writer.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writer.Write([]byte(`{"id":"service-key","client":"partner","roles":["reader"]}`))
+		}))
+		defer service.Close()
+		store := NewServiceAPIKeyStore(service.URL, time.Minute, service.Client())
+
+		for index := 0; index < 2; index++ {
+			apiKey, err := store.Find(context.Background(), "known-hash")
+			assert.NoError(t, err)
+			assert.Equal(t, "service-key", apiKey.ID)
+			apiKey, err = store.Find(context.Background(), "unknown-hash")
+			assert.NoError(t, err)
+			assert.Nil(t, apiKey)
+		}
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("ServiceAPIKeyStore_Should_Return_The_Errors_Of_The_Service", func(t *testing.T) {
+		service := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer service.Close()
+		store := NewServiceAPIKeyStore(service.URL, time.Minute, service.Client())
+
+		_, err := store.Find(context.Background(), "hash")
+
+		assert.EqualError(t, err, "The API key service answered 500")
+	})
+}
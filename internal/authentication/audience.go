@@ -0,0 +1,101 @@
+package authentication
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+)
+
+// AudienceClaim is the claim naming the API surfaces a token was issued for, a single audience or a list of them
+const AudienceClaim = "aud"
+
+// newRouteAudiences returns the configured route audiences, the longest path prefixes first so the most
+// specific one applies
+func newRouteAudiences(audiencesConfig []config.RouteAudienceConfig) []config.RouteAudienceConfig {
+	routeAudiences := append([]config.RouteAudienceConfig{}, audiencesConfig...)
+	sort.SliceStable(routeAudiences, func(first, second int) bool {
+		return len(routeAudiences[first].PathPrefix) > len(routeAudiences[second].PathPrefix)
+	})
+	return routeAudiences
+}
+
+// TokenAudiences reads the aud claim, accepting either a list of audiences or a single audience
+func TokenAudiences(token *jwt.Token) []string {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil
+	}
+	switch value := claims[AudienceClaim].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		audiences := []string{}
+		for _, audience := range value {
+			if audienceString, ok := audience.(string); ok {
+				audiences = append(audiences, audienceString)
+			}
+		}
+		return audiences
+	}
+	return nil
+}
+
+func hasAudience(token *jwt.Token, audience string) bool {
+	for _, tokenAudience := range TokenAudiences(token) {
+		if tokenAudience == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAudience rejects the access tokens missing the audience configured for the path of the request
+func (autheticationMiddleware *AutheticationMiddleware) checkAudience(
+	ctx *gin.Context,
+	logger commonLogger.Loggerer,
+	token *jwt.Token,
+) bool {
+	for _, routeAudience := range autheticationMiddleware.routeAudiences {
+		if !ratelimit.HasPathPrefix(ctx.Request.URL.Path, routeAudience.PathPrefix) {
+			continue
+		}
+		if hasAudience(token, routeAudience.Audience) {
+			return true
+		}
+		logger.Error(nil, fmt.Sprintf("The bearer token was not issued for the %s audience", routeAudience.Audience))
+		errors.Abort(ctx, errors.InvalidAudience)
+		return false
+	}
+	return true
+}
+
+// RequireAudience returns a middleware allowing only tokens issued for one of the given audiences
+func (autheticationMiddleware *AutheticationMiddleware) RequireAudience(audiences ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+		if err != nil {
+			errors.AbortWithError(ctx, errors.InternalError, err)
+			return
+		}
+		token := authenticatedToken(ctx, logger)
+		if token == nil {
+			return
+		}
+
+		for _, audience := range audiences {
+			if hasAudience(token, audience) {
+				ctx.Next()
+				return
+			}
+		}
+		logger.Error(nil, "The bearer token was not issued for the audience of the route")
+		errors.Abort(ctx, errors.InvalidAudience)
+	}
+}
@@ -0,0 +1,99 @@
+package authentication
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func TestAudience(t *testing.T) {
+	routeAudiences := newRouteAudiences([]config.RouteAudienceConfig{
+		{PathPrefix: "/api/v1", Audience: "api"},
+		{PathPrefix: "/api/v1/payment", Audience: "payments"},
+	})
+	tokenClaims := &commmonJWT.TokenClaims{
+		UserID: "user-id",
+		Type:   commonToken.AuthTokenType,
+		Expiry: time.Now().Add(time.Minute),
+	}
+
+	t.Run("RequireAuthentication_Other_Audience_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			routeAudiences:    routeAudiences,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header", Claims: jwt.MapClaims{AudienceClaim: "api"}}
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+		ctx.Request.URL.Path = "/api/v1/payment/checkout"
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Error(nil, "The bearer token was not issued for the payments audience")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"invalid_audience","message":"The access token was not issued for the route"}}`, w.Body.String())
+	})
+
+	t.Run("RequireAuthentication_Audience_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			routeAudiences:    routeAudiences,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header", Claims: jwt.MapClaims{AudienceClaim: []interface{}{"api", "payments"}}}
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+		ctx.Request.URL.Path = "/api/v1/payment/checkout"
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RequireAudience_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		authenticationMiddleware := &AutheticationMiddleware{}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), &jwt.Token{Claims: jwt.MapClaims{AudienceClaim: "api"}})
+		loggerMock.EXPECT().Error(nil, "The bearer token was not issued for the audience of the route")
+
+		authenticationMiddleware.RequireAudience("payments", "ledger")(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"invalid_audience","message":"The access token was not issued for the route"}}`, w.Body.String())
+	})
+}
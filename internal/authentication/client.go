@@ -7,14 +7,26 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
 	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
-	commonTLS "github.com/quadev-ltd/qd-common/pkg/tls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/preferences"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
 )
 
 // ServiceClienter is an interface for the authentication service client
 type ServiceClienter interface {
 	GetPublicKey(ctx context.Context) (*string, error)
+	GetUser(ctx context.Context) (*pb_authentication.User, error)
 	Register(ctx *gin.Context)
 	VerifyEmail(ctx *gin.Context)
 	ResendEmailVerification(ctx *gin.Context)
@@ -34,17 +46,40 @@ type ServiceClient struct {
 
 var _ ServiceClienter = &ServiceClient{}
 
+var _ preferences.Sourcer = &ServiceClient{}
+
 // InitServiceClient initializes the authentication service client
-func InitServiceClient(config *commonConfig.Config) (pb_authentication.AuthenticationServiceClient, error) {
-	grpcServiceAddress := fmt.Sprintf("%s:%s", config.AuthenticationService.Host, config.AuthenticationService.Port)
+func InitServiceClient(centralConfig *commonConfig.Config, configurations *config.Config) (pb_authentication.AuthenticationServiceClient, error) {
+	grpcServiceAddress := fmt.Sprintf("%s:%s", centralConfig.AuthenticationService.Host, centralConfig.AuthenticationService.Port)
 
-	fmt.Println("Connecting to authentication service at", grpcServiceAddress, config.TLSEnabled)
-	clientConnection, err := commonTLS.CreateGRPCConnection(grpcServiceAddress, config.TLSEnabled)
+	fmt.Println("Connecting to authentication service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection("authentication", grpcServiceAddress, centralConfig.TLSEnabled, configurations)
 	if err != nil {
 		return nil, fmt.Errorf("Could not connect to grpc authentication service: %v", err)
 	}
 
-	return pb_authentication.NewAuthenticationServiceClient(clientConnection), nil
+	regionalConnection, err := region.RouteConnection("authentication", clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConnection, err := tenancy.RouteConnection("authentication", regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	routedConnection, err := versioning.RouteConnection("authentication", tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tracedConnection := tracing.TraceConnection("authentication", routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection("authentication", tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection("authentication", instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection("authentication", retryingConnection, configurations)
+	hookedConnection := hooks.HookConnection("authentication", breakerConnection, configurations)
+
+	return pb_authentication.NewAuthenticationServiceClient(hookedConnection), nil
 }
 
 // GetPublicKey gets the public key from server
@@ -59,6 +94,40 @@ func (service *ServiceClient) GetPublicKey(ctx context.Context) (*string, error)
 	return &response.PublicKey, nil
 }
 
+// GetUser gets the authenticated user from server
+func (service *ServiceClient) GetUser(ctx context.Context) (*pb_authentication.User, error) {
+	response, err := service.client.GetUserProfile(
+		ctx,
+		&pb_authentication.GetUserProfileRequest{},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return response.GetUser(), nil
+}
+
+// GetPreferences gets the stored preferences of the authenticated user, the service answering the profile with
+// the locale and the timezone of the user in the metadata keys the gateway forwards them upstream with
+func (service *ServiceClient) GetPreferences(ctx context.Context, userID string) (*preferences.Preferences, error) {
+	var header metadata.MD
+	_, err := service.client.GetUserProfile(
+		ctx,
+		&pb_authentication.GetUserProfileRequest{},
+		grpc.Header(&header),
+	)
+	if err != nil {
+		return nil, err
+	}
+	stored := &preferences.Preferences{}
+	if locales := header.Get(preferences.LocaleMetadataKey); len(locales) > 0 {
+		stored.Locale = locales[0]
+	}
+	if timezones := header.Get(preferences.TimezoneMetadataKey); len(timezones) > 0 {
+		stored.Timezone = timezones[0]
+	}
+	return stored, nil
+}
+
 // Register redirects request to the register route
 func (service *ServiceClient) Register(ctx *gin.Context) {
 	routes.Register(ctx, service.client)
@@ -0,0 +1,54 @@
+package authentication
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/preferences"
+)
+
+type profileClient struct {
+	pb_authentication.AuthenticationServiceClient
+	header metadata.MD
+}
+
+func (client *profileClient) GetUserProfile(
+	ctx context.Context,
+	in *pb_authentication.GetUserProfileRequest,
+	opts ...grpc.CallOption,
+) (*pb_authentication.GetUserProfileResponse, error) {
+	for _, opt := range opts {
+		if headerOption, ok := opt.(grpc.HeaderCallOption); ok {
+			*headerOption.HeaderAddr = client.header
+		}
+	}
+	return &pb_authentication.GetUserProfileResponse{User: &pb_authentication.User{UserID: "test-user-id"}}, nil
+}
+
+func TestServiceClientPreferences(t *testing.T) {
+	t.Run("GetPreferences_Reads_The_Profile_Metadata_Success", func(t *testing.T) {
+		service := &ServiceClient{client: &profileClient{header: metadata.Pairs(
+			preferences.LocaleMetadataKey, "es-ES",
+			preferences.TimezoneMetadataKey, "Europe/Madrid",
+		)}}
+
+		stored, err := service.GetPreferences(context.Background(), "test-user-id")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &preferences.Preferences{Locale: "es-ES", Timezone: "Europe/Madrid"}, stored)
+	})
+
+	t.Run("GetPreferences_Without_Stored_Preferences_Success", func(t *testing.T) {
+		service := &ServiceClient{client: &profileClient{}}
+
+		stored, err := service.GetPreferences(context.Background(), "test-user-id")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &preferences.Preferences{}, stored)
+	})
+}
@@ -0,0 +1,99 @@
+package authentication
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// DebugTokenRequestBody is the request body of the DebugToken route, the bearer token is decoded when it is empty
+type DebugTokenRequestBody struct {
+	Token string `json:"token"`
+}
+
+// DebugTokenResponse describes a token as the authentication middleware sees it, the claims of a token failing
+// the verification are still decoded so the reason can be told apart
+type DebugTokenResponse struct {
+	Valid            bool                   `json:"valid"`
+	Error            string                 `json:"error,omitempty"`
+	Type             string                 `json:"type,omitempty"`
+	UserID           string                 `json:"user_id,omitempty"`
+	Email            string                 `json:"email,omitempty"`
+	ExpiresAt        string                 `json:"expires_at,omitempty"`
+	ExpiresInSeconds int64                  `json:"expires_in_seconds"`
+	Expired          bool                   `json:"expired"`
+	Revoked          *bool                  `json:"revoked,omitempty"`
+	Header           map[string]interface{} `json:"header,omitempty"`
+	Claims           jwt.MapClaims          `json:"claims,omitempty"`
+}
+
+// DebugToken verifies a token with the verifier of the middleware and pretty prints its claims, type and expiry,
+// only registered outside production
+func (autheticationMiddleware *AutheticationMiddleware) DebugToken(ctx *gin.Context) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	body := DebugTokenRequestBody{}
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			errors.Abort(ctx, errors.InvalidRequestBody)
+			return
+		}
+	}
+	tokenString := body.Token
+	if tokenString == "" {
+		tokenString = strings.TrimPrefix(ctx.Request.Header.Get("Authorization"), "Bearer ")
+	}
+	if tokenString == "" {
+		errors.Abort(ctx, errors.InvalidRequestBody)
+		return
+	}
+
+	response := DebugTokenResponse{Valid: true}
+	token, err := autheticationMiddleware.verifier().Verify(tokenString)
+	if err != nil {
+		logger.Info("The token to debug failed the verification")
+		response.Valid, response.Error = false, err.Error()
+		token, _, err = new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+		if err != nil {
+			ctx.IndentedJSON(http.StatusOK, response)
+			return
+		}
+	}
+	response.Header = token.Header
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		response.Claims = claims
+	}
+	claims, err := autheticationMiddleware.jwtTokenInspector.GetClaimsFromToken(token)
+	if err != nil {
+		if response.Valid {
+			response.Valid, response.Error = false, err.Error()
+		}
+		ctx.IndentedJSON(http.StatusOK, response)
+		return
+	}
+	response.Type = string(claims.Type)
+	response.UserID = claims.UserID
+	response.Email = claims.Email
+	response.ExpiresAt = claims.Expiry.UTC().Format(time.RFC3339)
+	response.ExpiresInSeconds = int64(time.Until(claims.Expiry).Seconds())
+	response.Expired = !claims.Expiry.After(time.Now())
+	if response.Valid && autheticationMiddleware.tokenRevocations != nil {
+		revoked, err := autheticationMiddleware.tokenRevocations.IsRevoked(ctx.Request.Context(), autheticationMiddleware.tokenID(token))
+		if err != nil {
+			logger.Error(err, "Could not check token revocation")
+		} else {
+			response.Revoked = &revoked
+			response.Valid = !revoked
+		}
+	}
+	ctx.IndentedJSON(http.StatusOK, response)
+}
@@ -0,0 +1,125 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+)
+
+func TestDebugToken(t *testing.T) {
+	t.Run("DebugToken_Valid_Token_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		tokenRevocations := session.NewMemoryTokenRevocations()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			tokenRevocations:  tokenRevocations,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		testToken := &jwt.Token{
+			Raw:    "test-token",
+			Header: map[string]interface{}{"alg": "RS256"},
+			Claims: jwt.MapClaims{"userID": "user-id"},
+		}
+		tokenClaims := &commmonJWT.TokenClaims{
+			UserID: "user-id",
+			Email:  "test@test.com",
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(time.Minute),
+		}
+		ctx, w := createTestContext(http.MethodPost, "/debug/token", []byte(`{"token":"test-token"}`), nil)
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+
+		jwtVerifierMock.EXPECT().Verify("test-token").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, TokenIDClaim).Return("token-id", nil)
+
+		authenticationMiddleware.DebugToken(ctx)
+
+		response := DebugTokenResponse{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, response.Valid)
+		assert.Equal(t, string(commonToken.AuthTokenType), response.Type)
+		assert.Equal(t, "user-id", response.UserID)
+		assert.Equal(t, "test@test.com", response.Email)
+		assert.False(t, response.Expired)
+		assert.InDelta(t, 60, response.ExpiresInSeconds, 2)
+		assert.Equal(t, false, *response.Revoked)
+		assert.Equal(t, "RS256", response.Header["alg"])
+		assert.Equal(t, "user-id", response.Claims["userID"])
+	})
+
+	t.Run("DebugToken_Unverified_Bearer_Token_Claims_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		expiry := time.Now().Add(-time.Minute)
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"userID": "user-id",
+			"exp":    expiry.Unix(),
+		}).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		authHeader := "Bearer " + tokenString
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify(tokenString).Return(nil, errors.New("Token is expired"))
+		loggerMock.EXPECT().Info("The token to debug failed the verification")
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(gomock.Any()).Return(&commmonJWT.TokenClaims{
+			UserID: "user-id",
+			Type:   commonToken.AuthTokenType,
+			Expiry: expiry,
+		}, nil)
+
+		authenticationMiddleware.DebugToken(ctx)
+
+		response := DebugTokenResponse{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, response.Valid)
+		assert.Equal(t, "Token is expired", response.Error)
+		assert.True(t, response.Expired)
+		assert.Nil(t, response.Revoked)
+		assert.Equal(t, "HS256", response.Header["alg"])
+		assert.Equal(t, "user-id", response.Claims["userID"])
+	})
+
+	t.Run("DebugToken_Missing_Token_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		authenticationMiddleware := &AutheticationMiddleware{}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+
+		authenticationMiddleware.DebugToken(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"invalid_request_body","message":"The request body is invalid"}}`, w.Body.String())
+	})
+}
@@ -0,0 +1,37 @@
+package authentication
+
+import (
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/oidc"
+)
+
+// TokenFederator accepts the tokens of the external identity providers next to the tokens of the authentication service
+type TokenFederator interface {
+	UseFederation(federation *oidc.Federation)
+}
+
+var _ TokenFederator = &AutheticationMiddleware{}
+
+// UseFederation verifies the tokens issued by the providers of the federation with their keys, it is set up
+// before the gateway serves
+func (autheticationMiddleware *AutheticationMiddleware) UseFederation(federation *oidc.Federation) {
+	autheticationMiddleware.federation = federation
+}
+
+// federatedTokenVerifier verifies the tokens of the issuers of the federation with the keys of their provider and
+// the other tokens with the key of the authentication service
+type federatedTokenVerifier struct {
+	verifier   commonJWT.TokenVerifierer
+	federation *oidc.Federation
+}
+
+var _ commonJWT.TokenVerifierer = &federatedTokenVerifier{}
+
+func (verifier *federatedTokenVerifier) Verify(tokenString string) (*jwt.Token, error) {
+	if verifier.federation.Trusts(oidc.Issuer(tokenString)) {
+		return verifier.federation.Verify(tokenString)
+	}
+	return verifier.verifier.Verify(tokenString)
+}
@@ -0,0 +1,96 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/oidc"
+)
+
+func TestFederation(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwks := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		json.NewEncoder(writer).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "idp-key",
+			"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+	federation, err := oidc.NewFederation(&config.Config{OIDC: config.OIDCConfig{Providers: []config.OIDCProviderConfig{{
+		Name: "auth0", Issuer: "https://example.eu.auth0.com/", Audiences: []string{"app"}, JWKSURL: jwks.URL,
+	}}}}, jwks.Client())
+	assert.NoError(t, err)
+	signFederatedToken := func(claims jwt.MapClaims) string {
+		claims["iss"] = "https://example.eu.auth0.com/"
+		claims["aud"] = "app"
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		federatedToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		federatedToken.Header["kid"] = "idp-key"
+		tokenString, err := federatedToken.SignedString(privateKey)
+		assert.NoError(t, err)
+		return tokenString
+	}
+
+	t.Run("Verifier_Federated_Token_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtVerifier: jwtVerifierMock}
+		authenticationMiddleware.UseFederation(federation)
+
+		tokenString := signFederatedToken(jwt.MapClaims{"sub": "auth0|42"})
+		jwtVerifierMock.EXPECT().Verify("service-token").Return(&jwt.Token{Raw: "service-token"}, nil)
+
+		token, err := authenticationMiddleware.verifier().Verify(tokenString)
+		serviceToken, serviceErr := authenticationMiddleware.verifier().Verify("service-token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "auth0:auth0|42", token.Claims.(jwt.MapClaims)["user_id"])
+		assert.NoError(t, serviceErr)
+		assert.Equal(t, "service-token", serviceToken.Raw)
+	})
+
+	t.Run("RequireRole_Federated_Token_Roles_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: &commonJWT.TokenInspector{}}
+		authenticationMiddleware.UseFederation(federation)
+
+		token, err := authenticationMiddleware.verifier().Verify(signFederatedToken(jwt.MapClaims{
+			"sub":         "auth0|42",
+			RolesClaim:    []string{"admin"},
+			RoleClaim:     "admin",
+			"tenant_id":   "acme",
+			"permissions": []string{"admin"},
+		}))
+		assert.NoError(t, err)
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commonJWT.JWTTokenKey), token)
+		loggerMock.EXPECT().Error(nil, "The user does not hold the role required by the route")
+
+		authenticationMiddleware.RequireRole("admin")(ctx)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NotContains(t, token.Claims.(jwt.MapClaims), "tenant_id")
+		assert.NotContains(t, token.Claims.(jwt.MapClaims), PermissionsClaim)
+	})
+}
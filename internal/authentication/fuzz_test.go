@@ -0,0 +1,123 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+)
+
+// The fuzz targets run their seed corpus with go test, and explore further with
+// go test -fuzz=FuzzRequireAuthentication ./internal/authentication
+
+func FuzzParseAccessToken(f *testing.F) {
+	for _, seed := range []string{"", "Bearer token", "bearer token", "Bearer ", "Basic dXNlcjpwYXNz", "Bearer a Bearer b", "BearerBearer token"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, authorization string) {
+		controller := gomock.NewController(t)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		loggerMock.EXPECT().Error(gomock.Any(), gomock.Any()).AnyTimes()
+		ctx, _ := createTestContextWithLogger(loggerMock, &authorization)
+
+		token := ParseAccessToken(ctx)
+
+		if (token == nil) != ctx.IsAborted() {
+			t.Fatalf("The header %q was neither parsed nor rejected", authorization)
+		}
+		if token != nil && !strings.Contains(ctx.Request.Header.Get("Authorization"), "Bearer "+*token) {
+			t.Fatalf("The token %q is not the bearer of the header %q", *token, authorization)
+		}
+	})
+}
+
+func FuzzRequireAuthentication(f *testing.F) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		f.Fatal(err)
+	}
+	otherPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		f.Fatal(err)
+	}
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		f.Fatal(err)
+	}
+	publicKey := string(pem.EncodeToMemory(&pem.Block{Type: commonJWT.PublicKeyType, Bytes: publicKeyBytes}))
+	sign := func(signingKey *rsa.PrivateKey, tokenType commonToken.Type, expiry time.Time) string {
+		token, err := commonJWT.NewTokenSigner(signingKey).SignToken(
+			commonJWT.ClaimPair{Key: commonJWT.UserIDClaim, Value: "user-id"},
+			commonJWT.ClaimPair{Key: commonJWT.EmailClaim, Value: "user@example.com"},
+			commonJWT.ClaimPair{Key: commonJWT.TypeClaim, Value: tokenType},
+			commonJWT.ClaimPair{Key: commonJWT.ExpiryClaim, Value: expiry},
+		)
+		if err != nil {
+			f.Fatal(err)
+		}
+		return *token
+	}
+	validToken := sign(privateKey, commonToken.AuthTokenType, time.Now().Add(time.Hour))
+	f.Add(validToken)
+	f.Add(sign(privateKey, commonToken.RefreshTokenType, time.Now().Add(time.Hour)))
+	f.Add(sign(privateKey, commonToken.AuthTokenType, time.Now().Add(-time.Hour)))
+	f.Add(sign(otherPrivateKey, commonToken.AuthTokenType, time.Now().Add(time.Hour)))
+	f.Add(validToken[:len(validToken)-1])
+	f.Add(strings.Replace(validToken, ".", "..", 1))
+	f.Add("")
+	f.Add("not.a.token")
+
+	autheticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: &commonJWT.TokenInspector{}}
+	if _, err := autheticationMiddleware.setPublicKey(publicKey, time.Now()); err != nil {
+		f.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+
+	f.Fuzz(func(t *testing.T, token string) {
+		controller := gomock.NewController(t)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		loggerMock.EXPECT().Error(gomock.Any(), gomock.Any()).AnyTimes()
+		loggerMock.EXPECT().Info(gomock.Any()).AnyTimes()
+		authenticated := false
+		router := gin.New()
+		router.Use(func(ctx *gin.Context) {
+			ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+		})
+		router.GET("/test", autheticationMiddleware.RequireAuthentication, func(ctx *gin.Context) {
+			authenticated = true
+			ctx.Status(http.StatusOK)
+		})
+		request := httptest.NewRequest(http.MethodGet, "/test", nil)
+		request.Header["Authorization"] = []string{"Bearer " + token}
+
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		if !authenticated {
+			return
+		}
+		parsedToken, err := autheticationMiddleware.verifier().Verify(token)
+		if err != nil {
+			t.Fatalf("The token %q was accepted without a valid signature: %v", token, err)
+		}
+		claims, err := (&commonJWT.TokenInspector{}).GetClaimsFromToken(parsedToken)
+		if err != nil {
+			t.Fatalf("The token %q was accepted without claims: %v", token, err)
+		}
+		if commonToken.Type(claims.Type) != commonToken.AuthTokenType || !claims.Expiry.After(time.Now()) {
+			t.Fatalf("The token %q was accepted as a %s expiring at %s", token, claims.Type, claims.Expiry)
+		}
+	})
+}
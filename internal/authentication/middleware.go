@@ -4,51 +4,151 @@ import (
 	"context"
 	"fmt"
 	"math"
-	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
 	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
 	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
 	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
 
 	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/oidc"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
 )
 
+// TokenExpiresInHeader is the response header hinting the seconds left before the access token expires
+const TokenExpiresInHeader = "X-Token-Expires-In"
+
+// accessTokenVerifiedKey marks the requests whose access token was already verified, so the authenticated hooks
+// run once when a policy and the route both require authentication
+const accessTokenVerifiedKey = "access_token_verified"
+
+// AuthenticatedHook runs after an access token is verified and before the rest of the handlers
+type AuthenticatedHook func(ctx *gin.Context, claims *commonJWT.TokenClaims)
+
 // AutheticationMiddlewarer interface is used to verify JWT tokens
 type AutheticationMiddlewarer interface {
+	OnAuthenticated(hook AuthenticatedHook)
 	RequireAuthentication(ctx *gin.Context)
 	RefreshAuthentication(ctx *gin.Context)
+	TrackSession(ctx *gin.Context)
+	RequireVerifiedEmail(ctx *gin.Context)
+	RequireStepUp(maxAge time.Duration) gin.HandlerFunc
+	RequireRole(roles ...string) gin.HandlerFunc
+	RequireRoles(roles ...string) gin.HandlerFunc
+	RequireAudience(audiences ...string) gin.HandlerFunc
+	PublicKeyRefreshJob() scheduler.Job
+	RevokeToken(ctx *gin.Context)
+	TokenRevocationJob() scheduler.Job
+	DebugToken(ctx *gin.Context)
+	MintToken(ctx *gin.Context)
 }
 
 // AutheticationMiddleware is used to verify JWT tokens
 type AutheticationMiddleware struct {
-	service           ServiceClienter
-	jwtVerifier       commonJWT.TokenVerifierer
-	jwtTokenInspector commonJWT.TokenInspectorer
+	service                ServiceClienter
+	jwtVerifier            commonJWT.TokenVerifierer
+	jwtTokenInspector      commonJWT.TokenInspectorer
+	expiryHintWindow       time.Duration
+	expiryPreemptWindow    time.Duration
+	activityStore          session.ActivityStorer
+	idleTimeout            time.Duration
+	activityRetention      time.Duration
+	activityFailOpen       bool
+	sessionRegistry        session.Registrier
+	maxSessions            int
+	sessionLimitPolicy     string
+	rotateRefreshTokens    bool
+	tokenRevocations       *session.TokenRevocations
+	revocationFailOpen     bool
+	tokenMinter            *TokenMinter
+	federation             *oidc.Federation
+	routeAudiences         []config.RouteAudienceConfig
+	tenantClaim            string
+	tenantRequired         bool
+	verifiedEmails         *verificationCache
+	authenticatedHooks     []AuthenticatedHook
+	refreshInterval        time.Duration
+	environment            string
+	publicKey              string
+	publicKeyFetchedAt     time.Time
+	publicKeyTTL           time.Duration
+	keyMismatchCooldown    time.Duration
+	keyMismatchRefreshedAt time.Time
+	refreshingPublicKey    atomic.Bool
+	verifierMtx            sync.RWMutex
 }
 
 var _ AutheticationMiddlewarer = &AutheticationMiddleware{}
 
 // InitAuthenticationMiddleware initializes the authentication middleware
 func InitAuthenticationMiddleware(authenticationService ServiceClienter, configurations *config.Config) (AutheticationMiddlewarer, error) {
+	authentication := configurations.Authentication
+	if authentication.IdleTimeout > 0 && authentication.ActivityRetention > 0 &&
+		authentication.ActivityRetention < authentication.IdleTimeout {
+		return nil, fmt.Errorf(
+			"The activity retention %s is shorter than the idle timeout %s",
+			authentication.ActivityRetention,
+			authentication.IdleTimeout,
+		)
+	}
 	correlationID := uuid.New().String()
 	publicKey, err := RequestPublicKey(authenticationService, correlationID, configurations.Environment, backoffDelay)
 	if err != nil {
 		return nil, err
 	}
-	jwtVerifier, err := commonJWT.NewTokenVerifier(*publicKey)
-	if err != nil {
+	jwtTokenInspector := &commonJWT.TokenInspector{}
+	autheticationMiddleware := &AutheticationMiddleware{
+		service:             authenticationService,
+		jwtTokenInspector:   jwtTokenInspector,
+		expiryHintWindow:    configurations.Authentication.ExpiryHintWindow,
+		expiryPreemptWindow: configurations.Authentication.ExpiryPreemptWindow,
+		activityStore:       session.NewActivityStore(configurations),
+		idleTimeout:         configurations.Authentication.IdleTimeout,
+		activityRetention:   configurations.Authentication.ActivityRetention,
+		activityFailOpen:    configurations.Authentication.ActivityFailOpen,
+		sessionRegistry:     session.NewRegistry(configurations),
+		maxSessions:         configurations.Authentication.MaxConcurrentSessions,
+		sessionLimitPolicy:  configurations.Authentication.SessionLimitPolicy,
+		rotateRefreshTokens: configurations.Authentication.RefreshTokenRotation,
+		verifiedEmails:      newVerificationCache(configurations.Authentication.VerificationCacheTTL),
+		refreshInterval:     configurations.Authentication.PublicKeyRefreshInterval,
+		environment:         configurations.Environment,
+		publicKeyTTL:        configurations.Authentication.PublicKeyCacheTTL,
+		keyMismatchCooldown: configurations.Authentication.PublicKeyMismatchCooldown,
+		routeAudiences:      newRouteAudiences(configurations.Authentication.Audiences),
+	}
+	if configurations.Tenancy.Enabled {
+		autheticationMiddleware.tenantClaim = tenancy.Claim(configurations)
+		autheticationMiddleware.tenantRequired = configurations.Tenancy.Required
+	}
+	if configurations.TokenRevocation.Enabled {
+		autheticationMiddleware.tokenRevocations, err = session.NewTokenRevocations(configurations)
+		if err != nil {
+			return nil, err
+		}
+		autheticationMiddleware.revocationFailOpen = configurations.TokenRevocation.FailOpen
+	}
+	if configurations.TokenMinting.Enabled {
+		autheticationMiddleware.tokenMinter, err = NewTokenMinter(configurations)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := autheticationMiddleware.setPublicKey(*publicKey, time.Now()); err != nil {
 		return nil, err
 	}
-	jwtTokenInspector := &commonJWT.TokenInspector{}
-	return &AutheticationMiddleware{
-		authenticationService,
-		jwtVerifier,
-		jwtTokenInspector,
-	}, nil
+	return autheticationMiddleware, nil
 }
 
 // BackoffStrategy is backoff strategy type
@@ -92,6 +192,60 @@ func RequestPublicKey(
 	return nil, fmt.Errorf("Could not obtain public key after %d attempts: %v", maxAttempts, err)
 }
 
+// FetchPublicKey connects to the authentication service and fetches its public key once without retrying,
+// checking that it verifies tokens, used by the startup check
+func FetchPublicKey(ctx context.Context, centralConfig *commonConfig.Config, configurations *config.Config) (string, error) {
+	client, err := InitServiceClient(centralConfig, configurations)
+	if err != nil {
+		return "", err
+	}
+	service := &ServiceClient{client: client}
+	publicKey, err := service.GetPublicKey(commonLogger.AddCorrelationIDToOutgoingContext(ctx, uuid.New().String()))
+	if err != nil {
+		return "", fmt.Errorf("Could not obtain public key: %v", err)
+	}
+	if _, err := commonJWT.NewTokenVerifier(*publicKey); err != nil {
+		return "", fmt.Errorf("Invalid public key: %v", err)
+	}
+	return *publicKey, nil
+}
+
+// RefreshPublicKey fetches the public key again so the tokens signed after a key rotation are accepted
+func (autheticationMiddleware *AutheticationMiddleware) RefreshPublicKey(ctx context.Context) error {
+	publicKey, err := autheticationMiddleware.fetchPublicKey(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = autheticationMiddleware.setPublicKey(*publicKey, time.Now())
+	return err
+}
+
+func (autheticationMiddleware *AutheticationMiddleware) fetchPublicKey(ctx context.Context) (*string, error) {
+	publicKey, err := autheticationMiddleware.service.GetPublicKey(
+		commonLogger.AddCorrelationIDToOutgoingContext(ctx, uuid.New().String()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Could not obtain public key: %v", err)
+	}
+	return publicKey, nil
+}
+
+// PublicKeyRefreshJob refreshes the public key on every refresh interval
+func (autheticationMiddleware *AutheticationMiddleware) PublicKeyRefreshJob() scheduler.Job {
+	return scheduler.Job{
+		Name:     "authentication_public_key_refresh",
+		Schedule: scheduler.Every(autheticationMiddleware.refreshInterval),
+		Run: func(ctx context.Context, now time.Time) error {
+			return autheticationMiddleware.RefreshPublicKey(ctx)
+		},
+	}
+}
+
+// OnAuthenticated registers a hook run on every request authenticated with an access token
+func (autheticationMiddleware *AutheticationMiddleware) OnAuthenticated(hook AuthenticatedHook) {
+	autheticationMiddleware.authenticatedHooks = append(autheticationMiddleware.authenticatedHooks, hook)
+}
+
 // RequireAuthentication verifies the access token
 func (autheticationMiddleware *AutheticationMiddleware) RequireAuthentication(ctx *gin.Context) {
 	autheticationMiddleware.verifyToken(ctx, commonToken.AuthTokenType)
@@ -106,15 +260,16 @@ func (autheticationMiddleware *AutheticationMiddleware) RefreshAuthentication(ct
 func ParseAccessToken(ctx *gin.Context) *string {
 	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
 	if err != nil {
-		ctx.AbortWithError(http.StatusInternalServerError, err)
+		errors.AbortWithError(ctx, errors.InternalError, err)
 		return nil
 	}
 	authorization := ctx.Request.Header.Get("Authorization")
 
 	if authorization == "" {
 		logger.Error(nil, "No authorization header was present in the request")
-		ctx.AbortWithError(
-			http.StatusForbidden,
+		errors.AbortWithError(
+			ctx,
+			errors.PermissionDenied,
 			fmt.Errorf("No authorization header was present in the request"),
 		)
 		return nil
@@ -124,8 +279,9 @@ func ParseAccessToken(ctx *gin.Context) *string {
 
 	if len(token) < 2 {
 		logger.Error(nil, "No bearer token was present in the authorization header")
-		ctx.AbortWithError(
-			http.StatusUnauthorized,
+		errors.AbortWithError(
+			ctx,
+			errors.Unauthenticated,
 			fmt.Errorf("No bearer token was present in the authorization header"),
 		)
 		return nil
@@ -136,34 +292,79 @@ func ParseAccessToken(ctx *gin.Context) *string {
 func (autheticationMiddleware *AutheticationMiddleware) verifyToken(ctx *gin.Context, expectedTokenType commonToken.Type) {
 	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
 	if err != nil {
-		ctx.AbortWithError(http.StatusInternalServerError, err)
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	if expectedTokenType == commonToken.AuthTokenType && ctx.GetBool(accessTokenVerifiedKey) {
+		ctx.Next()
 		return
 	}
+	requestContext := ctx.Request.Context()
 	parsedAuthorizationToken := ParseAccessToken(ctx)
 	if parsedAuthorizationToken == nil {
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "missing_token")
 		return
 	}
-	parsedToken, err := autheticationMiddleware.jwtVerifier.Verify(*parsedAuthorizationToken)
+	tracing.AddEvent(requestContext, "authentication.token_parsed", "token.expected_type", string(expectedTokenType))
+	parsedToken, err := autheticationMiddleware.verifier().Verify(*parsedAuthorizationToken)
+	if err != nil && autheticationMiddleware.refreshAfterKeyMismatch(ctx.Request.Context(), err) {
+		parsedToken, err = autheticationMiddleware.verifier().Verify(*parsedAuthorizationToken)
+	}
 	if err != nil {
 		logger.Error(err, "The bearer token was invalid")
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "invalid_token")
+		errors.Abort(ctx, errors.Unauthenticated)
 		return
 	}
 	claims, err := autheticationMiddleware.jwtTokenInspector.GetClaimsFromToken(parsedToken)
 	if err != nil {
 		logger.Error(err, "Could not obtain claims from bearer token")
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "invalid_claims")
+		errors.Abort(ctx, errors.Unauthenticated)
 		return
 	}
 	if commonToken.Type(claims.Type) != expectedTokenType {
 		logger.Error(nil, fmt.Sprintf("The bearer token was not an %s but a %s", expectedTokenType, claims.Type))
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "wrong_token_type")
+		errors.Abort(ctx, errors.Unauthenticated)
 		return
 	}
 
 	if claims.Expiry.Before(time.Now()) {
 		logger.Error(nil, "The bearer token has expired")
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "expired_token")
+		errors.Abort(ctx, errors.Unauthenticated)
+		return
+	}
+
+	expiresIn := time.Until(claims.Expiry)
+	if expectedTokenType == commonToken.AuthTokenType && expiresIn < autheticationMiddleware.expiryPreemptWindow {
+		logger.Error(nil, "The bearer token is about to expire")
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "expiring_token")
+		ctx.Header(TokenExpiresInHeader, strconv.Itoa(int(expiresIn.Seconds())))
+		errors.Abort(ctx, errors.TokenExpiring)
+		return
+	}
+	if expectedTokenType == commonToken.AuthTokenType && expiresIn < autheticationMiddleware.expiryHintWindow {
+		ctx.Header(TokenExpiresInHeader, strconv.Itoa(int(expiresIn.Seconds())))
+	}
+
+	tracing.AddEvent(requestContext, "authentication.token_verified")
+
+	if expectedTokenType == commonToken.AuthTokenType && !autheticationMiddleware.checkTokenRevocation(ctx, logger, parsedToken) {
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "revoked_token")
+		return
+	}
+	if expectedTokenType == commonToken.AuthTokenType && !autheticationMiddleware.checkAudience(ctx, logger, parsedToken) {
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "invalid_audience")
+		return
+	}
+	if !autheticationMiddleware.checkSessionActivity(ctx, logger, expectedTokenType, parsedToken.Raw) {
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "idle_session")
+		return
+	}
+	if expectedTokenType == commonToken.RefreshTokenType && !autheticationMiddleware.checkSessionRevocation(ctx, logger, *parsedAuthorizationToken) {
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "revoked_session")
 		return
 	}
 
@@ -171,7 +372,81 @@ func (autheticationMiddleware *AutheticationMiddleware) verifyToken(ctx *gin.Con
 	ctx.Request = ctx.Request.WithContext(newContext)
 	ctx.Set(string(commonJWT.ClaimsContextKey), claims)
 	ctx.Set(string(commonJWT.JWTTokenKey), parsedToken)
+	if !autheticationMiddleware.setTenant(ctx, logger, parsedToken) {
+		tracing.AddEvent(requestContext, "authentication.rejected", "reason", "missing_tenant")
+		return
+	}
 
 	logger.Info("Successfully authenticated user")
+	tracing.AddEvent(requestContext, "authentication.authenticated")
+	if expectedTokenType == commonToken.AuthTokenType {
+		ctx.Set(accessTokenVerifiedKey, true)
+		for _, hook := range autheticationMiddleware.authenticatedHooks {
+			hook(ctx, claims)
+			if ctx.IsAborted() {
+				return
+			}
+		}
+	}
 	ctx.Next()
 }
+
+// checkSessionActivity rejects sessions idle beyond the configured timeout, the session of an access token being the
+// one it was issued for so its activity carries over the refreshes. The access tokens record the activity of their
+// session while a refresh only checks it, and the check fails closed unless configured to fail open
+func (autheticationMiddleware *AutheticationMiddleware) checkSessionActivity(
+	ctx *gin.Context,
+	logger commonLogger.Loggerer,
+	tokenType commonToken.Type,
+	token string,
+) bool {
+	if !autheticationMiddleware.idleTrackingEnabled() {
+		return true
+	}
+	requestContext := ctx.Request.Context()
+	now := time.Now()
+	sessionID := session.IDFromToken(token)
+	var err error
+	if tokenType == commonToken.AuthTokenType {
+		sessionID, err = autheticationMiddleware.sessionOf(requestContext, token)
+	}
+	var lastSeen *time.Time
+	if err == nil {
+		lastSeen, err = autheticationMiddleware.activityStore.LastSeen(requestContext, sessionID)
+	}
+	if err != nil {
+		logger.Error(err, "Could not check session activity")
+		if autheticationMiddleware.activityFailOpen {
+			return true
+		}
+		errors.Abort(ctx, errors.ServiceUnavailable)
+		return false
+	}
+	if lastSeen != nil && now.Sub(*lastSeen) > autheticationMiddleware.idleTimeout {
+		logger.Error(nil, "The session has been idle beyond the allowed window")
+		errors.Abort(ctx, errors.IdleTimeout)
+		return false
+	}
+	if tokenType != commonToken.AuthTokenType {
+		return true
+	}
+	err = autheticationMiddleware.activityStore.Touch(requestContext, sessionID, now, autheticationMiddleware.activityRetention)
+	if err != nil {
+		logger.Error(err, "Could not record session activity")
+	}
+	return true
+}
+
+// sessionOf returns the session the access token was issued for, the tokens issued before their session was
+// linked being their own session
+func (autheticationMiddleware *AutheticationMiddleware) sessionOf(ctx context.Context, token string) (string, error) {
+	tokenID := session.IDFromToken(token)
+	sessionID, err := autheticationMiddleware.activityStore.SessionOf(ctx, tokenID)
+	if err != nil {
+		return "", err
+	}
+	if sessionID == "" {
+		return tokenID, nil
+	}
+	return sessionID, nil
+}
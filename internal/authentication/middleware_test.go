@@ -20,6 +20,10 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/mock"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
 )
 
 func createTestContext(method, path string, body []byte, authHeader *string) (*gin.Context, *httptest.ResponseRecorder) {
@@ -100,9 +104,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		ctx, w := createTestContext("GET", "/test", nil, nil)
 
@@ -118,9 +122,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -140,9 +144,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -163,9 +167,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -186,9 +190,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -211,9 +215,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -240,9 +244,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -270,9 +274,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -301,9 +305,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -325,6 +329,76 @@ func TestMiddleware(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
+	t.Run("RequireAuthentication_Runs_Authenticated_Hooks_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+		}
+		var hookedClaims *commmonJWT.TokenClaims
+		authenticationMiddleware.OnAuthenticated(func(ctx *gin.Context, claims *commmonJWT.TokenClaims) {
+			hookedClaims = claims
+		})
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{}
+		tokenClaims := &commmonJWT.TokenClaims{
+			UserID: "user-id",
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(10 * time.Second),
+		}
+
+		ctx, _ := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, tokenClaims, hookedClaims)
+	})
+
+	t.Run("RequireAuthentication_Verifies_The_Token_Once_Per_Request_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+		}
+		hookRuns := 0
+		authenticationMiddleware.OnAuthenticated(func(ctx *gin.Context, claims *commmonJWT.TokenClaims) {
+			hookRuns++
+		})
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{}
+		tokenClaims := &commmonJWT.TokenClaims{
+			UserID: "user-id",
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(10 * time.Second),
+		}
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, 1, hookRuns)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
 	// Refresh Authentication
 	t.Run("RefreshAuthentication_Wrong_Type_Claim_Authorization_Header_Error", func(t *testing.T) {
 		controller := gomock.NewController(t)
@@ -333,9 +407,9 @@ func TestMiddleware(t *testing.T) {
 		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
 		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
 		authenticationMiddleware := &AutheticationMiddleware{
-			serviceMock,
-			jwtVerifierMock,
-			jwtTokenInspectorMock,
+			service:           serviceMock,
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
 		}
 		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
 
@@ -357,3 +431,469 @@ func TestMiddleware(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
 }
+
+func TestMiddlewareExpiryHint(t *testing.T) {
+	t.Run("RequireAuthentication_Expiry_Hint_Header_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			expiryHintWindow:  time.Minute,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(30 * time.Second),
+		}
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get(TokenExpiresInHeader))
+	})
+
+	t.Run("RequireAuthentication_No_Expiry_Hint_Header_Outside_Window", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			expiryHintWindow:  time.Minute,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(10 * time.Minute),
+		}
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(TokenExpiresInHeader))
+	})
+
+	t.Run("RequireAuthentication_Expiry_Preempt_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:         jwtVerifierMock,
+			jwtTokenInspector:   jwtTokenInspectorMock,
+			expiryHintWindow:    time.Minute,
+			expiryPreemptWindow: 10 * time.Second,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(5 * time.Second),
+		}
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Error(nil, "The bearer token is about to expire")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "token_expiring")
+	})
+}
+
+func TestMiddlewareIdleTimeout(t *testing.T) {
+	t.Run("RequireAuthentication_Idle_Timeout_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		activityStore := session.NewMemoryActivityStore()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore:     activityStore,
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(10 * time.Minute),
+			UserID: "test-user-id",
+		}
+		activityStore.Touch(context.Background(), session.IDFromToken("test-header"), time.Now().Add(-2*time.Minute), time.Hour)
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Error(nil, "The session has been idle beyond the allowed window")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "idle_timeout")
+	})
+
+	t.Run("RequireAuthentication_Idle_Timeout_Records_Activity_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		activityStore := session.NewMemoryActivityStore()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore:     activityStore,
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(10 * time.Minute),
+			UserID: "test-user-id",
+		}
+		activityStore.Touch(context.Background(), session.IDFromToken("test-header"), time.Now().Add(-30*time.Second), time.Hour)
+		activityStore.Touch(context.Background(), session.IDFromToken("other-session-token"), time.Now().Add(-2*time.Minute), time.Hour)
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		lastSeen, err := activityStore.LastSeen(context.Background(), session.IDFromToken("test-header"))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), *lastSeen, time.Second)
+		otherLastSeen, err := activityStore.LastSeen(context.Background(), session.IDFromToken("other-session-token"))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(-2*time.Minute), *otherLastSeen, time.Second)
+	})
+
+	t.Run("TrackSession_Resets_Activity_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		activityStore := session.NewMemoryActivityStore()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore:     activityStore,
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		activityStore.Touch(context.Background(), session.IDFromToken("issued-token"), time.Now().Add(-2*time.Minute), time.Hour)
+
+		ctx, _ := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(routes.IssuedAuthTokenKey, "issued-token")
+
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("issued-token").Return(&commmonJWT.TokenClaims{
+			UserID: "test-user-id",
+		}, nil)
+
+		authenticationMiddleware.TrackSession(ctx)
+
+		lastSeen, err := activityStore.LastSeen(context.Background(), session.IDFromToken("issued-token"))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), *lastSeen, time.Second)
+	})
+
+	t.Run("RequireAuthentication_Idle_Timeout_Of_The_Linked_Session_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		activityStore := session.NewMemoryActivityStore()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore:     activityStore,
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(10 * time.Minute),
+			UserID: "test-user-id",
+		}
+		activityStore.Link(context.Background(), session.IDFromToken("test-header"), session.IDFromToken("refresh-token"), time.Hour)
+		activityStore.Touch(context.Background(), session.IDFromToken("refresh-token"), time.Now().Add(-2*time.Minute), time.Hour)
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Error(nil, "The session has been idle beyond the allowed window")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "idle_timeout")
+	})
+
+	t.Run("RefreshAuthentication_Idle_Timeout_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		activityStore := session.NewMemoryActivityStore()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore:     activityStore,
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer refresh-token"
+		testToken := &jwt.Token{Raw: "refresh-token"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.RefreshTokenType,
+			Expiry: time.Now().Add(24 * time.Hour),
+			UserID: "test-user-id",
+		}
+		activityStore.Touch(context.Background(), session.IDFromToken("refresh-token"), time.Now().Add(-2*time.Minute), time.Hour)
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("refresh-token").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Error(nil, "The session has been idle beyond the allowed window")
+
+		authenticationMiddleware.RefreshAuthentication(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "idle_timeout")
+	})
+
+	t.Run("RefreshAuthentication_Does_Not_Record_Activity_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		activityStore := session.NewMemoryActivityStore()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore:     activityStore,
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer refresh-token"
+		testToken := &jwt.Token{Raw: "refresh-token"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.RefreshTokenType,
+			Expiry: time.Now().Add(24 * time.Hour),
+			UserID: "test-user-id",
+		}
+		seenAt := time.Now().Add(-30 * time.Second)
+		activityStore.Touch(context.Background(), session.IDFromToken("refresh-token"), seenAt, time.Hour)
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("refresh-token").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RefreshAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		lastSeen, err := activityStore.LastSeen(context.Background(), session.IDFromToken("refresh-token"))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, seenAt, *lastSeen, time.Millisecond)
+	})
+
+	t.Run("RequireAuthentication_Idle_Timeout_Store_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore: session.NewRedisActivityStore(redis.NewClient(&config.Config{
+				Redis: config.RedisConfig{Address: "127.0.0.1:1", Timeout: 100 * time.Millisecond},
+			})),
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(10 * time.Minute),
+			UserID: "test-user-id",
+		}
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Error(gomock.Any(), "Could not check session activity")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"service_unavailable","message":"The service is unavailable, retry later"}}`, w.Body.String())
+	})
+
+	t.Run("RequireAuthentication_Idle_Timeout_Store_Error_Fail_Open_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore: session.NewRedisActivityStore(redis.NewClient(&config.Config{
+				Redis: config.RedisConfig{Address: "127.0.0.1:1", Timeout: 100 * time.Millisecond},
+			})),
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+			activityFailOpen:  true,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(10 * time.Minute),
+			UserID: "test-user-id",
+		}
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Error(gomock.Any(), "Could not check session activity")
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("TrackSession_Links_The_Access_Token_To_The_Session_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		activityStore := session.NewMemoryActivityStore()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore:     activityStore,
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		ctx, _ := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(routes.IssuedAuthTokenKey, "issued-token")
+		ctx.Set(routes.IssuedRefreshTokenKey, "issued-refresh-token")
+
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("issued-token").Return(&commmonJWT.TokenClaims{
+			UserID: "test-user-id",
+			Expiry: time.Now().Add(10 * time.Minute),
+		}, nil)
+
+		authenticationMiddleware.TrackSession(ctx)
+
+		sessionID, err := activityStore.SessionOf(context.Background(), session.IDFromToken("issued-token"))
+		assert.NoError(t, err)
+		assert.Equal(t, session.IDFromToken("issued-refresh-token"), sessionID)
+		lastSeen, err := activityStore.LastSeen(context.Background(), sessionID)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), *lastSeen, time.Second)
+	})
+
+	t.Run("TrackSession_Refresh_Keeps_The_Session_Activity_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		activityStore := session.NewMemoryActivityStore()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtTokenInspector: jwtTokenInspectorMock,
+			activityStore:     activityStore,
+			idleTimeout:       time.Minute,
+			activityRetention: time.Hour,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		seenAt := time.Now().Add(-30 * time.Second)
+		activityStore.Touch(context.Background(), session.IDFromToken("refresh-token"), seenAt, time.Hour)
+
+		ctx, _ := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), &jwt.Token{Raw: "refresh-token"})
+		ctx.Set(routes.IssuedAuthTokenKey, "issued-token")
+		ctx.Set(routes.IssuedRefreshTokenKey, "issued-refresh-token")
+
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("issued-token").Return(&commmonJWT.TokenClaims{
+			UserID: "test-user-id",
+			Expiry: time.Now().Add(10 * time.Minute),
+		}, nil)
+
+		authenticationMiddleware.TrackSession(ctx)
+
+		sessionID, err := activityStore.SessionOf(context.Background(), session.IDFromToken("issued-token"))
+		assert.NoError(t, err)
+		assert.Equal(t, session.IDFromToken("issued-refresh-token"), sessionID)
+		lastSeen, err := activityStore.LastSeen(context.Background(), sessionID)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, seenAt, *lastSeen, time.Millisecond)
+	})
+
+	t.Run("InitAuthenticationMiddleware_Retention_Shorter_Than_Idle_Timeout_Error", func(t *testing.T) {
+		authenticationMiddleware, err := InitAuthenticationMiddleware(nil, &config.Config{
+			Authentication: config.AuthenticationConfig{IdleTimeout: time.Hour, ActivityRetention: time.Minute},
+		})
+
+		assert.Nil(t, authenticationMiddleware)
+		assert.EqualError(t, err, "The activity retention 1m0s is shorter than the idle timeout 1h0m0s")
+	})
+}
@@ -10,6 +10,7 @@ import (
 
 	gin "github.com/gin-gonic/gin"
 	gomock "github.com/golang/mock/gomock"
+	pb_authentication "github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
 )
 
 // MockServiceClienter is a mock of ServiceClienter interface.
@@ -74,6 +75,21 @@ func (mr *MockServiceClienterMockRecorder) GetPublicKey(ctx interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPublicKey", reflect.TypeOf((*MockServiceClienter)(nil).GetPublicKey), ctx)
 }
 
+// GetUser mocks base method.
+func (m *MockServiceClienter) GetUser(ctx context.Context) (*pb_authentication.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx)
+	ret0, _ := ret[0].(*pb_authentication.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockServiceClienterMockRecorder) GetUser(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockServiceClienter)(nil).GetUser), ctx)
+}
+
 // GetUserProfile mocks base method.
 func (m *MockServiceClienter) GetUserProfile(ctx *gin.Context) {
 	m.ctrl.T.Helper()
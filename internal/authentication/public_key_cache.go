@@ -0,0 +1,141 @@
+package authentication
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+)
+
+// publicKeyRefreshTimeout bounds the background refreshes of an expired public key
+const publicKeyRefreshTimeout = 10 * time.Second
+
+// PublicKeyInfo is the metadata of the cached public key, the key itself is left out
+type PublicKeyInfo struct {
+	Fingerprint string     `json:"fingerprint"`
+	Algorithm   string     `json:"algorithm"`
+	Bits        int        `json:"bits,omitempty"`
+	FetchedAt   time.Time  `json:"fetchedAt"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	Refreshing  bool       `json:"refreshing"`
+}
+
+// PublicKeyDescriber describes the public key verifying the tokens
+type PublicKeyDescriber interface {
+	PublicKeyInfo() PublicKeyInfo
+}
+
+var _ PublicKeyDescriber = &AutheticationMiddleware{}
+
+// PublicKeyInfo describes the cached public key: the SHA-256 fingerprint of its DER encoding, or of the key as
+// fetched when it is not a PEM encoded key, its algorithm and size, and when it was fetched and expires
+func (autheticationMiddleware *AutheticationMiddleware) PublicKeyInfo() PublicKeyInfo {
+	autheticationMiddleware.verifierMtx.RLock()
+	publicKey := autheticationMiddleware.publicKey
+	info := PublicKeyInfo{
+		Algorithm:  "unknown",
+		FetchedAt:  autheticationMiddleware.publicKeyFetchedAt,
+		Refreshing: autheticationMiddleware.refreshingPublicKey.Load(),
+	}
+	if autheticationMiddleware.publicKeyTTL > 0 {
+		expiresAt := info.FetchedAt.Add(autheticationMiddleware.publicKeyTTL)
+		info.ExpiresAt = &expiresAt
+	}
+	autheticationMiddleware.verifierMtx.RUnlock()
+
+	encoded := []byte(publicKey)
+	if block, _ := pem.Decode(encoded); block != nil {
+		encoded = block.Bytes
+		if parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+			switch typedKey := parsedKey.(type) {
+			case *rsa.PublicKey:
+				info.Algorithm, info.Bits = "RSA", typedKey.N.BitLen()
+			case *ecdsa.PublicKey:
+				info.Algorithm, info.Bits = "ECDSA", typedKey.Curve.Params().BitSize
+			case ed25519.PublicKey:
+				info.Algorithm = "Ed25519"
+			}
+		}
+	}
+	fingerprint := sha256.Sum256(encoded)
+	info.Fingerprint = hex.EncodeToString(fingerprint[:])
+	return info
+}
+
+// setPublicKey caches the verifier of the public key and tells whether the key changed
+func (autheticationMiddleware *AutheticationMiddleware) setPublicKey(publicKey string, now time.Time) (bool, error) {
+	autheticationMiddleware.verifierMtx.Lock()
+	defer autheticationMiddleware.verifierMtx.Unlock()
+	autheticationMiddleware.publicKeyFetchedAt = now
+	if publicKey == autheticationMiddleware.publicKey && autheticationMiddleware.jwtVerifier != nil {
+		return false, nil
+	}
+	jwtVerifier, err := commonJWT.NewTokenVerifier(publicKey)
+	if err != nil {
+		return false, err
+	}
+	autheticationMiddleware.publicKey = publicKey
+	autheticationMiddleware.jwtVerifier = jwtVerifier
+	return true, nil
+}
+
+// verifier returns the verifier of the cached public key, refreshing the key in the background once
+// its TTL is over while the stale key keeps verifying tokens, so the lookups do not depend on the service
+func (autheticationMiddleware *AutheticationMiddleware) verifier() commonJWT.TokenVerifierer {
+	autheticationMiddleware.verifierMtx.RLock()
+	jwtVerifier := autheticationMiddleware.jwtVerifier
+	expired := autheticationMiddleware.publicKeyTTL > 0 &&
+		time.Since(autheticationMiddleware.publicKeyFetchedAt) > autheticationMiddleware.publicKeyTTL
+	autheticationMiddleware.verifierMtx.RUnlock()
+	if expired && autheticationMiddleware.refreshingPublicKey.CompareAndSwap(false, true) {
+		go func() {
+			defer autheticationMiddleware.refreshingPublicKey.Store(false)
+			ctx, cancel := context.WithTimeout(context.Background(), publicKeyRefreshTimeout)
+			defer cancel()
+			if err := autheticationMiddleware.RefreshPublicKey(ctx); err != nil {
+				commonLogger.NewLogFactory(autheticationMiddleware.environment).NewLogger().Error(err, "Could not refresh the expired public key")
+			}
+		}()
+	}
+	if autheticationMiddleware.tokenMinter != nil {
+		jwtVerifier = &mintedTokenVerifier{verifier: jwtVerifier, minted: autheticationMiddleware.tokenMinter.verifier}
+	}
+	if autheticationMiddleware.federation != nil {
+		jwtVerifier = &federatedTokenVerifier{verifier: jwtVerifier, federation: autheticationMiddleware.federation}
+	}
+	return jwtVerifier
+}
+
+// refreshAfterKeyMismatch fetches the public key again when a token signature does not match the cached key,
+// at most once per cooldown so invalid signatures cannot flood the authentication service, and tells
+// whether the key changed so the token is worth verifying again
+func (autheticationMiddleware *AutheticationMiddleware) refreshAfterKeyMismatch(ctx context.Context, err error) bool {
+	validationError, ok := err.(*jwt.ValidationError)
+	if !ok || validationError.Errors&jwt.ValidationErrorSignatureInvalid == 0 || autheticationMiddleware.service == nil {
+		return false
+	}
+	autheticationMiddleware.verifierMtx.Lock()
+	now := time.Now()
+	if now.Sub(autheticationMiddleware.keyMismatchRefreshedAt) < autheticationMiddleware.keyMismatchCooldown {
+		autheticationMiddleware.verifierMtx.Unlock()
+		return false
+	}
+	autheticationMiddleware.keyMismatchRefreshedAt = now
+	autheticationMiddleware.verifierMtx.Unlock()
+
+	publicKey, err := autheticationMiddleware.fetchPublicKey(ctx)
+	if err != nil {
+		return false
+	}
+	changed, err := autheticationMiddleware.setPublicKey(*publicKey, now)
+	return err == nil && changed
+}
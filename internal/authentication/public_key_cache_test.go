@@ -0,0 +1,116 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/mock"
+)
+
+func newTestKey(t *testing.T) (*rsa.PrivateKey, string) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	assert.NoError(t, err)
+	return privateKey, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}))
+}
+
+func signTestToken(t *testing.T, privateKey *rsa.PrivateKey) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	}).SignedString(privateKey)
+	assert.NoError(t, err)
+	return token
+}
+
+func TestPublicKeyCache(t *testing.T) {
+	_, oldPublicKey := newTestKey(t)
+	rotatedPrivateKey, rotatedPublicKey := newTestKey(t)
+	rotatedToken := signTestToken(t, rotatedPrivateKey)
+
+	newCachedMiddleware := func(t *testing.T, service ServiceClienter) *AutheticationMiddleware {
+		autheticationMiddleware := &AutheticationMiddleware{service: service, keyMismatchCooldown: time.Minute}
+		_, err := autheticationMiddleware.setPublicKey(oldPublicKey, time.Now())
+		assert.NoError(t, err)
+		return autheticationMiddleware
+	}
+
+	t.Run("RefreshAfterKeyMismatch_Should_Adopt_The_Rotated_Key", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		serviceMock := mock.NewMockServiceClienter(controller)
+		autheticationMiddleware := newCachedMiddleware(t, serviceMock)
+
+		serviceMock.EXPECT().GetPublicKey(gomock.Any()).Return(&rotatedPublicKey, nil)
+
+		_, err := autheticationMiddleware.verifier().Verify(rotatedToken)
+		assert.Error(t, err)
+		assert.True(t, autheticationMiddleware.refreshAfterKeyMismatch(context.Background(), err))
+		_, err = autheticationMiddleware.verifier().Verify(rotatedToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("RefreshAfterKeyMismatch_Should_Wait_For_The_Cooldown", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		serviceMock := mock.NewMockServiceClienter(controller)
+		autheticationMiddleware := newCachedMiddleware(t, serviceMock)
+
+		serviceMock.EXPECT().GetPublicKey(gomock.Any()).Return(&oldPublicKey, nil).Times(1)
+
+		_, err := autheticationMiddleware.verifier().Verify(rotatedToken)
+		assert.False(t, autheticationMiddleware.refreshAfterKeyMismatch(context.Background(), err))
+		assert.False(t, autheticationMiddleware.refreshAfterKeyMismatch(context.Background(), err))
+	})
+
+	t.Run("RefreshAfterKeyMismatch_Should_Ignore_Other_Errors", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		serviceMock := mock.NewMockServiceClienter(controller)
+		autheticationMiddleware := newCachedMiddleware(t, serviceMock)
+
+		_, err := autheticationMiddleware.verifier().Verify("not-a-token")
+		assert.False(t, autheticationMiddleware.refreshAfterKeyMismatch(context.Background(), err))
+	})
+
+	t.Run("Verifier_Should_Refresh_The_Expired_Key_In_The_Background", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		serviceMock := mock.NewMockServiceClienter(controller)
+		autheticationMiddleware := newCachedMiddleware(t, serviceMock)
+		autheticationMiddleware.publicKeyTTL = time.Minute
+		autheticationMiddleware.publicKeyFetchedAt = time.Now().Add(-2 * time.Minute)
+
+		serviceMock.EXPECT().GetPublicKey(gomock.Any()).Return(&rotatedPublicKey, nil)
+
+		_, err := autheticationMiddleware.verifier().Verify(rotatedToken)
+		assert.Error(t, err)
+		assert.Eventually(t, func() bool {
+			_, err := autheticationMiddleware.verifier().Verify(rotatedToken)
+			return err == nil
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("PublicKeyInfo_Should_Describe_The_Cached_Key", func(t *testing.T) {
+		autheticationMiddleware := newCachedMiddleware(t, nil)
+		autheticationMiddleware.publicKeyTTL = time.Minute
+
+		info := autheticationMiddleware.PublicKeyInfo()
+
+		assert.Equal(t, "RSA", info.Algorithm)
+		assert.Equal(t, 2048, info.Bits)
+		assert.Len(t, info.Fingerprint, 64)
+		assert.Equal(t, info.FetchedAt.Add(time.Minute), *info.ExpiresAt)
+		_, err := autheticationMiddleware.setPublicKey(rotatedPublicKey, time.Now())
+		assert.NoError(t, err)
+		assert.NotEqual(t, info.Fingerprint, autheticationMiddleware.PublicKeyInfo().Fingerprint)
+	})
+}
@@ -0,0 +1,136 @@
+package authentication
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Token claims listing the roles and permissions of the user
+const (
+	RolesClaim       = "roles"
+	RoleClaim        = "role"
+	PermissionsClaim = "permissions"
+)
+
+// RequireRole returns a middleware allowing only tokens holding one of the given roles
+func (autheticationMiddleware *AutheticationMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+		if err != nil {
+			errors.AbortWithError(ctx, errors.InternalError, err)
+			return
+		}
+		token := authenticatedToken(ctx, logger)
+		if token == nil {
+			return
+		}
+
+		for _, tokenRole := range autheticationMiddleware.GetRoles(token) {
+			for _, role := range roles {
+				if strings.EqualFold(tokenRole, role) {
+					ctx.Next()
+					return
+				}
+			}
+		}
+		logger.Error(nil, "The user does not hold the role required by the route")
+		errors.Abort(ctx, errors.InsufficientRole)
+	}
+}
+
+// RequireRoles returns a middleware allowing only tokens holding every one of the given roles,
+// each of them granted either by the roles claims or by the permissions claim
+func (autheticationMiddleware *AutheticationMiddleware) RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+		if err != nil {
+			errors.AbortWithError(ctx, errors.InternalError, err)
+			return
+		}
+		token := authenticatedToken(ctx, logger)
+		if token == nil {
+			return
+		}
+
+		granted := map[string]bool{}
+		for _, grant := range append(autheticationMiddleware.GetRoles(token), autheticationMiddleware.GetPermissions(token)...) {
+			granted[strings.ToLower(grant)] = true
+		}
+		for _, role := range roles {
+			if !granted[strings.ToLower(role)] {
+				logger.Error(nil, fmt.Sprintf("The user does not hold the role %s required by the route", role))
+				errors.Abort(ctx, errors.InsufficientRole)
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// authenticatedToken returns the token verified by the authentication middleware, aborting when there is none
+func authenticatedToken(ctx *gin.Context, logger commonLogger.Loggerer) *jwt.Token {
+	tokenValue, _ := ctx.Get(string(commonJWT.JWTTokenKey))
+	token, ok := tokenValue.(*jwt.Token)
+	if !ok {
+		logger.Error(nil, "No authenticated token was found in the request")
+		errors.Abort(ctx, errors.Unauthenticated)
+		return nil
+	}
+	return token
+}
+
+// GetRoles reads the roles claim, accepting either a list of roles or a single role
+func (autheticationMiddleware *AutheticationMiddleware) GetRoles(token *jwt.Token) []string {
+	roles := []string{}
+	if claim, err := autheticationMiddleware.jwtTokenInspector.GetClaimFromToken(token, RolesClaim); err == nil {
+		switch value := claim.(type) {
+		case []interface{}:
+			for _, role := range value {
+				if roleName, ok := role.(string); ok {
+					roles = append(roles, roleName)
+				}
+			}
+		case string:
+			roles = append(roles, strings.Fields(value)...)
+		}
+	}
+	if claim, err := autheticationMiddleware.jwtTokenInspector.GetClaimFromToken(token, RoleClaim); err == nil {
+		if roleName, ok := claim.(string); ok && roleName != "" {
+			roles = append(roles, roleName)
+		}
+	}
+	return roles
+}
+
+// TokenRoles reads the roles of a verified token, for the role checks made outside of the middleware
+func TokenRoles(token *jwt.Token) []string {
+	inspector := &AutheticationMiddleware{jwtTokenInspector: &commonJWT.TokenInspector{}}
+	return inspector.GetRoles(token)
+}
+
+// GetPermissions reads the permissions claim, accepting either a list of permissions or a space separated string
+func (autheticationMiddleware *AutheticationMiddleware) GetPermissions(token *jwt.Token) []string {
+	permissions := []string{}
+	claim, err := autheticationMiddleware.jwtTokenInspector.GetClaimFromToken(token, PermissionsClaim)
+	if err != nil {
+		return permissions
+	}
+	switch value := claim.(type) {
+	case []interface{}:
+		for _, permission := range value {
+			if permissionName, ok := permission.(string); ok {
+				permissions = append(permissions, permissionName)
+			}
+		}
+	case string:
+		permissions = append(permissions, strings.Fields(value)...)
+	}
+	return permissions
+}
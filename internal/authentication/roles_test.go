@@ -0,0 +1,156 @@
+package authentication
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	t.Run("RequireRole_Roles_Claim_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RolesClaim).Return([]interface{}{"user", "Admin"}, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RoleClaim).Return(nil, errors.New("missing claim"))
+
+		authenticationMiddleware.RequireRole("admin")(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RequireRole_Role_Claim_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RolesClaim).Return(nil, errors.New("missing claim"))
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RoleClaim).Return("admin", nil)
+
+		authenticationMiddleware.RequireRole("admin")(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RequireRole_Missing_Role_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RolesClaim).Return([]interface{}{"user"}, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RoleClaim).Return(nil, errors.New("missing claim"))
+		loggerMock.EXPECT().Error(nil, "The user does not hold the role required by the route")
+
+		authenticationMiddleware.RequireRole("admin")(ctx)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.True(t, ctx.IsAborted())
+		assert.Contains(t, w.Body.String(), "insufficient_role")
+	})
+
+	t.Run("RequireRole_No_Token_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+
+		loggerMock.EXPECT().Error(nil, "No authenticated token was found in the request")
+
+		authenticationMiddleware.RequireRole("admin")(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.True(t, ctx.IsAborted())
+	})
+}
+
+func TestRequireRoles(t *testing.T) {
+	t.Run("RequireRoles_Roles_And_Permissions_Claims_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RolesClaim).Return([]interface{}{"Support"}, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RoleClaim).Return(nil, errors.New("missing claim"))
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, PermissionsClaim).Return("tickets:read tickets:write", nil)
+
+		authenticationMiddleware.RequireRoles("support", "tickets:write")(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RequireRoles_Missing_One_Role_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RolesClaim).Return(nil, errors.New("missing claim"))
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, RoleClaim).Return("support", nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, PermissionsClaim).Return([]interface{}{"tickets:read"}, nil)
+		loggerMock.EXPECT().Error(nil, "The user does not hold the role tickets:write required by the route")
+
+		authenticationMiddleware.RequireRoles("support", "tickets:write")(ctx)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.True(t, ctx.IsAborted())
+		assert.Contains(t, w.Body.String(), "insufficient_role")
+	})
+
+	t.Run("RequireRoles_No_Token_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+
+		loggerMock.EXPECT().Error(nil, "No authenticated token was found in the request")
+
+		authenticationMiddleware.RequireRoles("admin")(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.True(t, ctx.IsAborted())
+	})
+}
@@ -5,21 +5,25 @@ import (
 
 	"github.com/gin-gonic/gin"
 	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
-	"golang.org/x/time/rate"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
 
 	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
-	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/preferences"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/public"
 )
 
 // RegisterRoutes registers the authentication routes
 func RegisterRoutes(
 	api *gin.RouterGroup,
+	publicRoutes *public.Group,
 	centralConfig *commonConfig.Config,
 	configurations *config.Config,
-) (*ServiceClient, error) {
-	client, err := InitServiceClient(centralConfig)
+	localization *preferences.Resolver,
+) (*ServiceClient, AutheticationMiddlewarer, error) {
+	client, err := InitServiceClient(centralConfig, configurations)
 	if err != nil {
-		return nil, fmt.Errorf("Could not initialize authentication service client: %v", err)
+		return nil, nil, fmt.Errorf("Could not initialize authentication service client: %v", err)
 	}
 	service := &ServiceClient{
 		client: client,
@@ -27,27 +31,37 @@ func RegisterRoutes(
 
 	authenticationMiddleware, err := InitAuthenticationMiddleware(service, configurations)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to initiate authenticator middleware: %v", err)
+		return nil, nil, fmt.Errorf("Failed to initiate authenticator middleware: %v", err)
 	}
 
-	rl := middleware.NewRateLimiter(rate.Limit(0.08), 5)
+	eventPublisher, err := events.NewPublisher(configurations)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to initiate event publisher: %v", err)
+	}
+	loginEvents := events.LoginEventMiddleware(eventPublisher, &commonJWT.TokenInspector{})
+
+	ageGate := NewAgeGate(service, &commonJWT.TokenInspector{}, configurations)
+	localization.UseSource(service)
+
+	publicUserRoutes := publicRoutes.Group("/user")
+	publicUserRoutes.Use(localization.Middleware)
+	publicUserRoutes.POST("/", publicRoutes.Protected(service.Register)...)
+	publicUserRoutes.POST("/:userID/email/:verificationToken", publicRoutes.Limited(service.VerifyEmail)...)
+	publicUserRoutes.POST("/sessions", publicRoutes.Protected(loginEvents, authenticationMiddleware.TrackSession, service.Authenticate)...)
+	publicUserRoutes.POST("/firebase/sessions", publicRoutes.Protected(loginEvents, authenticationMiddleware.TrackSession, service.AuthenticateWithFirebase)...)
+	publicUserRoutes.POST("/:userID/email/verification", publicRoutes.Limited(service.ResendEmailVerification)...)
+	publicUserRoutes.POST("/password/reset", publicRoutes.Protected(service.ForgotPassword)...)
+	publicUserRoutes.GET("/:userID/password/reset-verification/:verificationToken", publicRoutes.Limited(service.VerifyResetPasswordToken)...)
+	publicUserRoutes.POST("/:userID/password/reset/:verificationToken", publicRoutes.Protected(service.ResetPassword)...)
 
 	userRoutes := api.Group("/user")
-	userRoutes.POST("/", middleware.RateLimitMiddleware(rl), service.Register)
-	userRoutes.POST("/:userID/email/:verificationToken", service.VerifyEmail)
-	userRoutes.POST("/sessions", middleware.RateLimitMiddleware(rl), service.Authenticate)
-	userRoutes.POST("/firebase/sessions", middleware.RateLimitMiddleware(rl), service.AuthenticateWithFirebase)
-	userRoutes.POST("/:userID/email/verification", middleware.RateLimitMiddleware(rl), service.ResendEmailVerification)
-	userRoutes.POST("/password/reset", middleware.RateLimitMiddleware(rl), service.ForgotPassword)
-	userRoutes.GET("/:userID/password/reset-verification/:verificationToken", middleware.RateLimitMiddleware(rl), service.VerifyResetPasswordToken)
-	userRoutes.POST("/:userID/password/reset/:verificationToken", middleware.RateLimitMiddleware(rl), service.ResetPassword)
-	userRoutes.GET("/profile", authenticationMiddleware.RequireAuthentication, service.GetUserProfile)
-	userRoutes.PUT("/profile", authenticationMiddleware.RequireAuthentication, service.UpdateUserProfile)
-	userRoutes.DELETE("", authenticationMiddleware.RequireAuthentication, service.DeleteAccount)
+	userRoutes.GET("/profile", authenticationMiddleware.RequireAuthentication, ageGate.RequireAge, localization.Middleware, service.GetUserProfile)
+	userRoutes.PUT("/profile", authenticationMiddleware.RequireAuthentication, authenticationMiddleware.RequireVerifiedEmail, ageGate.RequireAge, localization.Middleware, service.UpdateUserProfile)
+	userRoutes.DELETE("", authenticationMiddleware.RequireAuthentication, ageGate.RequireAge, localization.Middleware, service.DeleteAccount)
 
 	authenticationRoutes := api.Group("/authentication")
-	authenticationRoutes.Use(authenticationMiddleware.RefreshAuthentication)
+	authenticationRoutes.Use(authenticationMiddleware.RefreshAuthentication, authenticationMiddleware.TrackSession)
 	authenticationRoutes.POST("/refresh", service.RefreshToken)
 
-	return service, nil
+	return service, authenticationMiddleware, nil
 }
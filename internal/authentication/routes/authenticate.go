@@ -9,6 +9,12 @@ import (
 	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
 )
 
+// Context keys holding the tokens issued by a successful login or refresh
+const (
+	IssuedAuthTokenKey    = "issued_auth_token"
+	IssuedRefreshTokenKey = "issued_refresh_token"
+)
+
 // AuthenticateRequestBody is the request body for the Authenticate route
 type AuthenticateRequestBody struct {
 	Email    string `json:"email"`
@@ -19,8 +25,8 @@ type AuthenticateRequestBody struct {
 func Authenticate(ctx *gin.Context, client pb_authentication.AuthenticationServiceClient) {
 	body := AuthenticateRequestBody{}
 
-	if err := ctx.BindJSON(&body); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
 		return
 	}
 
@@ -34,5 +40,7 @@ func Authenticate(ctx *gin.Context, client pb_authentication.AuthenticationServi
 		return
 	}
 
+	ctx.Set(IssuedAuthTokenKey, res.GetAuthToken())
+	ctx.Set(IssuedRefreshTokenKey, res.GetRefreshToken())
 	ctx.JSON(http.StatusOK, &res)
 }
@@ -21,8 +21,8 @@ type AuthenticateWithFirebaseRequestBody struct {
 func AuthenticateWithFirebase(ctx *gin.Context, client pb_authentication.AuthenticationServiceClient) {
 	body := AuthenticateWithFirebaseRequestBody{}
 
-	if err := ctx.BindJSON(&body); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
 		return
 	}
 
@@ -41,5 +41,7 @@ func AuthenticateWithFirebase(ctx *gin.Context, client pb_authentication.Authent
 		return
 	}
 
+	ctx.Set(IssuedAuthTokenKey, res.GetAuthToken())
+	ctx.Set(IssuedRefreshTokenKey, res.GetRefreshToken())
 	ctx.JSON(http.StatusOK, &res)
 }
@@ -18,8 +18,8 @@ type ForgotPasswordRequestBody struct {
 func ForgotPassword(ctx *gin.Context, client pb_authentication.AuthenticationServiceClient) {
 	body := ForgotPasswordRequestBody{}
 
-	if err := ctx.BindJSON(&body); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
 		return
 	}
 	res, err := client.ForgotPassword(
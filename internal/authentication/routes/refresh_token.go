@@ -29,5 +29,7 @@ func RefreshToken(
 		return
 	}
 
+	ctx.Set(IssuedAuthTokenKey, res.GetAuthToken())
+	ctx.Set(IssuedRefreshTokenKey, res.GetRefreshToken())
 	ctx.JSON(http.StatusOK, &res)
 }
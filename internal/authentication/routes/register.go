@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -24,12 +23,12 @@ type RegisterRequestBody struct {
 func Register(ctx *gin.Context, client pb_authentication.AuthenticationServiceClient) {
 	body := RegisterRequestBody{}
 
-	if err := ctx.BindJSON(&body); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
 		return
 	}
 	if body.DateOfBirth == nil {
-		ctx.AbortWithError(http.StatusBadRequest, fmt.Errorf("dateOfBirth is required"))
+		errors.Abort(ctx, errors.InvalidRequestBody, errors.FieldViolation("dateOfBirth", "dateOfBirth is required"))
 		return
 	}
 	res, err := client.Register(ctx.Request.Context(), &pb_authentication.RegisterRequest{
@@ -17,8 +17,8 @@ type ResetPasswordRequestBody struct {
 // ResetPassword resets a user's password
 func ResetPassword(ctx *gin.Context, client pb_authentication.AuthenticationServiceClient) {
 	body := ResetPasswordRequestBody{}
-	if err := ctx.BindJSON(&body); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
 		return
 	}
 	res, err := client.ResetPassword(
@@ -22,8 +22,8 @@ type UpdateUserProfileRequestBody struct {
 func UpdateUserProfile(ctx *gin.Context, client pb_authentication.AuthenticationServiceClient) {
 	body := UpdateUserProfileRequestBody{}
 
-	if err := ctx.BindJSON(&body); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
 		return
 	}
 
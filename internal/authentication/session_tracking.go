@@ -0,0 +1,299 @@
+package authentication
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+)
+
+// Policies applied when a user exceeds the maximum number of concurrent sessions
+const (
+	SessionLimitRejectNewest = "reject_newest"
+	SessionLimitRevokeOldest = "revoke_oldest"
+)
+
+// ActivityTracker tracks the idle time of the sessions in an activity store the reloaded routers share
+type ActivityTracker interface {
+	ActivityStore() session.ActivityStorer
+	UseActivityStore(store session.ActivityStorer)
+}
+
+var _ ActivityTracker = &AutheticationMiddleware{}
+
+// bufferedResponseWriter holds the response back until the session has been registered
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (writer *bufferedResponseWriter) WriteHeader(code int) {
+	writer.status = code
+}
+
+func (writer *bufferedResponseWriter) WriteHeaderNow() {}
+
+func (writer *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return writer.body.Write(data)
+}
+
+func (writer *bufferedResponseWriter) WriteString(data string) (int, error) {
+	return writer.body.WriteString(data)
+}
+
+func (writer *bufferedResponseWriter) Status() int {
+	if writer.status == 0 {
+		return http.StatusOK
+	}
+	return writer.status
+}
+
+func (writer *bufferedResponseWriter) Size() int {
+	return writer.body.Len()
+}
+
+func (writer *bufferedResponseWriter) Written() bool {
+	return writer.status != 0 || writer.body.Len() > 0
+}
+
+func (writer *bufferedResponseWriter) flush() {
+	if writer.status != 0 {
+		writer.ResponseWriter.WriteHeader(writer.status)
+	}
+	if writer.body.Len() > 0 {
+		writer.ResponseWriter.Write(writer.body.Bytes())
+	}
+}
+
+// ActivityStore returns the store of the last activity of the sessions
+func (autheticationMiddleware *AutheticationMiddleware) ActivityStore() session.ActivityStorer {
+	return autheticationMiddleware.activityStore
+}
+
+// UseActivityStore tracks the activity of the sessions in the given store
+func (autheticationMiddleware *AutheticationMiddleware) UseActivityStore(store session.ActivityStorer) {
+	autheticationMiddleware.activityStore = store
+}
+
+func (autheticationMiddleware *AutheticationMiddleware) idleTrackingEnabled() bool {
+	return autheticationMiddleware.idleTimeout > 0 && autheticationMiddleware.activityStore != nil
+}
+
+func (autheticationMiddleware *AutheticationMiddleware) sessionLimitEnabled() bool {
+	return autheticationMiddleware.maxSessions > 0 && autheticationMiddleware.sessionRegistry != nil
+}
+
+func (autheticationMiddleware *AutheticationMiddleware) rotationEnabled() bool {
+	return autheticationMiddleware.rotateRefreshTokens && autheticationMiddleware.sessionRegistry != nil
+}
+
+// TrackSession registers the session issued by a successful login or refresh before the response is sent
+func (autheticationMiddleware *AutheticationMiddleware) TrackSession(ctx *gin.Context) {
+	if !autheticationMiddleware.idleTrackingEnabled() &&
+		!autheticationMiddleware.sessionLimitEnabled() &&
+		!autheticationMiddleware.rotationEnabled() {
+		ctx.Next()
+		return
+	}
+
+	writer := &bufferedResponseWriter{ResponseWriter: ctx.Writer}
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = writer.ResponseWriter
+
+	if autheticationMiddleware.registerSession(ctx) {
+		writer.flush()
+	}
+}
+
+func (autheticationMiddleware *AutheticationMiddleware) registerSession(ctx *gin.Context) bool {
+	issuedToken := ctx.GetString(routes.IssuedAuthTokenKey)
+	if issuedToken == "" {
+		return true
+	}
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		return true
+	}
+	claims, err := autheticationMiddleware.jwtTokenInspector.GetClaimsFromTokenString(issuedToken)
+	if err != nil {
+		logger.Error(err, "Could not obtain claims from issued token")
+		return true
+	}
+	now := time.Now()
+
+	if autheticationMiddleware.sessionLimitEnabled() &&
+		!autheticationMiddleware.enforceSessionLimit(ctx, logger, claims.UserID, now) {
+		return false
+	}
+
+	if autheticationMiddleware.rotationEnabled() {
+		autheticationMiddleware.revokeRefreshedToken(ctx, logger, claims.UserID)
+	}
+
+	if autheticationMiddleware.idleTrackingEnabled() {
+		autheticationMiddleware.recordSessionStart(ctx, logger, issuedToken, claims, now)
+	}
+	return true
+}
+
+// recordSessionStart links the issued access token to its session, identified by the issued refresh token as in the
+// session registry. A refresh carries the last activity of the refreshed session over so it does not reset the idle time
+func (autheticationMiddleware *AutheticationMiddleware) recordSessionStart(
+	ctx *gin.Context,
+	logger commonLogger.Loggerer,
+	issuedToken string,
+	claims *commonJWT.TokenClaims,
+	now time.Time,
+) {
+	requestContext := ctx.Request.Context()
+	store := autheticationMiddleware.activityStore
+	sessionID := session.IDFromToken(issuedToken)
+	lastSeen := now
+	if previousToken, exists := ctx.Get(string(commonJWT.JWTTokenKey)); exists {
+		if previous, ok := previousToken.(*jwt.Token); ok && previous.Raw != "" {
+			sessionID = session.IDFromToken(previous.Raw)
+			previousLastSeen, err := store.LastSeen(requestContext, sessionID)
+			if err != nil {
+				logger.Error(err, "Could not obtain the activity of the refreshed session")
+			} else if previousLastSeen != nil {
+				lastSeen = *previousLastSeen
+			}
+		}
+	}
+	if refreshToken := ctx.GetString(routes.IssuedRefreshTokenKey); refreshToken != "" {
+		sessionID = session.IDFromToken(refreshToken)
+	}
+	if err := store.Touch(requestContext, sessionID, lastSeen, autheticationMiddleware.activityRetention); err != nil {
+		logger.Error(err, "Could not record session start")
+	}
+	linkTTL := time.Until(claims.Expiry)
+	if linkTTL <= 0 {
+		linkTTL = autheticationMiddleware.activityRetention
+	}
+	if err := store.Link(requestContext, session.IDFromToken(issuedToken), sessionID, linkTTL); err != nil {
+		logger.Error(err, "Could not link the issued token to its session")
+	}
+}
+
+// enforceSessionLimit registers the issued session, rejecting it or revoking the oldest ones when the user has too many
+func (autheticationMiddleware *AutheticationMiddleware) enforceSessionLimit(
+	ctx *gin.Context,
+	logger commonLogger.Loggerer,
+	userID string,
+	now time.Time,
+) bool {
+	refreshToken := ctx.GetString(routes.IssuedRefreshTokenKey)
+	if refreshToken == "" {
+		return true
+	}
+	refreshClaims, err := autheticationMiddleware.jwtTokenInspector.GetClaimsFromTokenString(refreshToken)
+	if err != nil {
+		logger.Error(err, "Could not obtain claims from issued refresh token")
+		return true
+	}
+	requestContext := ctx.Request.Context()
+	registry := autheticationMiddleware.sessionRegistry
+	entry := session.Entry{
+		ID:     session.IDFromToken(refreshToken),
+		Expiry: refreshClaims.Expiry,
+	}
+
+	if previousToken, exists := ctx.Get(string(commonJWT.JWTTokenKey)); exists {
+		if previous, ok := previousToken.(*jwt.Token); ok && previous.Raw != "" {
+			if err := registry.Remove(requestContext, userID, session.IDFromToken(previous.Raw)); err != nil {
+				logger.Error(err, "Could not unregister refreshed session")
+			}
+		}
+		if err := registry.Add(requestContext, userID, entry); err != nil {
+			logger.Error(err, "Could not register refreshed session")
+		}
+		return true
+	}
+
+	active, err := registry.Active(requestContext, userID, now)
+	if err != nil {
+		logger.Error(err, "Could not list the active sessions of the user")
+		return true
+	}
+	if len(active) >= autheticationMiddleware.maxSessions {
+		if autheticationMiddleware.sessionLimitPolicy == SessionLimitRejectNewest {
+			logger.Error(nil, "The user reached the maximum number of concurrent sessions")
+			errors.Abort(ctx, errors.ConcurrentSessionLimit)
+			return false
+		}
+		for _, oldest := range active[:len(active)-autheticationMiddleware.maxSessions+1] {
+			if err := registry.Revoke(requestContext, userID, oldest); err != nil {
+				logger.Error(err, "Could not revoke the oldest session of the user")
+				continue
+			}
+			logger.Info("Revoked the oldest session of the user to honour the concurrent session limit")
+		}
+	}
+	if err := registry.Add(requestContext, userID, entry); err != nil {
+		logger.Error(err, "Could not register session")
+	}
+	return true
+}
+
+// revokeRefreshedToken revokes the refresh token a refresh was authorized with once the service rotated it,
+// so a replay of the old token is rejected until it would have expired
+func (autheticationMiddleware *AutheticationMiddleware) revokeRefreshedToken(
+	ctx *gin.Context,
+	logger commonLogger.Loggerer,
+	userID string,
+) {
+	issuedRefreshToken := ctx.GetString(routes.IssuedRefreshTokenKey)
+	if issuedRefreshToken == "" {
+		return
+	}
+	previousToken, tokenExists := ctx.Get(string(commonJWT.JWTTokenKey))
+	previousClaims, claimsExist := ctx.Get(string(commonJWT.ClaimsContextKey))
+	if !tokenExists || !claimsExist {
+		return
+	}
+	previous, ok := previousToken.(*jwt.Token)
+	if !ok || previous.Raw == "" || previous.Raw == issuedRefreshToken {
+		return
+	}
+	claims, ok := previousClaims.(*commonJWT.TokenClaims)
+	if !ok {
+		return
+	}
+	entry := session.Entry{ID: session.IDFromToken(previous.Raw), Expiry: claims.Expiry}
+	if err := autheticationMiddleware.sessionRegistry.Revoke(ctx.Request.Context(), userID, entry); err != nil {
+		logger.Error(err, "Could not revoke the rotated refresh token")
+	}
+}
+
+// checkSessionRevocation rejects refresh tokens of sessions revoked by the concurrent session limit
+// and the refresh tokens already rotated
+func (autheticationMiddleware *AutheticationMiddleware) checkSessionRevocation(
+	ctx *gin.Context,
+	logger commonLogger.Loggerer,
+	refreshToken string,
+) bool {
+	if !autheticationMiddleware.sessionLimitEnabled() && !autheticationMiddleware.rotationEnabled() {
+		return true
+	}
+	revoked, err := autheticationMiddleware.sessionRegistry.IsRevoked(ctx.Request.Context(), session.IDFromToken(refreshToken))
+	if err != nil {
+		logger.Error(err, "Could not check session revocation")
+		return true
+	}
+	if revoked {
+		logger.Error(nil, "The session has been revoked")
+		errors.Abort(ctx, errors.SessionRevoked)
+		return false
+	}
+	return true
+}
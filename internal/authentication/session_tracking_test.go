@@ -0,0 +1,217 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+)
+
+func performLogin(logger commonLogger.Loggerer, authenticationMiddleware *AutheticationMiddleware) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(
+		"/sessions",
+		func(ctx *gin.Context) {
+			newCtx := context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, logger)
+			ctx.Request = ctx.Request.WithContext(newCtx)
+		},
+		authenticationMiddleware.TrackSession,
+		func(ctx *gin.Context) {
+			ctx.Set(routes.IssuedAuthTokenKey, "auth-token")
+			ctx.Set(routes.IssuedRefreshTokenKey, "refresh-token")
+			ctx.JSON(http.StatusOK, gin.H{"authToken": "auth-token", "refreshToken": "refresh-token"})
+		},
+	)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sessions", nil))
+	return w
+}
+
+func performRefresh(logger commonLogger.Loggerer, authenticationMiddleware *AutheticationMiddleware, refreshExpiry time.Time) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(
+		"/refresh",
+		func(ctx *gin.Context) {
+			newCtx := context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, logger)
+			ctx.Request = ctx.Request.WithContext(newCtx)
+			ctx.Set(string(commmonJWT.JWTTokenKey), &jwt.Token{Raw: "old-refresh-token"})
+			ctx.Set(string(commmonJWT.ClaimsContextKey), &commmonJWT.TokenClaims{Expiry: refreshExpiry})
+		},
+		authenticationMiddleware.TrackSession,
+		func(ctx *gin.Context) {
+			ctx.Set(routes.IssuedAuthTokenKey, "auth-token")
+			ctx.Set(routes.IssuedRefreshTokenKey, "refresh-token")
+			ctx.JSON(http.StatusOK, gin.H{"authToken": "auth-token", "refreshToken": "refresh-token"})
+		},
+	)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refresh", nil))
+	return w
+}
+
+func TestSessionTracking(t *testing.T) {
+	userID := "test-user-id"
+	refreshExpiry := time.Now().Add(24 * time.Hour)
+
+	t.Run("TrackSession_Registers_Session_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		registry := session.NewMemoryRegistry()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtTokenInspector: jwtTokenInspectorMock,
+			sessionRegistry:   registry,
+			maxSessions:       2,
+		}
+
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("auth-token").Return(&commmonJWT.TokenClaims{UserID: userID}, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("refresh-token").Return(&commmonJWT.TokenClaims{UserID: userID, Expiry: refreshExpiry}, nil)
+
+		w := performLogin(loggerMock, authenticationMiddleware)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "auth-token")
+		active, err := registry.Active(context.Background(), userID, time.Now())
+		assert.NoError(t, err)
+		assert.Len(t, active, 1)
+		assert.Equal(t, session.IDFromToken("refresh-token"), active[0].ID)
+	})
+
+	t.Run("TrackSession_Reject_Newest_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		registry := session.NewMemoryRegistry()
+		registry.Add(context.Background(), userID, session.Entry{ID: "existing-session", Expiry: refreshExpiry})
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtTokenInspector:  jwtTokenInspectorMock,
+			sessionRegistry:    registry,
+			maxSessions:        1,
+			sessionLimitPolicy: SessionLimitRejectNewest,
+		}
+
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("auth-token").Return(&commmonJWT.TokenClaims{UserID: userID}, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("refresh-token").Return(&commmonJWT.TokenClaims{UserID: userID, Expiry: refreshExpiry}, nil)
+		loggerMock.EXPECT().Error(nil, "The user reached the maximum number of concurrent sessions")
+
+		w := performLogin(loggerMock, authenticationMiddleware)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "concurrent_session_limit")
+		assert.NotContains(t, w.Body.String(), "auth-token")
+	})
+
+	t.Run("TrackSession_Revoke_Oldest_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		registry := session.NewMemoryRegistry()
+		registry.Add(context.Background(), userID, session.Entry{ID: "oldest-session", Expiry: time.Now().Add(time.Hour)})
+		registry.Add(context.Background(), userID, session.Entry{ID: "newer-session", Expiry: time.Now().Add(2 * time.Hour)})
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtTokenInspector:  jwtTokenInspectorMock,
+			sessionRegistry:    registry,
+			maxSessions:        2,
+			sessionLimitPolicy: SessionLimitRevokeOldest,
+		}
+
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("auth-token").Return(&commmonJWT.TokenClaims{UserID: userID}, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("refresh-token").Return(&commmonJWT.TokenClaims{UserID: userID, Expiry: refreshExpiry}, nil)
+		loggerMock.EXPECT().Info("Revoked the oldest session of the user to honour the concurrent session limit")
+
+		w := performLogin(loggerMock, authenticationMiddleware)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		revoked, err := registry.IsRevoked(context.Background(), "oldest-session")
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		active, err := registry.Active(context.Background(), userID, time.Now())
+		assert.NoError(t, err)
+		assert.Len(t, active, 2)
+	})
+
+	t.Run("TrackSession_Revokes_Rotated_Refresh_Token_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		registry := session.NewMemoryRegistry()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtTokenInspector:   jwtTokenInspectorMock,
+			sessionRegistry:     registry,
+			rotateRefreshTokens: true,
+		}
+
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromTokenString("auth-token").Return(&commmonJWT.TokenClaims{UserID: userID}, nil)
+
+		w := performRefresh(loggerMock, authenticationMiddleware, refreshExpiry)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "refresh-token")
+		revoked, err := registry.IsRevoked(context.Background(), session.IDFromToken("old-refresh-token"))
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		revoked, err = registry.IsRevoked(context.Background(), session.IDFromToken("refresh-token"))
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("CheckSessionRevocation_Rotated_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		registry := session.NewMemoryRegistry()
+		registry.Revoke(context.Background(), userID, session.Entry{ID: session.IDFromToken("refresh-token"), Expiry: refreshExpiry})
+		authenticationMiddleware := &AutheticationMiddleware{
+			sessionRegistry:     registry,
+			rotateRefreshTokens: true,
+		}
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+
+		loggerMock.EXPECT().Error(nil, "The session has been revoked")
+
+		allowed := authenticationMiddleware.checkSessionRevocation(ctx, loggerMock, "refresh-token")
+
+		assert.False(t, allowed)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("CheckSessionRevocation_Revoked_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		registry := session.NewMemoryRegistry()
+		registry.Revoke(context.Background(), userID, session.Entry{ID: session.IDFromToken("refresh-token"), Expiry: refreshExpiry})
+		authenticationMiddleware := &AutheticationMiddleware{
+			sessionRegistry: registry,
+			maxSessions:     1,
+		}
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+
+		loggerMock.EXPECT().Error(nil, "The session has been revoked")
+
+		allowed := authenticationMiddleware.checkSessionRevocation(ctx, loggerMock, "refresh-token")
+
+		assert.False(t, allowed)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "session_revoked")
+	})
+}
@@ -0,0 +1,61 @@
+package authentication
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Token claims stating when the user last authenticated
+const (
+	AuthTimeClaim = "auth_time"
+	IssuedAtClaim = "iat"
+)
+
+// RequireStepUp returns a middleware rejecting tokens whose authentication is older than maxAge
+func (autheticationMiddleware *AutheticationMiddleware) RequireStepUp(maxAge time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+		if err != nil {
+			errors.AbortWithError(ctx, errors.InternalError, err)
+			return
+		}
+		tokenValue, _ := ctx.Get(string(commonJWT.JWTTokenKey))
+		token, ok := tokenValue.(*jwt.Token)
+		if !ok {
+			logger.Error(nil, "No authenticated token was found in the request")
+			errors.Abort(ctx, errors.Unauthenticated)
+			return
+		}
+
+		authenticatedAt := autheticationMiddleware.getAuthenticationTime(token)
+		if authenticatedAt == nil || time.Since(*authenticatedAt) > maxAge {
+			logger.Error(nil, "The user has to authenticate again to access the route")
+			ctx.Header(
+				"WWW-Authenticate",
+				fmt.Sprintf(`Bearer error="insufficient_user_authentication", max_age=%d`, int(maxAge.Seconds())),
+			)
+			errors.Abort(ctx, errors.StepUpRequired)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// getAuthenticationTime reads the authentication time claim falling back to the issue time
+func (autheticationMiddleware *AutheticationMiddleware) getAuthenticationTime(token *jwt.Token) *time.Time {
+	for _, claimKey := range []string{AuthTimeClaim, IssuedAtClaim} {
+		claim, err := autheticationMiddleware.jwtTokenInspector.GetClaimFromToken(token, claimKey)
+		if seconds, ok := claim.(float64); err == nil && ok {
+			authenticatedAt := time.Unix(int64(seconds), 0)
+			return &authenticatedAt
+		}
+	}
+	return nil
+}
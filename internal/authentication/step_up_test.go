@@ -0,0 +1,95 @@
+package authentication
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireStepUp(t *testing.T) {
+	maxAge := 5 * time.Minute
+
+	t.Run("RequireStepUp_Recent_Authentication_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, AuthTimeClaim).Return(float64(time.Now().Add(-time.Minute).Unix()), nil)
+
+		authenticationMiddleware.RequireStepUp(maxAge)(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RequireStepUp_Issued_At_Fallback_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, AuthTimeClaim).Return(nil, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, IssuedAtClaim).Return(float64(time.Now().Unix()), nil)
+
+		authenticationMiddleware.RequireStepUp(maxAge)(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RequireStepUp_Stale_Authentication_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, AuthTimeClaim).Return(float64(time.Now().Add(-time.Hour).Unix()), nil)
+		loggerMock.EXPECT().Error(nil, "The user has to authenticate again to access the route")
+
+		authenticationMiddleware.RequireStepUp(maxAge)(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.True(t, ctx.IsAborted())
+		assert.Contains(t, w.Body.String(), "step_up_required")
+		assert.Equal(t, `Bearer error="insufficient_user_authentication", max_age=300`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("RequireStepUp_No_Token_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+
+		loggerMock.EXPECT().Error(nil, "No authenticated token was found in the request")
+
+		authenticationMiddleware.RequireStepUp(maxAge)(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.True(t, ctx.IsAborted())
+	})
+}
@@ -0,0 +1,44 @@
+package authentication
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+)
+
+// TokenTenant reads the tenant claim of the token, empty when the token has none
+func TokenTenant(token *jwt.Token, claim string) string {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	tenantID, _ := claims[claim].(string)
+	return tenantID
+}
+
+// setTenant places the tenant of the token in the context and the outgoing metadata of the request, rejecting
+// the tokens without a tenant when it is required
+func (autheticationMiddleware *AutheticationMiddleware) setTenant(
+	ctx *gin.Context,
+	logger commonLogger.Loggerer,
+	token *jwt.Token,
+) bool {
+	if autheticationMiddleware.tenantClaim == "" {
+		return true
+	}
+	tenantID := TokenTenant(token, autheticationMiddleware.tenantClaim)
+	if tenantID == "" {
+		if !autheticationMiddleware.tenantRequired {
+			return true
+		}
+		logger.Error(nil, "The bearer token has no tenant")
+		errors.Abort(ctx, errors.TenantRequired)
+		return false
+	}
+	ctx.Set(tenancy.ContextKey, tenantID)
+	ctx.Request = ctx.Request.WithContext(tenancy.ContextWithTenant(ctx.Request.Context(), tenantID))
+	return true
+}
@@ -0,0 +1,82 @@
+package authentication
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+)
+
+func TestTenant(t *testing.T) {
+	tokenClaims := &commmonJWT.TokenClaims{
+		UserID: "user-id",
+		Type:   commonToken.AuthTokenType,
+		Expiry: time.Now().Add(time.Minute),
+	}
+
+	t.Run("RequireAuthentication_Tenant_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			tenantClaim:       tenancy.DefaultClaim,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header", Claims: jwt.MapClaims{tenancy.DefaultClaim: "acme"}}
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "acme", ctx.GetString(tenancy.ContextKey))
+		assert.Equal(t, "acme", tenancy.FromContext(ctx.Request.Context()))
+		outgoingMD, _ := metadata.FromOutgoingContext(ctx.Request.Context())
+		assert.Equal(t, []string{"acme"}, outgoingMD.Get(tenancy.MetadataKey))
+	})
+
+	t.Run("RequireAuthentication_Missing_Required_Tenant_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			tenantClaim:       tenancy.DefaultClaim,
+			tenantRequired:    true,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header", Claims: jwt.MapClaims{}}
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		loggerMock.EXPECT().Error(nil, "The bearer token has no tenant")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"tenant_required","message":"The access token does not belong to a tenant"}}`, w.Body.String())
+	})
+}
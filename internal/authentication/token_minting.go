@@ -0,0 +1,172 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// MintedTokenKeyID is the kid header of the minted tokens, verified with the staging key
+const MintedTokenKeyID = "staging"
+
+// AdminKeyHeader is the request header carrying the admin key of the token minting
+const AdminKeyHeader = "X-Admin-Key"
+
+const defaultMintedTokenTTL = 15 * time.Minute
+
+// MintTokenRequestBody is the request body of the MintToken route, the claims are added as they are and a
+// negative expires_in, in seconds, mints an already expired token
+type MintTokenRequestBody struct {
+	UserID    string                 `json:"user_id" binding:"required"`
+	Email     string                 `json:"email"`
+	Type      string                 `json:"type"`
+	ExpiresIn int64                  `json:"expires_in"`
+	Claims    map[string]interface{} `json:"claims"`
+}
+
+// TokenMinter mints tokens signed by the staging key so the end-to-end tests go through the real middleware
+type TokenMinter struct {
+	privateKey   *rsa.PrivateKey
+	verifier     commonJWT.TokenVerifierer
+	adminKeyHash string
+	defaultTTL   time.Duration
+	maxTTL       time.Duration
+}
+
+// NewTokenMinter loads the staging key of the keys directory, generating it when the directory holds none,
+// and refuses to mint tokens in production
+func NewTokenMinter(configurations *config.Config) (*TokenMinter, error) {
+	mintingConfig := configurations.TokenMinting
+	if configurations.Environment == commonConfig.ProductionEnvironment {
+		return nil, fmt.Errorf("Tokens can not be minted in production")
+	}
+	if mintingConfig.AdminKeyHash == "" || mintingConfig.KeysDirectory == "" {
+		return nil, fmt.Errorf("The token minting needs a keys directory and the hash of its admin key")
+	}
+	keyManager, err := commonJWT.NewKeyManager(mintingConfig.KeysDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load the staging key: %v", err)
+	}
+	publicKey, err := keyManager.GetPublicKey(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := commonJWT.NewTokenVerifier(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	minter := &TokenMinter{
+		privateKey:   keyManager.GetRSAPrivateKey(),
+		verifier:     verifier,
+		adminKeyHash: mintingConfig.AdminKeyHash,
+		defaultTTL:   mintingConfig.DefaultTTL,
+		maxTTL:       mintingConfig.MaxTTL,
+	}
+	if minter.defaultTTL <= 0 {
+		minter.defaultTTL = defaultMintedTokenTTL
+	}
+	return minter, nil
+}
+
+// Mint signs a token with the claims of the body, the user, email, type and expiry taking precedence over them
+func (minter *TokenMinter) Mint(body MintTokenRequestBody, now time.Time) (string, time.Time, error) {
+	ttl := time.Duration(body.ExpiresIn) * time.Second
+	if body.ExpiresIn == 0 {
+		ttl = minter.defaultTTL
+	}
+	if minter.maxTTL > 0 && ttl > minter.maxTTL {
+		return "", time.Time{}, fmt.Errorf("The token can not expire later than %s", minter.maxTTL)
+	}
+	tokenType := commonToken.AuthTokenType
+	if body.Type != "" {
+		tokenType = commonToken.Type(body.Type)
+	}
+	expiry := now.Add(ttl)
+	claims := jwt.MapClaims{TokenIDClaim: uuid.New().String()}
+	for name, value := range body.Claims {
+		claims[name] = value
+	}
+	claims[commonJWT.IssuedAtClaim] = now.Unix()
+	claims[commonJWT.UserIDClaim] = body.UserID
+	claims[commonJWT.EmailClaim] = body.Email
+	claims[commonJWT.TypeClaim] = string(tokenType)
+	claims[commonJWT.ExpiryClaim] = expiry.Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = MintedTokenKeyID
+	signedToken, err := token.SignedString(minter.privateKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signedToken, expiry, nil
+}
+
+// allowsAdminKey tells whether the key is the admin key of the token minting
+func (minter *TokenMinter) allowsAdminKey(key string) bool {
+	return key != "" && subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(minter.adminKeyHash)) == 1
+}
+
+// mintedTokenVerifier verifies the minted tokens with the staging key and the other tokens with the key
+// of the authentication service
+type mintedTokenVerifier struct {
+	verifier commonJWT.TokenVerifierer
+	minted   commonJWT.TokenVerifierer
+}
+
+var _ commonJWT.TokenVerifierer = &mintedTokenVerifier{}
+
+func (verifier *mintedTokenVerifier) Verify(tokenString string) (*jwt.Token, error) {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err == nil && token.Header["kid"] == MintedTokenKeyID {
+		return verifier.minted.Verify(tokenString)
+	}
+	return verifier.verifier.Verify(tokenString)
+}
+
+// MintToken mints a token signed by the staging key for the requests carrying the admin key
+func (autheticationMiddleware *AutheticationMiddleware) MintToken(ctx *gin.Context) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	if autheticationMiddleware.tokenMinter == nil {
+		errors.Abort(ctx, errors.RouteNotFound)
+		return
+	}
+	if !autheticationMiddleware.tokenMinter.allowsAdminKey(ctx.GetHeader(AdminKeyHeader)) {
+		logger.Error(nil, "The admin key of the token minting was invalid")
+		errors.Abort(ctx, errors.Unauthenticated)
+		return
+	}
+	body := MintTokenRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+		return
+	}
+	token, expiry, err := autheticationMiddleware.tokenMinter.Mint(body, time.Now())
+	if err != nil {
+		logger.Error(err, "Could not mint the token")
+		errors.Abort(ctx, errors.InvalidTokenMint)
+		return
+	}
+	logger.Info(fmt.Sprintf("Minted a staging token for the user %s", body.UserID))
+	ctx.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expiry.Unix(),
+	})
+}
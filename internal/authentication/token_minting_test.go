@@ -0,0 +1,118 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newTestTokenMinter(t *testing.T) *TokenMinter {
+	minter, err := NewTokenMinter(&config.Config{
+		Environment: commonConfig.DevelopmentEnvironment,
+		TokenMinting: config.TokenMintingConfig{
+			Enabled:       true,
+			KeysDirectory: t.TempDir(),
+			AdminKeyHash:  HashAPIKey("admin-key"),
+			MaxTTL:        time.Hour,
+		},
+	})
+	assert.NoError(t, err)
+	return minter
+}
+
+func TestTokenMinting(t *testing.T) {
+	minter := newTestTokenMinter(t)
+
+	t.Run("Verifier_Minted_Token_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtVerifier: jwtVerifierMock, tokenMinter: minter}
+
+		tokenString, _, err := minter.Mint(MintTokenRequestBody{
+			UserID: "user-id",
+			Email:  "test@test.com",
+			Claims: map[string]interface{}{RolesClaim: []string{"admin"}, "user_id": "other-id"},
+		}, time.Now())
+		assert.NoError(t, err)
+		jwtVerifierMock.EXPECT().Verify("service-token").Return(&jwt.Token{Raw: "service-token"}, nil)
+
+		token, err := authenticationMiddleware.verifier().Verify(tokenString)
+		serviceToken, serviceErr := authenticationMiddleware.verifier().Verify("service-token")
+
+		assert.NoError(t, err)
+		claims := token.Claims.(jwt.MapClaims)
+		assert.Equal(t, "user-id", claims["user_id"])
+		assert.Equal(t, []interface{}{"admin"}, claims[RolesClaim])
+		assert.NotEmpty(t, claims[TokenIDClaim])
+		assert.NoError(t, serviceErr)
+		assert.Equal(t, "service-token", serviceToken.Raw)
+	})
+
+	t.Run("Verifier_Expired_Minted_Token_Error", func(t *testing.T) {
+		authenticationMiddleware := &AutheticationMiddleware{tokenMinter: minter}
+
+		tokenString, expiry, err := minter.Mint(MintTokenRequestBody{UserID: "user-id", ExpiresIn: -60}, time.Now())
+		assert.NoError(t, err)
+
+		_, verifyErr := authenticationMiddleware.verifier().Verify(tokenString)
+
+		assert.True(t, expiry.Before(time.Now()))
+		assert.ErrorContains(t, verifyErr, "expired")
+	})
+
+	t.Run("Mint_Beyond_Max_TTL_Error", func(t *testing.T) {
+		_, _, err := minter.Mint(MintTokenRequestBody{UserID: "user-id", ExpiresIn: 7200}, time.Now())
+
+		assert.EqualError(t, err, "The token can not expire later than 1h0m0s")
+	})
+
+	t.Run("MintToken_Admin_Key", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		authenticationMiddleware := &AutheticationMiddleware{tokenMinter: minter}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Request.Header.Set(AdminKeyHeader, "wrong-key")
+		loggerMock.EXPECT().Error(nil, "The admin key of the token minting was invalid")
+
+		authenticationMiddleware.MintToken(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		ctx, w = createTestContext(http.MethodPost, "/staging/tokens", []byte(`{"user_id":"user-id","expires_in":60}`), nil)
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+		ctx.Request.Header.Set(AdminKeyHeader, "admin-key")
+		loggerMock.EXPECT().Info("Minted a staging token for the user user-id")
+
+		authenticationMiddleware.MintToken(ctx)
+
+		response := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, http.StatusOK, w.Code)
+		_, err := authenticationMiddleware.verifier().Verify(response["token"].(string))
+		assert.NoError(t, err)
+	})
+
+	t.Run("NewTokenMinter_Production_Error", func(t *testing.T) {
+		_, err := NewTokenMinter(&config.Config{
+			Environment:  commonConfig.ProductionEnvironment,
+			TokenMinting: config.TokenMintingConfig{Enabled: true, KeysDirectory: t.TempDir(), AdminKeyHash: HashAPIKey("admin-key")},
+		})
+
+		assert.EqualError(t, err, "Tokens can not be minted in production")
+	})
+}
@@ -0,0 +1,117 @@
+package authentication
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+)
+
+// TokenIDClaim is the claim identifying an access token in the revocation list
+const TokenIDClaim = "jti"
+
+// RevokeTokenRequestBody is the request body of the RevokeToken route, either the access token
+// or its jti with its expiry as a unix timestamp
+type RevokeTokenRequestBody struct {
+	Token     string `json:"token"`
+	TokenID   string `json:"jti"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// tokenID returns the jti of the token, or the hash of the token when it has none
+func (autheticationMiddleware *AutheticationMiddleware) tokenID(token *jwt.Token) string {
+	claim, err := autheticationMiddleware.jwtTokenInspector.GetClaimFromToken(token, TokenIDClaim)
+	if tokenID, ok := claim.(string); err == nil && ok && tokenID != "" {
+		return tokenID
+	}
+	return session.IDFromToken(token.Raw)
+}
+
+// checkTokenRevocation rejects the access tokens listed as revoked, and those checked when the list can not be read
+// unless the revocation check fails open
+func (autheticationMiddleware *AutheticationMiddleware) checkTokenRevocation(
+	ctx *gin.Context,
+	logger commonLogger.Loggerer,
+	token *jwt.Token,
+) bool {
+	if autheticationMiddleware.tokenRevocations == nil {
+		return true
+	}
+	revoked, err := autheticationMiddleware.tokenRevocations.IsRevoked(ctx.Request.Context(), autheticationMiddleware.tokenID(token))
+	if err != nil {
+		logger.Error(err, "Could not check token revocation")
+		if autheticationMiddleware.revocationFailOpen {
+			return true
+		}
+		errors.Abort(ctx, errors.ServiceUnavailable)
+		return false
+	}
+	if revoked {
+		logger.Error(nil, "The bearer token has been revoked")
+		errors.Abort(ctx, errors.TokenRevoked)
+		return false
+	}
+	return true
+}
+
+// RevokeToken lists an access token as revoked until it expires, so it is rejected before reaching the services
+func (autheticationMiddleware *AutheticationMiddleware) RevokeToken(ctx *gin.Context) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	if autheticationMiddleware.tokenRevocations == nil {
+		errors.Abort(ctx, errors.RouteNotFound)
+		return
+	}
+	body := RevokeTokenRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+		return
+	}
+	if body.Token == "" && (body.TokenID == "" || body.ExpiresAt == 0) {
+		errors.Abort(ctx, errors.InvalidTokenRevocation)
+		return
+	}
+
+	tokenID, expiry := body.TokenID, time.Unix(body.ExpiresAt, 0)
+	if body.Token != "" {
+		token, err := autheticationMiddleware.verifier().Verify(body.Token)
+		if err != nil {
+			logger.Error(err, "The token to revoke was invalid")
+			errors.Abort(ctx, errors.InvalidTokenRevocation)
+			return
+		}
+		claims, err := autheticationMiddleware.jwtTokenInspector.GetClaimsFromToken(token)
+		if err != nil {
+			logger.Error(err, "Could not obtain claims from the token to revoke")
+			errors.Abort(ctx, errors.InvalidTokenRevocation)
+			return
+		}
+		tokenID, expiry = autheticationMiddleware.tokenID(token), claims.Expiry
+	}
+
+	if err := autheticationMiddleware.tokenRevocations.Revoke(ctx.Request.Context(), tokenID, expiry); err != nil {
+		logger.Error(err, "Could not revoke the token")
+		errors.Abort(ctx, errors.InternalError)
+		return
+	}
+	logger.Info("Revoked the token")
+	ctx.JSON(http.StatusOK, gin.H{
+		"jti":        tokenID,
+		"expires_at": expiry.Unix(),
+	})
+}
+
+// TokenRevocationJob prunes the token revocation list and keeps its subscription to the revocations running,
+// used when the token revocation is enabled
+func (autheticationMiddleware *AutheticationMiddleware) TokenRevocationJob() scheduler.Job {
+	return autheticationMiddleware.tokenRevocations.Job()
+}
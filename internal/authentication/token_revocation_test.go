@@ -0,0 +1,209 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+)
+
+func TestTokenRevocation(t *testing.T) {
+	t.Run("RequireAuthentication_Revoked_Token_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		tokenRevocations := session.NewMemoryTokenRevocations()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			tokenRevocations:  tokenRevocations,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			UserID: "user-id",
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(time.Minute),
+		}
+		assert.NoError(t, tokenRevocations.Revoke(context.Background(), "token-id", tokenClaims.Expiry))
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, TokenIDClaim).Return("token-id", nil)
+		loggerMock.EXPECT().Error(nil, "The bearer token has been revoked")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"token_revoked","message":"The access token has been revoked"}}`, w.Body.String())
+	})
+
+	t.Run("RequireAuthentication_Token_Without_Jti_Not_Revoked_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		tokenRevocations := session.NewMemoryTokenRevocations()
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:       jwtVerifierMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			tokenRevocations:  tokenRevocations,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			UserID: "user-id",
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(time.Minute),
+		}
+		assert.NoError(t, tokenRevocations.Revoke(context.Background(), "token-id", tokenClaims.Expiry))
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, TokenIDClaim).Return(nil, nil)
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RequireAuthentication_Revocation_Store_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		tokenRevocations, err := session.NewTokenRevocations(&config.Config{
+			Redis:           config.RedisConfig{Address: "127.0.0.1:1", Timeout: 100 * time.Millisecond},
+			TokenRevocation: config.TokenRevocationConfig{Enabled: true, Store: session.TokenRevocationStoreRedis},
+		})
+		assert.NoError(t, err)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:        jwtVerifierMock,
+			jwtTokenInspector:  jwtTokenInspectorMock,
+			tokenRevocations:   tokenRevocations,
+			revocationFailOpen: false,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			UserID: "user-id",
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(time.Minute),
+		}
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, TokenIDClaim).Return("token-id", nil)
+		loggerMock.EXPECT().Error(gomock.Any(), "Could not check token revocation")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"service_unavailable","message":"The service is unavailable, retry later"}}`, w.Body.String())
+	})
+
+	t.Run("RequireAuthentication_Revocation_Store_Error_Fail_Open_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtVerifierMock := commonJWTMock.NewMockTokenVerifierer(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		tokenRevocations, err := session.NewTokenRevocations(&config.Config{
+			Redis:           config.RedisConfig{Address: "127.0.0.1:1", Timeout: 100 * time.Millisecond},
+			TokenRevocation: config.TokenRevocationConfig{Enabled: true, Store: session.TokenRevocationStoreRedis},
+		})
+		assert.NoError(t, err)
+		authenticationMiddleware := &AutheticationMiddleware{
+			jwtVerifier:        jwtVerifierMock,
+			jwtTokenInspector:  jwtTokenInspectorMock,
+			tokenRevocations:   tokenRevocations,
+			revocationFailOpen: true,
+		}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		authHeader := "Bearer test-header"
+		testToken := &jwt.Token{Raw: "test-header"}
+		tokenClaims := &commmonJWT.TokenClaims{
+			UserID: "user-id",
+			Type:   commonToken.AuthTokenType,
+			Expiry: time.Now().Add(time.Minute),
+		}
+
+		ctx, w := createTestContextWithLogger(loggerMock, &authHeader)
+
+		jwtVerifierMock.EXPECT().Verify("test-header").Return(testToken, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimsFromToken(testToken).Return(tokenClaims, nil)
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, TokenIDClaim).Return("token-id", nil)
+		loggerMock.EXPECT().Error(gomock.Any(), "Could not check token revocation")
+		loggerMock.EXPECT().Info("Successfully authenticated user")
+
+		authenticationMiddleware.RequireAuthentication(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RevokeToken_Jti_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		tokenRevocations := session.NewMemoryTokenRevocations()
+		authenticationMiddleware := &AutheticationMiddleware{tokenRevocations: tokenRevocations}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		expiresAt := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+
+		ctx, w := createTestContext(http.MethodPost, "/admin/tokens/revocations", []byte(`{"jti":"token-id","expires_at":`+expiresAt+`}`), nil)
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+
+		loggerMock.EXPECT().Info("Revoked the token")
+
+		authenticationMiddleware.RevokeToken(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"jti":"token-id","expires_at":`+expiresAt+`}`, w.Body.String())
+		revoked, err := tokenRevocations.IsRevoked(context.Background(), "token-id")
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("RevokeToken_Jti_Without_Expiry_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		authenticationMiddleware := &AutheticationMiddleware{tokenRevocations: session.NewMemoryTokenRevocations()}
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+
+		ctx, w := createTestContext(http.MethodPost, "/admin/tokens/revocations", []byte(`{"jti":"token-id"}`), nil)
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+
+		authenticationMiddleware.RevokeToken(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"invalid_token_revocation","message":"The token revocation is invalid"}}`, w.Body.String())
+	})
+}
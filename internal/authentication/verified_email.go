@@ -0,0 +1,112 @@
+package authentication
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// EmailVerifiedClaim is the token claim stating whether the user verified the email
+const EmailVerifiedClaim = "email_verified"
+
+// verificationCache remembers the users whose email was verified by a backend lookup
+type verificationCache struct {
+	verifiedAt map[string]time.Time
+	ttl        time.Duration
+	mtx        sync.Mutex
+}
+
+func newVerificationCache(ttl time.Duration) *verificationCache {
+	return &verificationCache{
+		verifiedAt: make(map[string]time.Time),
+		ttl:        ttl,
+	}
+}
+
+func (cache *verificationCache) isVerified(userID string, now time.Time) bool {
+	if cache == nil {
+		return false
+	}
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	verifiedAt, exists := cache.verifiedAt[userID]
+	if !exists {
+		return false
+	}
+	if now.Sub(verifiedAt) > cache.ttl {
+		delete(cache.verifiedAt, userID)
+		return false
+	}
+	return true
+}
+
+func (cache *verificationCache) markVerified(userID string, now time.Time) {
+	if cache == nil {
+		return
+	}
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	cache.verifiedAt[userID] = now
+}
+
+// RequireVerifiedEmail blocks authenticated users whose email has not been verified yet
+func (autheticationMiddleware *AutheticationMiddleware) RequireVerifiedEmail(ctx *gin.Context) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey))
+	claims, ok := claimsValue.(*commonJWT.TokenClaims)
+	if !exists || !ok {
+		logger.Error(nil, "No authenticated user claims were found in the request")
+		errors.Abort(ctx, errors.Unauthenticated)
+		return
+	}
+
+	verified, err := autheticationMiddleware.isEmailVerified(ctx, claims.UserID)
+	if err != nil {
+		logger.Error(err, "Could not obtain the email verification status")
+		errors.HandleError(ctx, err)
+		return
+	}
+	if !verified {
+		logger.Error(nil, "The user email has not been verified")
+		errors.Abort(ctx, errors.EmailNotVerified)
+		return
+	}
+	ctx.Next()
+}
+
+// isEmailVerified checks the verified email claim and falls back to a cached backend lookup
+func (autheticationMiddleware *AutheticationMiddleware) isEmailVerified(ctx *gin.Context, userID string) (bool, error) {
+	if tokenValue, exists := ctx.Get(string(commonJWT.JWTTokenKey)); exists {
+		if token, ok := tokenValue.(*jwt.Token); ok {
+			claim, err := autheticationMiddleware.jwtTokenInspector.GetClaimFromToken(token, EmailVerifiedClaim)
+			if verified, isBool := claim.(bool); err == nil && isBool {
+				return verified, nil
+			}
+		}
+	}
+
+	now := time.Now()
+	if autheticationMiddleware.verifiedEmails.isVerified(userID, now) {
+		return true, nil
+	}
+	user, err := autheticationMiddleware.service.GetUser(ctx.Request.Context())
+	if err != nil {
+		return false, err
+	}
+	if user.GetAccountStatus() != pb_authentication.AccountStatus_VERIFIED.String() {
+		return false, nil
+	}
+	autheticationMiddleware.verifiedEmails.markVerified(userID, now)
+	return true, nil
+}
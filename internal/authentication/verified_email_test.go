@@ -0,0 +1,106 @@
+package authentication
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	commmonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/mock"
+)
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	claims := &commmonJWT.TokenClaims{UserID: "test-user-id"}
+
+	t.Run("RequireVerifiedEmail_Claim_Verified_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.ClaimsContextKey), claims)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, EmailVerifiedClaim).Return(true, nil)
+
+		authenticationMiddleware.RequireVerifiedEmail(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, ctx.IsAborted())
+	})
+
+	t.Run("RequireVerifiedEmail_Claim_Unverified_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{jwtTokenInspector: jwtTokenInspectorMock}
+		testToken := &jwt.Token{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+		ctx.Set(string(commmonJWT.ClaimsContextKey), claims)
+		ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, EmailVerifiedClaim).Return(false, nil)
+		loggerMock.EXPECT().Error(nil, "The user email has not been verified")
+
+		authenticationMiddleware.RequireVerifiedEmail(ctx)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "email_not_verified")
+	})
+
+	t.Run("RequireVerifiedEmail_Backend_Lookup_Cached_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		serviceMock := mock.NewMockServiceClienter(controller)
+		jwtTokenInspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{
+			service:           serviceMock,
+			jwtTokenInspector: jwtTokenInspectorMock,
+			verifiedEmails:    newVerificationCache(time.Minute),
+		}
+		testToken := &jwt.Token{}
+		user := &pb_authentication.User{AccountStatus: "VERIFIED"}
+
+		jwtTokenInspectorMock.EXPECT().GetClaimFromToken(testToken, EmailVerifiedClaim).Return(nil, nil).Times(2)
+		serviceMock.EXPECT().GetUser(gomock.Any()).Return(user, nil).Times(1)
+
+		for attempt := 0; attempt < 2; attempt++ {
+			ctx, w := createTestContextWithLogger(loggerMock, nil)
+			ctx.Set(string(commmonJWT.ClaimsContextKey), claims)
+			ctx.Set(string(commmonJWT.JWTTokenKey), testToken)
+
+			authenticationMiddleware.RequireVerifiedEmail(ctx)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.False(t, ctx.IsAborted())
+		}
+	})
+
+	t.Run("RequireVerifiedEmail_No_Claims_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		authenticationMiddleware := &AutheticationMiddleware{}
+
+		ctx, w := createTestContextWithLogger(loggerMock, nil)
+
+		loggerMock.EXPECT().Error(nil, "No authenticated user claims were found in the request")
+
+		authenticationMiddleware.RequireVerifiedEmail(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
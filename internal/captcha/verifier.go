@@ -0,0 +1,213 @@
+package captcha
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Headers carrying the captcha token and the key of trusted clients
+const (
+	TokenHeader     = "X-Captcha-Token"
+	ClientKeyHeader = "X-Client-Key"
+)
+
+// Supported captcha providers
+const (
+	ProviderRecaptcha = "recaptcha"
+	ProviderHCaptcha  = "hcaptcha"
+	ProviderTurnstile = "turnstile"
+)
+
+var verifyURLs = map[string]string{
+	ProviderRecaptcha: "https://www.google.com/recaptcha/api/siteverify",
+	ProviderHCaptcha:  "https://api.hcaptcha.com/siteverify",
+	ProviderTurnstile: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+// Result is the outcome of a token verification
+type Result struct {
+	Success bool
+	// Score goes from 0 for bots to 1 for humans, nil when the provider does not score
+	Score      *float64
+	ErrorCodes []string
+}
+
+// Verifierer verifies captcha tokens against a provider
+type Verifierer interface {
+	Verify(ctx context.Context, token, remoteIP string) (*Result, error)
+}
+
+// Verifier verifies captcha tokens with the siteverify API of the configured provider
+type Verifier struct {
+	provider  string
+	verifyURL string
+	secret    string
+	siteKey   string
+	client    *http.Client
+}
+
+var _ Verifierer = &Verifier{}
+
+// NewVerifier creates a verifier for the configured provider
+func NewVerifier(configurations *config.Config, client *http.Client) (*Verifier, error) {
+	provider := strings.ToLower(configurations.Captcha.Provider)
+	verifyURL, supported := verifyURLs[provider]
+	if !supported {
+		return nil, fmt.Errorf("Unsupported captcha provider: %s", configurations.Captcha.Provider)
+	}
+	if configurations.Captcha.VerifyURL != "" {
+		verifyURL = configurations.Captcha.VerifyURL
+	}
+	return &Verifier{
+		provider:  provider,
+		verifyURL: verifyURL,
+		secret:    configurations.Captcha.Secret,
+		siteKey:   configurations.Captcha.SiteKey,
+		client:    client,
+	}, nil
+}
+
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	Score      *float64 `json:"score"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify posts the token to the provider and normalizes its score
+func (verifier *Verifier) Verify(ctx context.Context, token, remoteIP string) (*Result, error) {
+	form := url.Values{}
+	form.Set("secret", verifier.secret)
+	form.Set("response", token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	if verifier.provider == ProviderHCaptcha && verifier.siteKey != "" {
+		form.Set("sitekey", verifier.siteKey)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, verifier.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("Could not create captcha verification request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := verifier.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Could not verify captcha token: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Captcha provider responded with status %d", response.StatusCode)
+	}
+
+	decoded := siteVerifyResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("Could not decode captcha verification response: %v", err)
+	}
+	result := &Result{Success: decoded.Success, ErrorCodes: decoded.ErrorCodes}
+	if decoded.Score != nil {
+		score := *decoded.Score
+		// hCaptcha scores the risk of the request rather than how human it looks
+		if verifier.provider == ProviderHCaptcha {
+			score = 1 - score
+		}
+		result.Score = &score
+	}
+	return result, nil
+}
+
+// Middleware checks the captcha token of requests coming from untrusted clients
+type Middleware struct {
+	verifier      Verifierer
+	minimumScore  float64
+	trustedKeys   []string
+	trustedRanges []*net.IPNet
+	timeout       time.Duration
+}
+
+// NewMiddleware creates the captcha middleware with the trusted clients of the configuration
+func NewMiddleware(verifier Verifierer, configurations *config.Config) (*Middleware, error) {
+	trustedRanges := []*net.IPNet{}
+	for _, cidr := range configurations.Captcha.TrustedCIDRs {
+		_, trustedRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted captcha CIDR %s: %v", cidr, err)
+		}
+		trustedRanges = append(trustedRanges, trustedRange)
+	}
+	return &Middleware{
+		verifier:      verifier,
+		minimumScore:  configurations.Captcha.MinimumScore,
+		trustedKeys:   configurations.Captcha.TrustedClientKeys,
+		trustedRanges: trustedRanges,
+		timeout:       configurations.Captcha.Timeout,
+	}, nil
+}
+
+// Check verifies the captcha token of the request, aborting it when missing or failed
+func (middleware *Middleware) Check(ctx *gin.Context) bool {
+	if middleware.isTrusted(ctx) {
+		return true
+	}
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return false
+	}
+	token := ctx.GetHeader(TokenHeader)
+	if token == "" {
+		logger.Error(nil, "No captcha token was present in the request")
+		errors.Abort(ctx, errors.CaptchaRequired)
+		return false
+	}
+
+	verifyContext := ctx.Request.Context()
+	if middleware.timeout > 0 {
+		var cancel context.CancelFunc
+		verifyContext, cancel = context.WithTimeout(verifyContext, middleware.timeout)
+		defer cancel()
+	}
+	result, err := middleware.verifier.Verify(verifyContext, token, ctx.ClientIP())
+	if err != nil {
+		logger.Error(err, "Could not verify the captcha token")
+		errors.Abort(ctx, errors.ServiceUnavailable)
+		return false
+	}
+	if !result.Success || (result.Score != nil && *result.Score < middleware.minimumScore) {
+		logger.Error(nil, fmt.Sprintf("The captcha verification failed: %v", result.ErrorCodes))
+		errors.Abort(ctx, errors.CaptchaFailed)
+		return false
+	}
+	return true
+}
+
+func (middleware *Middleware) isTrusted(ctx *gin.Context) bool {
+	if clientKey := ctx.GetHeader(ClientKeyHeader); clientKey != "" {
+		for _, trustedKey := range middleware.trustedKeys {
+			if subtle.ConstantTimeCompare([]byte(clientKey), []byte(trustedKey)) == 1 {
+				return true
+			}
+		}
+	}
+	clientIP := net.ParseIP(ctx.ClientIP())
+	if clientIP == nil {
+		return false
+	}
+	for _, trustedRange := range middleware.trustedRanges {
+		if trustedRange.Contains(clientIP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package captcha
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type stubVerifier struct {
+	result *Result
+	err    error
+	calls  int
+}
+
+func (verifier *stubVerifier) Verify(ctx context.Context, token, remoteIP string) (*Result, error) {
+	verifier.calls++
+	return verifier.result, verifier.err
+}
+
+func newCaptchaConfig(provider, verifyURL string) *config.Config {
+	return &config.Config{Captcha: config.CaptchaConfig{
+		Provider:          provider,
+		Secret:            "test-secret",
+		VerifyURL:         verifyURL,
+		MinimumScore:      0.5,
+		TrustedClientKeys: []string{"trusted-key"},
+		TrustedCIDRs:      []string{"10.0.0.0/8"},
+	}}
+}
+
+func newCaptchaContext(t *testing.T, logger commonLogger.Loggerer, headers map[string]string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	request := httptest.NewRequest(http.MethodPost, "/user/sessions", nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	ctx.Request = request.WithContext(context.WithValue(request.Context(), commonLogger.LoggerKey, logger))
+	return ctx, w
+}
+
+func TestVerifier(t *testing.T) {
+	t.Run("Verify_HCaptcha_Should_Invert_Risk_Score", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, r.ParseForm())
+			assert.Equal(t, "test-secret", r.PostForm.Get("secret"))
+			assert.Equal(t, "test-token", r.PostForm.Get("response"))
+			fmt.Fprint(w, `{"success":true,"score":0.2}`)
+		}))
+		defer server.Close()
+		verifier, err := NewVerifier(newCaptchaConfig(ProviderHCaptcha, server.URL), server.Client())
+		assert.NoError(t, err)
+
+		result, err := verifier.Verify(context.Background(), "test-token", "192.0.2.1")
+
+		assert.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.InDelta(t, 0.8, *result.Score, 0.0001)
+	})
+
+	t.Run("Verify_Provider_Error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+		verifier, err := NewVerifier(newCaptchaConfig(ProviderTurnstile, server.URL), server.Client())
+		assert.NoError(t, err)
+
+		result, err := verifier.Verify(context.Background(), "test-token", "")
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("NewVerifier_Unsupported_Provider_Error", func(t *testing.T) {
+		verifier, err := NewVerifier(newCaptchaConfig("unknown", ""), http.DefaultClient)
+
+		assert.Error(t, err)
+		assert.Nil(t, verifier)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	lowScore := 0.1
+	highScore := 0.9
+
+	t.Run("Check_Trusted_Client_Key_Should_Bypass", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		verifier := &stubVerifier{}
+		middleware, err := NewMiddleware(verifier, newCaptchaConfig(ProviderRecaptcha, ""))
+		assert.NoError(t, err)
+		ctx, _ := newCaptchaContext(t, commonLoggerMock.NewMockLoggerer(controller), map[string]string{ClientKeyHeader: "trusted-key"})
+
+		assert.True(t, middleware.Check(ctx))
+		assert.Equal(t, 0, verifier.calls)
+	})
+
+	t.Run("Check_Missing_Token_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		middleware, err := NewMiddleware(&stubVerifier{}, newCaptchaConfig(ProviderRecaptcha, ""))
+		assert.NoError(t, err)
+		ctx, w := newCaptchaContext(t, loggerMock, nil)
+
+		loggerMock.EXPECT().Error(nil, "No captcha token was present in the request")
+
+		assert.False(t, middleware.Check(ctx))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "captcha_required")
+	})
+
+	t.Run("Check_Low_Score_Error", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		middleware, err := NewMiddleware(&stubVerifier{result: &Result{Success: true, Score: &lowScore}}, newCaptchaConfig(ProviderRecaptcha, ""))
+		assert.NoError(t, err)
+		ctx, w := newCaptchaContext(t, loggerMock, map[string]string{TokenHeader: "test-token"})
+
+		loggerMock.EXPECT().Error(nil, "The captcha verification failed: []")
+
+		assert.False(t, middleware.Check(ctx))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "captcha_failed")
+	})
+
+	t.Run("Check_High_Score_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		middleware, err := NewMiddleware(&stubVerifier{result: &Result{Success: true, Score: &highScore}}, newCaptchaConfig(ProviderRecaptcha, ""))
+		assert.NoError(t, err)
+		ctx, _ := newCaptchaContext(t, commonLoggerMock.NewMockLoggerer(controller), map[string]string{TokenHeader: "test-token"})
+
+		assert.True(t, middleware.Check(ctx))
+	})
+
+	t.Run("NewMiddleware_Invalid_CIDR_Error", func(t *testing.T) {
+		configurations := newCaptchaConfig(ProviderRecaptcha, "")
+		configurations.Captcha.TrustedCIDRs = []string{"not-a-cidr"}
+
+		middleware, err := NewMiddleware(&stubVerifier{}, configurations)
+
+		assert.Error(t, err)
+		assert.Nil(t, middleware)
+	})
+}
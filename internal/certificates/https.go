@@ -0,0 +1,181 @@
+package certificates
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// Sources of the certificates of the HTTPS termination
+const (
+	SourceStatic = "static"
+	SourceACME   = "acme"
+)
+
+const defaultACMECacheDir = "certs/acme"
+
+// HTTPS terminates the TLS of the gateway with the static certificate or the certificates of the ACME manager
+type HTTPS struct {
+	certificate *tls.Certificate
+	manager     *autocert.Manager
+	redirect    *Redirect
+}
+
+// NewHTTPS loads the static certificate or creates the ACME manager of the configured domains
+func NewHTTPS(configurations *config.Config) (*HTTPS, error) {
+	httpsConfig := configurations.HTTPS
+	https := &HTTPS{redirect: NewRedirect(configurations)}
+	switch httpsConfig.Certificates {
+	case "", SourceStatic:
+		certificate, err := tls.LoadX509KeyPair(httpsConfig.CertFile, httpsConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load the HTTPS certificate: %v", err)
+		}
+		https.certificate = &certificate
+	case SourceACME:
+		if len(httpsConfig.ACME.Domains) == 0 {
+			return nil, fmt.Errorf("The ACME certificates need the domains they are provisioned for")
+		}
+		cacheDir := httpsConfig.ACME.CacheDir
+		if cacheDir == "" {
+			cacheDir = defaultACMECacheDir
+		}
+		https.manager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cacheDir),
+			HostPolicy: autocert.HostWhitelist(httpsConfig.ACME.Domains...),
+			Email:      httpsConfig.ACME.Email,
+		}
+		if httpsConfig.ACME.DirectoryURL != "" {
+			https.manager.Client = &acme.Client{DirectoryURL: httpsConfig.ACME.DirectoryURL}
+		}
+	default:
+		return nil, fmt.Errorf("Unknown HTTPS certificates source %s", httpsConfig.Certificates)
+	}
+	return https, nil
+}
+
+// TLSConfig returns the TLS configuration of the gateway server, the ACME one also answers the TLS-ALPN challenges
+func (https *HTTPS) TLSConfig() *tls.Config {
+	if https.manager != nil {
+		tlsConfig := https.manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{*https.certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// Handler returns the handler of the plain HTTP listener, redirecting to HTTPS once the ACME HTTP challenges are
+// answered
+func (https *HTTPS) Handler() http.Handler {
+	if https.manager != nil {
+		return https.manager.HTTPHandler(https.redirect)
+	}
+	return https.redirect
+}
+
+// Redirect redirects the plain HTTP requests to the same URL over HTTPS
+type Redirect struct {
+	httpsPort           string
+	trustForwardedProto bool
+	exemptPaths         map[string]bool
+}
+
+// NewRedirect creates the redirect of the configuration
+func NewRedirect(configurations *config.Config) *Redirect {
+	redirectConfig := configurations.HTTPS.Redirect
+	redirect := &Redirect{
+		httpsPort:           redirectConfig.HTTPSPort,
+		trustForwardedProto: redirectConfig.TrustForwardedProto,
+		exemptPaths:         map[string]bool{},
+	}
+	for _, path := range redirectConfig.ExemptPaths {
+		redirect.exemptPaths[path] = true
+	}
+	return redirect
+}
+
+// Secure tells whether the request came over HTTPS, to the gateway or to the load balancer in front of it
+func (redirect *Redirect) Secure(request *http.Request) bool {
+	if request.TLS != nil {
+		return true
+	}
+	return redirect.trustForwardedProto && strings.EqualFold(request.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// Location returns the HTTPS URL of the request
+func (redirect *Redirect) Location(request *http.Request) string {
+	hostname := request.Host
+	if splitHostname, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = splitHostname
+	}
+	hostname = strings.Trim(hostname, "[]")
+	host := hostname
+	switch {
+	case redirect.httpsPort != "" && redirect.httpsPort != "443":
+		host = net.JoinHostPort(hostname, redirect.httpsPort)
+	case strings.Contains(hostname, ":"):
+		host = "[" + hostname + "]"
+	}
+	return "https://" + host + request.URL.RequestURI()
+}
+
+// ServeHTTP redirects the request to HTTPS, keeping the method of the requests other than GET and HEAD
+func (redirect *Redirect) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	status := http.StatusPermanentRedirect
+	if request.Method == http.MethodGet || request.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(writer, request, redirect.Location(request), status)
+}
+
+// Middleware redirects the plain HTTP requests to HTTPS, except the requests of the exempt paths such as the probes
+func (redirect *Redirect) Middleware(ctx *gin.Context) {
+	if redirect.Secure(ctx.Request) || redirect.exemptPaths[ctx.Request.URL.Path] {
+		return
+	}
+	// The requests of the sidecars over the unix socket stay on it
+	if _, unix := ctx.Request.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr); unix {
+		return
+	}
+	redirect.ServeHTTP(ctx.Writer, ctx.Request)
+	ctx.Abort()
+}
+
+// HSTS tells the browsers to only reach the gateway over HTTPS
+type HSTS struct {
+	redirect *Redirect
+	value    string
+}
+
+// NewHSTS creates the Strict-Transport-Security header of the configuration
+func NewHSTS(configurations *config.Config) *HSTS {
+	hstsConfig := configurations.HTTPS.HSTS
+	directives := []string{"max-age=" + strconv.FormatInt(int64(hstsConfig.MaxAge.Seconds()), 10)}
+	if hstsConfig.IncludeSubdomains {
+		directives = append(directives, "includeSubDomains")
+	}
+	if hstsConfig.Preload {
+		directives = append(directives, "preload")
+	}
+	return &HSTS{redirect: NewRedirect(configurations), value: strings.Join(directives, "; ")}
+}
+
+// Middleware sets the header on the responses to the HTTPS requests, the browsers ignore it over plain HTTP
+func (hsts *HSTS) Middleware(ctx *gin.Context) {
+	if hsts.redirect.Secure(ctx.Request) {
+		ctx.Header("Strict-Transport-Security", hsts.value)
+	}
+}
@@ -0,0 +1,115 @@
+package certificates
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newRedirectRouter(configurations *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRedirect(configurations).Middleware, NewHSTS(configurations).Middleware)
+	router.Any("/*path", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	return router
+}
+
+func TestHTTPS(t *testing.T) {
+	configurations := &config.Config{HTTPS: config.HTTPSConfig{
+		Redirect: config.HTTPSRedirectConfig{Enabled: true, HTTPSPort: "8443", TrustForwardedProto: true, ExemptPaths: []string{"/healthz"}},
+		HSTS:     config.HSTSConfig{Enabled: true, MaxAge: 365 * 24 * time.Hour, IncludeSubdomains: true},
+	}}
+
+	t.Run("Redirect_Should_Send_The_Plain_HTTP_Requests_To_HTTPS", func(t *testing.T) {
+		router := newRedirectRouter(configurations)
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://api.example.com:8080/api/v1/user?id=1", nil))
+
+		assert.Equal(t, http.StatusMovedPermanently, recorder.Code)
+		assert.Equal(t, "https://api.example.com:8443/api/v1/user?id=1", recorder.Header().Get("Location"))
+		assert.Empty(t, recorder.Header().Get("Strict-Transport-Security"))
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://api.example.com/api/v1/user", nil))
+		assert.Equal(t, http.StatusPermanentRedirect, recorder.Code)
+	})
+
+	t.Run("Redirect_Should_Let_The_Exempt_And_Forwarded_HTTPS_Requests_Through", func(t *testing.T) {
+		router := newRedirectRouter(configurations)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://10.0.0.5/healthz", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		recorder = httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/v1/user", nil)
+		request.Header.Set("X-Forwarded-Proto", "https")
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "max-age=31536000; includeSubDomains", recorder.Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("Location_Should_Leave_Out_The_Default_Port", func(t *testing.T) {
+		redirect := NewRedirect(&config.Config{})
+
+		assert.Equal(t, "https://api.example.com/media", redirect.Location(httptest.NewRequest(http.MethodGet, "http://api.example.com:80/media", nil)))
+		assert.Equal(t, "https://[::1]/media", redirect.Location(httptest.NewRequest(http.MethodGet, "http://[::1]:80/media", nil)))
+	})
+
+	t.Run("NewHTTPS_Should_Load_The_Static_Certificate", func(t *testing.T) {
+		directory := t.TempDir()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "api.example.com"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		assert.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		assert.NoError(t, err)
+		certFile, keyFile := filepath.Join(directory, "gateway.pem"), filepath.Join(directory, "gateway-key.pem")
+		assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+		assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+		https, err := NewHTTPS(&config.Config{HTTPS: config.HTTPSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile}})
+
+		assert.NoError(t, err)
+		tlsConfig := https.TLSConfig()
+		assert.Len(t, tlsConfig.Certificates, 1)
+		assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	})
+
+	t.Run("NewHTTPS_Should_Reject_The_Invalid_Sources", func(t *testing.T) {
+		_, err := NewHTTPS(&config.Config{HTTPS: config.HTTPSConfig{Certificates: SourceACME}})
+		assert.ErrorContains(t, err, "need the domains")
+
+		_, err = NewHTTPS(&config.Config{HTTPS: config.HTTPSConfig{Certificates: "vault"}})
+		assert.ErrorContains(t, err, "Unknown HTTPS certificates source vault")
+
+		https, err := NewHTTPS(&config.Config{HTTPS: config.HTTPSConfig{Certificates: SourceACME, ACME: config.ACMEConfig{
+			Domains: []string{"api.example.com"}, CacheDir: t.TempDir(),
+		}}})
+		assert.NoError(t, err)
+		assert.NotNil(t, https.TLSConfig().GetCertificate)
+	})
+}
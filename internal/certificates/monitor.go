@@ -0,0 +1,223 @@
+package certificates
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/alerting"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+const defaultCheckInterval = 12 * time.Hour
+
+// Status is the expiry status of a single certificate
+type Status struct {
+	File         string    `json:"file"`
+	Subject      string    `json:"subject"`
+	NotAfter     time.Time `json:"notAfter"`
+	DaysToExpiry int       `json:"daysToExpiry"`
+}
+
+// Monitorer is the interface for the certificate expiry monitor
+type Monitorer interface {
+	Check(now time.Time) []Status
+	Statuses() []Status
+	Job() scheduler.Job
+}
+
+// Monitor periodically checks the expiry of the configured TLS material
+type Monitor struct {
+	files         []string
+	interval      time.Duration
+	warningWindow time.Duration
+	statuses      []Status
+	expiry        *metrics.GaugeVec
+	logger        commonLogger.Loggerer
+	mtx           sync.RWMutex
+}
+
+var _ Monitorer = &Monitor{}
+var _ alerting.Conditioner = &Monitor{}
+
+// NewMonitor creates a certificate expiry monitor of the certificate files of the configuration, the days left
+// before their expiry are exposed in the default metrics when the metrics are enabled
+func NewMonitor(configurations *config.Config) *Monitor {
+	monitor := &Monitor{
+		files:         MonitoredFiles(configurations),
+		interval:      configurations.Certificates.CheckInterval,
+		warningWindow: configurations.Certificates.WarningWindow,
+		logger:        commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}
+	if monitor.interval <= 0 {
+		monitor.interval = defaultCheckInterval
+	}
+	if configurations.Metrics.Enabled {
+		monitor.expiry = metrics.DefaultMetrics.CertificateExpiry()
+	}
+	return monitor
+}
+
+// MonitoredFiles returns the certificate files of the configuration: the listed files, the static HTTPS certificate,
+// the client certificates and CA files of the upstream services and the CA file of the outbound HTTP clients
+func MonitoredFiles(configurations *config.Config) []string {
+	files := append([]string{}, configurations.Certificates.Files...)
+	if configurations.HTTPS.Enabled && configurations.HTTPS.Certificates != SourceACME {
+		files = append(files, configurations.HTTPS.CertFile)
+	}
+	services := make([]string, 0, len(configurations.UpstreamTLS.Services))
+	for service := range configurations.UpstreamTLS.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		serviceTLS := configurations.UpstreamTLS.Services[service]
+		if !serviceTLS.Enabled {
+			continue
+		}
+		if serviceTLS.CertFile != "" {
+			files = append(files, serviceTLS.CertFile)
+		}
+		if serviceTLS.CAFile != "" {
+			files = append(files, serviceTLS.CAFile)
+		}
+	}
+	if configurations.OutboundHTTP.CAFile != "" {
+		files = append(files, configurations.OutboundHTTP.CAFile)
+	}
+	monitored := []string{}
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if !seen[file] {
+			seen[file] = true
+			monitored = append(monitored, file)
+		}
+	}
+	return monitored
+}
+
+// LoadCertificates reads all the PEM encoded certificates in a file
+func LoadCertificates(file string) ([]*x509.Certificate, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read certificate file %s: %v", file, err)
+	}
+	certificates := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse certificate in %s: %v", file, err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("No certificates found in %s", file)
+	}
+	return certificates, nil
+}
+
+// Check loads every configured certificate and logs a warning for the ones close to expiry
+func (monitor *Monitor) Check(now time.Time) []Status {
+	statuses := []Status{}
+	for _, file := range monitor.files {
+		certificates, err := LoadCertificates(file)
+		if err != nil {
+			monitor.logger.Error(err, "Could not check certificate expiry")
+			continue
+		}
+		for _, certificate := range certificates {
+			status := Status{
+				File:         file,
+				Subject:      certificate.Subject.String(),
+				NotAfter:     certificate.NotAfter,
+				DaysToExpiry: int(certificate.NotAfter.Sub(now).Hours() / 24),
+			}
+			if certificate.NotAfter.Sub(now) < monitor.warningWindow {
+				monitor.logger.Warn(fmt.Sprintf(
+					"Certificate %s in %s expires in %d days",
+					status.Subject,
+					file,
+					status.DaysToExpiry,
+				))
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].NotAfter.Before(statuses[j].NotAfter)
+	})
+
+	monitor.mtx.Lock()
+	monitor.statuses = statuses
+	monitor.mtx.Unlock()
+	if monitor.expiry != nil {
+		values := make([]metrics.GaugeValue, 0, len(statuses))
+		for _, status := range statuses {
+			values = append(values, metrics.GaugeValue{
+				LabelValues: []string{status.File, status.Subject},
+				Value:       float64(status.DaysToExpiry),
+			})
+		}
+		monitor.expiry.Replace(values)
+	}
+	return statuses
+}
+
+// Statuses returns the result of the last check sorted by expiry
+func (monitor *Monitor) Statuses() []Status {
+	monitor.mtx.RLock()
+	defer monitor.mtx.RUnlock()
+	return append([]Status{}, monitor.statuses...)
+}
+
+// Job checks the certificates on start and then on every interval
+func (monitor *Monitor) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:       "certificates_check",
+		Schedule:   scheduler.Every(monitor.interval),
+		RunOnStart: true,
+		Run: func(ctx context.Context, now time.Time) error {
+			monitor.Check(now)
+			return nil
+		},
+	}
+}
+
+// Evaluate raises an alert when the certificate closest to expiry is within the warning window
+func (monitor *Monitor) Evaluate(now time.Time) *alerting.Alert {
+	statuses := monitor.Statuses()
+	if len(statuses) == 0 || statuses[0].NotAfter.Sub(now) >= monitor.warningWindow {
+		return nil
+	}
+	return &alerting.Alert{
+		Name:     "certificate_expiring",
+		Severity: alerting.SeverityWarning,
+		Summary: fmt.Sprintf(
+			"Certificate %s expires in %d days",
+			statuses[0].Subject,
+			statuses[0].DaysToExpiry,
+		),
+		Details: map[string]string{
+			"file":     statuses[0].File,
+			"notAfter": statuses[0].NotAfter.Format(time.RFC3339),
+		},
+		Timestamp: now,
+	}
+}
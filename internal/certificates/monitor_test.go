@@ -0,0 +1,131 @@
+package certificates
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+func writeTestCertificate(t *testing.T, directory string, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	file := filepath.Join(directory, "cert.pem")
+	err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	assert.NoError(t, err)
+	return file
+}
+
+func TestMonitor(t *testing.T) {
+	t.Run("Check_Should_Report_Days_To_Expiry_And_Alert", func(t *testing.T) {
+		now := time.Now()
+		file := writeTestCertificate(t, t.TempDir(), now.Add(10*24*time.Hour+time.Hour))
+		monitor := NewMonitor(&config.Config{
+			Environment: "test",
+			Certificates: config.CertificatesConfig{
+				Files:         []string{file},
+				WarningWindow: 30 * 24 * time.Hour,
+			},
+		})
+
+		statuses := monitor.Check(now)
+
+		assert.Len(t, statuses, 1)
+		assert.Equal(t, 10, statuses[0].DaysToExpiry)
+		assert.Equal(t, "CN=example.com", statuses[0].Subject)
+		alert := monitor.Evaluate(now)
+		assert.NotNil(t, alert)
+		assert.Equal(t, "certificate_expiring", alert.Name)
+	})
+
+	t.Run("Check_Should_Not_Alert_Outside_Warning_Window", func(t *testing.T) {
+		now := time.Now()
+		file := writeTestCertificate(t, t.TempDir(), now.Add(90*24*time.Hour))
+		monitor := NewMonitor(&config.Config{
+			Environment: "test",
+			Certificates: config.CertificatesConfig{
+				Files:         []string{file},
+				WarningWindow: 30 * 24 * time.Hour,
+			},
+		})
+
+		monitor.Check(now)
+
+		assert.Nil(t, monitor.Evaluate(now))
+	})
+
+	t.Run("Check_Should_Set_The_Days_To_Expiry_Gauge", func(t *testing.T) {
+		now := time.Now()
+		file := writeTestCertificate(t, t.TempDir(), now.Add(45*24*time.Hour+time.Hour))
+		monitor := NewMonitor(&config.Config{
+			Environment:  "test",
+			Metrics:      config.MetricsConfig{Enabled: true},
+			Certificates: config.CertificatesConfig{Files: []string{file}},
+		})
+
+		monitor.Check(now)
+
+		assert.Equal(t, float64(45), metrics.DefaultMetrics.CertificateExpiry().Value(file, "CN=example.com"))
+	})
+
+	t.Run("Job_Should_Default_The_Check_Interval", func(t *testing.T) {
+		monitor := NewMonitor(&config.Config{Environment: "test"})
+
+		_, err := scheduler.ParseSchedule(monitor.Job().Schedule)
+
+		assert.NoError(t, err)
+		assert.Equal(t, scheduler.Every(defaultCheckInterval), monitor.Job().Schedule)
+	})
+
+	t.Run("MonitoredFiles_Should_Derive_The_Files_From_The_TLS_Configuration", func(t *testing.T) {
+		files := MonitoredFiles(&config.Config{
+			Certificates: config.CertificatesConfig{Files: []string{"certs/extra.pem", "certs/gateway.pem"}},
+			HTTPS:        config.HTTPSConfig{Enabled: true, CertFile: "certs/gateway.pem"},
+			UpstreamTLS: config.UpstreamTLSConfig{Services: map[string]config.UpstreamServiceTLSConfig{
+				"payment":        {Enabled: true, CAFile: "certs/payment-ca.pem", CertFile: "certs/payment.pem"},
+				"authentication": {Enabled: true, CAFile: "certs/internal-ca.pem"},
+				"media":          {CertFile: "certs/media.pem"},
+			}},
+			OutboundHTTP: config.OutboundHTTPConfig{CAFile: "certs/internal-ca.pem"},
+		})
+
+		assert.Equal(t, []string{
+			"certs/extra.pem",
+			"certs/gateway.pem",
+			"certs/internal-ca.pem",
+			"certs/payment.pem",
+			"certs/payment-ca.pem",
+		}, files)
+	})
+
+	t.Run("LoadCertificates_Should_Fail_Without_Certificates", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "empty.pem")
+		assert.NoError(t, os.WriteFile(file, []byte("not a certificate"), 0600))
+
+		certificates, err := LoadCertificates(file)
+
+		assert.Nil(t, certificates)
+		assert.Error(t, err)
+	})
+}
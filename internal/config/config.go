@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	commonAWS "github.com/quadev-ltd/qd-common/pkg/aws"
 	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
@@ -10,9 +11,1180 @@ import (
 
 // Config is the configuration of the application
 type Config struct {
-	Verbose     bool
-	Environment string
-	AWS         commonAWS.Config
+	Verbose             bool
+	Environment         string
+	AWS                 commonAWS.Config
+	Authentication      AuthenticationConfig   `mapstructure:"authentication"`
+	PublicRoutes        PublicRoutesConfig     `mapstructure:"public_routes"`
+	Captcha             CaptchaConfig          `mapstructure:"captcha"`
+	Attestation         AttestationConfig      `mapstructure:"attestation"`
+	Versioning          VersioningConfig       `mapstructure:"versioning"`
+	Redis               RedisConfig            `mapstructure:"redis"`
+	AgeGate             AgeGateConfig          `mapstructure:"age_gate"`
+	Preferences         PreferencesConfig      `mapstructure:"preferences"`
+	NotificationService ServiceConfig          `mapstructure:"notification_service"`
+	PaymentService      PaymentServiceConfig   `mapstructure:"payment_service"`
+	MediaService        MediaServiceConfig     `mapstructure:"media_service"`
+	SearchService       SearchServiceConfig    `mapstructure:"search_service"`
+	SupportService      SupportServiceConfig   `mapstructure:"support_service"`
+	ReferenceService    ReferenceServiceConfig `mapstructure:"reference_service"`
+	Admin               AdminConfig            `mapstructure:"admin"`
+	Events              EventsConfig           `mapstructure:"events"`
+	Alerting            AlertingConfig         `mapstructure:"alerting"`
+	Certificates        CertificatesConfig     `mapstructure:"certificates"`
+	HTTPS               HTTPSConfig            `mapstructure:"https"`
+	Documentation       DocumentationConfig    `mapstructure:"documentation"`
+	Analytics           AnalyticsConfig        `mapstructure:"analytics"`
+	TrafficTap          TrafficTapConfig       `mapstructure:"traffic_tap"`
+	Experiments         ExperimentsConfig      `mapstructure:"experiments"`
+	OutboundHTTP        OutboundHTTPConfig     `mapstructure:"outbound_http"`
+	DNS                 DNSConfig              `mapstructure:"dns"`
+	EgressProxy         EgressProxyConfig      `mapstructure:"egress_proxy"`
+	UnixSockets         UnixSocketsConfig      `mapstructure:"unix_sockets"`
+	UpstreamTLS         UpstreamTLSConfig      `mapstructure:"upstream_tls"`
+	Mesh                MeshConfig             `mapstructure:"mesh"`
+	Serverless          ServerlessConfig       `mapstructure:"serverless"`
+	Scheduler           SchedulerConfig        `mapstructure:"scheduler"`
+	LeaderElection      LeaderElectionConfig   `mapstructure:"leader_election"`
+	Journal             JournalConfig          `mapstructure:"journal"`
+	ResponseHeaders     ResponseHeadersConfig  `mapstructure:"response_headers"`
+	WellKnown           WellKnownConfig        `mapstructure:"well_known"`
+	Crawlers            CrawlersConfig         `mapstructure:"crawlers"`
+	Noise               NoiseConfig            `mapstructure:"noise"`
+	Capabilities        CapabilitiesConfig     `mapstructure:"capabilities"`
+	Region              RegionConfig           `mapstructure:"region"`
+	Timeouts            TimeoutsConfig         `mapstructure:"timeouts"`
+	RateLimits          RateLimitsConfig       `mapstructure:"rate_limits"`
+	FakeBackends        FakeBackendsConfig     `mapstructure:"fake_backends"`
+	CircuitBreakers     CircuitBreakersConfig  `mapstructure:"circuit_breakers"`
+	Retries             RetriesConfig          `mapstructure:"retries"`
+	Metrics             MetricsConfig          `mapstructure:"metrics"`
+	Tracing             TracingConfig          `mapstructure:"tracing"`
+	WebSockets          WebSocketsConfig       `mapstructure:"websockets"`
+	Hooks               HooksConfig            `mapstructure:"hooks"`
+	Validation          ValidationConfig       `mapstructure:"validation"`
+	APIKeys             APIKeysConfig          `mapstructure:"api_keys"`
+	WASMFilters         WASMFiltersConfig      `mapstructure:"wasm_filters"`
+	Scripts             ScriptsConfig          `mapstructure:"scripts"`
+	PolicyBundles       PolicyBundlesConfig    `mapstructure:"policy_bundles"`
+	TokenRevocation     TokenRevocationConfig  `mapstructure:"token_revocation"`
+	CORS                CORSConfig             `mapstructure:"cors"`
+	ConfigDrift         ConfigDriftConfig      `mapstructure:"config_drift"`
+	Health              HealthConfig           `mapstructure:"health"`
+	UpstreamRoutes      UpstreamRoutesConfig   `mapstructure:"upstream_routes"`
+	DebugToken          DebugTokenConfig       `mapstructure:"debug_token"`
+	ConfigReload        ConfigReloadConfig     `mapstructure:"config_reload"`
+	TokenMinting        TokenMintingConfig     `mapstructure:"token_minting"`
+	OIDC                OIDCConfig             `mapstructure:"oidc"`
+	Transcoding         TranscodingConfig      `mapstructure:"transcoding"`
+	QueryRedaction      QueryRedactionConfig   `mapstructure:"query_redaction"`
+	RequestLog          RequestLogConfig       `mapstructure:"request_log"`
+	ResponseSigning     ResponseSigningConfig  `mapstructure:"response_signing"`
+	Entitlements        EntitlementsConfig     `mapstructure:"entitlements"`
+	Tenancy             TenancyConfig          `mapstructure:"tenancy"`
+	ResponseCache       ResponseCacheConfig    `mapstructure:"response_cache"`
+	Shutdown            ShutdownConfig         `mapstructure:"shutdown"`
+}
+
+// AuthenticationConfig is the configuration of the authentication middleware
+type AuthenticationConfig struct {
+	ExpiryHintWindow          time.Duration         `mapstructure:"expiry_hint_window"`
+	ExpiryPreemptWindow       time.Duration         `mapstructure:"expiry_preempt_window"`
+	IdleTimeout               time.Duration         `mapstructure:"idle_timeout"`
+	ActivityRetention         time.Duration         `mapstructure:"activity_retention"`
+	ActivityFailOpen          bool                  `mapstructure:"activity_fail_open"`
+	MaxConcurrentSessions     int                   `mapstructure:"max_concurrent_sessions"`
+	SessionLimitPolicy        string                `mapstructure:"session_limit_policy"`
+	VerificationCacheTTL      time.Duration         `mapstructure:"verification_cache_ttl"`
+	PublicKeyRefreshInterval  time.Duration         `mapstructure:"public_key_refresh_interval"`
+	PublicKeyCacheTTL         time.Duration         `mapstructure:"public_key_cache_ttl"`
+	PublicKeyMismatchCooldown time.Duration         `mapstructure:"public_key_mismatch_cooldown"`
+	RefreshTokenRotation      bool                  `mapstructure:"refresh_token_rotation"`
+	Audiences                 []RouteAudienceConfig `mapstructure:"audiences"`
+}
+
+// RouteAudienceConfig requires the access tokens of the requests under the path prefix to hold the audience
+// in their aud claim, so the tokens issued for an API surface can not be replayed against another
+type RouteAudienceConfig struct {
+	PathPrefix string `mapstructure:"path_prefix"`
+	Audience   string `mapstructure:"audience"`
+}
+
+// PublicRoutesConfig is the configuration of the hardening of the unauthenticated routes
+type PublicRoutesConfig struct {
+	RateLimit      float64 `mapstructure:"rate_limit"`
+	RateLimitBurst int     `mapstructure:"rate_limit_burst"`
+}
+
+// CaptchaConfig is the configuration of the captcha verification of the protected public routes
+type CaptchaConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	Provider          string        `mapstructure:"provider"`
+	Secret            string        `mapstructure:"secret"`
+	SiteKey           string        `mapstructure:"site_key"`
+	VerifyURL         string        `mapstructure:"verify_url"`
+	MinimumScore      float64       `mapstructure:"minimum_score"`
+	Timeout           time.Duration `mapstructure:"timeout"`
+	TrustedClientKeys []string      `mapstructure:"trusted_client_keys"`
+	TrustedCIDRs      []string      `mapstructure:"trusted_cidrs"`
+}
+
+// AttestationConfig is the configuration of the mobile app attestation of the sensitive routes
+type AttestationConfig struct {
+	Enabled         bool                `mapstructure:"enabled"`
+	Routes          []string            `mapstructure:"routes"`
+	CacheTTL        time.Duration       `mapstructure:"cache_ttl"`
+	FailureCacheTTL time.Duration       `mapstructure:"failure_cache_ttl"`
+	PlayIntegrity   PlayIntegrityConfig `mapstructure:"play_integrity"`
+	AppAttest       AppAttestConfig     `mapstructure:"app_attest"`
+}
+
+// PlayIntegrityConfig is the configuration of the Google Play Integrity verification
+type PlayIntegrityConfig struct {
+	PackageName        string   `mapstructure:"package_name"`
+	CertificateDigests []string `mapstructure:"certificate_digests"`
+	AccessToken        string   `mapstructure:"access_token"`
+	DecodeURL          string   `mapstructure:"decode_url"`
+}
+
+// AppAttestConfig is the configuration of the Apple App Attest verification
+type AppAttestConfig struct {
+	AppID string `mapstructure:"app_id"`
+}
+
+// VersioningConfig is the configuration of the client app version enforcement and routing
+type VersioningConfig struct {
+	MinimumVersion  string            `mapstructure:"minimum_version"`
+	MinimumVersions map[string]string `mapstructure:"minimum_versions"`
+	UpgradeURL      string            `mapstructure:"upgrade_url"`
+	UpgradeURLs     map[string]string `mapstructure:"upgrade_urls"`
+	Upstreams       []UpstreamConfig  `mapstructure:"upstreams"`
+}
+
+// UpstreamConfig is an alternate upstream of a service serving the app versions matching a constraint
+type UpstreamConfig struct {
+	Service  string `mapstructure:"service"`
+	Versions string `mapstructure:"versions"`
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	Socket   string `mapstructure:"socket"`
+}
+
+// AgeGateConfig is the configuration of the minimum age and parental consent checks
+type AgeGateConfig struct {
+	MinimumAge        int      `mapstructure:"minimum_age"`
+	ConsentMinimumAge int      `mapstructure:"consent_minimum_age"`
+	Routes            []string `mapstructure:"routes"`
+}
+
+// PreferencesConfig is the configuration of the user localization preferences
+type PreferencesConfig struct {
+	SupportedLocales []string                                `mapstructure:"supported_locales"`
+	DefaultLocale    string                                  `mapstructure:"default_locale"`
+	DefaultTimezone  string                                  `mapstructure:"default_timezone"`
+	CacheTTL         time.Duration                           `mapstructure:"cache_ttl"`
+	CacheMaxEntries  int                                     `mapstructure:"cache_max_entries"`
+	Labels           map[string]map[string]map[string]string `mapstructure:"labels"`
+}
+
+// ServiceConfig is the configuration of a backend service not provided by the central configuration
+type ServiceConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Host    string `mapstructure:"host"`
+	Port    string `mapstructure:"port"`
+}
+
+// PaymentServiceConfig is the configuration of the payment service and its stricter request policy
+type PaymentServiceConfig struct {
+	ServiceConfig  `mapstructure:",squash"`
+	StepUpMaxAge   time.Duration `mapstructure:"step_up_max_age"`
+	RateLimit      float64       `mapstructure:"rate_limit"`
+	RateLimitBurst int           `mapstructure:"rate_limit_burst"`
+}
+
+// MediaServiceConfig is the configuration of the media service uploads and thumbnail caching
+type MediaServiceConfig struct {
+	ServiceConfig   `mapstructure:",squash"`
+	MaxUploadSize   int64         `mapstructure:"max_upload_size"`
+	ThumbnailSizes  []int         `mapstructure:"thumbnail_sizes"`
+	ThumbnailMaxAge time.Duration `mapstructure:"thumbnail_max_age"`
+}
+
+// SearchServiceConfig is the configuration of the search service rate limits and result caching
+type SearchServiceConfig struct {
+	ServiceConfig   `mapstructure:",squash"`
+	RateLimit       float64       `mapstructure:"rate_limit"`
+	RateLimitBurst  int           `mapstructure:"rate_limit_burst"`
+	CacheTTL        time.Duration `mapstructure:"cache_ttl"`
+	CacheMaxEntries int           `mapstructure:"cache_max_entries"`
+}
+
+// SupportServiceConfig is the configuration of the support ticket attachments, spam filtering and rate limits
+type SupportServiceConfig struct {
+	ServiceConfig          `mapstructure:",squash"`
+	MaxAttachments         int           `mapstructure:"max_attachments"`
+	MaxAttachmentSize      int64         `mapstructure:"max_attachment_size"`
+	AllowedAttachmentTypes []string      `mapstructure:"allowed_attachment_types"`
+	SpamThreshold          float64       `mapstructure:"spam_threshold"`
+	DuplicateWindow        time.Duration `mapstructure:"duplicate_window"`
+	RateLimit              float64       `mapstructure:"rate_limit"`
+	RateLimitBurst         int           `mapstructure:"rate_limit_burst"`
+}
+
+// ReferenceServiceConfig is the configuration of the cached reference data routes
+type ReferenceServiceConfig struct {
+	ServiceConfig   `mapstructure:",squash"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	MaxAge          time.Duration `mapstructure:"max_age"`
+	EdgeMaxAge      time.Duration `mapstructure:"edge_max_age"`
+}
+
+// AdminConfig is the configuration of the user administration routes and of the introspection routes
+type AdminConfig struct {
+	Enabled       bool                     `mapstructure:"enabled"`
+	Roles         []string                 `mapstructure:"roles"`
+	Introspection AdminIntrospectionConfig `mapstructure:"introspection"`
+}
+
+// AdminIntrospectionConfig is the configuration of the /admin routes describing the runtime state of the gateway,
+// served to the operators presenting the admin bearer token rather than a user token
+type AdminIntrospectionConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Token   string `mapstructure:"token"`
+}
+
+// RedisConfig is the configuration of the redis connections shared by the gateway instances, each client
+// keeping up to the pool size of connections
+type RedisConfig struct {
+	Address  string        `mapstructure:"address"`
+	Password string        `mapstructure:"password"`
+	DB       int           `mapstructure:"db"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	PoolSize int           `mapstructure:"pool_size"`
+}
+
+// EventsConfig is the configuration of the event bus the gateway publishes to
+type EventsConfig struct {
+	Enabled bool               `mapstructure:"enabled"`
+	BusName string             `mapstructure:"bus_name"`
+	Region  string             `mapstructure:"region"`
+	Source  string             `mapstructure:"source"`
+	Outbox  EventsOutboxConfig `mapstructure:"outbox"`
+}
+
+// EventsOutboxConfig is the configuration of the outbox keeping the events until the bus accepts them,
+// the backend is "file" or "redis"
+type EventsOutboxConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Backend       string        `mapstructure:"backend"`
+	Path          string        `mapstructure:"path"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
+	MaxBackoff    time.Duration `mapstructure:"max_backoff"`
+	MaxAttempts   int           `mapstructure:"max_attempts"`
+}
+
+// AlertingConfig is the configuration of the alert notifier
+type AlertingConfig struct {
+	Enabled                bool          `mapstructure:"enabled"`
+	SlackWebhookURL        string        `mapstructure:"slack_webhook_url"`
+	PagerDutyRoutingKey    string        `mapstructure:"pagerduty_routing_key"`
+	PagerDutyEventsURL     string        `mapstructure:"pagerduty_events_url"`
+	EvaluationInterval     time.Duration `mapstructure:"evaluation_interval"`
+	Cooldown               time.Duration `mapstructure:"cooldown"`
+	AuthErrorRateThreshold int           `mapstructure:"auth_error_rate_threshold"`
+	AuthErrorRateWindow    time.Duration `mapstructure:"auth_error_rate_window"`
+	BreakerOpenDuration    time.Duration `mapstructure:"breaker_open_duration"`
+}
+
+// CertificatesConfig is the configuration of the TLS certificate expiry monitor
+type CertificatesConfig struct {
+	Files         []string      `mapstructure:"files"`
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	WarningWindow time.Duration `mapstructure:"warning_window"`
+}
+
+// HTTPSConfig is the configuration of the TLS termination of the gateway on its listen address, with a static
+// certificate or certificates provisioned by ACME. The redirect and HSTS apply as well when the TLS is terminated
+// by a load balancer in front of the gateway
+type HTTPSConfig struct {
+	Enabled      bool                `mapstructure:"enabled"`
+	Certificates string              `mapstructure:"certificates"`
+	CertFile     string              `mapstructure:"cert_file"`
+	KeyFile      string              `mapstructure:"key_file"`
+	ACME         ACMEConfig          `mapstructure:"acme"`
+	Redirect     HTTPSRedirectConfig `mapstructure:"redirect"`
+	HSTS         HSTSConfig          `mapstructure:"hsts"`
+}
+
+// ACMEConfig provisions and renews the certificates of the domains from an ACME directory, Let's Encrypt by default.
+// The certificates are cached in the directory so the restarts do not provision them again
+type ACMEConfig struct {
+	Domains      []string `mapstructure:"domains"`
+	Email        string   `mapstructure:"email"`
+	CacheDir     string   `mapstructure:"cache_dir"`
+	DirectoryURL string   `mapstructure:"directory_url"`
+}
+
+// HTTPSRedirectConfig redirects the plain HTTP requests to HTTPS, on the address listening for them when set, which
+// also answers the ACME HTTP challenges. The forwarded protocol header is only trusted behind a load balancer
+type HTTPSRedirectConfig struct {
+	Enabled             bool     `mapstructure:"enabled"`
+	Address             string   `mapstructure:"address"`
+	HTTPSPort           string   `mapstructure:"https_port"`
+	TrustForwardedProto bool     `mapstructure:"trust_forwarded_proto"`
+	ExemptPaths         []string `mapstructure:"exempt_paths"`
+}
+
+// HSTSConfig is the Strict-Transport-Security header of the HTTPS responses
+type HSTSConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	MaxAge            time.Duration `mapstructure:"max_age"`
+	IncludeSubdomains bool          `mapstructure:"include_subdomains"`
+	Preload           bool          `mapstructure:"preload"`
+}
+
+// DocumentationConfig is the configuration of the public API documentation links
+type DocumentationConfig struct {
+	OpenAPIURL string `mapstructure:"openapi_url"`
+}
+
+// AnalyticsConfig is the configuration of the daily route usage reports
+type AnalyticsConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Bucket         string        `mapstructure:"bucket"`
+	Region         string        `mapstructure:"region"`
+	Prefix         string        `mapstructure:"prefix"`
+	Formats        []string      `mapstructure:"formats"`
+	ExportInterval time.Duration `mapstructure:"export_interval"`
+	CountryHeader  string        `mapstructure:"country_header"`
+}
+
+// TrafficTapConfig is the configuration of the admin real-time traffic stream
+type TrafficTapConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	MaxSubscribers int           `mapstructure:"max_subscribers"`
+	BufferSize     int           `mapstructure:"buffer_size"`
+	KeepAlive      time.Duration `mapstructure:"keep_alive"`
+	MaxDuration    time.Duration `mapstructure:"max_duration"`
+}
+
+// ExperimentsConfig is the configuration of the A/B experiment assignments
+type ExperimentsConfig struct {
+	Enabled     bool               `mapstructure:"enabled"`
+	ExposureTTL time.Duration      `mapstructure:"exposure_ttl"`
+	Definitions []ExperimentConfig `mapstructure:"definitions"`
+}
+
+// ExperimentConfig is the definition of an experiment, applied to every route when none is listed
+type ExperimentConfig struct {
+	Name     string          `mapstructure:"name"`
+	Salt     string          `mapstructure:"salt"`
+	Variants []VariantConfig `mapstructure:"variants"`
+	Routes   []string        `mapstructure:"routes"`
+}
+
+// VariantConfig is a variant of an experiment with its relative weight
+type VariantConfig struct {
+	Name   string `mapstructure:"name"`
+	Weight int    `mapstructure:"weight"`
+}
+
+// OutboundHTTPConfig is the configuration shared by the HTTP clients calling third parties
+type OutboundHTTPConfig struct {
+	Timeout             time.Duration                   `mapstructure:"timeout"`
+	DialTimeout         time.Duration                   `mapstructure:"dial_timeout"`
+	TLSHandshakeTimeout time.Duration                   `mapstructure:"tls_handshake_timeout"`
+	IdleConnTimeout     time.Duration                   `mapstructure:"idle_conn_timeout"`
+	MaxIdleConnsPerHost int                             `mapstructure:"max_idle_conns_per_host"`
+	MaxRetries          int                             `mapstructure:"max_retries"`
+	RetryBackoff        time.Duration                   `mapstructure:"retry_backoff"`
+	MaxRetryBackoff     time.Duration                   `mapstructure:"max_retry_backoff"`
+	ProxyURL            string                          `mapstructure:"proxy_url"`
+	CAFile              string                          `mapstructure:"ca_file"`
+	MinTLSVersion       string                          `mapstructure:"min_tls_version"`
+	Clients             map[string]OutboundClientConfig `mapstructure:"clients"`
+}
+
+// OutboundClientConfig overrides the outbound policy for the client of a feature
+type OutboundClientConfig struct {
+	Timeout    time.Duration `mapstructure:"timeout"`
+	MaxRetries *int          `mapstructure:"max_retries"`
+}
+
+// DNSConfig is the configuration of the DNS cache resolving the upstream services
+type DNSConfig struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	MinTTL             time.Duration `mapstructure:"min_ttl"`
+	MaxTTL             time.Duration `mapstructure:"max_ttl"`
+	DefaultTTL         time.Duration `mapstructure:"default_ttl"`
+	NegativeTTL        time.Duration `mapstructure:"negative_ttl"`
+	MaxNegativeEntries int           `mapstructure:"max_negative_entries"`
+	StaleTTL           time.Duration `mapstructure:"stale_ttl"`
+	LookupTimeout      time.Duration `mapstructure:"lookup_timeout"`
+}
+
+// EgressProxyConfig routes the upstream and webhook traffic through a corporate proxy
+type EgressProxyConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	URL         string        `mapstructure:"url"`
+	NoProxy     []string      `mapstructure:"no_proxy"`
+	DialTimeout time.Duration `mapstructure:"dial_timeout"`
+}
+
+// UnixSocketsConfig serves the API and dials the upstreams over unix sockets in sidecar deployments
+type UnixSocketsConfig struct {
+	Listen     string                        `mapstructure:"listen"`
+	ListenMode string                        `mapstructure:"listen_mode"`
+	Upstreams  map[string]UnixUpstreamConfig `mapstructure:"upstreams"`
+}
+
+// UnixUpstreamConfig is the socket of a service, usually plain text to a sidecar terminating mTLS
+type UnixUpstreamConfig struct {
+	Path       string `mapstructure:"path"`
+	TLSEnabled bool   `mapstructure:"tls_enabled"`
+}
+
+// UpstreamTLSConfig is the TLS of the gRPC connections to the upstream services by service name, the services
+// left out follow the TLS setting of the central configuration. The files are read again on every reload interval
+// when they changed so the certificates are rotated without restarting the gateway
+type UpstreamTLSConfig struct {
+	ReloadInterval time.Duration                       `mapstructure:"reload_interval"`
+	Services       map[string]UpstreamServiceTLSConfig `mapstructure:"services"`
+}
+
+// UpstreamServiceTLSConfig is the TLS of the connections to a service, mutual when the client certificate and key
+// are set. The server certificate is verified against the CA file, the default CA certificate when not set
+type UpstreamServiceTLSConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	CAFile     string `mapstructure:"ca_file"`
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	ServerName string `mapstructure:"server_name"`
+}
+
+// MeshConfig is the configuration of the Envoy/Istio header compatibility
+type MeshConfig struct {
+	Enabled             bool          `mapstructure:"enabled"`
+	TrustInboundHeaders bool          `mapstructure:"trust_inbound_headers"`
+	GenerateB3          bool          `mapstructure:"generate_b3"`
+	SampleNewTraces     bool          `mapstructure:"sample_new_traces"`
+	Timeout             time.Duration `mapstructure:"timeout"`
+	MaxRetries          *int          `mapstructure:"max_retries"`
+	RetryOn             string        `mapstructure:"retry_on"`
+	RetryGRPCOn         string        `mapstructure:"retry_grpc_on"`
+	PerTryTimeout       time.Duration `mapstructure:"per_try_timeout"`
+}
+
+// ServerlessConfig selects the request contract the router is served with: empty for a persistent
+// server, "lambda", "cloud_run" or "auto" to detect them from the environment
+type ServerlessConfig struct {
+	Platform string `mapstructure:"platform"`
+}
+
+// SchedulerConfig overrides the schedule of the periodic gateway jobs by name
+type SchedulerConfig struct {
+	Jobs map[string]JobConfig `mapstructure:"jobs"`
+}
+
+// JobConfig overrides the schedule, jitter and timeout of a job, or disables it
+type JobConfig struct {
+	Schedule  string        `mapstructure:"schedule"`
+	Jitter    time.Duration `mapstructure:"jitter"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+	Disabled  bool          `mapstructure:"disabled"`
+	Singleton *bool         `mapstructure:"singleton"`
+}
+
+// LeaderElectionConfig is the configuration of the election of the replica running the singleton jobs,
+// the backend is "redis" or "kubernetes"
+type LeaderElectionConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Backend       string        `mapstructure:"backend"`
+	LeaseName     string        `mapstructure:"lease_name"`
+	Namespace     string        `mapstructure:"namespace"`
+	TTL           time.Duration `mapstructure:"ttl"`
+	RenewInterval time.Duration `mapstructure:"renew_interval"`
+}
+
+// JournalConfig is the configuration of the encrypted journal of the sampled request envelopes,
+// the encryption key is a base64 encoded AES key
+type JournalConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Directory     string        `mapstructure:"directory"`
+	EncryptionKey string        `mapstructure:"encryption_key"`
+	SampleRate    float64       `mapstructure:"sample_rate"`
+	SampleErrors  bool          `mapstructure:"sample_errors"`
+	Headers       []string      `mapstructure:"headers"`
+	CaptureBodies bool          `mapstructure:"capture_bodies"`
+	MaxBodyBytes  int           `mapstructure:"max_body_bytes"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	MaxBuffered   int           `mapstructure:"max_buffered"`
+	Retention     time.Duration `mapstructure:"retention"`
+	MaxBytes      int64         `mapstructure:"max_bytes"`
+}
+
+// ResponseHeadersConfig is the configuration of the removal of the response headers leaking backend internals,
+// on top of the built-in list, the allowed headers are never removed
+type ResponseHeadersConfig struct {
+	Enabled              bool     `mapstructure:"enabled"`
+	Strip                []string `mapstructure:"strip"`
+	StripPrefixes        []string `mapstructure:"strip_prefixes"`
+	Allow                []string `mapstructure:"allow"`
+	InternalHostSuffixes []string `mapstructure:"internal_host_suffixes"`
+}
+
+// WellKnownConfig is the configuration of the /.well-known endpoints, each one is served only when configured
+type WellKnownConfig struct {
+	SecurityTxt             SecurityTxtConfig             `mapstructure:"security_txt"`
+	ChangePasswordURL       string                        `mapstructure:"change_password_url"`
+	OIDC                    OIDCDiscoveryConfig           `mapstructure:"oidc"`
+	AppleAppSiteAssociation AppleAppSiteAssociationConfig `mapstructure:"apple_app_site_association"`
+	AndroidAssetLinks       []AndroidAssetLinkConfig      `mapstructure:"android_asset_links"`
+}
+
+// AppleAppSiteAssociationConfig is the content of the apple-app-site-association of the iOS apps,
+// the paths prefixed with "NOT " are excluded from the universal links
+type AppleAppSiteAssociationConfig struct {
+	AppLinks       []AppleAppLinkConfig `mapstructure:"app_links"`
+	WebCredentials []string             `mapstructure:"web_credentials"`
+	AppClips       []string             `mapstructure:"app_clips"`
+}
+
+// AppleAppLinkConfig is the universal link paths handled by the apps
+type AppleAppLinkConfig struct {
+	AppIDs []string `mapstructure:"app_ids"`
+	Paths  []string `mapstructure:"paths"`
+}
+
+// AndroidAssetLinkConfig is the statement of an Android app, the relations default to the app links and the
+// shared credentials
+type AndroidAssetLinkConfig struct {
+	PackageName            string   `mapstructure:"package_name"`
+	SHA256CertFingerprints []string `mapstructure:"sha256_cert_fingerprints"`
+	Relations              []string `mapstructure:"relations"`
+}
+
+// SecurityTxtConfig is the content of the security.txt, the expiry is a fixed RFC 3339 date or
+// rolls forward by expires_in
+type SecurityTxtConfig struct {
+	Contacts           []string      `mapstructure:"contacts"`
+	Expires            string        `mapstructure:"expires"`
+	ExpiresIn          time.Duration `mapstructure:"expires_in"`
+	Encryption         []string      `mapstructure:"encryption"`
+	Acknowledgments    string        `mapstructure:"acknowledgments"`
+	PreferredLanguages []string      `mapstructure:"preferred_languages"`
+	Canonical          string        `mapstructure:"canonical"`
+	Policy             string        `mapstructure:"policy"`
+	Hiring             string        `mapstructure:"hiring"`
+}
+
+// OIDCDiscoveryConfig is the configuration of the discovery documents passed through from the issuer
+type OIDCDiscoveryConfig struct {
+	IssuerURL string        `mapstructure:"issuer_url"`
+	Documents []string      `mapstructure:"documents"`
+	CacheTTL  time.Duration `mapstructure:"cache_ttl"`
+}
+
+// CrawlersConfig is the configuration of the robots.txt and of the crawler throttling
+type CrawlersConfig struct {
+	RobotsTxt RobotsTxtConfig       `mapstructure:"robots_txt"`
+	Throttle  CrawlerThrottleConfig `mapstructure:"throttle"`
+}
+
+// RobotsTxtConfig is the content of the robots.txt
+type RobotsTxtConfig struct {
+	Groups   []RobotsGroupConfig `mapstructure:"groups"`
+	Sitemaps []string            `mapstructure:"sitemaps"`
+}
+
+// RobotsGroupConfig is a group of rules of the robots.txt
+type RobotsGroupConfig struct {
+	UserAgents []string `mapstructure:"user_agents"`
+	Allow      []string `mapstructure:"allow"`
+	Disallow   []string `mapstructure:"disallow"`
+	CrawlDelay int      `mapstructure:"crawl_delay"`
+}
+
+// CrawlerThrottleConfig is the configuration of the crawler rate limits, the generic user agents match the
+// crawlers not configured by name, limited per address with the default limits
+type CrawlerThrottleConfig struct {
+	Enabled             bool            `mapstructure:"enabled"`
+	GenericUserAgents   []string        `mapstructure:"generic_user_agents"`
+	RateLimit           float64         `mapstructure:"rate_limit"`
+	Burst               int             `mapstructure:"burst"`
+	UnverifiedRateLimit float64         `mapstructure:"unverified_rate_limit"`
+	UnverifiedBurst     int             `mapstructure:"unverified_burst"`
+	DeniedPaths         []string        `mapstructure:"denied_paths"`
+	Crawlers            []CrawlerConfig `mapstructure:"crawlers"`
+}
+
+// CrawlerConfig is a known crawler, verified by the address ranges it publishes
+type CrawlerConfig struct {
+	Name       string   `mapstructure:"name"`
+	UserAgents []string `mapstructure:"user_agents"`
+	IPRanges   []string `mapstructure:"ip_ranges"`
+	RateLimit  float64  `mapstructure:"rate_limit"`
+	Burst      int      `mapstructure:"burst"`
+}
+
+// NoiseConfig is the configuration of the noise requests answered before the router, on top of the built-in ones
+type NoiseConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	IconPaths    []string `mapstructure:"icon_paths"`
+	ScanSegments []string `mapstructure:"scan_segments"`
+	ScanSuffixes []string `mapstructure:"scan_suffixes"`
+}
+
+// CapabilitiesConfig is the configuration of the capability documents answered to OPTIONS requests
+type CapabilitiesConfig struct {
+	Enabled               bool                    `mapstructure:"enabled"`
+	DefaultAuthentication string                  `mapstructure:"default_authentication"`
+	Routes                []RouteCapabilityConfig `mapstructure:"routes"`
+}
+
+// RouteCapabilityConfig describes the methods of the routes matching the path pattern, every method when none is listed
+type RouteCapabilityConfig struct {
+	Path           string   `mapstructure:"path"`
+	Methods        []string `mapstructure:"methods"`
+	Authentication string   `mapstructure:"authentication"`
+	Roles          []string `mapstructure:"roles"`
+	RateLimit      float64  `mapstructure:"rate_limit"`
+	RateLimitBurst int      `mapstructure:"rate_limit_burst"`
+	Deprecated     bool     `mapstructure:"deprecated"`
+	Sunset         string   `mapstructure:"sunset"`
+	Replacement    string   `mapstructure:"replacement"`
+}
+
+// RegionConfig is the configuration of the region of the gateway and the regional upstream endpoints,
+// the default endpoint of every service is considered to be in the region of the gateway
+type RegionConfig struct {
+	Name             string                 `mapstructure:"name"`
+	AllowOverride    bool                   `mapstructure:"allow_override"`
+	FailoverCooldown time.Duration          `mapstructure:"failover_cooldown"`
+	Endpoints        []RegionEndpointConfig `mapstructure:"endpoints"`
+}
+
+// RegionEndpointConfig is an additional upstream endpoint of a service labeled with its region
+type RegionEndpointConfig struct {
+	Service string `mapstructure:"service"`
+	Region  string `mapstructure:"region"`
+	Host    string `mapstructure:"host"`
+	Port    string `mapstructure:"port"`
+	Socket  string `mapstructure:"socket"`
+}
+
+// TimeoutsConfig is the configuration of the deadlines of the API routes, static or adapted to their latency.
+// The clients may set the deadline of their request in the request timeout header, bounded by the max request
+// timeout, the header is ignored without one
+type TimeoutsConfig struct {
+	Enabled              bool                  `mapstructure:"enabled"`
+	Default              time.Duration         `mapstructure:"default"`
+	Routes               []RouteTimeoutConfig  `mapstructure:"routes"`
+	Adaptive             AdaptiveTimeoutConfig `mapstructure:"adaptive"`
+	RequestTimeoutHeader string                `mapstructure:"request_timeout_header"`
+	MaxRequestTimeout    time.Duration         `mapstructure:"max_request_timeout"`
+}
+
+// RouteTimeoutConfig is the static deadline of a route pattern
+type RouteTimeoutConfig struct {
+	Path    string        `mapstructure:"path"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// AdaptiveTimeoutConfig sets the deadlines of the routes from a percentile of their rolling latency plus a margin,
+// the static deadlines apply until a route has enough samples
+type AdaptiveTimeoutConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Percentile  float64       `mapstructure:"percentile"`
+	Margin      time.Duration `mapstructure:"margin"`
+	MarginRatio float64       `mapstructure:"margin_ratio"`
+	Min         time.Duration `mapstructure:"min"`
+	Max         time.Duration `mapstructure:"max"`
+	WindowSize  int           `mapstructure:"window_size"`
+	MinSamples  int           `mapstructure:"min_samples"`
+}
+
+// RateLimitsConfig is the configuration of the token bucket limits of the route groups, the clients using the
+// warning percentage of the burst of a group are warned before they are limited
+type RateLimitsConfig struct {
+	Enabled           bool                   `mapstructure:"enabled"`
+	APIKeyHeader      string                 `mapstructure:"api_key_header"`
+	IdleTTL           time.Duration          `mapstructure:"idle_ttl"`
+	WarningPercentage float64                `mapstructure:"warning_percentage"`
+	Groups            []RateLimitGroupConfig `mapstructure:"groups"`
+	Costs             []RateLimitCostConfig  `mapstructure:"costs"`
+}
+
+// RateLimitGroupConfig limits the requests under the path prefix per client IP, API key, authenticated user or tenant,
+// only the requests of the listed tenants when tenants are set
+type RateLimitGroupConfig struct {
+	Name       string   `mapstructure:"name"`
+	PathPrefix string   `mapstructure:"path_prefix"`
+	Methods    []string `mapstructure:"methods"`
+	Key        string   `mapstructure:"key"`
+	Tenants    []string `mapstructure:"tenants"`
+	RateLimit  float64  `mapstructure:"rate_limit"`
+	Burst      int      `mapstructure:"burst"`
+}
+
+// RateLimitCostConfig is the number of tokens a request of the route takes from the buckets of its groups,
+// one for the routes without a cost, its path is the full route template
+type RateLimitCostConfig struct {
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+	Cost   int    `mapstructure:"cost"`
+}
+
+// FakeBackendsConfig runs the gateway against in-process fake backends for reproducible load tests
+type FakeBackendsConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	GatewayHost string        `mapstructure:"gateway_host"`
+	GatewayPort string        `mapstructure:"gateway_port"`
+	Latency     time.Duration `mapstructure:"latency"`
+	TokenTTL    time.Duration `mapstructure:"token_ttl"`
+}
+
+// CircuitBreakersConfig is the configuration of the circuit breakers of the backend service connections
+type CircuitBreakersConfig struct {
+	Enabled          bool                   `mapstructure:"enabled"`
+	FailureThreshold int                    `mapstructure:"failure_threshold"`
+	ResetTimeout     time.Duration          `mapstructure:"reset_timeout"`
+	HalfOpenRequests int                    `mapstructure:"half_open_requests"`
+	Services         []CircuitBreakerConfig `mapstructure:"services"`
+}
+
+// CircuitBreakerConfig overrides the circuit breaker settings of a service
+type CircuitBreakerConfig struct {
+	Service          string        `mapstructure:"service"`
+	FailureThreshold int           `mapstructure:"failure_threshold"`
+	ResetTimeout     time.Duration `mapstructure:"reset_timeout"`
+	HalfOpenRequests int           `mapstructure:"half_open_requests"`
+}
+
+// RetriesConfig is the configuration of the retries of the upstream calls, only the listed methods are retried
+// so they must be idempotent
+type RetriesConfig struct {
+	Enabled        bool                `mapstructure:"enabled"`
+	MaxRetries     int                 `mapstructure:"max_retries"`
+	InitialBackoff time.Duration       `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration       `mapstructure:"max_backoff"`
+	Methods        []RetryMethodConfig `mapstructure:"methods"`
+}
+
+// RetryMethodConfig enables the retries of a gRPC method, given by its full name, overriding the shared policy
+type RetryMethodConfig struct {
+	Method         string        `mapstructure:"method"`
+	MaxRetries     *int          `mapstructure:"max_retries"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+}
+
+// MetricsConfig is the configuration of the Prometheus metrics, served at the path to the scrapers
+// presenting the bearer token when one is set
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+	Token   string `mapstructure:"token"`
+}
+
+// TracingConfig is the configuration of the distributed tracing, the spans are exported over OTLP/HTTP
+// to the collector of the exporter, "otlp" or "jaeger"
+type TracingConfig struct {
+	Enabled       bool              `mapstructure:"enabled"`
+	ServiceName   string            `mapstructure:"service_name"`
+	Exporter      string            `mapstructure:"exporter"`
+	Endpoint      string            `mapstructure:"endpoint"`
+	Headers       map[string]string `mapstructure:"headers"`
+	SampleRatio   float64           `mapstructure:"sample_ratio"`
+	FlushInterval time.Duration     `mapstructure:"flush_interval"`
+	MaxQueueSize  int               `mapstructure:"max_queue_size"`
+}
+
+// WebSocketsConfig is the configuration of the WebSocket routes, bridged to a streaming gRPC method
+// or to a WebSocket backend
+type WebSocketsConfig struct {
+	Enabled          bool                   `mapstructure:"enabled"`
+	HandshakeTimeout time.Duration          `mapstructure:"handshake_timeout"`
+	MaxMessageSize   int                    `mapstructure:"max_message_size"`
+	PingInterval     time.Duration          `mapstructure:"ping_interval"`
+	Routes           []WebSocketRouteConfig `mapstructure:"routes"`
+}
+
+// WebSocketRouteConfig is a WebSocket route of the API, its backend is either the URL of a WebSocket service
+// or the bidirectional streaming gRPC method of an upstream, whose messages are exchanged as JSON
+type WebSocketRouteConfig struct {
+	Path    string `mapstructure:"path"`
+	Public  bool   `mapstructure:"public"`
+	Backend string `mapstructure:"backend"`
+	Service string `mapstructure:"service"`
+	Host    string `mapstructure:"host"`
+	Port    string `mapstructure:"port"`
+	Method  string `mapstructure:"method"`
+}
+
+// HooksConfig enables the hook modules compiled into the gateway, their hooks run in the order of the modules
+type HooksConfig struct {
+	Enabled bool               `mapstructure:"enabled"`
+	Modules []HookModuleConfig `mapstructure:"modules"`
+}
+
+// HookModuleConfig enables a registered hook module with its settings
+type HookModuleConfig struct {
+	Name     string            `mapstructure:"name"`
+	Settings map[string]string `mapstructure:"settings"`
+}
+
+// ValidationConfig bounds the size of the request bodies and validates the JSON bodies of routes against a JSON Schema.
+// The successful responses of the upstreams are validated against the response schema of their route, the
+// response mode either logs the violations or answers a bad gateway in place of the response
+type ValidationConfig struct {
+	Enabled      bool                    `mapstructure:"enabled"`
+	MaxBodySize  int64                   `mapstructure:"max_body_size"`
+	ResponseMode string                  `mapstructure:"response_mode"`
+	Routes       []ValidationRouteConfig `mapstructure:"routes"`
+}
+
+// ValidationRouteConfig is the JSON Schema files and the body size limit of a route, of any method when it is empty,
+// the response mode overrides the one of the validation for the route. The strict routes reject the request bodies
+// with fields their schema does not declare
+type ValidationRouteConfig struct {
+	Method         string `mapstructure:"method"`
+	Path           string `mapstructure:"path"`
+	Schema         string `mapstructure:"schema"`
+	Strict         bool   `mapstructure:"strict"`
+	MaxBodySize    int64  `mapstructure:"max_body_size"`
+	ResponseSchema string `mapstructure:"response_schema"`
+	ResponseMode   string `mapstructure:"response_mode"`
+}
+
+// APIKeysConfig is the configuration of the API keys authenticating the machine clients on the listed routes,
+// the keys are found in the static keys, a JSON file or a backend service
+type APIKeysConfig struct {
+	Enabled        bool           `mapstructure:"enabled"`
+	Header         string         `mapstructure:"header"`
+	Store          string         `mapstructure:"store"`
+	Keys           []APIKeyConfig `mapstructure:"keys"`
+	File           string         `mapstructure:"file"`
+	ReloadInterval time.Duration  `mapstructure:"reload_interval"`
+	ServiceURL     string         `mapstructure:"service_url"`
+	CacheTTL       time.Duration  `mapstructure:"cache_ttl"`
+	Routes         []string       `mapstructure:"routes"`
+}
+
+// APIKeyConfig is a static API key, only the hex SHA-256 hash of the key is configured
+type APIKeyConfig struct {
+	ID     string   `mapstructure:"id"`
+	Client string   `mapstructure:"client"`
+	Hash   string   `mapstructure:"hash"`
+	Roles  []string `mapstructure:"roles"`
+}
+
+// WASMFiltersConfig is the configuration of the experimental WebAssembly filters run on the requests and responses,
+// each instance of a filter is bounded by the memory pages and each call by the timeout, the instance is closed
+// once it expires
+type WASMFiltersConfig struct {
+	Enabled        bool               `mapstructure:"enabled"`
+	Filters        []WASMFilterConfig `mapstructure:"filters"`
+	MaxMemoryPages uint32             `mapstructure:"max_memory_pages"`
+	Timeout        time.Duration      `mapstructure:"timeout"`
+	FailOpen       bool               `mapstructure:"fail_open"`
+	ReloadInterval time.Duration      `mapstructure:"reload_interval"`
+}
+
+// WASMFilterConfig is a filter module run on the requests whose path starts with the prefix, on every request when it is empty
+type WASMFilterConfig struct {
+	Name       string `mapstructure:"name"`
+	File       string `mapstructure:"file"`
+	PathPrefix string `mapstructure:"path_prefix"`
+}
+
+// ScriptsConfig is the configuration of the Lua scripts run on the requests of routes, each run of a script
+// is bounded by the steps it executes and the timeout
+type ScriptsConfig struct {
+	Enabled        bool                `mapstructure:"enabled"`
+	Routes         []ScriptRouteConfig `mapstructure:"routes"`
+	MaxSteps       int                 `mapstructure:"max_steps"`
+	Timeout        time.Duration       `mapstructure:"timeout"`
+	FailOpen       bool                `mapstructure:"fail_open"`
+	ReloadInterval time.Duration       `mapstructure:"reload_interval"`
+}
+
+// ScriptRouteConfig is the Lua script file run on the requests of a route, of any method when it is empty
+type ScriptRouteConfig struct {
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+	File   string `mapstructure:"file"`
+}
+
+// PolicyBundlesConfig is the configuration of the versioned policy bundles of route access and rate limits
+// applied and rolled back through the admin API, the initial bundle is read from the file
+// or else built from the rate limit groups
+type PolicyBundlesConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	File       string `mapstructure:"file"`
+	MaxHistory int    `mapstructure:"max_history"`
+}
+
+// TokenRevocationConfig is the configuration of the list of revoked access tokens, keyed by their jti.
+// The redis store is shared by the gateway instances, the memory store learns the revocations published
+// on the redis channel by the authentication service; the interval prunes it and renews the subscription.
+// The access tokens are rejected when the list can not be read unless fail open lets them through.
+type TokenRevocationConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Store    string        `mapstructure:"store"`
+	Channel  string        `mapstructure:"channel"`
+	Interval time.Duration `mapstructure:"interval"`
+	FailOpen bool          `mapstructure:"fail_open"`
+}
+
+// CORSConfig is the configuration of the cross-origin requests allowed from the browsers, overridden per environment
+// by its configuration file. An origin may allow its subdomains with a wildcard such as https://*.example.com,
+// the max age lets the browsers cache the preflight responses.
+type CORSConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"`
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`
+	ExposedHeaders   []string      `mapstructure:"exposed_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"`
+}
+
+// ConfigDriftConfig is the configuration of the detection of the replicas running another configuration,
+// every replica reports the hash of its effective configuration to the redis key on each interval and
+// the reports older than the stale period are dropped as replicas that went away
+type ConfigDriftConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Key         string        `mapstructure:"key"`
+	Interval    time.Duration `mapstructure:"interval"`
+	StalePeriod time.Duration `mapstructure:"stale_period"`
+}
+
+// HealthConfig is the configuration of the liveness and readiness endpoints, the readiness checks every backend
+// with the gRPC health protocol, asking for the health service named by backend or else for the whole server
+type HealthConfig struct {
+	Enabled  bool              `mapstructure:"enabled"`
+	Timeout  time.Duration     `mapstructure:"timeout"`
+	Services map[string]string `mapstructure:"services"`
+}
+
+// UpstreamRoutesConfig is the configuration of the upstream services whose routes are declared instead of written,
+// every route maps a method and path of the API to a unary gRPC method called with JSON encoded messages
+type UpstreamRoutesConfig struct {
+	Enabled  bool                    `mapstructure:"enabled"`
+	Services []UpstreamServiceConfig `mapstructure:"services"`
+}
+
+// UpstreamServiceConfig is an upstream service and its declared routes
+type UpstreamServiceConfig struct {
+	Name   string                `mapstructure:"name"`
+	Host   string                `mapstructure:"host"`
+	Port   string                `mapstructure:"port"`
+	Routes []UpstreamRouteConfig `mapstructure:"routes"`
+}
+
+// UpstreamRouteConfig is a declared route, requiring authentication and the roles unless it is public
+type UpstreamRouteConfig struct {
+	Method     string   `mapstructure:"method"`
+	Path       string   `mapstructure:"path"`
+	GRPCMethod string   `mapstructure:"grpc_method"`
+	Public     bool     `mapstructure:"public"`
+	Roles      []string `mapstructure:"roles"`
+	Audience   string   `mapstructure:"audience"`
+}
+
+// DebugTokenConfig is the configuration of the token debugging endpoint, never served in production
+type DebugTokenConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// ConfigReloadConfig is the configuration of the runtime reload of the route definitions, rate limits and CORS settings,
+// polled on every interval when it is set and triggered by the admin route
+type ConfigReloadConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// TokenMintingConfig is the configuration of the staging endpoint minting tokens signed by a staging key the
+// authentication middleware trusts, the requests carry the admin key whose hex SHA-256 hash is configured
+type TokenMintingConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	KeysDirectory string        `mapstructure:"keys_directory"`
+	AdminKeyHash  string        `mapstructure:"admin_key_hash"`
+	DefaultTTL    time.Duration `mapstructure:"default_ttl"`
+	MaxTTL        time.Duration `mapstructure:"max_ttl"`
+}
+
+// OIDCConfig is the configuration of the tokens issued by external identity providers, such as Google or Auth0,
+// verified with the keys of their JWKS instead of the public key of the authentication service. The provider of a
+// token is selected by its iss claim, the keys are refreshed on every refresh interval and, at most once per
+// cooldown, when a token is signed by an unknown key
+type OIDCConfig struct {
+	Enabled         bool                 `mapstructure:"enabled"`
+	RefreshInterval time.Duration        `mapstructure:"refresh_interval"`
+	RefreshCooldown time.Duration        `mapstructure:"refresh_cooldown"`
+	Providers       []OIDCProviderConfig `mapstructure:"providers"`
+}
+
+// OIDCProviderConfig is an identity provider trusted for the tokens of its issuer holding one of the audiences,
+// usually the client IDs of the apps. The JWKS URL is discovered from the openid-configuration of the issuer unless
+// it is set, and the user ID is read from the sub claim unless another claim is set, prefixed with the name of the
+// provider. Only the identity claims of the tokens are kept, the authorization claims such as the roles are dropped
+// unless they are mapped from a claim of the provider to the claim of the gateway
+type OIDCProviderConfig struct {
+	Name        string            `mapstructure:"name"`
+	Issuer      string            `mapstructure:"issuer"`
+	Audiences   []string          `mapstructure:"audiences"`
+	JWKSURL     string            `mapstructure:"jwks_url"`
+	UserIDClaim string            `mapstructure:"user_id_claim"`
+	Claims      map[string]string `mapstructure:"claims"`
+}
+
+// TranscodingConfig is the configuration of the services called through the gateway without hand-written routes,
+// with JSON requests on the routes of their google.api.http annotations or with gRPC-Web from the browsers,
+// the services and their annotations are read from a descriptor set built with protoc --include_imports
+type TranscodingConfig struct {
+	Enabled       bool                      `mapstructure:"enabled"`
+	DescriptorSet string                    `mapstructure:"descriptor_set"`
+	HTTPRules     bool                      `mapstructure:"http_rules"`
+	GRPCWeb       bool                      `mapstructure:"grpc_web"`
+	Services      []TranscodedServiceConfig `mapstructure:"services"`
+}
+
+// TranscodedServiceConfig is a service of the descriptor set, by full name, and the address of its backend,
+// its methods require authentication, the roles and the audience unless it is public
+type TranscodedServiceConfig struct {
+	Name     string   `mapstructure:"name"`
+	Host     string   `mapstructure:"host"`
+	Port     string   `mapstructure:"port"`
+	Public   bool     `mapstructure:"public"`
+	Roles    []string `mapstructure:"roles"`
+	Audience string   `mapstructure:"audience"`
+}
+
+// QueryRedactionConfig is the configuration of the query strings kept out of the access log and the traces,
+// the values of those of the requests under a path prefix are hashed with the key, so equal values can still be
+// correlated, or the whole query string is dropped
+type QueryRedactionConfig struct {
+	Enabled bool                        `mapstructure:"enabled"`
+	HashKey string                      `mapstructure:"hash_key"`
+	Routes  []QueryRedactionRouteConfig `mapstructure:"routes"`
+}
+
+// QueryRedactionRouteConfig redacts the query strings of the requests under the path prefix, hash or drop
+type QueryRedactionRouteConfig struct {
+	PathPrefix string `mapstructure:"path_prefix"`
+	Mode       string `mapstructure:"mode"`
+}
+
+// RequestLogConfig is the configuration of the structured log line of every request, the JSON bodies are only
+// logged with debug bodies, the values of the redacted fields and the email addresses masked. The excluded paths
+// are path patterns, a trailing * matching the rest of the path
+type RequestLogConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	DebugBodies    bool     `mapstructure:"debug_bodies"`
+	MaxBodyBytes   int      `mapstructure:"max_body_bytes"`
+	RedactedFields []string `mapstructure:"redacted_fields"`
+	RedactEmails   bool     `mapstructure:"redact_emails"`
+	ExcludedPaths  []string `mapstructure:"excluded_paths"`
+}
+
+// ResponseSigningConfig is the configuration of the detached JWS signing the response bodies of the routes whose
+// responses the clients verify offline, the signing key is loaded from the keys directory and published with its key ID
+type ResponseSigningConfig struct {
+	Enabled       bool                         `mapstructure:"enabled"`
+	KeysDirectory string                       `mapstructure:"keys_directory"`
+	KeyID         string                       `mapstructure:"key_id"`
+	Header        string                       `mapstructure:"header"`
+	Routes        []ResponseSigningRouteConfig `mapstructure:"routes"`
+}
+
+// ResponseSigningRouteConfig signs the responses of the route, its path is the full route template
+type ResponseSigningRouteConfig struct {
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+}
+
+// EntitlementsConfig is the configuration of the subscriptions service gating the paid features, the
+// entitlements of a user are cached for the cache TTL
+type EntitlementsConfig struct {
+	ServiceConfig `mapstructure:",squash"`
+	CacheTTL      time.Duration            `mapstructure:"cache_ttl"`
+	Routes        []EntitlementRouteConfig `mapstructure:"routes"`
+}
+
+// EntitlementRouteConfig requires the entitlement on the route, its path is the full route template
+type EntitlementRouteConfig struct {
+	Method      string `mapstructure:"method"`
+	Path        string `mapstructure:"path"`
+	Entitlement string `mapstructure:"entitlement"`
+}
+
+// TenancyConfig is the configuration of the multi-tenant routing, the tenant of a request is read from a claim
+// of its access token and required on every authenticated request when required is set
+type TenancyConfig struct {
+	Enabled  bool           `mapstructure:"enabled"`
+	Claim    string         `mapstructure:"claim"`
+	Required bool           `mapstructure:"required"`
+	Tenants  []TenantConfig `mapstructure:"tenants"`
+}
+
+// TenantConfig overrides the routes and the upstream targets of a tenant
+type TenantConfig struct {
+	ID             string                 `mapstructure:"id"`
+	DisabledRoutes []TenantRouteConfig    `mapstructure:"disabled_routes"`
+	Upstreams      []TenantUpstreamConfig `mapstructure:"upstreams"`
+}
+
+// TenantRouteConfig is a route of a tenant override, its path is the full route template
+type TenantRouteConfig struct {
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+}
+
+// TenantUpstreamConfig is the dedicated upstream endpoint of a service for a tenant
+type TenantUpstreamConfig struct {
+	Service string `mapstructure:"service"`
+	Host    string `mapstructure:"host"`
+	Port    string `mapstructure:"port"`
+	Socket  string `mapstructure:"socket"`
+}
+
+// ResponseCacheConfig is the configuration of the cache of the responses of the GET routes, kept per path, query
+// and user in the memory of the gateway, bounded by the max entries, or in redis to share them between the instances.
+// The Cache-Control headers of the requests and the responses are honored, the default TTL applies to the
+// responses without a max age.
+type ResponseCacheConfig struct {
+	Enabled      bool                       `mapstructure:"enabled"`
+	Store        string                     `mapstructure:"store"`
+	MaxEntries   int                        `mapstructure:"max_entries"`
+	MaxBodyBytes int                        `mapstructure:"max_body_bytes"`
+	DefaultTTL   time.Duration              `mapstructure:"default_ttl"`
+	Routes       []ResponseCacheRouteConfig `mapstructure:"routes"`
+}
+
+// ResponseCacheRouteConfig caches the responses of a GET route, its path is the full route template. Its cached
+// responses are dropped once a request of one of the mutations succeeds
+type ResponseCacheRouteConfig struct {
+	Path          string                        `mapstructure:"path"`
+	TTL           time.Duration                 `mapstructure:"ttl"`
+	InvalidatedBy []ResponseCacheMutationConfig `mapstructure:"invalidated_by"`
+}
+
+// ResponseCacheMutationConfig is a route changing the responses of a cached route, its path is the full route template
+type ResponseCacheMutationConfig struct {
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+}
+
+// ShutdownConfig is the configuration of the graceful shutdown on SIGTERM: the readiness fails during the drain
+// delay so the load balancers stop routing to the gateway, then the listeners close and the requests in flight
+// finish within the grace period, before the buffers are flushed within the flush timeout
+type ShutdownConfig struct {
+	GracePeriod  time.Duration `mapstructure:"grace_period"`
+	DrainDelay   time.Duration `mapstructure:"drain_delay"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
 }
 
 // Load loads the configuration from the given path yml file
@@ -0,0 +1,194 @@
+package contract
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Types of the values of a response shape
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+// Kinds of the changes between two shapes, removals and type changes break the clients
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeType    = "changed"
+)
+
+// Shape maps the paths of a JSON response to the type of their values, the keys of the objects are joined
+// with a dot and the elements of the arrays are under []
+type Shape map[string]string
+
+// ShapeOf returns the shape of a JSON document
+func ShapeOf(document []byte) (Shape, error) {
+	var value interface{}
+	if err := json.Unmarshal(document, &value); err != nil {
+		return nil, fmt.Errorf("Could not parse the response: %v", err)
+	}
+	shape := make(Shape)
+	shape.add("", value)
+	return shape, nil
+}
+
+func (shape Shape) add(path string, value interface{}) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		shape[path] = TypeObject
+		for key, child := range typed {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			shape.add(childPath, child)
+		}
+	case []interface{}:
+		shape[path] = TypeArray
+		for _, element := range typed {
+			shape.add(path+"[]", element)
+		}
+	case string:
+		shape[path] = TypeString
+	case float64:
+		shape[path] = TypeNumber
+	case bool:
+		shape[path] = TypeBoolean
+	default:
+		if _, exists := shape[path]; !exists {
+			shape[path] = TypeNull
+		}
+	}
+}
+
+// Change is a difference between the recorded shape of a response and its current one
+type Change struct {
+	Kind   string
+	Path   string
+	Before string
+	After  string
+}
+
+// Breaking tells whether the change breaks the clients relying on the recorded shape
+func (change Change) Breaking() bool {
+	return change.Kind != ChangeAdded
+}
+
+// String describes the change the way it is acknowledged
+func (change Change) String() string {
+	path := change.Path
+	if path == "" {
+		path = "."
+	}
+	switch change.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("added %s %s", path, change.After)
+	case ChangeRemoved:
+		return fmt.Sprintf("removed %s %s", path, change.Before)
+	default:
+		return fmt.Sprintf("changed %s %s to %s", path, change.Before, change.After)
+	}
+}
+
+// Compare returns the changes from the recorded shape to the current one sorted by path, a value recorded
+// as null may take any type as the sample had nothing to tell about it
+func Compare(recorded, current Shape) []Change {
+	changes := []Change{}
+	for path, before := range recorded {
+		after, exists := current[path]
+		switch {
+		case !exists:
+			changes = append(changes, Change{Kind: ChangeRemoved, Path: path, Before: before})
+		case before != after && before != TypeNull:
+			changes = append(changes, Change{Kind: ChangeType, Path: path, Before: before, After: after})
+		}
+	}
+	for path, after := range current {
+		if _, exists := recorded[path]; !exists {
+			changes = append(changes, Change{Kind: ChangeAdded, Path: path, After: after})
+		}
+	}
+	sort.Slice(changes, func(first, second int) bool {
+		return changes[first].Path < changes[second].Path
+	})
+	return changes
+}
+
+// Acknowledgements are the breaking changes of the contracts accepted on purpose, per contract name
+type Acknowledgements map[string]map[string]bool
+
+// LoadAcknowledgements reads the acknowledged changes, one "<contract>: <change>" per line, a missing
+// file acknowledges nothing and the lines starting with # are comments
+func LoadAcknowledgements(path string) (Acknowledgements, error) {
+	acknowledgements := make(Acknowledgements)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return acknowledgements, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not open the acknowledged changes: %v", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, change, found := strings.Cut(text, ":")
+		if !found {
+			return nil, fmt.Errorf("The acknowledged change at line %d has no contract name", line)
+		}
+		name = strings.TrimSpace(name)
+		if acknowledgements[name] == nil {
+			acknowledgements[name] = make(map[string]bool)
+		}
+		acknowledgements[name][strings.TrimSpace(change)] = true
+	}
+	return acknowledgements, scanner.Err()
+}
+
+// Unacknowledged returns the breaking changes of the contract nobody acknowledged
+func (acknowledgements Acknowledgements) Unacknowledged(name string, changes []Change) []Change {
+	unacknowledged := []Change{}
+	for _, change := range changes {
+		if change.Breaking() && !acknowledgements[name][change.String()] {
+			unacknowledged = append(unacknowledged, change)
+		}
+	}
+	return unacknowledged
+}
+
+// LoadShape reads a recorded shape, nil when it was never recorded
+func LoadShape(path string) (Shape, error) {
+	document, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the recorded shape: %v", err)
+	}
+	shape := Shape{}
+	if err := json.Unmarshal(document, &shape); err != nil {
+		return nil, fmt.Errorf("Could not parse the recorded shape: %v", err)
+	}
+	return shape, nil
+}
+
+// Save records the shape, the paths are sorted so the diffs of the recorded shapes are readable
+func (shape Shape) Save(path string) error {
+	document, err := json.MarshalIndent(shape, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(document, '\n'), 0o644)
+}
@@ -0,0 +1,238 @@
+package contract
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/public"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/reference"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_reference"
+)
+
+// The recorded shapes are rewritten with go test ./internal/contract -update, which is refused while
+// a breaking change is not acknowledged in testdata/acknowledged.txt
+var update = flag.Bool("update", false, "record the current response shapes of the public routes")
+
+// publicContract is a public route answered with the sample replies of the upstream services
+type publicContract struct {
+	name    string
+	method  string
+	route   string
+	path    string
+	body    string
+	handler func(connection *SampleConnection) gin.HandlerFunc
+}
+
+func authenticationHandler(route func(ctx *gin.Context, client pb_authentication.AuthenticationServiceClient)) func(connection *SampleConnection) gin.HandlerFunc {
+	return func(connection *SampleConnection) gin.HandlerFunc {
+		client := pb_authentication.NewAuthenticationServiceClient(connection)
+		return func(ctx *gin.Context) { route(ctx, client) }
+	}
+}
+
+func referenceHandler(route func(service *reference.ServiceClient) gin.HandlerFunc) func(connection *SampleConnection) gin.HandlerFunc {
+	return func(connection *SampleConnection) gin.HandlerFunc {
+		client := pb_reference.NewReferenceServiceClient(connection)
+		return route(reference.NewServiceClient(client, &config.Config{ReferenceService: config.ReferenceServiceConfig{RefreshInterval: time.Hour}}))
+	}
+}
+
+var publicContracts = []publicContract{
+	{
+		name: "get_health", method: http.MethodGet, route: "/health", path: "/health",
+		handler: func(*SampleConnection) gin.HandlerFunc { return public.Health },
+	},
+	{
+		name: "post_user", method: http.MethodPost, route: "/user/", path: "/user/",
+		body:    `{"email":"user@example.com","password":"password","firstName":"First","lastName":"Last","dateOfBirth":{"seconds":631152000}}`,
+		handler: authenticationHandler(routes.Register),
+	},
+	{
+		name: "post_user_email_verification_token", method: http.MethodPost,
+		route: "/user/:userID/email/:verificationToken", path: "/user/user-id/email/token",
+		handler: authenticationHandler(routes.VerifyEmail),
+	},
+	{
+		name: "post_user_sessions", method: http.MethodPost, route: "/user/sessions", path: "/user/sessions",
+		body:    `{"email":"user@example.com","password":"password"}`,
+		handler: authenticationHandler(routes.Authenticate),
+	},
+	{
+		name: "post_user_firebase_sessions", method: http.MethodPost, route: "/user/firebase/sessions", path: "/user/firebase/sessions",
+		body:    `{"email":"user@example.com","firstName":"First","lastName":"Last","idToken":"token"}`,
+		handler: authenticationHandler(routes.AuthenticateWithFirebase),
+	},
+	{
+		name: "post_user_email_verification", method: http.MethodPost,
+		route: "/user/:userID/email/verification", path: "/user/user-id/email/verification",
+		handler: authenticationHandler(routes.ResendEmailVerification),
+	},
+	{
+		name: "post_user_password_reset", method: http.MethodPost, route: "/user/password/reset", path: "/user/password/reset",
+		body:    `{"email":"user@example.com"}`,
+		handler: authenticationHandler(routes.ForgotPassword),
+	},
+	{
+		name: "get_user_password_reset_verification", method: http.MethodGet,
+		route: "/user/:userID/password/reset-verification/:verificationToken", path: "/user/user-id/password/reset-verification/token",
+		handler: authenticationHandler(routes.VerifyResetPasswordToken),
+	},
+	{
+		name: "post_user_password_reset_token", method: http.MethodPost,
+		route: "/user/:userID/password/reset/:verificationToken", path: "/user/user-id/password/reset/token",
+		body:    `{"password":"password"}`,
+		handler: authenticationHandler(routes.ResetPassword),
+	},
+	{
+		name: "post_authentication_refresh", method: http.MethodPost, route: "/authentication/refresh", path: "/authentication/refresh",
+		handler: authenticationHandler(routes.RefreshToken),
+	},
+	{
+		name: "get_reference_countries", method: http.MethodGet, route: "/reference/countries", path: "/reference/countries",
+		handler: referenceHandler(func(service *reference.ServiceClient) gin.HandlerFunc { return service.ListCountries }),
+	},
+	{
+		name: "get_reference_locales", method: http.MethodGet, route: "/reference/locales", path: "/reference/locales",
+		handler: referenceHandler(func(service *reference.ServiceClient) gin.HandlerFunc { return service.ListLocales }),
+	},
+	{
+		name: "get_reference_plans", method: http.MethodGet, route: "/reference/plans", path: "/reference/plans",
+		handler: referenceHandler(func(service *reference.ServiceClient) gin.HandlerFunc { return service.ListPlans }),
+	},
+}
+
+// errorContract is the shape of the errors answered by the upstream routes
+var errorContract = publicContract{
+	name: "error", method: http.MethodPost, route: "/user/sessions", path: "/user/sessions",
+	body:    `{"email":"user@example.com","password":"password"}`,
+	handler: authenticationHandler(routes.Authenticate),
+}
+
+func serveContract(t *testing.T, contract publicContract, connection *SampleConnection) []byte {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(contract.method, contract.route, contract.handler(connection))
+	request := httptest.NewRequest(contract.method, contract.path, strings.NewReader(contract.body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if connection.Err == nil && recorder.Code >= http.StatusBadRequest {
+		t.Fatalf("The contract %s was answered %d: %s", contract.name, recorder.Code, recorder.Body.String())
+	}
+	return recorder.Body.Bytes()
+}
+
+func checkContract(t *testing.T, contract publicContract, connection *SampleConnection, acknowledgements Acknowledgements) {
+	current, err := ShapeOf(serveContract(t, contract, connection))
+	assert.NoError(t, err)
+	path := filepath.Join("testdata", contract.name+".json")
+	recorded, err := LoadShape(path)
+	assert.NoError(t, err)
+	if recorded == nil && !*update {
+		t.Fatalf("The %s contract has no recorded shape, record it with -update", contract.name)
+	}
+
+	changes := Compare(recorded, current)
+	for _, change := range acknowledgements.Unacknowledged(contract.name, changes) {
+		t.Errorf("Breaking change to the %s contract, acknowledge it with the line %q in testdata/acknowledged.txt: %s",
+			contract.name, contract.name+": "+change.String(), change)
+	}
+	if t.Failed() {
+		return
+	}
+	if *update {
+		assert.NoError(t, current.Save(path))
+		return
+	}
+	for _, change := range changes {
+		t.Logf("Compatible change to the %s contract, record it with -update: %s", contract.name, change)
+	}
+}
+
+func TestPublicContracts(t *testing.T) {
+	acknowledgements, err := LoadAcknowledgements(filepath.Join("testdata", "acknowledged.txt"))
+	assert.NoError(t, err)
+
+	for _, contract := range publicContracts {
+		t.Run(contract.name, func(t *testing.T) {
+			checkContract(t, contract, &SampleConnection{}, acknowledgements)
+		})
+	}
+	t.Run(errorContract.name, func(t *testing.T) {
+		checkContract(t, errorContract, &SampleConnection{Err: status.Error(codes.NotFound, "user not found")}, acknowledgements)
+	})
+
+	t.Run("Recorded_Shapes_Should_Belong_To_A_Contract", func(t *testing.T) {
+		names := map[string]bool{errorContract.name: true}
+		for _, contract := range publicContracts {
+			names[contract.name] = true
+		}
+		files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+		assert.NoError(t, err)
+
+		for _, file := range files {
+			if name := strings.TrimSuffix(filepath.Base(file), ".json"); !names[name] {
+				t.Errorf("The recorded shape %s belongs to no contract, remove it with the route", file)
+			}
+		}
+	})
+}
+
+func TestCompare(t *testing.T) {
+	recorded := Shape{"": TypeObject, "user": TypeObject, "user.email": TypeString, "user.age": TypeNumber, "tags": TypeNull}
+
+	t.Run("Compare_Should_Tell_The_Breaking_Changes", func(t *testing.T) {
+		current := Shape{"": TypeObject, "user": TypeObject, "user.age": TypeString, "user.name": TypeString, "tags": TypeArray}
+
+		changes := Compare(recorded, current)
+
+		assert.Equal(t, []Change{
+			{Kind: ChangeType, Path: "user.age", Before: TypeNumber, After: TypeString},
+			{Kind: ChangeRemoved, Path: "user.email", Before: TypeString},
+			{Kind: ChangeAdded, Path: "user.name", After: TypeString},
+		}, changes)
+		assert.True(t, changes[0].Breaking())
+		assert.True(t, changes[1].Breaking())
+		assert.False(t, changes[2].Breaking())
+	})
+
+	t.Run("Unacknowledged_Should_Skip_The_Acknowledged_Changes", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "acknowledged.txt")
+		assert.NoError(t, os.WriteFile(file, []byte("# Accepted changes\nprofile: removed user.email string\n"), 0o644))
+		acknowledgements, err := LoadAcknowledgements(file)
+		assert.NoError(t, err)
+		changes := Compare(recorded, Shape{"": TypeObject, "user": TypeObject, "user.age": TypeNumber, "tags": TypeNull})
+
+		assert.Empty(t, acknowledgements.Unacknowledged("profile", changes))
+		assert.Len(t, acknowledgements.Unacknowledged("session", changes), 1)
+	})
+
+	t.Run("ShapeOf_Should_Walk_The_Arrays", func(t *testing.T) {
+		shape, err := ShapeOf([]byte(`{"plans":[{"id":"basic","features":["ads"],"priceCents":0}]}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, Shape{
+			"":                   TypeObject,
+			"plans":              TypeArray,
+			"plans[]":            TypeObject,
+			"plans[].id":         TypeString,
+			"plans[].features":   TypeArray,
+			"plans[].features[]": TypeString,
+			"plans[].priceCents": TypeNumber,
+		}, shape)
+	})
+}
@@ -0,0 +1,88 @@
+package contract
+
+import (
+	"context"
+	"reflect"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxDepth bounds the sample of recursive messages
+const maxDepth = 8
+
+// Populate fills every exported field of the value with a sample, pointers are allocated, slices and maps
+// get one element, so the omitempty fields show in the response shapes
+func Populate(value interface{}) {
+	populate(reflect.ValueOf(value), 0)
+}
+
+func populate(value reflect.Value, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			if !value.CanSet() {
+				return
+			}
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		populate(value.Elem(), depth+1)
+	case reflect.Struct:
+		for index := 0; index < value.NumField(); index++ {
+			if value.Type().Field(index).PkgPath == "" {
+				populate(value.Field(index), depth+1)
+			}
+		}
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			value.SetBytes([]byte("sample"))
+			return
+		}
+		slice := reflect.MakeSlice(value.Type(), 1, 1)
+		populate(slice.Index(0), depth+1)
+		value.Set(slice)
+	case reflect.Map:
+		key := reflect.New(value.Type().Key()).Elem()
+		element := reflect.New(value.Type().Elem()).Elem()
+		populate(key, depth+1)
+		populate(element, depth+1)
+		entries := reflect.MakeMap(value.Type())
+		entries.SetMapIndex(key, element)
+		value.Set(entries)
+	case reflect.String:
+		value.SetString("sample")
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(1.5)
+	}
+}
+
+// SampleConnection answers every call with a populated reply, or with its error when it has one
+type SampleConnection struct {
+	Err error
+}
+
+var _ grpc.ClientConnInterface = &SampleConnection{}
+
+// Invoke populates the reply of the call
+func (connection *SampleConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	if connection.Err != nil {
+		return connection.Err
+	}
+	Populate(reply)
+	return nil
+}
+
+// NewStream is not supported, the contracts cover the unary calls
+func (connection *SampleConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Error(codes.Unimplemented, "Streams have no sample")
+}
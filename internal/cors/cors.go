@@ -0,0 +1,211 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// Headers of the cross-origin requests and responses
+const (
+	OriginHeader           = "Origin"
+	RequestMethodHeader    = "Access-Control-Request-Method"
+	RequestHeadersHeader   = "Access-Control-Request-Headers"
+	AllowOriginHeader      = "Access-Control-Allow-Origin"
+	AllowMethodsHeader     = "Access-Control-Allow-Methods"
+	AllowHeadersHeader     = "Access-Control-Allow-Headers"
+	AllowCredentialsHeader = "Access-Control-Allow-Credentials"
+	ExposeHeadersHeader    = "Access-Control-Expose-Headers"
+	MaxAgeHeader           = "Access-Control-Max-Age"
+)
+
+var defaultMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// wildcardOrigin allows the subdomains of a domain, for the scheme and port of the configured origin
+type wildcardOrigin struct {
+	prefix string
+	suffix string
+}
+
+// CORS answers the preflight requests of the allowed origins and adds the CORS headers to their responses
+type CORS struct {
+	allowAll         bool
+	origins          map[string]bool
+	wildcardOrigins  []wildcardOrigin
+	methods          map[string]bool
+	allowedMethods   string
+	headers          map[string]bool
+	allowAnyHeader   bool
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// NewCORS creates the CORS middleware of the configuration
+func NewCORS(configurations *config.Config) (*CORS, error) {
+	corsConfig := configurations.CORS
+	cors := &CORS{
+		origins:          make(map[string]bool),
+		methods:          make(map[string]bool),
+		headers:          make(map[string]bool),
+		exposedHeaders:   strings.Join(corsConfig.ExposedHeaders, ", "),
+		allowCredentials: corsConfig.AllowCredentials,
+	}
+	for _, origin := range corsConfig.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "*":
+			cors.allowAll = true
+		case strings.Contains(origin, "*"):
+			wildcard, err := parseWildcardOrigin(origin)
+			if err != nil {
+				return nil, err
+			}
+			cors.wildcardOrigins = append(cors.wildcardOrigins, wildcard)
+		default:
+			if _, err := parseOrigin(origin); err != nil {
+				return nil, err
+			}
+			cors.origins[origin] = true
+		}
+	}
+	if cors.allowAll && cors.allowCredentials {
+		return nil, fmt.Errorf("The CORS credentials can not be allowed to every origin")
+	}
+
+	configuredMethods := corsConfig.AllowedMethods
+	if len(configuredMethods) == 0 {
+		configuredMethods = defaultMethods
+	}
+	methods := make([]string, 0, len(configuredMethods))
+	for _, method := range configuredMethods {
+		method = strings.ToUpper(method)
+		cors.methods[method] = true
+		methods = append(methods, method)
+	}
+	cors.allowedMethods = strings.Join(methods, ", ")
+	for _, header := range corsConfig.AllowedHeaders {
+		if header == "*" {
+			cors.allowAnyHeader = true
+			continue
+		}
+		cors.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	if corsConfig.MaxAge > 0 {
+		cors.maxAge = strconv.Itoa(int(corsConfig.MaxAge.Seconds()))
+	}
+	return cors, nil
+}
+
+func parseOrigin(origin string) (*url.URL, error) {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Path != "" {
+		return nil, fmt.Errorf("Invalid CORS origin %s, expected a scheme and a host such as https://app.example.com", origin)
+	}
+	return parsed, nil
+}
+
+// parseWildcardOrigin parses an origin such as https://*.example.com or https://*.example.com:8443
+func parseWildcardOrigin(origin string) (wildcardOrigin, error) {
+	scheme, host, found := strings.Cut(origin, "://")
+	if !found || !strings.HasPrefix(host, "*.") || strings.Count(origin, "*") != 1 {
+		return wildcardOrigin{}, fmt.Errorf("Invalid CORS origin %s, a wildcard only stands for the subdomains such as https://*.example.com", origin)
+	}
+	if _, err := parseOrigin(scheme + "://" + strings.TrimPrefix(host, "*.")); err != nil {
+		return wildcardOrigin{}, err
+	}
+	return wildcardOrigin{prefix: scheme + "://", suffix: host[1:]}, nil
+}
+
+// allowsOrigin tells whether the origin is configured or is a subdomain of a wildcard origin
+func (cors *CORS) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if cors.allowAll || cors.origins[origin] {
+		return true
+	}
+	for _, wildcard := range cors.wildcardOrigins {
+		if !strings.HasPrefix(origin, wildcard.prefix) || !strings.HasSuffix(origin, wildcard.suffix) {
+			continue
+		}
+		subdomain := origin[len(wildcard.prefix) : len(origin)-len(wildcard.suffix)]
+		if subdomain != "" && !strings.ContainsAny(subdomain, "/:@?#") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders tells whether every header of the comma separated list is allowed
+func (cors *CORS) allowsHeaders(requestedHeaders string) bool {
+	if cors.allowAnyHeader {
+		return true
+	}
+	for _, header := range strings.Split(requestedHeaders, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !cors.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware answers the preflight requests and adds the CORS headers to the responses of the allowed origins,
+// the responses to the other origins are sent without them so the browsers block them
+func (cors *CORS) Middleware(ctx *gin.Context) {
+	origin := ctx.GetHeader(OriginHeader)
+	if origin == "" {
+		ctx.Next()
+		return
+	}
+	ctx.Writer.Header().Add("Vary", OriginHeader)
+	preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader(RequestMethodHeader) != ""
+	if !cors.allowsOrigin(origin) {
+		if preflight {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Next()
+		return
+	}
+
+	requestedHeaders := ctx.GetHeader(RequestHeadersHeader)
+	if preflight {
+		ctx.Writer.Header().Add("Vary", RequestMethodHeader)
+		ctx.Writer.Header().Add("Vary", RequestHeadersHeader)
+		if !cors.methods[strings.ToUpper(ctx.GetHeader(RequestMethodHeader))] || !cors.allowsHeaders(requestedHeaders) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+	}
+
+	if cors.allowAll && !cors.allowCredentials {
+		ctx.Header(AllowOriginHeader, "*")
+	} else {
+		ctx.Header(AllowOriginHeader, origin)
+	}
+	if cors.allowCredentials {
+		ctx.Header(AllowCredentialsHeader, "true")
+	}
+	if !preflight {
+		if cors.exposedHeaders != "" {
+			ctx.Header(ExposeHeadersHeader, cors.exposedHeaders)
+		}
+		ctx.Next()
+		return
+	}
+
+	ctx.Header(AllowMethodsHeader, cors.allowedMethods)
+	if requestedHeaders != "" {
+		ctx.Header(AllowHeadersHeader, requestedHeaders)
+	}
+	if cors.maxAge != "" {
+		ctx.Header(MaxAgeHeader, cors.maxAge)
+	}
+	ctx.AbortWithStatus(http.StatusNoContent)
+}
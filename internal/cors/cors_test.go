@@ -0,0 +1,128 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newCORSRouter(t *testing.T, corsConfig config.CORSConfig) *gin.Engine {
+	cors, err := NewCORS(&config.Config{CORS: corsConfig})
+	assert.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(cors.Middleware)
+	router.GET("/api/v1/user", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	return router
+}
+
+func serveCORS(router *gin.Engine, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/api/v1/user", nil)
+	if origin != "" {
+		request.Header.Set(OriginHeader, origin)
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestCORS(t *testing.T) {
+	corsConfig := config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"get", "post"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Token-Expires-In"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	t.Run("Middleware_Should_Answer_The_Preflight_Of_An_Allowed_Origin", func(t *testing.T) {
+		router := newCORSRouter(t, corsConfig)
+
+		recorder := serveCORS(router, http.MethodOptions, "https://app.example.com", map[string]string{
+			RequestMethodHeader:  http.MethodPost,
+			RequestHeadersHeader: "authorization, content-type",
+		})
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.Equal(t, "https://app.example.com", recorder.Header().Get(AllowOriginHeader))
+		assert.Equal(t, "GET, POST", recorder.Header().Get(AllowMethodsHeader))
+		assert.Equal(t, "authorization, content-type", recorder.Header().Get(AllowHeadersHeader))
+		assert.Equal(t, "true", recorder.Header().Get(AllowCredentialsHeader))
+		assert.Equal(t, "600", recorder.Header().Get(MaxAgeHeader))
+		assert.Equal(t, []string{OriginHeader, RequestMethodHeader, RequestHeadersHeader}, recorder.Header().Values("Vary"))
+	})
+
+	t.Run("Middleware_Should_Reject_The_Preflight_Of_A_Method_Or_Header_Not_Allowed", func(t *testing.T) {
+		router := newCORSRouter(t, corsConfig)
+
+		method := serveCORS(router, http.MethodOptions, "https://app.example.com", map[string]string{RequestMethodHeader: http.MethodDelete})
+		header := serveCORS(router, http.MethodOptions, "https://app.example.com", map[string]string{
+			RequestMethodHeader:  http.MethodGet,
+			RequestHeadersHeader: "X-Internal",
+		})
+
+		assert.Equal(t, http.StatusForbidden, method.Code)
+		assert.Equal(t, http.StatusForbidden, header.Code)
+		assert.Empty(t, header.Header().Get(AllowOriginHeader))
+	})
+
+	t.Run("Middleware_Should_Match_The_Wildcard_Subdomains", func(t *testing.T) {
+		router := newCORSRouter(t, corsConfig)
+
+		for origin, allowed := range map[string]bool{
+			"https://spa.example.org":          true,
+			"https://eu.spa.example.org":       true,
+			"https://example.org":              false,
+			"http://spa.example.org":           false,
+			"https://spa.example.org:8443":     false,
+			"https://evilexample.org":          false,
+			"https://spa.example.org.evil.com": false,
+		} {
+			recorder := serveCORS(router, http.MethodGet, origin, nil)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			if allowed {
+				assert.Equal(t, origin, recorder.Header().Get(AllowOriginHeader), origin)
+				assert.Equal(t, "X-Token-Expires-In", recorder.Header().Get(ExposeHeadersHeader))
+			} else {
+				assert.Empty(t, recorder.Header().Get(AllowOriginHeader), origin)
+			}
+		}
+	})
+
+	t.Run("Middleware_Should_Let_The_Requests_Without_Origin_Through", func(t *testing.T) {
+		recorder := serveCORS(newCORSRouter(t, corsConfig), http.MethodGet, "", nil)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Values("Vary"))
+	})
+
+	t.Run("Middleware_Should_Allow_Every_Origin_Without_Credentials", func(t *testing.T) {
+		recorder := serveCORS(newCORSRouter(t, config.CORSConfig{AllowedOrigins: []string{"*"}}), http.MethodGet, "https://any.example.net", nil)
+
+		assert.Equal(t, "*", recorder.Header().Get(AllowOriginHeader))
+	})
+
+	t.Run("NewCORS_Should_Reject_Invalid_Origins", func(t *testing.T) {
+		for _, corsConfig := range []config.CORSConfig{
+			{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			{AllowedOrigins: []string{"app.example.com"}},
+			{AllowedOrigins: []string{"https://app.*.example.com"}},
+			{AllowedOrigins: []string{"https://app.example.com/path"}},
+		} {
+			_, err := NewCORS(&config.Config{CORS: corsConfig})
+
+			assert.Error(t, err, corsConfig.AllowedOrigins[0])
+		}
+	})
+}
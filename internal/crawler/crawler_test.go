@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func TestRobotsTxt(t *testing.T) {
+	t.Run("RegisterRoutes_Should_Serve_The_Configured_Rules", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		RegisterRoutes(router, &config.Config{Crawlers: config.CrawlersConfig{RobotsTxt: config.RobotsTxtConfig{
+			Groups: []config.RobotsGroupConfig{
+				{UserAgents: []string{"*"}, Allow: []string{"/api/public/"}, Disallow: []string{"/api/"}},
+				{UserAgents: []string{"Bingbot"}, CrawlDelay: 10},
+			},
+			Sitemaps: []string{"https://example.com/sitemap.xml"},
+		}}})
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, RobotsTxtPath, nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, "User-agent: *\n"+
+			"Allow: /api/public/\n"+
+			"Disallow: /api/\n"+
+			"\n"+
+			"User-agent: Bingbot\n"+
+			"Crawl-delay: 10\n"+
+			"\n"+
+			"Sitemap: https://example.com/sitemap.xml\n", recorder.Body.String())
+	})
+}
+
+func newThrottledRouter(t *testing.T) *gin.Engine {
+	throttler, err := NewThrottler(&config.Config{Crawlers: config.CrawlersConfig{Throttle: config.CrawlerThrottleConfig{
+		GenericUserAgents:   []string{"bot"},
+		RateLimit:           0.001,
+		Burst:               2,
+		UnverifiedRateLimit: 0.001,
+		UnverifiedBurst:     1,
+		DeniedPaths:         []string{"/api/auth"},
+		Crawlers: []config.CrawlerConfig{
+			{Name: "googlebot", UserAgents: []string{"Googlebot"}, IPRanges: []string{"66.249.64.0/19"}, RateLimit: 0.001, Burst: 3},
+		},
+	}}})
+	assert.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(throttler.Middleware)
+	router.GET("/api/*path", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	return router
+}
+
+func crawl(router *gin.Engine, path, userAgent, clientIP string) int {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("User-Agent", userAgent)
+	request.RemoteAddr = clientIP + ":1234"
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestThrottler(t *testing.T) {
+	googlebot := "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+
+	t.Run("Middleware_Should_Limit_Verified_Crawlers_As_A_Whole", func(t *testing.T) {
+		router := newThrottledRouter(t)
+
+		assert.Equal(t, http.StatusOK, crawl(router, "/api/users", googlebot, "66.249.66.1"))
+		assert.Equal(t, http.StatusOK, crawl(router, "/api/users", googlebot, "66.249.66.2"))
+		assert.Equal(t, http.StatusOK, crawl(router, "/api/users", googlebot, "66.249.66.3"))
+		assert.Equal(t, http.StatusTooManyRequests, crawl(router, "/api/users", googlebot, "66.249.66.4"))
+	})
+
+	t.Run("Middleware_Should_Limit_Unverified_Crawlers_Per_Address", func(t *testing.T) {
+		router := newThrottledRouter(t)
+
+		assert.Equal(t, http.StatusOK, crawl(router, "/api/users", googlebot, "203.0.113.7"))
+		assert.Equal(t, http.StatusTooManyRequests, crawl(router, "/api/users", googlebot, "203.0.113.7"))
+		assert.Equal(t, http.StatusOK, crawl(router, "/api/users", googlebot, "66.249.66.1"))
+	})
+
+	t.Run("Middleware_Should_Limit_Generic_Crawlers_Per_Address", func(t *testing.T) {
+		router := newThrottledRouter(t)
+
+		assert.Equal(t, http.StatusOK, crawl(router, "/api/users", "SomeBot/1.0", "198.51.100.1"))
+		assert.Equal(t, http.StatusOK, crawl(router, "/api/users", "SomeBot/1.0", "198.51.100.1"))
+		assert.Equal(t, http.StatusTooManyRequests, crawl(router, "/api/users", "SomeBot/1.0", "198.51.100.1"))
+		assert.Equal(t, http.StatusOK, crawl(router, "/api/users", "SomeBot/1.0", "198.51.100.2"))
+	})
+
+	t.Run("Middleware_Should_Deny_Crawlers_The_Denied_Paths_Only", func(t *testing.T) {
+		router := newThrottledRouter(t)
+
+		assert.Equal(t, http.StatusForbidden, crawl(router, "/api/auth/login", googlebot, "66.249.66.1"))
+		for index := 0; index < 5; index++ {
+			assert.Equal(t, http.StatusOK, crawl(router, "/api/auth/login", "Mozilla/5.0 (Windows NT 10.0)", "198.51.100.1"))
+		}
+	})
+
+	t.Run("NewThrottler_Should_Reject_Invalid_Ranges", func(t *testing.T) {
+		_, err := NewThrottler(&config.Config{Crawlers: config.CrawlersConfig{Throttle: config.CrawlerThrottleConfig{
+			Crawlers: []config.CrawlerConfig{{Name: "googlebot", UserAgents: []string{"Googlebot"}, IPRanges: []string{"66.249"}}},
+		}}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// RobotsTxtPath is the path crawlers read their rules from
+const RobotsTxtPath = "/robots.txt"
+
+// RenderRobotsTxt builds the robots.txt of the configured groups and sitemaps
+func RenderRobotsTxt(robotsConfig config.RobotsTxtConfig) string {
+	builder := strings.Builder{}
+	for index, group := range robotsConfig.Groups {
+		if index > 0 {
+			builder.WriteString("\n")
+		}
+		for _, userAgent := range group.UserAgents {
+			fmt.Fprintf(&builder, "User-agent: %s\n", userAgent)
+		}
+		for _, path := range group.Allow {
+			fmt.Fprintf(&builder, "Allow: %s\n", path)
+		}
+		for _, path := range group.Disallow {
+			fmt.Fprintf(&builder, "Disallow: %s\n", path)
+		}
+		if group.CrawlDelay > 0 {
+			fmt.Fprintf(&builder, "Crawl-delay: %d\n", group.CrawlDelay)
+		}
+	}
+	if len(robotsConfig.Sitemaps) > 0 && len(robotsConfig.Groups) > 0 {
+		builder.WriteString("\n")
+	}
+	for _, sitemap := range robotsConfig.Sitemaps {
+		fmt.Fprintf(&builder, "Sitemap: %s\n", sitemap)
+	}
+	return builder.String()
+}
+
+// RegisterRoutes serves the robots.txt when it has any rule
+func RegisterRoutes(router *gin.Engine, configurations *config.Config) {
+	robotsConfig := configurations.Crawlers.RobotsTxt
+	if len(robotsConfig.Groups) == 0 && len(robotsConfig.Sitemaps) == 0 {
+		return
+	}
+	robotsTxt := []byte(RenderRobotsTxt(robotsConfig))
+	router.GET(RobotsTxtPath, func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "public, max-age=86400")
+		ctx.Data(http.StatusOK, "text/plain; charset=utf-8", robotsTxt)
+	})
+}
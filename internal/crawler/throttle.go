@@ -0,0 +1,173 @@
+package crawler
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// unverifiedKeyPrefix separates the limiters of the clients claiming to be a crawler from an unverified address
+const unverifiedKeyPrefix = "unverified:"
+
+type crawler struct {
+	name       string
+	userAgents []string
+	ipRanges   []*net.IPNet
+	rate       rate.Limit
+	burst      int
+}
+
+// Throttler rate limits the verified crawlers as a whole and the crawlers without address ranges per address,
+// the clients claiming to be a verified crawler from outside of its ranges are limited per address with the
+// unverified limits
+type Throttler struct {
+	crawlers        []*crawler
+	generic         *crawler
+	unverifiedRate  rate.Limit
+	unverifiedBurst int
+	deniedPaths     []string
+	limiters        map[string]*rate.Limiter
+	mtx             sync.Mutex
+}
+
+// NewThrottler creates the throttler of the configured crawlers
+func NewThrottler(configurations *config.Config) (*Throttler, error) {
+	throttleConfig := configurations.Crawlers.Throttle
+	throttler := &Throttler{
+		unverifiedRate:  rate.Limit(throttleConfig.UnverifiedRateLimit),
+		unverifiedBurst: throttleConfig.UnverifiedBurst,
+		deniedPaths:     throttleConfig.DeniedPaths,
+		limiters:        map[string]*rate.Limiter{},
+	}
+	for _, crawlerConfig := range throttleConfig.Crawlers {
+		if crawlerConfig.Name == "" || len(crawlerConfig.UserAgents) == 0 {
+			return nil, fmt.Errorf("Every crawler requires a name and user agents")
+		}
+		crawler := &crawler{
+			name:       crawlerConfig.Name,
+			userAgents: lowerCase(crawlerConfig.UserAgents),
+			rate:       rate.Limit(crawlerConfig.RateLimit),
+			burst:      crawlerConfig.Burst,
+		}
+		for _, ipRange := range crawlerConfig.IPRanges {
+			_, network, err := net.ParseCIDR(ipRange)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid IP range %s of crawler %s: %v", ipRange, crawlerConfig.Name, err)
+			}
+			crawler.ipRanges = append(crawler.ipRanges, network)
+		}
+		throttler.crawlers = append(throttler.crawlers, crawler)
+	}
+	if len(throttleConfig.GenericUserAgents) > 0 {
+		throttler.generic = &crawler{
+			name:       "generic",
+			userAgents: lowerCase(throttleConfig.GenericUserAgents),
+			rate:       rate.Limit(throttleConfig.RateLimit),
+			burst:      throttleConfig.Burst,
+		}
+	}
+	return throttler, nil
+}
+
+// Middleware rejects the crawler requests to the denied paths and the ones over the crawler limits
+func (throttler *Throttler) Middleware(ctx *gin.Context) {
+	crawler := throttler.match(ctx.Request.UserAgent())
+	if crawler == nil {
+		ctx.Next()
+		return
+	}
+	path := ctx.Request.URL.Path
+	for _, deniedPath := range throttler.deniedPaths {
+		if strings.HasPrefix(path, deniedPath) {
+			errors.Abort(ctx, errors.CrawlerDenied)
+			return
+		}
+	}
+
+	limiter := throttler.limiter(crawler, ctx.ClientIP())
+	reservation := limiter.Reserve()
+	if delay := reservation.Delay(); !reservation.OK() || delay > 0 {
+		reservation.Cancel()
+		if reservation.OK() {
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		}
+		errors.Abort(ctx, errors.TooManyRequests)
+		return
+	}
+	ctx.Next()
+}
+
+// match returns the configured crawler of the user agent, then the generic one
+func (throttler *Throttler) match(userAgent string) *crawler {
+	if userAgent == "" {
+		return nil
+	}
+	lowerUserAgent := strings.ToLower(userAgent)
+	for _, crawler := range throttler.crawlers {
+		if crawler.matches(lowerUserAgent) {
+			return crawler
+		}
+	}
+	if throttler.generic != nil && throttler.generic.matches(lowerUserAgent) {
+		return throttler.generic
+	}
+	return nil
+}
+
+// limiter returns the limiter of the crawler and the client address
+func (throttler *Throttler) limiter(crawler *crawler, clientIP string) *rate.Limiter {
+	key, limit, burst := crawler.name, crawler.rate, crawler.burst
+	switch {
+	case len(crawler.ipRanges) == 0:
+		key = crawler.name + ":" + clientIP
+	case !crawler.verified(net.ParseIP(clientIP)):
+		key, limit, burst = unverifiedKeyPrefix+clientIP, throttler.unverifiedRate, throttler.unverifiedBurst
+	}
+	throttler.mtx.Lock()
+	defer throttler.mtx.Unlock()
+	limiter, exists := throttler.limiters[key]
+	if !exists {
+		limiter = rate.NewLimiter(limit, burst)
+		throttler.limiters[key] = limiter
+	}
+	return limiter
+}
+
+func (crawler *crawler) matches(lowerUserAgent string) bool {
+	for _, userAgent := range crawler.userAgents {
+		if strings.Contains(lowerUserAgent, userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+// verified tells whether the address belongs to the ranges of the crawler
+func (crawler *crawler) verified(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipRange := range crawler.ipRanges {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerCase(values []string) []string {
+	lowerValues := make([]string, 0, len(values))
+	for _, value := range values {
+		lowerValues = append(lowerValues, strings.ToLower(value))
+	}
+	return lowerValues
+}
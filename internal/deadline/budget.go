@@ -0,0 +1,78 @@
+package deadline
+
+import (
+	"context"
+	"time"
+)
+
+// Stage is a step of a composite handler, either a single upstream call or parallel calls sharing the stage deadline
+type Stage struct {
+	Weight  float64
+	Minimum time.Duration
+}
+
+// Budget splits the remaining deadline of a request across the sequential stages of a composite handler,
+// so a slow dependency cannot consume the budget of the calls after it, its stages are taken in order
+type Budget struct {
+	ctx    context.Context
+	stages []Stage
+	next   int
+	now    func() time.Time
+}
+
+// NewBudget creates the budget of the stages run in order under the deadline of the context
+func NewBudget(ctx context.Context, stages ...Stage) *Budget {
+	return &Budget{ctx: ctx, stages: stages, now: time.Now}
+}
+
+// Next returns the context of the next stage, bounded by its weighted share of the remaining deadline,
+// at least its minimum while the minimums of the later stages are kept when the remaining deadline allows it,
+// the last stage and the stages of a context without deadline get the whole remaining deadline
+func (budget *Budget) Next() (context.Context, context.CancelFunc) {
+	index := budget.next
+	if budget.next < len(budget.stages) {
+		budget.next++
+	}
+
+	deadline, exists := budget.ctx.Deadline()
+	if !exists || index >= len(budget.stages)-1 {
+		return context.WithCancel(budget.ctx)
+	}
+	now := budget.now()
+	remaining := deadline.Sub(now)
+	if remaining <= 0 {
+		return context.WithCancel(budget.ctx)
+	}
+	return context.WithDeadline(budget.ctx, now.Add(budget.share(index, remaining)))
+}
+
+func (budget *Budget) share(index int, remaining time.Duration) time.Duration {
+	stage := budget.stages[index]
+	totalWeight := 0.0
+	laterMinimums := time.Duration(0)
+	for position, later := range budget.stages[index:] {
+		totalWeight += weight(later)
+		if position > 0 {
+			laterMinimums += later.Minimum
+		}
+	}
+	share := time.Duration(float64(remaining) * weight(stage) / totalWeight)
+	if share > remaining-laterMinimums {
+		share = remaining - laterMinimums
+	}
+	if share < stage.Minimum {
+		share = stage.Minimum
+	}
+	if share > remaining {
+		share = remaining
+	}
+	return share
+}
+
+// weight defaults the stages without weight to an even split
+func weight(stage Stage) float64 {
+	if stage.Weight <= 0 {
+		return 1
+	}
+	return stage.Weight
+}
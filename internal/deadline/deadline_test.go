@@ -0,0 +1,197 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func TestTimeouts(t *testing.T) {
+	newTimeouts := func(adaptive config.AdaptiveTimeoutConfig) *Timeouts {
+		return NewTimeouts(&config.Config{Timeouts: config.TimeoutsConfig{
+			Enabled:  true,
+			Default:  10 * time.Second,
+			Routes:   []config.RouteTimeoutConfig{{Path: "/media", Timeout: time.Minute}},
+			Adaptive: adaptive,
+		}})
+	}
+
+	t.Run("Timeout_Should_Use_The_Static_Timeouts", func(t *testing.T) {
+		timeouts := newTimeouts(config.AdaptiveTimeoutConfig{})
+
+		assert.Equal(t, time.Minute, timeouts.Timeout("/media"))
+		assert.Equal(t, 10*time.Second, timeouts.Timeout("/user/profile"))
+	})
+
+	t.Run("Timeout_Should_Use_The_Percentile_Plus_Margin_Once_There_Are_Enough_Samples", func(t *testing.T) {
+		timeouts := newTimeouts(config.AdaptiveTimeoutConfig{
+			Enabled: true, Percentile: 0.9, Margin: 50 * time.Millisecond, MarginRatio: 0.5, MinSamples: 10, WindowSize: 10,
+		})
+
+		for index := 1; index <= 9; index++ {
+			timeouts.Record("/media", time.Duration(index)*100*time.Millisecond)
+		}
+		assert.Equal(t, time.Minute, timeouts.Timeout("/media"))
+
+		timeouts.Record("/media", time.Second)
+		assert.Equal(t, 900*time.Millisecond+450*time.Millisecond+50*time.Millisecond, timeouts.Timeout("/media"))
+	})
+
+	t.Run("Timeout_Should_Be_Bounded_By_The_Minimum_And_Maximum", func(t *testing.T) {
+		timeouts := newTimeouts(config.AdaptiveTimeoutConfig{
+			Enabled: true, MinSamples: 1, WindowSize: 10, Min: 500 * time.Millisecond, Max: 5 * time.Second,
+		})
+
+		timeouts.Record("/user/profile", 10*time.Millisecond)
+		assert.Equal(t, 500*time.Millisecond, timeouts.Timeout("/user/profile"))
+
+		timeouts.Record("/media", time.Minute)
+		assert.Equal(t, 5*time.Second, timeouts.Timeout("/media"))
+	})
+
+	t.Run("Record_Should_Roll_The_Window", func(t *testing.T) {
+		timeouts := newTimeouts(config.AdaptiveTimeoutConfig{Enabled: true, Percentile: 1, MinSamples: 5, WindowSize: 5})
+
+		for index := 0; index < 5; index++ {
+			timeouts.Record("/media", 3*time.Second)
+		}
+		assert.Equal(t, 3*time.Second, timeouts.Timeout("/media"))
+		for index := 0; index < recomputeEvery; index++ {
+			timeouts.Record("/media", time.Second)
+		}
+		assert.Equal(t, time.Second, timeouts.Timeout("/media"))
+	})
+
+	t.Run("Middleware_Should_Set_The_Deadline_Of_The_Route", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(newTimeouts(config.AdaptiveTimeoutConfig{}).Middleware)
+		router.GET("/media", func(ctx *gin.Context) {
+			deadline, exists := ctx.Request.Context().Deadline()
+			assert.True(t, exists)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+			ctx.Status(http.StatusOK)
+		})
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/media", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	newClientTimeouts := func() *Timeouts {
+		return NewTimeouts(&config.Config{Timeouts: config.TimeoutsConfig{
+			Enabled:           true,
+			Default:           10 * time.Second,
+			MaxRequestTimeout: 30 * time.Second,
+		}})
+	}
+
+	t.Run("RequestTimeout_Should_Parse_The_Client_Timeout_Up_To_The_Maximum", func(t *testing.T) {
+		timeouts := newClientTimeouts()
+
+		for header, expected := range map[string]time.Duration{"2.5": 2500 * time.Millisecond, "500ms": 500 * time.Millisecond, "90": 30 * time.Second, "1h": 30 * time.Second} {
+			timeout, fromClient, err := timeouts.RequestTimeout(header)
+			assert.NoError(t, err)
+			assert.True(t, fromClient)
+			assert.Equal(t, expected, timeout)
+		}
+		for _, header := range []string{"0", "-1", "soon", "NaN"} {
+			_, _, err := timeouts.RequestTimeout(header)
+			assert.Error(t, err, header)
+		}
+		_, fromClient, err := newTimeouts(config.AdaptiveTimeoutConfig{}).RequestTimeout("5")
+		assert.NoError(t, err)
+		assert.False(t, fromClient)
+	})
+
+	t.Run("Middleware_Should_Answer_A_Gateway_Timeout_Once_The_Deadline_Passed", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(newClientTimeouts().Middleware)
+		router.GET("/media", func(ctx *gin.Context) {
+			<-ctx.Request.Context().Done()
+		})
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/media", nil)
+		request.Header.Set(DefaultRequestTimeoutHeader, "10ms")
+
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+		assert.JSONEq(t, `{"error":{
+			"code":"upstream_timeout",
+			"message":"The service did not answer in time",
+			"details":[{"type":"error_info","metadata":{"timeout":"10ms"}}]
+		}}`, recorder.Body.String())
+	})
+
+	t.Run("Middleware_Should_Reject_An_Invalid_Request_Timeout", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(newClientTimeouts().Middleware)
+		router.GET("/media", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/media", nil)
+		request.Header.Set(DefaultRequestTimeoutHeader, "soon")
+
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
+
+func TestBudget(t *testing.T) {
+	now := time.Now()
+	newBudget := func(timeout time.Duration, stages ...Stage) (*Budget, context.CancelFunc) {
+		ctx, cancel := context.WithDeadline(context.Background(), now.Add(timeout))
+		budget := NewBudget(ctx, stages...)
+		budget.now = func() time.Time { return now }
+		return budget, cancel
+	}
+	stageTimeout := func(budget *Budget) time.Duration {
+		stageContext, cancel := budget.Next()
+		defer cancel()
+		stageDeadline, exists := stageContext.Deadline()
+		assert.True(t, exists)
+		return stageDeadline.Sub(now)
+	}
+
+	t.Run("Next_Should_Split_The_Remaining_Deadline_By_Weight", func(t *testing.T) {
+		budget, cancel := newBudget(10*time.Second, Stage{Weight: 1}, Stage{Weight: 3}, Stage{Weight: 1})
+		defer cancel()
+
+		assert.Equal(t, 2*time.Second, stageTimeout(budget))
+		now = now.Add(time.Second)
+		assert.Equal(t, 6750*time.Millisecond, stageTimeout(budget))
+		assert.Equal(t, 9*time.Second, stageTimeout(budget))
+	})
+
+	t.Run("Next_Should_Keep_The_Minimums", func(t *testing.T) {
+		budget, cancel := newBudget(time.Second, Stage{Weight: 9, Minimum: 100 * time.Millisecond}, Stage{Weight: 1, Minimum: 400 * time.Millisecond})
+		defer cancel()
+
+		assert.Equal(t, 600*time.Millisecond, stageTimeout(budget))
+
+		budget, cancel = newBudget(time.Second, Stage{Weight: 1, Minimum: 300 * time.Millisecond}, Stage{Weight: 9})
+		defer cancel()
+
+		assert.Equal(t, 300*time.Millisecond, stageTimeout(budget))
+	})
+
+	t.Run("Next_Should_Not_Set_A_Deadline_Without_One", func(t *testing.T) {
+		budget := NewBudget(context.Background(), Stage{Weight: 1}, Stage{Weight: 1})
+
+		stageContext, cancel := budget.Next()
+		defer cancel()
+		_, exists := stageContext.Deadline()
+		assert.False(t, exists)
+	})
+}
@@ -0,0 +1,206 @@
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// DefaultRequestTimeoutHeader carries the deadline the client sets on its request, in seconds or as a duration
+const DefaultRequestTimeoutHeader = "Request-Timeout"
+
+// Defaults of the adaptive deadlines
+const (
+	defaultPercentile = 0.99
+	defaultWindowSize = 1000
+	defaultMinSamples = 100
+	// recomputeEvery is the number of samples a route records before its adaptive deadline is computed again
+	recomputeEvery = 10
+)
+
+// latencyWindow keeps the last latencies of a route and the deadline computed from them
+type latencyWindow struct {
+	samples       []time.Duration
+	next          int
+	full          bool
+	sinceComputed int
+	computed      time.Duration
+}
+
+// Timeouts sets the deadline of the requests of every route, the adaptive mode derives it from the rolling
+// latency of the route so the deadlines track the real behavior of the backends. The deadline is carried by the
+// request context to the gRPC calls of the handlers
+type Timeouts struct {
+	defaultTimeout       time.Duration
+	routes               map[string]time.Duration
+	adaptive             config.AdaptiveTimeoutConfig
+	requestTimeoutHeader string
+	maxRequestTimeout    time.Duration
+	windows              map[string]*latencyWindow
+	mtx                  sync.Mutex
+}
+
+// NewTimeouts creates the route deadlines of the configuration
+func NewTimeouts(configurations *config.Config) *Timeouts {
+	timeoutsConfig := configurations.Timeouts
+	timeouts := &Timeouts{
+		defaultTimeout:       timeoutsConfig.Default,
+		routes:               map[string]time.Duration{},
+		adaptive:             timeoutsConfig.Adaptive,
+		requestTimeoutHeader: timeoutsConfig.RequestTimeoutHeader,
+		maxRequestTimeout:    timeoutsConfig.MaxRequestTimeout,
+		windows:              map[string]*latencyWindow{},
+	}
+	if timeouts.requestTimeoutHeader == "" {
+		timeouts.requestTimeoutHeader = DefaultRequestTimeoutHeader
+	}
+	for _, route := range timeoutsConfig.Routes {
+		timeouts.routes[route.Path] = route.Timeout
+	}
+	if timeouts.adaptive.Percentile <= 0 || timeouts.adaptive.Percentile > 1 {
+		timeouts.adaptive.Percentile = defaultPercentile
+	}
+	if timeouts.adaptive.WindowSize <= 0 {
+		timeouts.adaptive.WindowSize = defaultWindowSize
+	}
+	if timeouts.adaptive.MinSamples <= 0 {
+		timeouts.adaptive.MinSamples = defaultMinSamples
+	}
+	if timeouts.adaptive.MinSamples > timeouts.adaptive.WindowSize {
+		timeouts.adaptive.MinSamples = timeouts.adaptive.WindowSize
+	}
+	return timeouts
+}
+
+// Middleware bounds the request context with the deadline of the client or else of its route, answers a gateway
+// timeout when the deadline passed without a response and records the latency of the route
+func (timeouts *Timeouts) Middleware(ctx *gin.Context) {
+	route := ctx.FullPath()
+	if route == "" {
+		ctx.Next()
+		return
+	}
+	timeout, fromClient, err := timeouts.RequestTimeout(ctx.GetHeader(timeouts.requestTimeoutHeader))
+	if err != nil {
+		errors.AbortWithMessage(ctx, errors.InvalidArgument, err.Error(), errors.FieldViolation(timeouts.requestTimeoutHeader, err.Error()))
+		return
+	}
+	if !fromClient {
+		timeout = timeouts.Timeout(route)
+	}
+	if timeout <= 0 {
+		ctx.Next()
+		return
+	}
+	requestContext, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+	defer cancel()
+	ctx.Request = ctx.Request.WithContext(requestContext)
+
+	start := time.Now()
+	ctx.Next()
+	if requestContext.Err() == context.DeadlineExceeded && !ctx.Writer.Written() {
+		errors.Abort(ctx, errors.UpstreamTimeout, errors.Metadata(errors.ErrorInfoDetail, map[string]string{
+			"timeout": timeout.String(),
+		}))
+	}
+	// The latencies bounded by the client deadlines would skew the adaptive deadline of the route
+	if timeouts.adaptive.Enabled && !fromClient {
+		timeouts.Record(route, time.Since(start))
+	}
+}
+
+// RequestTimeout parses the deadline of the request timeout header, in seconds or as a duration such as 500ms,
+// bounded by the max request timeout. It is not taken from the client without a max request timeout
+func (timeouts *Timeouts) RequestTimeout(header string) (time.Duration, bool, error) {
+	if header == "" || timeouts.maxRequestTimeout <= 0 {
+		return 0, false, nil
+	}
+	timeout, err := time.ParseDuration(header)
+	if seconds, parseErr := strconv.ParseFloat(header, 64); parseErr == nil {
+		// The seconds are bounded before the conversion so NaN and the huge values cannot overflow the duration
+		timeout, err = 0, nil
+		if seconds > 0 {
+			timeout = time.Duration(math.Min(seconds, timeouts.maxRequestTimeout.Seconds()) * float64(time.Second))
+		}
+	}
+	if err != nil || timeout <= 0 {
+		return 0, false, fmt.Errorf("The %s header must be a positive number of seconds or a duration", timeouts.requestTimeoutHeader)
+	}
+	if timeout > timeouts.maxRequestTimeout {
+		timeout = timeouts.maxRequestTimeout
+	}
+	return timeout, true, nil
+}
+
+// Timeout returns the adaptive deadline of the route once it has enough samples, otherwise its static one
+func (timeouts *Timeouts) Timeout(route string) time.Duration {
+	if timeouts.adaptive.Enabled {
+		timeouts.mtx.Lock()
+		window, exists := timeouts.windows[route]
+		computed := time.Duration(0)
+		if exists {
+			computed = window.computed
+		}
+		timeouts.mtx.Unlock()
+		if computed > 0 {
+			return computed
+		}
+	}
+	if timeout, exists := timeouts.routes[route]; exists {
+		return timeout
+	}
+	return timeouts.defaultTimeout
+}
+
+// Record adds the latency to the rolling window of the route, computing its deadline again every few samples
+func (timeouts *Timeouts) Record(route string, latency time.Duration) {
+	timeouts.mtx.Lock()
+	defer timeouts.mtx.Unlock()
+	window, exists := timeouts.windows[route]
+	if !exists {
+		window = &latencyWindow{samples: make([]time.Duration, timeouts.adaptive.WindowSize)}
+		timeouts.windows[route] = window
+	}
+	window.samples[window.next] = latency
+	window.next = (window.next + 1) % len(window.samples)
+	window.full = window.full || window.next == 0
+	window.sinceComputed++
+
+	count := window.next
+	if window.full {
+		count = len(window.samples)
+	}
+	if count < timeouts.adaptive.MinSamples || (window.computed > 0 && window.sinceComputed < recomputeEvery) {
+		return
+	}
+	window.sinceComputed = 0
+	window.computed = timeouts.adaptiveTimeout(window.samples[:count])
+}
+
+// adaptiveTimeout returns the percentile of the latencies plus the margins, bounded by the minimum and maximum
+func (timeouts *Timeouts) adaptiveTimeout(samples []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(math.Ceil(timeouts.adaptive.Percentile*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	percentile := sorted[index]
+	timeout := percentile + time.Duration(float64(percentile)*timeouts.adaptive.MarginRatio) + timeouts.adaptive.Margin
+	if timeouts.adaptive.Min > 0 && timeout < timeouts.adaptive.Min {
+		timeout = timeouts.adaptive.Min
+	}
+	if timeouts.adaptive.Max > 0 && timeout > timeouts.adaptive.Max {
+		timeout = timeouts.adaptive.Max
+	}
+	return timeout
+}
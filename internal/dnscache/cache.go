@@ -0,0 +1,192 @@
+package dnscache
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// unknownTTL is returned by the lookups that could not read the TTL of the answers
+const unknownTTL = time.Duration(-1)
+
+// LookupFunc resolves a host returning its addresses and the TTL of the answers
+type LookupFunc func(ctx context.Context, host string) ([]string, time.Duration, error)
+
+type entry struct {
+	addresses  []string
+	err        error
+	expiresAt  time.Time
+	lastGood   []string
+	lastGoodAt time.Time
+}
+
+// Cache resolves the upstream hosts keeping the answers for their TTL and
+// falling back to the last known good answer while the DNS is failing
+type Cache struct {
+	lookup             LookupFunc
+	minTTL             time.Duration
+	maxTTL             time.Duration
+	defaultTTL         time.Duration
+	negativeTTL        time.Duration
+	maxNegativeEntries int
+	staleTTL           time.Duration
+	lookupTimeout      time.Duration
+	entries            map[string]*entry
+	logger             commonLogger.Loggerer
+	now                func() time.Time
+	mtx                sync.Mutex
+}
+
+// NewCache creates the DNS cache of the configuration resolving through the system resolver
+func NewCache(configurations *config.Config) *Cache {
+	dnsConfig := configurations.DNS
+	return &Cache{
+		lookup:             SystemLookup,
+		minTTL:             dnsConfig.MinTTL,
+		maxTTL:             dnsConfig.MaxTTL,
+		defaultTTL:         dnsConfig.DefaultTTL,
+		negativeTTL:        dnsConfig.NegativeTTL,
+		maxNegativeEntries: dnsConfig.MaxNegativeEntries,
+		staleTTL:           dnsConfig.StaleTTL,
+		lookupTimeout:      dnsConfig.LookupTimeout,
+		entries:            make(map[string]*entry),
+		logger:             commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+		now:                time.Now,
+	}
+}
+
+// LookupHost returns the addresses of the host and for how long they remain valid
+func (cache *Cache) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, cache.maxTTL, nil
+	}
+	now := cache.now()
+	cache.mtx.Lock()
+	cached, exists := cache.entries[host]
+	if exists && now.Before(cached.expiresAt) {
+		addresses, err := cached.addresses, cached.err
+		cache.mtx.Unlock()
+		return addresses, cached.expiresAt.Sub(now), err
+	}
+	cache.mtx.Unlock()
+
+	lookupContext := ctx
+	if cache.lookupTimeout > 0 {
+		var cancel context.CancelFunc
+		lookupContext, cancel = context.WithTimeout(ctx, cache.lookupTimeout)
+		defer cancel()
+	}
+	addresses, ttl, err := cache.lookup(lookupContext, host)
+
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	if exists {
+		cached = &entry{lastGood: cached.lastGood, lastGoodAt: cached.lastGoodAt}
+	} else {
+		cached = &entry{}
+	}
+	cache.entries[host] = cached
+
+	if err == nil && len(addresses) > 0 {
+		ttl = cache.clampTTL(ttl)
+		cached.addresses = addresses
+		cached.expiresAt = now.Add(ttl)
+		cached.lastGood = addresses
+		cached.lastGoodAt = now
+		return addresses, ttl, nil
+	}
+	if err == nil {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+
+	// Transient failures serve the last known good answer, retried after the minimum TTL
+	if !isNotFound(err) && cached.lastGood != nil && now.Sub(cached.lastGoodAt) <= cache.staleTTL {
+		cache.logger.Warn(fmt.Sprintf("DNS lookup of %s failed, serving the last known good answer: %v", host, err))
+		cached.addresses = cached.lastGood
+		cached.expiresAt = now.Add(cache.minTTL)
+		return cached.lastGood, cache.minTTL, nil
+	}
+
+	cached.err = err
+	cached.expiresAt = now.Add(cache.negativeTTL)
+	cache.evictNegativeEntries(now)
+	return nil, cache.negativeTTL, err
+}
+
+// Prune drops the expired entries whose last good answer can no longer be served
+func (cache *Cache) Prune(now time.Time) {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	for host, cached := range cache.entries {
+		if now.Before(cached.expiresAt) {
+			continue
+		}
+		if cached.lastGood == nil || now.Sub(cached.lastGoodAt) > cache.staleTTL {
+			delete(cache.entries, host)
+		}
+	}
+}
+
+// Job prunes the cache on every maximum TTL
+func (cache *Cache) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "dns_prune",
+		Schedule: scheduler.Every(cache.maxTTL),
+		Run: func(ctx context.Context, now time.Time) error {
+			cache.Prune(now)
+			return nil
+		},
+	}
+}
+
+// clampTTL bounds the TTL of the answers, the default TTL is used when the lookup could not read it
+func (cache *Cache) clampTTL(ttl time.Duration) time.Duration {
+	if ttl == unknownTTL {
+		ttl = cache.defaultTTL
+	}
+	if ttl < cache.minTTL {
+		ttl = cache.minTTL
+	}
+	if cache.maxTTL > 0 && ttl > cache.maxTTL {
+		ttl = cache.maxTTL
+	}
+	return ttl
+}
+
+// evictNegativeEntries drops expired entries and the oldest failures over the negative entries limit
+func (cache *Cache) evictNegativeEntries(now time.Time) {
+	negativeHosts := []string{}
+	for host, cached := range cache.entries {
+		if cached.err == nil {
+			continue
+		}
+		if !now.Before(cached.expiresAt) && cached.lastGood == nil {
+			delete(cache.entries, host)
+			continue
+		}
+		negativeHosts = append(negativeHosts, host)
+	}
+	for cache.maxNegativeEntries > 0 && len(negativeHosts) > cache.maxNegativeEntries {
+		oldest := 0
+		for index, host := range negativeHosts {
+			if cache.entries[host].expiresAt.Before(cache.entries[negativeHosts[oldest]].expiresAt) {
+				oldest = index
+			}
+		}
+		delete(cache.entries, negativeHosts[oldest])
+		negativeHosts = append(negativeHosts[:oldest], negativeHosts[oldest+1:]...)
+	}
+}
+
+func isNotFound(err error) bool {
+	var dnsError *net.DNSError
+	return stdErrors.As(err, &dnsError) && dnsError.IsNotFound
+}
@@ -0,0 +1,286 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type fakeLookup struct {
+	addresses []string
+	ttl       time.Duration
+	err       error
+	calls     int
+	mtx       sync.Mutex
+}
+
+func (lookup *fakeLookup) lookup(ctx context.Context, host string) ([]string, time.Duration, error) {
+	lookup.mtx.Lock()
+	defer lookup.mtx.Unlock()
+	lookup.calls++
+	return lookup.addresses, lookup.ttl, lookup.err
+}
+
+func (lookup *fakeLookup) set(addresses []string, ttl time.Duration, err error) {
+	lookup.mtx.Lock()
+	defer lookup.mtx.Unlock()
+	lookup.addresses, lookup.ttl, lookup.err = addresses, ttl, err
+}
+
+func newTestCache(lookup *fakeLookup, now *time.Time) *Cache {
+	cache := NewCache(&config.Config{
+		Environment: "test",
+		DNS: config.DNSConfig{
+			MinTTL:             5 * time.Second,
+			MaxTTL:             5 * time.Minute,
+			DefaultTTL:         30 * time.Second,
+			NegativeTTL:        10 * time.Second,
+			MaxNegativeEntries: 2,
+			StaleTTL:           time.Hour,
+		},
+	})
+	cache.lookup = lookup.lookup
+	cache.now = func() time.Time { return *now }
+	return cache
+}
+
+func notFound(host string) error {
+	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func temporary(host string) error {
+	return &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+}
+
+func TestCache(t *testing.T) {
+	t.Run("LookupHost_Should_Cache_Answers_For_Their_TTL", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{addresses: []string{"10.0.0.1"}, ttl: time.Minute}
+		cache := newTestCache(lookup, &now)
+
+		addresses, validFor, err := cache.LookupHost(context.Background(), "auth.internal")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.1"}, addresses)
+		assert.Equal(t, time.Minute, validFor)
+
+		now = now.Add(30 * time.Second)
+		lookup.set([]string{"10.0.0.2"}, time.Minute, nil)
+		addresses, validFor, _ = cache.LookupHost(context.Background(), "auth.internal")
+		assert.Equal(t, []string{"10.0.0.1"}, addresses)
+		assert.Equal(t, 30*time.Second, validFor)
+		assert.Equal(t, 1, lookup.calls)
+
+		now = now.Add(31 * time.Second)
+		addresses, _, _ = cache.LookupHost(context.Background(), "auth.internal")
+		assert.Equal(t, []string{"10.0.0.2"}, addresses)
+		assert.Equal(t, 2, lookup.calls)
+	})
+
+	t.Run("LookupHost_Should_Clamp_TTLs", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{addresses: []string{"10.0.0.1"}, ttl: time.Second}
+		cache := newTestCache(lookup, &now)
+
+		_, validFor, _ := cache.LookupHost(context.Background(), "short.internal")
+		assert.Equal(t, 5*time.Second, validFor)
+
+		lookup.set([]string{"10.0.0.1"}, 24*time.Hour, nil)
+		_, validFor, _ = cache.LookupHost(context.Background(), "long.internal")
+		assert.Equal(t, 5*time.Minute, validFor)
+
+		lookup.set([]string{"10.0.0.1"}, unknownTTL, nil)
+		_, validFor, _ = cache.LookupHost(context.Background(), "unknown.internal")
+		assert.Equal(t, 30*time.Second, validFor)
+	})
+
+	t.Run("LookupHost_Should_Pass_Through_IP_Literals", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{}
+		cache := newTestCache(lookup, &now)
+
+		addresses, _, err := cache.LookupHost(context.Background(), "127.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"127.0.0.1"}, addresses)
+		assert.Equal(t, 0, lookup.calls)
+	})
+
+	t.Run("LookupHost_Should_Cache_Not_Found_Answers_For_The_Negative_TTL", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{err: notFound("missing.internal")}
+		cache := newTestCache(lookup, &now)
+
+		_, _, err := cache.LookupHost(context.Background(), "missing.internal")
+		assert.Error(t, err)
+		_, validFor, err := cache.LookupHost(context.Background(), "missing.internal")
+		assert.Error(t, err)
+		assert.Equal(t, 10*time.Second, validFor)
+		assert.Equal(t, 1, lookup.calls)
+
+		now = now.Add(11 * time.Second)
+		lookup.set([]string{"10.0.0.3"}, time.Minute, nil)
+		addresses, _, err := cache.LookupHost(context.Background(), "missing.internal")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.3"}, addresses)
+	})
+
+	t.Run("LookupHost_Should_Bound_The_Negative_Entries", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{err: notFound("missing")}
+		cache := newTestCache(lookup, &now)
+
+		for _, host := range []string{"a.internal", "b.internal", "c.internal"} {
+			cache.LookupHost(context.Background(), host)
+			now = now.Add(time.Second)
+		}
+		assert.Len(t, cache.entries, 2)
+		_, exists := cache.entries["a.internal"]
+		assert.False(t, exists)
+	})
+
+	t.Run("LookupHost_Should_Serve_The_Last_Good_Answer_On_Temporary_Failures", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{addresses: []string{"10.0.0.1"}, ttl: time.Minute}
+		cache := newTestCache(lookup, &now)
+		cache.LookupHost(context.Background(), "auth.internal")
+
+		now = now.Add(2 * time.Minute)
+		lookup.set(nil, 0, temporary("auth.internal"))
+		addresses, validFor, err := cache.LookupHost(context.Background(), "auth.internal")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.1"}, addresses)
+		assert.Equal(t, 5*time.Second, validFor)
+
+		now = now.Add(2 * time.Hour)
+		_, _, err = cache.LookupHost(context.Background(), "auth.internal")
+		assert.Error(t, err)
+	})
+
+	t.Run("LookupHost_Should_Not_Serve_The_Last_Good_Answer_When_The_Host_Is_Gone", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{addresses: []string{"10.0.0.1"}, ttl: time.Minute}
+		cache := newTestCache(lookup, &now)
+		cache.LookupHost(context.Background(), "auth.internal")
+
+		now = now.Add(2 * time.Minute)
+		lookup.set(nil, 0, notFound("auth.internal"))
+		_, _, err := cache.LookupHost(context.Background(), "auth.internal")
+		assert.Error(t, err)
+	})
+}
+
+func TestTTLRecorder(t *testing.T) {
+	buildAnswer := func(rcode dnsmessage.RCode, ttls ...uint32) []byte {
+		name := dnsmessage.MustNewName("auth.internal.")
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, RCode: rcode})
+		builder.StartQuestions()
+		builder.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET})
+		builder.StartAnswers()
+		for _, ttl := range ttls {
+			builder.AResource(
+				dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: ttl},
+				dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+			)
+		}
+		message, err := builder.Finish()
+		assert.NoError(t, err)
+		return message
+	}
+
+	t.Run("Record_Should_Keep_The_Lowest_Answer_TTL", func(t *testing.T) {
+		recorder := &ttlRecorder{ttl: unknownTTL}
+		recorder.record(buildAnswer(dnsmessage.RCodeSuccess, 120, 60))
+		recorder.record(buildAnswer(dnsmessage.RCodeSuccess, 90))
+		assert.Equal(t, time.Minute, recorder.get())
+	})
+
+	t.Run("Record_Should_Ignore_Failed_And_Invalid_Answers", func(t *testing.T) {
+		recorder := &ttlRecorder{ttl: unknownTTL}
+		recorder.record(buildAnswer(dnsmessage.RCodeNameError))
+		recorder.record([]byte{1, 2, 3})
+		assert.Equal(t, unknownTTL, recorder.get())
+	})
+}
+
+func mustParseTarget(t *testing.T, address string) *url.URL {
+	target, err := url.Parse(Scheme + ":///" + address)
+	assert.NoError(t, err)
+	return target
+}
+
+type fakeClientConnection struct {
+	resolver.ClientConn
+	states chan resolver.State
+	errors chan error
+}
+
+func (clientConnection *fakeClientConnection) UpdateState(state resolver.State) error {
+	clientConnection.states <- state
+	return nil
+}
+
+func (clientConnection *fakeClientConnection) ReportError(err error) {
+	clientConnection.errors <- err
+}
+
+func TestResolver(t *testing.T) {
+	t.Run("Build_Should_Update_The_Addresses_Of_The_Target", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{addresses: []string{"10.0.0.1", "10.0.0.2"}, ttl: time.Minute}
+		cache := newTestCache(lookup, &now)
+		clientConnection := &fakeClientConnection{states: make(chan resolver.State, 1), errors: make(chan error, 1)}
+
+		hostResolver, err := (&builder{cache: cache}).Build(
+			resolver.Target{URL: *mustParseTarget(t, "auth.internal:9090")},
+			clientConnection,
+			resolver.BuildOptions{},
+		)
+		assert.NoError(t, err)
+		defer hostResolver.Close()
+
+		select {
+		case state := <-clientConnection.states:
+			assert.Equal(t, []resolver.Address{{Addr: "10.0.0.1:9090"}, {Addr: "10.0.0.2:9090"}}, state.Addresses)
+		case <-time.After(time.Second):
+			t.Fatal("No state was reported")
+		}
+	})
+
+	t.Run("Build_Should_Report_Lookup_Errors", func(t *testing.T) {
+		now := time.Now()
+		lookup := &fakeLookup{err: errors.New("lookup failed")}
+		cache := newTestCache(lookup, &now)
+		clientConnection := &fakeClientConnection{states: make(chan resolver.State, 1), errors: make(chan error, 1)}
+
+		hostResolver, err := (&builder{cache: cache}).Build(
+			resolver.Target{URL: *mustParseTarget(t, "auth.internal:9090")},
+			clientConnection,
+			resolver.BuildOptions{},
+		)
+		assert.NoError(t, err)
+		defer hostResolver.Close()
+
+		select {
+		case err := <-clientConnection.errors:
+			assert.Error(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("No error was reported")
+		}
+	})
+
+	t.Run("Target_Should_Use_The_Scheme_Once_Registered", func(t *testing.T) {
+		assert.Equal(t, "auth.internal:9090", Target("auth.internal:9090"))
+		registered.Store(true)
+		defer registered.Store(false)
+		assert.Equal(t, "cacheddns:///auth.internal:9090", Target("auth.internal:9090"))
+	})
+}
@@ -0,0 +1,84 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ttlRecorder keeps the lowest TTL of the answers received during a lookup
+type ttlRecorder struct {
+	ttl time.Duration
+	mtx sync.Mutex
+}
+
+func (recorder *ttlRecorder) record(message []byte) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(message)
+	if err != nil || header.RCode != dnsmessage.RCodeSuccess {
+		return
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return
+	}
+	for {
+		answer, err := parser.AnswerHeader()
+		if err != nil {
+			return
+		}
+		ttl := time.Duration(answer.TTL) * time.Second
+		recorder.mtx.Lock()
+		if recorder.ttl == unknownTTL || ttl < recorder.ttl {
+			recorder.ttl = ttl
+		}
+		recorder.mtx.Unlock()
+		if err := parser.SkipAnswer(); err != nil {
+			return
+		}
+	}
+}
+
+func (recorder *ttlRecorder) get() time.Duration {
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+	return recorder.ttl
+}
+
+// recordingConn reads the TTLs of the UDP answers, it embeds the UDP connection so the resolver keeps
+// treating it as a packet connection
+type recordingConn struct {
+	*net.UDPConn
+	recorder *ttlRecorder
+}
+
+func (conn *recordingConn) Read(buffer []byte) (int, error) {
+	read, err := conn.UDPConn.Read(buffer)
+	if err == nil {
+		conn.recorder.record(buffer[:read])
+	}
+	return read, err
+}
+
+// SystemLookup resolves the host with the Go resolver, honouring resolv.conf search domains,
+// and reads the TTL of the UDP answers; answers over TCP or from the hosts file have an unknown TTL
+func SystemLookup(ctx context.Context, host string) ([]string, time.Duration, error) {
+	recorder := &ttlRecorder{ttl: unknownTTL}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			connection, err := (&net.Dialer{}).DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			if udpConnection, ok := connection.(*net.UDPConn); ok {
+				return &recordingConn{UDPConn: udpConnection, recorder: recorder}, nil
+			}
+			return connection, nil
+		},
+	}
+	addresses, err := resolver.LookupHost(ctx, host)
+	return addresses, recorder.get(), err
+}
@@ -0,0 +1,107 @@
+package dnscache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// Scheme is the gRPC target scheme resolved through the DNS cache
+const Scheme = "cacheddns"
+
+var registered atomic.Bool
+
+// Register makes the cache resolve the gRPC targets built with Target
+func Register(cache *Cache) {
+	resolver.Register(&builder{cache: cache})
+	registered.Store(true)
+}
+
+// Target returns the gRPC target of the address, resolved through the DNS cache once registered
+func Target(address string) string {
+	if !registered.Load() {
+		return address
+	}
+	return fmt.Sprintf("%s:///%s", Scheme, address)
+}
+
+type builder struct {
+	cache *Cache
+}
+
+func (builder *builder) Scheme() string {
+	return Scheme
+}
+
+// Build starts watching the host of the target, gRPC uses the endpoint as the TLS authority
+func (builder *builder) Build(target resolver.Target, clientConnection resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	host, port, err := net.SplitHostPort(target.Endpoint())
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s target %s: %v", Scheme, target.Endpoint(), err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher := &hostResolver{
+		cache:            builder.cache,
+		host:             host,
+		port:             port,
+		clientConnection: clientConnection,
+		resolveNow:       make(chan struct{}, 1),
+		cancel:           cancel,
+	}
+	go watcher.watch(ctx)
+	return watcher, nil
+}
+
+// hostResolver re-resolves the host whenever its cached answer expires or gRPC asks for it
+type hostResolver struct {
+	cache            *Cache
+	host             string
+	port             string
+	clientConnection resolver.ClientConn
+	resolveNow       chan struct{}
+	cancel           context.CancelFunc
+}
+
+func (watcher *hostResolver) watch(ctx context.Context) {
+	for {
+		addresses, validFor, err := watcher.cache.LookupHost(ctx, watcher.host)
+		if err != nil {
+			watcher.clientConnection.ReportError(err)
+		} else {
+			state := resolver.State{}
+			for _, address := range addresses {
+				state.Addresses = append(state.Addresses, resolver.Address{Addr: net.JoinHostPort(address, watcher.port)})
+			}
+			watcher.clientConnection.UpdateState(state)
+		}
+		if validFor <= 0 {
+			validFor = time.Second
+		}
+		timer := time.NewTimer(validFor)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-watcher.resolveNow:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// ResolveNow asks the watcher to resolve again, served from the cache while the answer is valid
+func (watcher *hostResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case watcher.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops watching the host
+func (watcher *hostResolver) Close() {
+	watcher.cancel()
+}
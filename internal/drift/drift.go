@@ -0,0 +1,254 @@
+package drift
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// ConfigComponent is the component of the fingerprint hashing the loaded configuration
+const ConfigComponent = "config"
+
+const (
+	defaultKey      = "qd-qpi-gateway:config:replicas"
+	defaultInterval = 30 * time.Second
+)
+
+// Report is the fingerprint of the effective configuration reported by a replica, with the version
+// of every component it hashes so a drift can be traced back to the component that differs
+type Report struct {
+	Replica     string            `json:"replica"`
+	Fingerprint string            `json:"fingerprint"`
+	Components  map[string]string `json:"components"`
+	ReportedAt  time.Time         `json:"reported_at"`
+}
+
+// Status is the fingerprint reported by every live replica, the expected fingerprint is the one
+// of most replicas and the divergent replicas report another one
+type Status struct {
+	Replica   string   `json:"replica"`
+	Drifted   bool     `json:"drifted"`
+	Expected  string   `json:"expected"`
+	Divergent []string `json:"divergent"`
+	Replicas  []Report `json:"replicas"`
+}
+
+// Detector reports the fingerprint of the replica to redis and compares it with the other replicas,
+// catching the rollouts where only some replicas picked up the new configuration
+type Detector struct {
+	client        redis.Clienter
+	key           string
+	replica       string
+	interval      time.Duration
+	stalePeriod   time.Duration
+	components    map[string]func() string
+	lastDivergent string
+	logger        commonLogger.Loggerer
+}
+
+// NewDetector creates the drift detector of the replica, identified by its hostname
+func NewDetector(client redis.Clienter, configurations *config.Config) (*Detector, error) {
+	driftConfig := configurations.ConfigDrift
+	if configurations.Redis.Address == "" {
+		return nil, fmt.Errorf("The config drift detection requires a redis address")
+	}
+	configHash, err := hashConfig(configurations)
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "gateway"
+	}
+	detector := &Detector{
+		client:      client,
+		key:         driftConfig.Key,
+		replica:     fmt.Sprintf("%s-%s", hostname, uuid.New().String()[:8]),
+		interval:    driftConfig.Interval,
+		stalePeriod: driftConfig.StalePeriod,
+		components:  map[string]func() string{ConfigComponent: func() string { return configHash }},
+		logger:      commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}
+	if detector.key == "" {
+		detector.key = defaultKey
+	}
+	if detector.interval <= 0 {
+		detector.interval = defaultInterval
+	}
+	if detector.stalePeriod < 2*detector.interval {
+		detector.stalePeriod = 4 * detector.interval
+	}
+	return detector, nil
+}
+
+// hashConfig hashes the configuration as loaded from the files and the environment
+func hashConfig(configurations *config.Config) (string, error) {
+	encoded, err := json.Marshal(configurations)
+	if err != nil {
+		return "", fmt.Errorf("Could not hash the configuration: %v", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Track adds a component changing at runtime to the fingerprint, such as the active policy bundle
+func (detector *Detector) Track(name string, version func() string) {
+	detector.components[name] = version
+}
+
+// Replica returns the identity of the replica
+func (detector *Detector) Replica() string {
+	return detector.replica
+}
+
+// Fingerprint returns the hash of the versions of every component and the versions
+func (detector *Detector) Fingerprint() (string, map[string]string) {
+	names := make([]string, 0, len(detector.components))
+	versions := make(map[string]string, len(detector.components))
+	for name, version := range detector.components {
+		names = append(names, name)
+		versions[name] = version()
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s=%s\n", name, versions[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], versions
+}
+
+// Report stores the fingerprint of the replica in the redis hash of the replicas
+func (detector *Detector) Report(ctx context.Context, now time.Time) error {
+	fingerprint, components := detector.Fingerprint()
+	encoded, err := json.Marshal(Report{
+		Replica:     detector.replica,
+		Fingerprint: fingerprint,
+		Components:  components,
+		ReportedAt:  now.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := detector.client.Do(ctx, "HSET", detector.key, detector.replica, string(encoded)); err != nil {
+		return fmt.Errorf("Could not report the config fingerprint: %v", err)
+	}
+	return nil
+}
+
+// Status compares the fingerprints of the live replicas, removing the reports of the replicas that went away
+func (detector *Detector) Status(ctx context.Context, now time.Time) (*Status, error) {
+	reply, err := detector.client.Do(ctx, "HGETALL", detector.key)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the config fingerprints: %v", err)
+	}
+	fields, err := redis.Strings(reply)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the config fingerprints: %v", err)
+	}
+
+	reports := []Report{}
+	stale := []string{}
+	for index := 0; index+1 < len(fields); index += 2 {
+		report := Report{}
+		if err := json.Unmarshal([]byte(fields[index+1]), &report); err != nil || now.Sub(report.ReportedAt) > detector.stalePeriod {
+			stale = append(stale, fields[index])
+			continue
+		}
+		reports = append(reports, report)
+	}
+	if len(stale) > 0 {
+		args := append([]string{"HDEL", detector.key}, stale...)
+		if _, err := detector.client.Do(ctx, args...); err != nil {
+			return nil, fmt.Errorf("Could not remove the stale config fingerprints: %v", err)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Replica < reports[j].Replica
+	})
+	return compare(detector.replica, reports), nil
+}
+
+// compare expects the fingerprint of most replicas, the lowest fingerprint when they are tied
+func compare(replica string, reports []Report) *Status {
+	status := &Status{Replica: replica, Divergent: []string{}, Replicas: reports}
+	counts := make(map[string]int)
+	for _, report := range reports {
+		counts[report.Fingerprint]++
+	}
+	for fingerprint, count := range counts {
+		if count > counts[status.Expected] || (count == counts[status.Expected] && fingerprint < status.Expected) {
+			status.Expected = fingerprint
+		}
+	}
+	for _, report := range reports {
+		if report.Fingerprint != status.Expected {
+			status.Divergent = append(status.Divergent, report.Replica)
+		}
+	}
+	status.Drifted = len(status.Divergent) > 0
+	return status
+}
+
+// check reports the fingerprint and warns once about every new set of divergent replicas
+func (detector *Detector) check(ctx context.Context, now time.Time) error {
+	if err := detector.Report(ctx, now); err != nil {
+		return err
+	}
+	status, err := detector.Status(ctx, now)
+	if err != nil {
+		return err
+	}
+	divergent := strings.Join(status.Divergent, ", ")
+	if divergent == detector.lastDivergent {
+		return nil
+	}
+	detector.lastDivergent = divergent
+	if status.Drifted {
+		detector.logger.Warn(fmt.Sprintf(
+			"The replicas %s run another configuration than the fingerprint %s of the other replicas", divergent, status.Expected,
+		))
+		return nil
+	}
+	detector.logger.Info(fmt.Sprintf("Every replica runs the configuration fingerprint %s", status.Expected))
+	return nil
+}
+
+// Job reports the fingerprint on start and then on every interval
+func (detector *Detector) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:       "config_drift",
+		Schedule:   scheduler.Every(detector.interval),
+		RunOnStart: true,
+		Run:        detector.check,
+	}
+}
+
+// StatusHandler answers with the fingerprints of the replicas and the ones diverging
+func (detector *Detector) StatusHandler(ctx *gin.Context) {
+	status, err := detector.Status(ctx.Request.Context(), time.Now())
+	if err != nil {
+		logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+		if loggerErr == nil {
+			logger.Error(err, "Could not compare the config fingerprints")
+		}
+		errors.Abort(ctx, errors.ServiceUnavailable)
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,133 @@
+package drift
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// fakeHashClient keeps the redis hashes in memory
+type fakeHashClient struct {
+	hashes map[string]map[string]string
+	mtx    sync.Mutex
+}
+
+func newFakeHashClient() *fakeHashClient {
+	return &fakeHashClient{hashes: make(map[string]map[string]string)}
+}
+
+func (client *fakeHashClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	hash, exists := client.hashes[args[1]]
+	if !exists {
+		hash = make(map[string]string)
+		client.hashes[args[1]] = hash
+	}
+	switch args[0] {
+	case "HSET":
+		hash[args[2]] = args[3]
+		return int64(1), nil
+	case "HDEL":
+		for _, field := range args[2:] {
+			delete(hash, field)
+		}
+		return int64(len(args) - 2), nil
+	}
+	fields := []interface{}{}
+	for field, value := range hash {
+		fields = append(fields, field, value)
+	}
+	return fields, nil
+}
+
+func (client *fakeHashClient) Get(ctx context.Context, key string) (string, error) { return "", nil }
+func (client *fakeHashClient) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return nil
+}
+func (client *fakeHashClient) Del(ctx context.Context, keys ...string) error { return nil }
+func (client *fakeHashClient) Close() error                                  { return nil }
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		Environment: "test",
+		Redis:       config.RedisConfig{Address: "localhost:6379"},
+		ConfigDrift: config.ConfigDriftConfig{Enabled: true, Interval: time.Minute},
+	}
+}
+
+func TestDetector(t *testing.T) {
+	t.Run("NewDetector_Without_Redis_Error", func(t *testing.T) {
+		_, err := NewDetector(newFakeHashClient(), &config.Config{ConfigDrift: config.ConfigDriftConfig{Enabled: true}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Fingerprint_Should_Change_With_The_Tracked_Components", func(t *testing.T) {
+		first, err := NewDetector(newFakeHashClient(), newTestConfig())
+		assert.NoError(t, err)
+		second, err := NewDetector(newFakeHashClient(), newTestConfig())
+		assert.NoError(t, err)
+		policyVersion := "v1"
+		second.Track("policy_bundle", func() string { return policyVersion })
+
+		firstFingerprint, _ := first.Fingerprint()
+		secondFingerprint, components := second.Fingerprint()
+		policyVersion = "v2"
+		changedFingerprint, _ := second.Fingerprint()
+
+		assert.NotEqual(t, firstFingerprint, secondFingerprint)
+		assert.NotEqual(t, secondFingerprint, changedFingerprint)
+		assert.Equal(t, "v1", components["policy_bundle"])
+		assert.Len(t, components[ConfigComponent], 64)
+	})
+
+	t.Run("Status_Should_Flag_The_Replicas_Running_Another_Configuration", func(t *testing.T) {
+		client := newFakeHashClient()
+		now := time.Now()
+		updatedConfig := newTestConfig()
+		updatedConfig.CORS.AllowedOrigins = []string{"https://app.example.com"}
+		detectors := []*Detector{}
+		for _, configurations := range []*config.Config{newTestConfig(), newTestConfig(), updatedConfig} {
+			detector, err := NewDetector(client, configurations)
+			assert.NoError(t, err)
+			assert.NoError(t, detector.Report(context.Background(), now))
+			detectors = append(detectors, detector)
+		}
+		expected, _ := detectors[0].Fingerprint()
+
+		status, err := detectors[0].Status(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.True(t, status.Drifted)
+		assert.Equal(t, expected, status.Expected)
+		assert.Equal(t, []string{detectors[2].Replica()}, status.Divergent)
+		assert.Len(t, status.Replicas, 3)
+	})
+
+	t.Run("Status_Should_Drop_The_Stale_Reports", func(t *testing.T) {
+		client := newFakeHashClient()
+		now := time.Now()
+		updatedConfig := newTestConfig()
+		updatedConfig.CORS.Enabled = true
+		current, err := NewDetector(client, newTestConfig())
+		assert.NoError(t, err)
+		gone, err := NewDetector(client, updatedConfig)
+		assert.NoError(t, err)
+		assert.NoError(t, gone.Report(context.Background(), now.Add(-time.Hour)))
+		assert.NoError(t, current.Report(context.Background(), now))
+
+		status, err := current.Status(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.False(t, status.Drifted)
+		assert.Empty(t, status.Divergent)
+		assert.Len(t, status.Replicas, 1)
+		assert.NotContains(t, client.hashes[defaultKey], gone.Replica())
+	})
+}
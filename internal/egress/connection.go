@@ -0,0 +1,144 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	commonTLS "github.com/quadev-ltd/qd-common/pkg/tls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/dnscache"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/unixsocket"
+)
+
+var current atomic.Pointer[Proxy]
+
+// connections are the upstream connections opened by the process, closed at once on shutdown. They are shared by
+// their target, so the routers rebuilt on a reload use the connections of the previous ones instead of dialing again
+var (
+	connections    []*grpc.ClientConn
+	sharedTargets  map[string]*grpc.ClientConn
+	connectionsMtx sync.Mutex
+)
+
+// Use routes the outbound traffic created afterwards through the proxy
+func Use(egressProxy *Proxy) {
+	current.Store(egressProxy)
+}
+
+// Current returns the proxy in use, nil when the traffic goes out directly
+func Current() *Proxy {
+	return current.Load()
+}
+
+// CreateServiceConnection connects to the service over its configured unix socket or to the TCP address, with the
+// TLS material of the service when it has its own
+func CreateServiceConnection(service, address string, tlsEnabled bool, configurations *config.Config) (*grpc.ClientConn, error) {
+	if socket, exists := configurations.UnixSockets.Upstreams[service]; exists && socket.Path != "" {
+		address, tlsEnabled = unixsocket.Target(socket.Path), socket.TLSEnabled
+		if !tlsEnabled {
+			return CreateGRPCConnection(address, false)
+		}
+	}
+	if upstreamTLS := CurrentTLS(); upstreamTLS != nil {
+		if serviceTLS, exists := upstreamTLS.Service(service); exists {
+			return sharedConnection(service+"|"+address, func() (*grpc.ClientConn, error) {
+				return createGRPCConnection(address, grpc.WithTransportCredentials(credentials.NewTLS(serviceTLS.Config(address))))
+			})
+		}
+	}
+	return CreateGRPCConnection(address, tlsEnabled)
+}
+
+// CreateGRPCConnection connects to an upstream service, through the proxy unless the address is an exception.
+// Proxied addresses are resolved by the proxy so they skip the DNS cache, unix socket targets are dialed directly.
+// The connection is shared with the later calls for the same address and kept until CloseConnections closes it.
+func CreateGRPCConnection(address string, tlsEnabled bool) (*grpc.ClientConn, error) {
+	return sharedConnection(fmt.Sprintf("%s|%t", address, tlsEnabled), func() (*grpc.ClientConn, error) {
+		transportOption := grpc.WithInsecure()
+		if tlsEnabled {
+			tlsConfig, err := commonTLS.CreateTLSConfig()
+			if err != nil {
+				return nil, fmt.Errorf("Could not create CA certificate pool: %v", err)
+			}
+			transportOption = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+		}
+		return createGRPCConnection(address, transportOption)
+	})
+}
+
+// sharedConnection returns the connection dialed for the target, dialing and keeping it the first time
+func sharedConnection(target string, dial func() (*grpc.ClientConn, error)) (*grpc.ClientConn, error) {
+	connectionsMtx.Lock()
+	defer connectionsMtx.Unlock()
+	if connection, exists := sharedTargets[target]; exists {
+		return connection, nil
+	}
+	connection, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	if sharedTargets == nil {
+		sharedTargets = map[string]*grpc.ClientConn{}
+	}
+	sharedTargets[target] = connection
+	connections = append(connections, connection)
+	return connection, nil
+}
+
+// CloseConnections closes the upstream connections opened by the process, once the requests are drained
+func CloseConnections(ctx context.Context) error {
+	connectionsMtx.Lock()
+	closed := connections
+	connections, sharedTargets = nil, nil
+	connectionsMtx.Unlock()
+	var closeErrors []error
+	for _, connection := range closed {
+		if err := connection.Close(); err != nil {
+			closeErrors = append(closeErrors, fmt.Errorf("Could not close the connection to %s: %v", connection.Target(), err))
+		}
+	}
+	return errors.Join(closeErrors...)
+}
+
+// ConnectionState is the connectivity state of an upstream connection
+type ConnectionState struct {
+	Target string `json:"target"`
+	State  string `json:"state"`
+}
+
+// ConnectionStates returns the states of the upstream connections opened by the process
+func ConnectionStates() []ConnectionState {
+	connectionsMtx.Lock()
+	defer connectionsMtx.Unlock()
+	states := make([]ConnectionState, 0, len(connections))
+	for _, connection := range connections {
+		states = append(states, ConnectionState{Target: connection.Target(), State: connection.GetState().String()})
+	}
+	return states
+}
+
+func createGRPCConnection(address string, transportOption grpc.DialOption) (*grpc.ClientConn, error) {
+	target, options := address, []grpc.DialOption{transportOption}
+	if !strings.HasPrefix(address, "unix:") {
+		target = dnscache.Target(address)
+		if egressProxy := Current(); egressProxy != nil && !egressProxy.Bypass(address) {
+			target = "passthrough:///" + address
+			options = append(options, grpc.WithContextDialer(func(ctx context.Context, target string) (net.Conn, error) {
+				return egressProxy.DialContext(ctx, "tcp", target)
+			}))
+		}
+	}
+	connection, err := grpc.Dial(target, options...)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to server: %v", err)
+	}
+	return connection, nil
+}
@@ -0,0 +1,152 @@
+package egress
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newTestProxy(t *testing.T, proxyURL string, noProxy ...string) *Proxy {
+	egressProxy, err := NewProxy(&config.Config{
+		EgressProxy: config.EgressProxyConfig{URL: proxyURL, NoProxy: noProxy, DialTimeout: time.Second},
+	})
+	assert.NoError(t, err)
+	return egressProxy
+}
+
+// startConnectProxy serves a single CONNECT tunnel and reports the proxy authorization received
+func startConnectProxy(t *testing.T, status int) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	authorizations := make(chan string, 1)
+	go func() {
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer connection.Close()
+		request, err := http.ReadRequest(bufio.NewReader(connection))
+		if err != nil {
+			return
+		}
+		authorizations <- request.Header.Get("Proxy-Authorization")
+		if status != http.StatusOK {
+			io.WriteString(connection, "HTTP/1.1 403 Forbidden\r\n\r\n")
+			return
+		}
+		upstream, err := net.Dial("tcp", request.Host)
+		if err != nil {
+			return
+		}
+		defer upstream.Close()
+		io.WriteString(connection, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go io.Copy(upstream, connection)
+		io.Copy(connection, upstream)
+	}()
+	return listener, authorizations
+}
+
+func startEchoServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer connection.Close()
+		io.Copy(connection, connection)
+	}()
+	return listener
+}
+
+func TestExceptions(t *testing.T) {
+	t.Run("Match_Should_Bypass_The_Configured_Destinations", func(t *testing.T) {
+		exceptions, err := ParseExceptions([]string{".svc.cluster.local", "*.corp.example", "10.0.0.0/8", "billing.example.com:443", "hooks.example.com"})
+		assert.NoError(t, err)
+
+		assert.True(t, exceptions.Match("auth.default.svc.cluster.local:9090"))
+		assert.True(t, exceptions.Match("corp.example:443"))
+		assert.True(t, exceptions.Match("api.corp.example:443"))
+		assert.True(t, exceptions.Match("10.1.2.3:9090"))
+		assert.True(t, exceptions.Match("billing.example.com:443"))
+		assert.True(t, exceptions.Match("HOOKS.example.com:8443"))
+		assert.True(t, exceptions.Match("localhost:9090"))
+		assert.True(t, exceptions.Match("127.0.0.1:9090"))
+
+		assert.False(t, exceptions.Match("billing.example.com:80"))
+		assert.False(t, exceptions.Match("notcorp.example:443"))
+		assert.False(t, exceptions.Match("11.1.2.3:9090"))
+		assert.False(t, exceptions.Match("api.example.com:443"))
+	})
+
+	t.Run("Match_Should_Bypass_Everything_With_A_Wildcard", func(t *testing.T) {
+		exceptions, err := ParseExceptions([]string{"*"})
+		assert.NoError(t, err)
+		assert.True(t, exceptions.Match("api.example.com:443"))
+	})
+
+	t.Run("ParseExceptions_Should_Reject_Empty_Zones", func(t *testing.T) {
+		_, err := ParseExceptions([]string{"."})
+		assert.Error(t, err)
+	})
+}
+
+func TestProxy(t *testing.T) {
+	t.Run("NewProxy_Should_Reject_Unsupported_Schemes", func(t *testing.T) {
+		_, err := NewProxy(&config.Config{EgressProxy: config.EgressProxyConfig{URL: "ftp://proxy:21"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("HTTPProxy_Should_Skip_The_Exceptions", func(t *testing.T) {
+		egressProxy := newTestProxy(t, "socks5://proxy.corp:1080", "internal.example.com")
+
+		proxyURL, err := egressProxy.HTTPProxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "hooks.slack.com"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "socks5://proxy.corp:1080", proxyURL.String())
+
+		proxyURL, err = egressProxy.HTTPProxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "internal.example.com"}})
+		assert.NoError(t, err)
+		assert.Nil(t, proxyURL)
+	})
+
+	t.Run("DialContext_Should_Tunnel_Through_The_HTTP_Proxy", func(t *testing.T) {
+		echoServer := startEchoServer(t)
+		defer echoServer.Close()
+		proxyListener, authorizations := startConnectProxy(t, http.StatusOK)
+		defer proxyListener.Close()
+		egressProxy := newTestProxy(t, "http://user:secret@"+proxyListener.Addr().String())
+		_, port, _ := net.SplitHostPort(echoServer.Addr().String())
+
+		connection, err := egressProxy.connect(context.Background(), &net.Dialer{}, net.JoinHostPort("127.0.0.1", port))
+		assert.NoError(t, err)
+		defer connection.Close()
+		assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", <-authorizations)
+
+		_, err = io.WriteString(connection, "ping")
+		assert.NoError(t, err)
+		reply := make([]byte, 4)
+		_, err = io.ReadFull(connection, reply)
+		assert.NoError(t, err)
+		assert.Equal(t, "ping", string(reply))
+	})
+
+	t.Run("DialContext_Should_Fail_When_The_Proxy_Refuses", func(t *testing.T) {
+		proxyListener, _ := startConnectProxy(t, http.StatusForbidden)
+		defer proxyListener.Close()
+		egressProxy := newTestProxy(t, "http://"+proxyListener.Addr().String())
+
+		_, err := egressProxy.connect(context.Background(), &net.Dialer{}, "api.example.com:443")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "403")
+	})
+}
@@ -0,0 +1,91 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+type exception struct {
+	host    string
+	zone    string
+	network *net.IPNet
+	port    string
+}
+
+// Exceptions are the destinations reached without the proxy, written as NO_PROXY entries:
+// a host, a ".zone" or "*.zone" suffix, an IP or a CIDR, each optionally followed by a port
+type Exceptions struct {
+	entries []exception
+	all     bool
+}
+
+// ParseExceptions parses the destinations that bypass the proxy
+func ParseExceptions(entries []string) (*Exceptions, error) {
+	exceptions := &Exceptions{}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			exceptions.all = true
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			exceptions.entries = append(exceptions.entries, exception{network: network})
+			continue
+		}
+		parsed := exception{}
+		if host, port, err := net.SplitHostPort(entry); err == nil {
+			entry, parsed.port = host, port
+		}
+		switch {
+		case strings.HasPrefix(entry, "*."):
+			parsed.zone = entry[1:]
+		case strings.HasPrefix(entry, "."):
+			parsed.zone = entry
+		default:
+			parsed.host = strings.Trim(entry, "[]")
+		}
+		if parsed.zone == "." || (parsed.zone == "" && parsed.host == "") {
+			return nil, fmt.Errorf("Invalid egress proxy exception: %s", entry)
+		}
+		exceptions.entries = append(exceptions.entries, parsed)
+	}
+	return exceptions, nil
+}
+
+// Match tells whether the host:port address bypasses the proxy
+func (exceptions *Exceptions) Match(address string) bool {
+	if exceptions.all {
+		return true
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() || host == "localhost" {
+		return true
+	}
+	for _, entry := range exceptions.entries {
+		if entry.port != "" && entry.port != port {
+			continue
+		}
+		switch {
+		case entry.network != nil:
+			if ip != nil && entry.network.Contains(ip) {
+				return true
+			}
+		case entry.zone != "":
+			if strings.HasSuffix(host, entry.zone) || host == entry.zone[1:] {
+				return true
+			}
+		case host == entry.host:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package egress
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+var supportedSchemes = map[string]bool{
+	"http":    true,
+	"https":   true,
+	"socks5":  true,
+	"socks5h": true,
+}
+
+// Proxy routes the outbound connections through the configured HTTP or SOCKS proxy
+type Proxy struct {
+	url         *url.URL
+	exceptions  *Exceptions
+	dialTimeout time.Duration
+}
+
+// NewProxy creates the egress proxy of the configuration
+func NewProxy(configurations *config.Config) (*Proxy, error) {
+	proxyURL, err := url.Parse(configurations.EgressProxy.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid egress proxy URL: %v", err)
+	}
+	if !supportedSchemes[proxyURL.Scheme] || proxyURL.Host == "" {
+		return nil, fmt.Errorf("Unsupported egress proxy URL: %s", proxyURL.Redacted())
+	}
+	exceptions, err := ParseExceptions(configurations.EgressProxy.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	return &Proxy{
+		url:         proxyURL,
+		exceptions:  exceptions,
+		dialTimeout: configurations.EgressProxy.DialTimeout,
+	}, nil
+}
+
+// Bypass tells whether the connections to the address are made directly
+func (egressProxy *Proxy) Bypass(address string) bool {
+	return egressProxy.exceptions.Match(address)
+}
+
+// HTTPProxy returns the proxy of the HTTP requests, it is meant to be the Proxy of an http.Transport
+func (egressProxy *Proxy) HTTPProxy(request *http.Request) (*url.URL, error) {
+	if egressProxy.Bypass(canonicalAddress(request.URL)) {
+		return nil, nil
+	}
+	return egressProxy.url, nil
+}
+
+// DialContext connects to the address through the proxy unless the address is an exception
+func (egressProxy *Proxy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: egressProxy.dialTimeout}
+	if egressProxy.Bypass(address) {
+		return dialer.DialContext(ctx, network, address)
+	}
+	switch egressProxy.url.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if egressProxy.url.User != nil {
+			password, _ := egressProxy.url.User.Password()
+			auth = &proxy.Auth{User: egressProxy.url.User.Username(), Password: password}
+		}
+		socksDialer, err := proxy.SOCKS5("tcp", egressProxy.url.Host, auth, dialer)
+		if err != nil {
+			return nil, fmt.Errorf("Could not create SOCKS dialer: %v", err)
+		}
+		return socksDialer.(proxy.ContextDialer).DialContext(ctx, network, address)
+	default:
+		return egressProxy.connect(ctx, dialer, address)
+	}
+}
+
+// connect opens a tunnel to the address with an HTTP CONNECT request
+func (egressProxy *Proxy) connect(ctx context.Context, dialer *net.Dialer, address string) (net.Conn, error) {
+	connection, err := dialer.DialContext(ctx, "tcp", canonicalAddress(egressProxy.url))
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to egress proxy: %v", err)
+	}
+	if egressProxy.url.Scheme == "https" {
+		tlsConnection := tls.Client(connection, &tls.Config{ServerName: egressProxy.url.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConnection.HandshakeContext(ctx); err != nil {
+			connection.Close()
+			return nil, fmt.Errorf("Could not establish TLS with egress proxy: %v", err)
+		}
+		connection = tlsConnection
+	}
+	if deadline, exists := ctx.Deadline(); exists {
+		connection.SetDeadline(deadline)
+		defer connection.SetDeadline(time.Time{})
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if egressProxy.url.User != nil {
+		password, _ := egressProxy.url.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(egressProxy.url.User.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := request.Write(connection); err != nil {
+		connection.Close()
+		return nil, fmt.Errorf("Could not send CONNECT to egress proxy: %v", err)
+	}
+	reader := bufio.NewReader(connection)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		connection.Close()
+		return nil, fmt.Errorf("Could not read CONNECT response of egress proxy: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		connection.Close()
+		return nil, fmt.Errorf("Egress proxy refused to connect to %s: %s", address, response.Status)
+	}
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: connection, reader: reader}, nil
+	}
+	return connection, nil
+}
+
+// bufferedConn keeps the bytes the proxy sent after the CONNECT response
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (connection *bufferedConn) Read(buffer []byte) (int, error) {
+	return connection.reader.Read(buffer)
+}
+
+func canonicalAddress(target *url.URL) string {
+	if target.Port() != "" {
+		return target.Host
+	}
+	port := "80"
+	if strings.HasSuffix(target.Scheme, "s") {
+		port = "443"
+	}
+	return net.JoinHostPort(target.Hostname(), port)
+}
@@ -0,0 +1,222 @@
+package egress
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// DefaultCAFile is the CA certificate verifying the upstream services without a CA file of their own
+const DefaultCAFile = "certs/ca.pem"
+
+const defaultTLSReloadInterval = 5 * time.Minute
+
+var currentTLS atomic.Pointer[UpstreamTLS]
+
+// UseTLS secures the connections to the services of the upstream TLS created afterwards with their own material
+func UseTLS(upstreamTLS *UpstreamTLS) {
+	currentTLS.Store(upstreamTLS)
+}
+
+// CurrentTLS returns the upstream TLS in use, nil when every service follows the central configuration
+func CurrentTLS() *UpstreamTLS {
+	return currentTLS.Load()
+}
+
+// UpstreamTLS is the TLS material of the upstream services configured with their own
+type UpstreamTLS struct {
+	services       map[string]*ServiceTLS
+	reloadInterval time.Duration
+}
+
+// NewUpstreamTLS loads the TLS material of the enabled upstream services of the configuration
+func NewUpstreamTLS(configurations *config.Config) (*UpstreamTLS, error) {
+	upstreamTLS := &UpstreamTLS{
+		services:       map[string]*ServiceTLS{},
+		reloadInterval: configurations.UpstreamTLS.ReloadInterval,
+	}
+	if upstreamTLS.reloadInterval <= 0 {
+		upstreamTLS.reloadInterval = defaultTLSReloadInterval
+	}
+	for service, serviceConfig := range configurations.UpstreamTLS.Services {
+		if !serviceConfig.Enabled {
+			continue
+		}
+		serviceTLS, err := NewServiceTLS(service, serviceConfig)
+		if err != nil {
+			return nil, err
+		}
+		upstreamTLS.services[service] = serviceTLS
+	}
+	return upstreamTLS, nil
+}
+
+// Service returns the TLS material of the service, false when the service has none of its own
+func (upstreamTLS *UpstreamTLS) Service(service string) (*ServiceTLS, bool) {
+	serviceTLS, exists := upstreamTLS.services[service]
+	return serviceTLS, exists
+}
+
+// Reload reads the changed files of every service again, the services failing keep their previous material
+func (upstreamTLS *UpstreamTLS) Reload() error {
+	var reloadErrors []error
+	for _, serviceTLS := range upstreamTLS.services {
+		if _, err := serviceTLS.Reload(); err != nil {
+			reloadErrors = append(reloadErrors, err)
+		}
+	}
+	return errors.Join(reloadErrors...)
+}
+
+// Job reloads the TLS material on every reload interval
+func (upstreamTLS *UpstreamTLS) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "upstream_tls_reload",
+		Schedule: scheduler.Every(upstreamTLS.reloadInterval),
+		Run: func(ctx context.Context, now time.Time) error {
+			return upstreamTLS.Reload()
+		},
+	}
+}
+
+// ServiceTLS is the TLS material of the connections to an upstream service. The connections read it on every
+// handshake, so the material reloaded from rotated files is used by the next connections without redialing
+type ServiceTLS struct {
+	service     string
+	files       config.UpstreamServiceTLSConfig
+	roots       *x509.CertPool
+	certificate *tls.Certificate
+	modTimes    map[string]time.Time
+	mtx         sync.RWMutex
+}
+
+// NewServiceTLS loads the TLS material of the service, it needs both the certificate and the key for mTLS
+func NewServiceTLS(service string, serviceConfig config.UpstreamServiceTLSConfig) (*ServiceTLS, error) {
+	if (serviceConfig.CertFile == "") != (serviceConfig.KeyFile == "") {
+		return nil, fmt.Errorf("The TLS of the %s upstream needs both a client certificate and key file", service)
+	}
+	if serviceConfig.CAFile == "" {
+		serviceConfig.CAFile = DefaultCAFile
+	}
+	serviceTLS := &ServiceTLS{service: service, files: serviceConfig}
+	if _, err := serviceTLS.Reload(); err != nil {
+		return nil, err
+	}
+	return serviceTLS, nil
+}
+
+// Mutual tells whether the gateway presents a client certificate to the service
+func (serviceTLS *ServiceTLS) Mutual() bool {
+	return serviceTLS.files.CertFile != ""
+}
+
+// Reload reads the files again when one of them was modified since the last load and returns whether the material
+// changed, the previous material is kept when the files can not be loaded
+func (serviceTLS *ServiceTLS) Reload() (bool, error) {
+	files := []string{serviceTLS.files.CAFile}
+	if serviceTLS.Mutual() {
+		files = append(files, serviceTLS.files.CertFile, serviceTLS.files.KeyFile)
+	}
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, fmt.Errorf("Could not read the TLS file %s of the %s upstream: %v", file, serviceTLS.service, err)
+		}
+		modTimes[file] = info.ModTime()
+	}
+	serviceTLS.mtx.RLock()
+	unchanged := serviceTLS.modTimes != nil
+	for file, modTime := range modTimes {
+		unchanged = unchanged && serviceTLS.modTimes[file].Equal(modTime)
+	}
+	serviceTLS.mtx.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	ca, err := os.ReadFile(serviceTLS.files.CAFile)
+	if err != nil {
+		return false, fmt.Errorf("Could not read the CA certificate of the %s upstream: %v", serviceTLS.service, err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return false, fmt.Errorf("No CA certificates found in %s for the %s upstream", serviceTLS.files.CAFile, serviceTLS.service)
+	}
+	var certificate *tls.Certificate
+	if serviceTLS.Mutual() {
+		keyPair, err := tls.LoadX509KeyPair(serviceTLS.files.CertFile, serviceTLS.files.KeyFile)
+		if err != nil {
+			return false, fmt.Errorf("Could not load the client key pair of the %s upstream: %v", serviceTLS.service, err)
+		}
+		certificate = &keyPair
+	}
+	serviceTLS.mtx.Lock()
+	defer serviceTLS.mtx.Unlock()
+	serviceTLS.roots, serviceTLS.certificate, serviceTLS.modTimes = roots, certificate, modTimes
+	return true, nil
+}
+
+// Config returns the TLS configuration of a connection to the address, verifying the server certificate against
+// the roots of the last reload and presenting the client certificate of the last reload
+func (serviceTLS *ServiceTLS) Config(address string) *tls.Config {
+	serverName := serviceTLS.files.ServerName
+	if serverName == "" {
+		serverName = "localhost"
+		if host, _, err := net.SplitHostPort(address); err == nil && !strings.HasPrefix(address, "unix:") {
+			serverName = host
+		}
+	}
+	return &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+		// The verification of the server certificate is done by VerifyConnection against the reloaded roots
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return serviceTLS.verify(serverName, state)
+		},
+		GetClientCertificate: serviceTLS.clientCertificate,
+	}
+}
+
+func (serviceTLS *ServiceTLS) verify(serverName string, state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("The %s upstream presented no certificate", serviceTLS.service)
+	}
+	serviceTLS.mtx.RLock()
+	roots := serviceTLS.roots
+	serviceTLS.mtx.RUnlock()
+	intermediates := x509.NewCertPool()
+	for _, certificate := range state.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return fmt.Errorf("The certificate of the %s upstream is not trusted: %v", serviceTLS.service, err)
+	}
+	return nil
+}
+
+func (serviceTLS *ServiceTLS) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	serviceTLS.mtx.RLock()
+	defer serviceTLS.mtx.RUnlock()
+	if serviceTLS.certificate == nil {
+		return &tls.Certificate{}, nil
+	}
+	return serviceTLS.certificate, nil
+}
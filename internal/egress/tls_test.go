@@ -0,0 +1,184 @@
+package egress
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// testAuthority issues the certificates of the TLS tests
+type testAuthority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	file        string
+}
+
+func newTestAuthority(t *testing.T, directory, name string) *testAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	file := filepath.Join(directory, name+".pem")
+	assert.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	return &testAuthority{certificate: certificate, key: key, file: file}
+}
+
+// issue writes a certificate and key of the name, valid for the loopback address
+func (authority *testAuthority) issue(t *testing.T, directory, name string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, authority.certificate, &key.PublicKey, authority.key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	certFile, keyFile := filepath.Join(directory, name+".pem"), filepath.Join(directory, name+"-key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+// startMutualTLSServer accepts a single connection requiring a client certificate of the authority and reports
+// its common name
+func startMutualTLSServer(t *testing.T, directory string, authority *testAuthority) (string, chan string) {
+	certFile, keyFile := authority.issue(t, directory, "server", 2)
+	keyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(authority.certificate)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	clients := make(chan string, 1)
+	go func() {
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer connection.Close()
+		tlsConnection := connection.(*tls.Conn)
+		if err := tlsConnection.Handshake(); err != nil {
+			clients <- ""
+			return
+		}
+		clients <- tlsConnection.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}()
+	return listener.Addr().String(), clients
+}
+
+func TestServiceTLS(t *testing.T) {
+	t.Run("Config_Should_Present_The_Client_Certificate", func(t *testing.T) {
+		directory := t.TempDir()
+		authority := newTestAuthority(t, directory, "ca")
+		address, clients := startMutualTLSServer(t, directory, authority)
+		certFile, keyFile := authority.issue(t, directory, "gateway", 3)
+		serviceTLS, err := NewServiceTLS("authentication", config.UpstreamServiceTLSConfig{
+			Enabled: true, CAFile: authority.file, CertFile: certFile, KeyFile: keyFile,
+		})
+		assert.NoError(t, err)
+
+		connection, err := tls.Dial("tcp", address, serviceTLS.Config(address))
+
+		assert.NoError(t, err)
+		defer connection.Close()
+		assert.Equal(t, "gateway", <-clients)
+		assert.True(t, serviceTLS.Mutual())
+	})
+
+	t.Run("Config_Should_Reject_The_Servers_Of_Another_Authority", func(t *testing.T) {
+		directory := t.TempDir()
+		address, _ := startMutualTLSServer(t, directory, newTestAuthority(t, directory, "ca"))
+		serviceTLS, err := NewServiceTLS("payment", config.UpstreamServiceTLSConfig{
+			Enabled: true, CAFile: newTestAuthority(t, directory, "other-ca").file,
+		})
+		assert.NoError(t, err)
+
+		_, err = tls.Dial("tcp", address, serviceTLS.Config(address))
+
+		assert.ErrorContains(t, err, "The certificate of the payment upstream is not trusted")
+	})
+
+	t.Run("Reload_Should_Read_The_Rotated_Files", func(t *testing.T) {
+		directory := t.TempDir()
+		authority := newTestAuthority(t, directory, "ca")
+		certFile, keyFile := authority.issue(t, directory, "gateway", 3)
+		serviceTLS, err := NewServiceTLS("media", config.UpstreamServiceTLSConfig{
+			Enabled: true, CAFile: authority.file, CertFile: certFile, KeyFile: keyFile,
+		})
+		assert.NoError(t, err)
+		reloaded, err := serviceTLS.Reload()
+		assert.NoError(t, err)
+		assert.False(t, reloaded)
+
+		authority.issue(t, directory, "gateway", 4)
+		rotatedAt := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(certFile, rotatedAt, rotatedAt))
+		reloaded, err = serviceTLS.Reload()
+
+		assert.NoError(t, err)
+		assert.True(t, reloaded)
+		certificate, err := serviceTLS.clientCertificate(nil)
+		assert.NoError(t, err)
+		leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), leaf.SerialNumber.Int64())
+	})
+
+	t.Run("Reload_Should_Keep_The_Material_When_The_Files_Are_Invalid", func(t *testing.T) {
+		directory := t.TempDir()
+		authority := newTestAuthority(t, directory, "ca")
+		serviceTLS, err := NewServiceTLS("search", config.UpstreamServiceTLSConfig{Enabled: true, CAFile: authority.file})
+		assert.NoError(t, err)
+		roots := serviceTLS.roots
+
+		assert.NoError(t, os.WriteFile(authority.file, []byte("not a certificate"), 0600))
+		rotatedAt := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(authority.file, rotatedAt, rotatedAt))
+		_, err = serviceTLS.Reload()
+
+		assert.ErrorContains(t, err, "No CA certificates found")
+		assert.Same(t, roots, serviceTLS.roots)
+	})
+
+	t.Run("NewServiceTLS_Should_Require_The_Key_Of_The_Client_Certificate", func(t *testing.T) {
+		_, err := NewServiceTLS("support", config.UpstreamServiceTLSConfig{Enabled: true, CertFile: "gateway.pem"})
+
+		assert.ErrorContains(t, err, "needs both a client certificate and key file")
+	})
+}
@@ -0,0 +1,153 @@
+package entitlement
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/routeregistry"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_subscription"
+)
+
+// cachedEntitlements are the entitlements of a user and when they were fetched
+type cachedEntitlements struct {
+	entitlements map[string]bool
+	fetchedAt    time.Time
+}
+
+// Checker gates the paid features on the entitlements the subscription service grants to the user
+type Checker struct {
+	client   pb_subscription.SubscriptionServiceClient
+	cacheTTL time.Duration
+	routes   map[string]string
+	cache    map[string]cachedEntitlements
+	mtx      sync.Mutex
+}
+
+// NewChecker creates the entitlement checker of the configured routes
+func NewChecker(client pb_subscription.SubscriptionServiceClient, configurations *config.Config) (*Checker, error) {
+	checker := &Checker{
+		client:   client,
+		cacheTTL: configurations.Entitlements.CacheTTL,
+		routes:   make(map[string]string),
+		cache:    make(map[string]cachedEntitlements),
+	}
+	for _, route := range configurations.Entitlements.Routes {
+		if route.Method == "" || !strings.HasPrefix(route.Path, "/") || route.Entitlement == "" {
+			return nil, fmt.Errorf(
+				"The entitlement route %s %s needs a method, a path starting with / and an entitlement",
+				route.Method, route.Path,
+			)
+		}
+		checker.routes[routeKey(route.Method, route.Path)] = route.Entitlement
+	}
+	return checker, nil
+}
+
+// RegisterChecker connects to the subscription service and checks the entitlements of the configured routes
+// once their requests are authenticated
+func RegisterChecker(
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*Checker, error) {
+	service := configurations.Entitlements.ServiceConfig
+	connection, err := routeregistry.ConnectService(
+		"subscription",
+		fmt.Sprintf("%s:%s", service.Host, service.Port),
+		centralConfig,
+		configurations,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc subscription service: %v", err)
+	}
+	checker, err := NewChecker(pb_subscription.NewSubscriptionServiceClient(connection), configurations)
+	if err != nil {
+		return nil, err
+	}
+	authenticationMiddleware.OnAuthenticated(checker.OnAuthenticated)
+	return checker, nil
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// OnAuthenticated requires the entitlement of the configured route of the authenticated request
+func (checker *Checker) OnAuthenticated(ctx *gin.Context, claims *commonJWT.TokenClaims) {
+	entitlement, gated := checker.routes[routeKey(ctx.Request.Method, ctx.FullPath())]
+	if !gated {
+		return
+	}
+	checker.check(ctx, claims, entitlement)
+}
+
+// RequireEntitlement blocks the users whose subscriptions do not include the entitlement, it runs after the
+// authentication of the route
+func (checker *Checker) RequireEntitlement(entitlement string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey))
+		claims, ok := claimsValue.(*commonJWT.TokenClaims)
+		if !exists || !ok {
+			errors.Abort(ctx, errors.Unauthenticated)
+			return
+		}
+		checker.check(ctx, claims, entitlement)
+		if ctx.IsAborted() {
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func (checker *Checker) check(ctx *gin.Context, claims *commonJWT.TokenClaims, entitlement string) {
+	entitlements, err := checker.Entitlements(ctx.Request.Context(), claims.UserID)
+	if err != nil {
+		if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil {
+			logger.Error(err, "Could not obtain the user entitlements")
+		}
+		errors.HandleError(ctx, err)
+		return
+	}
+	if !entitlements[entitlement] {
+		errors.Abort(ctx, errors.EntitlementRequired, errors.Metadata(errors.ErrorInfoDetail, map[string]string{
+			"entitlement": entitlement,
+		}))
+	}
+}
+
+// Entitlements returns the entitlements of the user, fetched from the subscription service once per cache TTL
+func (checker *Checker) Entitlements(ctx context.Context, userID string) (map[string]bool, error) {
+	now := time.Now()
+	checker.mtx.Lock()
+	cached, exists := checker.cache[userID]
+	if exists && now.Sub(cached.fetchedAt) <= checker.cacheTTL {
+		checker.mtx.Unlock()
+		return cached.entitlements, nil
+	}
+	delete(checker.cache, userID)
+	checker.mtx.Unlock()
+
+	response, err := checker.client.GetEntitlements(ctx, &pb_subscription.GetEntitlementsRequest{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	entitlements := make(map[string]bool, len(response.Entitlements))
+	for _, entitlement := range response.Entitlements {
+		entitlements[entitlement] = true
+	}
+	checker.mtx.Lock()
+	checker.cache[userID] = cachedEntitlements{entitlements: entitlements, fetchedAt: now}
+	checker.mtx.Unlock()
+	return entitlements, nil
+}
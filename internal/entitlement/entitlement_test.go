@@ -0,0 +1,125 @@
+package entitlement
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_subscription"
+)
+
+type fakeSubscriptionClient struct {
+	entitlements []string
+	err          error
+	calls        int
+}
+
+func (client *fakeSubscriptionClient) GetEntitlements(ctx context.Context, in *pb_subscription.GetEntitlementsRequest, opts ...grpc.CallOption) (*pb_subscription.GetEntitlementsResponse, error) {
+	client.calls++
+	if client.err != nil {
+		return nil, client.err
+	}
+	return &pb_subscription.GetEntitlementsResponse{Entitlements: client.entitlements}, nil
+}
+
+func TestChecker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	entitlementsConfig := config.EntitlementsConfig{
+		CacheTTL: time.Minute,
+		Routes:   []config.EntitlementRouteConfig{{Method: "get", Path: "/reports/:reportID", Entitlement: "premium_api"}},
+	}
+	serve := func(t *testing.T, checker *Checker, path string) *httptest.ResponseRecorder {
+		router := gin.New()
+		authenticate := func(ctx *gin.Context) {
+			claims := &commonJWT.TokenClaims{UserID: "user-id"}
+			ctx.Set(string(commonJWT.ClaimsContextKey), claims)
+			checker.OnAuthenticated(ctx, claims)
+		}
+		ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+		router.GET("/reports/:reportID", authenticate, ok)
+		router.GET("/exports", authenticate, checker.RequireEntitlement("exports"), ok)
+		router.GET("/status", authenticate, ok)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	t.Run("OnAuthenticated_Should_Allow_The_Entitled_Users", func(t *testing.T) {
+		client := &fakeSubscriptionClient{entitlements: []string{"premium_api"}}
+		checker, err := NewChecker(client, &config.Config{Entitlements: entitlementsConfig})
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, serve(t, checker, "/reports/report-1").Code)
+		assert.Equal(t, http.StatusOK, serve(t, checker, "/reports/report-2").Code)
+		assert.Equal(t, 1, client.calls)
+	})
+
+	t.Run("OnAuthenticated_Should_Block_The_Users_Without_The_Entitlement", func(t *testing.T) {
+		checker, err := NewChecker(&fakeSubscriptionClient{}, &config.Config{Entitlements: entitlementsConfig})
+		assert.NoError(t, err)
+
+		recorder := serve(t, checker, "/reports/report-1")
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.JSONEq(t, `{"error":{
+			"code":"entitlement_required",
+			"message":"The subscription of the user does not include the feature of the route",
+			"details":[{"type":"error_info","metadata":{"entitlement":"premium_api"}}]
+		}}`, recorder.Body.String())
+	})
+
+	t.Run("OnAuthenticated_Should_Skip_The_Routes_Without_An_Entitlement", func(t *testing.T) {
+		client := &fakeSubscriptionClient{}
+		checker, err := NewChecker(client, &config.Config{Entitlements: entitlementsConfig})
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, serve(t, checker, "/status").Code)
+		assert.Equal(t, 0, client.calls)
+	})
+
+	t.Run("RequireEntitlement_Should_Check_The_Given_Entitlement", func(t *testing.T) {
+		checker, err := NewChecker(&fakeSubscriptionClient{entitlements: []string{"premium_api"}}, &config.Config{})
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusForbidden, serve(t, checker, "/exports").Code)
+	})
+
+	t.Run("Entitlements_Should_Fetch_Again_Once_The_Cache_Expired", func(t *testing.T) {
+		client := &fakeSubscriptionClient{entitlements: []string{"premium_api"}}
+		checker, err := NewChecker(client, &config.Config{})
+		assert.NoError(t, err)
+
+		_, err = checker.Entitlements(context.Background(), "user-id")
+		assert.NoError(t, err)
+		_, err = checker.Entitlements(context.Background(), "user-id")
+		assert.NoError(t, err)
+
+		assert.Equal(t, 2, client.calls)
+	})
+
+	t.Run("OnAuthenticated_Should_Answer_The_Subscription_Service_Errors", func(t *testing.T) {
+		client := &fakeSubscriptionClient{err: status.Error(codes.Unavailable, "unavailable")}
+		checker, err := NewChecker(client, &config.Config{Entitlements: entitlementsConfig})
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusServiceUnavailable, serve(t, checker, "/reports/report-1").Code)
+	})
+
+	t.Run("NewChecker_Should_Require_The_Entitlement_Of_The_Routes", func(t *testing.T) {
+		_, err := NewChecker(&fakeSubscriptionClient{}, &config.Config{Entitlements: config.EntitlementsConfig{
+			Routes: []config.EntitlementRouteConfig{{Method: "GET", Path: "/reports"}},
+		}})
+
+		assert.Error(t, err)
+	})
+}
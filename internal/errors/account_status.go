@@ -0,0 +1,99 @@
+package errors
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons reported by the backend in the error info details of account status errors
+const (
+	AccountLockedReason   = "ACCOUNT_LOCKED"
+	AccountDisabledReason = "ACCOUNT_DISABLED"
+	unlockAtMetadataKey   = "unlock_at"
+)
+
+// AccountStatusError describes a locked or disabled account reported by the backend
+type AccountStatusError struct {
+	Reason   string
+	UnlockAt *time.Time
+}
+
+// RemainingLockout returns how long the account stays locked from the given time
+func (accountStatusError *AccountStatusError) RemainingLockout(now time.Time) time.Duration {
+	if accountStatusError.UnlockAt == nil || accountStatusError.UnlockAt.Before(now) {
+		return 0
+	}
+	return accountStatusError.UnlockAt.Sub(now)
+}
+
+// GetAccountStatusError parses the gRPC error details to find a locked or disabled account
+func GetAccountStatusError(err error) *AccountStatusError {
+	errorStatus, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	var accountStatusError *AccountStatusError
+	var retryDelay *time.Duration
+	for _, detail := range errorStatus.Details() {
+		switch typedDetail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			reason := typedDetail.GetReason()
+			if reason != AccountLockedReason && reason != AccountDisabledReason {
+				continue
+			}
+			accountStatusError = &AccountStatusError{Reason: reason}
+			if unlockAt, err := time.Parse(time.RFC3339, typedDetail.GetMetadata()[unlockAtMetadataKey]); err == nil {
+				accountStatusError.UnlockAt = &unlockAt
+			}
+		case *errdetails.RetryInfo:
+			if typedDetail.GetRetryDelay() != nil {
+				delay := typedDetail.GetRetryDelay().AsDuration()
+				retryDelay = &delay
+			}
+		}
+	}
+	if accountStatusError != nil && accountStatusError.UnlockAt == nil && retryDelay != nil {
+		unlockAt := time.Now().Add(*retryDelay)
+		accountStatusError.UnlockAt = &unlockAt
+	}
+	return accountStatusError
+}
+
+// handleAccountStatusError responds with a friendly error when the account is locked or disabled
+func handleAccountStatusError(ctx *gin.Context, err error) bool {
+	accountStatusError := GetAccountStatusError(err)
+	if accountStatusError == nil {
+		return false
+	}
+
+	if accountStatusError.Reason == AccountDisabledReason {
+		AbortWithMessage(ctx, AccountDisabled, "Your account has been disabled. Please contact support to restore access.")
+		ctx.Error(err)
+		return true
+	}
+
+	message := "Your account is temporarily locked after too many failed sign in attempts. Wait for the lockout to expire or reset your password to unlock it."
+	details := []Detail{}
+	remaining := accountStatusError.RemainingLockout(time.Now())
+	if remaining > 0 {
+		remainingSeconds := int(math.Ceil(remaining.Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(remainingSeconds))
+		details = append(details, Metadata(RetryDetail, map[string]string{
+			"retry_after_seconds": strconv.Itoa(remainingSeconds),
+			"unlock_at":           accountStatusError.UnlockAt.UTC().Format(time.RFC3339),
+		}))
+		message = fmt.Sprintf(
+			"Your account is temporarily locked after too many failed sign in attempts. Try again in %d minutes or reset your password to unlock it.",
+			int(math.Ceil(remaining.Minutes())),
+		)
+	}
+	AbortWithMessage(ctx, AccountLocked, message, details...)
+	ctx.Error(err)
+	return true
+}
@@ -0,0 +1,70 @@
+package errors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestAccountStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("HandleError_Account_Locked_Success", func(t *testing.T) {
+		lockedStatus, err := status.New(codes.PermissionDenied, "account locked").WithDetails(
+			&errdetails.ErrorInfo{Reason: AccountLockedReason},
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(10 * time.Minute)},
+		)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+
+		HandleError(ctx, lockedStatus.Err())
+
+		assert.Equal(t, http.StatusLocked, w.Code)
+		assert.Equal(t, "600", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), AccountLocked)
+		assert.Contains(t, w.Body.String(), "Try again in 10 minutes")
+	})
+
+	t.Run("HandleError_Account_Disabled_Success", func(t *testing.T) {
+		disabledStatus, err := status.New(codes.PermissionDenied, "account disabled").WithDetails(
+			&errdetails.ErrorInfo{Reason: AccountDisabledReason},
+		)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+
+		HandleError(ctx, disabledStatus.Err())
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), AccountDisabled)
+	})
+
+	t.Run("GetAccountStatusError_Unlock_At_Metadata_Success", func(t *testing.T) {
+		unlockAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		lockedStatus, err := status.New(codes.FailedPrecondition, "account locked").WithDetails(
+			&errdetails.ErrorInfo{
+				Reason:   AccountLockedReason,
+				Metadata: map[string]string{"unlock_at": unlockAt.Format(time.RFC3339)},
+			},
+		)
+		assert.NoError(t, err)
+
+		accountStatusError := GetAccountStatusError(lockedStatus.Err())
+
+		assert.NotNil(t, accountStatusError)
+		assert.True(t, unlockAt.Equal(*accountStatusError.UnlockAt))
+	})
+
+	t.Run("GetAccountStatusError_Other_Error_Nil", func(t *testing.T) {
+		assert.Nil(t, GetAccountStatusError(status.Error(codes.PermissionDenied, "denied")))
+	})
+}
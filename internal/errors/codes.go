@@ -0,0 +1,126 @@
+package errors
+
+import (
+	"net/http"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Definition is the HTTP status and the default message a gateway error code is answered with
+type Definition struct {
+	Status  int
+	Message string
+}
+
+// definitions is the registry of the error codes the gateway answers, a code is registered before any route uses it
+var definitions = map[string]Definition{
+	TooManyRequests:         {http.StatusTooManyRequests, "Too many requests, retry later"},
+	TokenExpiring:           {http.StatusUnauthorized, "The access token is about to expire, refresh it"},
+	IdleTimeout:             {http.StatusUnauthorized, "The session expired after a period of inactivity"},
+	ConcurrentSessionLimit:  {http.StatusConflict, "The maximum number of concurrent sessions was reached"},
+	SessionRevoked:          {http.StatusUnauthorized, "The session has been revoked"},
+	AccountLocked:           {http.StatusLocked, "The account is temporarily locked"},
+	AccountDisabled:         {http.StatusForbidden, "The account has been disabled"},
+	EmailNotVerified:        {http.StatusForbidden, "The email address has not been verified"},
+	MinimumAgeRequired:      {http.StatusForbidden, "The user does not meet the minimum age of the route"},
+	ParentalConsentRequired: {http.StatusForbidden, "The route requires parental consent"},
+	StepUpRequired:          {http.StatusUnauthorized, "The route requires a recent authentication"},
+	IdempotencyKeyRequired:  {http.StatusBadRequest, "A valid Idempotency-Key header is required"},
+	PayloadTooLarge:         {http.StatusRequestEntityTooLarge, "The request body is too large"},
+	InsufficientRole:        {http.StatusForbidden, "The user does not have the role the route requires"},
+	SpamDetected:            {http.StatusUnprocessableEntity, "The content was detected as spam"},
+	CaptchaRequired:         {http.StatusForbidden, "A captcha token is required"},
+	CaptchaFailed:           {http.StatusForbidden, "The captcha verification failed"},
+	AttestationRequired:     {http.StatusForbidden, "An app attestation is required"},
+	AttestationFailed:       {http.StatusForbidden, "The app attestation failed"},
+	UpgradeRequired:         {http.StatusUpgradeRequired, "The app version is no longer supported, upgrade it"},
+	RouteNotFound:           {http.StatusNotFound, "No route matches the requested path"},
+	MethodNotAllowed:        {http.StatusMethodNotAllowed, "The route does not support the requested method"},
+	DiscoveryUnavailable:    {http.StatusBadGateway, "The discovery document is unavailable"},
+	CrawlerDenied:           {http.StatusForbidden, "The crawler is not allowed"},
+	WebSocketRequired:       {http.StatusBadRequest, "The route requires a WebSocket upgrade"},
+	InvalidRequestBody:      {http.StatusBadRequest, "The request body is invalid"},
+	FilterRejected:          {http.StatusForbidden, "The request was rejected by a filter"},
+	ScriptRejected:          {http.StatusForbidden, "The request was rejected by a route script"},
+	RouteDisabled:           {http.StatusServiceUnavailable, "The route is disabled"},
+	InvalidPolicyBundle:     {http.StatusBadRequest, "The policy bundle is invalid"},
+	PolicyBundleExists:      {http.StatusConflict, "The policy bundle version already exists"},
+	PolicyBundleNotFound:    {http.StatusNotFound, "The policy bundle version was not found"},
+	TokenRevoked:            {http.StatusUnauthorized, "The access token has been revoked"},
+	InvalidTokenRevocation:  {http.StatusBadRequest, "The token revocation is invalid"},
+	InvalidTokenMint:        {http.StatusBadRequest, "The token can not be minted"},
+	InvalidAudience:         {http.StatusUnauthorized, "The access token was not issued for the route"},
+	GRPCWebRequired:         {http.StatusUnsupportedMediaType, "The route requires a gRPC-Web request"},
+	EntitlementRequired:     {http.StatusForbidden, "The subscription of the user does not include the feature of the route"},
+	TenantRequired:          {http.StatusForbidden, "The access token does not belong to a tenant"},
+	InvalidArgument:         {http.StatusBadRequest, "The request is invalid"},
+	Unauthenticated:         {http.StatusUnauthorized, "The request is not authenticated"},
+	PermissionDenied:        {http.StatusForbidden, "The request is not allowed"},
+	NotFound:                {http.StatusNotFound, "The resource was not found"},
+	AlreadyExists:           {http.StatusConflict, "The resource already exists"},
+	FailedPrecondition:      {http.StatusPreconditionFailed, "The resource is not in the state the request requires"},
+	RequestCancelled:        {http.StatusRequestTimeout, "The request was cancelled"},
+	UpstreamTimeout:         {http.StatusGatewayTimeout, "The service did not answer in time"},
+	NotImplemented:          {http.StatusNotImplemented, "The operation is not implemented"},
+	ServiceUnavailable:      {http.StatusServiceUnavailable, "The service is unavailable, retry later"},
+	BadGateway:              {http.StatusBadGateway, "The service answered an invalid response"},
+	InternalError:           {http.StatusInternalServerError, "An internal error occurred"},
+}
+
+// grpcErrorCodes maps the gRPC status codes to the gateway error codes
+var grpcErrorCodes = map[codes.Code]string{
+	codes.Canceled:           RequestCancelled,
+	codes.Unknown:            InternalError,
+	codes.InvalidArgument:    InvalidArgument,
+	codes.DeadlineExceeded:   UpstreamTimeout,
+	codes.NotFound:           NotFound,
+	codes.AlreadyExists:      AlreadyExists,
+	codes.PermissionDenied:   PermissionDenied,
+	codes.Unauthenticated:    Unauthenticated,
+	codes.ResourceExhausted:  TooManyRequests,
+	codes.FailedPrecondition: FailedPrecondition,
+	codes.Aborted:            FailedPrecondition,
+	codes.OutOfRange:         InvalidArgument,
+	codes.Unimplemented:      NotImplemented,
+	codes.Internal:           InternalError,
+	codes.Unavailable:        ServiceUnavailable,
+	codes.DataLoss:           InternalError,
+}
+
+// Lookup returns the definition of the error code, the one of an internal error for an unregistered code
+func Lookup(code string) Definition {
+	if definition, ok := definitions[code]; ok {
+		return definition
+	}
+	return definitions[InternalError]
+}
+
+// IsRegistered tells whether the error code is in the registry
+func IsRegistered(code string) bool {
+	_, ok := definitions[code]
+	return ok
+}
+
+// Codes returns the registered error codes, sorted
+func Codes() []string {
+	registered := make([]string, 0, len(definitions))
+	for code := range definitions {
+		registered = append(registered, code)
+	}
+	sort.Strings(registered)
+	return registered
+}
+
+// GRPCErrorCode returns the gateway error code of a gRPC error, an internal error when it has no status
+func GRPCErrorCode(err error) string {
+	errorStatus, ok := status.FromError(err)
+	if !ok {
+		return InternalError
+	}
+	if code, ok := grpcErrorCodes[errorStatus.Code()]; ok {
+		return code
+	}
+	return InternalError
+}
@@ -0,0 +1,105 @@
+package errors
+
+import (
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// Types of the details of the error responses
+const (
+	FieldViolationDetail = "field_violation"
+	ErrorInfoDetail      = "error_info"
+	RetryDetail          = "retry"
+	RequestDetail        = "request"
+	SuggestionDetail     = "suggestion"
+	HelpDetail           = "help"
+)
+
+// Detail is a detail of an error response, a field violation or the metadata of the error
+type Detail struct {
+	Type        string            `json:"type"`
+	Field       string            `json:"field,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// Body is the error of an error response
+type Body struct {
+	Code          string   `json:"code"`
+	Message       string   `json:"message"`
+	CorrelationID string   `json:"correlationID,omitempty"`
+	Details       []Detail `json:"details,omitempty"`
+}
+
+// Response is the envelope of the error responses of every route
+type Response struct {
+	Error Body `json:"error"`
+}
+
+// FieldViolation returns the detail of an invalid field of the request
+func FieldViolation(field, description string) Detail {
+	return Detail{Type: FieldViolationDetail, Field: field, Description: description}
+}
+
+// Metadata returns a detail of the type carrying the metadata
+func Metadata(detailType string, metadata map[string]string) Detail {
+	return Detail{Type: detailType, Metadata: metadata}
+}
+
+// NewResponse returns the error envelope of the request, carrying its correlation ID
+func NewResponse(ctx *gin.Context, code, message string, details ...Detail) Response {
+	body := Body{Code: code, Message: message, Details: details}
+	if ctx.Request != nil {
+		if correlationID, err := commonLogger.GetCorrelationIDFromContext(ctx.Request.Context()); err == nil {
+			body.CorrelationID = *correlationID
+		}
+	}
+	return Response{Error: body}
+}
+
+// Abort answers the error envelope of the code with its registered status and message
+func Abort(ctx *gin.Context, code string, details ...Detail) {
+	definition := Lookup(code)
+	abort(ctx, definition.Status, code, definition.Message, details...)
+}
+
+// AbortWithMessage answers the error envelope of the code with its registered status and the message
+func AbortWithMessage(ctx *gin.Context, code, message string, details ...Detail) {
+	abort(ctx, Lookup(code).Status, code, message, details...)
+}
+
+// AbortWithError answers the error envelope of the code and records the error on the context for the logs,
+// without echoing it as it may quote the request
+func AbortWithError(ctx *gin.Context, code string, err error, details ...Detail) {
+	Abort(ctx, code, details...)
+	ctx.Error(err)
+}
+
+func abort(ctx *gin.Context, httpStatus int, code, message string, details ...Detail) {
+	ctx.AbortWithStatusJSON(httpStatus, NewResponse(ctx, code, message, details...))
+}
+
+// statusDetails returns the field violations and the error info of the gRPC status
+func statusDetails(errorStatus *status.Status) []Detail {
+	details := []Detail{}
+	for _, detail := range errorStatus.Details() {
+		switch typedDetail := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, violation := range typedDetail.GetFieldViolations() {
+				details = append(details, FieldViolation(violation.GetField(), violation.GetDescription()))
+			}
+		case *errdetails.ErrorInfo:
+			metadata := map[string]string{"reason": typedDetail.GetReason()}
+			if typedDetail.GetDomain() != "" {
+				metadata["domain"] = typedDetail.GetDomain()
+			}
+			for key, value := range typedDetail.GetMetadata() {
+				metadata[key] = value
+			}
+			details = append(details, Metadata(ErrorInfoDetail, metadata))
+		}
+	}
+	return details
+}
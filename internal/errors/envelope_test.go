@@ -0,0 +1,97 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("HandleError_Should_Answer_The_Field_Violations_Of_The_Status", func(t *testing.T) {
+		invalidStatus, err := status.New(codes.InvalidArgument, "invalid profile").WithDetails(
+			&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "firstName", Description: "must not be empty"},
+			}},
+			&errdetails.ErrorInfo{Reason: "PROFILE_INVALID", Domain: "user"},
+		)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+
+		HandleError(ctx, invalidStatus.Err())
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":{
+			"code":"invalid_argument",
+			"message":"invalid profile",
+			"details":[
+				{"type":"field_violation","field":"firstName","description":"must not be empty"},
+				{"type":"error_info","metadata":{"reason":"PROFILE_INVALID","domain":"user"}}
+			]
+		}}`, w.Body.String())
+		assert.Len(t, ctx.Errors, 1)
+	})
+
+	t.Run("HandleError_Should_Map_The_Status_Codes", func(t *testing.T) {
+		for code, errorCode := range grpcErrorCodes {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+
+			HandleError(ctx, status.Error(code, "failed"))
+
+			assert.Equal(t, Lookup(errorCode).Status, w.Code, code.String())
+			assert.Contains(t, w.Body.String(), fmt.Sprintf(`"code":"%s"`, errorCode))
+		}
+	})
+
+	t.Run("HandleError_Should_Answer_An_Internal_Error_Without_A_Status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+
+		HandleError(ctx, fmt.Errorf("connection reset"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"internal_error"`)
+	})
+
+	t.Run("Abort_Should_Answer_The_Registered_Status_And_Correlation_ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		ctx.Request = ctx.Request.WithContext(
+			commonLogger.AddCorrelationIDToIncomingContext(ctx.Request.Context(), "correlation-id"),
+		)
+
+		Abort(ctx, PayloadTooLarge)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.JSONEq(t, `{"error":{
+			"code":"payload_too_large",
+			"message":"The request body is too large",
+			"correlationID":"correlation-id"
+		}}`, w.Body.String())
+	})
+
+	t.Run("Lookup_Should_Answer_An_Internal_Error_For_Unregistered_Codes", func(t *testing.T) {
+		assert.False(t, IsRegistered("unregistered"))
+		assert.Equal(t, http.StatusInternalServerError, Lookup("unregistered").Status)
+	})
+
+	t.Run("Codes_Should_Register_The_Mapped_Codes", func(t *testing.T) {
+		registered := Codes()
+		for _, errorCode := range grpcErrorCodes {
+			assert.Contains(t, registered, errorCode)
+		}
+		assert.Contains(t, registered, AccountLocked)
+	})
+}
@@ -4,14 +4,63 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	commonPB "github.com/quadev-ltd/qd-common/pkg/pb"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 // Error name constants
 const (
-	TooManyRequests = "too_many_requests"
+	TooManyRequests         = "too_many_requests"
+	TokenExpiring           = "token_expiring"
+	IdleTimeout             = "idle_timeout"
+	ConcurrentSessionLimit  = "concurrent_session_limit"
+	SessionRevoked          = "session_revoked"
+	AccountLocked           = "account_locked"
+	AccountDisabled         = "account_disabled"
+	EmailNotVerified        = "email_not_verified"
+	MinimumAgeRequired      = "minimum_age_required"
+	ParentalConsentRequired = "parental_consent_required"
+	StepUpRequired          = "step_up_required"
+	IdempotencyKeyRequired  = "idempotency_key_required"
+	PayloadTooLarge         = "payload_too_large"
+	InsufficientRole        = "insufficient_role"
+	SpamDetected            = "spam_detected"
+	CaptchaRequired         = "captcha_required"
+	CaptchaFailed           = "captcha_failed"
+	AttestationRequired     = "attestation_required"
+	AttestationFailed       = "attestation_failed"
+	UpgradeRequired         = "upgrade_required"
+	RouteNotFound           = "route_not_found"
+	MethodNotAllowed        = "method_not_allowed"
+	DiscoveryUnavailable    = "discovery_unavailable"
+	CrawlerDenied           = "crawler_denied"
+	WebSocketRequired       = "websocket_required"
+	InvalidRequestBody      = "invalid_request_body"
+	FilterRejected          = "filter_rejected"
+	ScriptRejected          = "script_rejected"
+	RouteDisabled           = "route_disabled"
+	InvalidPolicyBundle     = "invalid_policy_bundle"
+	PolicyBundleExists      = "policy_bundle_exists"
+	PolicyBundleNotFound    = "policy_bundle_not_found"
+	TokenRevoked            = "token_revoked"
+	InvalidTokenRevocation  = "invalid_token_revocation"
+	InvalidTokenMint        = "invalid_token_mint"
+	InvalidAudience         = "invalid_audience"
+	GRPCWebRequired         = "grpc_web_required"
+	EntitlementRequired     = "entitlement_required"
+	TenantRequired          = "tenant_required"
+	InvalidArgument         = "invalid_argument"
+	Unauthenticated         = "unauthenticated"
+	PermissionDenied        = "permission_denied"
+	NotFound                = "not_found"
+	AlreadyExists           = "already_exists"
+	FailedPrecondition      = "failed_precondition"
+	RequestCancelled        = "request_cancelled"
+	UpstreamTimeout         = "upstream_timeout"
+	NotImplemented          = "not_implemented"
+	ServiceUnavailable      = "service_unavailable"
+	BadGateway              = "bad_gateway"
+	InternalError           = "internal_error"
 )
 
 // GRPCErrorToHTTPStatus converts a gRPC error to an HTTP status code
@@ -60,22 +109,16 @@ func GRPCErrorToHTTPStatus(err error) int {
 	}
 }
 
-// HandleError handles an error by returning an HTTP response with the appropriate status code
+// HandleError answers the error envelope of a gRPC error, its code mapped from the status code and its details
+// carrying the field violations and the error info of the status
 func HandleError(ctx *gin.Context, err error) error {
-	errorHTTPStatusCode := GRPCErrorToHTTPStatus(err)
-	errorsMap := gin.H{"error": err.Error()}
-
-	fieldValidationErrors, parsingError := commonPB.GetFieldValidationErrors(err)
-	if parsingError != nil {
-		ctx.JSON(errorHTTPStatusCode, errorsMap)
-		ctx.AbortWithError(errorHTTPStatusCode, err)
-		return parsingError
+	if handleAccountStatusError(ctx, err) {
+		return nil
 	}
 
-	if len(fieldValidationErrors) > 0 {
-		errorsMap["field_errors"] = fieldValidationErrors
-	}
-	ctx.JSON(errorHTTPStatusCode, errorsMap)
-	ctx.AbortWithError(errorHTTPStatusCode, err)
+	errorHTTPStatusCode := GRPCErrorToHTTPStatus(err)
+	errorStatus := status.Convert(err)
+	abort(ctx, errorHTTPStatusCode, GRPCErrorCode(err), errorStatus.Message(), statusDetails(errorStatus)...)
+	ctx.Error(err)
 	return nil
 }
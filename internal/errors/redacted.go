@@ -0,0 +1,14 @@
+package errors
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleRedactedError responds with the HTTP status and the error code of the error without exposing or recording
+// its message, for backends whose errors may carry sensitive payload data
+func HandleRedactedError(ctx *gin.Context, err error) {
+	errorHTTPStatusCode := GRPCErrorToHTTPStatus(err)
+	abort(ctx, errorHTTPStatusCode, GRPCErrorCode(err), http.StatusText(errorHTTPStatusCode))
+}
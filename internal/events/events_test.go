@@ -0,0 +1,314 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonJWTMock "github.com/quadev-ltd/qd-common/pkg/jwt/mock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+)
+
+type publishedEvent struct {
+	detailType string
+	detail     interface{}
+}
+
+type fakePublisher struct {
+	events chan publishedEvent
+}
+
+func (publisher *fakePublisher) Publish(ctx context.Context, detailType string, detail interface{}) error {
+	publisher.events <- publishedEvent{detailType: detailType, detail: detail}
+	return nil
+}
+
+type flakyPublisher struct {
+	err       error
+	published []string
+}
+
+func (publisher *flakyPublisher) Publish(ctx context.Context, detailType string, detail interface{}) error {
+	if publisher.err != nil {
+		return publisher.err
+	}
+	detailJSON, _ := json.Marshal(detail)
+	publisher.published = append(publisher.published, string(detailJSON))
+	return nil
+}
+
+type fakeEventBridge struct {
+	input  *eventbridge.PutEventsInput
+	output *eventbridge.PutEventsOutput
+	mtx    sync.Mutex
+}
+
+func (client *fakeEventBridge) PutEventsWithContext(ctx aws.Context, input *eventbridge.PutEventsInput, opts ...request.Option) (*eventbridge.PutEventsOutput, error) {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	client.input = input
+	return client.output, nil
+}
+
+func TestEvents(t *testing.T) {
+	t.Run("LoginEventMiddleware_Publishes_Event_Success", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		inspectorMock := commonJWTMock.NewMockTokenInspectorer(controller)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		publisher := &fakePublisher{events: make(chan publishedEvent, 1)}
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST(
+			"/sessions",
+			func(ctx *gin.Context) {
+				newCtx := context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock)
+				ctx.Request = ctx.Request.WithContext(newCtx)
+			},
+			LoginEventMiddleware(publisher, inspectorMock),
+			func(ctx *gin.Context) {
+				ctx.Set(routes.IssuedAuthTokenKey, "auth-token")
+				ctx.Set(routes.IssuedRefreshTokenKey, "refresh-token")
+				ctx.JSON(http.StatusOK, gin.H{})
+			},
+		)
+		inspectorMock.EXPECT().GetClaimsFromTokenString("auth-token").Return(&commonJWT.TokenClaims{
+			UserID: "test-user-id",
+			Email:  "test@example.com",
+		}, nil)
+
+		request := httptest.NewRequest(http.MethodPost, "/sessions", nil)
+		request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 Version/17.0 Safari/605.1.15")
+		request.Header.Set("CloudFront-Viewer-Country", "GB")
+		request.Header.Set("CloudFront-Viewer-City", "London")
+		request.Header.Set("X-Device-Name", "Work laptop")
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		select {
+		case published := <-publisher.events:
+			assert.Equal(t, LoginSucceededDetailType, published.detailType)
+			event := published.detail.(LoginEvent)
+			assert.Equal(t, "test-user-id", event.UserID)
+			assert.Equal(t, "test@example.com", event.Email)
+			assert.Equal(t, "macOS", event.Device.OS)
+			assert.Equal(t, "Safari", event.Device.Browser)
+			assert.Equal(t, "Work laptop", event.Device.Name)
+			assert.Equal(t, "GB", event.Location.Country)
+			assert.Equal(t, "London", event.Location.City)
+			assert.Equal(t, session.IDFromToken("refresh-token"), event.SessionID)
+		case <-time.After(time.Second):
+			t.Fatal("Login event was not published")
+		}
+	})
+
+	t.Run("LoginEventMiddleware_Failed_Login_No_Event", func(t *testing.T) {
+		publisher := &fakePublisher{events: make(chan publishedEvent, 1)}
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/sessions", LoginEventMiddleware(publisher, &commonJWT.TokenInspector{}), func(ctx *gin.Context) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{})
+		})
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/sessions", nil))
+
+		select {
+		case <-publisher.events:
+			t.Fatal("No event should be published for failed logins")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("EventBridgePublisher_Publish_Success", func(t *testing.T) {
+		client := &fakeEventBridge{output: &eventbridge.PutEventsOutput{FailedEntryCount: new(int64)}}
+		publisher := NewEventBridgePublisher(client, "test-bus", "qd.api-gateway")
+
+		err := publisher.Publish(context.Background(), LoginSucceededDetailType, LoginEvent{UserID: "test-user-id"})
+
+		assert.NoError(t, err)
+		entry := client.input.Entries[0]
+		assert.Equal(t, "test-bus", *entry.EventBusName)
+		assert.Equal(t, LoginSucceededDetailType, *entry.DetailType)
+		detail := LoginEvent{}
+		assert.NoError(t, json.Unmarshal([]byte(*entry.Detail), &detail))
+		assert.Equal(t, "test-user-id", detail.UserID)
+	})
+
+	t.Run("EventBridgePublisher_Publish_Rejected_Error", func(t *testing.T) {
+		failed := int64(1)
+		client := &fakeEventBridge{output: &eventbridge.PutEventsOutput{
+			FailedEntryCount: &failed,
+			Entries: []*eventbridge.PutEventsResultEntry{
+				{ErrorCode: aws.String("InternalFailure"), ErrorMessage: aws.String("failure")},
+			},
+		}}
+		publisher := NewEventBridgePublisher(client, "test-bus", "qd.api-gateway")
+
+		err := publisher.Publish(context.Background(), LoginSucceededDetailType, LoginEvent{})
+
+		assert.Error(t, err)
+		assert.Equal(t, "Event bus rejected the event: InternalFailure failure", err.Error())
+	})
+}
+
+func newTestOutbox(t *testing.T, publisher Publisherer, maxAttempts int) (*Outbox, *FileOutboxStore) {
+	store, err := NewFileOutboxStore(t.TempDir())
+	assert.NoError(t, err)
+	outbox := NewOutbox(publisher, store, &config.Config{
+		Environment: "test",
+		Events: config.EventsConfig{Outbox: config.EventsOutboxConfig{
+			RetryInterval: time.Second,
+			MaxBackoff:    3 * time.Second,
+			MaxAttempts:   maxAttempts,
+		}},
+	})
+	return outbox, store
+}
+
+func TestOutbox(t *testing.T) {
+	t.Run("Publish_Delivered_Event_Not_Kept_Success", func(t *testing.T) {
+		publisher := &flakyPublisher{}
+		outbox, store := newTestOutbox(t, publisher, 0)
+
+		assert.NoError(t, outbox.Publish(context.Background(), LoginSucceededDetailType, LoginEvent{UserID: "test-user-id"}))
+
+		assert.Len(t, publisher.published, 1)
+		messages, err := store.Due(context.Background(), time.Now().Add(time.Hour), 0)
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("Retry_Delivers_Events_Rejected_By_The_Bus_Success", func(t *testing.T) {
+		publisher := &flakyPublisher{err: errors.New("bus unavailable")}
+		outbox, store := newTestOutbox(t, publisher, 0)
+
+		assert.NoError(t, outbox.Publish(context.Background(), LoginSucceededDetailType, LoginEvent{UserID: "test-user-id"}))
+		messages, err := store.Due(context.Background(), time.Now().Add(time.Hour), 0)
+		assert.NoError(t, err)
+		assert.Len(t, messages, 1)
+		assert.Equal(t, 1, messages[0].Attempts)
+		assert.Equal(t, "bus unavailable", messages[0].LastError)
+
+		assert.NoError(t, outbox.Retry(context.Background(), time.Now()))
+		assert.Error(t, outbox.Retry(context.Background(), time.Now().Add(2*time.Second)))
+		messages, err = store.Due(context.Background(), time.Now().Add(time.Hour), 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, messages[0].Attempts)
+
+		publisher.err = nil
+		assert.NoError(t, outbox.Retry(context.Background(), time.Now().Add(time.Hour)))
+		assert.Len(t, publisher.published, 1)
+		detail := LoginEvent{}
+		assert.NoError(t, json.Unmarshal([]byte(publisher.published[0]), &detail))
+		assert.Equal(t, "test-user-id", detail.UserID)
+		messages, err = store.Due(context.Background(), time.Now().Add(time.Hour), 0)
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("Retry_Drops_Events_After_Max_Attempts_Error", func(t *testing.T) {
+		publisher := &flakyPublisher{err: errors.New("bus unavailable")}
+		outbox, store := newTestOutbox(t, publisher, 2)
+
+		assert.NoError(t, outbox.Publish(context.Background(), LoginSucceededDetailType, LoginEvent{}))
+		assert.Error(t, outbox.Retry(context.Background(), time.Now().Add(time.Hour)))
+
+		messages, err := store.Due(context.Background(), time.Now().Add(time.Hour), 0)
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("Backoff_Doubles_Up_To_Max_Backoff_Success", func(t *testing.T) {
+		outbox, _ := newTestOutbox(t, &flakyPublisher{}, 0)
+
+		assert.Equal(t, time.Second, outbox.backoff(1))
+		assert.Equal(t, 2*time.Second, outbox.backoff(2))
+		assert.Equal(t, 3*time.Second, outbox.backoff(5))
+	})
+}
+
+func publishedMetadata(t *testing.T, client *fakeEventBridge) Metadata {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	detail := map[string]json.RawMessage{}
+	assert.NoError(t, json.Unmarshal([]byte(*client.input.Entries[0].Detail), &detail))
+	metadata := Metadata{}
+	assert.NoError(t, json.Unmarshal(detail[MetadataKey], &metadata))
+	return metadata
+}
+
+func TestIdentity(t *testing.T) {
+	t.Run("EventBridgePublisher_Publish_Deterministic_Event_ID_Success", func(t *testing.T) {
+		client := &fakeEventBridge{output: &eventbridge.PutEventsOutput{FailedEntryCount: new(int64)}}
+		publisher := NewEventBridgePublisher(client, "test-bus", "qd.api-gateway")
+		event := LoginEvent{UserID: "test-user-id", SessionID: "test-session-id", Time: time.Now()}
+
+		assert.NoError(t, publisher.Publish(context.Background(), LoginSucceededDetailType, event))
+		first := publishedMetadata(t, client)
+		event.Time = event.Time.Add(time.Second)
+		assert.NoError(t, publisher.Publish(context.Background(), LoginSucceededDetailType, event))
+		second := publishedMetadata(t, client)
+
+		assert.NotEmpty(t, first.EventID)
+		assert.Equal(t, first.EventID, second.EventID)
+		assert.Equal(t, "test-user-id|test-session-id", first.DedupKey)
+		assert.Equal(t, 1, first.Attempt)
+	})
+
+	t.Run("LoginEvent_Different_Sessions_Sharing_The_Correlation_ID_Success", func(t *testing.T) {
+		first := LoginEvent{UserID: "test-user-id", CorrelationID: "test-correlation-id", SessionID: "first-session-id"}
+		second := LoginEvent{UserID: "test-user-id", CorrelationID: "test-correlation-id", SessionID: "second-session-id"}
+
+		firstIdentity := NewIdentity("qd.api-gateway", LoginSucceededDetailType, first, nil)
+		secondIdentity := NewIdentity("qd.api-gateway", LoginSucceededDetailType, second, nil)
+
+		assert.NotEqual(t, firstIdentity.EventID, secondIdentity.EventID)
+	})
+
+	t.Run("NewIdentity_Without_Natural_Key_Hashes_The_Detail_Success", func(t *testing.T) {
+		first := NewIdentity("qd.api-gateway", LoginSucceededDetailType, LoginEvent{}, []byte(`{"userID":"a"}`))
+		second := NewIdentity("qd.api-gateway", LoginSucceededDetailType, LoginEvent{}, []byte(`{"userID":"b"}`))
+		otherType := NewIdentity("qd.api-gateway", "other", LoginEvent{}, []byte(`{"userID":"a"}`))
+
+		assert.NotEqual(t, first.EventID, second.EventID)
+		assert.NotEqual(t, first.EventID, otherType.EventID)
+		assert.Len(t, first.DedupKey, 64)
+	})
+
+	t.Run("Outbox_Retry_Keeps_Event_ID_And_Counts_Attempts_Success", func(t *testing.T) {
+		client := &fakeEventBridge{output: &eventbridge.PutEventsOutput{FailedEntryCount: new(int64)}}
+		bus := NewEventBridgePublisher(client, "test-bus", "qd.api-gateway")
+		publisher := &flakyPublisher{err: errors.New("bus unavailable")}
+		outbox, store := newTestOutbox(t, publisher, 0)
+		event := LoginEvent{UserID: "test-user-id", SessionID: "test-session-id"}
+
+		assert.NoError(t, outbox.Publish(context.Background(), LoginSucceededDetailType, event))
+		assert.NoError(t, outbox.Publish(context.Background(), LoginSucceededDetailType, event))
+		messages, err := store.Due(context.Background(), time.Now().Add(time.Hour), 0)
+		assert.NoError(t, err)
+		assert.Len(t, messages, 1)
+
+		outbox.publisher = bus
+		assert.NoError(t, outbox.Retry(context.Background(), time.Now().Add(time.Hour)))
+		metadata := publishedMetadata(t, client)
+		assert.Equal(t, messages[0].ID, metadata.EventID)
+		assert.Equal(t, 2, metadata.Attempt)
+	})
+}
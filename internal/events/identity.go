@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetadataKey is the key of the dedup metadata added to the detail of every published event
+const MetadataKey = "metadata"
+
+type identityContextKey struct{}
+
+// Deduplicator is implemented by the events having a natural key, the deliveries of the same
+// occurrence share it even when they are emitted more than once
+type Deduplicator interface {
+	DedupKey() string
+}
+
+// Identity identifies an event occurrence across the retries and failovers of the gateway
+type Identity struct {
+	EventID  string
+	DedupKey string
+	Attempt  int
+}
+
+// Metadata lets the consumers discard the events delivered more than once
+type Metadata struct {
+	EventID     string    `json:"eventID"`
+	DedupKey    string    `json:"dedupKey"`
+	Attempt     int       `json:"attempt"`
+	PublishedAt time.Time `json:"publishedAt"`
+}
+
+// NewIdentity derives the deterministic identity of the event from its natural key,
+// or from its serialized detail when it has none
+func NewIdentity(source, detailType string, detail interface{}, detailJSON []byte) Identity {
+	dedupKey := ""
+	if deduplicator, ok := detail.(Deduplicator); ok {
+		dedupKey = deduplicator.DedupKey()
+	}
+	if dedupKey == "" {
+		hash := sha256.Sum256(detailJSON)
+		dedupKey = hex.EncodeToString(hash[:])
+	}
+	return Identity{
+		EventID:  uuid.NewSHA1(uuid.NameSpaceURL, []byte(source+"/"+detailType+"/"+dedupKey)).String(),
+		DedupKey: dedupKey,
+		Attempt:  1,
+	}
+}
+
+// WithIdentity makes the publisher reuse the identity of an event being delivered again
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+func identityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityContextKey{}).(Identity)
+	return identity, ok
+}
+
+// addMetadata adds the metadata to the detail, details not being JSON objects are left untouched
+func addMetadata(detailJSON []byte, metadata Metadata) []byte {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(detailJSON, &fields); err != nil || fields == nil {
+		return detailJSON
+	}
+	serializedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return detailJSON
+	}
+	fields[MetadataKey] = serializedMetadata
+	detailWithMetadata, err := json.Marshal(fields)
+	if err != nil {
+		return detailJSON
+	}
+	return detailWithMetadata
+}
@@ -0,0 +1,170 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+)
+
+// LoginSucceededDetailType is the detail type of the event emitted after a successful login
+const LoginSucceededDetailType = "user.login.succeeded"
+
+const publishTimeout = 5 * time.Second
+
+// Device describes the client used to sign in
+type Device struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	OS        string `json:"os,omitempty"`
+	Browser   string `json:"browser,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// Location is the approximate location of the client as reported by the edge proxy
+type Location struct {
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+// LoginEvent is the event consumed by the notification service to send new sign-in emails
+type LoginEvent struct {
+	UserID        string    `json:"userID"`
+	Email         string    `json:"email"`
+	IP            string    `json:"ip"`
+	Device        Device    `json:"device"`
+	Location      Location  `json:"location"`
+	Time          time.Time `json:"time"`
+	CorrelationID string    `json:"correlationID,omitempty"`
+	SessionID     string    `json:"sessionID,omitempty"`
+}
+
+var _ Deduplicator = LoginEvent{}
+
+// DedupKey identifies the login by the session the gateway issued for it, the correlation ID is not used since
+// the clients can send it
+func (event LoginEvent) DedupKey() string {
+	if event.SessionID == "" {
+		return ""
+	}
+	return event.UserID + "|" + event.SessionID
+}
+
+// LoginEventMiddleware emits a login event for every successful login handled by the next handlers
+func LoginEventMiddleware(publisher Publisherer, inspector commonJWT.TokenInspectorer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		issuedToken := ctx.GetString(routes.IssuedAuthTokenKey)
+		if issuedToken == "" || ctx.Writer.Status() != http.StatusOK {
+			return
+		}
+		logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+		if err != nil {
+			return
+		}
+		claims, err := inspector.GetClaimsFromTokenString(issuedToken)
+		if err != nil {
+			logger.Error(err, "Could not obtain claims for the login event")
+			return
+		}
+		event := NewLoginEvent(ctx.Request, ctx.ClientIP(), claims)
+		event.SessionID = issuedSessionID(ctx, issuedToken)
+
+		go func() {
+			publishContext, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			defer cancel()
+			if err := publisher.Publish(publishContext, LoginSucceededDetailType, event); err != nil {
+				logger.Error(err, "Could not publish login event")
+			}
+		}()
+	}
+}
+
+// NewLoginEvent builds the login event from the request context
+func NewLoginEvent(request *http.Request, clientIP string, claims *commonJWT.TokenClaims) LoginEvent {
+	userAgent := request.UserAgent()
+	correlationID := ""
+	if contextCorrelationID, err := commonLogger.GetCorrelationIDFromContext(request.Context()); err == nil {
+		correlationID = *contextCorrelationID
+	}
+	return LoginEvent{
+		UserID: claims.UserID,
+		Email:  claims.Email,
+		IP:     clientIP,
+		Device: Device{
+			ID:        request.Header.Get("X-Device-ID"),
+			Name:      request.Header.Get("X-Device-Name"),
+			OS:        detectOS(userAgent),
+			Browser:   detectBrowser(userAgent),
+			UserAgent: userAgent,
+		},
+		Location: Location{
+			Country: firstHeader(request, "CloudFront-Viewer-Country", "CF-IPCountry"),
+			Region:  firstHeader(request, "CloudFront-Viewer-Country-Region-Name", "CloudFront-Viewer-Country-Region"),
+			City:    request.Header.Get("CloudFront-Viewer-City"),
+		},
+		Time:          time.Now().UTC(),
+		CorrelationID: correlationID,
+	}
+}
+
+// issuedSessionID identifies the session by the refresh token issued with the login as the session registry does,
+// or by the auth token when there is none
+func issuedSessionID(ctx *gin.Context, issuedToken string) string {
+	if refreshToken := ctx.GetString(routes.IssuedRefreshTokenKey); refreshToken != "" {
+		return session.IDFromToken(refreshToken)
+	}
+	return session.IDFromToken(issuedToken)
+}
+
+func firstHeader(request *http.Request, names ...string) string {
+	for _, name := range names {
+		if value := request.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func detectOS(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		return "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		return "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		return "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		return "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		return "Linux"
+	default:
+		return ""
+	}
+}
+
+func detectBrowser(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		return "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		return "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		return "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		return "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		return "Safari"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Outbox backends
+const (
+	OutboxBackendFile  = "file"
+	OutboxBackendRedis = "redis"
+)
+
+const (
+	defaultOutboxBatchSize     = 100
+	defaultOutboxRetryInterval = 30 * time.Second
+	defaultOutboxMaxBackoff    = 10 * time.Minute
+)
+
+// OutboxMessage is an event kept until the event bus accepts it
+type OutboxMessage struct {
+	ID          string          `json:"id"`
+	DedupKey    string          `json:"dedupKey"`
+	DetailType  string          `json:"detailType"`
+	Detail      json.RawMessage `json:"detail"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastError   string          `json:"lastError,omitempty"`
+}
+
+// OutboxStorer persists the outbox messages
+type OutboxStorer interface {
+	Put(ctx context.Context, message *OutboxMessage) error
+	Due(ctx context.Context, now time.Time, limit int) ([]*OutboxMessage, error)
+	Delete(ctx context.Context, id string) error
+	// Shared tells whether every replica sees the same messages
+	Shared() bool
+}
+
+// Outbox persists every event before publishing it, the events the bus rejects are retried by the outbox job
+type Outbox struct {
+	publisher     Publisherer
+	store         OutboxStorer
+	source        string
+	batchSize     int
+	retryInterval time.Duration
+	maxBackoff    time.Duration
+	maxAttempts   int
+	logger        commonLogger.Loggerer
+}
+
+var _ Publisherer = &Outbox{}
+
+// NewOutbox creates the outbox delivering the stored events through the publisher
+func NewOutbox(publisher Publisherer, store OutboxStorer, configurations *config.Config) *Outbox {
+	outboxConfig := configurations.Events.Outbox
+	outbox := &Outbox{
+		publisher:     publisher,
+		store:         store,
+		source:        configurations.Events.Source,
+		batchSize:     outboxConfig.BatchSize,
+		retryInterval: outboxConfig.RetryInterval,
+		maxBackoff:    outboxConfig.MaxBackoff,
+		maxAttempts:   outboxConfig.MaxAttempts,
+		logger:        commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}
+	if outbox.batchSize <= 0 {
+		outbox.batchSize = defaultOutboxBatchSize
+	}
+	if outbox.retryInterval <= 0 {
+		outbox.retryInterval = defaultOutboxRetryInterval
+	}
+	if outbox.maxBackoff <= 0 {
+		outbox.maxBackoff = defaultOutboxMaxBackoff
+	}
+	return outbox
+}
+
+// Publish stores the event under its deterministic identifier and then publishes it, an event the bus
+// rejects stays in the outbox so the error is only returned when the event could not be stored either
+func (outbox *Outbox) Publish(ctx context.Context, detailType string, detail interface{}) error {
+	detailJSON, err := json.Marshal(detail)
+	if err != nil {
+		return fmt.Errorf("Could not marshal event detail: %v", err)
+	}
+	now := time.Now().UTC()
+	identity := NewIdentity(outbox.source, detailType, detail, detailJSON)
+	message := &OutboxMessage{
+		ID:          identity.EventID,
+		DedupKey:    identity.DedupKey,
+		DetailType:  detailType,
+		Detail:      detailJSON,
+		CreatedAt:   now,
+		NextAttempt: now.Add(outbox.retryInterval),
+	}
+	if err := outbox.store.Put(ctx, message); err != nil {
+		outbox.logger.Error(err, "Could not store the event in the outbox")
+		return outbox.publisher.Publish(WithIdentity(ctx, identity), detailType, message.Detail)
+	}
+	if err := outbox.deliver(ctx, message, now); err != nil {
+		outbox.logger.Warn(fmt.Sprintf("Event %s %s kept in the outbox: %v", detailType, message.ID, err))
+	}
+	return nil
+}
+
+// Retry publishes the stored events due at the given time
+func (outbox *Outbox) Retry(ctx context.Context, now time.Time) error {
+	messages, err := outbox.store.Due(ctx, now, outbox.batchSize)
+	if err != nil {
+		return fmt.Errorf("Could not read the outbox: %v", err)
+	}
+	failures := 0
+	for _, message := range messages {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := outbox.deliver(ctx, message, now); err != nil {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("Could not publish %d of %d outbox events", failures, len(messages))
+	}
+	return nil
+}
+
+// Job retries the stored events on every retry interval, it only runs on the leader when the outbox is shared
+func (outbox *Outbox) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:       "events_outbox_retry",
+		Schedule:   scheduler.Every(outbox.retryInterval),
+		RunOnStart: true,
+		Singleton:  outbox.store.Shared(),
+		Run:        outbox.Retry,
+	}
+}
+
+// deliver publishes the message and removes it from the outbox, a failed attempt is rescheduled with backoff
+// until the maximum number of attempts
+func (outbox *Outbox) deliver(ctx context.Context, message *OutboxMessage, now time.Time) error {
+	identity := Identity{EventID: message.ID, DedupKey: message.DedupKey, Attempt: message.Attempts + 1}
+	publishErr := outbox.publisher.Publish(WithIdentity(ctx, identity), message.DetailType, message.Detail)
+	if publishErr == nil {
+		if err := outbox.store.Delete(ctx, message.ID); err != nil {
+			outbox.logger.Error(err, fmt.Sprintf("Could not remove the published event %s from the outbox", message.ID))
+		}
+		return nil
+	}
+
+	message.Attempts++
+	message.LastError = publishErr.Error()
+	if outbox.maxAttempts > 0 && message.Attempts >= outbox.maxAttempts {
+		outbox.logger.Error(publishErr, fmt.Sprintf("Dropping event %s %s after %d attempts", message.DetailType, message.ID, message.Attempts))
+		if err := outbox.store.Delete(ctx, message.ID); err != nil {
+			outbox.logger.Error(err, fmt.Sprintf("Could not remove the dropped event %s from the outbox", message.ID))
+		}
+		return publishErr
+	}
+	message.NextAttempt = now.Add(outbox.backoff(message.Attempts))
+	if err := outbox.store.Put(ctx, message); err != nil {
+		outbox.logger.Error(err, fmt.Sprintf("Could not reschedule the event %s in the outbox", message.ID))
+	}
+	return publishErr
+}
+
+// backoff doubles the retry interval on every failed attempt up to the maximum backoff
+func (outbox *Outbox) backoff(attempts int) time.Duration {
+	backoff := outbox.retryInterval
+	for attempt := 1; attempt < attempts && backoff < outbox.maxBackoff; attempt++ {
+		backoff *= 2
+	}
+	if backoff > outbox.maxBackoff {
+		return outbox.maxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,218 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+)
+
+const (
+	outboxMessagesKey = "events:outbox:messages"
+	outboxDueKey      = "events:outbox:due"
+	outboxFileSuffix  = ".json"
+)
+
+// NewOutboxStore creates the outbox store of the configured backend
+func NewOutboxStore(configurations *config.Config) (OutboxStorer, error) {
+	outboxConfig := configurations.Events.Outbox
+	switch outboxConfig.Backend {
+	case OutboxBackendFile:
+		return NewFileOutboxStore(outboxConfig.Path)
+	case OutboxBackendRedis:
+		return NewRedisOutboxStore(redis.NewClient(configurations)), nil
+	}
+	return nil, fmt.Errorf("Unsupported outbox backend: %s", outboxConfig.Backend)
+}
+
+// FileOutboxStore keeps every message in its own file of a local directory, surviving restarts of the replica
+type FileOutboxStore struct {
+	directory string
+	mtx       sync.Mutex
+}
+
+var _ OutboxStorer = &FileOutboxStore{}
+
+// NewFileOutboxStore creates the store in the directory, creating it when missing
+func NewFileOutboxStore(directory string) (*FileOutboxStore, error) {
+	if directory == "" {
+		return nil, fmt.Errorf("The outbox path is required by the file backend")
+	}
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, fmt.Errorf("Could not create the outbox directory: %v", err)
+	}
+	return &FileOutboxStore{directory: directory}, nil
+}
+
+// Put writes the message atomically, replacing the previous version
+func (store *FileOutboxStore) Put(ctx context.Context, message *OutboxMessage) error {
+	serializedMessage, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("Could not marshal the outbox message: %v", err)
+	}
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	temporaryFile, err := os.CreateTemp(store.directory, ".message-*")
+	if err != nil {
+		return fmt.Errorf("Could not write the outbox message: %v", err)
+	}
+	defer os.Remove(temporaryFile.Name())
+	if _, err := temporaryFile.Write(serializedMessage); err != nil {
+		temporaryFile.Close()
+		return fmt.Errorf("Could not write the outbox message: %v", err)
+	}
+	if err := temporaryFile.Sync(); err != nil {
+		temporaryFile.Close()
+		return fmt.Errorf("Could not sync the outbox message: %v", err)
+	}
+	if err := temporaryFile.Close(); err != nil {
+		return fmt.Errorf("Could not write the outbox message: %v", err)
+	}
+	if err := os.Rename(temporaryFile.Name(), store.path(message.ID)); err != nil {
+		return fmt.Errorf("Could not store the outbox message: %v", err)
+	}
+	return nil
+}
+
+// Due returns the messages due at the given time, oldest first
+func (store *FileOutboxStore) Due(ctx context.Context, now time.Time, limit int) ([]*OutboxMessage, error) {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	entries, err := os.ReadDir(store.directory)
+	if err != nil {
+		return nil, fmt.Errorf("Could not list the outbox messages: %v", err)
+	}
+	messages := []*OutboxMessage{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), outboxFileSuffix) {
+			continue
+		}
+		serializedMessage, err := os.ReadFile(filepath.Join(store.directory, entry.Name()))
+		if err != nil {
+			continue
+		}
+		message := &OutboxMessage{}
+		if err := json.Unmarshal(serializedMessage, message); err != nil {
+			return nil, fmt.Errorf("Could not decode the outbox message %s: %v", entry.Name(), err)
+		}
+		if !message.NextAttempt.After(now) {
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// Delete removes the message
+func (store *FileOutboxStore) Delete(ctx context.Context, id string) error {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	if err := os.Remove(store.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Could not delete the outbox message: %v", err)
+	}
+	return nil
+}
+
+// Shared is false, every replica retries its own messages
+func (store *FileOutboxStore) Shared() bool {
+	return false
+}
+
+func (store *FileOutboxStore) path(id string) string {
+	return filepath.Join(store.directory, filepath.Base(id)+outboxFileSuffix)
+}
+
+// RedisOutboxStore keeps the messages in a hash indexed by a sorted set scored by their next attempt
+type RedisOutboxStore struct {
+	client redis.Clienter
+}
+
+var _ OutboxStorer = &RedisOutboxStore{}
+
+// NewRedisOutboxStore creates an outbox store backed by redis
+func NewRedisOutboxStore(client redis.Clienter) *RedisOutboxStore {
+	return &RedisOutboxStore{client: client}
+}
+
+// Put stores the message and schedules its next attempt
+func (store *RedisOutboxStore) Put(ctx context.Context, message *OutboxMessage) error {
+	serializedMessage, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("Could not marshal the outbox message: %v", err)
+	}
+	if _, err := store.client.Do(ctx, "HSET", outboxMessagesKey, message.ID, string(serializedMessage)); err != nil {
+		return fmt.Errorf("Could not store the outbox message: %v", err)
+	}
+	nextAttempt := strconv.FormatInt(message.NextAttempt.UnixMilli(), 10)
+	if _, err := store.client.Do(ctx, "ZADD", outboxDueKey, nextAttempt, message.ID); err != nil {
+		return fmt.Errorf("Could not schedule the outbox message: %v", err)
+	}
+	return nil
+}
+
+// Due returns the messages due at the given time, soonest scheduled first
+func (store *RedisOutboxStore) Due(ctx context.Context, now time.Time, limit int) ([]*OutboxMessage, error) {
+	reply, err := store.client.Do(
+		ctx, "ZRANGEBYSCORE", outboxDueKey, "-inf", strconv.FormatInt(now.UnixMilli(), 10),
+		"LIMIT", "0", strconv.Itoa(limit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Could not list the due outbox messages: %v", err)
+	}
+	ids, err := redis.Strings(reply)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse the due outbox messages: %v", err)
+	}
+	messages := make([]*OutboxMessage, 0, len(ids))
+	for _, id := range ids {
+		reply, err := store.client.Do(ctx, "HGET", outboxMessagesKey, id)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the outbox message: %v", err)
+		}
+		serializedMessage, err := redis.String(reply)
+		if err == redis.ErrNil {
+			// The message was deleted after being scheduled
+			store.client.Do(ctx, "ZREM", outboxDueKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the outbox message: %v", err)
+		}
+		message := &OutboxMessage{}
+		if err := json.Unmarshal([]byte(serializedMessage), message); err != nil {
+			return nil, fmt.Errorf("Could not decode the outbox message %s: %v", id, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Delete removes the message
+func (store *RedisOutboxStore) Delete(ctx context.Context, id string) error {
+	if _, err := store.client.Do(ctx, "ZREM", outboxDueKey, id); err != nil {
+		return fmt.Errorf("Could not unschedule the outbox message: %v", err)
+	}
+	if _, err := store.client.Do(ctx, "HDEL", outboxMessagesKey, id); err != nil {
+		return fmt.Errorf("Could not delete the outbox message: %v", err)
+	}
+	return nil
+}
+
+// Shared is true, the messages of every replica are retried by the leader
+func (store *RedisOutboxStore) Shared() bool {
+	return true
+}
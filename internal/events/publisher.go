@@ -0,0 +1,137 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// Publisherer publishes gateway events to the event bus
+type Publisherer interface {
+	Publish(ctx context.Context, detailType string, detail interface{}) error
+}
+
+type eventBridgeAPI interface {
+	PutEventsWithContext(ctx aws.Context, input *eventbridge.PutEventsInput, opts ...request.Option) (*eventbridge.PutEventsOutput, error)
+}
+
+// EventBridgePublisher publishes events to an AWS EventBridge bus
+type EventBridgePublisher struct {
+	client  eventBridgeAPI
+	busName string
+	source  string
+}
+
+var _ Publisherer = &EventBridgePublisher{}
+
+// NoopPublisher discards every event, used when the event bus is disabled
+type NoopPublisher struct{}
+
+var _ Publisherer = &NoopPublisher{}
+
+// NewPublisher creates the event bus publisher enabled in the configuration, behind the outbox when enabled
+func NewPublisher(configurations *config.Config) (Publisherer, error) {
+	eventsConfig := configurations.Events
+	if !eventsConfig.Enabled {
+		return &NoopPublisher{}, nil
+	}
+	if eventsConfig.Outbox.Enabled {
+		return NewOutboxPublisher(configurations)
+	}
+	return newEventBridgePublisher(configurations)
+}
+
+// NewOutboxPublisher creates the outbox of the event bus publisher
+func NewOutboxPublisher(configurations *config.Config) (*Outbox, error) {
+	publisher, err := newEventBridgePublisher(configurations)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewOutboxStore(configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create the event outbox: %v", err)
+	}
+	return NewOutbox(publisher, store, configurations), nil
+}
+
+func newEventBridgePublisher(configurations *config.Config) (*EventBridgePublisher, error) {
+	eventsConfig := configurations.Events
+	awsSession, err := session.NewSession(
+		&aws.Config{
+			Region: aws.String(eventsConfig.Region),
+			Credentials: credentials.NewStaticCredentials(
+				configurations.AWS.Key,
+				configurations.AWS.Secret,
+				"",
+			),
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create AWS session for the event bus: %v", err)
+	}
+	return NewEventBridgePublisher(eventbridge.New(awsSession), eventsConfig.BusName, eventsConfig.Source), nil
+}
+
+// NewEventBridgePublisher creates a publisher for the given EventBridge bus
+func NewEventBridgePublisher(client eventBridgeAPI, busName, source string) *EventBridgePublisher {
+	return &EventBridgePublisher{
+		client:  client,
+		busName: busName,
+		source:  source,
+	}
+}
+
+// Publish sends the event detail as JSON to the bus along with its dedup metadata
+func (publisher *EventBridgePublisher) Publish(ctx context.Context, detailType string, detail interface{}) error {
+	detailJSON, err := json.Marshal(detail)
+	if err != nil {
+		return fmt.Errorf("Could not marshal event detail: %v", err)
+	}
+	identity, exists := identityFromContext(ctx)
+	if !exists {
+		identity = NewIdentity(publisher.source, detailType, detail, detailJSON)
+	}
+	now := time.Now()
+	detailJSON = addMetadata(detailJSON, Metadata{
+		EventID:     identity.EventID,
+		DedupKey:    identity.DedupKey,
+		Attempt:     identity.Attempt,
+		PublishedAt: now.UTC(),
+	})
+	output, err := publisher.client.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(publisher.busName),
+				Source:       aws.String(publisher.source),
+				DetailType:   aws.String(detailType),
+				Detail:       aws.String(string(detailJSON)),
+				Time:         aws.Time(now),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Could not publish event to the bus: %v", err)
+	}
+	if aws.Int64Value(output.FailedEntryCount) > 0 && len(output.Entries) > 0 {
+		return fmt.Errorf(
+			"Event bus rejected the event: %s %s",
+			aws.StringValue(output.Entries[0].ErrorCode),
+			aws.StringValue(output.Entries[0].ErrorMessage),
+		)
+	}
+	return nil
+}
+
+// Publish discards the event
+func (publisher *NoopPublisher) Publish(ctx context.Context, detailType string, detail interface{}) error {
+	return nil
+}
@@ -0,0 +1,239 @@
+package experiments
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Headers, metadata and context keys carrying the experiment assignments
+const (
+	AssignmentsHeader      = "X-Experiments"
+	AssignmentsMetadataKey = "x-experiments"
+	DeviceIDHeader         = "X-Device-ID"
+	ContextKey             = "experiment_assignments"
+)
+
+// ExposureDetailType is the event bus detail type of the experiment exposures
+const ExposureDetailType = "experiment.exposure"
+
+const publishTimeout = 5 * time.Second
+
+// Variant is a treatment of an experiment with its relative weight
+type Variant struct {
+	Name   string
+	Weight int
+}
+
+// Experiment is an experiment definition of the configuration
+type Experiment struct {
+	Name        string
+	Salt        string
+	Variants    []Variant
+	totalWeight int
+	routes      map[string]bool
+}
+
+// Exposure is the event published the first time a subject is exposed to an experiment variant
+type Exposure struct {
+	Experiment  string    `json:"experiment"`
+	Variant     string    `json:"variant"`
+	SubjectID   string    `json:"subjectID"`
+	SubjectType string    `json:"subjectType"`
+	Route       string    `json:"route"`
+	Timestamp   time.Time `json:"timestamp"`
+	window      time.Duration
+}
+
+var _ events.Deduplicator = Exposure{}
+
+// DedupKey identifies the exposure of the subject to the variant within the exposure TTL,
+// so the replicas exposing the same subject in the same window emit the same event
+func (exposure Exposure) DedupKey() string {
+	key := fmt.Sprintf("%s|%s|%s", exposure.Experiment, exposure.Variant, exposure.SubjectID)
+	if exposure.window <= 0 {
+		return key
+	}
+	return fmt.Sprintf("%s|%d", key, exposure.Timestamp.Truncate(exposure.window).Unix())
+}
+
+// Assigner buckets the users deterministically into the configured experiments
+type Assigner struct {
+	experiments []*Experiment
+	publisher   events.Publisherer
+	exposedAt   map[string]time.Time
+	exposureTTL time.Duration
+	mtx         sync.Mutex
+}
+
+// NewAssigner creates the assigner of the experiments defined in the configuration
+func NewAssigner(publisher events.Publisherer, configurations *config.Config) (*Assigner, error) {
+	assigner := &Assigner{
+		publisher:   publisher,
+		exposedAt:   make(map[string]time.Time),
+		exposureTTL: configurations.Experiments.ExposureTTL,
+	}
+	for _, experimentConfig := range configurations.Experiments.Definitions {
+		experiment := &Experiment{
+			Name:   experimentConfig.Name,
+			Salt:   experimentConfig.Salt,
+			routes: make(map[string]bool),
+		}
+		if experiment.Salt == "" {
+			experiment.Salt = experiment.Name
+		}
+		for _, variant := range experimentConfig.Variants {
+			if variant.Weight < 0 {
+				return nil, fmt.Errorf("Negative weight for variant %s of experiment %s", variant.Name, experiment.Name)
+			}
+			experiment.Variants = append(experiment.Variants, Variant{Name: variant.Name, Weight: variant.Weight})
+			experiment.totalWeight += variant.Weight
+		}
+		if experiment.totalWeight == 0 {
+			return nil, fmt.Errorf("Experiment %s has no weighted variants", experiment.Name)
+		}
+		for _, route := range experimentConfig.Routes {
+			experiment.routes[route] = true
+		}
+		assigner.experiments = append(assigner.experiments, experiment)
+	}
+	return assigner, nil
+}
+
+// Bucket returns the variant of the subject, the same subject and salt always get the same variant
+func (experiment *Experiment) Bucket(subjectID string) string {
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%s:%s", experiment.Salt, subjectID)))
+	point := int(binary.BigEndian.Uint64(digest[:8]) % uint64(experiment.totalWeight))
+	for _, variant := range experiment.Variants {
+		if point < variant.Weight {
+			return variant.Name
+		}
+		point -= variant.Weight
+	}
+	return experiment.Variants[len(experiment.Variants)-1].Name
+}
+
+func (experiment *Experiment) appliesTo(ctx *gin.Context) bool {
+	return len(experiment.routes) == 0 ||
+		experiment.routes[ctx.FullPath()] ||
+		experiment.routes[fmt.Sprintf("%s %s", ctx.Request.Method, ctx.FullPath())]
+}
+
+// Middleware assigns the anonymous requests by device, authenticated ones are assigned by OnAuthenticated
+func (assigner *Assigner) Middleware(ctx *gin.Context) {
+	if ctx.GetHeader("Authorization") == "" {
+		if deviceID := ctx.GetHeader(DeviceIDHeader); deviceID != "" {
+			assigner.Assign(ctx, deviceID, "device")
+		}
+	}
+	ctx.Next()
+}
+
+// OnAuthenticated assigns the authenticated user, registered as an authentication hook
+func (assigner *Assigner) OnAuthenticated(ctx *gin.Context, claims *commonJWT.TokenClaims) {
+	assigner.Assign(ctx, claims.UserID, "user")
+}
+
+// Assign buckets the subject into the experiments of the route and exposes the assignments
+// in the response header and the upstream metadata
+func (assigner *Assigner) Assign(ctx *gin.Context, subjectID, subjectType string) {
+	assignments := make(map[string]string)
+	for _, experiment := range assigner.experiments {
+		if !experiment.appliesTo(ctx) {
+			continue
+		}
+		variant := experiment.Bucket(subjectID)
+		assignments[experiment.Name] = variant
+		assigner.recordExposure(ctx, Exposure{
+			Experiment:  experiment.Name,
+			Variant:     variant,
+			SubjectID:   subjectID,
+			SubjectType: subjectType,
+			Route:       ctx.FullPath(),
+			Timestamp:   time.Now(),
+		})
+	}
+	if len(assignments) == 0 {
+		return
+	}
+	encoded := EncodeAssignments(assignments)
+	existingMD, ok := metadata.FromOutgoingContext(ctx.Request.Context())
+	if !ok {
+		existingMD = metadata.New(map[string]string{})
+	}
+	newMD := existingMD.Copy()
+	newMD.Set(AssignmentsMetadataKey, encoded)
+	ctx.Request = ctx.Request.WithContext(metadata.NewOutgoingContext(ctx.Request.Context(), newMD))
+	ctx.Set(ContextKey, assignments)
+	ctx.Header(AssignmentsHeader, encoded)
+}
+
+// recordExposure publishes the exposure unless the subject was exposed to the variant within the exposure TTL
+func (assigner *Assigner) recordExposure(ctx *gin.Context, exposure Exposure) {
+	key := fmt.Sprintf("%s|%s|%s", exposure.Experiment, exposure.Variant, exposure.SubjectID)
+	now := exposure.Timestamp
+	assigner.mtx.Lock()
+	if exposedAt, exists := assigner.exposedAt[key]; exists && now.Sub(exposedAt) < assigner.exposureTTL {
+		assigner.mtx.Unlock()
+		return
+	}
+	assigner.exposedAt[key] = now
+	assigner.mtx.Unlock()
+	exposure.window = assigner.exposureTTL
+
+	logger, _ := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	go func() {
+		publishContext, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		defer cancel()
+		if err := assigner.publisher.Publish(publishContext, ExposureDetailType, exposure); err != nil && logger != nil {
+			logger.Error(err, "Could not publish the experiment exposure")
+		}
+	}()
+}
+
+// Prune forgets the exposures older than the exposure TTL
+func (assigner *Assigner) Prune(now time.Time) {
+	assigner.mtx.Lock()
+	defer assigner.mtx.Unlock()
+	for key, exposedAt := range assigner.exposedAt {
+		if now.Sub(exposedAt) >= assigner.exposureTTL {
+			delete(assigner.exposedAt, key)
+		}
+	}
+}
+
+// Job prunes the exposures on every exposure TTL
+func (assigner *Assigner) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "experiments_exposures_prune",
+		Schedule: scheduler.Every(assigner.exposureTTL),
+		Run: func(ctx context.Context, now time.Time) error {
+			assigner.Prune(now)
+			return nil
+		},
+	}
+}
+
+// EncodeAssignments encodes the assignments as sorted experiment=variant pairs separated by semicolons
+func EncodeAssignments(assignments map[string]string) string {
+	pairs := make([]string, 0, len(assignments))
+	for experiment, variant := range assignments {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", experiment, variant))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
@@ -0,0 +1,137 @@
+package experiments
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type fakePublisher struct {
+	exposures chan Exposure
+}
+
+func (publisher *fakePublisher) Publish(ctx context.Context, detailType string, detail interface{}) error {
+	publisher.exposures <- detail.(Exposure)
+	return nil
+}
+
+func newTestAssigner(t *testing.T) (*Assigner, *fakePublisher) {
+	publisher := &fakePublisher{exposures: make(chan Exposure, 10)}
+	assigner, err := NewAssigner(publisher, &config.Config{
+		Experiments: config.ExperimentsConfig{
+			ExposureTTL: time.Hour,
+			Definitions: []config.ExperimentConfig{
+				{
+					Name:     "checkout_button",
+					Salt:     "2026-q1",
+					Variants: []config.VariantConfig{{Name: "control", Weight: 50}, {Name: "green", Weight: 50}},
+				},
+				{
+					Name:     "search_ranking",
+					Variants: []config.VariantConfig{{Name: "bm25", Weight: 1}},
+					Routes:   []string{"GET /search"},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	return assigner, publisher
+}
+
+func TestExperiment(t *testing.T) {
+	assigner, _ := newTestAssigner(t)
+	experiment := assigner.experiments[0]
+
+	t.Run("Bucket_Should_Be_Deterministic", func(t *testing.T) {
+		assert.Equal(t, experiment.Bucket("user-1"), experiment.Bucket("user-1"))
+	})
+
+	t.Run("Bucket_Should_Split_By_Weight", func(t *testing.T) {
+		counts := map[string]int{}
+		for index := 0; index < 2000; index++ {
+			counts[experiment.Bucket(fmt.Sprintf("user-%d", index))]++
+		}
+
+		assert.InDelta(t, 1000, counts["control"], 150)
+		assert.InDelta(t, 1000, counts["green"], 150)
+	})
+
+	t.Run("NewAssigner_Should_Reject_Unweighted_Experiments", func(t *testing.T) {
+		_, err := NewAssigner(&fakePublisher{}, &config.Config{
+			Experiments: config.ExperimentsConfig{
+				Definitions: []config.ExperimentConfig{{Name: "empty", Variants: []config.VariantConfig{{Name: "a"}}}},
+			},
+		})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestAssigner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("OnAuthenticated_Should_Expose_Assignments_And_Publish_Once", func(t *testing.T) {
+		assigner, publisher := newTestAssigner(t)
+		router := gin.New()
+		var outgoing metadata.MD
+		router.GET("/search", func(ctx *gin.Context) {
+			assigner.OnAuthenticated(ctx, &commonJWT.TokenClaims{UserID: "user-1"})
+			outgoing, _ = metadata.FromOutgoingContext(ctx.Request.Context())
+			ctx.Status(http.StatusOK)
+		})
+		expected := EncodeAssignments(map[string]string{
+			"checkout_button": assigner.experiments[0].Bucket("user-1"),
+			"search_ranking":  "bm25",
+		})
+
+		for index := 0; index < 2; index++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search", nil))
+			assert.Equal(t, expected, w.Header().Get(AssignmentsHeader))
+			assert.Equal(t, []string{expected}, outgoing.Get(AssignmentsMetadataKey))
+		}
+
+		received := map[string]Exposure{}
+		for index := 0; index < 2; index++ {
+			exposure := <-publisher.exposures
+			received[exposure.Experiment] = exposure
+		}
+		assert.Equal(t, "user", received["search_ranking"].SubjectType)
+		assert.Equal(t, "/search", received["search_ranking"].Route)
+		select {
+		case exposure := <-publisher.exposures:
+			t.Fatalf("unexpected repeated exposure %v", exposure)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("Middleware_Should_Assign_Anonymous_Devices_Only", func(t *testing.T) {
+		assigner, _ := newTestAssigner(t)
+		router := gin.New()
+		router.Use(assigner.Middleware)
+		router.GET("/home", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+		anonymous := httptest.NewRequest(http.MethodGet, "/home", nil)
+		anonymous.Header.Set(DeviceIDHeader, "device-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, anonymous)
+		assert.Equal(t, "checkout_button="+assigner.experiments[0].Bucket("device-1"), w.Header().Get(AssignmentsHeader))
+
+		authenticated := httptest.NewRequest(http.MethodGet, "/home", nil)
+		authenticated.Header.Set(DeviceIDHeader, "device-1")
+		authenticated.Header.Set("Authorization", "Bearer token")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, authenticated)
+		assert.Empty(t, w.Header().Get(AssignmentsHeader))
+	})
+}
@@ -0,0 +1,174 @@
+package fakebackend
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeDateOfBirth makes every fake user an adult, so the age gated routes are part of the measured traffic
+var fakeDateOfBirth = time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// AuthenticationService answers the authentication service calls of the gateway without any storage,
+// signing real tokens so the gateway verifies them as in production
+type AuthenticationService struct {
+	pb_authentication.UnimplementedAuthenticationServiceServer
+	publicKey      string
+	tokenSigner    commonJWT.TokenSignerer
+	tokenVerifier  commonJWT.TokenVerifierer
+	tokenInspector commonJWT.TokenInspectorer
+	latency        time.Duration
+	tokenTTL       time.Duration
+}
+
+var _ pb_authentication.AuthenticationServiceServer = &AuthenticationService{}
+
+// NewAuthenticationService creates the fake authentication service with a freshly generated signing key
+func NewAuthenticationService(latency, tokenTTL time.Duration) (*AuthenticationService, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("Could not generate the signing key: %v", err)
+	}
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal the public key: %v", err)
+	}
+	publicKey := string(pem.EncodeToMemory(&pem.Block{Type: commonJWT.PublicKeyType, Bytes: publicKeyBytes}))
+	tokenVerifier, err := commonJWT.NewTokenVerifier(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create the token verifier: %v", err)
+	}
+	return &AuthenticationService{
+		publicKey:      publicKey,
+		tokenSigner:    commonJWT.NewTokenSigner(privateKey),
+		tokenVerifier:  tokenVerifier,
+		tokenInspector: &commonJWT.TokenInspector{},
+		latency:        latency,
+		tokenTTL:       tokenTTL,
+	}, nil
+}
+
+// GetPublicKey returns the key the fake tokens are signed with
+func (service *AuthenticationService) GetPublicKey(ctx context.Context, request *pb_authentication.GetPublicKeyRequest) (*pb_authentication.GetPublicKeyResponse, error) {
+	if err := service.wait(ctx); err != nil {
+		return nil, err
+	}
+	return &pb_authentication.GetPublicKeyResponse{PublicKey: service.publicKey}, nil
+}
+
+// Authenticate accepts any credentials, the user ID is derived from the email so every virtual user keeps its own identity
+func (service *AuthenticationService) Authenticate(ctx context.Context, request *pb_authentication.AuthenticateRequest) (*pb_authentication.AuthenticateResponse, error) {
+	if err := service.wait(ctx); err != nil {
+		return nil, err
+	}
+	if request.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "The email is required")
+	}
+	return service.issueTokens(userIDOf(request.Email), request.Email)
+}
+
+// RefreshToken issues new tokens for the user of the refresh token forwarded by the gateway
+func (service *AuthenticationService) RefreshToken(ctx context.Context, request *pb_authentication.RefreshTokenRequest) (*pb_authentication.AuthenticateResponse, error) {
+	if err := service.wait(ctx); err != nil {
+		return nil, err
+	}
+	claims, err := service.claims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return service.issueTokens(claims.UserID, claims.Email)
+}
+
+// GetUserProfile returns a profile of the authenticated user
+func (service *AuthenticationService) GetUserProfile(ctx context.Context, request *pb_authentication.GetUserProfileRequest) (*pb_authentication.GetUserProfileResponse, error) {
+	if err := service.wait(ctx); err != nil {
+		return nil, err
+	}
+	claims, err := service.claims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_authentication.GetUserProfileResponse{User: &pb_authentication.User{
+		UserID:        claims.UserID,
+		Email:         claims.Email,
+		FirstName:     "Load",
+		LastName:      "Test",
+		DateOfBirth:   timestamppb.New(fakeDateOfBirth),
+		AccountStatus: "Verified",
+	}}, nil
+}
+
+func (service *AuthenticationService) issueTokens(userID, email string) (*pb_authentication.AuthenticateResponse, error) {
+	now := time.Now()
+	authToken, err := service.tokenSigner.SignToken(
+		commonJWT.ClaimPair{Key: commonJWT.UserIDClaim, Value: userID},
+		commonJWT.ClaimPair{Key: commonJWT.EmailClaim, Value: email},
+		commonJWT.ClaimPair{Key: commonJWT.TypeClaim, Value: commonToken.AuthTokenType},
+		commonJWT.ClaimPair{Key: commonJWT.ExpiryClaim, Value: now.Add(service.tokenTTL)},
+	)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not sign the auth token: %v", err)
+	}
+	refreshToken, err := service.tokenSigner.SignToken(
+		commonJWT.ClaimPair{Key: commonJWT.UserIDClaim, Value: userID},
+		commonJWT.ClaimPair{Key: commonJWT.EmailClaim, Value: email},
+		commonJWT.ClaimPair{Key: commonJWT.TypeClaim, Value: commonToken.RefreshTokenType},
+		commonJWT.ClaimPair{Key: commonJWT.ExpiryClaim, Value: now.Add(24 * time.Hour)},
+	)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not sign the refresh token: %v", err)
+	}
+	return &pb_authentication.AuthenticateResponse{AuthToken: *authToken, RefreshToken: *refreshToken}, nil
+}
+
+// claims reads the claims of the bearer token the gateway forwards in the metadata
+func (service *AuthenticationService) claims(ctx context.Context) (*commonJWT.TokenClaims, error) {
+	authorization := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(authorization) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "No bearer token was forwarded")
+	}
+	token, err := service.tokenVerifier.Verify(strings.TrimPrefix(authorization[0], "Bearer "))
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "The bearer token was invalid: %v", err)
+	}
+	claims, err := service.tokenInspector.GetClaimsFromToken(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "Could not obtain claims from bearer token: %v", err)
+	}
+	return claims, nil
+}
+
+// wait simulates the latency of the real service
+func (service *AuthenticationService) wait(ctx context.Context) error {
+	if service.latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(service.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func userIDOf(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return hex.EncodeToString(sum[:12])
+}
@@ -0,0 +1,58 @@
+package fakebackend
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// Backends are the in-process fake backends the gateway runs against in load tests, so the capacity
+// numbers measure the gateway alone and do not depend on the state of the real services
+type Backends struct {
+	AuthenticationService commonConfig.Address
+	servers               []*grpc.Server
+}
+
+// Start serves the fake backends on loopback ports picked by the system
+func Start(configurations *config.Config) (*Backends, error) {
+	fakeBackendsConfig := configurations.FakeBackends
+	authenticationService, err := NewAuthenticationService(fakeBackendsConfig.Latency, fakeBackendsConfig.TokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	backends := &Backends{}
+	server := grpc.NewServer()
+	pb_authentication.RegisterAuthenticationServiceServer(server, authenticationService)
+	backends.AuthenticationService, err = backends.serve(server)
+	if err != nil {
+		return nil, fmt.Errorf("Could not serve the fake authentication service: %v", err)
+	}
+	return backends, nil
+}
+
+// Stop stops every fake backend
+func (backends *Backends) Stop() {
+	for _, server := range backends.servers {
+		server.Stop()
+	}
+}
+
+func (backends *Backends) serve(server *grpc.Server) (commonConfig.Address, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return commonConfig.Address{}, err
+	}
+	backends.servers = append(backends.servers, server)
+	go server.Serve(listener)
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		server.Stop()
+		return commonConfig.Address{}, err
+	}
+	return commonConfig.Address{Host: host, Port: port}, nil
+}
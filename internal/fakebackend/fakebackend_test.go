@@ -0,0 +1,69 @@
+package fakebackend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestAuthenticationService(t *testing.T) {
+	service, err := NewAuthenticationService(0, time.Minute)
+	assert.NoError(t, err)
+	withBearer := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	t.Run("Authenticate_Should_Issue_Tokens_The_Gateway_Can_Verify", func(t *testing.T) {
+		publicKey, err := service.GetPublicKey(context.Background(), &pb_authentication.GetPublicKeyRequest{})
+		assert.NoError(t, err)
+		verifier, err := commonJWT.NewTokenVerifier(publicKey.PublicKey)
+		assert.NoError(t, err)
+
+		response, err := service.Authenticate(context.Background(), &pb_authentication.AuthenticateRequest{Email: "user@loadtest.local"})
+		assert.NoError(t, err)
+		token, err := verifier.Verify(response.AuthToken)
+		assert.NoError(t, err)
+		claims, err := (&commonJWT.TokenInspector{}).GetClaimsFromToken(token)
+		assert.NoError(t, err)
+
+		assert.Equal(t, userIDOf("user@loadtest.local"), claims.UserID)
+		assert.Equal(t, commonToken.AuthTokenType, commonToken.Type(claims.Type))
+		assert.WithinDuration(t, time.Now().Add(time.Minute), claims.Expiry, 5*time.Second)
+	})
+
+	t.Run("RefreshToken_Should_Keep_The_User", func(t *testing.T) {
+		response, err := service.Authenticate(context.Background(), &pb_authentication.AuthenticateRequest{Email: "user@loadtest.local"})
+		assert.NoError(t, err)
+
+		refreshed, err := service.RefreshToken(withBearer(response.RefreshToken), &pb_authentication.RefreshTokenRequest{})
+		assert.NoError(t, err)
+		profile, err := service.GetUserProfile(withBearer(refreshed.AuthToken), &pb_authentication.GetUserProfileRequest{})
+		assert.NoError(t, err)
+
+		assert.Equal(t, userIDOf("user@loadtest.local"), profile.User.UserID)
+		assert.Equal(t, "user@loadtest.local", profile.User.Email)
+	})
+
+	t.Run("GetUserProfile_Should_Reject_Missing_Tokens", func(t *testing.T) {
+		_, err := service.GetUserProfile(context.Background(), &pb_authentication.GetUserProfileRequest{})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Wait_Should_Stop_When_The_Call_Is_Cancelled", func(t *testing.T) {
+		slowService, err := NewAuthenticationService(time.Minute, time.Minute)
+		assert.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = slowService.GetPublicKey(ctx, &pb_authentication.GetPublicKeyRequest{})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,121 @@
+package fallback
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// Authentication requirements of the capability documents
+const (
+	AuthenticationRequired = "required"
+	AuthenticationOptional = "optional"
+	AuthenticationNone     = "none"
+)
+
+// RateLimit is the rate limit applied to a method of a route
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// MethodCapability describes how clients may call a method of a route
+type MethodCapability struct {
+	Route          string     `json:"route"`
+	Authentication string     `json:"authentication"`
+	Roles          []string   `json:"roles,omitempty"`
+	RateLimit      *RateLimit `json:"rate_limit,omitempty"`
+	Deprecated     bool       `json:"deprecated"`
+	Sunset         string     `json:"sunset,omitempty"`
+	Replacement    string     `json:"replacement,omitempty"`
+}
+
+// CapabilityDocument is the answer to OPTIONS requests, which client SDKs configure themselves from
+type CapabilityDocument struct {
+	Path             string                      `json:"path"`
+	AllowedMethods   []string                    `json:"allowed_methods"`
+	Methods          map[string]MethodCapability `json:"methods"`
+	DocumentationURL string                      `json:"documentation_url,omitempty"`
+}
+
+// Capabilities answers OPTIONS requests to registered routes with their capability document
+func (handlers *Handlers) Capabilities(ctx *gin.Context) {
+	document := handlers.capabilityDocument(ctx.Request.URL.Path)
+	ctx.Header("Allow", strings.Join(document.AllowedMethods, ", "))
+	ctx.AbortWithStatusJSON(http.StatusOK, document)
+}
+
+func (handlers *Handlers) capabilityDocument(path string) CapabilityDocument {
+	document := CapabilityDocument{
+		Path:             path,
+		AllowedMethods:   []string{http.MethodOptions},
+		Methods:          map[string]MethodCapability{},
+		DocumentationURL: handlers.documentationURL,
+	}
+	for _, route := range handlers.registeredRoutes() {
+		if !matches(route.Path, path) {
+			continue
+		}
+		if _, exists := document.Methods[route.Method]; exists {
+			continue
+		}
+		document.Methods[route.Method] = handlers.methodCapability(route.Method, route.Path)
+		if !contains(document.AllowedMethods, route.Method) {
+			document.AllowedMethods = append(document.AllowedMethods, route.Method)
+		}
+	}
+	sort.Strings(document.AllowedMethods)
+	return document
+}
+
+// methodCapability applies the first configured rule matching the route and method over the defaults
+func (handlers *Handlers) methodCapability(method, route string) MethodCapability {
+	capability := MethodCapability{
+		Route:          route,
+		Authentication: handlers.capabilities.DefaultAuthentication,
+	}
+	if capability.Authentication == "" {
+		capability.Authentication = AuthenticationRequired
+	}
+	rule := handlers.capabilityRule(method, route)
+	if rule == nil {
+		return capability
+	}
+	if rule.Authentication != "" {
+		capability.Authentication = rule.Authentication
+	}
+	capability.Roles = rule.Roles
+	if rule.RateLimit > 0 {
+		capability.RateLimit = &RateLimit{RequestsPerSecond: rule.RateLimit, Burst: rule.RateLimitBurst}
+	}
+	capability.Deprecated = rule.Deprecated || rule.Sunset != ""
+	capability.Sunset = rule.Sunset
+	capability.Replacement = rule.Replacement
+	return capability
+}
+
+func (handlers *Handlers) capabilityRule(method, route string) *config.RouteCapabilityConfig {
+	for index := range handlers.capabilities.Routes {
+		rule := &handlers.capabilities.Routes[index]
+		if !matches(rule.Path, route) {
+			continue
+		}
+		if len(rule.Methods) == 0 || containsFold(rule.Methods, method) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, existing := range values {
+		if strings.EqualFold(existing, value) {
+			return true
+		}
+	}
+	return false
+}
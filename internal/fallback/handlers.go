@@ -0,0 +1,203 @@
+package fallback
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// maxSuggestions is the maximum number of similar routes suggested for an unknown route
+const maxSuggestions = 3
+
+// Suggestion is a registered route resembling the requested one
+type Suggestion struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// Handlers answers unknown routes and methods with structured JSON errors
+type Handlers struct {
+	router           *gin.Engine
+	documentationURL string
+	capabilities     config.CapabilitiesConfig
+	routes           gin.RoutesInfo
+	once             sync.Once
+}
+
+// Register replaces the default gin 404 and 405 responses of the router
+func Register(router *gin.Engine, configurations *config.Config) *Handlers {
+	handlers := &Handlers{
+		router:           router,
+		documentationURL: configurations.Documentation.OpenAPIURL,
+		capabilities:     configurations.Capabilities,
+	}
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(handlers.NoRoute)
+	router.NoMethod(handlers.NoMethod)
+	return handlers
+}
+
+// registeredRoutes returns the routes of the router, read once every route has been registered
+func (handlers *Handlers) registeredRoutes() gin.RoutesInfo {
+	handlers.once.Do(func() {
+		handlers.routes = handlers.router.Routes()
+	})
+	return handlers.routes
+}
+
+// NoRoute answers requests to unknown routes suggesting the closest registered ones
+func (handlers *Handlers) NoRoute(ctx *gin.Context) {
+	details := handlers.details(ctx, map[string]string{})
+	for _, suggestion := range handlers.suggest(ctx.Request.URL.Path) {
+		details = append(details, errors.Metadata(errors.SuggestionDetail, map[string]string{
+			"method": suggestion.Method,
+			"path":   suggestion.Path,
+		}))
+	}
+	if logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context()); err == nil {
+		logger.Warn("No route matches the requested path")
+	}
+	errors.Abort(ctx, errors.RouteNotFound, details...)
+}
+
+// NoMethod answers requests using a method the route does not support, listing the allowed ones,
+// and OPTIONS requests with the capability document of the route when enabled
+func (handlers *Handlers) NoMethod(ctx *gin.Context) {
+	if ctx.Request.Method == http.MethodOptions && handlers.capabilities.Enabled {
+		handlers.Capabilities(ctx)
+		return
+	}
+	allowedMethods := handlers.allowedMethods(ctx.Request.URL.Path)
+	allow := strings.Join(allowedMethods, ", ")
+	ctx.Header("Allow", allow)
+	details := handlers.details(ctx, map[string]string{"allowed_methods": allow})
+	errors.Abort(ctx, errors.MethodNotAllowed, details...)
+}
+
+// details returns the request detail carrying the method, the path and the metadata, followed by the help
+// detail of the documentation when configured
+func (handlers *Handlers) details(ctx *gin.Context, metadata map[string]string) []errors.Detail {
+	metadata["method"] = ctx.Request.Method
+	metadata["path"] = ctx.Request.URL.Path
+	details := []errors.Detail{errors.Metadata(errors.RequestDetail, metadata)}
+	if handlers.documentationURL != "" {
+		details = append(details, errors.Metadata(errors.HelpDetail, map[string]string{"url": handlers.documentationURL}))
+	}
+	return details
+}
+
+func (handlers *Handlers) allowedMethods(path string) []string {
+	methods := []string{}
+	for _, route := range handlers.registeredRoutes() {
+		if matches(route.Path, path) && !contains(methods, route.Method) {
+			methods = append(methods, route.Method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+type scoredSuggestion struct {
+	suggestion Suggestion
+	distance   int
+}
+
+// suggest returns the registered routes within a small edit distance of the path, closest first
+func (handlers *Handlers) suggest(path string) []Suggestion {
+	maxDistance := len(path) / 4
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	scored := []scoredSuggestion{}
+	for _, route := range handlers.registeredRoutes() {
+		distance := levenshtein(strings.ToLower(path), strings.ToLower(fillParameters(route.Path, path)))
+		if distance <= maxDistance {
+			scored = append(scored, scoredSuggestion{
+				suggestion: Suggestion{Method: route.Method, Path: route.Path},
+				distance:   distance,
+			})
+		}
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].distance != scored[j].distance {
+			return scored[i].distance < scored[j].distance
+		}
+		return scored[i].suggestion.Path < scored[j].suggestion.Path
+	})
+	suggestions := []Suggestion{}
+	for _, candidate := range scored {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, candidate.suggestion)
+	}
+	return suggestions
+}
+
+// matches tells whether the path matches the route pattern with its :param and *wildcard segments
+func matches(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for index, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if index >= len(pathSegments) {
+			return false
+		}
+		if !strings.HasPrefix(segment, ":") && segment != pathSegments[index] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+// fillParameters replaces the parameters of the route pattern with the path segments in the same position,
+// aligning the segments as matches does so surrounding slashes do not shift them
+func fillParameters(pattern, path string) string {
+	trimmedPattern := strings.Trim(pattern, "/")
+	patternSegments := strings.Split(trimmedPattern, "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for index, segment := range patternSegments {
+		if index < len(pathSegments) && (strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*")) {
+			patternSegments[index] = pathSegments[index]
+		}
+	}
+	return strings.Replace(pattern, trimmedPattern, strings.Join(patternSegments, "/"), 1)
+}
+
+func levenshtein(source, target string) int {
+	previous := make([]int, len(target)+1)
+	for index := range previous {
+		previous[index] = index
+	}
+	for i := 1; i <= len(source); i++ {
+		current := make([]int, len(target)+1)
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(target)]
+}
+
+func contains(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package fallback
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	Register(router, &config.Config{
+		Documentation: config.DocumentationConfig{OpenAPIURL: "https://docs.example.com/openapi.json"},
+	})
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	router.GET("/api/v1/user/profile", ok)
+	router.PUT("/api/v1/user/profile", ok)
+	router.GET("/api/v1/media/:mediaID", ok)
+	router.POST("/api/v1/payments/charges", ok)
+	return router
+}
+
+func TestHandlers(t *testing.T) {
+	t.Run("NoRoute_Should_Suggest_Similar_Routes", func(t *testing.T) {
+		router := newTestRouter()
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/user/profle", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"error": {
+			"code": "route_not_found",
+			"message": "No route matches the requested path",
+			"details": [
+				{"type": "request", "metadata": {"method": "GET", "path": "/api/v1/user/profle"}},
+				{"type": "help", "metadata": {"url": "https://docs.example.com/openapi.json"}},
+				{"type": "suggestion", "metadata": {"method": "GET", "path": "/api/v1/user/profile"}},
+				{"type": "suggestion", "metadata": {"method": "PUT", "path": "/api/v1/user/profile"}}
+			]
+		}}`, w.Body.String())
+	})
+
+	t.Run("NoRoute_Should_Match_Parameters_When_Suggesting", func(t *testing.T) {
+		router := newTestRouter()
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/medias/123", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `{"type":"suggestion","metadata":{"method":"GET","path":"/api/v1/media/:mediaID"}}`)
+	})
+
+	t.Run("NoRoute_Should_Omit_Suggestions_For_Unrelated_Paths", func(t *testing.T) {
+		router := newTestRouter()
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wp-admin/setup.php", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NotContains(t, w.Body.String(), "suggestion")
+	})
+
+	t.Run("NoMethod_Should_List_Allowed_Methods", func(t *testing.T) {
+		router := newTestRouter()
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/user/profile", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, "GET, PUT", w.Header().Get("Allow"))
+		assert.Contains(t, w.Body.String(), `"allowed_methods":"GET, PUT"`)
+		assert.Contains(t, w.Body.String(), `"code":"method_not_allowed"`)
+	})
+
+	t.Run("NoMethod_Should_Answer_Options_With_The_Capability_Document", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		Register(router, &config.Config{Capabilities: config.CapabilitiesConfig{
+			Enabled: true,
+			Routes: []config.RouteCapabilityConfig{
+				{Path: "/api/v1/user/profile", Methods: []string{"put"}, Roles: []string{"editor"}, Sunset: "2027-01-01", Replacement: "/api/v2/user/profile"},
+				{Path: "/api/v1/user/*path", Authentication: AuthenticationNone, RateLimit: 0.5, RateLimitBurst: 5},
+			},
+		}})
+		ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+		router.GET("/api/v1/user/profile", ok)
+		router.PUT("/api/v1/user/profile", ok)
+		router.GET("/api/v1/media/:mediaID", ok)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/api/v1/user/profile", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "GET, OPTIONS, PUT", w.Header().Get("Allow"))
+		assert.JSONEq(t, `{
+			"path": "/api/v1/user/profile",
+			"allowed_methods": ["GET", "OPTIONS", "PUT"],
+			"methods": {
+				"GET": {
+					"route": "/api/v1/user/profile",
+					"authentication": "none",
+					"rate_limit": {"requests_per_second": 0.5, "burst": 5},
+					"deprecated": false
+				},
+				"PUT": {
+					"route": "/api/v1/user/profile",
+					"authentication": "required",
+					"roles": ["editor"],
+					"deprecated": true,
+					"sunset": "2027-01-01",
+					"replacement": "/api/v2/user/profile"
+				}
+			}
+		}`, w.Body.String())
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/api/v1/media/123", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"GET":{"route":"/api/v1/media/:mediaID","authentication":"required","deprecated":false}`)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/api/v1/unknown", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("NoMethod_Should_Reject_Options_When_Capabilities_Are_Disabled", func(t *testing.T) {
+		router := newTestRouter()
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/api/v1/user/profile", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
+
+func FuzzMatches(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"/api/v1/user/profile", "/api/v1/user/profile"},
+		{"/api/v1/media/:mediaID", "/api/v1/media/media-id"},
+		{"/api/v1/admin/*path", "/api/v1/admin/users/user-id"},
+		{"/api/v1/media/:mediaID", "/api/v1/media"},
+		{"/", "//"},
+		{"", "/api/v1/user/profile/"},
+		{":/v1/user/profile", "/0/v1/user/profile"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, pattern, path string) {
+		matched := matches(pattern, path)
+
+		if !strings.ContainsAny(pattern, ":*") && matched != (strings.Trim(pattern, "/") == strings.Trim(path, "/")) {
+			t.Fatalf("The literal pattern %q matching %q is %t", pattern, path, matched)
+		}
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		for index := range segments {
+			segments[index] = ":parameter"
+		}
+		if parameters := "/" + strings.Join(segments, "/"); !matches(parameters, path) {
+			t.Fatalf("The pattern %q of parameters does not match %q", parameters, path)
+		}
+		if filled := fillParameters(pattern, path); matched && !strings.Contains(pattern, "*") && strings.Trim(filled, "/") != strings.Trim(path, "/") {
+			t.Fatalf("The pattern %q filled with the matching %q is %q", pattern, path, filled)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package grpcjson
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// Name is the content subtype of the JSON codec, sent as application/grpc+json
+const Name = "json"
+
+// codec encodes gRPC messages as JSON so backends without generated protos can be called
+type codec struct{}
+
+func (codec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (codec) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+func (codec) Name() string {
+	return Name
+}
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// Invoke calls a unary gRPC method using JSON encoded messages
+func Invoke(
+	ctx context.Context,
+	connection grpc.ClientConnInterface,
+	method string,
+	request,
+	response interface{},
+	opts ...grpc.CallOption,
+) error {
+	return connection.Invoke(ctx, method, request, response, append(opts, grpc.CallContentSubtype(Name))...)
+}
+
+// NewStream opens a gRPC stream using JSON encoded messages
+func NewStream(
+	ctx context.Context,
+	connection grpc.ClientConnInterface,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	return connection.NewStream(ctx, desc, method, append(opts, grpc.CallContentSubtype(Name))...)
+}
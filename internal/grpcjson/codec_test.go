@@ -0,0 +1,30 @@
+package grpcjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/encoding"
+)
+
+type testMessage struct {
+	ID   string `json:"id"`
+	Read bool   `json:"read"`
+}
+
+func TestCodec(t *testing.T) {
+	t.Run("Codec_Should_Be_Registered", func(t *testing.T) {
+		assert.NotNil(t, encoding.GetCodec(Name))
+	})
+
+	t.Run("Codec_Should_Round_Trip_Messages", func(t *testing.T) {
+		data, err := codec{}.Marshal(&testMessage{ID: "test-id", Read: true})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":"test-id","read":true}`, string(data))
+
+		var message testMessage
+		err = codec{}.Unmarshal(data, &message)
+		assert.NoError(t, err)
+		assert.Equal(t, testMessage{ID: "test-id", Read: true}, message)
+	})
+}
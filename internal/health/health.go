@@ -0,0 +1,164 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+)
+
+// Paths of the probes
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// Statuses of the gateway readiness
+const (
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+	StatusDraining = "draining"
+)
+
+// StatusUnreachable is the status of a backend whose health could not be checked
+const StatusUnreachable = "UNREACHABLE"
+
+const defaultTimeout = 2 * time.Second
+
+// Dependency is the health of a backend, its status is the serving status of the gRPC health protocol
+type Dependency struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+type backend struct {
+	name    string
+	service string
+	client  grpc_health_v1.HealthClient
+}
+
+// Drainer tells whether the gateway is shutting down
+type Drainer interface {
+	Draining() bool
+}
+
+// Checker checks the health of the backends the gateway depends on
+type Checker struct {
+	configurations *config.Config
+	timeout        time.Duration
+	backends       []backend
+	drainer        Drainer
+	mtx            sync.RWMutex
+}
+
+// NewChecker creates the health checker of the configuration, without backends
+func NewChecker(configurations *config.Config) *Checker {
+	timeout := configurations.Health.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Checker{configurations: configurations, timeout: timeout}
+}
+
+// Add checks the backend over the connection, asking for its configured health service
+func (checker *Checker) Add(name string, connection grpc.ClientConnInterface) {
+	checker.mtx.Lock()
+	defer checker.mtx.Unlock()
+	checker.backends = append(checker.backends, backend{
+		name:    name,
+		service: checker.configurations.Health.Services[name],
+		client:  grpc_health_v1.NewHealthClient(connection),
+	})
+}
+
+// UseDrainer fails the readiness once the gateway is shutting down, so no new requests are routed to it
+func (checker *Checker) UseDrainer(drainer Drainer) {
+	checker.drainer = drainer
+}
+
+// Dial connects to the backend as the gateway routes do and checks it
+func (checker *Checker) Dial(name, address string, tlsEnabled bool) error {
+	connection, err := egress.CreateServiceConnection(name, address, tlsEnabled, checker.configurations)
+	if err != nil {
+		return err
+	}
+	checker.Add(name, connection)
+	return nil
+}
+
+// Check checks the backends at once, the gateway is ready when every backend is serving
+func (checker *Checker) Check(ctx context.Context) (bool, map[string]Dependency) {
+	checker.mtx.RLock()
+	backends := append([]backend{}, checker.backends...)
+	checker.mtx.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, checker.timeout)
+	defer cancel()
+	dependencies := make(map[string]Dependency, len(backends))
+	var dependenciesMtx sync.Mutex
+	var wait sync.WaitGroup
+	for _, checked := range backends {
+		wait.Add(1)
+		go func(checked backend) {
+			defer wait.Done()
+			dependency := checked.check(ctx)
+			dependenciesMtx.Lock()
+			dependencies[checked.name] = dependency
+			dependenciesMtx.Unlock()
+		}(checked)
+	}
+	wait.Wait()
+
+	ready := true
+	for _, dependency := range dependencies {
+		ready = ready && dependency.Status == grpc_health_v1.HealthCheckResponse_SERVING.String()
+	}
+	return ready, dependencies
+}
+
+func (checked backend) check(ctx context.Context) Dependency {
+	start := time.Now()
+	response, err := checked.client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: checked.service})
+	dependency := Dependency{LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		dependency.Status = StatusUnreachable
+		dependency.Error = err.Error()
+		return dependency
+	}
+	dependency.Status = response.GetStatus().String()
+	return dependency
+}
+
+// Liveness reports the gateway is up, without checking the backends so their outages do not restart it
+func (checker *Checker) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness reports the health of every backend, answering 503 while one of them is not serving or the gateway
+// is shutting down
+func (checker *Checker) Readiness(ctx *gin.Context) {
+	if checker.drainer != nil && checker.drainer.Draining() {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": StatusDraining})
+		return
+	}
+	ready, dependencies := checker.Check(ctx.Request.Context())
+	if !ready {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": StatusNotReady, "dependencies": dependencies})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": StatusReady, "dependencies": dependencies})
+}
+
+// Register serves the probes at the root of the router, apart from the API routes
+func (checker *Checker) Register(router *gin.Engine) {
+	router.GET(LivenessPath, checker.Liveness)
+	router.GET(ReadinessPath, checker.Readiness)
+}
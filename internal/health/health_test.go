@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// fakeHealthConnection answers the health checks with the status, or fails them with the error
+type fakeHealthConnection struct {
+	status  grpc_health_v1.HealthCheckResponse_ServingStatus
+	err     error
+	service string
+}
+
+func (connection *fakeHealthConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	if connection.err != nil {
+		return connection.err
+	}
+	connection.service = args.(*grpc_health_v1.HealthCheckRequest).GetService()
+	reply.(*grpc_health_v1.HealthCheckResponse).Status = connection.status
+	return nil
+}
+
+func (connection *fakeHealthConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Error(codes.Unimplemented, "streams are not supported")
+}
+
+type readinessResponse struct {
+	Status       string                `json:"status"`
+	Dependencies map[string]Dependency `json:"dependencies"`
+}
+
+// drainingGateway stands for the lifecycle of a gateway shutting down
+type drainingGateway struct{}
+
+func (drainingGateway) Draining() bool { return true }
+
+func serveProbe(checker *Checker, path string) (int, readinessResponse) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	checker.Register(router)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	response := readinessResponse{}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder.Code, response
+}
+
+func TestChecker(t *testing.T) {
+	t.Run("Readiness_Should_Be_Ready_When_Every_Backend_Serves", func(t *testing.T) {
+		checker := NewChecker(&config.Config{Health: config.HealthConfig{
+			Services: map[string]string{"authentication": "authentication.AuthenticationService"},
+		}})
+		authentication := &fakeHealthConnection{status: grpc_health_v1.HealthCheckResponse_SERVING}
+		checker.Add("authentication", authentication)
+		checker.Add("payment", &fakeHealthConnection{status: grpc_health_v1.HealthCheckResponse_SERVING})
+
+		code, response := serveProbe(checker, ReadinessPath)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, StatusReady, response.Status)
+		assert.Equal(t, "SERVING", response.Dependencies["authentication"].Status)
+		assert.Equal(t, "SERVING", response.Dependencies["payment"].Status)
+		assert.Equal(t, "authentication.AuthenticationService", authentication.service)
+	})
+
+	t.Run("Readiness_Should_Report_The_Backends_Not_Serving", func(t *testing.T) {
+		checker := NewChecker(&config.Config{})
+		checker.Add("authentication", &fakeHealthConnection{status: grpc_health_v1.HealthCheckResponse_SERVING})
+		checker.Add("payment", &fakeHealthConnection{status: grpc_health_v1.HealthCheckResponse_NOT_SERVING})
+		checker.Add("media", &fakeHealthConnection{err: status.Error(codes.Unavailable, "connection refused")})
+
+		code, response := serveProbe(checker, ReadinessPath)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, StatusNotReady, response.Status)
+		assert.Equal(t, "SERVING", response.Dependencies["authentication"].Status)
+		assert.Equal(t, "NOT_SERVING", response.Dependencies["payment"].Status)
+		assert.Equal(t, StatusUnreachable, response.Dependencies["media"].Status)
+		assert.Contains(t, response.Dependencies["media"].Error, "connection refused")
+	})
+
+	t.Run("Readiness_Should_Fail_While_Draining", func(t *testing.T) {
+		checker := NewChecker(&config.Config{})
+		checker.Add("authentication", &fakeHealthConnection{status: grpc_health_v1.HealthCheckResponse_SERVING})
+		checker.UseDrainer(drainingGateway{})
+
+		code, response := serveProbe(checker, ReadinessPath)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, StatusDraining, response.Status)
+	})
+
+	t.Run("Liveness_Should_Not_Check_The_Backends", func(t *testing.T) {
+		checker := NewChecker(&config.Config{})
+		checker.Add("authentication", &fakeHealthConnection{err: status.Error(codes.Unavailable, "connection refused")})
+
+		code, response := serveProbe(checker, LivenessPath)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", response.Status)
+	})
+}
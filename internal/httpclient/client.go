@@ -0,0 +1,117 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+)
+
+var tlsVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Policy is the resolved outbound policy of a named client
+type Policy struct {
+	Timeout         time.Duration
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// PolicyFor returns the default outbound policy with the overrides of the named client applied
+func PolicyFor(name string, configurations *config.Config) Policy {
+	outbound := configurations.OutboundHTTP
+	policy := Policy{
+		Timeout:         outbound.Timeout,
+		MaxRetries:      outbound.MaxRetries,
+		RetryBackoff:    outbound.RetryBackoff,
+		MaxRetryBackoff: outbound.MaxRetryBackoff,
+	}
+	if override, exists := outbound.Clients[name]; exists {
+		if override.Timeout > 0 {
+			policy.Timeout = override.Timeout
+		}
+		if override.MaxRetries != nil {
+			policy.MaxRetries = *override.MaxRetries
+		}
+	}
+	return policy
+}
+
+// New creates the HTTP client of a feature with the shared timeouts, retries, proxy, TLS settings and metrics.
+// The egress proxy, when in use, takes precedence over the outbound proxy URL.
+func New(name string, configurations *config.Config) (*http.Client, error) {
+	transport, err := newTransport(configurations)
+	if err != nil {
+		return nil, err
+	}
+	policy := PolicyFor(name, configurations)
+	return &http.Client{
+		Timeout: policy.Timeout,
+		Transport: &retryTransport{
+			next:    transport,
+			name:    name,
+			policy:  policy,
+			metrics: DefaultMetrics,
+			sleep:   sleepContext,
+		},
+	}, nil
+}
+
+func newTransport(configurations *config.Config) (*http.Transport, error) {
+	outbound := configurations.OutboundHTTP
+	minVersion, exists := tlsVersions[outbound.MinTLSVersion]
+	if !exists {
+		return nil, fmt.Errorf("Unsupported minimum TLS version: %s", outbound.MinTLSVersion)
+	}
+	tlsConfig := &tls.Config{MinVersion: minVersion}
+	if outbound.CAFile != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		caCertificates, err := os.ReadFile(outbound.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read outbound CA file: %v", err)
+		}
+		if !rootCAs.AppendCertsFromPEM(caCertificates) {
+			return nil, fmt.Errorf("No certificates found in outbound CA file %s", outbound.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if egressProxy := egress.Current(); egressProxy != nil {
+		proxy = egressProxy.HTTPProxy
+	} else if outbound.ProxyURL != "" {
+		proxyURL, err := url.Parse(outbound.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid outbound proxy URL: %v", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   outbound.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   outbound.TLSHandshakeTimeout,
+		IdleConnTimeout:       outbound.IdleConnTimeout,
+		MaxIdleConnsPerHost:   outbound.MaxIdleConnsPerHost,
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: time.Second,
+	}, nil
+}
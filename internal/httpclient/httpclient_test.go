@@ -0,0 +1,138 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newTestClient(server *httptest.Server, metrics *Metrics, maxRetries int) *http.Client {
+	return &http.Client{
+		Transport: &retryTransport{
+			next:    server.Client().Transport,
+			name:    "test",
+			policy:  Policy{MaxRetries: maxRetries, RetryBackoff: time.Millisecond, MaxRetryBackoff: time.Millisecond},
+			metrics: metrics,
+			sleep:   func(ctx context.Context, delay time.Duration) error { return nil },
+		},
+	}
+}
+
+func flakyServer(failures int32, status int) (*httptest.Server, *int32) {
+	calls := new(int32)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})), calls
+}
+
+func TestRetryTransport(t *testing.T) {
+	t.Run("RoundTrip_Should_Retry_Transient_Idempotent_Requests", func(t *testing.T) {
+		server, calls := flakyServer(2, http.StatusServiceUnavailable)
+		defer server.Close()
+		metrics := NewMetrics()
+
+		response, err := newTestClient(server, metrics, 2).Get(server.URL)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+		stats := metrics.Stats()["test"]
+		assert.Equal(t, int64(3), stats.Requests)
+		assert.Equal(t, int64(2), stats.Retries)
+		assert.Equal(t, int64(2), stats.Failures)
+		assert.Equal(t, map[string]int64{"5xx": 2, "2xx": 1}, stats.Statuses)
+	})
+
+	t.Run("RoundTrip_Should_Not_Retry_Posts_Without_Idempotency_Key", func(t *testing.T) {
+		server, calls := flakyServer(1, http.StatusBadGateway)
+		defer server.Close()
+
+		response, err := newTestClient(server, NewMetrics(), 2).Post(server.URL, "text/plain", strings.NewReader("body"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+
+	t.Run("RoundTrip_Should_Retry_Posts_With_Idempotency_Key", func(t *testing.T) {
+		bodies := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if len(bodies) == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		request, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+		request.Header.Set(IdempotencyKeyHeader, "key-1")
+
+		response, err := newTestClient(server, NewMetrics(), 1).Do(request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+	})
+
+	t.Run("RoundTrip_Should_Stop_After_Max_Retries", func(t *testing.T) {
+		server, calls := flakyServer(10, http.StatusGatewayTimeout)
+		defer server.Close()
+
+		response, err := newTestClient(server, NewMetrics(), 1).Get(server.URL)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusGatewayTimeout, response.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+}
+
+func TestNew(t *testing.T) {
+	retries := 0
+	configurations := &config.Config{
+		OutboundHTTP: config.OutboundHTTPConfig{
+			Timeout:    10 * time.Second,
+			MaxRetries: 2,
+			Clients: map[string]config.OutboundClientConfig{
+				"captcha": {Timeout: 3 * time.Second, MaxRetries: &retries},
+			},
+		},
+	}
+
+	t.Run("PolicyFor_Should_Apply_Client_Overrides", func(t *testing.T) {
+		assert.Equal(t, Policy{Timeout: 3 * time.Second}, PolicyFor("captcha", configurations))
+		assert.Equal(t, Policy{Timeout: 10 * time.Second, MaxRetries: 2}, PolicyFor("webhooks", configurations))
+	})
+
+	t.Run("New_Should_Reject_Invalid_Settings", func(t *testing.T) {
+		for _, outbound := range []config.OutboundHTTPConfig{
+			{MinTLSVersion: "1.0"},
+			{ProxyURL: "://proxy"},
+			{CAFile: "missing.pem"},
+		} {
+			_, err := New("test", &config.Config{OutboundHTTP: outbound})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("New_Should_Configure_Timeout", func(t *testing.T) {
+		client, err := New("captcha", configurations)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3*time.Second, client.Timeout)
+	})
+}
@@ -0,0 +1,90 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientStats are the counters of the outbound requests of a named client
+type ClientStats struct {
+	Requests         int64            `json:"requests"`
+	Retries          int64            `json:"retries"`
+	Failures         int64            `json:"failures"`
+	Statuses         map[string]int64 `json:"statuses"`
+	TotalLatencyMs   int64            `json:"totalLatencyMs"`
+	AverageLatencyMs float64          `json:"averageLatencyMs"`
+}
+
+// Metrics aggregates the outbound request counters per client
+type Metrics struct {
+	clients map[string]*ClientStats
+	mtx     sync.Mutex
+}
+
+// DefaultMetrics is the registry used by the clients created with New
+var DefaultMetrics = NewMetrics()
+
+// NewMetrics creates an empty metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{clients: make(map[string]*ClientStats)}
+}
+
+func (metrics *Metrics) client(name string) *ClientStats {
+	stats, exists := metrics.clients[name]
+	if !exists {
+		stats = &ClientStats{Statuses: make(map[string]int64)}
+		metrics.clients[name] = stats
+	}
+	return stats
+}
+
+func (metrics *Metrics) recordAttempt(name string, response *http.Response, err error, latency time.Duration) {
+	metrics.mtx.Lock()
+	defer metrics.mtx.Unlock()
+	stats := metrics.client(name)
+	stats.Requests++
+	stats.TotalLatencyMs += latency.Milliseconds()
+	if err != nil {
+		stats.Failures++
+		stats.Statuses["error"]++
+		return
+	}
+	if response.StatusCode >= http.StatusInternalServerError {
+		stats.Failures++
+	}
+	stats.Statuses[fmt.Sprintf("%dxx", response.StatusCode/100)]++
+}
+
+func (metrics *Metrics) recordRetry(name string) {
+	metrics.mtx.Lock()
+	defer metrics.mtx.Unlock()
+	metrics.client(name).Retries++
+}
+
+// Stats returns a copy of the counters of every client
+func (metrics *Metrics) Stats() map[string]ClientStats {
+	metrics.mtx.Lock()
+	defer metrics.mtx.Unlock()
+	stats := make(map[string]ClientStats, len(metrics.clients))
+	for name, clientStats := range metrics.clients {
+		copied := *clientStats
+		copied.Statuses = make(map[string]int64, len(clientStats.Statuses))
+		for status, count := range clientStats.Statuses {
+			copied.Statuses[status] = count
+		}
+		if copied.Requests > 0 {
+			copied.AverageLatencyMs = float64(copied.TotalLatencyMs) / float64(copied.Requests)
+		}
+		stats[name] = copied
+	}
+	return stats
+}
+
+// StatsHandler answers with the counters of every outbound client
+func (metrics *Metrics) StatsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"clients": metrics.Stats()})
+}
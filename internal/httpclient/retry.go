@@ -0,0 +1,109 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// IdempotencyKeyHeader marks requests that are safe to retry whatever their method
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// retryTransport retries idempotent requests failing with network errors or transient statuses
+type retryTransport struct {
+	next    http.RoundTripper
+	name    string
+	policy  Policy
+	metrics *Metrics
+	sleep   func(ctx context.Context, delay time.Duration) error
+}
+
+// RoundTrip sends the request, retrying it with exponential backoff while the policy allows it
+func (transport *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	retryable := isRetryable(request)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if request.GetBody != nil {
+				body, err := request.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				request = request.Clone(request.Context())
+				request.Body = body
+			}
+			transport.metrics.recordRetry(transport.name)
+		}
+		start := time.Now()
+		response, err := transport.next.RoundTrip(request)
+		transport.metrics.recordAttempt(transport.name, response, err, time.Since(start))
+
+		if !retryable || attempt >= transport.policy.MaxRetries || !isTransient(response, err) {
+			return response, err
+		}
+		delay := transport.backoff(attempt, response)
+		if response != nil {
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+		if err := transport.sleep(request.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff doubles the delay on every attempt with full jitter, honouring the Retry-After of the upstream
+func (transport *retryTransport) backoff(attempt int, response *http.Response) time.Duration {
+	if response != nil {
+		if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return minDuration(time.Duration(seconds)*time.Second, transport.policy.MaxRetryBackoff)
+		}
+	}
+	delay := minDuration(transport.policy.RetryBackoff<<attempt, transport.policy.MaxRetryBackoff)
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+func isRetryable(request *http.Request) bool {
+	if request.Body != nil && request.Body != http.NoBody && request.GetBody == nil {
+		return false
+	}
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return request.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+func isTransient(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func minDuration(duration, maximum time.Duration) time.Duration {
+	if maximum > 0 && duration > maximum {
+		return maximum
+	}
+	return duration
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,327 @@
+package journal
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	mathRand "math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+const (
+	segmentPrefix        = "journal-"
+	segmentSuffix        = ".log"
+	segmentLayout        = "2006010215"
+	defaultFlushInterval = time.Second
+	defaultMaxBuffered   = 10000
+)
+
+// Envelope is the journaled metadata of a request, the body is only kept when body capture is enabled
+type Envelope struct {
+	Timestamp     time.Time         `json:"timestamp"`
+	Method        string            `json:"method"`
+	Route         string            `json:"route"`
+	Path          string            `json:"path"`
+	Status        int               `json:"status"`
+	LatencyMs     int64             `json:"latencyMs"`
+	ClientIP      string            `json:"clientIP"`
+	UserID        string            `json:"userID,omitempty"`
+	CorrelationID string            `json:"correlationID,omitempty"`
+	RequestBytes  int64             `json:"requestBytes"`
+	ResponseBytes int               `json:"responseBytes"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          []byte            `json:"body,omitempty"`
+	BodyTruncated bool              `json:"bodyTruncated,omitempty"`
+}
+
+// Journal samples the request envelopes and appends them encrypted to hourly segment files
+type Journal struct {
+	directory     string
+	aead          cipher.AEAD
+	sampleRate    float64
+	sampleErrors  bool
+	headers       []string
+	captureBodies bool
+	maxBodyBytes  int
+	flushInterval time.Duration
+	maxBuffered   int
+	retention     time.Duration
+	maxBytes      int64
+	pending       []*Envelope
+	dropped       int64
+	random        *mathRand.Rand
+	logger        commonLogger.Loggerer
+	mtx           sync.Mutex
+	writeMtx      sync.Mutex
+}
+
+// NewJournal creates the journal of the configuration, the encryption key is a base64 encoded AES key
+func NewJournal(configurations *config.Config) (*Journal, error) {
+	journalConfig := configurations.Journal
+	if journalConfig.Directory == "" {
+		return nil, fmt.Errorf("The journal directory is required")
+	}
+	aead, err := NewCipher(journalConfig.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(journalConfig.Directory, 0700); err != nil {
+		return nil, fmt.Errorf("Could not create the journal directory: %v", err)
+	}
+	journal := &Journal{
+		directory:     journalConfig.Directory,
+		aead:          aead,
+		sampleRate:    journalConfig.SampleRate,
+		sampleErrors:  journalConfig.SampleErrors,
+		headers:       journalConfig.Headers,
+		captureBodies: journalConfig.CaptureBodies,
+		maxBodyBytes:  journalConfig.MaxBodyBytes,
+		flushInterval: journalConfig.FlushInterval,
+		maxBuffered:   journalConfig.MaxBuffered,
+		retention:     journalConfig.Retention,
+		maxBytes:      journalConfig.MaxBytes,
+		random:        mathRand.New(mathRand.NewSource(time.Now().UnixNano())),
+		logger:        commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}
+	if journal.flushInterval <= 0 {
+		journal.flushInterval = defaultFlushInterval
+	}
+	if journal.maxBuffered <= 0 {
+		journal.maxBuffered = defaultMaxBuffered
+	}
+	return journal, nil
+}
+
+// NewCipher creates the AES-GCM cipher of the base64 encoded 16, 24 or 32 bytes key
+func NewCipher(encodedKey string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid journal encryption key: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid journal encryption key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Middleware journals the sampled requests once they are answered, server errors are always kept when configured
+// and the bodies are left out of the routes marked with middleware.NoPayloadCapture
+func (journal *Journal) Middleware(ctx *gin.Context) {
+	start := time.Now()
+	var body []byte
+	truncated := false
+	if journal.captureBodies && ctx.Request.Body != nil && journal.maxBodyBytes > 0 {
+		body, truncated = journal.peekBody(ctx.Request)
+	}
+	ctx.Next()
+	if !middleware.PayloadCaptured(ctx) {
+		body, truncated = nil, false
+	}
+
+	status := ctx.Writer.Status()
+	if !journal.sampled(status) {
+		return
+	}
+	envelope := &Envelope{
+		Timestamp:     start.UTC(),
+		Method:        ctx.Request.Method,
+		Route:         ctx.FullPath(),
+		Path:          ctx.Request.URL.Path,
+		Status:        status,
+		LatencyMs:     time.Since(start).Milliseconds(),
+		ClientIP:      ctx.ClientIP(),
+		RequestBytes:  ctx.Request.ContentLength,
+		ResponseBytes: ctx.Writer.Size(),
+		Body:          body,
+		BodyTruncated: truncated,
+	}
+	for _, header := range journal.headers {
+		if value := ctx.Request.Header.Get(header); value != "" {
+			if envelope.Headers == nil {
+				envelope.Headers = map[string]string{}
+			}
+			envelope.Headers[http.CanonicalHeaderKey(header)] = value
+		}
+	}
+	if claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey)); exists {
+		if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+			envelope.UserID = claims.UserID
+		}
+	}
+	if correlationID, err := commonLogger.GetCorrelationIDFromContext(ctx.Request.Context()); err == nil {
+		envelope.CorrelationID = *correlationID
+	}
+	journal.Record(envelope)
+}
+
+// Record buffers the envelope until the next flush, it is dropped when the buffer is full
+func (journal *Journal) Record(envelope *Envelope) {
+	journal.mtx.Lock()
+	defer journal.mtx.Unlock()
+	if len(journal.pending) >= journal.maxBuffered {
+		journal.dropped++
+		return
+	}
+	journal.pending = append(journal.pending, envelope)
+}
+
+// Flush encrypts the buffered envelopes and appends them to the segment of their hour
+func (journal *Journal) Flush(ctx context.Context, now time.Time) error {
+	journal.mtx.Lock()
+	pending, dropped := journal.pending, journal.dropped
+	journal.pending, journal.dropped = nil, 0
+	journal.mtx.Unlock()
+	if dropped > 0 {
+		journal.logger.Warn(fmt.Sprintf("Journal buffer full, %d envelopes dropped", dropped))
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	journal.writeMtx.Lock()
+	defer journal.writeMtx.Unlock()
+	segments := map[string]*bytes.Buffer{}
+	for _, envelope := range pending {
+		line, err := journal.seal(envelope)
+		if err != nil {
+			return err
+		}
+		segment := SegmentName(envelope.Timestamp)
+		if segments[segment] == nil {
+			segments[segment] = &bytes.Buffer{}
+		}
+		segments[segment].Write(line)
+	}
+	for segment, lines := range segments {
+		if err := appendSegment(filepath.Join(journal.directory, segment), lines.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlushJob writes the buffered envelopes on every flush interval
+func (journal *Journal) FlushJob() scheduler.Job {
+	return scheduler.Job{
+		Name:     "journal_flush",
+		Schedule: scheduler.Every(journal.flushInterval),
+		Run:      journal.Flush,
+	}
+}
+
+// seal encrypts the serialized envelope into a base64 line prefixed with its nonce
+func (journal *Journal) seal(envelope *Envelope) ([]byte, error) {
+	serializedEnvelope, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("Could not serialize the journal envelope: %v", err)
+	}
+	nonce := make([]byte, journal.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("Could not generate the journal nonce: %v", err)
+	}
+	sealed := journal.aead.Seal(nonce, nonce, serializedEnvelope, nil)
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(sealed))+1)
+	base64.StdEncoding.Encode(line, sealed)
+	line[len(line)-1] = '\n'
+	return line, nil
+}
+
+func (journal *Journal) sampled(status int) bool {
+	if journal.sampleErrors && status >= http.StatusInternalServerError {
+		return true
+	}
+	if journal.sampleRate >= 1 {
+		return true
+	}
+	if journal.sampleRate <= 0 {
+		return false
+	}
+	journal.mtx.Lock()
+	defer journal.mtx.Unlock()
+	return journal.random.Float64() < journal.sampleRate
+}
+
+// peekBody reads the beginning of the request body and restores it for the next handlers
+func (journal *Journal) peekBody(request *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(request.Body, int64(journal.maxBodyBytes)+1))
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), request.Body), request.Body}
+	if err != nil {
+		return nil, false
+	}
+	if len(body) > journal.maxBodyBytes {
+		return body[:journal.maxBodyBytes], true
+	}
+	return body, false
+}
+
+// SegmentName returns the name of the segment file of the hour of the timestamp
+func SegmentName(timestamp time.Time) string {
+	return segmentPrefix + timestamp.UTC().Format(segmentLayout) + segmentSuffix
+}
+
+func appendSegment(path string, lines []byte) error {
+	segment, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Could not open the journal segment: %v", err)
+	}
+	if _, err := segment.Write(lines); err != nil {
+		segment.Close()
+		return fmt.Errorf("Could not write the journal segment: %v", err)
+	}
+	if err := segment.Sync(); err != nil {
+		segment.Close()
+		return fmt.Errorf("Could not sync the journal segment: %v", err)
+	}
+	return segment.Close()
+}
+
+// ReadSegment decrypts the envelopes of a segment file, used to reconstruct the traffic after an incident
+func ReadSegment(path string, aead cipher.AEAD) ([]*Envelope, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the journal segment: %v", err)
+	}
+	envelopes := []*Envelope{}
+	for index, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line == "" {
+			continue
+		}
+		sealed, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return nil, fmt.Errorf("Invalid journal record at line %d", index+1)
+		}
+		serializedEnvelope, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decrypt the journal record at line %d: %v", index+1, err)
+		}
+		envelope := &Envelope{}
+		if err := json.Unmarshal(serializedEnvelope, envelope); err != nil {
+			return nil, fmt.Errorf("Could not decode the journal record at line %d: %v", index+1, err)
+		}
+		envelopes = append(envelopes, envelope)
+	}
+	return envelopes, nil
+}
@@ -0,0 +1,169 @@
+package journal
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+)
+
+var testKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func newTestJournal(t *testing.T, journalConfig config.JournalConfig) *Journal {
+	journalConfig.Directory = t.TempDir()
+	journalConfig.EncryptionKey = testKey
+	journal, err := NewJournal(&config.Config{Environment: "test", Journal: journalConfig})
+	assert.NoError(t, err)
+	return journal
+}
+
+func serve(journal *Journal, status int, body string) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(journal.Middleware)
+	router.POST("/api/users/:userID", func(ctx *gin.Context) {
+		received, _ := ctx.GetRawData()
+		ctx.String(status, string(received))
+	})
+	request := httptest.NewRequest(http.MethodPost, "/api/users/42?token=secret", strings.NewReader(body))
+	request.Header.Set("User-Agent", "test-agent")
+	request.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+}
+
+func TestJournal(t *testing.T) {
+	t.Run("Middleware_Should_Journal_Encrypted_Envelopes_Without_Bodies", func(t *testing.T) {
+		journal := newTestJournal(t, config.JournalConfig{SampleRate: 1, Headers: []string{"user-agent"}})
+		serve(journal, http.StatusOK, "password=secret")
+
+		assert.NoError(t, journal.Flush(context.Background(), time.Now()))
+
+		segmentPath := filepath.Join(journal.directory, SegmentName(time.Now()))
+		content, err := os.ReadFile(segmentPath)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(content), "/api/users")
+		envelopes, err := ReadSegment(segmentPath, journal.aead)
+		assert.NoError(t, err)
+		assert.Len(t, envelopes, 1)
+		assert.Equal(t, "/api/users/:userID", envelopes[0].Route)
+		assert.Equal(t, "/api/users/42", envelopes[0].Path)
+		assert.Equal(t, http.StatusOK, envelopes[0].Status)
+		assert.Equal(t, map[string]string{"User-Agent": "test-agent"}, envelopes[0].Headers)
+		assert.Empty(t, envelopes[0].Body)
+
+		otherKey, err := NewCipher(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")))
+		assert.NoError(t, err)
+		_, err = ReadSegment(segmentPath, otherKey)
+		assert.Error(t, err)
+	})
+
+	t.Run("Middleware_Should_Capture_Truncated_Bodies_When_Enabled", func(t *testing.T) {
+		journal := newTestJournal(t, config.JournalConfig{SampleRate: 1, CaptureBodies: true, MaxBodyBytes: 5})
+		serve(journal, http.StatusCreated, "0123456789")
+
+		assert.NoError(t, journal.Flush(context.Background(), time.Now()))
+
+		envelopes, err := ReadSegment(filepath.Join(journal.directory, SegmentName(time.Now())), journal.aead)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("01234"), envelopes[0].Body)
+		assert.True(t, envelopes[0].BodyTruncated)
+		assert.Equal(t, 10, envelopes[0].ResponseBytes)
+	})
+
+	t.Run("Middleware_Should_Leave_Out_The_Bodies_Of_The_Routes_Without_Payload_Capture", func(t *testing.T) {
+		journal := newTestJournal(t, config.JournalConfig{SampleRate: 1, CaptureBodies: true, MaxBodyBytes: 64})
+		router := gin.New()
+		router.Use(journal.Middleware)
+		router.POST("/api/v1/payments/charges", middleware.NoPayloadCapture, func(ctx *gin.Context) {
+			received, _ := ctx.GetRawData()
+			ctx.String(http.StatusCreated, string(received))
+		})
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/payments/charges", strings.NewReader("card=4242424242424242")))
+
+		assert.Equal(t, "card=4242424242424242", recorder.Body.String())
+		assert.Len(t, journal.pending, 1)
+		assert.Equal(t, "/api/v1/payments/charges", journal.pending[0].Route)
+		assert.Nil(t, journal.pending[0].Body)
+		assert.False(t, journal.pending[0].BodyTruncated)
+	})
+
+	t.Run("Middleware_Should_Only_Keep_Server_Errors_When_Not_Sampled", func(t *testing.T) {
+		journal := newTestJournal(t, config.JournalConfig{SampleErrors: true})
+		serve(journal, http.StatusOK, "")
+		serve(journal, http.StatusBadGateway, "")
+
+		assert.Len(t, journal.pending, 1)
+		assert.Equal(t, http.StatusBadGateway, journal.pending[0].Status)
+	})
+
+	t.Run("Record_Should_Drop_Envelopes_When_The_Buffer_Is_Full", func(t *testing.T) {
+		journal := newTestJournal(t, config.JournalConfig{MaxBuffered: 2})
+		for index := 0; index < 3; index++ {
+			journal.Record(&Envelope{Timestamp: time.Now()})
+		}
+
+		assert.Len(t, journal.pending, 2)
+		assert.Equal(t, int64(1), journal.dropped)
+	})
+
+	t.Run("NewJournal_Should_Reject_Invalid_Keys", func(t *testing.T) {
+		_, err := NewJournal(&config.Config{Journal: config.JournalConfig{
+			Directory:     t.TempDir(),
+			EncryptionKey: base64.StdEncoding.EncodeToString([]byte("short")),
+		}})
+		assert.Error(t, err)
+	})
+}
+
+func TestRetention(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	writeSegments := func(journal *Journal, hoursAgo ...int) {
+		for _, hours := range hoursAgo {
+			name := SegmentName(now.Add(-time.Duration(hours) * time.Hour))
+			assert.NoError(t, os.WriteFile(filepath.Join(journal.directory, name), make([]byte, 100), 0600))
+		}
+	}
+	remaining := func(journal *Journal) []string {
+		segments, err := journal.segments()
+		assert.NoError(t, err)
+		names := []string{}
+		for _, segment := range segments {
+			names = append(names, segment.name)
+		}
+		return names
+	}
+
+	t.Run("Prune_Should_Delete_Segments_Older_Than_The_Retention", func(t *testing.T) {
+		journal := newTestJournal(t, config.JournalConfig{Retention: 24 * time.Hour})
+		writeSegments(journal, 0, 1, 24, 48)
+
+		assert.NoError(t, journal.Prune(context.Background(), now))
+
+		assert.Equal(t, []string{
+			SegmentName(now.Add(-24 * time.Hour)),
+			SegmentName(now.Add(-time.Hour)),
+			SegmentName(now),
+		}, remaining(journal))
+	})
+
+	t.Run("Prune_Should_Delete_The_Oldest_Segments_Over_The_Maximum_Size", func(t *testing.T) {
+		journal := newTestJournal(t, config.JournalConfig{MaxBytes: 150})
+		writeSegments(journal, 0, 1, 2)
+
+		assert.NoError(t, journal.Prune(context.Background(), now))
+
+		assert.Equal(t, []string{SegmentName(now)}, remaining(journal))
+	})
+}
@@ -0,0 +1,87 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+type segmentFile struct {
+	name string
+	hour time.Time
+	size int64
+}
+
+// Prune deletes the segments older than the retention, and then the oldest ones while the journal
+// exceeds its maximum size, the segment being written is always kept
+func (journal *Journal) Prune(ctx context.Context, now time.Time) error {
+	journal.writeMtx.Lock()
+	defer journal.writeMtx.Unlock()
+	segments, err := journal.segments()
+	if err != nil {
+		return err
+	}
+	current := SegmentName(now)
+	total := int64(0)
+	for _, segment := range segments {
+		total += segment.size
+	}
+	for _, segment := range segments {
+		if segment.name == current {
+			break
+		}
+		expired := journal.retention > 0 && now.Sub(segment.hour.Add(time.Hour)) > journal.retention
+		oversized := journal.maxBytes > 0 && total > journal.maxBytes
+		if !expired && !oversized {
+			break
+		}
+		if err := os.Remove(filepath.Join(journal.directory, segment.name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Could not delete the journal segment %s: %v", segment.name, err)
+		}
+		total -= segment.size
+	}
+	return nil
+}
+
+// RetentionJob applies the retention policy every ten minutes
+func (journal *Journal) RetentionJob() scheduler.Job {
+	return scheduler.Job{
+		Name:     "journal_retention",
+		Schedule: scheduler.Every(10 * time.Minute),
+		Run:      journal.Prune,
+	}
+}
+
+// segments lists the segment files, oldest first
+func (journal *Journal) segments() ([]segmentFile, error) {
+	entries, err := os.ReadDir(journal.directory)
+	if err != nil {
+		return nil, fmt.Errorf("Could not list the journal segments: %v", err)
+	}
+	segments := []segmentFile{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		hour, err := time.Parse(segmentLayout, strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segmentFile{name: name, hour: hour, size: info.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].hour.Before(segments[j].hour)
+	})
+	return segments, nil
+}
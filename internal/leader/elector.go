@@ -0,0 +1,130 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Leaser grants a lease to a single holder at a time
+type Leaser interface {
+	// Acquire takes or renews the lease for the holder, it returns false when another holder owns it
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	// Release gives the lease up if the holder owns it
+	Release(ctx context.Context, holder string) error
+}
+
+// Elector keeps the replica leading while it renews the lease in time
+type Elector struct {
+	leaser        Leaser
+	identity      string
+	ttl           time.Duration
+	renewInterval time.Duration
+	renewedAt     time.Time
+	logger        commonLogger.Loggerer
+	mtx           sync.Mutex
+}
+
+// NewElector creates the elector of the replica, identified by its hostname
+func NewElector(leaser Leaser, configurations *config.Config) (*Elector, error) {
+	leaderConfig := configurations.LeaderElection
+	if leaderConfig.TTL <= 0 || leaderConfig.RenewInterval <= 0 || leaderConfig.RenewInterval >= leaderConfig.TTL {
+		return nil, fmt.Errorf("The leader election renew interval must be positive and shorter than the TTL")
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "gateway"
+	}
+	return &Elector{
+		leaser:        leaser,
+		identity:      fmt.Sprintf("%s-%s", hostname, uuid.New().String()[:8]),
+		ttl:           leaderConfig.TTL,
+		renewInterval: leaderConfig.RenewInterval,
+		logger:        commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}, nil
+}
+
+// Identity returns the holder identity of the replica
+func (elector *Elector) Identity() string {
+	return elector.identity
+}
+
+// IsLeader tells whether the replica holds the lease; a leader that could not renew it
+// steps down before the lease expires so two replicas never lead at once
+func (elector *Elector) IsLeader() bool {
+	elector.mtx.Lock()
+	defer elector.mtx.Unlock()
+	return !elector.renewedAt.IsZero() && time.Since(elector.renewedAt) < elector.ttl-elector.renewInterval/2
+}
+
+// Campaign tries to take or renew the lease
+func (elector *Elector) Campaign(ctx context.Context) error {
+	attemptedAt := time.Now()
+	acquired, err := elector.leaser.Acquire(ctx, elector.identity, elector.ttl)
+
+	elector.mtx.Lock()
+	defer elector.mtx.Unlock()
+	if err != nil {
+		return fmt.Errorf("Could not renew the leader lease: %v", err)
+	}
+	if !acquired {
+		if !elector.renewedAt.IsZero() {
+			elector.logger.Warn(fmt.Sprintf("Replica %s lost the leadership", elector.identity))
+		}
+		elector.renewedAt = time.Time{}
+		return nil
+	}
+	if elector.renewedAt.IsZero() {
+		elector.logger.Info(fmt.Sprintf("Replica %s is the leader", elector.identity))
+	}
+	elector.renewedAt = attemptedAt
+	return nil
+}
+
+// Resign releases the lease so another replica takes over without waiting for it to expire
+func (elector *Elector) Resign(ctx context.Context) error {
+	elector.mtx.Lock()
+	elector.renewedAt = time.Time{}
+	elector.mtx.Unlock()
+	return elector.leaser.Release(ctx, elector.identity)
+}
+
+// Job campaigns for the lease on start and then on every renew interval
+func (elector *Elector) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:       "leader_election",
+		Schedule:   scheduler.Every(elector.renewInterval),
+		Timeout:    elector.renewInterval,
+		RunOnStart: true,
+		Run: func(ctx context.Context, now time.Time) error {
+			return elector.Campaign(ctx)
+		},
+	}
+}
+
+// Leader election backends
+const (
+	BackendRedis      = "redis"
+	BackendKubernetes = "kubernetes"
+)
+
+// NewLeaser creates the leaser of the configured backend
+func NewLeaser(configurations *config.Config) (Leaser, error) {
+	leaderConfig := configurations.LeaderElection
+	switch leaderConfig.Backend {
+	case BackendRedis:
+		return NewRedisLeaser(redis.NewClient(configurations), leaderConfig.LeaseName), nil
+	case BackendKubernetes:
+		return NewKubernetesLeaser(leaderConfig.LeaseName, leaderConfig.Namespace)
+	}
+	return nil, fmt.Errorf("Unsupported leader election backend: %s", leaderConfig.Backend)
+}
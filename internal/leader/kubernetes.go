@@ -0,0 +1,231 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// In-cluster service account files and lease time layout
+const (
+	serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+	microTimeLayout    = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// lease is the subset of the coordination.k8s.io/v1 Lease used by the leaser
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+// KubernetesLeaser keeps the lease in a Kubernetes Lease object, updated with optimistic concurrency
+type KubernetesLeaser struct {
+	baseURL   string
+	name      string
+	namespace string
+	tokenPath string
+	client    *http.Client
+	now       func() time.Time
+}
+
+var _ Leaser = &KubernetesLeaser{}
+
+// NewKubernetesLeaser creates the leaser of the Lease using the in-cluster service account,
+// the namespace defaults to the one of the pod
+func NewKubernetesLeaser(name, namespace string) (*KubernetesLeaser, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("The gateway is not running inside a Kubernetes cluster")
+	}
+	if namespace == "" {
+		podNamespace, err := os.ReadFile(path.Join(serviceAccountPath, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the pod namespace: %v", err)
+		}
+		namespace = strings.TrimSpace(string(podNamespace))
+	}
+	caCertificates, err := os.ReadFile(path.Join(serviceAccountPath, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the cluster CA: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caCertificates) {
+		return nil, fmt.Errorf("No certificates found in the cluster CA")
+	}
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}},
+	}
+	return newKubernetesLeaser("https://"+net.JoinHostPort(host, port), name, namespace, path.Join(serviceAccountPath, "token"), client), nil
+}
+
+func newKubernetesLeaser(baseURL, name, namespace, tokenPath string, client *http.Client) *KubernetesLeaser {
+	return &KubernetesLeaser{
+		baseURL:   baseURL,
+		name:      name,
+		namespace: namespace,
+		tokenPath: tokenPath,
+		client:    client,
+		now:       time.Now,
+	}
+}
+
+// Acquire creates the Lease, renews it for its holder or takes it over once expired
+func (leaser *KubernetesLeaser) Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	now := leaser.now()
+	current, err := leaser.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	if current == nil {
+		created := &lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: leaser.name, Namespace: leaser.namespace},
+			Spec: leaseSpec{
+				HolderIdentity:       holder,
+				LeaseDurationSeconds: leaseSeconds(ttl),
+				AcquireTime:          now.UTC().Format(microTimeLayout),
+				RenewTime:            now.UTC().Format(microTimeLayout),
+			},
+		}
+		return leaser.write(ctx, http.MethodPost, leaser.collectionURL(), created)
+	}
+
+	if current.Spec.HolderIdentity != holder {
+		if !leaseExpired(current, now) {
+			return false, nil
+		}
+		current.Spec.HolderIdentity = holder
+		current.Spec.AcquireTime = now.UTC().Format(microTimeLayout)
+		current.Spec.LeaseTransitions++
+	}
+	current.Spec.LeaseDurationSeconds = leaseSeconds(ttl)
+	current.Spec.RenewTime = now.UTC().Format(microTimeLayout)
+	return leaser.write(ctx, http.MethodPut, leaser.leaseURL(), current)
+}
+
+// Release empties the holder of the Lease if the holder owns it
+func (leaser *KubernetesLeaser) Release(ctx context.Context, holder string) error {
+	current, err := leaser.get(ctx)
+	if err != nil || current == nil || current.Spec.HolderIdentity != holder {
+		return err
+	}
+	current.Spec.HolderIdentity = ""
+	current.Spec.RenewTime = ""
+	_, err = leaser.write(ctx, http.MethodPut, leaser.leaseURL(), current)
+	return err
+}
+
+func (leaser *KubernetesLeaser) collectionURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", leaser.baseURL, leaser.namespace)
+}
+
+func (leaser *KubernetesLeaser) leaseURL() string {
+	return leaser.collectionURL() + "/" + leaser.name
+}
+
+func (leaser *KubernetesLeaser) get(ctx context.Context) (*lease, error) {
+	response, err := leaser.do(ctx, http.MethodGet, leaser.leaseURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Could not get the lease %s: %s", leaser.name, response.Status)
+	}
+	current := &lease{}
+	if err := json.NewDecoder(response.Body).Decode(current); err != nil {
+		return nil, fmt.Errorf("Could not decode the lease %s: %v", leaser.name, err)
+	}
+	return current, nil
+}
+
+// write creates or updates the Lease, a conflict means another replica changed it first
+func (leaser *KubernetesLeaser) write(ctx context.Context, method, url string, body *lease) (bool, error) {
+	serializedBody, err := json.Marshal(body)
+	if err != nil {
+		return false, err
+	}
+	response, err := leaser.do(ctx, method, url, serializedBody)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	switch {
+	case response.StatusCode == http.StatusConflict:
+		return false, nil
+	case response.StatusCode >= http.StatusBadRequest:
+		return false, fmt.Errorf("Could not write the lease %s: %s", leaser.name, response.Status)
+	}
+	return true, nil
+}
+
+func (leaser *KubernetesLeaser) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// The projected service account token is rotated, so it is read on every request
+	token, err := os.ReadFile(leaser.tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the service account token: %v", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	response, err := leaser.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Could not reach the Kubernetes API: %v", err)
+	}
+	return response, nil
+}
+
+func leaseExpired(current *lease, now time.Time) bool {
+	if current.Spec.HolderIdentity == "" {
+		return true
+	}
+	renewedAt, err := time.Parse(time.RFC3339Nano, current.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewedAt.Add(time.Duration(current.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+func leaseSeconds(ttl time.Duration) int {
+	seconds := int((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
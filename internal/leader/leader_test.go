@@ -0,0 +1,220 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+)
+
+type fakeLeaser struct {
+	holder string
+	err    error
+}
+
+func (leaser *fakeLeaser) Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	if leaser.err != nil {
+		return false, leaser.err
+	}
+	if leaser.holder == "" {
+		leaser.holder = holder
+	}
+	return leaser.holder == holder, nil
+}
+
+func (leaser *fakeLeaser) Release(ctx context.Context, holder string) error {
+	if leaser.holder == holder {
+		leaser.holder = ""
+	}
+	return nil
+}
+
+type fakeRedisClient struct {
+	redis.Clienter
+	commands [][]string
+	reply    interface{}
+}
+
+func (client *fakeRedisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	client.commands = append(client.commands, args)
+	return client.reply, nil
+}
+
+func newTestElector(t *testing.T, leaser Leaser, ttl, renewInterval time.Duration) *Elector {
+	elector, err := NewElector(leaser, &config.Config{
+		Environment:    "test",
+		LeaderElection: config.LeaderElectionConfig{TTL: ttl, RenewInterval: renewInterval},
+	})
+	assert.NoError(t, err)
+	return elector
+}
+
+func TestElector(t *testing.T) {
+	t.Run("NewElector_Should_Reject_Renew_Intervals_Not_Shorter_Than_The_TTL", func(t *testing.T) {
+		_, err := NewElector(&fakeLeaser{}, &config.Config{
+			LeaderElection: config.LeaderElectionConfig{TTL: time.Second, RenewInterval: time.Second},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("Campaign_Should_Lead_Only_The_Lease_Holder", func(t *testing.T) {
+		leaser := &fakeLeaser{}
+		first := newTestElector(t, leaser, time.Minute, 10*time.Second)
+		second := newTestElector(t, leaser, time.Minute, 10*time.Second)
+
+		assert.NoError(t, first.Campaign(context.Background()))
+		assert.NoError(t, second.Campaign(context.Background()))
+		assert.True(t, first.IsLeader())
+		assert.False(t, second.IsLeader())
+
+		assert.NoError(t, first.Resign(context.Background()))
+		assert.False(t, first.IsLeader())
+		assert.NoError(t, second.Campaign(context.Background()))
+		assert.True(t, second.IsLeader())
+	})
+
+	t.Run("IsLeader_Should_Step_Down_When_The_Lease_Is_Not_Renewed", func(t *testing.T) {
+		leaser := &fakeLeaser{}
+		elector := newTestElector(t, leaser, 40*time.Millisecond, 20*time.Millisecond)
+		assert.NoError(t, elector.Campaign(context.Background()))
+		assert.True(t, elector.IsLeader())
+
+		leaser.err = errors.New("redis unavailable")
+		assert.Error(t, elector.Campaign(context.Background()))
+		time.Sleep(35 * time.Millisecond)
+		assert.False(t, elector.IsLeader())
+	})
+}
+
+func TestRedisLeaser(t *testing.T) {
+	t.Run("Acquire_Should_Evaluate_The_Lease_Script", func(t *testing.T) {
+		client := &fakeRedisClient{reply: int64(1)}
+		leaser := NewRedisLeaser(client, "gateway-leader")
+
+		acquired, err := leaser.Acquire(context.Background(), "replica-a", 15*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+		assert.Equal(t, []string{"EVAL", acquireScript, "1", "gateway-leader", "replica-a", "15000"}, client.commands[0])
+
+		client.reply = int64(0)
+		acquired, err = leaser.Acquire(context.Background(), "replica-b", 15*time.Second)
+		assert.NoError(t, err)
+		assert.False(t, acquired)
+
+		assert.NoError(t, leaser.Release(context.Background(), "replica-a"))
+		assert.Equal(t, []string{"EVAL", releaseScript, "1", "gateway-leader", "replica-a"}, client.commands[2])
+	})
+}
+
+type fakeLeaseServer struct {
+	lease   *lease
+	version int
+	mtx     sync.Mutex
+}
+
+func (server *fakeLeaseServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	server.mtx.Lock()
+	defer server.mtx.Unlock()
+	if request.Header.Get("Authorization") != "Bearer cluster-token" {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch request.Method {
+	case http.MethodGet:
+		if server.lease == nil {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(writer).Encode(server.lease)
+		return
+	case http.MethodPost:
+		if server.lease != nil {
+			writer.WriteHeader(http.StatusConflict)
+			return
+		}
+	case http.MethodPut:
+		current := &lease{}
+		json.NewDecoder(request.Body).Decode(current)
+		if server.lease == nil || current.Metadata.ResourceVersion != server.lease.Metadata.ResourceVersion {
+			writer.WriteHeader(http.StatusConflict)
+			return
+		}
+		server.lease = current
+		server.version++
+		server.lease.Metadata.ResourceVersion = strconv.Itoa(server.version)
+		json.NewEncoder(writer).Encode(server.lease)
+		return
+	}
+	created := &lease{}
+	json.NewDecoder(request.Body).Decode(created)
+	server.lease = created
+	server.version++
+	server.lease.Metadata.ResourceVersion = strconv.Itoa(server.version)
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(server.lease)
+}
+
+func TestKubernetesLeaser(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenPath, []byte("cluster-token\n"), 0600))
+
+	t.Run("Acquire_Should_Create_Renew_And_Take_Over_Expired_Leases", func(t *testing.T) {
+		leaseServer := &fakeLeaseServer{}
+		server := httptest.NewServer(leaseServer)
+		defer server.Close()
+		now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+		leaser := newKubernetesLeaser(server.URL, "gateway-leader", "gateway", tokenPath, server.Client())
+		leaser.now = func() time.Time { return now }
+
+		acquired, err := leaser.Acquire(context.Background(), "replica-a", 15*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+		assert.Equal(t, "replica-a", leaseServer.lease.Spec.HolderIdentity)
+		assert.Equal(t, 15, leaseServer.lease.Spec.LeaseDurationSeconds)
+
+		now = now.Add(5 * time.Second)
+		acquired, err = leaser.Acquire(context.Background(), "replica-b", 15*time.Second)
+		assert.NoError(t, err)
+		assert.False(t, acquired)
+
+		acquired, err = leaser.Acquire(context.Background(), "replica-a", 15*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+
+		now = now.Add(20 * time.Second)
+		acquired, err = leaser.Acquire(context.Background(), "replica-b", 15*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+		assert.Equal(t, "replica-b", leaseServer.lease.Spec.HolderIdentity)
+		assert.Equal(t, 1, leaseServer.lease.Spec.LeaseTransitions)
+	})
+
+	t.Run("Release_Should_Free_The_Lease_For_Other_Replicas", func(t *testing.T) {
+		leaseServer := &fakeLeaseServer{}
+		server := httptest.NewServer(leaseServer)
+		defer server.Close()
+		leaser := newKubernetesLeaser(server.URL, "gateway-leader", "gateway", tokenPath, server.Client())
+
+		acquired, err := leaser.Acquire(context.Background(), "replica-a", 15*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+		assert.NoError(t, leaser.Release(context.Background(), "replica-a"))
+		assert.Empty(t, leaseServer.lease.Spec.HolderIdentity)
+
+		acquired, err = leaser.Acquire(context.Background(), "replica-b", 15*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+	})
+}
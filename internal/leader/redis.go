@@ -0,0 +1,62 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+)
+
+// acquireScript takes the lease when it is free and renews it when the holder owns it
+const acquireScript = `local current = redis.call('GET', KEYS[1])
+if current == false then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if current == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0`
+
+// releaseScript deletes the lease only when the holder owns it
+const releaseScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+
+// RedisLeaser keeps the lease in a redis key expiring with the TTL
+type RedisLeaser struct {
+	client redis.Clienter
+	key    string
+}
+
+var _ Leaser = &RedisLeaser{}
+
+// NewRedisLeaser creates the leaser of the key
+func NewRedisLeaser(client redis.Clienter, key string) *RedisLeaser {
+	return &RedisLeaser{client: client, key: key}
+}
+
+// Acquire takes or renews the lease atomically
+func (leaser *RedisLeaser) Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	reply, err := leaser.client.Do(ctx, "EVAL", acquireScript, "1", leaser.key, holder, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, fmt.Errorf("Could not acquire the redis lease %s: %v", leaser.key, err)
+	}
+	acquired, err := redis.Int64(reply)
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+// Release deletes the lease if the holder owns it
+func (leaser *RedisLeaser) Release(ctx context.Context, holder string) error {
+	if _, err := leaser.client.Do(ctx, "EVAL", releaseScript, "1", leaser.key, holder); err != nil {
+		return fmt.Errorf("Could not release the redis lease %s: %v", leaser.key, err)
+	}
+	return nil
+}
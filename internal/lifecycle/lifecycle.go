@@ -0,0 +1,153 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+const (
+	defaultGracePeriod  = 30 * time.Second
+	defaultFlushTimeout = 5 * time.Second
+	// drainPollInterval is how often the requests in flight are counted while the shutdown waits for them
+	drainPollInterval = 10 * time.Millisecond
+)
+
+// Hook is a step of the shutdown run once the requests are drained, such as flushing a buffer or closing
+// the backend connections
+type Hook struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Manager drains the gateway on shutdown: it reports it is draining so the readiness fails, closes the listeners
+// of its servers once the drain delay passed, waits for the requests in flight, the upgraded connections
+// included, and then runs the shutdown hooks in order
+type Manager struct {
+	gracePeriod  time.Duration
+	drainDelay   time.Duration
+	flushTimeout time.Duration
+	servers      []*http.Server
+	serving      atomic.Bool
+	draining     atomic.Bool
+	inFlight     atomic.Int64
+	mtx          sync.Mutex
+}
+
+// NewManager creates the lifecycle manager of the configuration
+func NewManager(configurations *config.Config) *Manager {
+	shutdownConfig := configurations.Shutdown
+	manager := &Manager{
+		gracePeriod:  shutdownConfig.GracePeriod,
+		drainDelay:   shutdownConfig.DrainDelay,
+		flushTimeout: shutdownConfig.FlushTimeout,
+	}
+	if manager.gracePeriod <= 0 {
+		manager.gracePeriod = defaultGracePeriod
+	}
+	if manager.flushTimeout <= 0 {
+		manager.flushTimeout = defaultFlushTimeout
+	}
+	return manager
+}
+
+// Serve drains the server on shutdown, counting the requests its handler serves
+func (manager *Manager) Serve(server *http.Server) {
+	manager.mtx.Lock()
+	defer manager.mtx.Unlock()
+	server.Handler = manager.Handler(server.Handler)
+	manager.servers = append(manager.servers, server)
+}
+
+// Serving tells the manager its servers accept requests, the servers never started are shut down without
+// waiting for the drain delay
+func (manager *Manager) Serving() {
+	manager.serving.Store(true)
+}
+
+// Handler counts the requests in flight of the handler, asking the clients to close their connection
+// once the gateway is draining
+func (manager *Manager) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		manager.inFlight.Add(1)
+		defer manager.inFlight.Add(-1)
+		if manager.draining.Load() {
+			writer.Header().Set("Connection", "close")
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// Draining tells whether the shutdown started
+func (manager *Manager) Draining() bool {
+	return manager.draining.Load()
+}
+
+// InFlight returns the number of requests being served
+func (manager *Manager) InFlight() int64 {
+	return manager.inFlight.Load()
+}
+
+// GracePeriod returns the time the requests in flight are given to finish
+func (manager *Manager) GracePeriod() time.Duration {
+	return manager.gracePeriod
+}
+
+// Shutdown drains the servers within the context and then runs the hooks, each within the flush timeout so they
+// still run once the grace period is over. It returns the errors of the servers not drained and of the hooks
+func (manager *Manager) Shutdown(ctx context.Context, hooks ...Hook) error {
+	manager.draining.Store(true)
+	manager.mtx.Lock()
+	servers := append([]*http.Server{}, manager.servers...)
+	manager.mtx.Unlock()
+
+	var shutdownErrors []error
+	if len(servers) > 0 {
+		if manager.serving.Load() {
+			select {
+			case <-time.After(manager.drainDelay):
+			case <-ctx.Done():
+			}
+		}
+		serverErrors := make(chan error, len(servers))
+		for _, server := range servers {
+			go func(server *http.Server) {
+				server.SetKeepAlivesEnabled(false)
+				serverErrors <- server.Shutdown(ctx)
+			}(server)
+		}
+		for range servers {
+			shutdownErrors = append(shutdownErrors, <-serverErrors)
+		}
+		// The upgraded connections are not tracked by the servers, their handlers are still counted
+		shutdownErrors = append(shutdownErrors, manager.wait(ctx))
+	}
+	for _, hook := range hooks {
+		hookContext, cancel := context.WithTimeout(context.Background(), manager.flushTimeout)
+		if err := hook.Run(hookContext); err != nil {
+			shutdownErrors = append(shutdownErrors, fmt.Errorf("The %s shutdown hook failed: %v", hook.Name, err))
+		}
+		cancel()
+	}
+	return errors.Join(shutdownErrors...)
+}
+
+// wait waits until no request is in flight
+func (manager *Manager) wait(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for manager.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d requests were still in flight: %v", manager.inFlight.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
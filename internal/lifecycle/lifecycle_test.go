@@ -0,0 +1,101 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func TestManager(t *testing.T) {
+	t.Run("Shutdown_Should_Wait_For_The_Requests_In_Flight_Before_The_Hooks", func(t *testing.T) {
+		manager := NewManager(&config.Config{})
+		started, release := make(chan struct{}), make(chan struct{})
+		server := &http.Server{Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			close(started)
+			<-release
+		})}
+		manager.Serve(server)
+		go server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		<-started
+
+		order := []string{}
+		done := make(chan error, 1)
+		go func() {
+			done <- manager.Shutdown(context.Background(),
+				Hook{Name: "journal", Run: func(ctx context.Context) error {
+					order = append(order, "journal")
+					return nil
+				}},
+				Hook{Name: "backend_connections", Run: func(ctx context.Context) error {
+					order = append(order, "backend_connections")
+					return nil
+				}})
+		}()
+		time.Sleep(50 * time.Millisecond)
+		assert.True(t, manager.Draining())
+		assert.Equal(t, int64(1), manager.InFlight())
+		close(release)
+
+		assert.NoError(t, <-done)
+		assert.Equal(t, []string{"journal", "backend_connections"}, order)
+	})
+
+	t.Run("Shutdown_Should_Run_The_Hooks_When_The_Grace_Period_Is_Over", func(t *testing.T) {
+		manager := NewManager(&config.Config{})
+		release := make(chan struct{})
+		defer close(release)
+		handler := manager.Handler(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			<-release
+		}))
+		manager.Serve(&http.Server{Handler: handler})
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		for manager.InFlight() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		flushed := false
+
+		err := manager.Shutdown(ctx, Hook{Name: "tracing", Run: func(ctx context.Context) error {
+			flushed = true
+			return errors.New("exporter unavailable")
+		}})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "requests were still in flight")
+		assert.Contains(t, err.Error(), "The tracing shutdown hook failed")
+		assert.True(t, flushed)
+	})
+
+	t.Run("Shutdown_Should_Only_Wait_For_The_Drain_Delay_Once_Serving", func(t *testing.T) {
+		manager := NewManager(&config.Config{Shutdown: config.ShutdownConfig{DrainDelay: time.Hour}})
+		manager.Serve(&http.Server{Handler: http.NotFoundHandler()})
+
+		assert.NoError(t, manager.Shutdown(context.Background()))
+
+		manager.Serving()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		manager.Shutdown(ctx)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	})
+
+	t.Run("Handler_Should_Close_The_Connections_While_Draining", func(t *testing.T) {
+		manager := NewManager(&config.Config{})
+		handler := manager.Handler(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+		assert.NoError(t, manager.Shutdown(context.Background()))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, "close", recorder.Header().Get("Connection"))
+	})
+}
@@ -0,0 +1,198 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a scenario
+const (
+	defaultMaxInFlight = 1000
+	defaultTimeout     = 10 * time.Second
+	loginStepName      = "login"
+)
+
+// tokens are the tokens the gateway answers a login or a refresh with
+type tokens struct {
+	AuthToken    string `json:"authToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type virtualUser struct {
+	name   string
+	email  string
+	mtx    sync.Mutex
+	tokens tokens
+}
+
+// Generator sends the traffic of a scenario to a gateway at a fixed arrival rate, the requests the gateway
+// cannot absorb in time are dropped and reported instead of delaying the next ones, so a saturated gateway
+// shows up in the numbers rather than slowing the generator down
+type Generator struct {
+	target   string
+	scenario *Scenario
+	client   *http.Client
+	users    []*virtualUser
+	random   *rand.Rand
+	weights  int
+}
+
+// NewGenerator creates the generator of the scenario against the gateway at the target URL
+func NewGenerator(target string, scenario *Scenario, client *http.Client) (*Generator, error) {
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		timeout := scenario.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	generator := &Generator{
+		target:   strings.TrimSuffix(target, "/"),
+		scenario: scenario,
+		client:   client,
+		random:   rand.New(rand.NewSource(scenario.Seed)),
+	}
+	for index := 0; index < scenario.Users; index++ {
+		name := fmt.Sprintf("loadtest-%d", index)
+		generator.users = append(generator.users, &virtualUser{name: name, email: name + "@loadtest.local"})
+	}
+	for _, step := range scenario.Steps {
+		generator.weights += step.Weight
+	}
+	return generator, nil
+}
+
+// Run sends the traffic until the scenario duration is over or the context is done and reports the results
+func (generator *Generator) Run(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, generator.scenario.Duration)
+	defer cancel()
+
+	maxInFlight := generator.scenario.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = defaultMaxInFlight
+	}
+	inFlight := make(chan struct{}, maxInFlight)
+	report := newReport(generator.scenario.Name)
+	interval := time.Duration(float64(time.Second) / generator.scenario.Rate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	waitGroup := sync.WaitGroup{}
+	started := time.Now()
+	for arrival := 0; ; arrival++ {
+		user := generator.users[arrival%len(generator.users)]
+		step := generator.pick()
+		select {
+		case inFlight <- struct{}{}:
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				defer func() { <-inFlight }()
+				generator.send(ctx, user, step, report)
+			}()
+		default:
+			report.drop(step.Name)
+		}
+		select {
+		case <-ctx.Done():
+			waitGroup.Wait()
+			report.Elapsed = time.Since(started)
+			return report
+		case <-ticker.C:
+		}
+	}
+}
+
+// pick draws a step by weight
+func (generator *Generator) pick() Step {
+	draw := generator.random.Intn(generator.weights)
+	for _, step := range generator.scenario.Steps {
+		if draw < step.Weight {
+			return step
+		}
+		draw -= step.Weight
+	}
+	return generator.scenario.Steps[len(generator.scenario.Steps)-1]
+}
+
+// send runs the step for the user, logging the user in first when the step needs tokens it does not hold
+func (generator *Generator) send(ctx context.Context, user *virtualUser, step Step, report *Report) {
+	user.mtx.Lock()
+	defer user.mtx.Unlock()
+	if step.Authentication != AuthenticationNone && user.tokens.AuthToken == "" {
+		login := generator.scenario.Login
+		if login.Name == "" {
+			login.Name = loginStepName
+		}
+		if !generator.do(ctx, user, login, report) {
+			return
+		}
+	}
+	generator.do(ctx, user, step, report)
+}
+
+// do sends a request of the user and records it, keeping the tokens of a successful login or refresh
+// and forgetting them once the gateway rejects them so the user logs in again
+func (generator *Generator) do(ctx context.Context, user *virtualUser, step Step, report *Report) bool {
+	var body io.Reader
+	if step.Body != "" {
+		body = strings.NewReader(render(step.Body, user))
+	}
+	request, err := http.NewRequestWithContext(ctx, step.Method, generator.target+render(step.Path, user), body)
+	if err != nil {
+		report.record(step.Name, 0, 0, err)
+		return false
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range generator.scenario.Headers {
+		request.Header.Set(name, value)
+	}
+	for name, value := range step.Headers {
+		request.Header.Set(name, value)
+	}
+	switch step.Authentication {
+	case AuthenticationAccess:
+		request.Header.Set("Authorization", "Bearer "+user.tokens.AuthToken)
+	case AuthenticationRefresh:
+		request.Header.Set("Authorization", "Bearer "+user.tokens.RefreshToken)
+	}
+
+	sent := time.Now()
+	response, err := generator.client.Do(request)
+	if err != nil {
+		if ctx.Err() == nil {
+			report.record(step.Name, 0, time.Since(sent), err)
+		}
+		return false
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	latency := time.Since(sent)
+	report.record(step.Name, response.StatusCode, latency, err)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case response.StatusCode == http.StatusUnauthorized:
+		user.tokens = tokens{}
+	case response.StatusCode == http.StatusOK:
+		issued := tokens{}
+		if json.Unmarshal(responseBody, &issued) == nil && issued.AuthToken != "" {
+			user.tokens = issued
+		}
+	}
+	return response.StatusCode < http.StatusBadRequest
+}
@@ -0,0 +1,148 @@
+package loadtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newScenario() *Scenario {
+	return &Scenario{
+		Name:     "auth_heavy",
+		Duration: 300 * time.Millisecond,
+		Rate:     200,
+		Users:    3,
+		Headers:  map[string]string{"X-App-Version": "1.0.0"},
+		Login: Step{
+			Method: http.MethodPost,
+			Path:   "/api/v1/user/sessions",
+			Body:   `{"email":"{{email}}"}`,
+		},
+		Steps: []Step{
+			{Name: "profile", Method: http.MethodGet, Path: "/api/v1/user/profile", Weight: 3, Authentication: AuthenticationAccess},
+			{Name: "refresh", Method: http.MethodPost, Path: "/api/v1/authentication/refresh", Weight: 1, Authentication: AuthenticationRefresh},
+		},
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Run_Should_Log_In_Every_User_And_Use_Its_Tokens", func(t *testing.T) {
+		logins := map[string]int{}
+		mtx := sync.Mutex{}
+		router := gin.New()
+		router.POST("/api/v1/user/sessions", func(ctx *gin.Context) {
+			body := struct {
+				Email string `json:"email"`
+			}{}
+			assert.NoError(t, ctx.BindJSON(&body))
+			assert.Equal(t, "1.0.0", ctx.GetHeader("X-App-Version"))
+			mtx.Lock()
+			logins[body.Email]++
+			mtx.Unlock()
+			ctx.JSON(http.StatusOK, gin.H{"authToken": "auth-" + body.Email, "refreshToken": "refresh-" + body.Email})
+		})
+		router.GET("/api/v1/user/profile", func(ctx *gin.Context) {
+			if !strings.HasPrefix(ctx.GetHeader("Authorization"), "Bearer auth-") {
+				ctx.Status(http.StatusUnauthorized)
+				return
+			}
+			ctx.Status(http.StatusOK)
+		})
+		router.POST("/api/v1/authentication/refresh", func(ctx *gin.Context) {
+			email := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer refresh-")
+			ctx.JSON(http.StatusOK, gin.H{"authToken": "auth-" + email, "refreshToken": "refresh-" + email})
+		})
+		server := httptest.NewServer(router)
+		defer server.Close()
+		generator, err := NewGenerator(server.URL, newScenario(), nil)
+		assert.NoError(t, err)
+
+		report := generator.Run(context.Background())
+		report.Summarize()
+
+		assert.Len(t, logins, 3)
+		for _, count := range logins {
+			assert.Equal(t, 1, count)
+		}
+		assert.Equal(t, 3, report.Steps[loginStepName].Statuses[http.StatusOK])
+		assert.Greater(t, report.Steps["profile"].Statuses[http.StatusOK], 0)
+		assert.Zero(t, report.Steps["profile"].Statuses[http.StatusUnauthorized])
+		assert.Greater(t, report.Steps["profile"].P99, time.Duration(0))
+	})
+
+	t.Run("Run_Should_Log_In_Again_After_The_Tokens_Are_Rejected", func(t *testing.T) {
+		logins := 0
+		mtx := sync.Mutex{}
+		router := gin.New()
+		router.POST("/api/v1/user/sessions", func(ctx *gin.Context) {
+			mtx.Lock()
+			logins++
+			mtx.Unlock()
+			ctx.JSON(http.StatusOK, gin.H{"authToken": "auth", "refreshToken": "refresh"})
+		})
+		router.GET("/api/v1/user/profile", func(ctx *gin.Context) { ctx.Status(http.StatusUnauthorized) })
+		server := httptest.NewServer(router)
+		defer server.Close()
+		scenario := newScenario()
+		scenario.Users = 1
+		scenario.Steps = scenario.Steps[:1]
+		generator, err := NewGenerator(server.URL, scenario, nil)
+		assert.NoError(t, err)
+
+		generator.Run(context.Background())
+
+		assert.Greater(t, logins, 1)
+	})
+
+	t.Run("Run_Should_Drop_The_Arrivals_Over_The_In_Flight_Limit", func(t *testing.T) {
+		release := make(chan struct{})
+		router := gin.New()
+		router.GET("/api/v1/health", func(ctx *gin.Context) {
+			select {
+			case <-release:
+			case <-ctx.Request.Context().Done():
+			}
+			ctx.Status(http.StatusOK)
+		})
+		server := httptest.NewServer(router)
+		defer server.Close()
+		defer close(release)
+		scenario := &Scenario{
+			Name: "saturated", Duration: 100 * time.Millisecond, Rate: 200, Users: 1, MaxInFlight: 1,
+			Steps: []Step{{Name: "health", Method: http.MethodGet, Path: "/api/v1/health", Weight: 1}},
+		}
+		generator, err := NewGenerator(server.URL, scenario, nil)
+		assert.NoError(t, err)
+
+		report := generator.Run(context.Background())
+
+		assert.Greater(t, report.Steps["health"].Dropped, 0)
+	})
+
+	t.Run("Validate_Should_Require_A_Login_For_Authenticated_Steps", func(t *testing.T) {
+		scenario := newScenario()
+		scenario.Login = Step{}
+
+		assert.Error(t, scenario.Validate())
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := []time.Duration{}
+	for index := 1; index <= 100; index++ {
+		latencies = append(latencies, time.Duration(index)*time.Millisecond)
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(latencies, 0.5))
+	assert.Equal(t, 99*time.Millisecond, percentile(latencies, 0.99))
+	assert.Equal(t, time.Millisecond, percentile(latencies[:1], 0.99))
+}
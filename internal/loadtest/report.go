@@ -0,0 +1,138 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// StepReport are the results of a step
+type StepReport struct {
+	Requests  int           `json:"requests"`
+	Errors    int           `json:"errors"`
+	Dropped   int           `json:"dropped"`
+	Statuses  map[int]int   `json:"statuses"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+	latencies []time.Duration
+}
+
+// Report are the results of a scenario run
+type Report struct {
+	Scenario string                 `json:"scenario"`
+	Elapsed  time.Duration          `json:"elapsed"`
+	Steps    map[string]*StepReport `json:"steps"`
+	mtx      sync.Mutex
+}
+
+func newReport(scenario string) *Report {
+	return &Report{Scenario: scenario, Steps: map[string]*StepReport{}}
+}
+
+func (report *Report) step(name string) *StepReport {
+	stepReport, exists := report.Steps[name]
+	if !exists {
+		stepReport = &StepReport{Statuses: map[int]int{}}
+		report.Steps[name] = stepReport
+	}
+	return stepReport
+}
+
+func (report *Report) record(name string, statusCode int, latency time.Duration, err error) {
+	report.mtx.Lock()
+	defer report.mtx.Unlock()
+	stepReport := report.step(name)
+	stepReport.Requests++
+	if err != nil {
+		stepReport.Errors++
+		return
+	}
+	stepReport.Statuses[statusCode]++
+	stepReport.latencies = append(stepReport.latencies, latency)
+}
+
+func (report *Report) drop(name string) {
+	report.mtx.Lock()
+	defer report.mtx.Unlock()
+	report.step(name).Dropped++
+}
+
+// Summarize computes the latency percentiles of every step
+func (report *Report) Summarize() {
+	report.mtx.Lock()
+	defer report.mtx.Unlock()
+	for _, stepReport := range report.Steps {
+		latencies := stepReport.latencies
+		if len(latencies) == 0 {
+			continue
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stepReport.P50 = percentile(latencies, 0.5)
+		stepReport.P90 = percentile(latencies, 0.9)
+		stepReport.P99 = percentile(latencies, 0.99)
+		stepReport.Max = latencies[len(latencies)-1]
+	}
+}
+
+// Print writes the summarized report as a table
+func (report *Report) Print(writer io.Writer) error {
+	report.Summarize()
+	names := make([]string, 0, len(report.Steps))
+	for name := range report.Steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(writer, "Scenario %s over %s\n", report.Scenario, report.Elapsed.Round(time.Millisecond))
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "STEP\tREQUESTS\tRPS\tERRORS\tDROPPED\tSTATUSES\tP50\tP90\tP99\tMAX")
+	for _, name := range names {
+		stepReport := report.Steps[name]
+		fmt.Fprintf(table, "%s\t%d\t%.1f\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			name,
+			stepReport.Requests,
+			float64(stepReport.Requests)/report.Elapsed.Seconds(),
+			stepReport.Errors,
+			stepReport.Dropped,
+			formatStatuses(stepReport.Statuses),
+			stepReport.P50, stepReport.P90, stepReport.P99, stepReport.Max,
+		)
+	}
+	return table.Flush()
+}
+
+// percentile returns the nearest rank percentile of the sorted latencies
+func percentile(sorted []time.Duration, fraction float64) time.Duration {
+	rank := int(fraction*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func formatStatuses(statuses map[int]int) string {
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	formatted := ""
+	for index, code := range codes {
+		if index > 0 {
+			formatted += ","
+		}
+		formatted += fmt.Sprintf("%d:%d", code, statuses[code])
+	}
+	if formatted == "" {
+		return "-"
+	}
+	return formatted
+}
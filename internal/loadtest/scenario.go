@@ -0,0 +1,98 @@
+package loadtest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Authentication modes of a step
+const (
+	AuthenticationNone    = ""
+	AuthenticationAccess  = "access"
+	AuthenticationRefresh = "refresh"
+)
+
+// Placeholders replaced in the paths and bodies of the steps with the values of the virtual user
+const (
+	EmailPlaceholder = "{{email}}"
+	UserPlaceholder  = "{{user}}"
+)
+
+// Step is a request of the scenario, picked at random by weight
+type Step struct {
+	Name           string            `mapstructure:"name"`
+	Method         string            `mapstructure:"method"`
+	Path           string            `mapstructure:"path"`
+	Body           string            `mapstructure:"body"`
+	Headers        map[string]string `mapstructure:"headers"`
+	Weight         int               `mapstructure:"weight"`
+	Authentication string            `mapstructure:"authentication"`
+}
+
+// Scenario describes the traffic of a load test: a fixed arrival rate spread over virtual users, each logging in
+// with the login step before its authenticated steps and again whenever the gateway rejects its tokens
+type Scenario struct {
+	Name        string            `mapstructure:"name"`
+	Duration    time.Duration     `mapstructure:"duration"`
+	Rate        float64           `mapstructure:"rate"`
+	Users       int               `mapstructure:"users"`
+	MaxInFlight int               `mapstructure:"max_in_flight"`
+	Timeout     time.Duration     `mapstructure:"timeout"`
+	Seed        int64             `mapstructure:"seed"`
+	Headers     map[string]string `mapstructure:"headers"`
+	Login       Step              `mapstructure:"login"`
+	Steps       []Step            `mapstructure:"steps"`
+}
+
+// LoadScenario loads the scenario of the given yml file
+func LoadScenario(path string) (*Scenario, error) {
+	vip := viper.New()
+	vip.SetConfigFile(path)
+	vip.SetConfigType("yml")
+	if err := vip.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("Could not read scenario %s: %v", path, err)
+	}
+	scenario := &Scenario{}
+	if err := vip.Unmarshal(scenario); err != nil {
+		return nil, fmt.Errorf("Error unmarshaling scenario %s: %v", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// Validate checks the scenario can be run
+func (scenario *Scenario) Validate() error {
+	if scenario.Duration <= 0 || scenario.Rate <= 0 || scenario.Users <= 0 {
+		return fmt.Errorf("The scenario %s requires a duration, a rate and users", scenario.Name)
+	}
+	if len(scenario.Steps) == 0 {
+		return fmt.Errorf("The scenario %s has no steps", scenario.Name)
+	}
+	authenticated := false
+	for _, step := range scenario.Steps {
+		if step.Method == "" || step.Path == "" || step.Weight <= 0 {
+			return fmt.Errorf("The step %s of scenario %s requires a method, a path and a weight", step.Name, scenario.Name)
+		}
+		switch step.Authentication {
+		case AuthenticationNone:
+		case AuthenticationAccess, AuthenticationRefresh:
+			authenticated = true
+		default:
+			return fmt.Errorf("Invalid authentication %s of step %s", step.Authentication, step.Name)
+		}
+	}
+	if authenticated && (scenario.Login.Method == "" || scenario.Login.Path == "") {
+		return fmt.Errorf("The scenario %s has authenticated steps but no login step", scenario.Name)
+	}
+	return nil
+}
+
+// render replaces the placeholders of the virtual user
+func render(template string, user *virtualUser) string {
+	return strings.NewReplacer(EmailPlaceholder, user.email, UserPlaceholder, user.name).Replace(template)
+}
@@ -0,0 +1,89 @@
+package media
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/media/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_media"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// ServiceClienter is an interface for the media service client
+type ServiceClienter interface {
+	UploadMedia(ctx *gin.Context)
+	DownloadMedia(ctx *gin.Context)
+	GetThumbnail(ctx *gin.Context)
+}
+
+// ServiceClient is a struct for the media service client
+type ServiceClient struct {
+	client          pb_media.MediaServiceClient
+	maxUploadSize   int64
+	thumbnailPolicy routes.ThumbnailPolicy
+}
+
+var _ ServiceClienter = &ServiceClient{}
+
+// InitServiceClient initializes the media service client
+func InitServiceClient(centralConfig *commonConfig.Config, configurations *config.Config) (pb_media.MediaServiceClient, error) {
+	grpcServiceAddress := fmt.Sprintf(
+		"%s:%s",
+		configurations.MediaService.Host,
+		configurations.MediaService.Port,
+	)
+
+	fmt.Println("Connecting to media service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection("media", grpcServiceAddress, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc media service: %v", err)
+	}
+
+	regionalConnection, err := region.RouteConnection("media", clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConnection, err := tenancy.RouteConnection("media", regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	routedConnection, err := versioning.RouteConnection("media", tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tracedConnection := tracing.TraceConnection("media", routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection("media", tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection("media", instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection("media", retryingConnection, configurations)
+	hookedConnection := hooks.HookConnection("media", breakerConnection, configurations)
+
+	return pb_media.NewMediaServiceClient(hookedConnection), nil
+}
+
+// UploadMedia redirects request to the upload media route
+func (service *ServiceClient) UploadMedia(ctx *gin.Context) {
+	routes.UploadMedia(ctx, service.client, service.maxUploadSize)
+}
+
+// DownloadMedia redirects request to the download media route
+func (service *ServiceClient) DownloadMedia(ctx *gin.Context) {
+	routes.DownloadMedia(ctx, service.client)
+}
+
+// GetThumbnail redirects request to the get thumbnail route
+func (service *ServiceClient) GetThumbnail(ctx *gin.Context) {
+	routes.GetThumbnail(ctx, service.client, service.thumbnailPolicy)
+}
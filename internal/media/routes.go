@@ -0,0 +1,45 @@
+package media
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/media/routes"
+)
+
+// RegisterRoutes registers the media routes
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*ServiceClient, error) {
+	mediaConfig := configurations.MediaService
+	if len(mediaConfig.ThumbnailSizes) == 0 {
+		return nil, fmt.Errorf("No thumbnail sizes were configured for the media service")
+	}
+	client, err := InitServiceClient(centralConfig, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not initialize media service client: %v", err)
+	}
+	service := &ServiceClient{
+		client:        client,
+		maxUploadSize: mediaConfig.MaxUploadSize,
+		thumbnailPolicy: routes.ThumbnailPolicy{
+			Sizes:       mediaConfig.ThumbnailSizes,
+			MaxAge:      mediaConfig.ThumbnailMaxAge,
+			DefaultSize: mediaConfig.ThumbnailSizes[0],
+		},
+	}
+
+	mediaRoutes := api.Group("/media")
+	mediaRoutes.POST("", authenticationMiddleware.RequireAuthentication, service.UploadMedia)
+	mediaRoutes.GET("/:mediaID", authenticationMiddleware.RequireAuthentication, service.DownloadMedia)
+	mediaRoutes.GET("/:mediaID/thumbnail", authenticationMiddleware.RequireAuthentication, service.GetThumbnail)
+
+	return service, nil
+}
@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteRange is a resolved range of bytes of a file
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+// ContentRange returns the Content-Range header value of the range
+func (byteRange ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", byteRange.Start, byteRange.Start+byteRange.Length-1, size)
+}
+
+// ParseRange resolves a single range Range header against the file size,
+// returning nil when the header asks for the whole file
+func ParseRange(header string, size int64) (*ByteRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, fmt.Errorf("Unsupported range unit: %s", header)
+	}
+	spec := strings.TrimSpace(strings.TrimPrefix(header, prefix))
+	if strings.Contains(spec, ",") {
+		return nil, fmt.Errorf("Multiple ranges are not supported: %s", header)
+	}
+	startValue, endValue, found := strings.Cut(spec, "-")
+	if !found {
+		return nil, fmt.Errorf("Invalid range: %s", header)
+	}
+	startValue = strings.TrimSpace(startValue)
+	endValue = strings.TrimSpace(endValue)
+
+	if startValue == "" {
+		suffixLength, err := strconv.ParseInt(endValue, 10, 64)
+		if err != nil || suffixLength <= 0 {
+			return nil, fmt.Errorf("Invalid suffix range: %s", header)
+		}
+		if suffixLength > size {
+			suffixLength = size
+		}
+		if suffixLength == 0 {
+			return nil, fmt.Errorf("Range not satisfiable: %s", header)
+		}
+		return &ByteRange{Start: size - suffixLength, Length: suffixLength}, nil
+	}
+
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil || start < 0 {
+		return nil, fmt.Errorf("Invalid range start: %s", header)
+	}
+	if start >= size {
+		return nil, fmt.Errorf("Range not satisfiable: %s", header)
+	}
+	end := size - 1
+	if endValue != "" {
+		end, err = strconv.ParseInt(endValue, 10, 64)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("Invalid range end: %s", header)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return &ByteRange{Start: start, Length: end - start + 1}, nil
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRange(t *testing.T) {
+	const size = int64(1000)
+
+	t.Run("ParseRange_No_Header_Should_Return_Whole_File", func(t *testing.T) {
+		byteRange, err := ParseRange("", size)
+
+		assert.NoError(t, err)
+		assert.Nil(t, byteRange)
+	})
+
+	t.Run("ParseRange_Closed_Range_Success", func(t *testing.T) {
+		byteRange, err := ParseRange("bytes=100-199", size)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ByteRange{Start: 100, Length: 100}, byteRange)
+		assert.Equal(t, "bytes 100-199/1000", byteRange.ContentRange(size))
+	})
+
+	t.Run("ParseRange_Open_Range_Success", func(t *testing.T) {
+		byteRange, err := ParseRange("bytes=900-", size)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ByteRange{Start: 900, Length: 100}, byteRange)
+	})
+
+	t.Run("ParseRange_End_Beyond_Size_Should_Be_Clamped", func(t *testing.T) {
+		byteRange, err := ParseRange("bytes=900-5000", size)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ByteRange{Start: 900, Length: 100}, byteRange)
+	})
+
+	t.Run("ParseRange_Suffix_Range_Success", func(t *testing.T) {
+		byteRange, err := ParseRange("bytes=-50", size)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ByteRange{Start: 950, Length: 50}, byteRange)
+	})
+
+	t.Run("ParseRange_Start_Beyond_Size_Error", func(t *testing.T) {
+		byteRange, err := ParseRange("bytes=1000-", size)
+
+		assert.Error(t, err)
+		assert.Nil(t, byteRange)
+	})
+
+	t.Run("ParseRange_Multiple_Ranges_Error", func(t *testing.T) {
+		byteRange, err := ParseRange("bytes=0-1,5-6", size)
+
+		assert.Error(t, err)
+		assert.Nil(t, byteRange)
+	})
+
+	t.Run("ParseRange_Invalid_Unit_Error", func(t *testing.T) {
+		byteRange, err := ParseRange("items=0-1", size)
+
+		assert.Error(t, err)
+		assert.Nil(t, byteRange)
+	})
+}
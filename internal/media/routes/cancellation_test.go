@@ -0,0 +1,156 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_media"
+)
+
+// fakeMediaClient records the contexts of the upstream streams so the tests can check they were cancelled
+type fakeMediaClient struct {
+	pb_media.MediaServiceClient
+	streamContext context.Context
+	committed     bool
+	chunks        chan []byte
+}
+
+func (client *fakeMediaClient) UploadMedia(ctx context.Context, opts ...grpc.CallOption) (pb_media.MediaService_UploadMediaClient, error) {
+	client.streamContext = ctx
+	return &fakeUploadStream{client: client}, nil
+}
+
+func (client *fakeMediaClient) GetMedia(ctx context.Context, in *pb_media.GetMediaRequest, opts ...grpc.CallOption) (*pb_media.Media, error) {
+	return &pb_media.Media{Size: 1 << 20, ContentType: "video/mp4"}, nil
+}
+
+func (client *fakeMediaClient) DownloadMedia(ctx context.Context, in *pb_media.DownloadMediaRequest, opts ...grpc.CallOption) (pb_media.MediaService_DownloadMediaClient, error) {
+	client.streamContext = ctx
+	return &fakeDownloadStream{ctx: ctx, chunks: client.chunks}, nil
+}
+
+type fakeUploadStream struct {
+	grpc.ClientStream
+	client *fakeMediaClient
+}
+
+func (stream *fakeUploadStream) Send(message *pb_media.UploadMediaRequest) error {
+	return stream.client.streamContext.Err()
+}
+
+func (stream *fakeUploadStream) CloseAndRecv() (*pb_media.Media, error) {
+	stream.client.committed = true
+	return &pb_media.Media{}, nil
+}
+
+// fakeDownloadStream serves the queued chunks and then waits for more until its context is cancelled,
+// a closed queue ends the download
+type fakeDownloadStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	chunks chan []byte
+}
+
+func (stream *fakeDownloadStream) Recv() (*pb_media.DownloadMediaResponse, error) {
+	select {
+	case chunk, open := <-stream.chunks:
+		if !open {
+			return nil, io.EOF
+		}
+		return &pb_media.DownloadMediaResponse{Chunk: chunk}, nil
+	case <-stream.ctx.Done():
+		return nil, status.Error(codes.Canceled, stream.ctx.Err().Error())
+	}
+}
+
+// disconnectingBody returns part of the upload and then fails as a body read does when the client disconnects
+type disconnectingBody struct {
+	sent bool
+}
+
+func (body *disconnectingBody) Read(buffer []byte) (int, error) {
+	if body.sent {
+		return 0, io.ErrUnexpectedEOF
+	}
+	body.sent = true
+	return copy(buffer, bytes.Repeat([]byte("a"), 1024)), nil
+}
+
+func newCancellationContext(t *testing.T, request *http.Request) (*gin.Context, *httptest.ResponseRecorder) {
+	controller := gomock.NewController(t)
+	loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+	loggerMock.EXPECT().Info(gomock.Any()).AnyTimes()
+	loggerMock.EXPECT().Error(gomock.Any(), gomock.Any()).AnyTimes()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = request.WithContext(context.WithValue(request.Context(), commonLogger.LoggerKey, loggerMock))
+	return ctx, recorder
+}
+
+func TestCancellation(t *testing.T) {
+	t.Run("UploadMedia_Should_Cancel_The_Upstream_Stream_When_The_Client_Disconnects", func(t *testing.T) {
+		client := &fakeMediaClient{}
+		request := httptest.NewRequest(http.MethodPost, "/media", &disconnectingBody{})
+		request.Header.Set(FilenameHeader, "video.mp4")
+		ctx, recorder := newCancellationContext(t, request)
+
+		UploadMedia(ctx, client, 1<<20)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.False(t, client.committed)
+		assert.Error(t, client.streamContext.Err())
+	})
+
+	t.Run("DownloadMedia_Should_Cancel_The_Upstream_Stream_When_The_Client_Disconnects", func(t *testing.T) {
+		client := &fakeMediaClient{chunks: make(chan []byte, 1)}
+		client.chunks <- []byte("first chunk")
+		requestContext, disconnect := context.WithCancel(context.Background())
+		request := httptest.NewRequest(http.MethodGet, "/media/media-id", nil).WithContext(requestContext)
+		ctx, recorder := newCancellationContext(t, request)
+		ctx.Params = gin.Params{{Key: "mediaID", Value: "media-id"}}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			DownloadMedia(ctx, client)
+		}()
+		assert.Eventually(t, func() bool { return len(client.chunks) == 0 }, time.Second, time.Millisecond)
+		disconnect()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("The download kept waiting on the upstream after the client disconnected")
+		}
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Error(t, client.streamContext.Err())
+	})
+
+	t.Run("DownloadMedia_Should_Release_The_Upstream_Stream_When_It_Completes", func(t *testing.T) {
+		client := &fakeMediaClient{chunks: make(chan []byte, 1)}
+		client.chunks <- []byte("only chunk")
+		close(client.chunks)
+		ctx, recorder := newCancellationContext(t, httptest.NewRequest(http.MethodGet, "/media/media-id", nil))
+
+		DownloadMedia(ctx, client)
+
+		assert.Equal(t, "only chunk", recorder.Body.String())
+		assert.NoError(t, ctx.Request.Context().Err())
+		assert.Error(t, client.streamContext.Err())
+	})
+}
@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/deadline"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_media"
+)
+
+// Budget stages of the download, the metadata lookup cannot consume the deadline of the stream
+var (
+	metadataStage = deadline.Stage{Weight: 1, Minimum: 250 * time.Millisecond}
+	downloadStage = deadline.Stage{Weight: 9, Minimum: time.Second}
+)
+
+// DownloadMedia streams a file of the media service, serving a single byte range when requested
+func DownloadMedia(ctx *gin.Context, client pb_media.MediaServiceClient) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	mediaID := ctx.Param("mediaID")
+	budget := deadline.NewBudget(ctx.Request.Context(), metadataStage, downloadStage)
+	metadataContext, cancelMetadata := budget.Next()
+	media, err := client.GetMedia(metadataContext, &pb_media.GetMediaRequest{MediaID: mediaID})
+	cancelMetadata()
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Accept-Ranges", "bytes")
+	ctx.Header("Cache-Control", "private, max-age=0, must-revalidate")
+	if media.Etag != "" {
+		ctx.Header("ETag", media.Etag)
+		if ctx.GetHeader("If-None-Match") == media.Etag {
+			ctx.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+	}
+
+	rangeHeader := ctx.GetHeader("Range")
+	if ifRange := ctx.GetHeader("If-Range"); ifRange != "" && ifRange != media.Etag {
+		rangeHeader = ""
+	}
+	byteRange, err := ParseRange(rangeHeader, media.Size)
+	if err != nil {
+		logger.Warn(err.Error())
+		ctx.Header("Content-Range", fmt.Sprintf("bytes */%d", media.Size))
+		ctx.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	request := &pb_media.DownloadMediaRequest{MediaID: mediaID}
+	statusCode := http.StatusOK
+	contentLength := media.Size
+	if byteRange != nil {
+		request.Offset = byteRange.Start
+		request.Length = byteRange.Length
+		statusCode = http.StatusPartialContent
+		contentLength = byteRange.Length
+		ctx.Header("Content-Range", byteRange.ContentRange(media.Size))
+	}
+
+	downloadContext, cancelDownload := budget.Next()
+	defer cancelDownload()
+	stream, err := client.DownloadMedia(downloadContext, request)
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+	firstChunk, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Type", media.ContentType)
+	ctx.Header("Content-Length", strconv.FormatInt(contentLength, 10))
+	ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", media.Filename))
+	ctx.Status(statusCode)
+	if firstChunk == nil {
+		return
+	}
+	if _, err := ctx.Writer.Write(firstChunk.Chunk); err != nil {
+		logger.Error(err, "Could not write the download to the client")
+		return
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil && ctx.Request.Context().Err() != nil {
+			logger.Info("The client disconnected during the download")
+			return
+		}
+		if err != nil {
+			// The status line has already been sent so the truncated body is all the client gets
+			logger.Error(err, "The media service download stream failed")
+			return
+		}
+		if _, err := ctx.Writer.Write(chunk.Chunk); err != nil {
+			logger.Error(err, "Could not write the download to the client")
+			return
+		}
+		ctx.Writer.Flush()
+	}
+}
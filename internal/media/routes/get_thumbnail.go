@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_media"
+)
+
+// ThumbnailPolicy restricts the thumbnail sizes and sets how long the browsers cache them
+type ThumbnailPolicy struct {
+	Sizes       []int
+	MaxAge      time.Duration
+	DefaultSize int
+}
+
+func (policy ThumbnailPolicy) isAllowed(size int) bool {
+	for _, allowed := range policy.Sizes {
+		if allowed == size {
+			return true
+		}
+	}
+	return false
+}
+
+// GetThumbnail returns a square thumbnail of a file, cached by the browser of the user only as the thumbnails of
+// private media must not be shared by the edge caches
+func GetThumbnail(ctx *gin.Context, client pb_media.MediaServiceClient, policy ThumbnailPolicy) {
+	size := policy.DefaultSize
+	if sizeValue := ctx.Query("size"); sizeValue != "" {
+		parsedSize, err := strconv.Atoi(sizeValue)
+		// Only the configured sizes are rendered so arbitrary sizes cannot bust the caches
+		if err != nil || !policy.isAllowed(parsedSize) {
+			errors.Abort(ctx, errors.InvalidArgument, errors.FieldViolation("size", fmt.Sprintf("size must be one of %v", policy.Sizes)))
+			return
+		}
+		size = parsedSize
+	}
+
+	res, err := client.GetThumbnail(
+		ctx.Request.Context(),
+		&pb_media.GetThumbnailRequest{
+			MediaID: ctx.Param("mediaID"),
+			Width:   int32(size),
+			Height:  int32(size),
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", fmt.Sprintf(
+		"private, max-age=%d, stale-while-revalidate=%d",
+		int(policy.MaxAge.Seconds()),
+		int(policy.MaxAge.Seconds()),
+	))
+	if res.UpdatedAt > 0 {
+		ctx.Header("Last-Modified", time.Unix(res.UpdatedAt, 0).UTC().Format(http.TimeFormat))
+	}
+	if res.Etag != "" {
+		ctx.Header("ETag", res.Etag)
+		if ctx.GetHeader("If-None-Match") == res.Etag {
+			ctx.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+	}
+	ctx.Data(http.StatusOK, res.ContentType, res.Data)
+}
@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_media"
+)
+
+// thumbnailMediaClient renders the thumbnails of the requested sizes
+type thumbnailMediaClient struct {
+	pb_media.MediaServiceClient
+}
+
+func (client *thumbnailMediaClient) GetThumbnail(ctx context.Context, in *pb_media.GetThumbnailRequest, opts ...grpc.CallOption) (*pb_media.Thumbnail, error) {
+	return &pb_media.Thumbnail{ContentType: "image/webp", Data: []byte("thumbnail"), Etag: `"v1"`}, nil
+}
+
+func TestGetThumbnail(t *testing.T) {
+	policy := ThumbnailPolicy{Sizes: []int{128, 256}, MaxAge: time.Hour, DefaultSize: 128}
+
+	t.Run("GetThumbnail_Should_Only_Let_The_Browser_Cache_The_Thumbnail", func(t *testing.T) {
+		ctx, recorder := newCancellationContext(t, httptest.NewRequest(http.MethodGet, "/media/media-id/thumbnail?size=256", nil))
+		ctx.Params = gin.Params{{Key: "mediaID", Value: "media-id"}}
+
+		GetThumbnail(ctx, &thumbnailMediaClient{}, policy)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "private, max-age=3600, stale-while-revalidate=3600", recorder.Header().Get("Cache-Control"))
+		assert.Equal(t, "thumbnail", recorder.Body.String())
+	})
+
+	t.Run("GetThumbnail_Should_Reject_The_Sizes_Not_Configured", func(t *testing.T) {
+		ctx, recorder := newCancellationContext(t, httptest.NewRequest(http.MethodGet, "/media/media-id/thumbnail?size=1000", nil))
+		ctx.Params = gin.Params{{Key: "mediaID", Value: "media-id"}}
+
+		GetThumbnail(ctx, &thumbnailMediaClient{}, policy)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Cache-Control"))
+	})
+}
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"context"
+	stdErrors "errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_media"
+)
+
+// FilenameHeader is the request header carrying the name of the uploaded file
+const FilenameHeader = "X-Filename"
+
+const uploadChunkSize = 64 * 1024
+
+// UploadMedia streams the raw request body to the media service without buffering the whole file
+func UploadMedia(ctx *gin.Context, client pb_media.MediaServiceClient, maxUploadSize int64) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	filename := ctx.GetHeader(FilenameHeader)
+	if filename == "" {
+		errors.AbortWithMessage(ctx, errors.InvalidArgument, "The X-Filename header is required")
+		return
+	}
+	if ctx.Request.ContentLength > maxUploadSize {
+		errors.Abort(ctx, errors.PayloadTooLarge)
+		return
+	}
+	contentType := ctx.ContentType()
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// The stream is cancelled on every early return, so a client disconnecting mid-upload never commits a truncated file
+	uploadContext, cancelUpload := context.WithCancel(ctx.Request.Context())
+	defer cancelUpload()
+	stream, err := client.UploadMedia(uploadContext)
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+	err = stream.Send(&pb_media.UploadMediaRequest{
+		Metadata: &pb_media.UploadMetadata{
+			Filename:    filename,
+			ContentType: contentType,
+			Size:        ctx.Request.ContentLength,
+		},
+	})
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxUploadSize)
+	buffer := make([]byte, uploadChunkSize)
+	for {
+		read, readErr := body.Read(buffer)
+		if read > 0 {
+			if err := stream.Send(&pb_media.UploadMediaRequest{Chunk: buffer[:read]}); err != nil {
+				logger.Error(err, "Could not stream the upload to the media service")
+				break
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			var maxBytesError *http.MaxBytesError
+			if stdErrors.As(readErr, &maxBytesError) {
+				errors.Abort(ctx, errors.PayloadTooLarge)
+				return
+			}
+			logger.Error(readErr, "Could not read the upload body")
+			errors.Abort(ctx, errors.InvalidRequestBody)
+			return
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, &res)
+}
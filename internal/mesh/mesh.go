@@ -0,0 +1,162 @@
+package mesh
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+)
+
+// Request id and Envoy headers exchanged with the mesh
+const (
+	RequestIDHeader            = "x-request-id"
+	EnvoyExpectedTimeoutHeader = "x-envoy-expected-rq-timeout-ms"
+	EnvoyUpstreamTimeoutHeader = "x-envoy-upstream-rq-timeout-ms"
+	EnvoyPerTryTimeoutHeader   = "x-envoy-upstream-rq-per-try-timeout-ms"
+	EnvoyMaxRetriesHeader      = "x-envoy-max-retries"
+	EnvoyRetryOnHeader         = "x-envoy-retry-on"
+	EnvoyRetryGRPCOnHeader     = "x-envoy-retry-grpc-on"
+	EnvoyAttemptCountHeader    = "x-envoy-attempt-count"
+	maxRequestIDLength         = 128
+	firstAttempt               = 1
+)
+
+// Propagator keeps the request id, the trace and the Envoy budgets of the requests on their upstream calls
+type Propagator struct {
+	trustInbound  bool
+	generateB3    bool
+	sampleNew     bool
+	maxRetries    *int
+	retryOn       string
+	retryGRPCOn   string
+	perTryTimeout time.Duration
+	timeout       time.Duration
+}
+
+// NewPropagator creates the mesh header propagator of the configuration
+func NewPropagator(configurations *config.Config) *Propagator {
+	return &Propagator{
+		trustInbound:  configurations.Mesh.TrustInboundHeaders,
+		generateB3:    configurations.Mesh.GenerateB3,
+		sampleNew:     configurations.Mesh.SampleNewTraces,
+		maxRetries:    configurations.Mesh.MaxRetries,
+		retryOn:       configurations.Mesh.RetryOn,
+		retryGRPCOn:   configurations.Mesh.RetryGRPCOn,
+		perTryTimeout: configurations.Mesh.PerTryTimeout,
+		timeout:       configurations.Mesh.Timeout,
+	}
+}
+
+// Middleware adopts the mesh request id as the correlation id and forwards the mesh headers upstream,
+// it runs after the correlation id is created and before the request logger is. Without a valid mesh
+// request id the correlation id of the request becomes the request id
+func (propagator *Propagator) Middleware(ctx *gin.Context) {
+	requestID := ctx.GetHeader(RequestIDHeader)
+	if !validRequestID(requestID) {
+		requestID = middleware.GetCorrelationID(ctx)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+	}
+	ctx.Header(RequestIDHeader, requestID)
+	if middleware.GetCorrelationID(ctx) != "" {
+		ctx.Set(middleware.CorrelationIDContextKey, requestID)
+		ctx.Header(middleware.CorrelationIDHeader, requestID)
+	}
+
+	upstreamHeaders := map[string]string{
+		RequestIDHeader:               requestID,
+		commonLogger.CorrelationIDKey: requestID,
+	}
+	trace, exists := parseTrace(ctx.GetHeader)
+	if !exists {
+		trace = newTrace(propagator.sampleNew)
+	}
+	trace.spanID = randomHex(8)
+	for name, value := range trace.headers(propagator.generateB3) {
+		upstreamHeaders[name] = value
+	}
+
+	requestContext := ctx.Request.Context()
+	timeout := propagator.timeout
+	if propagator.trustInbound {
+		if expected, err := strconv.Atoi(ctx.GetHeader(EnvoyExpectedTimeoutHeader)); err == nil && expected > 0 {
+			expectedTimeout := time.Duration(expected) * time.Millisecond
+			if timeout <= 0 || expectedTimeout < timeout {
+				timeout = expectedTimeout
+			}
+		}
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		requestContext, cancel = context.WithTimeout(requestContext, timeout)
+		defer cancel()
+		upstreamHeaders[EnvoyUpstreamTimeoutHeader] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+	propagator.setRetryPolicy(ctx, upstreamHeaders)
+
+	incomingMD, ok := metadata.FromIncomingContext(requestContext)
+	if !ok {
+		incomingMD = metadata.New(map[string]string{})
+	}
+	newIncomingMD := incomingMD.Copy()
+	newIncomingMD.Set(commonLogger.CorrelationIDKey, requestID)
+	requestContext = metadata.NewIncomingContext(requestContext, newIncomingMD)
+
+	existingMD, ok := metadata.FromOutgoingContext(requestContext)
+	if !ok {
+		existingMD = metadata.New(map[string]string{})
+	}
+	newMD := existingMD.Copy()
+	for name, value := range upstreamHeaders {
+		newMD.Set(name, value)
+	}
+	ctx.Request = ctx.Request.WithContext(metadata.NewOutgoingContext(requestContext, newMD))
+	ctx.Next()
+}
+
+// setRetryPolicy sets the upstream retries, a request already retried by the mesh is not retried again
+// so the retries do not multiply across hops and drain the retry budget
+func (propagator *Propagator) setRetryPolicy(ctx *gin.Context, upstreamHeaders map[string]string) {
+	if propagator.maxRetries == nil {
+		return
+	}
+	maxRetries := *propagator.maxRetries
+	if propagator.trustInbound {
+		if attempt, err := strconv.Atoi(ctx.GetHeader(EnvoyAttemptCountHeader)); err == nil && attempt > firstAttempt {
+			maxRetries = 0
+		}
+	}
+	upstreamHeaders[EnvoyMaxRetriesHeader] = strconv.Itoa(maxRetries)
+	if maxRetries == 0 {
+		return
+	}
+	if propagator.retryOn != "" {
+		upstreamHeaders[EnvoyRetryOnHeader] = propagator.retryOn
+	}
+	if propagator.retryGRPCOn != "" {
+		upstreamHeaders[EnvoyRetryGRPCOnHeader] = propagator.retryGRPCOn
+	}
+	if propagator.perTryTimeout > 0 {
+		upstreamHeaders[EnvoyPerTryTimeoutHeader] = strconv.FormatInt(propagator.perTryTimeout.Milliseconds(), 10)
+	}
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, character := range requestID {
+		if character <= ' ' || character > '~' {
+			return false
+		}
+	}
+	return true
+}
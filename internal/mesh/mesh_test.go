@@ -0,0 +1,175 @@
+package mesh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+)
+
+type capturedRequest struct {
+	outgoing    metadata.MD
+	incoming    metadata.MD
+	hasDeadline bool
+	remaining   time.Duration
+}
+
+func serve(t *testing.T, meshConfig config.MeshConfig, headers map[string]string) (*httptest.ResponseRecorder, *capturedRequest) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	captured := &capturedRequest{}
+	router.Use(NewPropagator(&config.Config{Mesh: meshConfig}).Middleware)
+	router.GET("/api/v1/users", func(ctx *gin.Context) {
+		captured.outgoing, _ = metadata.FromOutgoingContext(ctx.Request.Context())
+		captured.incoming, _ = metadata.FromIncomingContext(ctx.Request.Context())
+		var deadline time.Time
+		deadline, captured.hasDeadline = ctx.Request.Context().Deadline()
+		captured.remaining = time.Until(deadline)
+		ctx.Status(http.StatusOK)
+	})
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder, captured
+}
+
+func get(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Middleware_Should_Adopt_The_Mesh_Request_ID_As_Correlation_ID", func(t *testing.T) {
+		recorder, captured := serve(t, config.MeshConfig{}, map[string]string{RequestIDHeader: "mesh-request-1"})
+
+		assert.Equal(t, "mesh-request-1", recorder.Header().Get(RequestIDHeader))
+		assert.Equal(t, "mesh-request-1", get(captured.outgoing, RequestIDHeader))
+		assert.Equal(t, "mesh-request-1", get(captured.outgoing, commonLogger.CorrelationIDKey))
+		assert.Equal(t, "mesh-request-1", get(captured.incoming, commonLogger.CorrelationIDKey))
+	})
+
+	t.Run("Middleware_Should_Generate_Invalid_Request_IDs", func(t *testing.T) {
+		recorder, _ := serve(t, config.MeshConfig{}, map[string]string{RequestIDHeader: strings.Repeat("a", 200)})
+
+		requestID := recorder.Header().Get(RequestIDHeader)
+		assert.NotEmpty(t, requestID)
+		assert.NotEqual(t, strings.Repeat("a", 200), requestID)
+	})
+
+	t.Run("Middleware_Should_Keep_The_Correlation_ID_Without_A_Request_ID", func(t *testing.T) {
+		router := gin.New()
+		outgoing := metadata.MD{}
+		router.Use(middleware.CorrelationID, NewPropagator(&config.Config{}).Middleware)
+		router.GET("/api/v1/users", func(ctx *gin.Context) {
+			outgoing, _ = metadata.FromOutgoingContext(ctx.Request.Context())
+			ctx.Status(http.StatusOK)
+		})
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		request.Header.Set(middleware.CorrelationIDHeader, "correlation-id")
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, "correlation-id", recorder.Header().Get(RequestIDHeader))
+		assert.Equal(t, "correlation-id", recorder.Header().Get(middleware.CorrelationIDHeader))
+		assert.Equal(t, "correlation-id", get(outgoing, RequestIDHeader))
+		assert.Equal(t, "correlation-id", get(outgoing, commonLogger.CorrelationIDKey))
+	})
+
+	t.Run("Middleware_Should_Continue_The_W3C_Trace_With_A_New_Span", func(t *testing.T) {
+		_, captured := serve(t, config.MeshConfig{}, map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"tracestate":  "vendor=value",
+		})
+
+		traceparent := strings.Split(get(captured.outgoing, TraceparentHeader), "-")
+		assert.Len(t, traceparent, 4)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceparent[1])
+		assert.NotEqual(t, "00f067aa0ba902b7", traceparent[2])
+		assert.Equal(t, "01", traceparent[3])
+		assert.Equal(t, "vendor=value", get(captured.outgoing, TracestateHeader))
+		assert.Empty(t, get(captured.outgoing, B3TraceIDHeader))
+	})
+
+	t.Run("Middleware_Should_Continue_The_B3_Trace", func(t *testing.T) {
+		_, captured := serve(t, config.MeshConfig{}, map[string]string{
+			"X-B3-TraceId": "463ac35c9f6413ad",
+			"X-B3-SpanId":  "a2fb4a1d1a96d312",
+			"X-B3-Sampled": "1",
+		})
+
+		assert.Equal(t, "463ac35c9f6413ad", get(captured.outgoing, B3TraceIDHeader))
+		assert.Equal(t, "a2fb4a1d1a96d312", get(captured.outgoing, B3ParentSpanHeader))
+		assert.Len(t, get(captured.outgoing, B3SpanIDHeader), 16)
+		assert.Equal(t, "1", get(captured.outgoing, B3SampledHeader))
+		assert.True(t, strings.HasPrefix(get(captured.outgoing, TraceparentHeader), "00-0000000000000000463ac35c9f6413ad-"))
+	})
+
+	t.Run("Middleware_Should_Read_The_Single_B3_Header", func(t *testing.T) {
+		_, captured := serve(t, config.MeshConfig{}, map[string]string{
+			"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-d",
+		})
+
+		assert.Equal(t, "80f198ee56343ba864fe8b2a57d3eff7", get(captured.outgoing, B3TraceIDHeader))
+		assert.Equal(t, "1", get(captured.outgoing, B3FlagsHeader))
+	})
+
+	t.Run("Middleware_Should_Start_A_Trace_When_None_Is_Received", func(t *testing.T) {
+		_, captured := serve(t, config.MeshConfig{GenerateB3: true, SampleNewTraces: true}, nil)
+
+		traceparent := strings.Split(get(captured.outgoing, TraceparentHeader), "-")
+		assert.Len(t, traceparent, 4)
+		assert.Equal(t, "01", traceparent[3])
+		assert.Equal(t, traceparent[1], get(captured.outgoing, B3TraceIDHeader))
+		assert.Equal(t, traceparent[2], get(captured.outgoing, B3SpanIDHeader))
+	})
+
+	t.Run("Middleware_Should_Honour_The_Envoy_Timeout_Of_Trusted_Meshes", func(t *testing.T) {
+		headers := map[string]string{EnvoyExpectedTimeoutHeader: "2000"}
+
+		_, captured := serve(t, config.MeshConfig{Timeout: 30 * time.Second, TrustInboundHeaders: true}, headers)
+		assert.True(t, captured.hasDeadline)
+		assert.True(t, captured.remaining <= 2*time.Second)
+		assert.Equal(t, "2000", get(captured.outgoing, EnvoyUpstreamTimeoutHeader))
+
+		_, captured = serve(t, config.MeshConfig{Timeout: 30 * time.Second}, headers)
+		assert.True(t, captured.remaining > 2*time.Second)
+		assert.Equal(t, "30000", get(captured.outgoing, EnvoyUpstreamTimeoutHeader))
+	})
+
+	t.Run("Middleware_Should_Set_The_Upstream_Retry_Policy", func(t *testing.T) {
+		meshConfig := config.MeshConfig{
+			TrustInboundHeaders: true,
+			MaxRetries:          intPointer(2),
+			RetryGRPCOn:         "unavailable",
+			PerTryTimeout:       time.Second,
+		}
+
+		_, captured := serve(t, meshConfig, nil)
+		assert.Equal(t, "2", get(captured.outgoing, EnvoyMaxRetriesHeader))
+		assert.Equal(t, "unavailable", get(captured.outgoing, EnvoyRetryGRPCOnHeader))
+		assert.Equal(t, "1000", get(captured.outgoing, EnvoyPerTryTimeoutHeader))
+
+		_, captured = serve(t, meshConfig, map[string]string{EnvoyAttemptCountHeader: "2"})
+		assert.Equal(t, "0", get(captured.outgoing, EnvoyMaxRetriesHeader))
+		assert.Empty(t, get(captured.outgoing, EnvoyRetryGRPCOnHeader))
+	})
+}
@@ -0,0 +1,139 @@
+package mesh
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// Trace headers understood by the mesh
+const (
+	TraceparentHeader  = "traceparent"
+	TracestateHeader   = "tracestate"
+	B3Header           = "b3"
+	B3TraceIDHeader    = "x-b3-traceid"
+	B3SpanIDHeader     = "x-b3-spanid"
+	B3ParentSpanHeader = "x-b3-parentspanid"
+	B3SampledHeader    = "x-b3-sampled"
+	B3FlagsHeader      = "x-b3-flags"
+)
+
+// traceContext is the trace of a request, the span is the one created by the gateway
+type traceContext struct {
+	traceID      string
+	spanID       string
+	parentSpanID string
+	sampled      string
+	debug        bool
+	tracestate   string
+	fromB3       bool
+}
+
+type headerGetter func(name string) string
+
+// parseTrace reads the W3C trace context and falls back to the single and multi B3 headers
+func parseTrace(get headerGetter) (*traceContext, bool) {
+	if trace, ok := parseTraceparent(get(TraceparentHeader)); ok {
+		trace.tracestate = get(TracestateHeader)
+		return trace, true
+	}
+	if trace, ok := parseSingleB3(get(B3Header)); ok {
+		return trace, true
+	}
+	traceID, spanID := strings.ToLower(get(B3TraceIDHeader)), strings.ToLower(get(B3SpanIDHeader))
+	if !isHex(traceID, 16, 32) || !isHex(spanID, 16, 16) {
+		return nil, false
+	}
+	return &traceContext{
+		traceID:      traceID,
+		parentSpanID: spanID,
+		sampled:      get(B3SampledHeader),
+		debug:        get(B3FlagsHeader) == "1",
+		fromB3:       true,
+	}, true
+}
+
+func parseTraceparent(traceparent string) (*traceContext, bool) {
+	fields := strings.Split(strings.ToLower(strings.TrimSpace(traceparent)), "-")
+	if len(fields) < 4 || fields[0] == "ff" || !isHex(fields[0], 2, 2) ||
+		!isHex(fields[1], 32, 32) || !isHex(fields[2], 16, 16) || !isHex(fields[3], 2, 2) ||
+		isZero(fields[1]) || isZero(fields[2]) {
+		return nil, false
+	}
+	flags, _ := hex.DecodeString(fields[3])
+	sampled := "0"
+	if flags[0]&1 == 1 {
+		sampled = "1"
+	}
+	return &traceContext{traceID: fields[1], parentSpanID: fields[2], sampled: sampled}, true
+}
+
+// parseSingleB3 reads the {traceId}-{spanId}-{sampled}-{parentSpanId} header, a lone sampling decision has no trace
+func parseSingleB3(b3 string) (*traceContext, bool) {
+	fields := strings.Split(strings.ToLower(strings.TrimSpace(b3)), "-")
+	if len(fields) < 2 || !isHex(fields[0], 16, 32) || !isHex(fields[1], 16, 16) {
+		return nil, false
+	}
+	trace := &traceContext{traceID: fields[0], parentSpanID: fields[1], fromB3: true}
+	if len(fields) > 2 {
+		trace.sampled = fields[2]
+		if trace.sampled == "d" {
+			trace.sampled, trace.debug = "1", true
+		}
+	}
+	return trace, true
+}
+
+// newTrace starts a trace at the gateway
+func newTrace(sampled bool) *traceContext {
+	trace := &traceContext{traceID: randomHex(16), sampled: "0"}
+	if sampled {
+		trace.sampled = "1"
+	}
+	return trace
+}
+
+// headers returns the headers propagating the trace to the upstreams
+func (trace *traceContext) headers(generateB3 bool) map[string]string {
+	flags := "00"
+	if trace.sampled == "1" || trace.debug {
+		flags = "01"
+	}
+	headers := map[string]string{
+		TraceparentHeader: "00-" + strings.Repeat("0", 32-len(trace.traceID)) + trace.traceID + "-" + trace.spanID + "-" + flags,
+	}
+	if trace.tracestate != "" {
+		headers[TracestateHeader] = trace.tracestate
+	}
+	if trace.fromB3 || generateB3 {
+		headers[B3TraceIDHeader] = trace.traceID
+		headers[B3SpanIDHeader] = trace.spanID
+		if trace.parentSpanID != "" {
+			headers[B3ParentSpanHeader] = trace.parentSpanID
+		}
+		if trace.debug {
+			headers[B3FlagsHeader] = "1"
+		} else if trace.sampled != "" {
+			headers[B3SampledHeader] = trace.sampled
+		}
+	}
+	return headers
+}
+
+func randomHex(length int) string {
+	random := make([]byte, length)
+	rand.Read(random)
+	return hex.EncodeToString(random)
+}
+
+func isHex(value string, minLength, maxLength int) bool {
+	if len(value) < minLength || len(value) > maxLength {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+func isZero(value string) bool {
+	return strings.Trim(value, "0") == ""
+}
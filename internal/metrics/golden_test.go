@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// The dashboards and alerts query the series by their names and labels, go test ./internal/metrics -update
+// rewrites the golden file once they are migrated
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+var labelNamePattern = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)="`)
+
+// seriesSignatures reduces the exposition to the types of the families and the label names of their series
+func seriesSignatures(exposition string) string {
+	seen := make(map[string]bool)
+	signatures := []string{}
+	for _, line := range strings.Split(exposition, "\n") {
+		if line == "" || strings.HasPrefix(line, "# HELP") {
+			continue
+		}
+		signature := line
+		if !strings.HasPrefix(line, "#") {
+			name := strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })[0]
+			labels := []string{}
+			if start := strings.Index(line, "{"); start >= 0 {
+				for _, match := range labelNamePattern.FindAllStringSubmatch(line[start:strings.LastIndex(line, "}")], -1) {
+					labels = append(labels, match[1])
+				}
+			}
+			signature = name + "{" + strings.Join(labels, ",") + "}"
+		}
+		if !seen[signature] {
+			seen[signature] = true
+			signatures = append(signatures, signature)
+		}
+	}
+	return strings.Join(signatures, "\n") + "\n"
+}
+
+func assertGolden(t *testing.T, name, actual string) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		assert.NoError(t, os.WriteFile(path, []byte(actual), 0o644))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), actual, "The output of %s changed, update the dashboards and alerts then rerun with -update", name)
+}
+
+func TestMetricsGolden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	metrics := NewMetrics(DefaultBuckets)
+	router := gin.New()
+	router.Use(metrics.Middleware)
+	metrics.Register(router, &config.Config{})
+	router.GET("/api/v1/user/profile", func(ctx *gin.Context) { ctx.AbortWithStatus(http.StatusUnauthorized) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/user/profile", nil))
+	connection := &InstrumentedConnection{
+		service:    "authentication",
+		connection: &fakeConnection{err: status.Error(codes.Unavailable, "connection refused")},
+		metrics:    metrics,
+	}
+	connection.Invoke(context.Background(), "/pb_authentication.AuthenticationService/GetPublicKey", nil, nil)
+	metrics.CertificateExpiry().Set(30, "certs/gateway.pem", "CN=api.example.com")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, DefaultPath, nil))
+
+	assertGolden(t, "metrics.golden", seriesSignatures(recorder.Body.String()))
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// DefaultPath is where the metrics are served when no path is configured
+const DefaultPath = "/metrics"
+
+// unmatchedRoute labels the requests matching no route, so unknown paths do not create series
+const unmatchedRoute = "unmatched"
+
+// Metrics are the series of the gateway traffic and of its calls to the upstream services
+type Metrics struct {
+	registry               *Registry
+	requests               *CounterVec
+	requestDuration        *HistogramVec
+	authenticationFailures *CounterVec
+	upstreamDuration       *HistogramVec
+	certificateExpiry      *GaugeVec
+}
+
+// DefaultMetrics is the registry used by the middleware and the connections created with InstrumentConnection
+var DefaultMetrics = NewMetrics(DefaultBuckets)
+
+// NewMetrics registers the gateway series with the given latency buckets
+func NewMetrics(buckets []float64) *Metrics {
+	registry := NewRegistry()
+	return &Metrics{
+		registry: registry,
+		requests: registry.Counter(
+			"gateway_http_requests_total", "HTTP requests answered by the gateway.", "method", "route", "status",
+		),
+		requestDuration: registry.Histogram(
+			"gateway_http_request_duration_seconds", "Latency of the HTTP requests answered by the gateway.", buckets, "method", "route",
+		),
+		authenticationFailures: registry.Counter(
+			"gateway_authentication_failures_total", "Requests rejected as unauthenticated or forbidden.", "route", "status",
+		),
+		upstreamDuration: registry.Histogram(
+			"gateway_upstream_call_duration_seconds", "Latency of the gRPC calls to the upstream services.", buckets, "service", "method", "code",
+		),
+		certificateExpiry: registry.Gauge(
+			"gateway_certificate_days_to_expiry", "Days left before the monitored certificates expire.", "file", "subject",
+		),
+	}
+}
+
+// CertificateExpiry returns the gauge of the days left before each monitored certificate expires
+func (metrics *Metrics) CertificateExpiry() *GaugeVec {
+	return metrics.certificateExpiry
+}
+
+// Middleware records the count, status and latency of the requests per route template
+func (metrics *Metrics) Middleware(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+	route := ctx.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	statusCode := ctx.Writer.Status()
+	metrics.requests.Inc(ctx.Request.Method, route, strconv.Itoa(statusCode))
+	metrics.requestDuration.Observe(time.Since(start).Seconds(), ctx.Request.Method, route)
+	if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+		metrics.authenticationFailures.Inc(route, strconv.Itoa(statusCode))
+	}
+}
+
+// Handler serves the metrics in the Prometheus text exposition format, behind the bearer token when one is configured
+func (metrics *Metrics) Handler(configurations *config.Config) gin.HandlerFunc {
+	expected := []byte("Bearer " + configurations.Metrics.Token)
+	return func(ctx *gin.Context) {
+		if configurations.Metrics.Token != "" &&
+			subtle.ConstantTimeCompare([]byte(ctx.GetHeader("Authorization")), expected) != 1 {
+			errors.Abort(ctx, errors.Unauthenticated)
+			return
+		}
+		ctx.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		ctx.Status(http.StatusOK)
+		metrics.registry.Write(ctx.Writer)
+	}
+}
+
+// Register serves the metrics at the configured path
+func (metrics *Metrics) Register(router *gin.Engine, configurations *config.Config) {
+	path := configurations.Metrics.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	router.GET(path, metrics.Handler(configurations))
+}
+
+// InstrumentedConnection records the latency and status code of the calls to a service
+type InstrumentedConnection struct {
+	service    string
+	connection grpc.ClientConnInterface
+	metrics    *Metrics
+}
+
+var _ grpc.ClientConnInterface = &InstrumentedConnection{}
+
+// Invoke calls the service and records the call
+func (connection *InstrumentedConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := connection.connection.Invoke(ctx, method, args, reply, opts...)
+	connection.metrics.upstreamDuration.Observe(time.Since(start).Seconds(), connection.service, method, status.Code(err).String())
+	return err
+}
+
+// NewStream opens the stream with the service, only the opening of the stream is recorded
+func (connection *InstrumentedConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+	stream, err := connection.connection.NewStream(ctx, desc, method, opts...)
+	connection.metrics.upstreamDuration.Observe(time.Since(start).Seconds(), connection.service, method, status.Code(err).String())
+	return stream, err
+}
+
+// InstrumentConnection records the calls to the service in the default metrics when the metrics are enabled
+func InstrumentConnection(service string, connection grpc.ClientConnInterface, configurations *config.Config) grpc.ClientConnInterface {
+	if !configurations.Metrics.Enabled {
+		return connection
+	}
+	return &InstrumentedConnection{service: service, connection: connection, metrics: DefaultMetrics}
+}
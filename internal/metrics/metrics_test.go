@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// fakeConnection answers every call with its error
+type fakeConnection struct {
+	err error
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	return connection.err
+}
+
+func (connection *fakeConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, connection.err
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(metrics *Metrics, configurations *config.Config) *gin.Engine {
+		router := gin.New()
+		router.Use(metrics.Middleware)
+		metrics.Register(router, configurations)
+		router.GET("/api/v1/user/:userID", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		router.GET("/api/v1/user/profile", func(ctx *gin.Context) { ctx.AbortWithStatus(http.StatusUnauthorized) })
+		return router
+	}
+	serve := func(router *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("Middleware_Should_Count_The_Requests_Per_Route_Template", func(t *testing.T) {
+		metrics := NewMetrics(DefaultBuckets)
+		router := newRouter(metrics, &config.Config{})
+
+		serve(router, "/api/v1/user/first", nil)
+		serve(router, "/api/v1/user/second", nil)
+		serve(router, "/api/v1/user/profile", nil)
+		serve(router, "/unknown/path", nil)
+
+		assert.Equal(t, float64(2), metrics.requests.Value(http.MethodGet, "/api/v1/user/:userID", "200"))
+		assert.Equal(t, float64(1), metrics.requests.Value(http.MethodGet, unmatchedRoute, "404"))
+		assert.Equal(t, uint64(2), metrics.requestDuration.Count(http.MethodGet, "/api/v1/user/:userID"))
+		assert.Equal(t, float64(1), metrics.authenticationFailures.Value("/api/v1/user/profile", "401"))
+	})
+
+	t.Run("Handler_Should_Write_The_Text_Exposition_Format", func(t *testing.T) {
+		metrics := NewMetrics([]float64{0.1, 1})
+		router := newRouter(metrics, &config.Config{})
+		metrics.upstreamDuration.Observe(0.5, "authentication", "/pb_authentication.AuthenticationService/GetPublicKey", "OK")
+
+		recorder := serve(router, DefaultPath, nil)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+		body := recorder.Body.String()
+		assert.Contains(t, body, "# TYPE gateway_upstream_call_duration_seconds histogram\n")
+		labels := `service="authentication",method="/pb_authentication.AuthenticationService/GetPublicKey",code="OK"`
+		assert.Contains(t, body, "gateway_upstream_call_duration_seconds_bucket{"+labels+`,le="0.1"} 0`+"\n")
+		assert.Contains(t, body, "gateway_upstream_call_duration_seconds_bucket{"+labels+`,le="1"} 1`+"\n")
+		assert.Contains(t, body, "gateway_upstream_call_duration_seconds_bucket{"+labels+`,le="+Inf"} 1`+"\n")
+		assert.Contains(t, body, "gateway_upstream_call_duration_seconds_sum{"+labels+"} 0.5\n")
+		assert.Contains(t, body, "gateway_upstream_call_duration_seconds_count{"+labels+"} 1\n")
+	})
+
+	t.Run("Handler_Should_Require_The_Configured_Token", func(t *testing.T) {
+		configurations := &config.Config{Metrics: config.MetricsConfig{Path: "/internal/metrics", Token: "scraper-token"}}
+		router := newRouter(NewMetrics(DefaultBuckets), configurations)
+
+		assert.Equal(t, http.StatusUnauthorized, serve(router, "/internal/metrics", nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(router, "/internal/metrics", map[string]string{"Authorization": "Bearer other"}).Code)
+		assert.Equal(t, http.StatusOK, serve(router, "/internal/metrics", map[string]string{"Authorization": "Bearer scraper-token"}).Code)
+	})
+
+	t.Run("InstrumentedConnection_Should_Record_The_Status_Code_Of_The_Calls", func(t *testing.T) {
+		metrics := NewMetrics(DefaultBuckets)
+		connection := &InstrumentedConnection{
+			service:    "payment",
+			connection: &fakeConnection{err: status.Error(codes.Unavailable, "connection refused")},
+			metrics:    metrics,
+		}
+
+		connection.Invoke(context.Background(), "/payment.PaymentService/GetCharge", nil, nil)
+
+		assert.Equal(t, uint64(1), metrics.upstreamDuration.Count("payment", "/payment.PaymentService/GetCharge", codes.Unavailable.String()))
+	})
+
+	t.Run("InstrumentConnection_Should_Keep_The_Connection_When_Disabled", func(t *testing.T) {
+		upstream := &fakeConnection{}
+
+		assert.Equal(t, upstream, InstrumentConnection("payment", upstream, &config.Config{}))
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("Write_Should_Escape_The_Label_Values", func(t *testing.T) {
+		registry := NewRegistry()
+		counter := registry.Counter("test_total", "Escaped\nhelp.", "path")
+		counter.Inc("/a\"b\\c\nd")
+		builder := &strings.Builder{}
+
+		assert.NoError(t, registry.Write(builder))
+
+		assert.Equal(t, "# HELP test_total Escaped\\nhelp.\n# TYPE test_total counter\ntest_total{path=\"/a\\\"b\\\\c\\nd\"} 1\n", builder.String())
+	})
+
+	t.Run("Replace_Should_Drop_The_Series_Of_The_Gauge_No_Longer_Measured", func(t *testing.T) {
+		registry := NewRegistry()
+		gauge := registry.Gauge("test_days", "Days.", "file")
+		gauge.Set(3, "old.pem")
+		builder := &strings.Builder{}
+
+		gauge.Replace([]GaugeValue{{LabelValues: []string{"new.pem"}, Value: 30}})
+		assert.NoError(t, registry.Write(builder))
+
+		assert.Equal(t, "# HELP test_days Days.\n# TYPE test_days gauge\ntest_days{file=\"new.pem\"} 30\n", builder.String())
+	})
+}
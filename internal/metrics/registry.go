@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kinds of the metric families
+const (
+	KindCounter   = "counter"
+	KindGauge     = "gauge"
+	KindHistogram = "histogram"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms when none are configured
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds the metric families and writes them in the Prometheus text exposition format
+type Registry struct {
+	families []*family
+	mtx      sync.Mutex
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+	mtx     sync.Mutex
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	count       uint64
+}
+
+func (registry *Registry) register(name, help, kind string, buckets []float64, labels []string) *family {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	metricFamily := &family{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*series)}
+	registry.families = append(registry.families, metricFamily)
+	return metricFamily
+}
+
+// seriesOf returns the series of the label values, creating it the first time, the caller holds the lock
+func (metricFamily *family) seriesOf(labelValues []string) *series {
+	if len(labelValues) != len(metricFamily.labels) {
+		panic(fmt.Sprintf("The metric %s takes %d labels, got %d", metricFamily.name, len(metricFamily.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	metricSeries, exists := metricFamily.series[key]
+	if !exists {
+		metricSeries = &series{labelValues: append([]string(nil), labelValues...)}
+		if metricFamily.kind == KindHistogram {
+			metricSeries.counts = make([]uint64, len(metricFamily.buckets))
+		}
+		metricFamily.series[key] = metricSeries
+	}
+	return metricSeries
+}
+
+// CounterVec is a counter partitioned by its labels
+type CounterVec struct {
+	family *family
+}
+
+// Counter registers a counter with the given label names
+func (registry *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{family: registry.register(name, help, KindCounter, nil, labels)}
+}
+
+// Inc adds one to the counter of the label values
+func (counter *CounterVec) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+// Add adds the value to the counter of the label values
+func (counter *CounterVec) Add(value float64, labelValues ...string) {
+	counter.family.mtx.Lock()
+	defer counter.family.mtx.Unlock()
+	counter.family.seriesOf(labelValues).value += value
+}
+
+// Value returns the counter of the label values
+func (counter *CounterVec) Value(labelValues ...string) float64 {
+	counter.family.mtx.Lock()
+	defer counter.family.mtx.Unlock()
+	return counter.family.seriesOf(labelValues).value
+}
+
+// GaugeVec is a gauge partitioned by its labels
+type GaugeVec struct {
+	family *family
+}
+
+// Gauge registers a gauge with the given label names
+func (registry *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{family: registry.register(name, help, KindGauge, nil, labels)}
+}
+
+// Set sets the gauge of the label values
+func (gauge *GaugeVec) Set(value float64, labelValues ...string) {
+	gauge.family.mtx.Lock()
+	defer gauge.family.mtx.Unlock()
+	gauge.family.seriesOf(labelValues).value = value
+}
+
+// Value returns the gauge of the label values
+func (gauge *GaugeVec) Value(labelValues ...string) float64 {
+	gauge.family.mtx.Lock()
+	defer gauge.family.mtx.Unlock()
+	return gauge.family.seriesOf(labelValues).value
+}
+
+// GaugeValue is the value of the series of a gauge with the label values
+type GaugeValue struct {
+	LabelValues []string
+	Value       float64
+}
+
+// Replace drops every series of the gauge and sets the given ones at once, so the series no longer measured
+// are not written anymore
+func (gauge *GaugeVec) Replace(values []GaugeValue) {
+	gauge.family.mtx.Lock()
+	defer gauge.family.mtx.Unlock()
+	gauge.family.series = make(map[string]*series)
+	for _, value := range values {
+		gauge.family.seriesOf(value.LabelValues).value = value.Value
+	}
+}
+
+// HistogramVec is a histogram partitioned by its labels
+type HistogramVec struct {
+	family *family
+}
+
+// Histogram registers a histogram with the given bucket upper bounds and label names
+func (registry *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &HistogramVec{family: registry.register(name, help, KindHistogram, sorted, labels)}
+}
+
+// Observe records the value in the histogram of the label values
+func (histogram *HistogramVec) Observe(value float64, labelValues ...string) {
+	histogram.family.mtx.Lock()
+	defer histogram.family.mtx.Unlock()
+	metricSeries := histogram.family.seriesOf(labelValues)
+	for index, upperBound := range histogram.family.buckets {
+		if value <= upperBound {
+			metricSeries.counts[index]++
+		}
+	}
+	metricSeries.count++
+	metricSeries.value += value
+}
+
+// Count returns the number of values observed in the histogram of the label values
+func (histogram *HistogramVec) Count(labelValues ...string) uint64 {
+	histogram.family.mtx.Lock()
+	defer histogram.family.mtx.Unlock()
+	return histogram.family.seriesOf(labelValues).count
+}
+
+// Write writes every family in the Prometheus text exposition format, the series sorted by their labels
+func (registry *Registry) Write(writer io.Writer) error {
+	registry.mtx.Lock()
+	families := append([]*family(nil), registry.families...)
+	registry.mtx.Unlock()
+	buffered := bufio.NewWriter(writer)
+	for _, metricFamily := range families {
+		metricFamily.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+func (metricFamily *family) write(writer *bufio.Writer) {
+	metricFamily.mtx.Lock()
+	defer metricFamily.mtx.Unlock()
+	fmt.Fprintf(writer, "# HELP %s %s\n", metricFamily.name, escapeHelp(metricFamily.help))
+	fmt.Fprintf(writer, "# TYPE %s %s\n", metricFamily.name, metricFamily.kind)
+	keys := make([]string, 0, len(metricFamily.series))
+	for key := range metricFamily.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		metricSeries := metricFamily.series[key]
+		if metricFamily.kind != KindHistogram {
+			fmt.Fprintf(writer, "%s%s %s\n", metricFamily.name, metricFamily.labelSet(metricSeries.labelValues, "", ""), formatValue(metricSeries.value))
+			continue
+		}
+		for index, upperBound := range metricFamily.buckets {
+			fmt.Fprintf(writer, "%s_bucket%s %d\n", metricFamily.name,
+				metricFamily.labelSet(metricSeries.labelValues, "le", formatValue(upperBound)), metricSeries.counts[index])
+		}
+		fmt.Fprintf(writer, "%s_bucket%s %d\n", metricFamily.name, metricFamily.labelSet(metricSeries.labelValues, "le", "+Inf"), metricSeries.count)
+		fmt.Fprintf(writer, "%s_sum%s %s\n", metricFamily.name, metricFamily.labelSet(metricSeries.labelValues, "", ""), formatValue(metricSeries.value))
+		fmt.Fprintf(writer, "%s_count%s %d\n", metricFamily.name, metricFamily.labelSet(metricSeries.labelValues, "", ""), metricSeries.count)
+	}
+}
+
+func (metricFamily *family) labelSet(labelValues []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(labelValues)+1)
+	for index, name := range metricFamily.labels {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabel(labelValues[index])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
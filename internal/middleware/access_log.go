@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys selecting how a request is written to the access log
+const (
+	LogProfileKey    = "log_profile"
+	LogRouteKey      = "log_route"
+	PublicLogProfile = "public"
+)
+
+// PublicLogging makes the access log record the route template instead of the raw path and query,
+// since public routes carry verification and reset tokens in their URLs
+func PublicLogging(ctx *gin.Context) {
+	ctx.Set(LogProfileKey, PublicLogProfile)
+	ctx.Set(LogRouteKey, ctx.FullPath())
+	ctx.Next()
+}
+
+// AccessLogFormatter formats the access log like gin does, honouring the log profile of the request
+func AccessLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	path := param.Path
+	if query, ok := param.Keys[LogQueryKey].(string); ok {
+		path = param.Request.URL.Path
+		if query != "" {
+			path += "?" + query
+		}
+	}
+	errorMessage := param.ErrorMessage
+	if param.Keys[LogProfileKey] == PublicLogProfile {
+		if route, ok := param.Keys[LogRouteKey].(string); ok && route != "" {
+			path = route
+		} else {
+			path = param.Request.URL.Path
+		}
+		errorMessage = ""
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		path,
+		errorMessage,
+	)
+}
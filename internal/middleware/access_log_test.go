@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// The access log lines are parsed by the log pipeline, go test ./internal/middleware -update rewrites the
+// golden file once it is migrated
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestAccessLogFormatterGolden(t *testing.T) {
+	format := func(keys map[string]interface{}) string {
+		return AccessLogFormatter(gin.LogFormatterParams{
+			Request:      httptest.NewRequest(http.MethodPost, "/api/v1/user/user-id/password/reset/token", nil),
+			TimeStamp:    time.Date(2024, time.March, 1, 12, 30, 45, 0, time.UTC),
+			StatusCode:   http.StatusBadRequest,
+			Latency:      1500 * time.Microsecond,
+			ClientIP:     "203.0.113.7",
+			Method:       http.MethodPost,
+			Path:         "/api/v1/user/user-id/password/reset/token?lang=en",
+			ErrorMessage: "Error #01: invalid token\n",
+			Keys:         keys,
+		})
+	}
+
+	actual := format(nil) + format(map[string]interface{}{
+		LogProfileKey: PublicLogProfile,
+		LogRouteKey:   "/api/v1/user/:userID/password/reset/:verificationToken",
+	})
+
+	path := filepath.Join("testdata", "access_log.golden")
+	if *update {
+		assert.NoError(t, os.WriteFile(path, []byte(actual), 0o644))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), actual, "The access log format changed, update the log pipeline then rerun with -update")
+}
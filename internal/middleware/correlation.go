@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"google.golang.org/grpc/metadata"
+)
+
+// CorrelationIDHeader carries the correlation ID of the request, read from the client and echoed in the response
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// CorrelationIDContextKey is the gin context key of the correlation ID of the request
+const CorrelationIDContextKey = "correlationID"
+
+const maxCorrelationIDLength = 128
+
+// CorrelationID adopts the correlation ID of the request header, or generates one when it is missing or invalid,
+// and keeps it in the gin context, in the incoming metadata read by the logger and in the outgoing metadata
+// sent to the backends. It runs before the logger middleware so the request logger carries it
+func CorrelationID(ctx *gin.Context) {
+	correlationID := ctx.GetHeader(CorrelationIDHeader)
+	if !ValidCorrelationID(correlationID) {
+		correlationID = uuid.New().String()
+	}
+	SetCorrelationID(ctx, correlationID)
+	ctx.Next()
+}
+
+// SetCorrelationID replaces the correlation ID of the request, keeping the rest of its metadata
+func SetCorrelationID(ctx *gin.Context, correlationID string) {
+	ctx.Set(CorrelationIDContextKey, correlationID)
+	ctx.Header(CorrelationIDHeader, correlationID)
+	ctx.Request = ctx.Request.WithContext(WithCorrelationID(ctx.Request.Context(), correlationID))
+}
+
+// WithCorrelationID returns the context with the correlation ID in its incoming and outgoing metadata
+func WithCorrelationID(requestContext context.Context, correlationID string) context.Context {
+	incomingMD, ok := metadata.FromIncomingContext(requestContext)
+	if !ok {
+		incomingMD = metadata.New(map[string]string{})
+	}
+	newIncomingMD := incomingMD.Copy()
+	newIncomingMD.Set(commonLogger.CorrelationIDKey, correlationID)
+	requestContext = metadata.NewIncomingContext(requestContext, newIncomingMD)
+
+	outgoingMD, ok := metadata.FromOutgoingContext(requestContext)
+	if !ok {
+		outgoingMD = metadata.New(map[string]string{})
+	}
+	newOutgoingMD := outgoingMD.Copy()
+	newOutgoingMD.Set(commonLogger.CorrelationIDKey, correlationID)
+	return metadata.NewOutgoingContext(requestContext, newOutgoingMD)
+}
+
+// GetCorrelationID returns the correlation ID of the request, empty before the middleware ran
+func GetCorrelationID(ctx *gin.Context) string {
+	return ctx.GetString(CorrelationIDContextKey)
+}
+
+// ValidCorrelationID tells whether the client correlation ID can be adopted, printable ASCII of a bounded length
+// so it cannot inject log lines or oversized metadata
+func ValidCorrelationID(correlationID string) bool {
+	if correlationID == "" || len(correlationID) > maxCorrelationIDLength {
+		return false
+	}
+	for _, character := range correlationID {
+		if character <= ' ' || character > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestCorrelationID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(headers map[string]string) (*httptest.ResponseRecorder, string, metadata.MD, metadata.MD) {
+		router := gin.New()
+		var stored string
+		var incoming, outgoing metadata.MD
+		router.Use(func(ctx *gin.Context) {
+			ctx.Request = ctx.Request.WithContext(metadata.NewIncomingContext(
+				ctx.Request.Context(),
+				metadata.Pairs("authorization", "Bearer token"),
+			))
+		}, CorrelationID)
+		router.GET("/api/v1/users", func(ctx *gin.Context) {
+			stored = GetCorrelationID(ctx)
+			incoming, _ = metadata.FromIncomingContext(ctx.Request.Context())
+			outgoing, _ = metadata.FromOutgoingContext(ctx.Request.Context())
+			ctx.Status(http.StatusOK)
+		})
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder, stored, incoming, outgoing
+	}
+
+	t.Run("CorrelationID_Should_Adopt_The_Header_Of_The_Request", func(t *testing.T) {
+		recorder, stored, incoming, outgoing := serve(map[string]string{CorrelationIDHeader: "correlation-id"})
+
+		assert.Equal(t, "correlation-id", stored)
+		assert.Equal(t, "correlation-id", recorder.Header().Get(CorrelationIDHeader))
+		assert.Equal(t, []string{"correlation-id"}, incoming.Get(commonLogger.CorrelationIDKey))
+		assert.Equal(t, []string{"Bearer token"}, incoming.Get("authorization"))
+		assert.Equal(t, []string{"correlation-id"}, outgoing.Get(commonLogger.CorrelationIDKey))
+	})
+
+	t.Run("CorrelationID_Should_Generate_An_ID_Without_The_Header", func(t *testing.T) {
+		recorder, stored, incoming, outgoing := serve(map[string]string{})
+
+		_, err := uuid.Parse(stored)
+		assert.NoError(t, err)
+		assert.Equal(t, stored, recorder.Header().Get(CorrelationIDHeader))
+		assert.Equal(t, []string{stored}, incoming.Get(commonLogger.CorrelationIDKey))
+		assert.Equal(t, []string{stored}, outgoing.Get(commonLogger.CorrelationIDKey))
+	})
+
+	t.Run("CorrelationID_Should_Replace_Invalid_IDs", func(t *testing.T) {
+		for _, invalidID := range []string{strings.Repeat("a", 129), "line\nbreak", "naïve"} {
+			_, stored, _, _ := serve(map[string]string{CorrelationIDHeader: invalidID})
+
+			assert.NotEqual(t, invalidID, stored)
+			_, err := uuid.Parse(stored)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("CorrelationID_Should_Be_Read_By_The_Logger", func(t *testing.T) {
+		_, _, incoming, _ := serve(map[string]string{CorrelationIDHeader: "correlation-id"})
+
+		correlationID, err := commonLogger.GetCorrelationIDFromContext(metadata.NewIncomingContext(context.Background(), incoming))
+		assert.NoError(t, err)
+		assert.Equal(t, "correlation-id", *correlationID)
+	})
+}
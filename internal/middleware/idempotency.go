@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Idempotency key header, upstream metadata key and length bounds
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyKeyMetadataKey = "idempotency-key"
+	minIdempotencyKeyLength   = 8
+	maxIdempotencyKeyLength   = 255
+)
+
+// RequireIdempotencyKey rejects requests without a valid idempotency key and forwards it upstream
+func RequireIdempotencyKey(ctx *gin.Context) {
+	idempotencyKey := ctx.GetHeader(IdempotencyKeyHeader)
+	if len(idempotencyKey) < minIdempotencyKeyLength || len(idempotencyKey) > maxIdempotencyKeyLength {
+		errors.Abort(ctx, errors.IdempotencyKeyRequired)
+		return
+	}
+
+	existingMD, ok := metadata.FromOutgoingContext(ctx.Request.Context())
+	if !ok {
+		existingMD = metadata.New(map[string]string{})
+	}
+	newMD := existingMD.Copy()
+	newMD.Set(IdempotencyKeyMetadataKey, idempotencyKey)
+	ctx.Request = ctx.Request.WithContext(metadata.NewOutgoingContext(ctx.Request.Context(), newMD))
+	ctx.Set(IdempotencyKeyMetadataKey, idempotencyKey)
+	ctx.Next()
+}
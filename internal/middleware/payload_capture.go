@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// NoPayloadCaptureKey marks the requests whose bodies are never captured by the journal or the request log
+const NoPayloadCaptureKey = "no_payload_capture"
+
+// NoPayloadCapture keeps the request and response bodies of the route out of the journal and the request log,
+// whatever their configuration, used by the routes carrying payment details
+func NoPayloadCapture(ctx *gin.Context) {
+	ctx.Set(NoPayloadCaptureKey, true)
+	ctx.Next()
+}
+
+// PayloadCaptured tells whether the bodies of the request may be captured, checked once the request is answered
+// since the marker is set by the route
+func PayloadCaptured(ctx *gin.Context) bool {
+	return !ctx.GetBool(NoPayloadCaptureKey)
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// Modes of the query redaction of a route
+const (
+	QueryRedactionHash = "hash"
+	QueryRedactionDrop = "drop"
+)
+
+// LogQueryKey is the context key of the redacted query string recorded instead of the raw one
+const LogQueryKey = "log_query"
+
+// hashedValueLength is the length of the hex digest prefix recorded for a hashed query value
+const hashedValueLength = 16
+
+// QueryRedactor hashes or drops the query strings of the configured routes before the access log and the
+// traces record them, as some routes carry tokens in their query strings
+type QueryRedactor struct {
+	routes  []config.QueryRedactionRouteConfig
+	hashKey []byte
+}
+
+// NewQueryRedactor creates the redactor of the configured routes, the longest path prefixes first so the most
+// specific one applies
+func NewQueryRedactor(configurations *config.Config) (*QueryRedactor, error) {
+	redactionConfig := configurations.QueryRedaction
+	routes := append([]config.QueryRedactionRouteConfig{}, redactionConfig.Routes...)
+	for _, route := range routes {
+		switch route.Mode {
+		case QueryRedactionDrop:
+		case QueryRedactionHash:
+			if redactionConfig.HashKey == "" {
+				return nil, fmt.Errorf("The query redaction of %s hashes the values without a hash key", route.PathPrefix)
+			}
+		default:
+			return nil, fmt.Errorf("The query redaction of %s has an unknown mode %q", route.PathPrefix, route.Mode)
+		}
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			return nil, fmt.Errorf("The query redaction path prefix %s must start with /", route.PathPrefix)
+		}
+	}
+	sort.SliceStable(routes, func(first, second int) bool {
+		return len(routes[first].PathPrefix) > len(routes[second].PathPrefix)
+	})
+	return &QueryRedactor{routes: routes, hashKey: []byte(redactionConfig.HashKey)}, nil
+}
+
+// Middleware records the redacted query string of the requests under a configured path prefix
+func (redactor *QueryRedactor) Middleware(ctx *gin.Context) {
+	if query, ok := redactor.Redact(ctx.Request.URL); ok {
+		ctx.Set(LogQueryKey, query)
+	}
+	ctx.Next()
+}
+
+// Redact returns the query string of the URL as recorded when its path is under a configured prefix
+func (redactor *QueryRedactor) Redact(requestURL *url.URL) (string, bool) {
+	for _, route := range redactor.routes {
+		if !strings.HasPrefix(requestURL.Path, route.PathPrefix) {
+			continue
+		}
+		if route.Mode == QueryRedactionDrop || requestURL.RawQuery == "" {
+			return "", true
+		}
+		query, err := url.ParseQuery(requestURL.RawQuery)
+		if err != nil {
+			return "", true
+		}
+		hashed := url.Values{}
+		for name, values := range query {
+			for _, value := range values {
+				hashed.Add(name, redactor.hash(value))
+			}
+		}
+		return hashed.Encode(), true
+	}
+	return "", false
+}
+
+func (redactor *QueryRedactor) hash(value string) string {
+	mac := hmac.New(sha256.New, redactor.hashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:hashedValueLength]
+}
+
+// LoggedPath returns the path of the request as the observability outputs record it, the route template
+// for the public log profile
+func LoggedPath(ctx *gin.Context) string {
+	if ctx.GetString(LogProfileKey) == PublicLogProfile {
+		if route := ctx.GetString(LogRouteKey); route != "" {
+			return route
+		}
+	}
+	return ctx.Request.URL.Path
+}
+
+// LoggedQuery returns the query string of the request as the observability outputs record it, redacted for
+// the configured routes and left out for the public log profile
+func LoggedQuery(ctx *gin.Context) string {
+	if ctx.GetString(LogProfileKey) == PublicLogProfile {
+		return ""
+	}
+	if query, ok := ctx.Get(LogQueryKey); ok {
+		return query.(string)
+	}
+	return ctx.Request.URL.RawQuery
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newTestQueryRedactor(t *testing.T) *QueryRedactor {
+	redactor, err := NewQueryRedactor(&config.Config{QueryRedaction: config.QueryRedactionConfig{
+		HashKey: "hash-key",
+		Routes: []config.QueryRedactionRouteConfig{
+			{PathPrefix: "/api/v1/user/", Mode: QueryRedactionHash},
+			{PathPrefix: "/api/v1/user/password/", Mode: QueryRedactionDrop},
+		},
+	}})
+	assert.NoError(t, err)
+	return redactor
+}
+
+func TestQueryRedactor(t *testing.T) {
+	t.Run("Redact_Should_Apply_The_Most_Specific_Route", func(t *testing.T) {
+		redactor := newTestQueryRedactor(t)
+
+		dropped, ok := redactor.Redact(httptest.NewRequest(http.MethodGet, "/api/v1/user/password/reset?token=secret", nil).URL)
+		assert.True(t, ok)
+		assert.Empty(t, dropped)
+
+		hashed, ok := redactor.Redact(httptest.NewRequest(http.MethodGet, "/api/v1/user/verify?token=secret&lang=en", nil).URL)
+		assert.True(t, ok)
+		assert.NotContains(t, hashed, "secret")
+		assert.Regexp(t, `^lang=[0-9a-f]{16}&token=[0-9a-f]{16}$`, hashed)
+		again, _ := redactor.Redact(httptest.NewRequest(http.MethodGet, "/api/v1/user/verify?token=secret&lang=en", nil).URL)
+		assert.Equal(t, hashed, again)
+
+		_, ok = redactor.Redact(httptest.NewRequest(http.MethodGet, "/api/v1/media?token=secret", nil).URL)
+		assert.False(t, ok)
+	})
+
+	t.Run("NewQueryRedactor_Should_Reject_The_Invalid_Routes", func(t *testing.T) {
+		for _, redactionConfig := range []config.QueryRedactionConfig{
+			{Routes: []config.QueryRedactionRouteConfig{{PathPrefix: "/api/v1/user/", Mode: QueryRedactionHash}}},
+			{Routes: []config.QueryRedactionRouteConfig{{PathPrefix: "/api/v1/user/", Mode: "encrypt"}}},
+			{Routes: []config.QueryRedactionRouteConfig{{PathPrefix: "api/v1/user/", Mode: QueryRedactionDrop}}},
+		} {
+			_, err := NewQueryRedactor(&config.Config{QueryRedaction: redactionConfig})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("AccessLogFormatter_Should_Record_The_Redacted_Query", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		var line string
+		router := gin.New()
+		router.Use(newTestQueryRedactor(t).Middleware, func(ctx *gin.Context) {
+			ctx.Next()
+			line = AccessLogFormatter(gin.LogFormatterParams{
+				Request:    ctx.Request,
+				TimeStamp:  time.Now(),
+				StatusCode: ctx.Writer.Status(),
+				Method:     ctx.Request.Method,
+				Path:       ctx.Request.URL.Path + "?" + ctx.Request.URL.RawQuery,
+				Keys:       ctx.Keys,
+			})
+		})
+		router.POST("/api/v1/user/password/reset", func(ctx *gin.Context) {
+			assert.Empty(t, LoggedQuery(ctx))
+			ctx.Status(http.StatusNoContent)
+		})
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/user/password/reset?token=secret", nil))
+
+		assert.True(t, strings.Contains(line, `"/api/v1/user/password/reset"`), line)
+		assert.NotContains(t, line, "secret")
+	})
+}
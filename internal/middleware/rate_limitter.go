@@ -1,10 +1,10 @@
 package middleware
 
 import (
-	"net/http"
 	"sync"
 
 	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
 	"golang.org/x/time/rate"
 
 	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
@@ -48,9 +48,27 @@ func RateLimitMiddleware(rl *RateLimiter) gin.HandlerFunc {
 		limiter := rl.GetLimiter(ip)
 
 		if !limiter.Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error": errors.TooManyRequests,
-			})
+			errors.Abort(c, errors.TooManyRequests)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UserRateLimitMiddleware returns the rate limiter middleware keyed by the authenticated user, falling back to the IP
+func UserRateLimitMiddleware(rl *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.ClientIP()
+		if claimsValue, exists := c.Get(string(commonJWT.ClaimsContextKey)); exists {
+			if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+				key = claims.UserID
+			}
+		}
+		limiter := rl.GetLimiter(key)
+
+		if !limiter.Allow() {
+			errors.Abort(c, errors.TooManyRequests)
 			return
 		}
 
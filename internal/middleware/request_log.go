@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// RedactedValue replaces the values of the redacted fields and the email addresses of the logged bodies
+const RedactedValue = "[REDACTED]"
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// RequestLogEntry is the structured log line of a handled request
+type RequestLogEntry struct {
+	Method        string          `json:"method"`
+	Path          string          `json:"path"`
+	Query         string          `json:"query,omitempty"`
+	Status        int             `json:"status"`
+	LatencyMs     int64           `json:"latencyMs"`
+	UserID        string          `json:"userID,omitempty"`
+	CorrelationID string          `json:"correlationID,omitempty"`
+	RequestBody   json.RawMessage `json:"requestBody,omitempty"`
+	ResponseBody  json.RawMessage `json:"responseBody,omitempty"`
+}
+
+// RequestLogger logs every request once answered, with its bodies redacted when debug bodies are enabled
+type RequestLogger struct {
+	debugBodies    bool
+	maxBodyBytes   int
+	redactedFields []string
+	redactEmails   bool
+	excludedPaths  []string
+}
+
+// NewRequestLogger creates the request logger of the configuration, the redacted fields match the JSON keys
+// containing them regardless of the case and the excluded paths are path patterns, a trailing * matching the rest of the path
+func NewRequestLogger(configurations *config.Config) (*RequestLogger, error) {
+	logConfig := configurations.RequestLog
+	if logConfig.DebugBodies && logConfig.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("The request log needs a maximum body size to log the bodies")
+	}
+	logger := &RequestLogger{
+		debugBodies:  logConfig.DebugBodies,
+		maxBodyBytes: logConfig.MaxBodyBytes,
+		redactEmails: logConfig.RedactEmails,
+	}
+	for _, field := range logConfig.RedactedFields {
+		if field == "" {
+			return nil, fmt.Errorf("The request log has an empty redacted field")
+		}
+		logger.redactedFields = append(logger.redactedFields, strings.ToLower(field))
+	}
+	for _, pattern := range logConfig.ExcludedPaths {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("Invalid request log excluded path %q", pattern)
+		}
+		logger.excludedPaths = append(logger.excludedPaths, pattern)
+	}
+	return logger, nil
+}
+
+// Middleware logs the method, path, status, latency, user and correlation ID of the request once answered
+func (requestLogger *RequestLogger) Middleware(ctx *gin.Context) {
+	if requestLogger.excluded(ctx.Request.URL.Path) {
+		ctx.Next()
+		return
+	}
+	start := time.Now()
+	var requestBody []byte
+	requestTruncated := false
+	var writer *capturingWriter
+	if requestLogger.debugBodies {
+		if ctx.Request.Body != nil {
+			requestBody, requestTruncated = requestLogger.peekBody(ctx.Request)
+		}
+		writer = &capturingWriter{ResponseWriter: ctx.Writer, limit: requestLogger.maxBodyBytes}
+		ctx.Writer = writer
+	}
+	ctx.Next()
+
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		return
+	}
+	entry := RequestLogEntry{
+		Method:    ctx.Request.Method,
+		Path:      LoggedPath(ctx),
+		Query:     LoggedQuery(ctx),
+		Status:    ctx.Writer.Status(),
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey)); exists {
+		if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+			entry.UserID = claims.UserID
+		}
+	}
+	if correlationID, err := commonLogger.GetCorrelationIDFromContext(ctx.Request.Context()); err == nil {
+		entry.CorrelationID = *correlationID
+	}
+	if writer != nil && PayloadCaptured(ctx) {
+		entry.RequestBody = requestLogger.Body(requestBody, requestTruncated, ctx.Request.Header.Get("Content-Type"))
+		entry.ResponseBody = requestLogger.Body(writer.body.Bytes(), writer.truncated, writer.Header().Get("Content-Type"))
+	}
+	serializedEntry, err := json.Marshal(entry)
+	if err != nil {
+		logger.Error(err, "Could not serialize the request log entry")
+		return
+	}
+	logger.Info(fmt.Sprintf("Request log: %s", serializedEntry))
+}
+
+// excluded tells whether the path matches an excluded path, path.Match does not let * cross slashes
+// so a trailing * matches the rest of the path
+func (requestLogger *RequestLogger) excluded(requestPath string) bool {
+	for _, pattern := range requestLogger.excludedPaths {
+		if strings.HasSuffix(pattern, "*") && !strings.ContainsAny(pattern[:len(pattern)-1], "*?[") {
+			if strings.HasPrefix(requestPath, pattern[:len(pattern)-1]) {
+				return true
+			}
+			continue
+		}
+		if matches, _ := path.Match(pattern, requestPath); matches {
+			return true
+		}
+	}
+	return false
+}
+
+// peekBody reads the beginning of the request body and restores it for the next handlers
+func (requestLogger *RequestLogger) peekBody(request *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(request.Body, int64(requestLogger.maxBodyBytes)+1))
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), request.Body), request.Body}
+	if err != nil {
+		return nil, false
+	}
+	if len(body) > requestLogger.maxBodyBytes {
+		return body[:requestLogger.maxBodyBytes], true
+	}
+	return body, false
+}
+
+// Body returns the logged form of a body, the redacted JSON document or a note of why it was left out, as a
+// truncated or non-JSON body could leak the values the redaction would have masked
+func (requestLogger *RequestLogger) Body(body []byte, truncated bool, contentType string) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if truncated {
+		return note(fmt.Sprintf("[body of more than %d bytes omitted]", requestLogger.maxBodyBytes))
+	}
+	if !strings.Contains(strings.ToLower(contentType), "json") {
+		return note(fmt.Sprintf("[%s body omitted]", contentType))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// The numbers are kept as written so the logged document matches the body
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return note("[invalid JSON body omitted]")
+	}
+	redacted, err := json.Marshal(requestLogger.Redact(document))
+	if err != nil {
+		return note("[invalid JSON body omitted]")
+	}
+	return redacted
+}
+
+// Redact masks the values of the redacted fields of the JSON document and its email addresses
+func (requestLogger *RequestLogger) Redact(document interface{}) interface{} {
+	switch typed := document.(type) {
+	case map[string]interface{}:
+		for key, value := range typed {
+			if requestLogger.isRedacted(key) {
+				typed[key] = RedactedValue
+				continue
+			}
+			typed[key] = requestLogger.Redact(value)
+		}
+	case []interface{}:
+		for index, value := range typed {
+			typed[index] = requestLogger.Redact(value)
+		}
+	case string:
+		if requestLogger.redactEmails {
+			return emailPattern.ReplaceAllString(typed, RedactedValue)
+		}
+	}
+	return document
+}
+
+func (requestLogger *RequestLogger) isRedacted(key string) bool {
+	lowerKey := strings.ToLower(key)
+	for _, field := range requestLogger.redactedFields {
+		if strings.Contains(lowerKey, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func note(message string) json.RawMessage {
+	serializedNote, _ := json.Marshal(message)
+	return serializedNote
+}
+
+// capturingWriter keeps the beginning of the response body for the request log
+type capturingWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (writer *capturingWriter) capture(data []byte) {
+	remaining := writer.limit - writer.body.Len()
+	if len(data) > remaining {
+		data = data[:remaining]
+		writer.truncated = true
+	}
+	writer.body.Write(data)
+}
+
+func (writer *capturingWriter) Write(data []byte) (int, error) {
+	writer.capture(data)
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *capturingWriter) WriteString(data string) (int, error) {
+	writer.capture([]byte(data))
+	return writer.ResponseWriter.WriteString(data)
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logConfig := config.RequestLogConfig{
+		Enabled:        true,
+		DebugBodies:    true,
+		MaxBodyBytes:   1024,
+		RedactedFields: []string{"password", "token"},
+		RedactEmails:   true,
+		ExcludedPaths:  []string{"/healthz", "/internal/*", "/api/v1/*/probe"},
+	}
+	serve := func(t *testing.T, logConfig config.RequestLogConfig, request *http.Request) (RequestLogEntry, bool) {
+		requestLogger, err := NewRequestLogger(&config.Config{RequestLog: logConfig})
+		assert.NoError(t, err)
+		controller := gomock.NewController(t)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		logged := ""
+		loggerMock.EXPECT().Info(gomock.Any()).Do(func(message string) { logged = message }).AnyTimes()
+		router := gin.New()
+		router.Use(func(ctx *gin.Context) {
+			requestContext := commonLogger.AddCorrelationIDToIncomingContext(ctx.Request.Context(), "correlation-id")
+			ctx.Request = ctx.Request.WithContext(context.WithValue(requestContext, commonLogger.LoggerKey, loggerMock))
+		}, requestLogger.Middleware)
+		handler := func(ctx *gin.Context) {
+			ctx.Set(string(commonJWT.ClaimsContextKey), &commonJWT.TokenClaims{UserID: "user-id"})
+			body := map[string]interface{}{}
+			assert.NoError(t, ctx.ShouldBindJSON(&body))
+			ctx.JSON(http.StatusCreated, gin.H{"accessToken": "secret", "contact": "user@example.com"})
+		}
+		router.POST("/api/v1/user/sessions", handler)
+		router.POST("/healthz", handler)
+		router.POST("/internal/jobs/status", handler)
+		router.POST("/api/v1/users/probe", handler)
+		router.POST("/api/v1/payments/charges", NoPayloadCapture, handler)
+
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		entry := RequestLogEntry{}
+		prefix, serializedEntry, found := strings.Cut(logged, "{")
+		if !found {
+			return entry, false
+		}
+		assert.Equal(t, "Request log: ", prefix)
+		assert.NoError(t, json.Unmarshal([]byte("{"+serializedEntry), &entry))
+		return entry, true
+	}
+	newRequest := func(path string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"email":"user@example.com","password":"hunter2","profile":{"refreshToken":"abc","note":"mail me at user@example.com","age":30}}`))
+		request.Header.Set("Content-Type", "application/json")
+		return request
+	}
+
+	t.Run("Middleware_Should_Log_The_Request_With_Redacted_Bodies", func(t *testing.T) {
+		entry, logged := serve(t, logConfig, newRequest("/api/v1/user/sessions?lang=en"))
+
+		assert.True(t, logged)
+		assert.Equal(t, http.MethodPost, entry.Method)
+		assert.Equal(t, "/api/v1/user/sessions", entry.Path)
+		assert.Equal(t, "lang=en", entry.Query)
+		assert.Equal(t, http.StatusCreated, entry.Status)
+		assert.Equal(t, "user-id", entry.UserID)
+		assert.Equal(t, "correlation-id", entry.CorrelationID)
+		assert.JSONEq(t, `{
+			"email":"[REDACTED]",
+			"password":"[REDACTED]",
+			"profile":{"refreshToken":"[REDACTED]","note":"mail me at [REDACTED]","age":30}
+		}`, string(entry.RequestBody))
+		assert.JSONEq(t, `{"accessToken":"[REDACTED]","contact":"[REDACTED]"}`, string(entry.ResponseBody))
+	})
+
+	t.Run("Middleware_Should_Leave_Out_The_Bodies_Without_Debug_Bodies", func(t *testing.T) {
+		withoutBodies := logConfig
+		withoutBodies.DebugBodies = false
+
+		entry, logged := serve(t, withoutBodies, newRequest("/api/v1/user/sessions"))
+
+		assert.True(t, logged)
+		assert.Nil(t, entry.RequestBody)
+		assert.Nil(t, entry.ResponseBody)
+	})
+
+	t.Run("Middleware_Should_Leave_Out_The_Truncated_Bodies", func(t *testing.T) {
+		smallBodies := logConfig
+		smallBodies.MaxBodyBytes = 16
+
+		entry, _ := serve(t, smallBodies, newRequest("/api/v1/user/sessions"))
+
+		assert.Equal(t, `"[body of more than 16 bytes omitted]"`, string(entry.RequestBody))
+		assert.NotContains(t, string(entry.ResponseBody), "secret")
+	})
+
+	t.Run("Middleware_Should_Skip_The_Excluded_Paths", func(t *testing.T) {
+		_, logged := serve(t, logConfig, newRequest("/healthz"))
+
+		assert.False(t, logged)
+	})
+
+	t.Run("Middleware_Should_Skip_The_Paths_Matching_The_Excluded_Patterns", func(t *testing.T) {
+		_, prefixLogged := serve(t, logConfig, newRequest("/internal/jobs/status"))
+		_, patternLogged := serve(t, logConfig, newRequest("/api/v1/users/probe"))
+
+		assert.False(t, prefixLogged)
+		assert.False(t, patternLogged)
+	})
+
+	t.Run("Middleware_Should_Leave_Out_The_Bodies_Of_The_Routes_Without_Payload_Capture", func(t *testing.T) {
+		entry, logged := serve(t, logConfig, newRequest("/api/v1/payments/charges"))
+
+		assert.True(t, logged)
+		assert.Equal(t, http.StatusCreated, entry.Status)
+		assert.Nil(t, entry.RequestBody)
+		assert.Nil(t, entry.ResponseBody)
+	})
+
+	t.Run("NewRequestLogger_Should_Reject_Invalid_Excluded_Paths", func(t *testing.T) {
+		invalidPattern := logConfig
+		invalidPattern.ExcludedPaths = []string{"/api/["}
+
+		_, err := NewRequestLogger(&config.Config{RequestLog: invalidPattern})
+
+		assert.EqualError(t, err, `Invalid request log excluded path "/api/["`)
+	})
+
+	t.Run("NewRequestLogger_Should_Require_A_Maximum_Body_Size", func(t *testing.T) {
+		withoutLimit := logConfig
+		withoutLimit.MaxBodyBytes = 0
+
+		_, err := NewRequestLogger(&config.Config{RequestLog: withoutLimit})
+
+		assert.Error(t, err)
+	})
+}
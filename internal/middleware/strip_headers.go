@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// defaultStrippedHeaders reveal the software and the hosts behind the gateway
+var defaultStrippedHeaders = []string{
+	"Server",
+	"Via",
+	"X-Powered-By",
+	"X-AspNet-Version",
+	"X-AspNetMvc-Version",
+	"X-Runtime",
+	"X-Version",
+	"X-Served-By",
+	"X-Hostname",
+	"X-Pod-Name",
+	"X-Stack-Trace",
+}
+
+// defaultStrippedHeaderPrefixes cover the debug fields, the mesh headers and the forwarded gRPC metadata
+var defaultStrippedHeaderPrefixes = []string{
+	"X-Debug-",
+	"X-Internal-",
+	"X-Backend-",
+	"X-Upstream-",
+	"X-Envoy-",
+	"X-Amzn-",
+	"Grpc-",
+	"X-Grpc-",
+}
+
+// HeaderStripper removes the headers leaking backend internals from every response
+type HeaderStripper struct {
+	names        map[string]bool
+	prefixes     []string
+	allowed      map[string]bool
+	hostSuffixes []string
+}
+
+// NewHeaderStripper creates the stripper of the default headers and the configured ones, except the allowed ones
+func NewHeaderStripper(configurations *config.Config) *HeaderStripper {
+	headersConfig := configurations.ResponseHeaders
+	stripper := &HeaderStripper{
+		names:   map[string]bool{},
+		allowed: map[string]bool{},
+	}
+	for _, name := range append(defaultStrippedHeaders, headersConfig.Strip...) {
+		stripper.names[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, prefix := range append(defaultStrippedHeaderPrefixes, headersConfig.StripPrefixes...) {
+		stripper.prefixes = append(stripper.prefixes, strings.ToLower(prefix))
+	}
+	for _, name := range headersConfig.Allow {
+		stripper.allowed[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, suffix := range headersConfig.InternalHostSuffixes {
+		stripper.hostSuffixes = append(stripper.hostSuffixes, strings.ToLower(suffix))
+	}
+	return stripper
+}
+
+// Middleware strips the response headers right before they are sent
+func (stripper *HeaderStripper) Middleware(ctx *gin.Context) {
+	writer := &strippingWriter{ResponseWriter: ctx.Writer, stripper: stripper}
+	ctx.Writer = writer
+	ctx.Next()
+	// Responses without a body are written by gin after the handlers, bypassing the wrapper
+	writer.strip()
+}
+
+// Strip removes the leaking headers
+func (stripper *HeaderStripper) Strip(header http.Header) {
+	for name, values := range header {
+		if stripper.allowed[name] {
+			continue
+		}
+		if stripper.leaks(name, values) {
+			header.Del(name)
+		}
+	}
+}
+
+func (stripper *HeaderStripper) leaks(name string, values []string) bool {
+	if stripper.names[name] {
+		return true
+	}
+	lowerName := strings.ToLower(name)
+	for _, prefix := range stripper.prefixes {
+		if strings.HasPrefix(lowerName, prefix) {
+			return true
+		}
+	}
+	for _, value := range values {
+		lowerValue := strings.ToLower(value)
+		for _, suffix := range stripper.hostSuffixes {
+			if strings.Contains(lowerValue, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// strippingWriter strips the headers once, before the status line is written
+type strippingWriter struct {
+	gin.ResponseWriter
+	stripper *HeaderStripper
+	stripped bool
+}
+
+func (writer *strippingWriter) strip() {
+	if writer.stripped || writer.ResponseWriter.Written() {
+		return
+	}
+	writer.stripped = true
+	writer.stripper.Strip(writer.ResponseWriter.Header())
+}
+
+func (writer *strippingWriter) WriteHeaderNow() {
+	writer.strip()
+	writer.ResponseWriter.WriteHeaderNow()
+}
+
+func (writer *strippingWriter) Write(data []byte) (int, error) {
+	writer.strip()
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *strippingWriter) WriteString(data string) (int, error) {
+	writer.strip()
+	return writer.ResponseWriter.WriteString(data)
+}
+
+func (writer *strippingWriter) Flush() {
+	writer.strip()
+	writer.ResponseWriter.Flush()
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func serveWithStripper(headersConfig config.ResponseHeadersConfig, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewHeaderStripper(&config.Config{ResponseHeaders: headersConfig}).Middleware)
+	router.GET("/resource", handler)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/resource", nil))
+	return recorder
+}
+
+func TestHeaderStripper(t *testing.T) {
+	t.Run("Middleware_Should_Strip_Leaking_Headers", func(t *testing.T) {
+		recorder := serveWithStripper(config.ResponseHeadersConfig{
+			Strip:                []string{"x-build"},
+			InternalHostSuffixes: []string{".svc.cluster.local"},
+		}, func(ctx *gin.Context) {
+			ctx.Header("Server", "envoy")
+			ctx.Header("X-Powered-By", "Go")
+			ctx.Header("X-Debug-Query", "select 1")
+			ctx.Header("Grpc-Status", "0")
+			ctx.Header("X-Build", "1.2.3")
+			ctx.Header("X-Location", "users.default.svc.cluster.local:9090")
+			ctx.Header("X-Cache", "HIT")
+			ctx.JSON(http.StatusOK, gin.H{})
+		})
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		for _, name := range []string{"Server", "X-Powered-By", "X-Debug-Query", "Grpc-Status", "X-Build", "X-Location"} {
+			assert.Empty(t, recorder.Header().Get(name), name)
+		}
+		assert.Equal(t, "HIT", recorder.Header().Get("X-Cache"))
+		assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+	})
+
+	t.Run("Middleware_Should_Keep_Allowed_Headers", func(t *testing.T) {
+		recorder := serveWithStripper(config.ResponseHeadersConfig{
+			Allow: []string{"x-envoy-upstream-service-time"},
+		}, func(ctx *gin.Context) {
+			ctx.Header("X-Envoy-Upstream-Service-Time", "12")
+			ctx.Header("X-Envoy-Decorator-Operation", "users")
+			ctx.String(http.StatusOK, "ok")
+		})
+
+		assert.Equal(t, "12", recorder.Header().Get("X-Envoy-Upstream-Service-Time"))
+		assert.Empty(t, recorder.Header().Get("X-Envoy-Decorator-Operation"))
+	})
+
+	t.Run("Middleware_Should_Strip_Responses_Without_Body", func(t *testing.T) {
+		recorder := serveWithStripper(config.ResponseHeadersConfig{}, func(ctx *gin.Context) {
+			ctx.Header("X-Internal-Node", "node-3")
+			ctx.Status(http.StatusNoContent)
+		})
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("X-Internal-Node"))
+	})
+}
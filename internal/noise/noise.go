@@ -0,0 +1,111 @@
+package noise
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// defaultIconPaths are requested by browsers and devices on their own, they are answered without content
+var defaultIconPaths = []string{
+	"/favicon.ico",
+	"/apple-touch-icon.png",
+	"/apple-touch-icon-precomposed.png",
+	"/browserconfig.xml",
+}
+
+// defaultScanSegments are the path segments probed by vulnerability scanners
+var defaultScanSegments = []string{
+	".env",
+	".git",
+	".aws",
+	".ssh",
+	".DS_Store",
+	"wp-admin",
+	"wp-login.php",
+	"wp-content",
+	"xmlrpc.php",
+	"phpmyadmin",
+	"cgi-bin",
+}
+
+// defaultScanSuffixes are the file extensions probed by vulnerability scanners
+var defaultScanSuffixes = []string{
+	".php",
+	".asp",
+	".aspx",
+	".jsp",
+	".cgi",
+}
+
+// Filter answers the noise requests before the router, so they reach neither the middlewares nor the metrics
+type Filter struct {
+	next         http.Handler
+	iconPaths    map[string]bool
+	scanSegments []string
+	scanSuffixes []string
+}
+
+// NewFilter wraps the handler with the filter of the default and configured noise requests,
+// the handler is returned as is when the filter is disabled
+func NewFilter(next http.Handler, configurations *config.Config) http.Handler {
+	noiseConfig := configurations.Noise
+	if !noiseConfig.Enabled {
+		return next
+	}
+	filter := &Filter{next: next, iconPaths: map[string]bool{}}
+	for _, path := range append(defaultIconPaths, noiseConfig.IconPaths...) {
+		filter.iconPaths[strings.ToLower(path)] = true
+	}
+	filter.scanSegments = lowerCase(append(defaultScanSegments, noiseConfig.ScanSegments...))
+	filter.scanSuffixes = lowerCase(append(defaultScanSuffixes, noiseConfig.ScanSuffixes...))
+	return filter
+}
+
+// ServeHTTP answers the icon requests with no content, the scans with not found and passes the rest on
+func (filter *Filter) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	path := strings.ToLower(request.URL.Path)
+	switch {
+	case filter.isIcon(path):
+		writer.Header().Set("Cache-Control", "public, max-age=604800")
+		writer.WriteHeader(http.StatusNoContent)
+	case filter.isScan(path):
+		writer.Header().Set("Cache-Control", "no-store")
+		writer.WriteHeader(http.StatusNotFound)
+	default:
+		filter.next.ServeHTTP(writer, request)
+	}
+}
+
+func (filter *Filter) isIcon(path string) bool {
+	if filter.iconPaths[path] {
+		return true
+	}
+	// Devices also request the sized variants, such as apple-touch-icon-120x120.png
+	return strings.HasPrefix(path, "/apple-touch-icon") && strings.HasSuffix(path, ".png")
+}
+
+func (filter *Filter) isScan(path string) bool {
+	for _, suffix := range filter.scanSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	for _, segment := range strings.Split(path, "/") {
+		for _, scanSegment := range filter.scanSegments {
+			if segment == scanSegment || strings.HasPrefix(segment, scanSegment+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lowerCase(values []string) []string {
+	lowerValues := make([]string, 0, len(values))
+	for _, value := range values {
+		lowerValues = append(lowerValues, strings.ToLower(value))
+	}
+	return lowerValues
+}
@@ -0,0 +1,64 @@
+package noise
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func TestFilter(t *testing.T) {
+	reached := 0
+	next := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		reached++
+		writer.WriteHeader(http.StatusOK)
+	})
+	serve := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	t.Run("ServeHTTP_Should_Answer_Icons_Without_Content", func(t *testing.T) {
+		reached = 0
+		filter := NewFilter(next, &config.Config{Noise: config.NoiseConfig{Enabled: true}})
+
+		for _, path := range []string{"/favicon.ico", "/apple-touch-icon.png", "/apple-touch-icon-120x120-precomposed.png"} {
+			recorder := serve(filter, path)
+			assert.Equal(t, http.StatusNoContent, recorder.Code, path)
+			assert.Equal(t, "public, max-age=604800", recorder.Header().Get("Cache-Control"))
+		}
+		assert.Equal(t, 0, reached)
+	})
+
+	t.Run("ServeHTTP_Should_Answer_Scans_With_Not_Found", func(t *testing.T) {
+		reached = 0
+		filter := NewFilter(next, &config.Config{Noise: config.NoiseConfig{Enabled: true, ScanSegments: []string{"actuator"}}})
+
+		for _, path := range []string{"/.env", "/api/.env.production", "/.git/config", "/wp-login.php", "/index.PHP", "/actuator/health"} {
+			assert.Equal(t, http.StatusNotFound, serve(filter, path).Code, path)
+		}
+		assert.Equal(t, 0, reached)
+	})
+
+	t.Run("ServeHTTP_Should_Pass_Other_Requests_On", func(t *testing.T) {
+		reached = 0
+		filter := NewFilter(next, &config.Config{Noise: config.NoiseConfig{Enabled: true}})
+
+		for _, path := range []string{"/api/users", "/.well-known/security.txt", "/api/environment"} {
+			assert.Equal(t, http.StatusOK, serve(filter, path).Code, path)
+		}
+		assert.Equal(t, 3, reached)
+	})
+
+	t.Run("NewFilter_Should_Return_The_Handler_When_Disabled", func(t *testing.T) {
+		reached = 0
+		filter := NewFilter(next, &config.Config{})
+
+		assert.Equal(t, http.StatusOK, serve(filter, "/favicon.ico").Code)
+		assert.Equal(t, 1, reached)
+	})
+}
@@ -0,0 +1,99 @@
+package notification
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/notification/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_notification"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// ServiceClienter is an interface for the notification service client
+type ServiceClienter interface {
+	ListNotifications(ctx *gin.Context)
+	MarkNotificationRead(ctx *gin.Context)
+	MarkAllNotificationsRead(ctx *gin.Context)
+	RegisterPushToken(ctx *gin.Context)
+	UnregisterPushToken(ctx *gin.Context)
+}
+
+// ServiceClient is a struct for the notification service client
+type ServiceClient struct {
+	client pb_notification.NotificationServiceClient
+}
+
+var _ ServiceClienter = &ServiceClient{}
+
+// InitServiceClient initializes the notification service client
+func InitServiceClient(centralConfig *commonConfig.Config, configurations *config.Config) (pb_notification.NotificationServiceClient, error) {
+	grpcServiceAddress := fmt.Sprintf(
+		"%s:%s",
+		configurations.NotificationService.Host,
+		configurations.NotificationService.Port,
+	)
+
+	fmt.Println("Connecting to notification service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection("notification", grpcServiceAddress, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc notification service: %v", err)
+	}
+
+	regionalConnection, err := region.RouteConnection("notification", clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConnection, err := tenancy.RouteConnection("notification", regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	routedConnection, err := versioning.RouteConnection("notification", tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tracedConnection := tracing.TraceConnection("notification", routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection("notification", tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection("notification", instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection("notification", retryingConnection, configurations)
+	hookedConnection := hooks.HookConnection("notification", breakerConnection, configurations)
+
+	return pb_notification.NewNotificationServiceClient(hookedConnection), nil
+}
+
+// ListNotifications redirects request to the list notifications route
+func (service *ServiceClient) ListNotifications(ctx *gin.Context) {
+	routes.ListNotifications(ctx, service.client)
+}
+
+// MarkNotificationRead redirects request to the mark notification read route
+func (service *ServiceClient) MarkNotificationRead(ctx *gin.Context) {
+	routes.MarkNotificationRead(ctx, service.client)
+}
+
+// MarkAllNotificationsRead redirects request to the mark all notifications read route
+func (service *ServiceClient) MarkAllNotificationsRead(ctx *gin.Context) {
+	routes.MarkAllNotificationsRead(ctx, service.client)
+}
+
+// RegisterPushToken redirects request to the register push token route
+func (service *ServiceClient) RegisterPushToken(ctx *gin.Context) {
+	routes.RegisterPushToken(ctx, service.client)
+}
+
+// UnregisterPushToken redirects request to the unregister push token route
+func (service *ServiceClient) UnregisterPushToken(ctx *gin.Context) {
+	routes.UnregisterPushToken(ctx, service.client)
+}
@@ -0,0 +1,37 @@
+package notification
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// RegisterRoutes registers the notification routes
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*ServiceClient, error) {
+	client, err := InitServiceClient(centralConfig, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not initialize notification service client: %v", err)
+	}
+	service := &ServiceClient{
+		client: client,
+	}
+
+	notificationRoutes := api.Group("/notifications")
+	notificationRoutes.Use(authenticationMiddleware.RequireAuthentication)
+	notificationRoutes.GET("", service.ListNotifications)
+	notificationRoutes.POST("/read", service.MarkAllNotificationsRead)
+	notificationRoutes.POST("/:notificationID/read", service.MarkNotificationRead)
+	notificationRoutes.POST("/push-tokens", service.RegisterPushToken)
+	notificationRoutes.DELETE("/push-tokens/:token", service.UnregisterPushToken)
+
+	return service, nil
+}
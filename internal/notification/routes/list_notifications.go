@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_notification"
+)
+
+const maxPageSize = 100
+
+// ListNotifications lists the notifications of the authenticated user
+func ListNotifications(ctx *gin.Context, client pb_notification.NotificationServiceClient) {
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize <= 0 || pageSize > maxPageSize {
+		errors.Abort(ctx, errors.InvalidArgument, errors.FieldViolation("pageSize", "pageSize must be a number between 1 and 100"))
+		return
+	}
+
+	res, err := client.ListNotifications(
+		ctx.Request.Context(),
+		&pb_notification.ListNotificationsRequest{
+			Cursor:     ctx.Query("cursor"),
+			PageSize:   int32(pageSize),
+			UnreadOnly: ctx.Query("unreadOnly") == "true",
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
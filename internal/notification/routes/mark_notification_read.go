@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_notification"
+)
+
+// MarkNotificationRead marks a notification of the authenticated user as read
+func MarkNotificationRead(ctx *gin.Context, client pb_notification.NotificationServiceClient) {
+	res, err := client.MarkNotificationRead(
+		ctx.Request.Context(),
+		&pb_notification.MarkNotificationReadRequest{
+			NotificationID: ctx.Param("notificationID"),
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// MarkAllNotificationsRead marks every notification of the authenticated user as read
+func MarkAllNotificationsRead(ctx *gin.Context, client pb_notification.NotificationServiceClient) {
+	res, err := client.MarkAllNotificationsRead(
+		ctx.Request.Context(),
+		&pb_notification.MarkAllNotificationsReadRequest{},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
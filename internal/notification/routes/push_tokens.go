@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_notification"
+)
+
+// RegisterPushTokenRequestBody is the request body for the RegisterPushToken route
+type RegisterPushTokenRequestBody struct {
+	Token    string `json:"token" binding:"required"`
+	Platform string `json:"platform" binding:"required,oneof=ios android web"`
+	DeviceID string `json:"deviceID"`
+}
+
+// RegisterPushToken registers a device push token of the authenticated user
+func RegisterPushToken(ctx *gin.Context, client pb_notification.NotificationServiceClient) {
+	body := RegisterPushTokenRequestBody{}
+
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+		return
+	}
+
+	res, err := client.RegisterPushToken(
+		ctx.Request.Context(),
+		&pb_notification.RegisterPushTokenRequest{
+			Token:    body.Token,
+			Platform: body.Platform,
+			DeviceID: body.DeviceID,
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// UnregisterPushToken removes a device push token of the authenticated user
+func UnregisterPushToken(ctx *gin.Context, client pb_notification.NotificationServiceClient) {
+	res, err := client.UnregisterPushToken(
+		ctx.Request.Context(),
+		&pb_notification.UnregisterPushTokenRequest{
+			Token: ctx.Param("token"),
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
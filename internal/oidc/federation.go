@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+const defaultRefreshCooldown = time.Minute
+
+// Federation verifies the tokens of the external identity providers, such as Google or Auth0, each token with the
+// provider of its issuer, so the social login tokens are accepted next to the tokens of the authentication service
+type Federation struct {
+	providers       map[string]*Provider
+	refreshInterval time.Duration
+}
+
+// NewFederation creates the providers of the configuration, their keys are fetched by the first refresh or by the
+// first token they issued
+func NewFederation(configurations *config.Config, httpClient *http.Client) (*Federation, error) {
+	oidcConfig := configurations.OIDC
+	if len(oidcConfig.Providers) == 0 {
+		return nil, fmt.Errorf("The OIDC federation needs at least one provider")
+	}
+	cooldown := oidcConfig.RefreshCooldown
+	if cooldown <= 0 {
+		cooldown = defaultRefreshCooldown
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	federation := &Federation{
+		providers:       map[string]*Provider{},
+		refreshInterval: oidcConfig.RefreshInterval,
+	}
+	for _, providerConfig := range oidcConfig.Providers {
+		if _, exists := federation.providers[providerConfig.Issuer]; exists {
+			return nil, fmt.Errorf("The issuer %s is configured for several OIDC providers", providerConfig.Issuer)
+		}
+		provider, err := newProvider(providerConfig, cooldown, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		federation.providers[providerConfig.Issuer] = provider
+	}
+	return federation, nil
+}
+
+// Issuer reads the iss claim of the token without verifying it, empty when the token has none
+func Issuer(tokenString string) string {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	issuer, _ := claims[IssuerClaim].(string)
+	return issuer
+}
+
+// Trusts tells whether the tokens of the issuer are verified by one of the providers
+func (federation *Federation) Trusts(issuer string) bool {
+	_, exists := federation.providers[issuer]
+	return exists
+}
+
+// Verify verifies the token with the provider of its issuer
+func (federation *Federation) Verify(tokenString string) (*jwt.Token, error) {
+	issuer := Issuer(tokenString)
+	provider, exists := federation.providers[issuer]
+	if !exists {
+		return nil, fmt.Errorf("The issuer %s is not trusted", issuer)
+	}
+	return provider.Verify(tokenString)
+}
+
+// Refresh fetches the keys of every provider, a provider failing does not keep the others from refreshing
+func (federation *Federation) Refresh(ctx context.Context) error {
+	errs := []error{}
+	for _, provider := range federation.providers {
+		if err := provider.Refresh(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Providers returns the number of providers of the federation
+func (federation *Federation) Providers() int {
+	return len(federation.providers)
+}
+
+// Job refreshes the keys of the providers on every refresh interval
+func (federation *Federation) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "oidc_keys_refresh",
+		Schedule: scheduler.Every(federation.refreshInterval),
+		Run: func(ctx context.Context, now time.Time) error {
+			return federation.Refresh(ctx)
+		},
+	}
+}
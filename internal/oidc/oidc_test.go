@@ -0,0 +1,238 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// testProvider is an identity provider serving its openid-configuration and the JWKS of its keys, the rsa-2 key
+// once it is rotated
+type testProvider struct {
+	server     *httptest.Server
+	rsaKeys    map[string]*rsa.PrivateKey
+	ecKey      *ecdsa.PrivateKey
+	rotated    atomic.Bool
+	jwksServed atomic.Int32
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	provider := &testProvider{rsaKeys: map[string]*rsa.PrivateKey{"rsa-1": rsaKey, "rsa-2": rotatedKey}, ecKey: ecKey}
+	provider.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case DiscoveryPath:
+			json.NewEncoder(writer).Encode(map[string]string{
+				"issuer":   provider.server.URL,
+				"jwks_uri": provider.server.URL + "/keys",
+			})
+		case "/keys":
+			provider.jwksServed.Add(1)
+			encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+			keys := []map[string]string{{
+				"kty": "EC", "kid": "ec-1", "use": "sig", "crv": "P-256",
+				"x": encode(provider.ecKey.X), "y": encode(provider.ecKey.Y),
+			}, {"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}}
+			for keyID, key := range provider.rsaKeys {
+				if keyID == "rsa-2" && !provider.rotated.Load() {
+					continue
+				}
+				keys = append(keys, map[string]string{
+					"kty": "RSA", "kid": keyID, "use": "sig", "n": encode(key.N), "e": encode(big.NewInt(int64(key.E))),
+				})
+			}
+			json.NewEncoder(writer).Encode(map[string]interface{}{"keys": keys})
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+func (provider *testProvider) sign(t *testing.T, method jwt.SigningMethod, keyID string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = keyID
+	var key interface{} = provider.rsaKeys[keyID]
+	if keyID == "ec-1" {
+		key = provider.ecKey
+	}
+	tokenString, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return tokenString
+}
+
+func (provider *testProvider) claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   provider.server.URL,
+		"aud":   "app-client-id",
+		"sub":   "google-user",
+		"email": "user@gmail.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+	}
+}
+
+func newTestFederation(t *testing.T, provider *testProvider) *Federation {
+	federation, err := NewFederation(&config.Config{OIDC: config.OIDCConfig{
+		Enabled:         true,
+		RefreshCooldown: time.Hour,
+		Providers: []config.OIDCProviderConfig{{
+			Name: "google", Issuer: provider.server.URL, Audiences: []string{"app-client-id"},
+		}},
+	}}, provider.server.Client())
+	assert.NoError(t, err)
+	return federation
+}
+
+func TestFederation(t *testing.T) {
+	t.Run("Verify_Should_Map_The_Claims_Of_The_Provider_Tokens", func(t *testing.T) {
+		provider := newTestProvider(t)
+		federation := newTestFederation(t, provider)
+		claims := provider.claims()
+		claims["type"] = "refresh"
+
+		token, err := federation.Verify(provider.sign(t, jwt.SigningMethodRS256, "rsa-1", claims))
+
+		assert.NoError(t, err)
+		tokenClaims, err := (&commonJWT.TokenInspector{}).GetClaimsFromToken(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "google:google-user", tokenClaims.UserID)
+		assert.Equal(t, "user@gmail.com", tokenClaims.Email)
+		assert.Equal(t, commonToken.AuthTokenType, tokenClaims.Type)
+		assert.Equal(t, int32(1), provider.jwksServed.Load())
+	})
+
+	t.Run("Verify_Should_Accept_The_EC_Keys_And_The_Tokens_Without_Email", func(t *testing.T) {
+		provider := newTestProvider(t)
+		federation := newTestFederation(t, provider)
+		claims := provider.claims()
+		delete(claims, "email")
+		claims["aud"] = []string{"other-client-id", "app-client-id"}
+
+		token, err := federation.Verify(provider.sign(t, jwt.SigningMethodES256, "ec-1", claims))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "", token.Claims.(jwt.MapClaims)[commonJWT.EmailClaim])
+	})
+
+	t.Run("Verify_Should_Reject_The_Invalid_Tokens", func(t *testing.T) {
+		provider := newTestProvider(t)
+		federation := newTestFederation(t, provider)
+
+		otherAudience := provider.claims()
+		otherAudience["aud"] = "other-client-id"
+		_, err := federation.Verify(provider.sign(t, jwt.SigningMethodRS256, "rsa-1", otherAudience))
+		assert.ErrorContains(t, err, "not issued for an audience")
+
+		expired := provider.claims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		_, err = federation.Verify(provider.sign(t, jwt.SigningMethodRS256, "rsa-1", expired))
+		assert.ErrorContains(t, err, "expired")
+
+		withoutSubject := provider.claims()
+		delete(withoutSubject, "sub")
+		_, err = federation.Verify(provider.sign(t, jwt.SigningMethodRS256, "rsa-1", withoutSubject))
+		assert.ErrorContains(t, err, "has no sub claim")
+
+		hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, provider.claims()).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		_, err = federation.Verify(hmacToken)
+		assert.ErrorContains(t, err, "Unexpected signing method")
+
+		otherIssuer := provider.claims()
+		otherIssuer["iss"] = "https://accounts.example.com"
+		_, err = federation.Verify(provider.sign(t, jwt.SigningMethodRS256, "rsa-1", otherIssuer))
+		assert.EqualError(t, err, "The issuer https://accounts.example.com is not trusted")
+	})
+
+	t.Run("Verify_Should_Refresh_The_Keys_Once_Per_Cooldown_On_Unknown_Keys", func(t *testing.T) {
+		provider := newTestProvider(t)
+		federation := newTestFederation(t, provider)
+		assert.NoError(t, federation.Refresh(context.Background()))
+		provider.rotated.Store(true)
+
+		_, err := federation.Verify(provider.sign(t, jwt.SigningMethodRS256, "rsa-2", provider.claims()))
+
+		assert.ErrorContains(t, err, "has no key rsa-2")
+		assert.Equal(t, int32(1), provider.jwksServed.Load())
+
+		federation.providers[provider.server.URL].cooldown = 0
+		_, err = federation.Verify(provider.sign(t, jwt.SigningMethodRS256, "rsa-2", provider.claims()))
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), provider.jwksServed.Load())
+	})
+
+	t.Run("Verify_Should_Only_Keep_The_Identity_And_Mapped_Claims", func(t *testing.T) {
+		provider := newTestProvider(t)
+		federation, err := NewFederation(&config.Config{OIDC: config.OIDCConfig{Providers: []config.OIDCProviderConfig{{
+			Name:      "auth0",
+			Issuer:    provider.server.URL,
+			Audiences: []string{"app-client-id"},
+			Claims:    map[string]string{"https://example.com/roles": "roles"},
+		}}}}, provider.server.Client())
+		assert.NoError(t, err)
+		claims := provider.claims()
+		claims["roles"] = []string{"admin"}
+		claims["tenant_id"] = "acme"
+		claims["user_id"] = "internal-user"
+		claims["https://example.com/roles"] = []string{"support"}
+
+		token, err := federation.Verify(provider.sign(t, jwt.SigningMethodRS256, "rsa-1", claims))
+
+		assert.NoError(t, err)
+		verifiedClaims := token.Claims.(jwt.MapClaims)
+		assert.Equal(t, []interface{}{"support"}, verifiedClaims["roles"])
+		assert.Equal(t, "auth0:google-user", verifiedClaims[commonJWT.UserIDClaim])
+		assert.NotContains(t, verifiedClaims, "tenant_id")
+		assert.NotContains(t, verifiedClaims, "https://example.com/roles")
+		assert.Equal(t, "app-client-id", verifiedClaims[AudienceClaim])
+	})
+
+	t.Run("NewFederation_Should_Reject_The_Claims_Mapped_To_Reserved_Claims", func(t *testing.T) {
+		_, err := NewFederation(&config.Config{OIDC: config.OIDCConfig{Providers: []config.OIDCProviderConfig{{
+			Name: "auth0", Issuer: "https://example.eu.auth0.com/", Audiences: []string{"app"},
+			Claims: map[string]string{"https://example.com/id": "user_id"},
+		}}}}, nil)
+
+		assert.EqualError(t, err, "The claim https://example.com/id of the OIDC provider auth0 can not be mapped to the claim user_id")
+	})
+
+	t.Run("NewFederation_Should_Require_The_Audiences", func(t *testing.T) {
+		_, err := NewFederation(&config.Config{OIDC: config.OIDCConfig{Providers: []config.OIDCProviderConfig{{
+			Name: "auth0", Issuer: "https://example.eu.auth0.com/",
+		}}}}, nil)
+
+		assert.EqualError(t, err, "The OIDC provider auth0 needs the audiences of its tokens")
+	})
+
+	t.Run("Issuer_Should_Read_The_Unverified_Issuer", func(t *testing.T) {
+		provider := newTestProvider(t)
+
+		assert.Equal(t, provider.server.URL, Issuer(provider.sign(t, jwt.SigningMethodRS256, "rsa-1", provider.claims())))
+		assert.Empty(t, Issuer("not-a-token"))
+	})
+}
@@ -0,0 +1,378 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonToken "github.com/quadev-ltd/qd-common/pkg/token"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// DiscoveryPath is the path of the openid-configuration under the issuer
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// Claims of the tokens of the identity providers
+const (
+	IssuerClaim        = "iss"
+	AudienceClaim      = "aud"
+	SubjectClaim       = "sub"
+	NotBeforeClaim     = "nbf"
+	TokenIDClaim       = "jti"
+	EmailVerifiedClaim = "email_verified"
+	AuthTimeClaim      = "auth_time"
+)
+
+// UserIDSeparator separates the name of the provider from the subject in the user ID of its tokens, so a subject
+// can not stand for a user of the authentication service
+const UserIDSeparator = ":"
+
+// identityClaims are the claims of the provider tokens kept as they are, the others are dropped unless they are
+// mapped so a provider can not grant the roles, tenants or entitlements of the gateway
+var identityClaims = []string{
+	IssuerClaim,
+	AudienceClaim,
+	SubjectClaim,
+	commonJWT.ExpiryClaim,
+	commonJWT.IssuedAtClaim,
+	NotBeforeClaim,
+	TokenIDClaim,
+	commonJWT.EmailClaim,
+	EmailVerifiedClaim,
+	AuthTimeClaim,
+}
+
+// keyRefreshTimeout bounds the refreshes of the keys triggered by a token signed by an unknown key
+const keyRefreshTimeout = 10 * time.Second
+
+// discoveryDocument is the part of the openid-configuration of an issuer the gateway reads
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// jsonWebKey is a key of a JWKS, only the RSA and EC signing keys are used
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// Provider verifies the tokens of an identity provider with the keys of its JWKS, cached by key ID
+type Provider struct {
+	name          string
+	issuer        string
+	audiences     map[string]bool
+	configuredURL string
+	userIDClaim   string
+	claims        map[string]string
+	cooldown      time.Duration
+	httpClient    *http.Client
+	jwksURL       string
+	keys          map[string]crypto.PublicKey
+	refreshedAt   time.Time
+	attemptedAt   time.Time
+	keysMtx       sync.RWMutex
+	refreshMtx    sync.Mutex
+}
+
+// newProvider creates the provider of the configuration, its keys are fetched on the first refresh
+func newProvider(providerConfig config.OIDCProviderConfig, cooldown time.Duration, httpClient *http.Client) (*Provider, error) {
+	if providerConfig.Issuer == "" {
+		return nil, fmt.Errorf("The OIDC provider %s needs an issuer", providerConfig.Name)
+	}
+	if len(providerConfig.Audiences) == 0 {
+		return nil, fmt.Errorf("The OIDC provider %s needs the audiences of its tokens", providerConfig.Name)
+	}
+	provider := &Provider{
+		name:          providerConfig.Name,
+		issuer:        providerConfig.Issuer,
+		audiences:     map[string]bool{},
+		configuredURL: providerConfig.JWKSURL,
+		userIDClaim:   providerConfig.UserIDClaim,
+		claims:        providerConfig.Claims,
+		cooldown:      cooldown,
+		httpClient:    httpClient,
+		keys:          map[string]crypto.PublicKey{},
+	}
+	if provider.name == "" {
+		provider.name = provider.issuer
+	}
+	if provider.userIDClaim == "" {
+		provider.userIDClaim = SubjectClaim
+	}
+	for _, audience := range providerConfig.Audiences {
+		provider.audiences[audience] = true
+	}
+	for claim, gatewayClaim := range provider.claims {
+		if isReservedClaim(gatewayClaim) {
+			return nil, fmt.Errorf("The claim %s of the OIDC provider %s can not be mapped to the claim %s", claim, provider.name, gatewayClaim)
+		}
+	}
+	return provider, nil
+}
+
+// Name is the name of the provider
+func (provider *Provider) Name() string {
+	return provider.name
+}
+
+// Refresh fetches the JWKS of the provider again, discovering its URL from the issuer on the first refresh
+// unless it is configured, and replaces the cached keys
+func (provider *Provider) Refresh(ctx context.Context) error {
+	provider.keysMtx.Lock()
+	provider.attemptedAt = time.Now()
+	jwksURL := provider.jwksURL
+	provider.keysMtx.Unlock()
+	if jwksURL == "" {
+		jwksURL = provider.configuredURL
+	}
+	if jwksURL == "" {
+		document := discoveryDocument{}
+		if err := provider.getJSON(ctx, strings.TrimSuffix(provider.issuer, "/")+DiscoveryPath, &document); err != nil {
+			return fmt.Errorf("Could not discover the OIDC provider %s: %v", provider.name, err)
+		}
+		if document.Issuer != provider.issuer {
+			return fmt.Errorf("The OIDC provider %s announced the issuer %s", provider.name, document.Issuer)
+		}
+		if document.JWKSURI == "" {
+			return fmt.Errorf("The OIDC provider %s announced no JWKS", provider.name)
+		}
+		jwksURL = document.JWKSURI
+	}
+
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := provider.getJSON(ctx, jwksURL, &jwks); err != nil {
+		return fmt.Errorf("Could not fetch the keys of the OIDC provider %s: %v", provider.name, err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[key.KeyID] = publicKey
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("The OIDC provider %s has no RSA or EC signing key", provider.name)
+	}
+
+	provider.keysMtx.Lock()
+	defer provider.keysMtx.Unlock()
+	provider.jwksURL = jwksURL
+	provider.keys = keys
+	provider.refreshedAt = time.Now()
+	return nil
+}
+
+func (provider *Provider) getJSON(ctx context.Context, url string, value interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := provider.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %d", url, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(value)
+}
+
+// key returns the key of the key ID, refreshing the keys when it is unknown at most once per cooldown so the tokens
+// signed after a key rotation are accepted while forged key IDs can not flood the provider
+func (provider *Provider) key(keyID string) (crypto.PublicKey, error) {
+	provider.keysMtx.RLock()
+	publicKey, exists := provider.keys[keyID]
+	provider.keysMtx.RUnlock()
+	if exists {
+		return publicKey, nil
+	}
+
+	provider.refreshMtx.Lock()
+	defer provider.refreshMtx.Unlock()
+	provider.keysMtx.RLock()
+	publicKey, exists = provider.keys[keyID]
+	attemptedAt := provider.attemptedAt
+	provider.keysMtx.RUnlock()
+	if exists {
+		return publicKey, nil
+	}
+	if !attemptedAt.IsZero() && time.Since(attemptedAt) < provider.cooldown {
+		return nil, fmt.Errorf("The OIDC provider %s has no key %s", provider.name, keyID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keyRefreshTimeout)
+	defer cancel()
+	if err := provider.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	provider.keysMtx.RLock()
+	defer provider.keysMtx.RUnlock()
+	if publicKey, exists = provider.keys[keyID]; !exists {
+		return nil, fmt.Errorf("The OIDC provider %s has no key %s", provider.name, keyID)
+	}
+	return publicKey, nil
+}
+
+// Verify verifies the signature, the issuer, the audience and the validity window of the token, and returns it
+// with its identity claims, the mapped claims and the claims the authentication middleware reads: the user ID
+// prefixed with the name of the provider, the email, empty when the provider has none, and the access token type
+func (provider *Provider) Verify(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		keyID, _ := token.Header["kid"].(string)
+		return provider.key(keyID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("The token of the OIDC provider %s is not valid", provider.name)
+	}
+	if issuer, _ := claims[IssuerClaim].(string); issuer != provider.issuer {
+		return nil, fmt.Errorf("The token was not issued by the OIDC provider %s", provider.name)
+	}
+	if !provider.hasAudience(claims) {
+		return nil, fmt.Errorf("The token was not issued for an audience of the OIDC provider %s", provider.name)
+	}
+	if _, ok := claims[commonJWT.ExpiryClaim].(float64); !ok {
+		return nil, fmt.Errorf("The token of the OIDC provider %s has no expiry", provider.name)
+	}
+	userID, _ := claims[provider.userIDClaim].(string)
+	if userID == "" {
+		return nil, fmt.Errorf("The token of the OIDC provider %s has no %s claim", provider.name, provider.userIDClaim)
+	}
+	mappedClaims := jwt.MapClaims{}
+	for _, claim := range identityClaims {
+		if value, exists := claims[claim]; exists {
+			mappedClaims[claim] = value
+		}
+	}
+	for claim, gatewayClaim := range provider.claims {
+		if value, exists := claims[claim]; exists {
+			mappedClaims[gatewayClaim] = value
+		}
+	}
+	if _, ok := mappedClaims[commonJWT.EmailClaim].(string); !ok {
+		mappedClaims[commonJWT.EmailClaim] = ""
+	}
+	mappedClaims[commonJWT.UserIDClaim] = provider.name + UserIDSeparator + userID
+	mappedClaims[commonJWT.TypeClaim] = string(commonToken.AuthTokenType)
+	token.Claims = mappedClaims
+	return token, nil
+}
+
+// isReservedClaim tells whether the claim is read from the provider tokens or set by the gateway, so no claim of
+// the provider can be mapped to it
+func isReservedClaim(claim string) bool {
+	if claim == commonJWT.UserIDClaim || claim == commonJWT.TypeClaim {
+		return true
+	}
+	for _, identityClaim := range identityClaims {
+		if claim == identityClaim {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAudience tells whether the aud claim, a single audience or a list of them, holds an audience of the provider
+func (provider *Provider) hasAudience(claims jwt.MapClaims) bool {
+	switch value := claims[AudienceClaim].(type) {
+	case string:
+		return provider.audiences[value]
+	case []interface{}:
+		for _, audience := range value {
+			if audienceString, ok := audience.(string); ok && provider.audiences[audienceString] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// publicKey decodes the RSA or EC public key of the JWK
+func (key jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch key.KeyType {
+	case "RSA":
+		modulus, err := decodeInteger(key.N)
+		if err != nil {
+			return nil, err
+		}
+		exponent, err := decodeInteger(key.E)
+		if err != nil {
+			return nil, err
+		}
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("The exponent of the RSA key %s is too large", key.KeyID)
+		}
+		return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("Unknown curve %s of the EC key %s", key.Curve, key.KeyID)
+		}
+		x, err := decodeInteger(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInteger(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("The EC key %s is not on its curve", key.KeyID)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("Unknown type %s of the key %s", key.KeyType, key.KeyID)
+}
+
+func decodeInteger(encoded string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, fmt.Errorf("The key has an empty parameter")
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
@@ -0,0 +1,99 @@
+package payment
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/payment/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_payment"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// ServiceClienter is an interface for the payment service client
+type ServiceClienter interface {
+	CreateCharge(ctx *gin.Context)
+	GetCharge(ctx *gin.Context)
+	RefundCharge(ctx *gin.Context)
+	ListPaymentMethods(ctx *gin.Context)
+	DeletePaymentMethod(ctx *gin.Context)
+}
+
+// ServiceClient is a struct for the payment service client
+type ServiceClient struct {
+	client pb_payment.PaymentServiceClient
+}
+
+var _ ServiceClienter = &ServiceClient{}
+
+// InitServiceClient initializes the payment service client
+func InitServiceClient(centralConfig *commonConfig.Config, configurations *config.Config) (pb_payment.PaymentServiceClient, error) {
+	grpcServiceAddress := fmt.Sprintf(
+		"%s:%s",
+		configurations.PaymentService.Host,
+		configurations.PaymentService.Port,
+	)
+
+	fmt.Println("Connecting to payment service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection("payment", grpcServiceAddress, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc payment service: %v", err)
+	}
+
+	regionalConnection, err := region.RouteConnection("payment", clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConnection, err := tenancy.RouteConnection("payment", regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	routedConnection, err := versioning.RouteConnection("payment", tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tracedConnection := tracing.TraceConnection("payment", routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection("payment", tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection("payment", instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection("payment", retryingConnection, configurations)
+	hookedConnection := hooks.HookConnection("payment", breakerConnection, configurations)
+
+	return pb_payment.NewPaymentServiceClient(hookedConnection), nil
+}
+
+// CreateCharge redirects request to the create charge route
+func (service *ServiceClient) CreateCharge(ctx *gin.Context) {
+	routes.CreateCharge(ctx, service.client)
+}
+
+// GetCharge redirects request to the get charge route
+func (service *ServiceClient) GetCharge(ctx *gin.Context) {
+	routes.GetCharge(ctx, service.client)
+}
+
+// RefundCharge redirects request to the refund charge route
+func (service *ServiceClient) RefundCharge(ctx *gin.Context) {
+	routes.RefundCharge(ctx, service.client)
+}
+
+// ListPaymentMethods redirects request to the list payment methods route
+func (service *ServiceClient) ListPaymentMethods(ctx *gin.Context) {
+	routes.ListPaymentMethods(ctx, service.client)
+}
+
+// DeletePaymentMethod redirects request to the delete payment method route
+func (service *ServiceClient) DeletePaymentMethod(ctx *gin.Context) {
+	routes.DeletePaymentMethod(ctx, service.client)
+}
@@ -0,0 +1,53 @@
+package payment
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	"golang.org/x/time/rate"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+)
+
+// NoStore prevents clients and intermediaries from caching payment responses
+func NoStore(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+	ctx.Next()
+}
+
+// RegisterRoutes registers the payment routes; request and response payloads are never logged
+// and backend errors are answered without their messages
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*ServiceClient, error) {
+	client, err := InitServiceClient(centralConfig, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not initialize payment service client: %v", err)
+	}
+	service := &ServiceClient{
+		client: client,
+	}
+
+	rl := middleware.NewRateLimiter(
+		rate.Limit(configurations.PaymentService.RateLimit),
+		configurations.PaymentService.RateLimitBurst,
+	)
+	stepUp := authenticationMiddleware.RequireStepUp(configurations.PaymentService.StepUpMaxAge)
+
+	paymentRoutes := api.Group("/payments")
+	paymentRoutes.Use(middleware.NoPayloadCapture, NoStore, authenticationMiddleware.RequireAuthentication, middleware.UserRateLimitMiddleware(rl))
+	paymentRoutes.POST("/charges", stepUp, middleware.RequireIdempotencyKey, service.CreateCharge)
+	paymentRoutes.GET("/charges/:chargeID", service.GetCharge)
+	paymentRoutes.POST("/charges/:chargeID/refunds", stepUp, middleware.RequireIdempotencyKey, service.RefundCharge)
+	paymentRoutes.GET("/methods", service.ListPaymentMethods)
+	paymentRoutes.DELETE("/methods/:paymentMethodID", stepUp, service.DeletePaymentMethod)
+
+	return service, nil
+}
@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_payment"
+)
+
+// CreateChargeRequestBody is the request body for the CreateCharge route
+type CreateChargeRequestBody struct {
+	Amount          int64  `json:"amount" binding:"required,gt=0"`
+	Currency        string `json:"currency" binding:"required,len=3"`
+	PaymentMethodID string `json:"paymentMethodID" binding:"required"`
+	Description     string `json:"description" binding:"max=255"`
+}
+
+// RefundChargeRequestBody is the request body for the RefundCharge route
+type RefundChargeRequestBody struct {
+	Amount int64 `json:"amount" binding:"gte=0"`
+}
+
+// CreateCharge charges a payment method of the authenticated user
+func CreateCharge(ctx *gin.Context, client pb_payment.PaymentServiceClient) {
+	body := CreateChargeRequestBody{}
+
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		abortInvalidBody(ctx)
+		return
+	}
+
+	res, err := client.CreateCharge(
+		ctx.Request.Context(),
+		&pb_payment.CreateChargeRequest{
+			IdempotencyKey:  ctx.GetString(middleware.IdempotencyKeyMetadataKey),
+			Amount:          body.Amount,
+			Currency:        body.Currency,
+			PaymentMethodID: body.PaymentMethodID,
+			Description:     body.Description,
+		},
+	)
+
+	if err != nil {
+		errors.HandleRedactedError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, &res)
+}
+
+// GetCharge returns a charge of the authenticated user
+func GetCharge(ctx *gin.Context, client pb_payment.PaymentServiceClient) {
+	res, err := client.GetCharge(
+		ctx.Request.Context(),
+		&pb_payment.GetChargeRequest{
+			ChargeID: ctx.Param("chargeID"),
+		},
+	)
+
+	if err != nil {
+		errors.HandleRedactedError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// RefundCharge refunds a charge of the authenticated user
+func RefundCharge(ctx *gin.Context, client pb_payment.PaymentServiceClient) {
+	body := RefundChargeRequestBody{}
+
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			abortInvalidBody(ctx)
+			return
+		}
+	}
+
+	res, err := client.RefundCharge(
+		ctx.Request.Context(),
+		&pb_payment.RefundChargeRequest{
+			IdempotencyKey: ctx.GetString(middleware.IdempotencyKeyMetadataKey),
+			ChargeID:       ctx.Param("chargeID"),
+			Amount:         body.Amount,
+		},
+	)
+
+	if err != nil {
+		errors.HandleRedactedError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// abortInvalidBody rejects the request without echoing the binding error, which may quote the payload
+func abortInvalidBody(ctx *gin.Context) {
+	errors.Abort(ctx, errors.InvalidRequestBody)
+}
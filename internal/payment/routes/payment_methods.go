@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_payment"
+)
+
+// ListPaymentMethods lists the tokenized payment methods of the authenticated user
+func ListPaymentMethods(ctx *gin.Context, client pb_payment.PaymentServiceClient) {
+	res, err := client.ListPaymentMethods(
+		ctx.Request.Context(),
+		&pb_payment.ListPaymentMethodsRequest{},
+	)
+
+	if err != nil {
+		errors.HandleRedactedError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
+
+// DeletePaymentMethod removes a payment method of the authenticated user
+func DeletePaymentMethod(ctx *gin.Context, client pb_payment.PaymentServiceClient) {
+	res, err := client.DeletePaymentMethod(
+		ctx.Request.Context(),
+		&pb_payment.DeletePaymentMethodRequest{
+			PaymentMethodID: ctx.Param("paymentMethodID"),
+		},
+	)
+
+	if err != nil {
+		errors.HandleRedactedError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &res)
+}
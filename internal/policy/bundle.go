@@ -0,0 +1,237 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+)
+
+// AuthenticationRequired makes a route policy require an access token, an empty authentication leaves it to the route
+const AuthenticationRequired = "required"
+
+// The kinds and actions of the changes between two bundles
+const (
+	KindRoute         = "route"
+	KindRateLimit     = "rate_limit"
+	KindRateLimitCost = "rate_limit_cost"
+
+	ActionAdded   = "added"
+	ActionRemoved = "removed"
+	ActionChanged = "changed"
+)
+
+// Bundle is a version of the route access and rate limit policies of the gateway, applied as a whole
+type Bundle struct {
+	Version     string            `json:"version"`
+	Description string            `json:"description,omitempty"`
+	Routes      []RoutePolicy     `json:"routes"`
+	RateLimits  []RateLimitPolicy `json:"rate_limits"`
+	Costs       []RateLimitCost   `json:"rate_limit_costs,omitempty"`
+}
+
+// RoutePolicy is the access policy of the routes under the path prefix, for the methods listed or every method,
+// the most specific prefix applies
+type RoutePolicy struct {
+	PathPrefix     string   `json:"path_prefix"`
+	Methods        []string `json:"methods,omitempty"`
+	Authentication string   `json:"authentication,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	Disabled       bool     `json:"disabled,omitempty"`
+}
+
+// RateLimitPolicy is a rate limit group, see config.RateLimitGroupConfig
+type RateLimitPolicy struct {
+	Name       string   `json:"name"`
+	PathPrefix string   `json:"path_prefix"`
+	Methods    []string `json:"methods,omitempty"`
+	Key        string   `json:"key,omitempty"`
+	Tenants    []string `json:"tenants,omitempty"`
+	RateLimit  float64  `json:"rate_limit"`
+	Burst      int      `json:"burst"`
+}
+
+// RateLimitCost is the cost of a route in the rate limit buckets, see config.RateLimitCostConfig
+type RateLimitCost struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Cost   int    `json:"cost"`
+}
+
+// Change is a policy added, removed or changed from a bundle to another
+type Change struct {
+	Kind   string      `json:"kind"`
+	Key    string      `json:"key"`
+	Action string      `json:"action"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// ParseBundle decodes a JSON bundle, rejecting the unknown fields so misspelled policies are not silently dropped
+func ParseBundle(document []byte) (*Bundle, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.DisallowUnknownFields()
+	bundle := &Bundle{}
+	if err := decoder.Decode(bundle); err != nil {
+		return nil, fmt.Errorf("Could not decode the policy bundle: %v", err)
+	}
+	return bundle, nil
+}
+
+// LoadBundle reads a JSON bundle file
+func LoadBundle(file string) (*Bundle, error) {
+	document, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the policy bundle %s: %v", file, err)
+	}
+	return ParseBundle(document)
+}
+
+// bundleFromConfig builds the bundle of the rate limit groups and costs of the configuration
+func bundleFromConfig(configurations *config.Config) *Bundle {
+	bundle := &Bundle{Version: "config", Description: "Rate limit groups of the configuration"}
+	for _, cost := range configurations.RateLimits.Costs {
+		bundle.Costs = append(bundle.Costs, RateLimitCost{Method: cost.Method, Path: cost.Path, Cost: cost.Cost})
+	}
+	for _, group := range configurations.RateLimits.Groups {
+		bundle.RateLimits = append(bundle.RateLimits, RateLimitPolicy{
+			Name:       group.Name,
+			PathPrefix: group.PathPrefix,
+			Methods:    group.Methods,
+			Key:        group.Key,
+			Tenants:    group.Tenants,
+			RateLimit:  group.RateLimit,
+			Burst:      group.Burst,
+		})
+	}
+	return bundle
+}
+
+func (bundle *Bundle) rateLimitGroups() []config.RateLimitGroupConfig {
+	groups := make([]config.RateLimitGroupConfig, 0, len(bundle.RateLimits))
+	for _, rateLimit := range bundle.RateLimits {
+		groups = append(groups, config.RateLimitGroupConfig{
+			Name:       rateLimit.Name,
+			PathPrefix: rateLimit.PathPrefix,
+			Methods:    rateLimit.Methods,
+			Key:        rateLimit.Key,
+			Tenants:    rateLimit.Tenants,
+			RateLimit:  rateLimit.RateLimit,
+			Burst:      rateLimit.Burst,
+		})
+	}
+	return groups
+}
+
+func (bundle *Bundle) rateLimitCosts() []config.RateLimitCostConfig {
+	costs := make([]config.RateLimitCostConfig, 0, len(bundle.Costs))
+	for _, cost := range bundle.Costs {
+		costs = append(costs, config.RateLimitCostConfig{Method: cost.Method, Path: cost.Path, Cost: cost.Cost})
+	}
+	return costs
+}
+
+// Validate checks the bundle can be applied
+func (bundle *Bundle) Validate() error {
+	if strings.TrimSpace(bundle.Version) == "" {
+		return fmt.Errorf("The policy bundle requires a version")
+	}
+	prefixes := map[string]bool{}
+	for _, route := range bundle.Routes {
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			return fmt.Errorf("The path prefix %q of a route policy must start with /", route.PathPrefix)
+		}
+		key := route.key()
+		if prefixes[key] {
+			return fmt.Errorf("The route policy %s is defined twice", key)
+		}
+		prefixes[key] = true
+		if route.Authentication != "" && route.Authentication != AuthenticationRequired {
+			return fmt.Errorf("Invalid authentication %s of the route policy %s", route.Authentication, key)
+		}
+		if len(route.Roles) > 0 && route.Authentication != AuthenticationRequired {
+			return fmt.Errorf("The route policy %s requires roles without requiring authentication", key)
+		}
+	}
+	if err := ratelimit.ValidateGroups(bundle.rateLimitGroups()); err != nil {
+		return err
+	}
+	return ratelimit.ValidateCosts(bundle.rateLimitCosts())
+}
+
+// key identifies the route policy by its prefix and methods
+func (route RoutePolicy) key() string {
+	methods := make([]string, 0, len(route.Methods))
+	for _, method := range route.Methods {
+		methods = append(methods, strings.ToUpper(method))
+	}
+	sort.Strings(methods)
+	if len(methods) == 0 {
+		return "* " + route.prefix()
+	}
+	return strings.Join(methods, ",") + " " + route.prefix()
+}
+
+// prefix is the cleaned path prefix, the most specific route policies have the longest ones
+func (route RoutePolicy) prefix() string {
+	return strings.TrimSuffix(path.Clean(route.PathPrefix), "/")
+}
+
+// Diff lists the changes from the bundle to the other one, the routes first, then the rate limits and their costs
+func (bundle *Bundle) Diff(other *Bundle) []Change {
+	changes := []Change{}
+	before, after := map[string]interface{}{}, map[string]interface{}{}
+	keys := []string{}
+	collect := func(policies map[string]interface{}, key string, policy interface{}) {
+		if _, seen := before[key]; !seen {
+			if _, seen := after[key]; !seen {
+				keys = append(keys, key)
+			}
+		}
+		policies[key] = policy
+	}
+	for _, route := range bundle.Routes {
+		collect(before, KindRoute+" "+route.key(), route)
+	}
+	for _, route := range other.Routes {
+		collect(after, KindRoute+" "+route.key(), route)
+	}
+	for _, rateLimit := range bundle.RateLimits {
+		collect(before, KindRateLimit+" "+rateLimit.Name, rateLimit)
+	}
+	for _, rateLimit := range other.RateLimits {
+		collect(after, KindRateLimit+" "+rateLimit.Name, rateLimit)
+	}
+	for _, cost := range bundle.Costs {
+		collect(before, KindRateLimitCost+" "+cost.key(), cost)
+	}
+	for _, cost := range other.Costs {
+		collect(after, KindRateLimitCost+" "+cost.key(), cost)
+	}
+	for _, key := range keys {
+		kind, name, _ := strings.Cut(key, " ")
+		previous, existed := before[key]
+		next, exists := after[key]
+		switch {
+		case !existed:
+			changes = append(changes, Change{Kind: kind, Key: name, Action: ActionAdded, After: next})
+		case !exists:
+			changes = append(changes, Change{Kind: kind, Key: name, Action: ActionRemoved, Before: previous})
+		case !reflect.DeepEqual(previous, next):
+			changes = append(changes, Change{Kind: kind, Key: name, Action: ActionChanged, Before: previous, After: next})
+		}
+	}
+	return changes
+}
+
+// key identifies the cost by its method and route template
+func (cost RateLimitCost) key() string {
+	return strings.ToUpper(cost.Method) + " " + cost.Path
+}
@@ -0,0 +1,293 @@
+package policy
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+)
+
+const (
+	defaultMaxHistory = 20
+	// routePolicyKey holds the route policy matched by the request for the role check of the authenticated hook
+	routePolicyKey = "policy_route"
+)
+
+// Errors of the bundle store, answered with their own status codes by the admin routes
+var (
+	ErrBundleExists   = stdErrors.New("The policy bundle version already exists")
+	ErrBundleNotFound = stdErrors.New("The policy bundle version was not found")
+)
+
+// activation is the active bundle with its route policies, most specific first
+type activation struct {
+	bundle *Bundle
+	routes []RoutePolicy
+}
+
+// Bundles keeps the last applied policy bundles and enforces the route policies of the active one,
+// the rate limits are applied to the rate limiter
+type Bundles struct {
+	limiter        *ratelimit.Limiter
+	authentication authentication.AutheticationMiddlewarer
+	maxHistory     int
+	versions       []*Bundle
+	activations    []string
+	active         atomic.Pointer[activation]
+	applyMtx       sync.Mutex
+}
+
+// NewBundles applies the bundle file of the configuration, or the rate limit groups of the configuration without one
+func NewBundles(configurations *config.Config, limiter *ratelimit.Limiter) (*Bundles, error) {
+	bundles := &Bundles{
+		limiter:    limiter,
+		maxHistory: configurations.PolicyBundles.MaxHistory,
+	}
+	if bundles.maxHistory <= 0 {
+		bundles.maxHistory = defaultMaxHistory
+	}
+	bundle := bundleFromConfig(configurations)
+	if limiter == nil {
+		bundle.RateLimits, bundle.Costs = nil, nil
+	}
+	if configurations.PolicyBundles.File != "" {
+		var err error
+		bundle, err = LoadBundle(configurations.PolicyBundles.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := bundles.Apply(bundle); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+// UseAuthentication sets the authentication middleware enforcing the route policies requiring authentication
+func (bundles *Bundles) UseAuthentication(authenticationMiddleware authentication.AutheticationMiddlewarer) {
+	bundles.authentication = authenticationMiddleware
+}
+
+// Validate checks the bundle can be applied to the gateway
+func (bundles *Bundles) Validate(bundle *Bundle) error {
+	if err := bundle.Validate(); err != nil {
+		return err
+	}
+	if (len(bundle.RateLimits) > 0 || len(bundle.Costs) > 0) && bundles.limiter == nil {
+		return fmt.Errorf("The policy bundle defines rate limits but rate limiting is disabled")
+	}
+	return nil
+}
+
+// Apply validates and activates a new bundle version, returning its changes from the previously active bundle
+func (bundles *Bundles) Apply(bundle *Bundle) ([]Change, error) {
+	if err := bundles.Validate(bundle); err != nil {
+		return nil, err
+	}
+	bundles.applyMtx.Lock()
+	defer bundles.applyMtx.Unlock()
+
+	if bundles.version(bundle.Version) != nil {
+		return nil, ErrBundleExists
+	}
+	changes := bundles.Active().Diff(bundle)
+	if err := bundles.activate(bundle); err != nil {
+		return nil, err
+	}
+	bundles.versions = append(bundles.versions, bundle)
+	bundles.activations = append(bundles.activations, bundle.Version)
+	bundles.trim()
+	return changes, nil
+}
+
+// Rollback activates again a kept bundle version, or the previously active bundle when the version is empty,
+// returning its changes from the bundle it replaces
+func (bundles *Bundles) Rollback(version string) (*Bundle, []Change, error) {
+	bundles.applyMtx.Lock()
+	defer bundles.applyMtx.Unlock()
+
+	activations := bundles.activations
+	if version == "" {
+		if len(activations) < 2 {
+			return nil, nil, fmt.Errorf("There is no previous policy bundle to roll back to")
+		}
+		version = activations[len(activations)-2]
+		activations = activations[:len(activations)-1]
+	} else {
+		activations = append(activations, version)
+	}
+	bundle := bundles.version(version)
+	if bundle == nil {
+		return nil, nil, ErrBundleNotFound
+	}
+	changes := bundles.Active().Diff(bundle)
+	if err := bundles.activate(bundle); err != nil {
+		return nil, nil, err
+	}
+	bundles.activations = activations
+	bundles.trim()
+	return bundle, changes, nil
+}
+
+// Active returns the active bundle
+func (bundles *Bundles) Active() *Bundle {
+	active := bundles.active.Load()
+	if active == nil {
+		return &Bundle{}
+	}
+	return active.bundle
+}
+
+// Version returns a kept bundle version
+func (bundles *Bundles) Version(version string) (*Bundle, error) {
+	bundles.applyMtx.Lock()
+	defer bundles.applyMtx.Unlock()
+
+	bundle := bundles.version(version)
+	if bundle == nil {
+		return nil, ErrBundleNotFound
+	}
+	return bundle, nil
+}
+
+// Versions lists the kept bundle versions, oldest first
+func (bundles *Bundles) Versions() []*Bundle {
+	bundles.applyMtx.Lock()
+	defer bundles.applyMtx.Unlock()
+
+	return append([]*Bundle{}, bundles.versions...)
+}
+
+func (bundles *Bundles) version(version string) *Bundle {
+	for _, bundle := range bundles.versions {
+		if bundle.Version == version {
+			return bundle
+		}
+	}
+	return nil
+}
+
+// activate applies the rate limits first, so a bundle the limiter rejects leaves the routes untouched
+func (bundles *Bundles) activate(bundle *Bundle) error {
+	if bundles.limiter != nil {
+		if err := bundles.limiter.SetGroups(bundle.rateLimitGroups()); err != nil {
+			return err
+		}
+		if err := bundles.limiter.SetCosts(bundle.rateLimitCosts()); err != nil {
+			return err
+		}
+	}
+	routes := append([]RoutePolicy{}, bundle.Routes...)
+	sort.SliceStable(routes, func(first, second int) bool {
+		firstLength, secondLength := len(routes[first].prefix()), len(routes[second].prefix())
+		if firstLength != secondLength {
+			return firstLength > secondLength
+		}
+		return len(routes[first].Methods) > 0 && len(routes[second].Methods) == 0
+	})
+	bundles.active.Store(&activation{bundle: bundle, routes: routes})
+	return nil
+}
+
+// trim drops the oldest versions and activations beyond the history size, never the active version
+func (bundles *Bundles) trim() {
+	if len(bundles.activations) > bundles.maxHistory {
+		bundles.activations = bundles.activations[len(bundles.activations)-bundles.maxHistory:]
+	}
+	active := bundles.Active().Version
+	for len(bundles.versions) > bundles.maxHistory {
+		index := 0
+		if bundles.versions[index].Version == active {
+			index = 1
+		}
+		dropped := bundles.versions[index].Version
+		bundles.versions = append(bundles.versions[:index], bundles.versions[index+1:]...)
+		activations := bundles.activations[:0]
+		for _, version := range bundles.activations {
+			if version != dropped {
+				activations = append(activations, version)
+			}
+		}
+		bundles.activations = activations
+	}
+}
+
+// match returns the most specific route policy of the active bundle covering the request
+func (bundles *Bundles) match(request *http.Request) (RoutePolicy, bool) {
+	active := bundles.active.Load()
+	if active == nil {
+		return RoutePolicy{}, false
+	}
+	for _, route := range active.routes {
+		if !ratelimit.HasPathPrefix(request.URL.Path, route.PathPrefix) {
+			continue
+		}
+		if len(route.Methods) == 0 {
+			return route, true
+		}
+		for _, method := range route.Methods {
+			if strings.EqualFold(method, request.Method) {
+				return route, true
+			}
+		}
+	}
+	return RoutePolicy{}, false
+}
+
+// Middleware rejects the requests to disabled routes and authenticates the routes requiring it
+func (bundles *Bundles) Middleware(ctx *gin.Context) {
+	route, exists := bundles.match(ctx.Request)
+	if !exists {
+		ctx.Next()
+		return
+	}
+	if route.Disabled {
+		if logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context()); err == nil {
+			logger.Error(nil, fmt.Sprintf("The route %s is disabled by the policy bundle %s", route.PathPrefix, bundles.Active().Version))
+		}
+		errors.Abort(ctx, errors.RouteDisabled)
+		return
+	}
+	ctx.Set(routePolicyKey, route)
+	if route.Authentication == AuthenticationRequired && bundles.authentication != nil {
+		bundles.authentication.RequireAuthentication(ctx)
+		return
+	}
+	ctx.Next()
+}
+
+// OnAuthenticated rejects the authenticated requests holding none of the roles of the route policy
+func (bundles *Bundles) OnAuthenticated(ctx *gin.Context, claims *commonJWT.TokenClaims) {
+	value, exists := ctx.Get(routePolicyKey)
+	route, ok := value.(RoutePolicy)
+	if !exists || !ok || len(route.Roles) == 0 {
+		return
+	}
+	tokenValue, _ := ctx.Get(string(commonJWT.JWTTokenKey))
+	if token, ok := tokenValue.(*jwt.Token); ok {
+		for _, tokenRole := range authentication.TokenRoles(token) {
+			for _, role := range route.Roles {
+				if strings.EqualFold(tokenRole, role) {
+					return
+				}
+			}
+		}
+	}
+	if logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context()); err == nil {
+		logger.Error(nil, fmt.Sprintf("The user does not hold a role required by the route policy %s", route.PathPrefix))
+	}
+	errors.Abort(ctx, errors.InsufficientRole)
+}
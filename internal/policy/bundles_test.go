@@ -0,0 +1,211 @@
+package policy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+)
+
+// tokenAuthentication stands for the access token checks, the bearer token being the roles of the user
+type tokenAuthentication struct {
+	authentication.AutheticationMiddlewarer
+	hook authentication.AuthenticatedHook
+}
+
+func (tokenAuthentication *tokenAuthentication) RequireAuthentication(ctx *gin.Context) {
+	roles, found := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !found {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	ctx.Set(string(commonJWT.JWTTokenKey), &jwt.Token{Claims: jwt.MapClaims{authentication.RolesClaim: roles}})
+	tokenAuthentication.hook(ctx, &commonJWT.TokenClaims{UserID: "user-id"})
+	if ctx.IsAborted() {
+		return
+	}
+	ctx.Next()
+}
+
+func newBundles(t *testing.T, routes ...RoutePolicy) *Bundles {
+	limiter, err := ratelimit.NewLimiter(&config.Config{})
+	assert.NoError(t, err)
+	bundles, err := NewBundles(&config.Config{PolicyBundles: config.PolicyBundlesConfig{Enabled: true, MaxHistory: 3}}, limiter)
+	assert.NoError(t, err)
+	if len(routes) > 0 {
+		_, err = bundles.Apply(&Bundle{Version: "v1", Routes: routes})
+		assert.NoError(t, err)
+	}
+	return bundles
+}
+
+func newPolicyRouter(t *testing.T, bundles *Bundles) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	controller := gomock.NewController(t)
+	loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+	loggerMock.EXPECT().Error(gomock.Any(), gomock.Any()).AnyTimes()
+	bundles.UseAuthentication(&tokenAuthentication{hook: bundles.OnAuthenticated})
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+	}, bundles.Middleware)
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) }
+	router.GET("/api/v1/payments", ok)
+	router.POST("/api/v1/payments", ok)
+	router.GET("/api/v1/payments/refunds", ok)
+	router.GET("/api/v1/user", ok)
+	return router
+}
+
+func serve(router *gin.Engine, method, path, token string) int {
+	request := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestBundles(t *testing.T) {
+	t.Run("Middleware_Should_Enforce_The_Most_Specific_Route_Policy", func(t *testing.T) {
+		router := newPolicyRouter(t, newBundles(t,
+			RoutePolicy{PathPrefix: "/api/v1/payments", Authentication: AuthenticationRequired},
+			RoutePolicy{PathPrefix: "/api/v1/payments", Methods: []string{"post"}, Authentication: AuthenticationRequired, Roles: []string{"billing"}},
+			RoutePolicy{PathPrefix: "/api/v1/payments/refunds", Disabled: true},
+		))
+
+		assert.Equal(t, http.StatusNoContent, serve(router, http.MethodGet, "/api/v1/user", ""))
+		assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, "/api/v1/payments", ""))
+		assert.Equal(t, http.StatusNoContent, serve(router, http.MethodGet, "/api/v1/payments", "user"))
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPost, "/api/v1/payments", "user"))
+		assert.Equal(t, http.StatusNoContent, serve(router, http.MethodPost, "/api/v1/payments", "user Billing"))
+		assert.Equal(t, http.StatusServiceUnavailable, serve(router, http.MethodGet, "/api/v1/payments/refunds", "user"))
+	})
+
+	t.Run("Apply_Should_Return_The_Changes_And_Update_The_Rate_Limits", func(t *testing.T) {
+		bundles := newBundles(t, RoutePolicy{PathPrefix: "/api/v1/payments", Authentication: AuthenticationRequired})
+
+		changes, err := bundles.Apply(&Bundle{
+			Version:    "v2",
+			Routes:     []RoutePolicy{{PathPrefix: "/api/v1/payments", Disabled: true}},
+			RateLimits: []RateLimitPolicy{{Name: "api", PathPrefix: "/api/v1", Key: ratelimit.KeyIP, RateLimit: 1, Burst: 1}},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", bundles.Active().Version)
+		assert.Equal(t, []Change{
+			{
+				Kind:   KindRoute,
+				Key:    "* /api/v1/payments",
+				Action: ActionChanged,
+				Before: RoutePolicy{PathPrefix: "/api/v1/payments", Authentication: AuthenticationRequired},
+				After:  RoutePolicy{PathPrefix: "/api/v1/payments", Disabled: true},
+			},
+			{
+				Kind:   KindRateLimit,
+				Key:    "api",
+				Action: ActionAdded,
+				After:  RateLimitPolicy{Name: "api", PathPrefix: "/api/v1", Key: ratelimit.KeyIP, RateLimit: 1, Burst: 1},
+			},
+		}, changes)
+		assert.Equal(t, http.StatusServiceUnavailable, serve(newPolicyRouter(t, bundles), http.MethodGet, "/api/v1/payments", "user"))
+	})
+
+	t.Run("Apply_Should_Keep_The_Active_Bundle_On_Invalid_Bundles", func(t *testing.T) {
+		bundles := newBundles(t, RoutePolicy{PathPrefix: "/api/v1/payments", Authentication: AuthenticationRequired})
+
+		_, err := bundles.Apply(&Bundle{Version: "v1"})
+		assert.ErrorIs(t, err, ErrBundleExists)
+		_, err = bundles.Apply(&Bundle{Version: "v2", Routes: []RoutePolicy{{PathPrefix: "/api/v1/user", Roles: []string{"admin"}}}})
+		assert.Error(t, err)
+		_, err = bundles.Apply(&Bundle{Version: "v2", RateLimits: []RateLimitPolicy{{Name: "api", Key: "session", RateLimit: 1, Burst: 1}}})
+		assert.Error(t, err)
+
+		assert.Equal(t, "v1", bundles.Active().Version)
+	})
+
+	t.Run("Apply_Should_Return_The_Changed_Rate_Limit_Costs", func(t *testing.T) {
+		bundles := newBundles(t)
+		_, err := bundles.Apply(&Bundle{Version: "v1", Costs: []RateLimitCost{{Method: "GET", Path: "/api/v1/search", Cost: 5}}})
+		assert.NoError(t, err)
+
+		changes, err := bundles.Apply(&Bundle{Version: "v2", Costs: []RateLimitCost{{Method: "GET", Path: "/api/v1/search", Cost: 10}}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Change{{
+			Kind:   KindRateLimitCost,
+			Key:    "GET /api/v1/search",
+			Action: ActionChanged,
+			Before: RateLimitCost{Method: "GET", Path: "/api/v1/search", Cost: 5},
+			After:  RateLimitCost{Method: "GET", Path: "/api/v1/search", Cost: 10},
+		}}, changes)
+		_, err = bundles.Apply(&Bundle{Version: "v3", Costs: []RateLimitCost{{Method: "GET", Path: "/api/v1/search"}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Rollback_Should_Activate_The_Previous_Or_A_Kept_Version", func(t *testing.T) {
+		bundles := newBundles(t, RoutePolicy{PathPrefix: "/api/v1/payments", Disabled: true})
+		_, err := bundles.Apply(&Bundle{Version: "v2"})
+		assert.NoError(t, err)
+
+		bundle, changes, err := bundles.Rollback("")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", bundle.Version)
+		assert.Len(t, changes, 1)
+		assert.Equal(t, http.StatusServiceUnavailable, serve(newPolicyRouter(t, bundles), http.MethodGet, "/api/v1/payments", ""))
+
+		bundle, _, err = bundles.Rollback("config")
+		assert.NoError(t, err)
+		assert.Equal(t, "config", bundle.Version)
+		_, _, err = bundles.Rollback("v9")
+		assert.ErrorIs(t, err, ErrBundleNotFound)
+	})
+
+	t.Run("Apply_Should_Drop_The_Oldest_Versions_Beyond_The_History", func(t *testing.T) {
+		bundles := newBundles(t)
+		for _, version := range []string{"v1", "v2", "v3"} {
+			_, err := bundles.Apply(&Bundle{Version: version})
+			assert.NoError(t, err)
+		}
+
+		versions := []string{}
+		for _, bundle := range bundles.Versions() {
+			versions = append(versions, bundle.Version)
+		}
+		assert.Equal(t, []string{"v1", "v2", "v3"}, versions)
+		_, err := bundles.Version("config")
+		assert.ErrorIs(t, err, ErrBundleNotFound)
+	})
+
+	t.Run("BundleFromConfig_Should_Keep_The_Tenants_Of_The_Rate_Limit_Groups", func(t *testing.T) {
+		groups := []config.RateLimitGroupConfig{
+			{Name: "acme", PathPrefix: "/api/v1", Key: ratelimit.KeyUser, Tenants: []string{"acme"}, RateLimit: 5, Burst: 10},
+			{Name: "everyone", PathPrefix: "/api/v1", Key: ratelimit.KeyIP, RateLimit: 1, Burst: 1},
+		}
+
+		bundle := bundleFromConfig(&config.Config{RateLimits: config.RateLimitsConfig{Groups: groups}})
+
+		assert.Equal(t, []string{"acme"}, bundle.RateLimits[0].Tenants)
+		assert.Equal(t, groups, bundle.rateLimitGroups())
+	})
+
+	t.Run("ParseBundle_Should_Reject_Unknown_Fields", func(t *testing.T) {
+		_, err := ParseBundle([]byte(`{"version":"v1","route":[]}`))
+
+		assert.Error(t, err)
+	})
+}
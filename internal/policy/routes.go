@@ -0,0 +1,177 @@
+package policy
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/admin"
+	adminRoutes "github.com/quadev-ltd/qd-qpi-gateway/internal/admin/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+)
+
+// RollbackRequestBody is the request body of the rollback route, an empty version rolls back to the previous bundle
+type RollbackRequestBody struct {
+	Version string `json:"version"`
+}
+
+// RegisterRoutes registers the policy bundle administration routes, audited before the role check like the other admin routes
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	bundles *Bundles,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) error {
+	eventPublisher, err := events.NewPublisher(configurations)
+	if err != nil {
+		return fmt.Errorf("Failed to initiate event publisher: %v", err)
+	}
+	auditor := admin.NewAuditor(eventPublisher)
+	requireAdmin := authenticationMiddleware.RequireRole(configurations.Admin.Roles...)
+
+	bundleRoutes := api.Group("/admin/policy-bundles")
+	bundleRoutes.Use(authenticationMiddleware.RequireAuthentication)
+	bundleRoutes.GET("", auditor.Record("policy_bundles.list"), requireAdmin, bundles.ListHandler)
+	bundleRoutes.GET("/active", auditor.Record("policy_bundles.active"), requireAdmin, bundles.ActiveHandler)
+	bundleRoutes.GET("/:version", auditor.Record("policy_bundles.get"), requireAdmin, bundles.VersionHandler)
+	bundleRoutes.GET("/:version/diff", auditor.Record("policy_bundles.diff"), requireAdmin, bundles.DiffHandler)
+	bundleRoutes.POST("", auditor.Record("policy_bundles.apply"), requireAdmin, bundles.ApplyHandler)
+	bundleRoutes.POST("/validate", auditor.Record("policy_bundles.validate"), requireAdmin, bundles.ValidateHandler)
+	bundleRoutes.POST("/rollback", auditor.Record("policy_bundles.rollback"), requireAdmin, bundles.RollbackHandler)
+	return nil
+}
+
+// ListHandler answers the kept bundle versions and the active one
+func (bundles *Bundles) ListHandler(ctx *gin.Context) {
+	versions := []gin.H{}
+	for _, bundle := range bundles.Versions() {
+		versions = append(versions, gin.H{"version": bundle.Version, "description": bundle.Description})
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"active":   bundles.Active().Version,
+		"versions": versions,
+	})
+}
+
+// ActiveHandler answers the active bundle
+func (bundles *Bundles) ActiveHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, bundles.Active())
+}
+
+// VersionHandler answers a kept bundle version
+func (bundles *Bundles) VersionHandler(ctx *gin.Context) {
+	bundle, err := bundles.Version(ctx.Param("version"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, bundle)
+}
+
+// DiffHandler answers the changes a rollback to a kept bundle version would make to the active bundle
+func (bundles *Bundles) DiffHandler(ctx *gin.Context) {
+	bundle, err := bundles.Version(ctx.Param("version"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"active":  bundles.Active().Version,
+		"version": bundle.Version,
+		"changes": bundles.Active().Diff(bundle),
+	})
+}
+
+// ValidateHandler validates the bundle of the request body without applying it, answering its changes to the active bundle
+func (bundles *Bundles) ValidateHandler(ctx *gin.Context) {
+	bundle := readBundle(ctx)
+	if bundle == nil {
+		return
+	}
+	if err := bundles.Validate(bundle); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"active":  bundles.Active().Version,
+		"version": bundle.Version,
+		"changes": bundles.Active().Diff(bundle),
+	})
+}
+
+// ApplyHandler applies the bundle of the request body
+func (bundles *Bundles) ApplyHandler(ctx *gin.Context) {
+	bundle := readBundle(ctx)
+	if bundle == nil {
+		return
+	}
+	changes, err := bundles.Apply(bundle)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Set(adminRoutes.AuditDetailsKey, map[string]interface{}{"version": bundle.Version, "changes": len(changes)})
+	if logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context()); err == nil {
+		logger.Info(fmt.Sprintf("Applied the policy bundle %s", bundle.Version))
+	}
+	ctx.JSON(http.StatusCreated, gin.H{
+		"version": bundle.Version,
+		"changes": changes,
+	})
+}
+
+// RollbackHandler activates again a kept bundle version
+func (bundles *Bundles) RollbackHandler(ctx *gin.Context) {
+	body := RollbackRequestBody{}
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+			return
+		}
+	}
+	bundle, changes, err := bundles.Rollback(body.Version)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Set(adminRoutes.AuditDetailsKey, map[string]interface{}{"version": bundle.Version, "changes": len(changes)})
+	if logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context()); err == nil {
+		logger.Info(fmt.Sprintf("Rolled back to the policy bundle %s", bundle.Version))
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"version": bundle.Version,
+		"changes": changes,
+	})
+}
+
+func readBundle(ctx *gin.Context) *Bundle {
+	document, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+		return nil
+	}
+	bundle, err := ParseBundle(document)
+	if err != nil {
+		abortWithError(ctx, err)
+		return nil
+	}
+	return bundle
+}
+
+func abortWithError(ctx *gin.Context, err error) {
+	switch {
+	case stdErrors.Is(err, ErrBundleNotFound):
+		errors.Abort(ctx, errors.PolicyBundleNotFound)
+	case stdErrors.Is(err, ErrBundleExists):
+		errors.Abort(ctx, errors.PolicyBundleExists)
+	default:
+		errors.AbortWithMessage(ctx, errors.InvalidPolicyBundle, err.Error())
+	}
+}
@@ -0,0 +1,147 @@
+package preferences
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// LabelSuffix is appended to a localized field name to name its display string
+const LabelSuffix = "Label"
+
+// Labeler adds localized display strings next to the configured enum values of the JSON responses
+type Labeler struct {
+	resolver      *Resolver
+	labels        map[string]map[string]map[string]string
+	defaultLocale string
+}
+
+// NewLabeler creates the labeler of the configured labels, keyed by field, value and locale
+func NewLabeler(resolver *Resolver, configurations *config.Config) *Labeler {
+	labels := make(map[string]map[string]map[string]string)
+	for field, values := range configurations.Preferences.Labels {
+		labels[strings.ToLower(field)] = make(map[string]map[string]string)
+		for value, translations := range values {
+			localized := make(map[string]string)
+			for locale, label := range translations {
+				localized[strings.ToLower(locale)] = label
+			}
+			labels[strings.ToLower(field)][strings.ToLower(value)] = localized
+		}
+	}
+	return &Labeler{
+		resolver:      resolver,
+		labels:        labels,
+		defaultLocale: configurations.Preferences.DefaultLocale,
+	}
+}
+
+// labelWriter holds back JSON responses so they can be localized once the handlers finish
+type labelWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	decided   bool
+	buffering bool
+}
+
+func (writer *labelWriter) Write(data []byte) (int, error) {
+	if !writer.decided {
+		writer.decided = true
+		writer.buffering = strings.HasPrefix(writer.Header().Get("Content-Type"), "application/json")
+	}
+	if writer.buffering {
+		return writer.body.Write(data)
+	}
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *labelWriter) WriteString(data string) (int, error) {
+	return writer.Write([]byte(data))
+}
+
+// Middleware localizes the enum values of the JSON response to the request locale
+func (labeler *Labeler) Middleware(ctx *gin.Context) {
+	writer := &labelWriter{ResponseWriter: ctx.Writer}
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = writer.ResponseWriter
+	if !writer.buffering {
+		return
+	}
+
+	body := writer.body.Bytes()
+	var response interface{}
+	if err := json.Unmarshal(body, &response); err == nil && labeler.localize(response, labeler.locale(ctx)) {
+		if localized, err := json.Marshal(response); err == nil {
+			body = localized
+		} else if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil {
+			logger.Error(err, "Could not encode the localized response")
+		}
+	}
+	writer.ResponseWriter.Write(body)
+}
+
+// locale returns the resolved locale of the request or negotiates it from the Accept-Language header
+func (labeler *Labeler) locale(ctx *gin.Context) string {
+	if value, exists := ctx.Get(ContextKey); exists {
+		if preferences, ok := value.(Preferences); ok && preferences.Locale != "" {
+			return preferences.Locale
+		}
+	}
+	if locale := labeler.resolver.negotiateLocale(ctx.GetHeader("Accept-Language")); locale != "" {
+		return locale
+	}
+	return labeler.defaultLocale
+}
+
+// localize walks the decoded response adding the labels of the configured fields and tells whether any was added
+func (labeler *Labeler) localize(value interface{}, locale string) bool {
+	changed := false
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, field := range typed {
+			if enum, ok := field.(string); ok {
+				labelKey := key + LabelSuffix
+				if _, exists := typed[labelKey]; exists {
+					continue
+				}
+				if label, found := labeler.label(key, enum, locale); found {
+					typed[labelKey] = label
+					changed = true
+				}
+				continue
+			}
+			changed = labeler.localize(field, locale) || changed
+		}
+	case []interface{}:
+		for _, item := range typed {
+			changed = labeler.localize(item, locale) || changed
+		}
+	}
+	return changed
+}
+
+// label finds the display string of the value in the locale, its base language or the default locale
+func (labeler *Labeler) label(field, value, locale string) (string, bool) {
+	translations, exists := labeler.labels[strings.ToLower(field)][strings.ToLower(value)]
+	if !exists {
+		return "", false
+	}
+	locale = strings.ToLower(locale)
+	if label, found := translations[locale]; found {
+		return label, true
+	}
+	base := strings.SplitN(locale, "-", 2)[0]
+	for candidate, label := range translations {
+		if strings.SplitN(candidate, "-", 2)[0] == base {
+			return label, true
+		}
+	}
+	label, found := translations[strings.ToLower(labeler.defaultLocale)]
+	return label, found
+}
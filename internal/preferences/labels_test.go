@@ -0,0 +1,83 @@
+package preferences
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func performLabeledRequest(labeler *Labeler, acceptLanguage string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/test", labeler.Middleware, handler)
+	request := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if acceptLanguage != "" {
+		request.Header.Set("Accept-Language", acceptLanguage)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	return w
+}
+
+func TestLabeler(t *testing.T) {
+	// Viper lowercases the configuration keys
+	configurations := &config.Config{
+		Preferences: config.PreferencesConfig{
+			SupportedLocales: []string{"en-GB", "es-ES"},
+			DefaultLocale:    "en-GB",
+			Labels: map[string]map[string]map[string]string{
+				"accountstatus": {
+					"verified": {"en-gb": "Verified", "es-es": "Verificado"},
+				},
+			},
+		},
+	}
+	labeler := NewLabeler(NewResolver(nil, configurations), configurations)
+	users := func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"users": []gin.H{
+				{"id": "1", "accountStatus": "VERIFIED"},
+				{"id": "2", "accountStatus": "LOCKED"},
+			},
+		})
+	}
+
+	t.Run("Middleware_Should_Add_Labels_In_Negotiated_Locale", func(t *testing.T) {
+		w := performLabeledRequest(labeler, "es-MX,es;q=0.9", users)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"users":[
+			{"id":"1","accountStatus":"VERIFIED","accountStatusLabel":"Verificado"},
+			{"id":"2","accountStatus":"LOCKED"}
+		]}`, w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Fall_Back_To_Default_Locale", func(t *testing.T) {
+		w := performLabeledRequest(labeler, "fr-FR", users)
+
+		assert.Contains(t, w.Body.String(), `"accountStatusLabel":"Verified"`)
+	})
+
+	t.Run("Middleware_Should_Prefer_Resolved_User_Locale", func(t *testing.T) {
+		w := performLabeledRequest(labeler, "en-GB", func(ctx *gin.Context) {
+			ctx.Set(ContextKey, Preferences{Locale: "es-ES"})
+			ctx.JSON(http.StatusOK, gin.H{"accountStatus": "VERIFIED"})
+		})
+
+		assert.JSONEq(t, `{"accountStatus":"VERIFIED","accountStatusLabel":"Verificado"}`, w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Pass_Through_Non_JSON_Responses", func(t *testing.T) {
+		w := performLabeledRequest(labeler, "es-ES", func(ctx *gin.Context) {
+			ctx.Data(http.StatusPartialContent, "image/png", []byte("VERIFIED"))
+		})
+
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "VERIFIED", w.Body.String())
+	})
+}
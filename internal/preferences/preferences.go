@@ -0,0 +1,248 @@
+package preferences
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// Metadata keys and headers carrying the user preferences
+const (
+	LocaleMetadataKey   = "accept-language"
+	TimezoneMetadataKey = "x-user-timezone"
+	TimezoneHeader      = "X-Timezone"
+	ContextKey          = "user_preferences"
+)
+
+const defaultCacheMaxEntries = 10000
+
+// Preferences are the localization preferences of a user
+type Preferences struct {
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+}
+
+// Sourcer fetches the stored preferences of a user
+type Sourcer interface {
+	GetPreferences(ctx context.Context, userID string) (*Preferences, error)
+}
+
+type cachedPreferences struct {
+	userID      string
+	preferences Preferences
+	cachedAt    time.Time
+}
+
+// Resolver resolves the preferences of every request and forwards them upstream. The stored preferences of the
+// users are cached apart from the preferences requested, evicting the least recently used beyond the max entries
+type Resolver struct {
+	source           Sourcer
+	supportedLocales []string
+	defaultLocale    string
+	defaultTimezone  string
+	maxEntries       int
+	recency          *list.List
+	cache            map[string]*list.Element
+	ttl              time.Duration
+	mtx              sync.Mutex
+}
+
+// NewResolver creates a preferences resolver; source may be nil when the backend stores no preferences
+func NewResolver(source Sourcer, configurations *config.Config) *Resolver {
+	maxEntries := configurations.Preferences.CacheMaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	return &Resolver{
+		source:           source,
+		supportedLocales: configurations.Preferences.SupportedLocales,
+		defaultLocale:    configurations.Preferences.DefaultLocale,
+		defaultTimezone:  configurations.Preferences.DefaultTimezone,
+		maxEntries:       maxEntries,
+		recency:          list.New(),
+		cache:            make(map[string]*list.Element),
+		ttl:              configurations.Preferences.CacheTTL,
+	}
+}
+
+// UseSource fetches the stored preferences of the users from the source
+func (resolver *Resolver) UseSource(source Sourcer) {
+	resolver.mtx.Lock()
+	defer resolver.mtx.Unlock()
+	resolver.source = source
+}
+
+// Len returns the number of users whose stored preferences are cached, expired or not
+func (resolver *Resolver) Len() int {
+	resolver.mtx.Lock()
+	defer resolver.mtx.Unlock()
+	return resolver.recency.Len()
+}
+
+// Middleware resolves the request preferences and injects them into the upstream metadata
+func (resolver *Resolver) Middleware(ctx *gin.Context) {
+	requested := Preferences{
+		Locale:   resolver.negotiateLocale(ctx.GetHeader("Accept-Language")),
+		Timezone: validTimezone(ctx.GetHeader(TimezoneHeader)),
+	}
+	resolved := requested
+
+	if claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey)); exists {
+		if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+			resolved = resolver.resolveUserPreferences(ctx, claims.UserID, requested)
+		}
+	}
+	if resolved.Locale == "" {
+		resolved.Locale = resolver.defaultLocale
+	}
+	if resolved.Timezone == "" {
+		resolved.Timezone = resolver.defaultTimezone
+	}
+
+	existingMD, ok := metadata.FromOutgoingContext(ctx.Request.Context())
+	if !ok {
+		existingMD = metadata.New(map[string]string{})
+	}
+	newMD := existingMD.Copy()
+	newMD.Set(LocaleMetadataKey, resolved.Locale)
+	newMD.Set(TimezoneMetadataKey, resolved.Timezone)
+	ctx.Request = ctx.Request.WithContext(metadata.NewOutgoingContext(ctx.Request.Context(), newMD))
+	ctx.Set(ContextKey, resolved)
+	ctx.Header("Content-Language", resolved.Locale)
+	ctx.Next()
+}
+
+// resolveUserPreferences completes the requested preferences with the stored ones of the user
+func (resolver *Resolver) resolveUserPreferences(ctx *gin.Context, userID string, requested Preferences) Preferences {
+	stored := resolver.getStoredPreferences(ctx, userID)
+	if requested.Locale == "" {
+		requested.Locale = stored.Locale
+	}
+	if requested.Timezone == "" {
+		requested.Timezone = stored.Timezone
+	}
+	return requested
+}
+
+// getStoredPreferences returns the cached preferences of the user, fetching them again once expired. The expired
+// ones are kept when the source fails
+func (resolver *Resolver) getStoredPreferences(ctx *gin.Context, userID string) Preferences {
+	resolver.mtx.Lock()
+	source := resolver.source
+	var cached *cachedPreferences
+	if element, exists := resolver.cache[userID]; exists {
+		resolver.recency.MoveToFront(element)
+		cached = element.Value.(*cachedPreferences)
+	}
+	resolver.mtx.Unlock()
+	if cached != nil && time.Since(cached.cachedAt) <= resolver.ttl {
+		return cached.preferences
+	}
+	if source == nil {
+		return Preferences{}
+	}
+	stored, err := source.GetPreferences(ctx.Request.Context(), userID)
+	if err != nil || stored == nil {
+		if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil && err != nil {
+			logger.Error(err, "Could not fetch the user preferences")
+		}
+		if cached != nil {
+			return cached.preferences
+		}
+		return Preferences{}
+	}
+	resolver.store(userID, *stored)
+	return *stored
+}
+
+// store caches the stored preferences of the user, evicting the least recently used users beyond the max entries
+func (resolver *Resolver) store(userID string, stored Preferences) {
+	resolver.mtx.Lock()
+	defer resolver.mtx.Unlock()
+	if element, exists := resolver.cache[userID]; exists {
+		resolver.recency.Remove(element)
+	}
+	resolver.cache[userID] = resolver.recency.PushFront(&cachedPreferences{
+		userID:      userID,
+		preferences: stored,
+		cachedAt:    time.Now(),
+	})
+	for resolver.recency.Len() > resolver.maxEntries {
+		evicted := resolver.recency.Remove(resolver.recency.Back()).(*cachedPreferences)
+		delete(resolver.cache, evicted.userID)
+	}
+}
+
+type weightedLocale struct {
+	tag    string
+	weight float64
+}
+
+// negotiateLocale picks the supported locale best matching the Accept-Language header
+func (resolver *Resolver) negotiateLocale(acceptLanguage string) string {
+	if acceptLanguage == "" {
+		return ""
+	}
+	weighted := []weightedLocale{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		weight := 1.0
+		for _, parameter := range fields[1:] {
+			parameter = strings.TrimSpace(parameter)
+			if strings.HasPrefix(parameter, "q=") {
+				if parsed, err := strconv.ParseFloat(parameter[2:], 64); err == nil {
+					weight = parsed
+				}
+			}
+		}
+		if weight > 0 {
+			weighted = append(weighted, weightedLocale{tag: tag, weight: weight})
+		}
+	}
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].weight > weighted[j].weight
+	})
+
+	for _, candidate := range weighted {
+		if len(resolver.supportedLocales) == 0 {
+			return candidate.tag
+		}
+		for _, supported := range resolver.supportedLocales {
+			if strings.EqualFold(candidate.tag, supported) {
+				return supported
+			}
+		}
+		base := strings.SplitN(candidate.tag, "-", 2)[0]
+		for _, supported := range resolver.supportedLocales {
+			if strings.EqualFold(base, strings.SplitN(supported, "-", 2)[0]) {
+				return supported
+			}
+		}
+	}
+	return ""
+}
+
+func validTimezone(timezone string) string {
+	if timezone == "" {
+		return ""
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return ""
+	}
+	return timezone
+}
@@ -0,0 +1,144 @@
+package preferences
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type fakeSource struct {
+	calls int
+}
+
+func (source *fakeSource) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	source.calls++
+	return &Preferences{Locale: "es-ES", Timezone: "Europe/Madrid"}, nil
+}
+
+func performRequest(resolver *Resolver, headers map[string]string, userID string) (*httptest.ResponseRecorder, metadata.MD) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var outgoing metadata.MD
+	router.GET(
+		"/test",
+		func(ctx *gin.Context) {
+			if userID != "" {
+				ctx.Set(string(commonJWT.ClaimsContextKey), &commonJWT.TokenClaims{UserID: userID})
+			}
+		},
+		resolver.Middleware,
+		func(ctx *gin.Context) {
+			outgoing, _ = metadata.FromOutgoingContext(ctx.Request.Context())
+			ctx.Status(http.StatusNoContent)
+		},
+	)
+	request := httptest.NewRequest(http.MethodGet, "/test", nil)
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	return w, outgoing
+}
+
+func TestPreferences(t *testing.T) {
+	configurations := &config.Config{
+		Preferences: config.PreferencesConfig{
+			SupportedLocales: []string{"en-GB", "es-ES"},
+			DefaultLocale:    "en-GB",
+			DefaultTimezone:  "UTC",
+			CacheTTL:         time.Minute,
+		},
+	}
+
+	t.Run("Middleware_Negotiates_Accept_Language_Success", func(t *testing.T) {
+		resolver := NewResolver(nil, configurations)
+
+		w, outgoing := performRequest(resolver, map[string]string{
+			"Accept-Language": "fr-FR;q=0.9, es-MX;q=0.8, en;q=0.5",
+			TimezoneHeader:    "America/Mexico_City",
+		}, "")
+
+		assert.Equal(t, "es-ES", w.Header().Get("Content-Language"))
+		assert.Equal(t, []string{"es-ES"}, outgoing.Get(LocaleMetadataKey))
+		assert.Equal(t, []string{"America/Mexico_City"}, outgoing.Get(TimezoneMetadataKey))
+	})
+
+	t.Run("Middleware_Defaults_Success", func(t *testing.T) {
+		resolver := NewResolver(nil, configurations)
+
+		_, outgoing := performRequest(resolver, map[string]string{TimezoneHeader: "Not/AZone"}, "")
+
+		assert.Equal(t, []string{"en-GB"}, outgoing.Get(LocaleMetadataKey))
+		assert.Equal(t, []string{"UTC"}, outgoing.Get(TimezoneMetadataKey))
+	})
+
+	t.Run("Middleware_Caches_User_Preferences_Success", func(t *testing.T) {
+		source := &fakeSource{}
+		resolver := NewResolver(source, configurations)
+
+		for attempt := 0; attempt < 2; attempt++ {
+			_, outgoing := performRequest(resolver, nil, "test-user-id")
+
+			assert.Equal(t, []string{"es-ES"}, outgoing.Get(LocaleMetadataKey))
+			assert.Equal(t, []string{"Europe/Madrid"}, outgoing.Get(TimezoneMetadataKey))
+		}
+		assert.Equal(t, 1, source.calls)
+	})
+
+	t.Run("Middleware_Does_Not_Replay_The_Requested_Preferences_Success", func(t *testing.T) {
+		source := &fakeSource{}
+		resolver := NewResolver(source, configurations)
+
+		_, requested := performRequest(resolver, map[string]string{"Accept-Language": "en"}, "test-user-id")
+		_, outgoing := performRequest(resolver, nil, "test-user-id")
+
+		assert.Equal(t, []string{"en-GB"}, requested.Get(LocaleMetadataKey))
+		assert.Equal(t, []string{"es-ES"}, outgoing.Get(LocaleMetadataKey))
+		assert.Equal(t, 1, source.calls)
+	})
+
+	t.Run("Middleware_Does_Not_Cache_Without_A_Source_Success", func(t *testing.T) {
+		resolver := NewResolver(nil, configurations)
+
+		performRequest(resolver, map[string]string{"Accept-Language": "es"}, "test-user-id")
+		_, outgoing := performRequest(resolver, nil, "test-user-id")
+
+		assert.Equal(t, []string{"en-GB"}, outgoing.Get(LocaleMetadataKey))
+		assert.Equal(t, 0, resolver.Len())
+	})
+
+	t.Run("Middleware_Evicts_The_Least_Recently_Used_User_Success", func(t *testing.T) {
+		source := &fakeSource{}
+		resolver := NewResolver(source, &config.Config{
+			Preferences: config.PreferencesConfig{CacheTTL: time.Minute, CacheMaxEntries: 2},
+		})
+
+		for _, userID := range []string{"first-user-id", "second-user-id", "first-user-id", "third-user-id", "first-user-id"} {
+			performRequest(resolver, nil, userID)
+		}
+
+		assert.Equal(t, 2, resolver.Len())
+		assert.Equal(t, 3, source.calls)
+	})
+
+	t.Run("UseSource_Fetches_The_Stored_Preferences_Success", func(t *testing.T) {
+		source := &fakeSource{}
+		resolver := NewResolver(nil, configurations)
+		resolver.UseSource(source)
+
+		_, outgoing := performRequest(resolver, nil, "test-user-id")
+
+		assert.Equal(t, []string{"Europe/Madrid"}, outgoing.Get(TimezoneMetadataKey))
+		assert.Equal(t, 1, source.calls)
+	})
+}
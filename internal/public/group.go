@@ -0,0 +1,66 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+)
+
+// Check inspects a request before its handlers, returning whether it may continue and aborting it otherwise
+type Check func(ctx *gin.Context) bool
+
+// Group is the router group of the unauthenticated routes, hardened apart from the authenticated traffic
+type Group struct {
+	*gin.RouterGroup
+	rateLimit     gin.HandlerFunc
+	captchaChecks []Check
+}
+
+// NewGroup creates the public routes group with its own rate limits and logging profile and registers the health route
+func NewGroup(api *gin.RouterGroup, configurations *config.Config) *Group {
+	rl := middleware.NewRateLimiter(
+		rate.Limit(configurations.PublicRoutes.RateLimit),
+		configurations.PublicRoutes.RateLimitBurst,
+	)
+	group := &Group{
+		RouterGroup: api.Group(""),
+		rateLimit:   middleware.RateLimitMiddleware(rl),
+	}
+	group.Use(middleware.PublicLogging)
+	group.GET("/health", Health)
+	return group
+}
+
+// Health reports the gateway is up
+func Health(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// UseCaptcha adds a check run before the handlers of the protected routes
+func (group *Group) UseCaptcha(check Check) {
+	group.captchaChecks = append(group.captchaChecks, check)
+}
+
+// Limited prepends the public rate limit to the handlers
+func (group *Group) Limited(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	return append([]gin.HandlerFunc{group.rateLimit}, handlers...)
+}
+
+// Protected prepends the public rate limit and the captcha checks to the handlers
+func (group *Group) Protected(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	return append([]gin.HandlerFunc{group.rateLimit, group.verifyCaptcha}, handlers...)
+}
+
+// verifyCaptcha runs the captcha checks registered when the request arrives, so checks added after the routes still apply
+func (group *Group) verifyCaptcha(ctx *gin.Context) {
+	for _, check := range group.captchaChecks {
+		if !check(ctx) {
+			return
+		}
+	}
+	ctx.Next()
+}
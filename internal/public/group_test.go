@@ -0,0 +1,86 @@
+package public
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+)
+
+func newTestGroup(burst int) (*gin.Engine, *Group) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	configurations := &config.Config{
+		PublicRoutes: config.PublicRoutesConfig{RateLimit: 0.001, RateLimitBurst: burst},
+	}
+	return router, NewGroup(router.Group("/api"), configurations)
+}
+
+func TestGroup(t *testing.T) {
+	t.Run("Health_Should_Respond_Ok", func(t *testing.T) {
+		router, _ := newTestGroup(1)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+	})
+
+	t.Run("Protected_Should_Run_Captcha_Checks_Added_After_Registration", func(t *testing.T) {
+		router, group := newTestGroup(5)
+		group.POST("/login", group.Protected(func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})...)
+		group.UseCaptcha(func(ctx *gin.Context) bool {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return false
+		})
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login", nil))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Limited_Should_Apply_Public_Rate_Limit", func(t *testing.T) {
+		router, group := newTestGroup(1)
+		group.GET("/verify", group.Limited(func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})...)
+
+		first := httptest.NewRecorder()
+		router.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/api/verify", nil))
+		second := httptest.NewRecorder()
+		router.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/api/verify", nil))
+
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, http.StatusTooManyRequests, second.Code)
+	})
+
+	t.Run("Public_Routes_Should_Use_Public_Log_Profile", func(t *testing.T) {
+		router, group := newTestGroup(1)
+		var keys map[string]any
+		group.GET("/reset/:token", func(ctx *gin.Context) {
+			keys = ctx.Keys
+		})
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/reset/secret-token?x=1", nil))
+
+		line := middleware.AccessLogFormatter(gin.LogFormatterParams{
+			Request:      httptest.NewRequest(http.MethodGet, "/api/reset/secret-token?x=1", nil),
+			Path:         "/api/reset/secret-token?x=1",
+			Method:       http.MethodGet,
+			StatusCode:   http.StatusOK,
+			ErrorMessage: "secret-token is invalid",
+			Keys:         keys,
+		})
+		assert.Contains(t, line, "/api/reset/:token")
+		assert.NotContains(t, line, "secret-token")
+	})
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// ValidateCosts checks the configuration of the route costs
+func ValidateCosts(costsConfig []config.RateLimitCostConfig) error {
+	_, err := newCosts(costsConfig)
+	return err
+}
+
+func newCosts(costsConfig []config.RateLimitCostConfig) (map[string]int, error) {
+	costs := make(map[string]int, len(costsConfig))
+	for _, costConfig := range costsConfig {
+		if costConfig.Method == "" || !strings.HasPrefix(costConfig.Path, "/") {
+			return nil, fmt.Errorf("The rate limit cost %s %s needs a method and a path starting with /", costConfig.Method, costConfig.Path)
+		}
+		if costConfig.Cost < 1 {
+			return nil, fmt.Errorf("The rate limit cost of %s %s must be at least 1", costConfig.Method, costConfig.Path)
+		}
+		key := costKey(costConfig.Method, costConfig.Path)
+		if _, exists := costs[key]; exists {
+			return nil, fmt.Errorf("The rate limit cost of %s is defined twice", key)
+		}
+		costs[key] = costConfig.Cost
+	}
+	return costs, nil
+}
+
+func costKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// SetCosts replaces the route costs of the limiter at once, the buckets are kept
+func (limiter *Limiter) SetCosts(costsConfig []config.RateLimitCostConfig) error {
+	costs, err := newCosts(costsConfig)
+	if err != nil {
+		return err
+	}
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+	limiter.costs = costs
+	return nil
+}
+
+// cost returns the tokens the request takes from a bucket of the group, a cost above the burst of the group
+// takes the whole burst so the request can still be served once the bucket is full. It runs under the limiter lock
+func (limiter *Limiter) cost(ctx *gin.Context, group *group) int {
+	cost, exists := limiter.costs[costKey(ctx.Request.Method, ctx.FullPath())]
+	if !exists {
+		return 1
+	}
+	if cost > group.burst {
+		return group.burst
+	}
+	return cost
+}
+
+// RouteLimit is a group limiting the requests of a route
+type RouteLimit struct {
+	Group   string   `json:"group"`
+	Key     string   `json:"key"`
+	Rate    float64  `json:"rate"`
+	Burst   int      `json:"burst"`
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+// RouteLimits returns the groups limiting the requests of the route template and the tokens a request takes
+// from their buckets, the groups of tenants only limit the requests of their tenants
+func (limiter *Limiter) RouteLimits(method, path string) ([]RouteLimit, int) {
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+	limits := []RouteLimit{}
+	for _, group := range limiter.groups {
+		if !HasPathPrefix(path, group.pathPrefix) || !group.limitsMethod(method) {
+			continue
+		}
+		limit := RouteLimit{Group: group.name, Key: group.key, Rate: float64(group.rate), Burst: group.burst}
+		for tenant := range group.tenants {
+			limit.Tenants = append(limit.Tenants, tenant)
+		}
+		sort.Strings(limit.Tenants)
+		limits = append(limits, limit)
+	}
+	cost, exists := limiter.costs[costKey(method, path)]
+	if !exists {
+		cost = 1
+	}
+	return limits, cost
+}
@@ -0,0 +1,350 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"golang.org/x/time/rate"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+)
+
+// Keys the buckets of a group are kept by
+const (
+	KeyIP     = "ip"
+	KeyAPIKey = "api_key"
+	KeyUser   = "user"
+	KeyTenant = "tenant"
+)
+
+// Defaults of the limiter
+const (
+	defaultAPIKeyHeader = "X-API-Key"
+	defaultIdleTTL      = 30 * time.Minute
+)
+
+// GroupStats are the counters of a rate limited group
+type GroupStats struct {
+	Allowed int64 `json:"allowed"`
+	Limited int64 `json:"limited"`
+	Buckets int   `json:"buckets"`
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	warned   bool
+}
+
+type group struct {
+	name       string
+	pathPrefix string
+	methods    []string
+	key        string
+	tenants    map[string]bool
+	rate       rate.Limit
+	burst      int
+	buckets    map[string]*bucket
+	stats      GroupStats
+}
+
+// Limiter applies the token bucket limits of the configured route groups, the groups keyed by user or
+// tenant are applied once the request is authenticated, through the authentication hook. The expensive
+// routes take their configured cost from the buckets instead of a single token
+type Limiter struct {
+	groups            []*group
+	costs             map[string]int
+	apiKeyHeader      string
+	idleTTL           time.Duration
+	warningPercentage float64
+	publisher         events.Publisherer
+	mtx               sync.Mutex
+}
+
+// NewLimiter creates the limiter of the configured groups
+func NewLimiter(configurations *config.Config) (*Limiter, error) {
+	rateLimitsConfig := configurations.RateLimits
+	if rateLimitsConfig.WarningPercentage < 0 || rateLimitsConfig.WarningPercentage >= 100 {
+		return nil, fmt.Errorf("The rate limit warning percentage must be between 0 and 100")
+	}
+	limiter := &Limiter{
+		apiKeyHeader:      rateLimitsConfig.APIKeyHeader,
+		idleTTL:           rateLimitsConfig.IdleTTL,
+		warningPercentage: rateLimitsConfig.WarningPercentage,
+	}
+	if limiter.apiKeyHeader == "" {
+		limiter.apiKeyHeader = defaultAPIKeyHeader
+	}
+	if limiter.idleTTL <= 0 {
+		limiter.idleTTL = defaultIdleTTL
+	}
+	groups, err := newGroups(rateLimitsConfig.Groups)
+	if err != nil {
+		return nil, err
+	}
+	limiter.groups = groups
+	limiter.costs, err = newCosts(rateLimitsConfig.Costs)
+	if err != nil {
+		return nil, err
+	}
+	return limiter, nil
+}
+
+// ValidateGroups checks the configuration of the groups
+func ValidateGroups(groupsConfig []config.RateLimitGroupConfig) error {
+	_, err := newGroups(groupsConfig)
+	return err
+}
+
+func newGroups(groupsConfig []config.RateLimitGroupConfig) ([]*group, error) {
+	groups := make([]*group, 0, len(groupsConfig))
+	names := map[string]bool{}
+	for _, groupConfig := range groupsConfig {
+		key := strings.ToLower(groupConfig.Key)
+		if key == "" {
+			key = KeyIP
+		}
+		if key != KeyIP && key != KeyAPIKey && key != KeyUser && key != KeyTenant {
+			return nil, fmt.Errorf("Invalid key %s of rate limit group %s", groupConfig.Key, groupConfig.Name)
+		}
+		if len(groupConfig.Tenants) > 0 && key != KeyUser && key != KeyTenant {
+			return nil, fmt.Errorf("The rate limit group %s of tenants must be keyed by user or tenant", groupConfig.Name)
+		}
+		if groupConfig.Name == "" || groupConfig.RateLimit <= 0 || groupConfig.Burst <= 0 {
+			return nil, fmt.Errorf("Every rate limit group requires a name, a rate limit and a burst")
+		}
+		if names[groupConfig.Name] {
+			return nil, fmt.Errorf("The rate limit group %s is defined twice", groupConfig.Name)
+		}
+		names[groupConfig.Name] = true
+		groups = append(groups, &group{
+			name:       groupConfig.Name,
+			pathPrefix: groupConfig.PathPrefix,
+			methods:    groupConfig.Methods,
+			key:        key,
+			tenants:    tenants(groupConfig.Tenants),
+			rate:       rate.Limit(groupConfig.RateLimit),
+			burst:      groupConfig.Burst,
+			buckets:    map[string]*bucket{},
+		})
+	}
+	return groups, nil
+}
+
+func tenants(tenantIDs []string) map[string]bool {
+	if len(tenantIDs) == 0 {
+		return nil
+	}
+	tenants := make(map[string]bool, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		tenants[tenantID] = true
+	}
+	return tenants
+}
+
+// SetGroups replaces the groups of the limiter at once, the groups keeping their name, key, rate and burst
+// keep their buckets and counters
+func (limiter *Limiter) SetGroups(groupsConfig []config.RateLimitGroupConfig) error {
+	groups, err := newGroups(groupsConfig)
+	if err != nil {
+		return err
+	}
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+	previous := make(map[string]*group, len(limiter.groups))
+	for _, current := range limiter.groups {
+		previous[current.name] = current
+	}
+	for _, replacement := range groups {
+		current, exists := previous[replacement.name]
+		if exists && current.key == replacement.key && current.rate == replacement.rate && current.burst == replacement.burst {
+			replacement.buckets, replacement.stats = current.buckets, current.stats
+		}
+	}
+	limiter.groups = groups
+	return nil
+}
+
+// Middleware applies the limits of the groups keyed by client IP or API key matching the request
+func (limiter *Limiter) Middleware(ctx *gin.Context) {
+	limiter.limit(ctx, func(group *group) (string, bool) {
+		switch group.key {
+		case KeyAPIKey:
+			if apiKey := ctx.GetHeader(limiter.apiKeyHeader); apiKey != "" {
+				return KeyAPIKey + ":" + apiKey, true
+			}
+			return KeyIP + ":" + ctx.ClientIP(), true
+		case KeyIP:
+			return KeyIP + ":" + ctx.ClientIP(), true
+		}
+		return "", false
+	})
+}
+
+// OnAuthenticated applies the limits of the groups keyed by user or tenant matching the authenticated request,
+// the groups keyed by tenant skip the requests without a tenant
+func (limiter *Limiter) OnAuthenticated(ctx *gin.Context, claims *commonJWT.TokenClaims) {
+	tenantID := tenancy.FromContext(ctx.Request.Context())
+	limiter.limit(ctx, func(group *group) (string, bool) {
+		switch group.key {
+		case KeyUser:
+			return KeyUser + ":" + claims.UserID, true
+		case KeyTenant:
+			return KeyTenant + ":" + tenantID, tenantID != ""
+		}
+		return "", false
+	})
+}
+
+// limit takes the cost of the route from every matching group, rejecting the request without consuming any
+// of them when one group is over its limit
+func (limiter *Limiter) limit(ctx *gin.Context, keyOf func(group *group) (string, bool)) {
+	now := time.Now()
+	limiter.mtx.Lock()
+	reservations := []*rate.Reservation{}
+	matchedGroups := []*group{}
+	matchedBuckets := []*bucket{}
+	matchedKeys := []string{}
+	var retryAfter time.Duration
+	var limitedGroup *group
+	for _, group := range limiter.groups {
+		if !group.matches(ctx.Request) {
+			continue
+		}
+		key, applies := keyOf(group)
+		if !applies {
+			continue
+		}
+		groupBucket := group.bucket(key, now)
+		reservation := groupBucket.limiter.ReserveN(now, limiter.cost(ctx, group))
+		reservations = append(reservations, reservation)
+		matchedGroups = append(matchedGroups, group)
+		matchedBuckets = append(matchedBuckets, groupBucket)
+		matchedKeys = append(matchedKeys, key)
+		if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+			retryAfter, limitedGroup = delay, group
+			break
+		}
+	}
+	var warning *QuotaWarning
+	newWarning := false
+	if limitedGroup != nil {
+		for _, reservation := range reservations {
+			reservation.CancelAt(now)
+		}
+		limitedGroup.stats.Limited++
+	} else {
+		for _, group := range matchedGroups {
+			group.stats.Allowed++
+		}
+		warning, newWarning = limiter.warn(matchedGroups, matchedBuckets, matchedKeys, now)
+	}
+	limiter.mtx.Unlock()
+
+	if limitedGroup == nil {
+		if warning != nil {
+			limiter.sendWarning(ctx, warning, newWarning)
+		}
+		return
+	}
+	if retryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	errors.Abort(ctx, errors.TooManyRequests)
+}
+
+// Stats returns a copy of the counters of every group
+func (limiter *Limiter) Stats() map[string]GroupStats {
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+	stats := make(map[string]GroupStats, len(limiter.groups))
+	for _, group := range limiter.groups {
+		groupStats := group.stats
+		groupStats.Buckets = len(group.buckets)
+		stats[group.name] = groupStats
+	}
+	return stats
+}
+
+// StatsHandler answers with the counters of every rate limited group
+func (limiter *Limiter) StatsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"groups": limiter.Stats()})
+}
+
+// Prune drops the buckets unused for the idle TTL, they are full again by then
+func (limiter *Limiter) Prune(now time.Time) {
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+	for _, group := range limiter.groups {
+		for key, bucket := range group.buckets {
+			if now.Sub(bucket.lastSeen) > limiter.idleTTL {
+				delete(group.buckets, key)
+			}
+		}
+	}
+}
+
+// Job prunes the idle buckets every idle TTL
+func (limiter *Limiter) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "rate_limit_prune",
+		Schedule: scheduler.Every(limiter.idleTTL),
+		Run: func(ctx context.Context, now time.Time) error {
+			limiter.Prune(now)
+			return nil
+		},
+	}
+}
+
+func (group *group) matches(request *http.Request) bool {
+	if !HasPathPrefix(request.URL.Path, group.pathPrefix) {
+		return false
+	}
+	if group.tenants != nil && !group.tenants[tenancy.FromContext(request.Context())] {
+		return false
+	}
+	return group.limitsMethod(request.Method)
+}
+
+func (group *group) limitsMethod(method string) bool {
+	if len(group.methods) == 0 {
+		return true
+	}
+	for _, groupMethod := range group.methods {
+		if strings.EqualFold(groupMethod, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPathPrefix tells whether the cleaned path is the prefix or one of its subpaths, so neither dot segments
+// nor repeated slashes take a request out of its group and /api/v1/payments does not cover /api/v1/paymentsx
+func HasPathPrefix(requestPath, prefix string) bool {
+	cleaned := path.Clean("/" + requestPath)
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || cleaned == prefix || strings.HasPrefix(cleaned, prefix+"/")
+}
+
+func (group *group) bucket(key string, now time.Time) *bucket {
+	groupBucket, exists := group.buckets[key]
+	if !exists {
+		groupBucket = &bucket{limiter: rate.NewLimiter(group.rate, group.burst)}
+		group.buckets[key] = groupBucket
+	}
+	groupBucket.lastSeen = now
+	return groupBucket
+}
@@ -0,0 +1,222 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+)
+
+func TestLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(t *testing.T, groups ...config.RateLimitGroupConfig) (*gin.Engine, *Limiter) {
+		limiter, err := NewLimiter(&config.Config{RateLimits: config.RateLimitsConfig{Enabled: true, Groups: groups}})
+		assert.NoError(t, err)
+		router := gin.New()
+		router.Use(limiter.Middleware)
+		authenticate := func(ctx *gin.Context) {
+			if tenantID := ctx.GetHeader("X-Tenant"); tenantID != "" {
+				ctx.Request = ctx.Request.WithContext(tenancy.ContextWithTenant(ctx.Request.Context(), tenantID))
+			}
+			limiter.OnAuthenticated(ctx, &commonJWT.TokenClaims{UserID: ctx.GetHeader("X-User")})
+			ctx.Next()
+		}
+		router.GET("/api/v1/user", authenticate, func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		router.POST("/api/v1/payments", authenticate, func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		return router, limiter
+	}
+	serve := func(router *gin.Engine, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("Middleware_Should_Reject_Over_The_Burst_With_Retry_After", func(t *testing.T) {
+		router, limiter := newRouter(t, config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 0.5, Burst: 2})
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+		recorder := serve(router, http.MethodGet, "/api/v1/user", nil)
+
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		assert.Equal(t, "2", recorder.Header().Get("Retry-After"))
+		assert.Equal(t, GroupStats{Allowed: 2, Limited: 1, Buckets: 1}, limiter.Stats()["api"])
+	})
+
+	t.Run("Middleware_Should_Keep_A_Bucket_Per_API_Key", func(t *testing.T) {
+		router, _ := newRouter(t, config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyAPIKey, RateLimit: 1, Burst: 1})
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", map[string]string{"X-API-Key": "first"}).Code)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", map[string]string{"X-API-Key": "second"}).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/api/v1/user", map[string]string{"X-API-Key": "first"}).Code)
+	})
+
+	t.Run("OnAuthenticated_Should_Limit_The_Matching_Routes_Per_User", func(t *testing.T) {
+		router, limiter := newRouter(t, config.RateLimitGroupConfig{
+			Name: "payments", PathPrefix: "/api/v1/payments", Methods: []string{"post"}, Key: KeyUser, RateLimit: 1, Burst: 1,
+		})
+		user := map[string]string{"X-User": "user-id"}
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/api/v1/payments", user).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodPost, "/api/v1/payments", user).Code)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/api/v1/payments", map[string]string{"X-User": "other-id"}).Code)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", user).Code)
+		assert.Equal(t, 2, limiter.Stats()["payments"].Buckets)
+	})
+
+	t.Run("OnAuthenticated_Should_Limit_The_Listed_Tenants_Per_Tenant", func(t *testing.T) {
+		router, _ := newRouter(t, config.RateLimitGroupConfig{
+			Name: "acme", PathPrefix: "/api/v1", Key: KeyTenant, Tenants: []string{"acme"}, RateLimit: 1, Burst: 1,
+		})
+		acme := map[string]string{"X-User": "user-id", "X-Tenant": "acme"}
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", acme).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/api/v1/user", map[string]string{"X-User": "other-id", "X-Tenant": "acme"}).Code)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", map[string]string{"X-User": "user-id", "X-Tenant": "globex"}).Code)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", map[string]string{"X-User": "user-id"}).Code)
+	})
+
+	t.Run("NewLimiter_Should_Reject_Tenant_Groups_Keyed_By_IP", func(t *testing.T) {
+		_, err := NewLimiter(&config.Config{RateLimits: config.RateLimitsConfig{Groups: []config.RateLimitGroupConfig{
+			{Name: "acme", Key: KeyIP, Tenants: []string{"acme"}, RateLimit: 1, Burst: 1},
+		}}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Middleware_Should_Not_Consume_The_Other_Groups_When_Limited", func(t *testing.T) {
+		router, limiter := newRouter(t,
+			config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 1, Burst: 5},
+			config.RateLimitGroupConfig{Name: "user", PathPrefix: "/api/v1/user", Key: KeyIP, RateLimit: 1, Burst: 1},
+		)
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+		assert.Equal(t, int64(1), limiter.Stats()["api"].Allowed)
+	})
+
+	t.Run("Prune_Should_Drop_The_Idle_Buckets", func(t *testing.T) {
+		router, limiter := newRouter(t, config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 1, Burst: 1})
+		serve(router, http.MethodGet, "/api/v1/user", nil)
+
+		limiter.Prune(time.Now())
+		assert.Equal(t, 1, limiter.Stats()["api"].Buckets)
+		limiter.Prune(time.Now().Add(defaultIdleTTL + time.Minute))
+		assert.Equal(t, 0, limiter.Stats()["api"].Buckets)
+	})
+
+	t.Run("SetGroups_Should_Replace_The_Groups_Keeping_The_Unchanged_Buckets", func(t *testing.T) {
+		router, limiter := newRouter(t,
+			config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 1, Burst: 1},
+			config.RateLimitGroupConfig{Name: "user", PathPrefix: "/api/v1/user", Key: KeyIP, RateLimit: 1, Burst: 5},
+		)
+		serve(router, http.MethodGet, "/api/v1/user", nil)
+
+		err := limiter.SetGroups([]config.RateLimitGroupConfig{
+			{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 1, Burst: 1},
+			{Name: "user", PathPrefix: "/api/v1/user", Key: KeyIP, RateLimit: 1, Burst: 10},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, GroupStats{Allowed: 1, Buckets: 1}, limiter.Stats()["api"])
+		assert.Equal(t, GroupStats{}, limiter.Stats()["user"])
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+	})
+
+	t.Run("SetGroups_Should_Keep_The_Groups_On_Invalid_Groups", func(t *testing.T) {
+		_, limiter := newRouter(t, config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 1, Burst: 1})
+
+		err := limiter.SetGroups([]config.RateLimitGroupConfig{
+			{Name: "api", Key: KeyIP, RateLimit: 1, Burst: 1},
+			{Name: "api", Key: KeyIP, RateLimit: 2, Burst: 1},
+		})
+
+		assert.Error(t, err)
+		assert.Contains(t, limiter.Stats(), "api")
+		assert.Len(t, limiter.Stats(), 1)
+	})
+
+	t.Run("NewLimiter_Should_Reject_Unknown_Keys", func(t *testing.T) {
+		_, err := NewLimiter(&config.Config{RateLimits: config.RateLimitsConfig{
+			Groups: []config.RateLimitGroupConfig{{Name: "api", Key: "session", RateLimit: 1, Burst: 1}},
+		}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("SetCosts_Should_Take_The_Cost_Of_The_Route_From_The_Buckets", func(t *testing.T) {
+		router, limiter := newRouter(t, config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 0.001, Burst: 5})
+		assert.NoError(t, limiter.SetCosts([]config.RateLimitCostConfig{{Method: "get", Path: "/api/v1/user", Cost: 3}}))
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/api/v1/payments", nil).Code)
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/api/v1/payments", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodPost, "/api/v1/payments", nil).Code)
+	})
+
+	t.Run("SetCosts_Should_Take_The_Whole_Burst_For_Costs_Above_It", func(t *testing.T) {
+		router, limiter := newRouter(t, config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 0.001, Burst: 2})
+		assert.NoError(t, limiter.SetCosts([]config.RateLimitCostConfig{{Method: "GET", Path: "/api/v1/user", Cost: 10}}))
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodPost, "/api/v1/payments", nil).Code)
+	})
+
+	t.Run("SetCosts_Should_Keep_The_Costs_On_Invalid_Costs", func(t *testing.T) {
+		router, limiter := newRouter(t, config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", Key: KeyIP, RateLimit: 0.001, Burst: 2})
+		assert.NoError(t, limiter.SetCosts([]config.RateLimitCostConfig{{Method: "GET", Path: "/api/v1/user", Cost: 2}}))
+
+		assert.Error(t, limiter.SetCosts([]config.RateLimitCostConfig{{Method: "GET", Path: "/api/v1/user", Cost: 0}}))
+		assert.Error(t, limiter.SetCosts([]config.RateLimitCostConfig{{Method: "GET", Path: "api/v1/user", Cost: 1}}))
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, "/api/v1/user", nil).Code)
+	})
+}
+
+func FuzzHasPathPrefix(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"/api/v1/payments", "/api/v1/payments"},
+		{"/api/v1/payments/", "/api/v1/payments/checkout"},
+		{"/api/v1/payments", "/api/v1/paymentsx"},
+		{"/api/v1/payments", "/api/v1//payments"},
+		{"/api/v1/payments", "/api/v1/user/../payments"},
+		{"", "/"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, prefix, requestPath string) {
+		cleaned := path.Clean("/" + requestPath)
+		trimmed := strings.TrimSuffix(prefix, "/")
+		expected := trimmed == ""
+		if !expected {
+			prefixSegments := strings.Split(trimmed, "/")
+			pathSegments := strings.Split(cleaned, "/")
+			expected = len(pathSegments) >= len(prefixSegments)
+			for index := 0; expected && index < len(prefixSegments); index++ {
+				expected = pathSegments[index] == prefixSegments[index]
+			}
+		}
+
+		if HasPathPrefix(requestPath, prefix) != expected {
+			t.Fatalf("The path %q grouped under %q should be %t", requestPath, prefix, expected)
+		}
+		if HasPathPrefix(requestPath, prefix) != HasPathPrefix(cleaned, prefix) {
+			t.Fatalf("The path %q and its cleaned path %q are grouped apart under %q", requestPath, cleaned, prefix)
+		}
+	})
+}
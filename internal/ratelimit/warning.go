@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+)
+
+// QuotaWarningDetailType is the detail type of the event emitted when a client nears the limit of a group
+const QuotaWarningDetailType = "ratelimit.quota.warning"
+
+// Headers warning the clients nearing the limit of a group before their requests are rejected
+const (
+	WarningHeader   = "X-RateLimit-Warning"
+	LimitHeader     = "X-RateLimit-Limit"
+	RemainingHeader = "X-RateLimit-Remaining"
+)
+
+const publishTimeout = 5 * time.Second
+
+// QuotaWarning is the event emitted once when a client crosses the warning percentage of a group, the subject
+// is the user or the tenant of the groups keyed by them and is left out for the client IPs and API keys
+type QuotaWarning struct {
+	Group       string    `json:"group"`
+	SubjectType string    `json:"subjectType"`
+	SubjectID   string    `json:"subjectID,omitempty"`
+	Limit       int       `json:"limit"`
+	Remaining   int       `json:"remaining"`
+	Route       string    `json:"route"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// UsePublisher emits the quota warnings through the publisher
+func (limiter *Limiter) UsePublisher(publisher events.Publisherer) {
+	limiter.publisher = publisher
+}
+
+// warn returns the warning of the matched group with the fewest remaining requests once its usage reaches the
+// warning percentage, and whether its bucket just crossed it. It runs under the limiter lock
+func (limiter *Limiter) warn(groups []*group, buckets []*bucket, keys []string, now time.Time) (*QuotaWarning, bool) {
+	if limiter.warningPercentage <= 0 {
+		return nil, false
+	}
+	var warning *QuotaWarning
+	var warnedBucket *bucket
+	for index, group := range groups {
+		groupBucket := buckets[index]
+		remaining := int(math.Max(0, math.Floor(groupBucket.limiter.TokensAt(now))))
+		used := float64(group.burst-remaining) * 100 / float64(group.burst)
+		if used < limiter.warningPercentage {
+			groupBucket.warned = false
+			continue
+		}
+		if warning != nil && remaining >= warning.Remaining {
+			continue
+		}
+		subjectType, subjectID, _ := strings.Cut(keys[index], ":")
+		if subjectType != KeyUser && subjectType != KeyTenant {
+			subjectID = ""
+		}
+		warning = &QuotaWarning{
+			Group:       group.name,
+			SubjectType: subjectType,
+			SubjectID:   subjectID,
+			Limit:       group.burst,
+			Remaining:   remaining,
+			Timestamp:   now,
+		}
+		warnedBucket = groupBucket
+	}
+	if warning == nil {
+		return nil, false
+	}
+	newWarning := !warnedBucket.warned
+	warnedBucket.warned = true
+	return warning, newWarning
+}
+
+// sendWarning answers the warning headers and publishes the warning the first time the bucket crossed the percentage
+func (limiter *Limiter) sendWarning(ctx *gin.Context, warning *QuotaWarning, newWarning bool) {
+	ctx.Header(WarningHeader, warning.Group)
+	ctx.Header(LimitHeader, strconv.Itoa(warning.Limit))
+	ctx.Header(RemainingHeader, strconv.Itoa(warning.Remaining))
+	if !newWarning || limiter.publisher == nil {
+		return
+	}
+	warning.Route = ctx.FullPath()
+	logger, _ := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	go func() {
+		publishContext, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		defer cancel()
+		if err := limiter.publisher.Publish(publishContext, QuotaWarningDetailType, *warning); err != nil && logger != nil {
+			logger.Error(err, "Could not publish the quota warning")
+		}
+	}()
+}
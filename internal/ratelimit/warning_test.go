@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type recordingPublisher struct {
+	warnings chan QuotaWarning
+}
+
+func (publisher *recordingPublisher) Publish(ctx context.Context, detailType string, detail interface{}) error {
+	publisher.warnings <- detail.(QuotaWarning)
+	return nil
+}
+
+func TestQuotaWarning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(t *testing.T, warningPercentage float64, groups ...config.RateLimitGroupConfig) (*gin.Engine, *recordingPublisher) {
+		limiter, err := NewLimiter(&config.Config{RateLimits: config.RateLimitsConfig{
+			Enabled:           true,
+			WarningPercentage: warningPercentage,
+			Groups:            groups,
+		}})
+		assert.NoError(t, err)
+		publisher := &recordingPublisher{warnings: make(chan QuotaWarning, 10)}
+		limiter.UsePublisher(publisher)
+		router := gin.New()
+		router.Use(limiter.Middleware)
+		router.GET("/api/v1/user", func(ctx *gin.Context) {
+			limiter.OnAuthenticated(ctx, &commonJWT.TokenClaims{UserID: "user-id"})
+			ctx.Next()
+		}, func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		return router, publisher
+	}
+	serve := func(router *gin.Engine) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/user", nil))
+		return recorder
+	}
+	received := func(publisher *recordingPublisher) []QuotaWarning {
+		warnings := []QuotaWarning{}
+		for {
+			select {
+			case warning := <-publisher.warnings:
+				warnings = append(warnings, warning)
+			case <-time.After(100 * time.Millisecond):
+				return warnings
+			}
+		}
+	}
+
+	t.Run("Middleware_Should_Warn_Once_The_Warning_Percentage_Is_Used", func(t *testing.T) {
+		router, publisher := newRouter(t, 50, config.RateLimitGroupConfig{Name: "user", Key: KeyUser, RateLimit: 0.001, Burst: 4})
+
+		first := serve(router)
+		second := serve(router)
+		third := serve(router)
+
+		assert.Empty(t, first.Header().Get(WarningHeader))
+		assert.Equal(t, "user", second.Header().Get(WarningHeader))
+		assert.Equal(t, "4", second.Header().Get(LimitHeader))
+		assert.Equal(t, "2", second.Header().Get(RemainingHeader))
+		assert.Equal(t, "1", third.Header().Get(RemainingHeader))
+		warnings := received(publisher)
+		assert.Len(t, warnings, 1)
+		assert.Equal(t, "user", warnings[0].Group)
+		assert.Equal(t, KeyUser, warnings[0].SubjectType)
+		assert.Equal(t, "user-id", warnings[0].SubjectID)
+		assert.Equal(t, "/api/v1/user", warnings[0].Route)
+	})
+
+	t.Run("Middleware_Should_Leave_Out_The_Client_IP_Of_The_Warning", func(t *testing.T) {
+		router, publisher := newRouter(t, 50, config.RateLimitGroupConfig{Name: "api", Key: KeyIP, RateLimit: 0.001, Burst: 2})
+
+		assert.Equal(t, "api", serve(router).Header().Get(WarningHeader))
+
+		warnings := received(publisher)
+		assert.Len(t, warnings, 1)
+		assert.Equal(t, KeyIP, warnings[0].SubjectType)
+		assert.Empty(t, warnings[0].SubjectID)
+	})
+
+	t.Run("Middleware_Should_Not_Warn_Without_A_Warning_Percentage", func(t *testing.T) {
+		router, publisher := newRouter(t, 0, config.RateLimitGroupConfig{Name: "api", Key: KeyIP, RateLimit: 0.001, Burst: 1})
+
+		assert.Empty(t, serve(router).Header().Get(WarningHeader))
+		assert.Empty(t, received(publisher))
+	})
+
+	t.Run("NewLimiter_Should_Reject_An_Invalid_Warning_Percentage", func(t *testing.T) {
+		_, err := NewLimiter(&config.Config{RateLimits: config.RateLimitsConfig{WarningPercentage: 100}})
+
+		assert.Error(t, err)
+	})
+}
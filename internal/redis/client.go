@@ -0,0 +1,198 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// ErrNil is returned when redis replies with a nil value
+var ErrNil = errors.New("Redis nil reply")
+
+// defaultPoolSize is the number of connections of a client when the configuration does not set it
+const defaultPoolSize = 10
+
+// Clienter is the interface for the redis client
+type Clienter interface {
+	Do(ctx context.Context, args ...string) (interface{}, error)
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	Close() error
+}
+
+// Client is a minimal RESP client with a pool of connections, a command waits for a free connection
+// once the pool size is reached
+type Client struct {
+	address  string
+	password string
+	db       int
+	timeout  time.Duration
+	slots    chan struct{}
+	idle     []*connection
+	closed   bool
+	mtx      sync.Mutex
+}
+
+// connection is a connection of the pool with its buffered reader
+type connection struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+var _ Clienter = &Client{}
+
+// NewClient creates a redis client for the configured address; the connections are opened lazily
+func NewClient(configurations *config.Config) *Client {
+	timeout := configurations.Redis.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+	poolSize := configurations.Redis.PoolSize
+	if poolSize <= 0 {
+		poolSize = defaultPoolSize
+	}
+	return &Client{
+		address:  configurations.Redis.Address,
+		password: configurations.Redis.Password,
+		db:       configurations.Redis.DB,
+		timeout:  timeout,
+		slots:    make(chan struct{}, poolSize),
+	}
+}
+
+// Do sends a command on a connection of the pool and returns its reply, the connection is dropped
+// when the command fails on anything but a redis error
+func (client *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	connection, err := client.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := connection.roundTrip(ctx, client.timeout, args)
+	var redisError Error
+	client.release(connection, err == nil || errors.As(err, &redisError))
+	return reply, err
+}
+
+// Get returns the string value of a key or ErrNil when it does not exist
+func (client *Client) Get(ctx context.Context, key string) (string, error) {
+	reply, err := client.Do(ctx, "GET", key)
+	if err != nil {
+		return "", err
+	}
+	return String(reply)
+}
+
+// Set stores a value with an optional ttl
+func (client *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := client.Do(ctx, args...)
+	return err
+}
+
+// Del deletes the given keys
+func (client *Client) Del(ctx context.Context, keys ...string) error {
+	_, err := client.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Close closes the idle connections, the connections in use are closed once their command is answered
+func (client *Client) Close() error {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	client.closed = true
+	var errs []error
+	for _, connection := range client.idle {
+		errs = append(errs, connection.conn.Close())
+	}
+	client.idle = nil
+	return errors.Join(errs...)
+}
+
+// acquire takes a slot of the pool, waiting for one until the context is done, and an idle connection
+// or else a new one
+func (client *Client) acquire(ctx context.Context) (*connection, error) {
+	select {
+	case client.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("Could not get a redis connection: %v", ctx.Err())
+	}
+	client.mtx.Lock()
+	if client.closed {
+		client.mtx.Unlock()
+		<-client.slots
+		return nil, fmt.Errorf("The redis client is closed")
+	}
+	if count := len(client.idle); count > 0 {
+		connection := client.idle[count-1]
+		client.idle = client.idle[:count-1]
+		client.mtx.Unlock()
+		return connection, nil
+	}
+	client.mtx.Unlock()
+	connection, err := client.connect(ctx)
+	if err != nil {
+		<-client.slots
+		return nil, err
+	}
+	return connection, nil
+}
+
+// release gives the connection back to the pool, or closes it when it is broken or the client is closed
+func (client *Client) release(connection *connection, healthy bool) {
+	client.mtx.Lock()
+	if healthy && !client.closed {
+		client.idle = append(client.idle, connection)
+	} else {
+		connection.conn.Close()
+	}
+	client.mtx.Unlock()
+	<-client.slots
+}
+
+func (client *Client) connect(ctx context.Context) (*connection, error) {
+	dialer := net.Dialer{Timeout: client.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", client.address)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to redis at %s: %v", client.address, err)
+	}
+	connection := &connection{conn: conn, reader: bufio.NewReader(conn)}
+
+	if client.password != "" {
+		if _, err := connection.roundTrip(ctx, client.timeout, []string{"AUTH", client.password}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Could not authenticate with redis: %v", err)
+		}
+	}
+	if client.db != 0 {
+		if _, err := connection.roundTrip(ctx, client.timeout, []string{"SELECT", strconv.Itoa(client.db)}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Could not select redis database %d: %v", client.db, err)
+		}
+	}
+	return connection, nil
+}
+
+func (connection *connection) roundTrip(ctx context.Context, timeout time.Duration, args []string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := connection.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := connection.conn.Write(encodeCommand(args)); err != nil {
+		return nil, fmt.Errorf("Could not write redis command: %v", err)
+	}
+	return readReply(connection.reader)
+}
@@ -0,0 +1,139 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func startFakeServer(t *testing.T, replies map[string]string) (string, *atomic.Int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	connections := &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections.Add(1)
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					reply, err := readReply(reader)
+					if err != nil {
+						return
+					}
+					args, _ := Strings(reply)
+					conn.Write([]byte(replies[strings.Join(args, " ")]))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), connections
+}
+
+func TestClient(t *testing.T) {
+	t.Run("Get_Set_Del_Success", func(t *testing.T) {
+		address, _ := startFakeServer(t, map[string]string{
+			"SET key value PX 60000": "+OK\r\n",
+			"GET key":                "$5\r\nvalue\r\n",
+			"GET missing":            "$-1\r\n",
+			"DEL key":                ":1\r\n",
+		})
+		client := NewClient(&config.Config{Redis: config.RedisConfig{Address: address}})
+		defer client.Close()
+
+		assert.NoError(t, client.Set(context.Background(), "key", "value", time.Minute))
+		value, err := client.Get(context.Background(), "key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+		_, err = client.Get(context.Background(), "missing")
+		assert.Equal(t, ErrNil, err)
+		assert.NoError(t, client.Del(context.Background(), "key"))
+	})
+
+	t.Run("Do_Server_Error", func(t *testing.T) {
+		address, _ := startFakeServer(t, map[string]string{
+			"INCR key": "-ERR value is not an integer\r\n",
+		})
+		client := NewClient(&config.Config{Redis: config.RedisConfig{Address: address}})
+		defer client.Close()
+
+		_, err := client.Do(context.Background(), "INCR", "key")
+
+		assert.Error(t, err)
+		assert.Equal(t, "ERR value is not an integer", err.Error())
+	})
+
+	t.Run("Subscribe_Should_Hand_The_Messages_Until_The_Context_Is_Done", func(t *testing.T) {
+		address, _ := startFakeServer(t, map[string]string{
+			"SUBSCRIBE revocations": "*3\r\n$9\r\nsubscribe\r\n$11\r\nrevocations\r\n:1\r\n" +
+				"*3\r\n$7\r\nmessage\r\n$11\r\nrevocations\r\n$5\r\nhello\r\n",
+		})
+		client := NewClient(&config.Config{Redis: config.RedisConfig{Address: address}})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		messages := []string{}
+		err := client.Subscribe(ctx, func(channel, message string) {
+			messages = append(messages, channel+" "+message)
+			cancel()
+		}, "revocations")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"revocations hello"}, messages)
+	})
+
+	t.Run("Do_Should_Share_The_Pooled_Connections", func(t *testing.T) {
+		address, connections := startFakeServer(t, map[string]string{"PING": "+PONG\r\n"})
+		client := NewClient(&config.Config{Redis: config.RedisConfig{Address: address, PoolSize: 2}})
+		defer client.Close()
+
+		group := sync.WaitGroup{}
+		for index := 0; index < 20; index++ {
+			group.Add(1)
+			go func() {
+				defer group.Done()
+				reply, err := client.Do(context.Background(), "PING")
+				assert.NoError(t, err)
+				assert.Equal(t, "PONG", reply)
+			}()
+		}
+		group.Wait()
+
+		assert.LessOrEqual(t, connections.Load(), int32(2))
+		assert.LessOrEqual(t, len(client.idle), 2)
+	})
+
+	t.Run("Do_Should_Wait_For_A_Free_Connection_Until_The_Context_Is_Done", func(t *testing.T) {
+		client := NewClient(&config.Config{Redis: config.RedisConfig{Address: "127.0.0.1:1", PoolSize: 1}})
+		client.slots <- struct{}{}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Do(ctx, "PING")
+
+		assert.EqualError(t, err, "Could not get a redis connection: context deadline exceeded")
+	})
+
+	t.Run("Do_Connection_Error", func(t *testing.T) {
+		client := NewClient(&config.Config{Redis: config.RedisConfig{Address: "127.0.0.1:1"}})
+
+		_, err := client.Do(context.Background(), "PING")
+
+		assert.Error(t, err)
+	})
+}
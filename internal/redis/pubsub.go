@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Publish sends a message to the subscribers of the channel
+func (client *Client) Publish(ctx context.Context, channel, message string) error {
+	_, err := client.Do(ctx, "PUBLISH", channel, message)
+	return err
+}
+
+// Subscribe listens to the channels on a dedicated connection, handing every message to the handler
+// until the context is done or the connection fails
+func (client *Client) Subscribe(ctx context.Context, handle func(channel, message string), channels ...string) error {
+	subscriber, err := client.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer subscriber.conn.Close()
+	conn, reader := subscriber.conn, subscriber.reader
+
+	if err := conn.SetDeadline(time.Now().Add(client.timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(encodeCommand(append([]string{"SUBSCRIBE"}, channels...))); err != nil {
+		return fmt.Errorf("Could not write redis command: %v", err)
+	}
+	for range channels {
+		if _, err := readReply(reader); err != nil {
+			return fmt.Errorf("Could not subscribe to redis channels: %v", err)
+		}
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	for {
+		reply, err := readReply(reader)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("Redis subscription failed: %v", err)
+		}
+		values, err := Strings(reply)
+		if err != nil || len(values) != 3 || values[0] != "message" {
+			continue
+		}
+		handle(values[1], values[2])
+	}
+}
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Error is an error reply sent by the redis server
+type Error string
+
+func (err Error) Error() string {
+	return string(err)
+}
+
+func encodeCommand(args []string) []byte {
+	var builder strings.Builder
+	builder.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		builder.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		builder.WriteString(arg)
+		builder.WriteString("\r\n")
+	}
+	return []byte(builder.String())
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("Could not read redis reply: %v", err)
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("Malformed redis reply: %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// readReply parses a RESP2 reply into string, int64, []interface{} or nil values
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("Empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Malformed redis bulk length: %v", err)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		buffer := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			return nil, fmt.Errorf("Could not read redis bulk reply: %v", err)
+		}
+		return string(buffer[:length]), nil
+	case '*':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Malformed redis array length: %v", err)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, length)
+		for index := range values {
+			values[index], err = readReply(reader)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("Unknown redis reply type: %q", line[0])
+	}
+}
+
+// String converts a reply into a string, returning ErrNil for nil replies
+func String(reply interface{}) (string, error) {
+	switch value := reply.(type) {
+	case nil:
+		return "", ErrNil
+	case string:
+		return value, nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	default:
+		return "", fmt.Errorf("Unexpected redis reply type %T", reply)
+	}
+}
+
+// Int64 converts a reply into an int64, returning ErrNil for nil replies
+func Int64(reply interface{}) (int64, error) {
+	switch value := reply.(type) {
+	case nil:
+		return 0, ErrNil
+	case int64:
+		return value, nil
+	case string:
+		return strconv.ParseInt(value, 10, 64)
+	default:
+		return 0, fmt.Errorf("Unexpected redis reply type %T", reply)
+	}
+}
+
+// Strings converts an array reply into a slice of strings
+func Strings(reply interface{}) ([]string, error) {
+	switch values := reply.(type) {
+	case nil:
+		return nil, ErrNil
+	case []interface{}:
+		result := make([]string, 0, len(values))
+		for _, value := range values {
+			str, err := String(value)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, str)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("Unexpected redis reply type %T", reply)
+	}
+}
@@ -0,0 +1,142 @@
+package reference
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/reference/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_reference"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// ServiceClienter is an interface for the reference data service client
+type ServiceClienter interface {
+	ListCountries(ctx *gin.Context)
+	ListLocales(ctx *gin.Context)
+	ListPlans(ctx *gin.Context)
+	Job() scheduler.Job
+}
+
+// ServiceClient is a struct for the reference data service client
+type ServiceClient struct {
+	countries       *routes.Dataset
+	locales         *routes.Dataset
+	plans           *routes.Dataset
+	policy          routes.CachePolicy
+	refreshInterval time.Duration
+	logger          commonLogger.Loggerer
+}
+
+var _ ServiceClienter = &ServiceClient{}
+
+// InitServiceClient initializes the reference data service client
+func InitServiceClient(centralConfig *commonConfig.Config, configurations *config.Config) (pb_reference.ReferenceServiceClient, error) {
+	grpcServiceAddress := fmt.Sprintf(
+		"%s:%s",
+		configurations.ReferenceService.Host,
+		configurations.ReferenceService.Port,
+	)
+
+	fmt.Println("Connecting to reference data service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection("reference", grpcServiceAddress, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc reference data service: %v", err)
+	}
+
+	regionalConnection, err := region.RouteConnection("reference", clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConnection, err := tenancy.RouteConnection("reference", regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	routedConnection, err := versioning.RouteConnection("reference", tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tracedConnection := tracing.TraceConnection("reference", routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection("reference", tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection("reference", instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection("reference", retryingConnection, configurations)
+	hookedConnection := hooks.HookConnection("reference", breakerConnection, configurations)
+
+	return pb_reference.NewReferenceServiceClient(hookedConnection), nil
+}
+
+// NewServiceClient creates the reference data datasets loaded from the given client
+func NewServiceClient(client pb_reference.ReferenceServiceClient, configurations *config.Config) *ServiceClient {
+	return &ServiceClient{
+		countries: routes.NewDataset("countries", func(ctx context.Context) (interface{}, error) {
+			return client.ListCountries(ctx, &pb_reference.ListRequest{})
+		}),
+		locales: routes.NewDataset("locales", func(ctx context.Context) (interface{}, error) {
+			return client.ListLocales(ctx, &pb_reference.ListRequest{})
+		}),
+		plans: routes.NewDataset("plans", func(ctx context.Context) (interface{}, error) {
+			return client.ListPlans(ctx, &pb_reference.ListRequest{})
+		}),
+		policy: routes.CachePolicy{
+			MaxAge:     configurations.ReferenceService.MaxAge,
+			EdgeMaxAge: configurations.ReferenceService.EdgeMaxAge,
+		},
+		refreshInterval: configurations.ReferenceService.RefreshInterval,
+		logger:          commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}
+}
+
+// ListCountries redirects request to the reference data route of the countries
+func (service *ServiceClient) ListCountries(ctx *gin.Context) {
+	routes.ServeDataset(ctx, service.countries, service.policy)
+}
+
+// ListLocales redirects request to the reference data route of the locales
+func (service *ServiceClient) ListLocales(ctx *gin.Context) {
+	routes.ServeDataset(ctx, service.locales, service.policy)
+}
+
+// ListPlans redirects request to the reference data route of the plans
+func (service *ServiceClient) ListPlans(ctx *gin.Context) {
+	routes.ServeDataset(ctx, service.plans, service.policy)
+}
+
+// Refresh reloads every dataset, keeping the last good snapshot of the ones failing
+func (service *ServiceClient) Refresh(ctx context.Context) {
+	for _, dataset := range []*routes.Dataset{service.countries, service.locales, service.plans} {
+		refreshContext, cancel := context.WithTimeout(ctx, service.refreshInterval)
+		if _, err := dataset.Refresh(refreshContext); err != nil {
+			service.logger.Error(err, fmt.Sprintf("Could not refresh the %s reference data", dataset.Name()))
+		}
+		cancel()
+	}
+}
+
+// Job refreshes the datasets on start and then on every interval
+func (service *ServiceClient) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:       "reference_refresh",
+		Schedule:   scheduler.Every(service.refreshInterval),
+		RunOnStart: true,
+		Run: func(ctx context.Context, now time.Time) error {
+			service.Refresh(ctx)
+			return nil
+		},
+	}
+}
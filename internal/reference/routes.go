@@ -0,0 +1,33 @@
+package reference
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// RegisterRoutes registers the public reference data routes and starts their background refresh
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+) (*ServiceClient, error) {
+	if configurations.ReferenceService.RefreshInterval <= 0 {
+		return nil, fmt.Errorf("The reference data refresh interval must be positive")
+	}
+	client, err := InitServiceClient(centralConfig, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not initialize reference data service client: %v", err)
+	}
+	service := NewServiceClient(client, configurations)
+
+	referenceRoutes := api.Group("/reference")
+	referenceRoutes.GET("/countries", service.ListCountries)
+	referenceRoutes.GET("/locales", service.ListLocales)
+	referenceRoutes.GET("/plans", service.ListPlans)
+
+	return service, nil
+}
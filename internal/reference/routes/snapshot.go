@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Fetcher loads a reference data set from the upstream
+type Fetcher func(ctx context.Context) (interface{}, error)
+
+// Snapshot is the encoded content of a data set with its strong ETag
+type Snapshot struct {
+	Body      []byte
+	ETag      string
+	UpdatedAt time.Time
+}
+
+// CachePolicy sets how long clients and edges may reuse the reference data without revalidating
+type CachePolicy struct {
+	MaxAge     time.Duration
+	EdgeMaxAge time.Duration
+}
+
+// Dataset keeps the last good snapshot of a reference data set, refreshed in the background
+type Dataset struct {
+	name     string
+	fetch    Fetcher
+	snapshot *Snapshot
+	loading  sync.Mutex
+	mtx      sync.RWMutex
+}
+
+// NewDataset creates a dataset loaded by the given fetcher
+func NewDataset(name string, fetch Fetcher) *Dataset {
+	return &Dataset{name: name, fetch: fetch}
+}
+
+// Name returns the name of the dataset
+func (dataset *Dataset) Name() string {
+	return dataset.name
+}
+
+// Snapshot returns the current snapshot, or nil before the first successful refresh
+func (dataset *Dataset) Snapshot() *Snapshot {
+	dataset.mtx.RLock()
+	defer dataset.mtx.RUnlock()
+	return dataset.snapshot
+}
+
+// Refresh fetches the data set and replaces the snapshot, the previous one is kept when the upstream fails
+func (dataset *Dataset) Refresh(ctx context.Context) (*Snapshot, error) {
+	dataset.loading.Lock()
+	defer dataset.loading.Unlock()
+	content, err := dataset.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("Could not encode the %s reference data: %v", dataset.name, err)
+	}
+	digest := sha256.Sum256(body)
+	snapshot := &Snapshot{
+		Body:      body,
+		ETag:      fmt.Sprintf("\"%s\"", hex.EncodeToString(digest[:16])),
+		UpdatedAt: time.Now(),
+	}
+	dataset.mtx.Lock()
+	// An unchanged body keeps its snapshot so the ETag and Last-Modified stay stable
+	if dataset.snapshot != nil && dataset.snapshot.ETag == snapshot.ETag {
+		snapshot = dataset.snapshot
+	}
+	dataset.snapshot = snapshot
+	dataset.mtx.Unlock()
+	return snapshot, nil
+}
+
+// ServeDataset answers with the snapshot of the dataset, or 304 when the client already has it
+func ServeDataset(ctx *gin.Context, dataset *Dataset, policy CachePolicy) {
+	snapshot := dataset.Snapshot()
+	if snapshot == nil {
+		// Only the first requests before the background refresh succeeds reach the upstream
+		loaded, err := dataset.Refresh(ctx.Request.Context())
+		if err != nil {
+			if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil {
+				logger.Error(err, fmt.Sprintf("Could not load the %s reference data", dataset.Name()))
+			}
+			errors.HandleError(ctx, err)
+			return
+		}
+		snapshot = loaded
+	}
+
+	ctx.Header("Cache-Control", fmt.Sprintf(
+		"public, max-age=%d, s-maxage=%d, stale-while-revalidate=%d, stale-if-error=%d",
+		int(policy.MaxAge.Seconds()),
+		int(policy.EdgeMaxAge.Seconds()),
+		int(policy.MaxAge.Seconds()),
+		int(policy.EdgeMaxAge.Seconds()),
+	))
+	ctx.Header("ETag", snapshot.ETag)
+	ctx.Header("Last-Modified", snapshot.UpdatedAt.UTC().Format(http.TimeFormat))
+	if matchesETag(ctx.GetHeader("If-None-Match"), snapshot.ETag) {
+		ctx.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", snapshot.Body)
+}
+
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
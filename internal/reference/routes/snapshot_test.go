@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUpstream struct {
+	content interface{}
+	err     error
+	calls   int
+}
+
+func (upstream *fakeUpstream) fetch(ctx context.Context) (interface{}, error) {
+	upstream.calls++
+	return upstream.content, upstream.err
+}
+
+func serve(dataset *Dataset, ifNoneMatch string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/countries", func(ctx *gin.Context) {
+		ServeDataset(ctx, dataset, CachePolicy{MaxAge: time.Hour, EdgeMaxAge: 24 * time.Hour})
+	})
+	request := httptest.NewRequest(http.MethodGet, "/countries", nil)
+	if ifNoneMatch != "" {
+		request.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	return w
+}
+
+func TestDataset(t *testing.T) {
+	t.Run("ServeDataset_Should_Load_Once_And_Answer_Not_Modified", func(t *testing.T) {
+		upstream := &fakeUpstream{content: map[string][]string{"countries": {"ES", "GB"}}}
+		dataset := NewDataset("countries", upstream.fetch)
+
+		first := serve(dataset, "")
+		second := serve(dataset, first.Header().Get("ETag"))
+
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.JSONEq(t, `{"countries":["ES","GB"]}`, first.Body.String())
+		assert.Equal(t, "public, max-age=3600, s-maxage=86400, stale-while-revalidate=3600, stale-if-error=86400", first.Header().Get("Cache-Control"))
+		assert.Equal(t, http.StatusNotModified, second.Code)
+		assert.Empty(t, second.Body.String())
+		assert.Equal(t, 1, upstream.calls)
+	})
+
+	t.Run("Refresh_Should_Keep_ETag_Of_Unchanged_Content", func(t *testing.T) {
+		upstream := &fakeUpstream{content: []string{"en-GB"}}
+		dataset := NewDataset("locales", upstream.fetch)
+
+		first, err := dataset.Refresh(context.Background())
+		assert.NoError(t, err)
+		second, err := dataset.Refresh(context.Background())
+		assert.NoError(t, err)
+		upstream.content = []string{"en-GB", "es-ES"}
+		changed, err := dataset.Refresh(context.Background())
+		assert.NoError(t, err)
+
+		assert.True(t, first == second)
+		assert.NotEqual(t, first.ETag, changed.ETag)
+	})
+
+	t.Run("Refresh_Should_Keep_Last_Good_Snapshot_On_Upstream_Failure", func(t *testing.T) {
+		upstream := &fakeUpstream{content: []string{"basic"}}
+		dataset := NewDataset("plans", upstream.fetch)
+		snapshot, err := dataset.Refresh(context.Background())
+		assert.NoError(t, err)
+		upstream.err = errors.New("unavailable")
+
+		_, err = dataset.Refresh(context.Background())
+
+		assert.Error(t, err)
+		assert.Equal(t, snapshot, dataset.Snapshot())
+		assert.Equal(t, http.StatusOK, serve(dataset, "").Code)
+	})
+
+	t.Run("ServeDataset_Should_Fail_Without_Any_Snapshot", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		loggerMock.EXPECT().Error(gomock.Any(), "Could not load the plans reference data")
+		dataset := NewDataset("plans", (&fakeUpstream{err: errors.New("unavailable")}).fetch)
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/plans", nil)
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+
+		ServeDataset(ctx, dataset, CachePolicy{})
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,49 @@
+package region
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// OverrideHeader selects the preferred region of the upstream calls of a request when overrides are allowed
+const OverrideHeader = "X-Preferred-Region"
+
+type regionContextKey struct{}
+
+// ContextWithRegion returns a context preferring the upstream endpoints of the region
+func ContextWithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionContextKey{}, region)
+}
+
+// FromContext returns the preferred region of the context or an empty string when there is none
+func FromContext(ctx context.Context) string {
+	region, _ := ctx.Value(regionContextKey{}).(string)
+	return region
+}
+
+// Selector records the region override of the requests, so testers can reach the backends of another region
+type Selector struct {
+	regions map[string]bool
+}
+
+// NewSelector creates the selector of the region of the gateway and the regions of the configured endpoints
+func NewSelector(configurations *config.Config) *Selector {
+	selector := &Selector{regions: map[string]bool{strings.ToLower(configurations.Region.Name): true}}
+	for _, endpoint := range configurations.Region.Endpoints {
+		selector.regions[strings.ToLower(endpoint.Region)] = true
+	}
+	return selector
+}
+
+// Middleware prefers the region of the override header when it is a known region, other values are ignored
+func (selector *Selector) Middleware(ctx *gin.Context) {
+	region := strings.ToLower(strings.TrimSpace(ctx.GetHeader(OverrideHeader)))
+	if region != "" && selector.regions[region] {
+		ctx.Request = ctx.Request.WithContext(ContextWithRegion(ctx.Request.Context(), region))
+	}
+	ctx.Next()
+}
@@ -0,0 +1,135 @@
+package region
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type fakeConnection struct {
+	name    string
+	err     error
+	invoked *[]string
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	*connection.invoked = append(*connection.invoked, connection.name)
+	return connection.err
+}
+
+func (connection *fakeConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	*connection.invoked = append(*connection.invoked, connection.name)
+	return nil, connection.err
+}
+
+func newTestConnection(invoked *[]string, now *time.Time, local, remote *fakeConnection) *RegionalConnection {
+	local.invoked, remote.invoked = invoked, invoked
+	return &RegionalConnection{
+		localRegion: "eu-west-1",
+		endpoints: []*endpoint{
+			{region: "eu-west-1", connection: local},
+			{region: "us-east-1", connection: remote},
+		},
+		cooldown: time.Minute,
+		now:      func() time.Time { return *now },
+	}
+}
+
+func TestRegionalConnection(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	t.Run("Invoke_Should_Prefer_The_Local_Region", func(t *testing.T) {
+		invoked, now := []string{}, time.Now()
+		connection := newTestConnection(&invoked, &now, &fakeConnection{name: "local"}, &fakeConnection{name: "remote"})
+
+		assert.NoError(t, connection.Invoke(context.Background(), "/method", nil, nil))
+		assert.Equal(t, []string{"local"}, invoked)
+	})
+
+	t.Run("Invoke_Should_Prefer_The_Region_Of_The_Context", func(t *testing.T) {
+		invoked, now := []string{}, time.Now()
+		connection := newTestConnection(&invoked, &now, &fakeConnection{name: "local"}, &fakeConnection{name: "remote"})
+
+		assert.NoError(t, connection.Invoke(ContextWithRegion(context.Background(), "us-east-1"), "/method", nil, nil))
+		assert.Equal(t, []string{"remote"}, invoked)
+	})
+
+	t.Run("Invoke_Should_Fail_Over_Across_Regions_Until_The_Cooldown_Ends", func(t *testing.T) {
+		invoked, now := []string{}, time.Now()
+		local := &fakeConnection{name: "local", err: unavailable}
+		connection := newTestConnection(&invoked, &now, local, &fakeConnection{name: "remote"})
+
+		assert.NoError(t, connection.Invoke(context.Background(), "/method", nil, nil))
+		assert.NoError(t, connection.Invoke(context.Background(), "/method", nil, nil))
+		assert.Equal(t, []string{"local", "remote", "remote"}, invoked)
+
+		local.err = nil
+		now = now.Add(2 * time.Minute)
+		assert.NoError(t, connection.Invoke(context.Background(), "/method", nil, nil))
+		assert.Equal(t, "local", invoked[len(invoked)-1])
+	})
+
+	t.Run("Invoke_Should_Not_Fail_Over_Other_Errors", func(t *testing.T) {
+		invoked, now := []string{}, time.Now()
+		notFound := status.Error(codes.NotFound, "not found")
+		connection := newTestConnection(&invoked, &now, &fakeConnection{name: "local", err: notFound}, &fakeConnection{name: "remote"})
+
+		assert.Equal(t, notFound, connection.Invoke(context.Background(), "/method", nil, nil))
+		assert.Equal(t, []string{"local"}, invoked)
+	})
+
+	t.Run("NewStream_Should_Return_The_Last_Error_When_Every_Region_Is_Unavailable", func(t *testing.T) {
+		invoked, now := []string{}, time.Now()
+		connection := newTestConnection(&invoked, &now, &fakeConnection{name: "local", err: unavailable}, &fakeConnection{name: "remote", err: unavailable})
+
+		_, err := connection.NewStream(context.Background(), &grpc.StreamDesc{}, "/method")
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, []string{"local", "remote"}, invoked)
+	})
+
+	t.Run("RouteConnection_Should_Return_The_Connection_Without_Regional_Endpoints", func(t *testing.T) {
+		invoked := []string{}
+		connection := &fakeConnection{name: "local", invoked: &invoked}
+
+		routed, err := RouteConnection("media", connection, &config.Config{Region: config.RegionConfig{Name: "eu-west-1"}}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, connection, routed)
+	})
+}
+
+func TestSelector(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewSelector(&config.Config{Region: config.RegionConfig{
+		Name:      "eu-west-1",
+		Endpoints: []config.RegionEndpointConfig{{Service: "media", Region: "us-east-1"}},
+	}}).Middleware)
+	router.GET("/resource", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, FromContext(ctx.Request.Context()))
+	})
+	request := func(region string) string {
+		recorder := httptest.NewRecorder()
+		httpRequest := httptest.NewRequest(http.MethodGet, "/resource", nil)
+		httpRequest.Header.Set(OverrideHeader, region)
+		router.ServeHTTP(recorder, httpRequest)
+		return recorder.Body.String()
+	}
+
+	t.Run("Middleware_Should_Prefer_Known_Override_Regions", func(t *testing.T) {
+		assert.Equal(t, "us-east-1", request("US-East-1"))
+	})
+
+	t.Run("Middleware_Should_Ignore_Unknown_Override_Regions", func(t *testing.T) {
+		assert.Equal(t, "", request("ap-south-1"))
+	})
+}
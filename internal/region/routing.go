@@ -0,0 +1,133 @@
+package region
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/unixsocket"
+)
+
+// defaultFailoverCooldown is how long an unavailable endpoint is skipped when no cooldown is configured
+const defaultFailoverCooldown = 30 * time.Second
+
+type endpoint struct {
+	region      string
+	connection  grpc.ClientConnInterface
+	unavailable time.Time
+}
+
+// RegionalConnection prefers the upstream endpoints of the region of the gateway, or the one of the request,
+// and fails over to the endpoints of the other regions while the preferred ones are unavailable
+type RegionalConnection struct {
+	localRegion string
+	endpoints   []*endpoint
+	cooldown    time.Duration
+	now         func() time.Time
+	mtx         sync.Mutex
+}
+
+var _ grpc.ClientConnInterface = &RegionalConnection{}
+
+// Invoke calls the preferred endpoint, then the next one of the failover order while they are unavailable
+func (connection *RegionalConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	var err error
+	for _, endpoint := range connection.candidates(ctx) {
+		err = endpoint.connection.Invoke(ctx, method, args, reply, opts...)
+		if !connection.failedOver(ctx, endpoint, err) {
+			return err
+		}
+	}
+	return err
+}
+
+// NewStream opens the stream with the preferred endpoint, then the next one of the failover order while they are unavailable
+func (connection *RegionalConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	var stream grpc.ClientStream
+	var err error
+	for _, endpoint := range connection.candidates(ctx) {
+		stream, err = endpoint.connection.NewStream(ctx, desc, method, opts...)
+		if !connection.failedOver(ctx, endpoint, err) {
+			return stream, err
+		}
+	}
+	return stream, err
+}
+
+// candidates orders the endpoints of the preferred region first, then the rest, keeping the available ones
+// ahead of the ones in cooldown so every endpoint is still tried when all of them failed recently
+func (connection *RegionalConnection) candidates(ctx context.Context) []*endpoint {
+	preferred := FromContext(ctx)
+	if preferred == "" {
+		preferred = connection.localRegion
+	}
+	now := connection.now()
+	connection.mtx.Lock()
+	defer connection.mtx.Unlock()
+	ordered := make([]*endpoint, 0, len(connection.endpoints))
+	for _, cooling := range []bool{false, true} {
+		for _, sameRegion := range []bool{true, false} {
+			for _, endpoint := range connection.endpoints {
+				if (endpoint.region == preferred) == sameRegion && now.Before(endpoint.unavailable) == cooling {
+					ordered = append(ordered, endpoint)
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+// failedOver tells whether the call should move to the next endpoint, cooling down the unavailable one
+func (connection *RegionalConnection) failedOver(ctx context.Context, endpoint *endpoint, err error) bool {
+	if err == nil || ctx.Err() != nil || status.Code(err) != codes.Unavailable {
+		return false
+	}
+	connection.mtx.Lock()
+	defer connection.mtx.Unlock()
+	endpoint.unavailable = connection.now().Add(connection.cooldown)
+	return true
+}
+
+// RouteConnection wraps the connection of the service with the regional endpoints configured for it
+func RouteConnection(
+	service string,
+	connection grpc.ClientConnInterface,
+	configurations *config.Config,
+	tlsEnabled bool,
+) (grpc.ClientConnInterface, error) {
+	regionConfig := configurations.Region
+	localRegion := strings.ToLower(regionConfig.Name)
+	endpoints := []*endpoint{{region: localRegion, connection: connection}}
+	for _, regionalEndpoint := range regionConfig.Endpoints {
+		if regionalEndpoint.Service != service {
+			continue
+		}
+		address := fmt.Sprintf("%s:%s", regionalEndpoint.Host, regionalEndpoint.Port)
+		endpointTLSEnabled := tlsEnabled
+		if regionalEndpoint.Socket != "" {
+			address, endpointTLSEnabled = unixsocket.Target(regionalEndpoint.Socket), false
+		}
+		fmt.Println("Adding", service, "endpoint", address, "in region", regionalEndpoint.Region)
+		regionalConnection, err := egress.CreateGRPCConnection(address, endpointTLSEnabled)
+		if err != nil {
+			return nil, fmt.Errorf("Could not connect to %s endpoint in region %s: %v", service, regionalEndpoint.Region, err)
+		}
+		endpoints = append(endpoints, &endpoint{region: strings.ToLower(regionalEndpoint.Region), connection: regionalConnection})
+	}
+	if len(endpoints) == 1 {
+		return connection, nil
+	}
+	cooldown := regionConfig.FailoverCooldown
+	if cooldown <= 0 {
+		cooldown = defaultFailoverCooldown
+	}
+	return &RegionalConnection{localRegion: localRegion, endpoints: endpoints, cooldown: cooldown, now: time.Now}, nil
+}
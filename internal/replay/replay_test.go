@@ -0,0 +1,155 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/journal"
+)
+
+const testAdminKey = "replay-admin-key"
+
+// newTestGateway answers the user profile with the email of the user of the synthetic token, and the version
+func newTestGateway(t *testing.T, version string) *httptest.Server {
+	gateway := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch {
+		case request.URL.Path == DefaultTokenPath:
+			if request.Header.Get(authentication.AdminKeyHeader) != testAdminKey {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body := authentication.MintTokenRequestBody{}
+			json.NewDecoder(request.Body).Decode(&body)
+			json.NewEncoder(writer).Encode(map[string]interface{}{
+				"token":      "token-of-" + body.UserID,
+				"expires_at": time.Now().Add(time.Hour).Unix(),
+			})
+		case request.Header.Get("Authorization") == "":
+			writer.WriteHeader(http.StatusUnauthorized)
+		case request.URL.Path == "/api/v1/user":
+			json.NewEncoder(writer).Encode(map[string]interface{}{
+				"user":        strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer token-of-"),
+				"version":     version,
+				"generatedAt": time.Now().UnixNano(),
+			})
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func TestReplay(t *testing.T) {
+	t.Run("ReadAccessLog_Should_Parse_The_Access_Log_Lines", func(t *testing.T) {
+		log := strings.Join([]string{
+			"Listening API requests on URL:  localhost:8080/api/v1",
+			"[GIN] 2026/10/16 - 09:30:01 |\x1b[97;42m 200 \x1b[0m|    1.204ms |       10.0.0.1 |\x1b[97;44m GET     \x1b[0m \"/api/v1/user?fields=email\"",
+			"[GIN] 2026/10/16 - 09:30:02 | 404 |      91.2µs |       10.0.0.2 | DELETE  \"/api/v1/media/42\"",
+		}, "\n")
+
+		requests, err := ReadAccessLog(strings.NewReader(log))
+
+		assert.NoError(t, err)
+		assert.Len(t, requests, 2)
+		assert.Equal(t, Request{
+			Timestamp: time.Date(2026, 10, 16, 9, 30, 1, 0, time.UTC),
+			Method:    http.MethodGet,
+			Path:      "/api/v1/user?fields=email",
+			Status:    http.StatusOK,
+		}, requests[0])
+		assert.Equal(t, http.MethodDelete, requests[1].Method)
+		assert.Equal(t, http.StatusNotFound, requests[1].Status)
+	})
+
+	t.Run("ReadJournal_Should_Decrypt_The_Journaled_Requests", func(t *testing.T) {
+		key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+		directory := t.TempDir()
+		requestJournal, err := journal.NewJournal(&config.Config{Environment: "test", Journal: config.JournalConfig{
+			Directory: directory, EncryptionKey: key,
+		}})
+		assert.NoError(t, err)
+		now := time.Now()
+		requestJournal.Record(&journal.Envelope{
+			Timestamp: now, Method: http.MethodPost, Path: "/api/v1/user/email", Status: http.StatusOK,
+			UserID: "42", Headers: map[string]string{"Authorization": "Bearer secret"}, Body: []byte(`{"email":"a@b.c"}`),
+		})
+		assert.NoError(t, requestJournal.Flush(context.Background(), now))
+
+		requests, err := ReadJournal([]string{filepath.Join(directory, journal.SegmentName(now))}, key)
+
+		assert.NoError(t, err)
+		assert.Len(t, requests, 1)
+		assert.Equal(t, "42", requests[0].UserID)
+		assert.Equal(t, `{"email":"a@b.c"}`, string(requests[0].Body))
+		assert.Empty(t, requests[0].header().Get("Authorization"))
+		assert.Equal(t, "application/json", requests[0].header().Get("Content-Type"))
+	})
+
+	t.Run("Run_Should_Report_The_Responses_Differing_From_The_Baseline", func(t *testing.T) {
+		baseline, target := newTestGateway(t, "v1"), newTestGateway(t, "v2")
+		replayer, err := NewReplayer(Options{
+			Target:        target.URL,
+			Baseline:      baseline.URL,
+			AdminKey:      testAdminKey,
+			IgnoredFields: []string{"generatedAt"},
+		}, nil)
+		assert.NoError(t, err)
+
+		report := replayer.Run(context.Background(), []Request{
+			{Method: http.MethodGet, Path: "/api/v1/user", UserID: "42", Status: http.StatusOK},
+			{Method: http.MethodGet, Path: "/api/v1/media", Status: http.StatusNotFound},
+			{Method: http.MethodGet, Path: "/api/v1/verify/:token", Status: http.StatusOK},
+		})
+
+		assert.False(t, report.Passed())
+		assert.Equal(t, 2, report.Replayed)
+		assert.Equal(t, 1, report.Matched)
+		assert.Equal(t, map[string]int{"the path is a route template": 1}, report.Skipped)
+		assert.Equal(t, []Diff{{
+			Method:         http.MethodGet,
+			Path:           "/api/v1/user",
+			ExpectedStatus: http.StatusOK,
+			ActualStatus:   http.StatusOK,
+			Body:           `$.version: "v1" != "v2"`,
+		}}, report.Diffs)
+		var printed bytes.Buffer
+		assert.NoError(t, report.Print(&printed))
+		assert.Contains(t, printed.String(), "Replayed 2 requests against "+target.URL+" compared with "+baseline.URL+": 1 matched, 1 differed")
+	})
+
+	t.Run("Run_Should_Compare_The_Recorded_Statuses_Without_A_Baseline", func(t *testing.T) {
+		target := newTestGateway(t, "v2")
+		replayer, err := NewReplayer(Options{Target: target.URL, Methods: []string{"get"}}, nil)
+		assert.NoError(t, err)
+
+		report := replayer.Run(context.Background(), []Request{
+			{Method: http.MethodGet, Path: "/api/v1/user", Status: http.StatusOK},
+			{Method: http.MethodDelete, Path: "/api/v1/user", Status: http.StatusOK},
+		})
+
+		assert.Equal(t, 1, report.Replayed)
+		assert.Equal(t, map[string]int{"the DELETE requests are not replayed": 1}, report.Skipped)
+		assert.Equal(t, http.StatusUnauthorized, report.Diffs[0].ActualStatus)
+	})
+
+	t.Run("CompareBodies_Should_Find_The_First_Difference", func(t *testing.T) {
+		assert.Empty(t, CompareBodies([]byte(`{"a":[1,{"b":2}]}`), []byte(`{"a": [1, {"b": 2}]}`), nil))
+		assert.Equal(t, "$.a[1].b: 2 != 3", CompareBodies([]byte(`{"a":[1,{"b":2}]}`), []byte(`{"a":[1,{"b":3}]}`), nil))
+		assert.Equal(t, "$.a: array of 1 != array of 2", CompareBodies([]byte(`{"a":[1]}`), []byte(`{"a":[1,2]}`), nil))
+		assert.Equal(t, "$.c: missing != true", CompareBodies([]byte(`{}`), []byte(`{"c":true}`), nil))
+		assert.Equal(t, "the bodies differ", CompareBodies([]byte("ok"), []byte("nok"), nil))
+	})
+}
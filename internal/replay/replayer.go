@@ -0,0 +1,199 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+)
+
+// Defaults of a replay
+const (
+	DefaultTokenPath = "/api/v1/staging/tokens"
+	DefaultUser      = "replay"
+	defaultTimeout   = 10 * time.Second
+	// tokenMargin renews the synthetic tokens before they expire during a replay
+	tokenMargin = 30 * time.Second
+)
+
+// Options are the gateways the traffic is replayed against and how the requests are authenticated
+type Options struct {
+	// Target is the URL of the gateway under test
+	Target string
+	// Baseline is the URL of the gateway the responses are compared with, the recorded statuses are compared
+	// with when it is not set
+	Baseline string
+	// AdminKey is the admin key of the token minting of the gateways, the requests are sent without a token
+	// when it is not set
+	AdminKey string
+	// TokenPath is the route minting the synthetic tokens
+	TokenPath string
+	// User is the synthetic user of the requests recorded without one
+	User string
+	// Methods are the methods replayed, every method when empty
+	Methods []string
+	// IgnoredFields are the fields of the response bodies left out of the comparison, such as the timestamps
+	IgnoredFields []string
+}
+
+// syntheticToken is a token minted for a user by a gateway
+type syntheticToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// Replayer sends the recorded requests to the target gateway, authenticated with synthetic tokens of their users,
+// and reports the responses differing from the baseline
+type Replayer struct {
+	options Options
+	client  *http.Client
+	methods map[string]bool
+	ignored map[string]bool
+	tokens  map[string]syntheticToken
+}
+
+// NewReplayer creates the replayer of the options
+func NewReplayer(options Options, client *http.Client) (*Replayer, error) {
+	if options.Target == "" {
+		return nil, fmt.Errorf("The replay needs a target gateway")
+	}
+	options.Target = strings.TrimSuffix(options.Target, "/")
+	options.Baseline = strings.TrimSuffix(options.Baseline, "/")
+	if options.TokenPath == "" {
+		options.TokenPath = DefaultTokenPath
+	}
+	if options.User == "" {
+		options.User = DefaultUser
+	}
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	replayer := &Replayer{
+		options: options,
+		client:  client,
+		methods: map[string]bool{},
+		ignored: map[string]bool{},
+		tokens:  map[string]syntheticToken{},
+	}
+	for _, method := range options.Methods {
+		replayer.methods[strings.ToUpper(method)] = true
+	}
+	for _, field := range options.IgnoredFields {
+		replayer.ignored[field] = true
+	}
+	return replayer, nil
+}
+
+// Run replays the requests in their order until they are all sent or the context is done
+func (replayer *Replayer) Run(ctx context.Context, requests []Request) *Report {
+	report := newReport(replayer.options.Target, replayer.options.Baseline)
+	for _, request := range requests {
+		if ctx.Err() != nil {
+			break
+		}
+		if reason := request.Replayable(replayer.methods); reason != "" {
+			report.skip(reason)
+			continue
+		}
+		report.record(replayer.replay(ctx, request))
+	}
+	return report
+}
+
+// replay sends the request to the target and compares its response with the baseline one or the recorded status
+func (replayer *Replayer) replay(ctx context.Context, request Request) Diff {
+	diff := Diff{Method: request.Method, Path: request.Path, ExpectedStatus: request.Status}
+	actualStatus, actualBody, err := replayer.send(ctx, replayer.options.Target, request)
+	if err != nil {
+		diff.Error = err.Error()
+		return diff
+	}
+	diff.ActualStatus = actualStatus
+	if replayer.options.Baseline == "" {
+		return diff
+	}
+	expectedStatus, expectedBody, err := replayer.send(ctx, replayer.options.Baseline, request)
+	if err != nil {
+		diff.Error = fmt.Sprintf("The baseline failed: %v", err)
+		return diff
+	}
+	diff.ExpectedStatus = expectedStatus
+	diff.Body = CompareBodies(expectedBody, actualBody, replayer.ignored)
+	return diff
+}
+
+func (replayer *Replayer) send(ctx context.Context, gateway string, request Request) (int, []byte, error) {
+	var body io.Reader
+	if len(request.Body) > 0 {
+		body = bytes.NewReader(request.Body)
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, request.Method, gateway+request.Path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	httpRequest.Header = request.header()
+	if replayer.options.AdminKey != "" {
+		user := request.UserID
+		if user == "" {
+			user = replayer.options.User
+		}
+		token, err := replayer.token(ctx, gateway, user)
+		if err != nil {
+			return 0, nil, err
+		}
+		httpRequest.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := replayer.client.Do(httpRequest)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return response.StatusCode, responseBody, nil
+}
+
+// token returns the synthetic token of the user minted by the gateway, minting a new one once it is about to expire
+func (replayer *Replayer) token(ctx context.Context, gateway, user string) (string, error) {
+	key := gateway + " " + user
+	cached, exists := replayer.tokens[key]
+	if exists && time.Now().Add(tokenMargin).Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	mintBody, err := json.Marshal(authentication.MintTokenRequestBody{UserID: user, Email: user + "@replay.local"})
+	if err != nil {
+		return "", err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, gateway+replayer.options.TokenPath, bytes.NewReader(mintBody))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(authentication.AdminKeyHeader, replayer.options.AdminKey)
+	response, err := replayer.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("Could not mint a token for %s: %v", user, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Could not mint a token for %s, %s answered %d", user, gateway, response.StatusCode)
+	}
+	minted := struct {
+		Token     string `json:"token"`
+		ExpiresAt int64  `json:"expires_at"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&minted); err != nil {
+		return "", fmt.Errorf("Could not read the token minted for %s: %v", user, err)
+	}
+	replayer.tokens[key] = syntheticToken{token: minted.Token, expiresAt: time.Unix(minted.ExpiresAt, 0)}
+	return minted.Token, nil
+}
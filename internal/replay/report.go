@@ -0,0 +1,160 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"text/tabwriter"
+)
+
+// Diff is a replayed request whose response differs from the expected one
+type Diff struct {
+	Method         string `json:"method"`
+	Path           string `json:"path"`
+	ExpectedStatus int    `json:"expectedStatus"`
+	ActualStatus   int    `json:"actualStatus,omitempty"`
+	Body           string `json:"body,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Differs tells whether the response differs from the expected one
+func (diff Diff) Differs() bool {
+	return diff.Error != "" || diff.ExpectedStatus != diff.ActualStatus || diff.Body != ""
+}
+
+// Report are the results of a replay
+type Report struct {
+	Target   string         `json:"target"`
+	Baseline string         `json:"baseline,omitempty"`
+	Replayed int            `json:"replayed"`
+	Matched  int            `json:"matched"`
+	Skipped  map[string]int `json:"skipped"`
+	Diffs    []Diff         `json:"diffs"`
+}
+
+func newReport(target, baseline string) *Report {
+	return &Report{Target: target, Baseline: baseline, Skipped: map[string]int{}, Diffs: []Diff{}}
+}
+
+func (report *Report) skip(reason string) {
+	report.Skipped[reason]++
+}
+
+func (report *Report) record(diff Diff) {
+	report.Replayed++
+	if diff.Differs() {
+		report.Diffs = append(report.Diffs, diff)
+		return
+	}
+	report.Matched++
+}
+
+// Passed tells whether every replayed request was answered as expected
+func (report *Report) Passed() bool {
+	return len(report.Diffs) == 0
+}
+
+// Print writes the report as a summary followed by the differing requests
+func (report *Report) Print(writer io.Writer) error {
+	expected := "the recorded statuses"
+	if report.Baseline != "" {
+		expected = report.Baseline
+	}
+	fmt.Fprintf(writer, "Replayed %d requests against %s compared with %s: %d matched, %d differed\n",
+		report.Replayed, report.Target, expected, report.Matched, len(report.Diffs))
+	reasons := make([]string, 0, len(report.Skipped))
+	for reason := range report.Skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(writer, "Skipped %d requests: %s\n", report.Skipped[reason], reason)
+	}
+	if len(report.Diffs) == 0 {
+		return nil
+	}
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "\nMETHOD\tPATH\tEXPECTED\tACTUAL\tDIFFERENCE")
+	for _, diff := range report.Diffs {
+		difference := diff.Body
+		if diff.Error != "" {
+			difference = diff.Error
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%s\n", diff.Method, diff.Path, diff.ExpectedStatus, diff.ActualStatus, difference)
+	}
+	return table.Flush()
+}
+
+// CompareBodies returns the first difference between the JSON bodies, empty when they are equal once the ignored
+// fields are left out. The bodies that are not JSON are compared as they are
+func CompareBodies(expected, actual []byte, ignored map[string]bool) string {
+	var expectedValue, actualValue interface{}
+	if json.Unmarshal(expected, &expectedValue) != nil || json.Unmarshal(actual, &actualValue) != nil {
+		if bytes.Equal(expected, actual) {
+			return ""
+		}
+		return "the bodies differ"
+	}
+	return compareValues("$", expectedValue, actualValue, ignored)
+}
+
+func compareValues(path string, expected, actual interface{}, ignored map[string]bool) string {
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		actualValue, ok := actual.(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("%s: %s != %s", path, describe(expected), describe(actual))
+		}
+		fields := make([]string, 0, len(expectedValue)+len(actualValue))
+		for field := range expectedValue {
+			fields = append(fields, field)
+		}
+		for field := range actualValue {
+			if _, exists := expectedValue[field]; !exists {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if ignored[field] {
+				continue
+			}
+			if difference := compareValues(path+"."+field, expectedValue[field], actualValue[field], ignored); difference != "" {
+				return difference
+			}
+		}
+		return ""
+	case []interface{}:
+		actualValue, ok := actual.([]interface{})
+		if !ok || len(actualValue) != len(expectedValue) {
+			return fmt.Sprintf("%s: %s != %s", path, describe(expected), describe(actual))
+		}
+		for index := range expectedValue {
+			if difference := compareValues(fmt.Sprintf("%s[%d]", path, index), expectedValue[index], actualValue[index], ignored); difference != "" {
+				return difference
+			}
+		}
+		return ""
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		return fmt.Sprintf("%s: %s != %s", path, describe(expected), describe(actual))
+	}
+	return ""
+}
+
+// describe abbreviates the objects and arrays of a difference
+func describe(value interface{}) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return "missing"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return fmt.Sprintf("array of %d", len(typedValue))
+	}
+	serialized, _ := json.Marshal(value)
+	return string(serialized)
+}
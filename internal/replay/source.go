@@ -0,0 +1,127 @@
+package replay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/journal"
+)
+
+// accessLogPattern parses the access log lines of middleware.AccessLogFormatter, without their colors
+var (
+	accessLogPattern = regexp.MustCompile(`^\[GIN\] (\d{4}/\d{2}/\d{2} - \d{2}:\d{2}:\d{2}) \|\s*(\d{3})\s*\|[^|]*\|[^|]*\|\s*([A-Z]+)\s+"([^"]*)"`)
+	colorPattern     = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+// Request is a recorded request of the gateway traffic
+type Request struct {
+	Timestamp     time.Time
+	Method        string
+	Path          string
+	Status        int
+	UserID        string
+	Headers       map[string]string
+	Body          []byte
+	BodyTruncated bool
+}
+
+// FromEnvelopes returns the requests of the journal envelopes in their order
+func FromEnvelopes(envelopes []*journal.Envelope) []Request {
+	requests := make([]Request, 0, len(envelopes))
+	for _, envelope := range envelopes {
+		requests = append(requests, Request{
+			Timestamp:     envelope.Timestamp,
+			Method:        envelope.Method,
+			Path:          envelope.Path,
+			Status:        envelope.Status,
+			UserID:        envelope.UserID,
+			Headers:       envelope.Headers,
+			Body:          envelope.Body,
+			BodyTruncated: envelope.BodyTruncated,
+		})
+	}
+	return requests
+}
+
+// ReadJournal decrypts the requests of the journal segments with the journal encryption key
+func ReadJournal(paths []string, encryptionKey string) ([]Request, error) {
+	aead, err := journal.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	requests := []Request{}
+	for _, path := range paths {
+		envelopes, err := journal.ReadSegment(path, aead)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read %s: %v", path, err)
+		}
+		requests = append(requests, FromEnvelopes(envelopes)...)
+	}
+	return requests, nil
+}
+
+// ReadAccessLog parses the requests of the access log lines, the other lines of the log are skipped. The access
+// log has neither the users nor the bodies of the requests
+func ReadAccessLog(reader io.Reader) ([]Request, error) {
+	requests := []Request{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		match := accessLogPattern.FindStringSubmatch(colorPattern.ReplaceAllString(scanner.Text(), ""))
+		if match == nil {
+			continue
+		}
+		timestamp, err := time.Parse("2006/01/02 - 15:04:05", match[1])
+		if err != nil {
+			continue
+		}
+		status, _ := strconv.Atoi(match[2])
+		path, err := strconv.Unquote(`"` + match[4] + `"`)
+		if err != nil {
+			path = match[4]
+		}
+		requests = append(requests, Request{Timestamp: timestamp, Method: match[3], Path: path, Status: status})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read the access log: %v", err)
+	}
+	return requests, nil
+}
+
+// Replayable tells why the request can not be replayed, empty when it can: the public routes are logged with their
+// route template instead of their tokens and the truncated bodies would be rejected
+func (request Request) Replayable(methods map[string]bool) string {
+	switch {
+	case len(methods) > 0 && !methods[request.Method]:
+		return fmt.Sprintf("the %s requests are not replayed", request.Method)
+	case strings.Contains(request.Path, "/:") || strings.Contains(request.Path, "/*"):
+		return "the path is a route template"
+	case request.BodyTruncated:
+		return "the body was truncated"
+	case request.Method == "" || !strings.HasPrefix(request.Path, "/"):
+		return "the request is incomplete"
+	}
+	return ""
+}
+
+// header returns the recorded header, the credentials are never replayed as they are replaced by synthetic ones
+func (request Request) header() http.Header {
+	header := http.Header{}
+	for name, value := range request.Headers {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Cookie", "X-Api-Key":
+			continue
+		}
+		header.Set(name, value)
+	}
+	if len(request.Body) > 0 && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/json")
+	}
+	return header
+}
@@ -0,0 +1,191 @@
+package resilience
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// State is the state of a circuit breaker
+type State string
+
+// States of a circuit breaker
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+// Settings are the thresholds of a circuit breaker
+type Settings struct {
+	FailureThreshold int
+	ResetTimeout     time.Duration
+	HalfOpenRequests int
+}
+
+// BreakerStats are the state and counters of a circuit breaker
+type BreakerStats struct {
+	State               State     `json:"state"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	Failures            int64     `json:"failures"`
+	Rejected            int64     `json:"rejected"`
+	Opened              int64     `json:"opened"`
+	OpenedAt            time.Time `json:"openedAt"`
+	OpenSince           time.Time `json:"openSince,omitempty"`
+}
+
+// Breaker opens after consecutive failures so the calls fail fast instead of waiting on a failing upstream,
+// lets a few probe calls through once the reset timeout is over and closes again when they succeed
+type Breaker struct {
+	settings  Settings
+	state     State
+	failures  int
+	probes    int
+	successes int
+	openedAt  time.Time
+	trippedAt time.Time
+	stats     BreakerStats
+	now       func() time.Time
+	mtx       sync.Mutex
+}
+
+// NewBreaker creates a closed circuit breaker
+func NewBreaker(settings Settings) *Breaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 1
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = 1
+	}
+	return &Breaker{settings: settings, state: StateClosed, now: time.Now}
+}
+
+// Allow tells whether a call may go through, a call allowed must be followed by Success, Failure or Release
+func (breaker *Breaker) Allow() bool {
+	breaker.mtx.Lock()
+	defer breaker.mtx.Unlock()
+	if breaker.state == StateOpen && !breaker.now().Before(breaker.openedAt.Add(breaker.settings.ResetTimeout)) {
+		breaker.state, breaker.probes, breaker.successes = StateHalfOpen, 0, 0
+	}
+	switch breaker.state {
+	case StateOpen:
+		breaker.stats.Rejected++
+		return false
+	case StateHalfOpen:
+		if breaker.probes >= breaker.settings.HalfOpenRequests {
+			breaker.stats.Rejected++
+			return false
+		}
+		breaker.probes++
+	}
+	return true
+}
+
+// Success records a successful call, closing the breaker once enough probes succeeded
+func (breaker *Breaker) Success() {
+	breaker.mtx.Lock()
+	defer breaker.mtx.Unlock()
+	switch breaker.state {
+	case StateClosed:
+		breaker.failures = 0
+	case StateHalfOpen:
+		breaker.successes++
+		if breaker.successes >= breaker.settings.HalfOpenRequests {
+			breaker.state, breaker.failures = StateClosed, 0
+		}
+	}
+}
+
+// Failure records a failed call, opening the breaker at the failure threshold or when a probe fails
+func (breaker *Breaker) Failure() {
+	breaker.mtx.Lock()
+	defer breaker.mtx.Unlock()
+	breaker.stats.Failures++
+	switch breaker.state {
+	case StateClosed:
+		breaker.failures++
+		if breaker.failures >= breaker.settings.FailureThreshold {
+			breaker.open()
+		}
+	case StateHalfOpen:
+		breaker.open()
+	}
+}
+
+// Release records a call whose outcome says nothing about the upstream, such as one cancelled by the client
+func (breaker *Breaker) Release() {
+	breaker.mtx.Lock()
+	defer breaker.mtx.Unlock()
+	if breaker.state == StateHalfOpen && breaker.probes > 0 {
+		breaker.probes--
+	}
+}
+
+// Stats returns the state and counters of the breaker, OpenSince is when it last left the closed state so it
+// does not move when a failed probe opens it again
+func (breaker *Breaker) Stats() BreakerStats {
+	breaker.mtx.Lock()
+	defer breaker.mtx.Unlock()
+	stats := breaker.stats
+	stats.State = breaker.state
+	stats.ConsecutiveFailures = breaker.failures
+	stats.OpenedAt = breaker.openedAt
+	if breaker.state != StateClosed {
+		stats.OpenSince = breaker.trippedAt
+	}
+	return stats
+}
+
+func (breaker *Breaker) open() {
+	if breaker.state == StateClosed {
+		breaker.trippedAt = breaker.now()
+	}
+	breaker.state = StateOpen
+	breaker.openedAt = breaker.now()
+	breaker.stats.Opened++
+}
+
+// Breakers is the registry of the circuit breakers per service
+type Breakers struct {
+	breakers map[string]*Breaker
+	mtx      sync.Mutex
+}
+
+// DefaultBreakers is the registry used by the connections created with BreakConnection
+var DefaultBreakers = NewBreakers()
+
+// NewBreakers creates an empty circuit breakers registry
+func NewBreakers() *Breakers {
+	return &Breakers{breakers: make(map[string]*Breaker)}
+}
+
+// Breaker returns the breaker of the service, creating it with the settings the first time, so the
+// connections to the same service share it
+func (breakers *Breakers) Breaker(service string, settings Settings) *Breaker {
+	breakers.mtx.Lock()
+	defer breakers.mtx.Unlock()
+	breaker, exists := breakers.breakers[service]
+	if !exists {
+		breaker = NewBreaker(settings)
+		breakers.breakers[service] = breaker
+	}
+	return breaker
+}
+
+// Stats returns the state and counters of every breaker
+func (breakers *Breakers) Stats() map[string]BreakerStats {
+	breakers.mtx.Lock()
+	defer breakers.mtx.Unlock()
+	stats := make(map[string]BreakerStats, len(breakers.breakers))
+	for service, breaker := range breakers.breakers {
+		stats[service] = breaker.Stats()
+	}
+	return stats
+}
+
+// StatsHandler answers with the state and counters of every circuit breaker
+func (breakers *Breakers) StatsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"breakers": breakers.Stats()})
+}
@@ -0,0 +1,97 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// BreakerConnection guards the calls to a service with its circuit breaker, answering Unavailable
+// right away while the breaker is open, which the routes turn into a 503
+type BreakerConnection struct {
+	service    string
+	breaker    *Breaker
+	connection grpc.ClientConnInterface
+}
+
+var _ grpc.ClientConnInterface = &BreakerConnection{}
+
+// Invoke calls the service unless its breaker is open
+func (connection *BreakerConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	if !connection.breaker.Allow() {
+		return connection.openError()
+	}
+	err := connection.connection.Invoke(ctx, method, args, reply, opts...)
+	connection.record(ctx, err)
+	return err
+}
+
+// NewStream opens the stream with the service unless its breaker is open, only the opening of the stream is accounted
+func (connection *BreakerConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if !connection.breaker.Allow() {
+		return nil, connection.openError()
+	}
+	stream, err := connection.connection.NewStream(ctx, desc, method, opts...)
+	connection.record(ctx, err)
+	return stream, err
+}
+
+// record counts the errors telling the service is down or too slow as failures, the other errors are
+// answers of a healthy service and the calls cancelled by the client say nothing about it
+func (connection *BreakerConnection) record(ctx context.Context, err error) {
+	if err == nil {
+		connection.breaker.Success()
+		return
+	}
+	if ctx.Err() == context.Canceled {
+		connection.breaker.Release()
+		return
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		connection.breaker.Failure()
+	default:
+		connection.breaker.Success()
+	}
+}
+
+func (connection *BreakerConnection) openError() error {
+	return status.Error(codes.Unavailable, fmt.Sprintf("The %s service is unavailable", connection.service))
+}
+
+// BreakConnection guards the connection to the service with its circuit breaker when the breakers are enabled
+func BreakConnection(service string, connection grpc.ClientConnInterface, configurations *config.Config) grpc.ClientConnInterface {
+	breakersConfig := configurations.CircuitBreakers
+	if !breakersConfig.Enabled {
+		return connection
+	}
+	settings := Settings{
+		FailureThreshold: breakersConfig.FailureThreshold,
+		ResetTimeout:     breakersConfig.ResetTimeout,
+		HalfOpenRequests: breakersConfig.HalfOpenRequests,
+	}
+	for _, serviceConfig := range breakersConfig.Services {
+		if serviceConfig.Service != service {
+			continue
+		}
+		if serviceConfig.FailureThreshold > 0 {
+			settings.FailureThreshold = serviceConfig.FailureThreshold
+		}
+		if serviceConfig.ResetTimeout > 0 {
+			settings.ResetTimeout = serviceConfig.ResetTimeout
+		}
+		if serviceConfig.HalfOpenRequests > 0 {
+			settings.HalfOpenRequests = serviceConfig.HalfOpenRequests
+		}
+	}
+	return &BreakerConnection{
+		service:    service,
+		breaker:    DefaultBreakers.Breaker(service, settings),
+		connection: connection,
+	}
+}
@@ -0,0 +1,314 @@
+package resilience
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/quadev-ltd/qd-common/pb/gen/go/pb_authentication"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// fakeConnection answers the calls with its errors in turn, then with its error, and counts the calls reaching it
+type fakeConnection struct {
+	errs  []error
+	err   error
+	calls int
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	connection.calls++
+	if connection.calls <= len(connection.errs) {
+		return connection.errs[connection.calls-1]
+	}
+	return connection.err
+}
+
+func (connection *fakeConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	connection.calls++
+	return nil, connection.err
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	newBreaker := func(settings Settings) *Breaker {
+		breaker := NewBreaker(settings)
+		breaker.now = func() time.Time { return now }
+		return breaker
+	}
+
+	t.Run("Failure_Should_Open_At_The_Threshold_Of_Consecutive_Failures", func(t *testing.T) {
+		breaker := newBreaker(Settings{FailureThreshold: 3, ResetTimeout: time.Minute})
+
+		for index := 0; index < 2; index++ {
+			assert.True(t, breaker.Allow())
+			breaker.Failure()
+		}
+		assert.True(t, breaker.Allow())
+		breaker.Success()
+		for index := 0; index < 3; index++ {
+			assert.True(t, breaker.Allow())
+			breaker.Failure()
+		}
+
+		assert.False(t, breaker.Allow())
+		assert.Equal(t, StateOpen, breaker.Stats().State)
+		assert.Equal(t, int64(1), breaker.Stats().Rejected)
+	})
+
+	t.Run("Allow_Should_Let_The_Probes_Through_After_The_Reset_Timeout", func(t *testing.T) {
+		breaker := newBreaker(Settings{FailureThreshold: 1, ResetTimeout: time.Minute, HalfOpenRequests: 1})
+		breaker.Allow()
+		breaker.Failure()
+		assert.False(t, breaker.Allow())
+
+		now = now.Add(time.Minute)
+		assert.True(t, breaker.Allow())
+		assert.False(t, breaker.Allow())
+		assert.Equal(t, StateHalfOpen, breaker.Stats().State)
+
+		breaker.Success()
+		assert.Equal(t, StateClosed, breaker.Stats().State)
+		assert.True(t, breaker.Allow())
+	})
+
+	t.Run("Failure_Should_Open_Again_When_A_Probe_Fails", func(t *testing.T) {
+		breaker := newBreaker(Settings{FailureThreshold: 1, ResetTimeout: time.Minute})
+		breaker.Allow()
+		breaker.Failure()
+		now = now.Add(time.Minute)
+
+		assert.True(t, breaker.Allow())
+		breaker.Failure()
+
+		assert.False(t, breaker.Allow())
+		assert.Equal(t, int64(2), breaker.Stats().Opened)
+	})
+
+	t.Run("Stats_Should_Keep_The_Open_Since_Time_Until_The_Breaker_Closes", func(t *testing.T) {
+		breaker := newBreaker(Settings{FailureThreshold: 1, ResetTimeout: time.Minute})
+		breaker.Allow()
+		breaker.Failure()
+		trippedAt := now
+		now = now.Add(time.Minute)
+
+		assert.True(t, breaker.Allow())
+		breaker.Failure()
+		assert.Equal(t, trippedAt, breaker.Stats().OpenSince)
+		assert.Equal(t, now, breaker.Stats().OpenedAt)
+
+		now = now.Add(time.Minute)
+		assert.True(t, breaker.Allow())
+		breaker.Success()
+		assert.True(t, breaker.Stats().OpenSince.IsZero())
+	})
+
+	t.Run("Release_Should_Free_The_Probe", func(t *testing.T) {
+		breaker := newBreaker(Settings{FailureThreshold: 1, ResetTimeout: time.Minute})
+		breaker.Allow()
+		breaker.Failure()
+		now = now.Add(time.Minute)
+
+		assert.True(t, breaker.Allow())
+		breaker.Release()
+
+		assert.True(t, breaker.Allow())
+	})
+}
+
+func TestBreakerConnection(t *testing.T) {
+	newConnection := func(upstream *fakeConnection) *BreakerConnection {
+		return &BreakerConnection{
+			service:    "authentication",
+			breaker:    NewBreaker(Settings{FailureThreshold: 2, ResetTimeout: time.Minute}),
+			connection: upstream,
+		}
+	}
+
+	t.Run("Invoke_Should_Fail_Fast_Once_The_Upstream_Is_Down", func(t *testing.T) {
+		upstream := &fakeConnection{err: status.Error(codes.Unavailable, "connection refused")}
+		connection := newConnection(upstream)
+
+		for index := 0; index < 3; index++ {
+			err := connection.Invoke(context.Background(), "/method", nil, nil)
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+		}
+
+		assert.Equal(t, 2, upstream.calls)
+	})
+
+	t.Run("Invoke_Should_Not_Count_The_Answers_Of_A_Healthy_Upstream", func(t *testing.T) {
+		upstream := &fakeConnection{err: status.Error(codes.NotFound, "user not found")}
+		connection := newConnection(upstream)
+
+		for index := 0; index < 3; index++ {
+			connection.Invoke(context.Background(), "/method", nil, nil)
+		}
+
+		assert.Equal(t, 3, upstream.calls)
+		assert.Equal(t, StateClosed, connection.breaker.Stats().State)
+	})
+
+	t.Run("Invoke_Should_Not_Count_The_Calls_Cancelled_By_The_Client", func(t *testing.T) {
+		upstream := &fakeConnection{err: status.Error(codes.DeadlineExceeded, "cancelled")}
+		connection := newConnection(upstream)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for index := 0; index < 3; index++ {
+			connection.Invoke(ctx, "/method", nil, nil)
+		}
+
+		assert.Equal(t, 3, upstream.calls)
+	})
+
+	t.Run("ResendEmailVerification_Should_Answer_503_While_The_Breaker_Is_Open", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		upstream := &fakeConnection{err: status.Error(codes.Unavailable, "connection refused")}
+		client := pb_authentication.NewAuthenticationServiceClient(newConnection(upstream))
+		router := gin.New()
+		router.POST("/user/:userID/email/verification", func(ctx *gin.Context) {
+			routes.ResendEmailVerification(ctx, client)
+		})
+
+		for index := 0; index < 3; index++ {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/user/user-id/email/verification", nil))
+			assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		}
+		assert.Equal(t, 2, upstream.calls)
+	})
+
+	t.Run("BreakConnection_Should_Share_The_Breaker_Of_A_Service", func(t *testing.T) {
+		configurations := &config.Config{CircuitBreakers: config.CircuitBreakersConfig{
+			Enabled:          true,
+			FailureThreshold: 5,
+			Services:         []config.CircuitBreakerConfig{{Service: "breaker-test", FailureThreshold: 1}},
+		}}
+
+		first := BreakConnection("breaker-test", &fakeConnection{}, configurations).(*BreakerConnection)
+		second := BreakConnection("breaker-test", &fakeConnection{}, configurations).(*BreakerConnection)
+
+		assert.True(t, first.breaker == second.breaker)
+		assert.Equal(t, 1, first.breaker.settings.FailureThreshold)
+	})
+
+	t.Run("BreakConnection_Should_Keep_The_Connection_When_Disabled", func(t *testing.T) {
+		upstream := &fakeConnection{}
+
+		assert.Equal(t, upstream, BreakConnection("authentication", upstream, &config.Config{}))
+	})
+}
+
+func TestRetryingConnection(t *testing.T) {
+	const method = "/pb_authentication.AuthenticationService/GetPublicKey"
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	newConnection := func(t *testing.T, upstream *fakeConnection) (*RetryingConnection, *[]time.Duration) {
+		controller := gomock.NewController(t)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		loggerMock.EXPECT().Warn(gomock.Any()).AnyTimes()
+		loggerMock.EXPECT().Info(gomock.Any()).AnyTimes()
+		loggerMock.EXPECT().Error(gomock.Any(), gomock.Any()).AnyTimes()
+		delays := &[]time.Duration{}
+		return &RetryingConnection{
+			service:    "authentication",
+			policies:   map[string]RetryPolicy{method: {MaxRetries: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 250 * time.Millisecond}},
+			connection: upstream,
+			metrics:    NewRetryMetrics(),
+			logger:     loggerMock,
+			sleep: func(ctx context.Context, delay time.Duration) error {
+				*delays = append(*delays, delay)
+				return ctx.Err()
+			},
+		}, delays
+	}
+
+	t.Run("Invoke_Should_Retry_Until_The_Upstream_Recovers", func(t *testing.T) {
+		upstream := &fakeConnection{errs: []error{unavailable, unavailable}}
+		connection, delays := newConnection(t, upstream)
+
+		err := connection.Invoke(context.Background(), method, nil, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, upstream.calls)
+		assert.Len(t, *delays, 2)
+		assert.Equal(t, RetryStats{Calls: 1, Retries: 2, Recovered: 1}, connection.metrics.Stats()[method])
+	})
+
+	t.Run("Invoke_Should_Give_Up_After_The_Max_Retries", func(t *testing.T) {
+		upstream := &fakeConnection{err: unavailable}
+		connection, delays := newConnection(t, upstream)
+
+		err := connection.Invoke(context.Background(), method, nil, nil)
+
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 4, upstream.calls)
+		assert.Len(t, *delays, 3)
+		for attempt, delay := range *delays {
+			assert.True(t, delay > 0 && delay <= 100*time.Millisecond<<attempt && delay <= 250*time.Millisecond)
+		}
+		assert.Equal(t, RetryStats{Calls: 1, Retries: 3, Exhausted: 1}, connection.metrics.Stats()[method])
+	})
+
+	t.Run("Invoke_Should_Not_Retry_The_Other_Methods", func(t *testing.T) {
+		upstream := &fakeConnection{err: unavailable}
+		connection, _ := newConnection(t, upstream)
+
+		connection.Invoke(context.Background(), "/pb_authentication.AuthenticationService/Authenticate", nil, nil)
+
+		assert.Equal(t, 1, upstream.calls)
+		assert.Empty(t, connection.metrics.Stats())
+	})
+
+	t.Run("Invoke_Should_Not_Retry_The_Answers_Of_A_Healthy_Upstream", func(t *testing.T) {
+		upstream := &fakeConnection{err: status.Error(codes.NotFound, "key not found")}
+		connection, _ := newConnection(t, upstream)
+
+		connection.Invoke(context.Background(), method, nil, nil)
+
+		assert.Equal(t, 1, upstream.calls)
+	})
+
+	t.Run("Invoke_Should_Stop_When_The_Call_Is_Cancelled", func(t *testing.T) {
+		upstream := &fakeConnection{err: unavailable}
+		connection, _ := newConnection(t, upstream)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		connection.Invoke(ctx, method, nil, nil)
+
+		assert.Equal(t, 1, upstream.calls)
+	})
+
+	t.Run("RetryConnection_Should_Override_The_Policy_Per_Method", func(t *testing.T) {
+		maxRetries := 5
+		configurations := &config.Config{Retries: config.RetriesConfig{
+			Enabled:        true,
+			MaxRetries:     2,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     time.Second,
+			Methods:        []config.RetryMethodConfig{{Method: method, MaxRetries: &maxRetries}},
+		}}
+
+		connection := RetryConnection("authentication", &fakeConnection{}, configurations).(*RetryingConnection)
+
+		assert.Equal(t, RetryPolicy{MaxRetries: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, connection.policies[method])
+	})
+
+	t.Run("RetryConnection_Should_Keep_The_Connection_When_Disabled", func(t *testing.T) {
+		upstream := &fakeConnection{}
+
+		assert.Equal(t, upstream, RetryConnection("authentication", upstream, &config.Config{}))
+	})
+}
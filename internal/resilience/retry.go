@@ -0,0 +1,209 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// RetryPolicy is the number of retries of a method and the bounds of the backoff between them
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// RetryStats are the counters of the retries of a method
+type RetryStats struct {
+	Calls     int64 `json:"calls"`
+	Retries   int64 `json:"retries"`
+	Recovered int64 `json:"recovered"`
+	Exhausted int64 `json:"exhausted"`
+}
+
+// RetryMetrics aggregates the retry counters per method
+type RetryMetrics struct {
+	methods map[string]*RetryStats
+	mtx     sync.Mutex
+}
+
+// DefaultRetryMetrics is the registry used by the connections created with RetryConnection
+var DefaultRetryMetrics = NewRetryMetrics()
+
+// NewRetryMetrics creates an empty retry metrics registry
+func NewRetryMetrics() *RetryMetrics {
+	return &RetryMetrics{methods: make(map[string]*RetryStats)}
+}
+
+// record counts a call given the retries it took and whether it eventually failed with a retryable error
+func (metrics *RetryMetrics) record(method string, retries int, exhausted bool) {
+	metrics.mtx.Lock()
+	defer metrics.mtx.Unlock()
+	stats, exists := metrics.methods[method]
+	if !exists {
+		stats = &RetryStats{}
+		metrics.methods[method] = stats
+	}
+	stats.Calls++
+	stats.Retries += int64(retries)
+	switch {
+	case exhausted:
+		stats.Exhausted++
+	case retries > 0:
+		stats.Recovered++
+	}
+}
+
+// Stats returns the retry counters of every method
+func (metrics *RetryMetrics) Stats() map[string]RetryStats {
+	metrics.mtx.Lock()
+	defer metrics.mtx.Unlock()
+	stats := make(map[string]RetryStats, len(metrics.methods))
+	for method, methodStats := range metrics.methods {
+		stats[method] = *methodStats
+	}
+	return stats
+}
+
+// StatsHandler answers with the retry counters of every method
+func (metrics *RetryMetrics) StatsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"methods": metrics.Stats()})
+}
+
+// RetryingConnection retries the configured methods with exponential backoff and full jitter while the
+// service answers Unavailable or ResourceExhausted, the other methods and the streams go through once
+type RetryingConnection struct {
+	service    string
+	policies   map[string]RetryPolicy
+	connection grpc.ClientConnInterface
+	metrics    *RetryMetrics
+	logger     commonLogger.Loggerer
+	sleep      func(ctx context.Context, delay time.Duration) error
+}
+
+var _ grpc.ClientConnInterface = &RetryingConnection{}
+
+// Invoke calls the service, retrying the call when its method has a retry policy
+func (connection *RetryingConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	policy, retried := connection.policies[method]
+	if !retried {
+		return connection.connection.Invoke(ctx, method, args, reply, opts...)
+	}
+	for attempt := 0; ; attempt++ {
+		err := connection.connection.Invoke(ctx, method, args, reply, opts...)
+		if !isRetryable(err) || ctx.Err() != nil {
+			connection.metrics.record(method, attempt, false)
+			if err == nil && attempt > 0 {
+				connection.loggerOf(ctx).Info(fmt.Sprintf("Call to %s of the %s service succeeded after %d retries", method, connection.service, attempt))
+			}
+			return err
+		}
+		if attempt >= policy.MaxRetries {
+			connection.metrics.record(method, attempt, true)
+			connection.loggerOf(ctx).Error(err, fmt.Sprintf("Call to %s of the %s service failed after %d retries", method, connection.service, attempt))
+			return err
+		}
+		delay := policy.backoff(attempt)
+		connection.loggerOf(ctx).Warn(fmt.Sprintf(
+			"Retrying call to %s of the %s service in %s, retry %d of %d: %v",
+			method, connection.service, delay, attempt+1, policy.MaxRetries, err,
+		))
+		if sleepErr := connection.sleep(ctx, delay); sleepErr != nil {
+			connection.metrics.record(method, attempt, false)
+			return err
+		}
+	}
+}
+
+// NewStream opens the stream with the service, streams are never retried
+func (connection *RetryingConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return connection.connection.NewStream(ctx, desc, method, opts...)
+}
+
+// loggerOf returns the logger of the request when there is one, calls made outside of a request such as
+// the public key refresh use the logger of the connection
+func (connection *RetryingConnection) loggerOf(ctx context.Context) commonLogger.Loggerer {
+	if logger, err := commonLogger.GetLoggerFromContext(ctx); err == nil {
+		return logger
+	}
+	return connection.logger
+}
+
+// backoff doubles the delay on every retry with full jitter
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.InitialBackoff << attempt
+	if policy.MaxBackoff > 0 && (delay > policy.MaxBackoff || delay <= 0) {
+		delay = policy.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryConnection retries the configured methods of the calls to the service when the retries are enabled
+func RetryConnection(service string, connection grpc.ClientConnInterface, configurations *config.Config) grpc.ClientConnInterface {
+	retriesConfig := configurations.Retries
+	if !retriesConfig.Enabled || len(retriesConfig.Methods) == 0 {
+		return connection
+	}
+	policies := make(map[string]RetryPolicy, len(retriesConfig.Methods))
+	for _, methodConfig := range retriesConfig.Methods {
+		policy := RetryPolicy{
+			MaxRetries:     retriesConfig.MaxRetries,
+			InitialBackoff: retriesConfig.InitialBackoff,
+			MaxBackoff:     retriesConfig.MaxBackoff,
+		}
+		if methodConfig.MaxRetries != nil {
+			policy.MaxRetries = *methodConfig.MaxRetries
+		}
+		if methodConfig.InitialBackoff > 0 {
+			policy.InitialBackoff = methodConfig.InitialBackoff
+		}
+		if methodConfig.MaxBackoff > 0 {
+			policy.MaxBackoff = methodConfig.MaxBackoff
+		}
+		policies[methodConfig.Method] = policy
+	}
+	return &RetryingConnection{
+		service:    service,
+		policies:   policies,
+		connection: connection,
+		metrics:    DefaultRetryMetrics,
+		logger:     commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+		sleep:      sleepContext,
+	}
+}
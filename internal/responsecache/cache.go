@@ -0,0 +1,358 @@
+package responsecache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/routetable"
+)
+
+// Stores of the cached responses
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// CacheHeader tells the client whether the response was served from the cache, HIT or MISS
+const CacheHeader = "X-Cache"
+
+const (
+	defaultMaxEntries   = 1000
+	defaultMaxBodyBytes = 1 << 20
+	defaultTTL          = 30 * time.Second
+	// lookupContextKey holds the lookup of the request for the authenticated hook and the storing of its response
+	lookupContextKey = "response_cache_lookup"
+)
+
+// storedHeaders are the response headers kept with a cached response, the others belong to the request answered
+var storedHeaders = []string{"Content-Type", "Content-Encoding", "Content-Language", "Cache-Control", "ETag", "Last-Modified"}
+
+// lookup is the cache state of a request of a cached route, its key is known once the principal of the request is
+type lookup struct {
+	route      string
+	ttl        time.Duration
+	key        string
+	revalidate bool
+	served     bool
+}
+
+// Cache serves the responses of the configured GET routes from the store, keyed by the path, the query and
+// the principal of the request. The requests authenticated with an access token or an API key are looked up by
+// the authentication hooks, once their principal is verified and the other hooks allowed them. Only the routes
+// requiring no authentication are looked up for the anonymous requests, the others are not cached for them
+type Cache struct {
+	store         Storer
+	maxBodyBytes  int
+	routes        map[string]time.Duration
+	invalidations map[string][]string
+	publicRoutes  sync.Map
+}
+
+// NewCache creates the response cache of the configuration
+func NewCache(configurations *config.Config) (*Cache, error) {
+	cacheConfig := configurations.ResponseCache
+	maxEntries := cacheConfig.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	var store Storer
+	switch cacheConfig.Store {
+	case "", StoreMemory:
+		store = NewMemoryStore(maxEntries)
+	case StoreRedis:
+		if configurations.Redis.Address == "" {
+			return nil, fmt.Errorf("The redis response cache store requires a redis address")
+		}
+		store = NewRedisStore(redis.NewClient(configurations))
+	default:
+		return nil, fmt.Errorf("Unknown response cache store %s", cacheConfig.Store)
+	}
+	return NewCacheWithStore(store, configurations)
+}
+
+// NewCacheWithStore creates the response cache of the configuration keeping the responses in the store
+func NewCacheWithStore(store Storer, configurations *config.Config) (*Cache, error) {
+	cacheConfig := configurations.ResponseCache
+	cache := &Cache{
+		store:         store,
+		maxBodyBytes:  cacheConfig.MaxBodyBytes,
+		routes:        make(map[string]time.Duration),
+		invalidations: make(map[string][]string),
+	}
+	if cache.maxBodyBytes <= 0 {
+		cache.maxBodyBytes = defaultMaxBodyBytes
+	}
+	ttl := cacheConfig.DefaultTTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	for _, route := range cacheConfig.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("The cached route %s needs a path starting with /", route.Path)
+		}
+		cachedRoute := routeKey(http.MethodGet, route.Path)
+		if _, exists := cache.routes[cachedRoute]; exists {
+			return nil, fmt.Errorf("The cached route %s is defined twice", cachedRoute)
+		}
+		cache.routes[cachedRoute] = ttl
+		if route.TTL > 0 {
+			cache.routes[cachedRoute] = route.TTL
+		}
+		for _, mutation := range route.InvalidatedBy {
+			method := strings.ToUpper(mutation.Method)
+			if method == "" || method == http.MethodGet || method == http.MethodHead || !strings.HasPrefix(mutation.Path, "/") {
+				return nil, fmt.Errorf("The mutation %s %s of the cached route %s needs a method other than GET and a path starting with /", mutation.Method, mutation.Path, cachedRoute)
+			}
+			mutationRoute := routeKey(method, mutation.Path)
+			cache.invalidations[mutationRoute] = append(cache.invalidations[mutationRoute], cachedRoute)
+		}
+	}
+	return cache, nil
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// Store returns the store keeping the cached responses
+func (cache *Cache) Store() Storer {
+	return cache.store
+}
+
+// Middleware serves the cached responses of the anonymous requests of the public routes and caches the responses
+// of the cached routes, the successful requests of the mutations drop the cached responses of the routes they change
+func (cache *Cache) Middleware(ctx *gin.Context) {
+	route := routeKey(ctx.Request.Method, ctx.FullPath())
+	ttl, cached := cache.routes[route]
+	if !cached {
+		ctx.Next()
+		if cachedRoutes := cache.invalidations[route]; len(cachedRoutes) > 0 && ctx.Writer.Status() < http.StatusBadRequest {
+			cache.invalidate(ctx, cachedRoutes)
+		}
+		return
+	}
+	requestDirectives := parseCacheControl(ctx.Request.Header.Values("Cache-Control"))
+	if requestDirectives.has("no-store") {
+		ctx.Next()
+		return
+	}
+	requestLookup := &lookup{
+		route:      route,
+		ttl:        ttl,
+		revalidate: requestDirectives.has("no-cache") || requestDirectives.maxAge("max-age") == 0,
+	}
+	ctx.Set(lookupContextKey, requestLookup)
+	if ctx.GetHeader("Authorization") == "" && cache.public(ctx, route) {
+		cache.lookUp(ctx, requestLookup, "")
+		if requestLookup.served {
+			return
+		}
+	}
+	original := ctx.Writer
+	writer := &capturingWriter{ResponseWriter: original, limit: cache.maxBodyBytes}
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = original
+	if requestLookup.key == "" || requestLookup.served || writer.truncated {
+		return
+	}
+	cache.save(ctx, requestLookup, writer)
+}
+
+// OnAuthenticated serves the cached response of the verified user, it is registered after the other hooks
+// so a cached response is only served to the requests they allowed
+func (cache *Cache) OnAuthenticated(ctx *gin.Context, claims *commonJWT.TokenClaims) {
+	value, exists := ctx.Get(lookupContextKey)
+	if !exists {
+		return
+	}
+	requestLookup := value.(*lookup)
+	if requestLookup.key != "" {
+		return
+	}
+	cache.lookUp(ctx, requestLookup, "user:"+claims.UserID)
+}
+
+// OnAPIKeyAuthenticated serves the cached response of the verified API key, it is registered as an API key hook
+func (cache *Cache) OnAPIKeyAuthenticated(ctx *gin.Context, apiKey *authentication.APIKey) {
+	value, exists := ctx.Get(lookupContextKey)
+	if !exists {
+		return
+	}
+	requestLookup := value.(*lookup)
+	if requestLookup.key != "" {
+		return
+	}
+	cache.lookUp(ctx, requestLookup, "api_key:"+apiKey.ID)
+}
+
+// public tells whether the handlers of the route require no authentication, the anonymous requests of the other
+// routes are neither served nor stored since they are rejected or authenticated later on
+func (cache *Cache) public(ctx *gin.Context, route string) bool {
+	if public, known := cache.publicRoutes.Load(route); known {
+		return public.(bool)
+	}
+	required, _ := routetable.RequiredAuthentication(ctx.HandlerNames())
+	public := required == routetable.AuthenticationPublic
+	cache.publicRoutes.Store(route, public)
+	return public
+}
+
+// lookUp keys the request for the principal, empty for the anonymous requests, and answers it with its cached
+// response unless the client revalidates it
+func (cache *Cache) lookUp(ctx *gin.Context, requestLookup *lookup, principal string) {
+	requestLookup.key = requestKey(ctx.Request, principal)
+	ctx.Header(CacheHeader, "MISS")
+	if requestLookup.revalidate {
+		return
+	}
+	entry, err := cache.store.Get(ctx.Request.Context(), requestLookup.route, requestLookup.key)
+	if err != nil {
+		logError(ctx.Request.Context(), err, "Could not read the response cache")
+		return
+	}
+	if entry == nil {
+		return
+	}
+	for name, values := range entry.Header {
+		ctx.Writer.Header()[name] = values
+	}
+	ctx.Header(CacheHeader, "HIT")
+	ctx.Header("Age", strconv.FormatInt(int64(time.Since(entry.StoredAt).Seconds()), 10))
+	ctx.Writer.WriteHeader(entry.Status)
+	ctx.Writer.Write(entry.Body)
+	ctx.Abort()
+	requestLookup.served = true
+}
+
+// save caches the successful response for the max age of its Cache-Control header, or the TTL of the route
+// without one, unless the header forbids shared caches to store it or it sets a cookie
+func (cache *Cache) save(ctx *gin.Context, requestLookup *lookup, writer *capturingWriter) {
+	header := writer.Header()
+	if writer.Status() != http.StatusOK || header.Get("Set-Cookie") != "" {
+		return
+	}
+	responseDirectives := parseCacheControl(header.Values("Cache-Control"))
+	if responseDirectives.has("no-store") || responseDirectives.has("no-cache") || responseDirectives.has("private") {
+		return
+	}
+	ttl := requestLookup.ttl
+	if maxAge := responseDirectives.maxAge("s-maxage"); maxAge >= 0 {
+		ttl = time.Duration(maxAge) * time.Second
+	} else if maxAge := responseDirectives.maxAge("max-age"); maxAge >= 0 {
+		ttl = time.Duration(maxAge) * time.Second
+	}
+	if ttl <= 0 {
+		return
+	}
+	entry := &Entry{Status: writer.Status(), Header: http.Header{}, Body: writer.body.Bytes(), StoredAt: time.Now()}
+	for _, name := range storedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			entry.Header[name] = values
+		}
+	}
+	if err := cache.store.Set(ctx.Request.Context(), requestLookup.route, requestLookup.key, entry, ttl); err != nil {
+		logError(ctx.Request.Context(), err, "Could not store the response in the response cache")
+	}
+}
+
+// invalidate drops the cached responses of the routes changed by the mutation, for every user
+func (cache *Cache) invalidate(ctx *gin.Context, cachedRoutes []string) {
+	for _, cachedRoute := range cachedRoutes {
+		if err := cache.store.Invalidate(ctx.Request.Context(), cachedRoute); err != nil {
+			logError(ctx.Request.Context(), err, "Could not invalidate the response cache")
+		}
+	}
+}
+
+// requestKey hashes the path, the sorted query and the principal of the request so the keys are bounded and do not
+// reveal them
+func requestKey(request *http.Request, principal string) string {
+	hash := sha256.Sum256([]byte(request.URL.Path + "?" + request.URL.Query().Encode() + "\x00" + principal))
+	return hex.EncodeToString(hash[:])
+}
+
+func logError(ctx context.Context, err error, message string) {
+	if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx); loggerErr == nil {
+		logger.Error(err, message)
+	}
+}
+
+// cacheControl holds the directives of Cache-Control headers with their values, empty for the directives without one
+type cacheControl map[string]string
+
+func parseCacheControl(headers []string) cacheControl {
+	directives := cacheControl{}
+	for _, header := range headers {
+		for _, directive := range strings.Split(header, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+func (directives cacheControl) has(name string) bool {
+	_, exists := directives[name]
+	return exists
+}
+
+// maxAge returns the seconds of the directive, -1 when it is missing or invalid
+func (directives cacheControl) maxAge(name string) int {
+	value, exists := directives[name]
+	if !exists {
+		return -1
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return -1
+	}
+	return seconds
+}
+
+// capturingWriter keeps the response body for the cache, up to the limit
+type capturingWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (writer *capturingWriter) capture(data []byte) {
+	if writer.truncated {
+		return
+	}
+	if writer.body.Len()+len(data) > writer.limit {
+		writer.truncated = true
+		writer.body.Reset()
+		return
+	}
+	writer.body.Write(data)
+}
+
+func (writer *capturingWriter) Write(data []byte) (int, error) {
+	writer.capture(data)
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *capturingWriter) WriteString(data string) (int, error) {
+	writer.capture([]byte(data))
+	return writer.ResponseWriter.WriteString(data)
+}
@@ -0,0 +1,210 @@
+package responsecache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// tokenAuthentication stands for the access token checks, the user of the request is its Authorization header
+type tokenAuthentication struct {
+	authentication.AutheticationMiddlewarer
+	cache *Cache
+}
+
+func (tokenAuthentication *tokenAuthentication) RequireAuthentication(ctx *gin.Context) {
+	user := ctx.GetHeader("Authorization")
+	if user == "" {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	tokenAuthentication.cache.OnAuthenticated(ctx, &commonJWT.TokenClaims{UserID: user})
+}
+
+func TestCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cacheConfig := config.ResponseCacheConfig{
+		Enabled:    true,
+		DefaultTTL: time.Minute,
+		Routes: []config.ResponseCacheRouteConfig{
+			{Path: "/api/v1/reference/countries"},
+			{
+				Path:          "/api/v1/payments/methods",
+				InvalidatedBy: []config.ResponseCacheMutationConfig{{Method: "delete", Path: "/api/v1/payments/methods/:paymentMethodID"}},
+			},
+		},
+	}
+	apiKeysConfig := config.APIKeysConfig{
+		Enabled: true,
+		Keys: []config.APIKeyConfig{
+			{ID: "reporting-key", Client: "reporting", Hash: authentication.HashAPIKey("reporting-secret")},
+			{ID: "billing-key", Client: "billing", Hash: authentication.HashAPIKey("billing-secret")},
+		},
+		Routes: []string{"/api/v1/payments/methods"},
+	}
+	newRouter := func(t *testing.T, cacheControl string) (*gin.Engine, *int) {
+		configurations := &config.Config{ResponseCache: cacheConfig, APIKeys: apiKeysConfig}
+		cache, err := NewCache(configurations)
+		assert.NoError(t, err)
+		apiKeyStore, err := authentication.NewAPIKeyStore(configurations, nil)
+		assert.NoError(t, err)
+		apiKeyMiddleware := authentication.NewAPIKeyMiddleware(apiKeyStore, configurations)
+		apiKeyMiddleware.OnAuthenticated(cache.OnAPIKeyAuthenticated)
+		authenticationMiddleware := authentication.WithAPIKeys(&tokenAuthentication{cache: cache}, apiKeyMiddleware)
+		controller := gomock.NewController(t)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		loggerMock.EXPECT().Info(gomock.Any()).AnyTimes()
+		loggerMock.EXPECT().Error(gomock.Any(), gomock.Any()).AnyTimes()
+		calls := 0
+		router := gin.New()
+		router.Use(func(ctx *gin.Context) {
+			ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+		}, cache.Middleware)
+		handler := func(ctx *gin.Context) {
+			calls++
+			if cacheControl != "" {
+				ctx.Header("Cache-Control", cacheControl)
+			}
+			ctx.JSON(http.StatusOK, gin.H{"calls": calls, "user": ctx.GetHeader("Authorization")})
+		}
+		router.GET("/api/v1/reference/countries", handler)
+		router.GET("/api/v1/payments/methods", authenticationMiddleware.RequireAuthentication, handler)
+		router.DELETE("/api/v1/payments/methods/:paymentMethodID", func(ctx *gin.Context) {
+			ctx.Status(http.StatusNoContent)
+		})
+		return router, &calls
+	}
+	serve := func(router *gin.Engine, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, nil)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("Middleware_Should_Serve_The_Cached_Response_Per_Path_And_Query", func(t *testing.T) {
+		router, calls := newRouter(t, "")
+
+		first := serve(router, http.MethodGet, "/api/v1/reference/countries?page=1&size=10", nil)
+		second := serve(router, http.MethodGet, "/api/v1/reference/countries?size=10&page=1", nil)
+		other := serve(router, http.MethodGet, "/api/v1/reference/countries?page=2", nil)
+
+		assert.Equal(t, "MISS", first.Header().Get(CacheHeader))
+		assert.Equal(t, "HIT", second.Header().Get(CacheHeader))
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+		assert.Equal(t, "MISS", other.Header().Get(CacheHeader))
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("OnAuthenticated_Should_Key_The_Cached_Responses_Per_User", func(t *testing.T) {
+		router, calls := newRouter(t, "")
+
+		serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{"Authorization": "first-user"})
+		cached := serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{"Authorization": "first-user"})
+		other := serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{"Authorization": "second-user"})
+
+		assert.Equal(t, "HIT", cached.Header().Get(CacheHeader))
+		assert.JSONEq(t, `{"calls":1,"user":"first-user"}`, cached.Body.String())
+		assert.JSONEq(t, `{"calls":2,"user":"second-user"}`, other.Body.String())
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("OnAPIKeyAuthenticated_Should_Key_The_Cached_Responses_Per_API_Key", func(t *testing.T) {
+		router, calls := newRouter(t, "")
+
+		serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{authentication.DefaultAPIKeyHeader: "reporting-secret"})
+		cached := serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{authentication.DefaultAPIKeyHeader: "reporting-secret"})
+		other := serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{authentication.DefaultAPIKeyHeader: "billing-secret"})
+		anonymous := serve(router, http.MethodGet, "/api/v1/payments/methods", nil)
+
+		assert.Equal(t, "HIT", cached.Header().Get(CacheHeader))
+		assert.Equal(t, "MISS", other.Header().Get(CacheHeader))
+		assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+		assert.Empty(t, anonymous.Header().Get(CacheHeader))
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("Middleware_Should_Not_Serve_The_Anonymous_Requests_Of_The_Authenticated_Routes", func(t *testing.T) {
+		router, calls := newRouter(t, "")
+
+		serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{"Authorization": "first-user"})
+		anonymous := serve(router, http.MethodGet, "/api/v1/payments/methods", nil)
+
+		assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+		assert.NotContains(t, anonymous.Body.String(), "first-user")
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("Middleware_Should_Not_Store_The_Responses_Forbidding_It", func(t *testing.T) {
+		router, calls := newRouter(t, "private, max-age=60")
+
+		serve(router, http.MethodGet, "/api/v1/reference/countries", nil)
+		serve(router, http.MethodGet, "/api/v1/reference/countries", nil)
+
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("Middleware_Should_Revalidate_On_Request_No_Cache", func(t *testing.T) {
+		router, calls := newRouter(t, "")
+
+		serve(router, http.MethodGet, "/api/v1/reference/countries", nil)
+		revalidated := serve(router, http.MethodGet, "/api/v1/reference/countries", map[string]string{"Cache-Control": "no-cache"})
+		cached := serve(router, http.MethodGet, "/api/v1/reference/countries", nil)
+
+		assert.Equal(t, "MISS", revalidated.Header().Get(CacheHeader))
+		assert.JSONEq(t, `{"calls":2,"user":""}`, cached.Body.String())
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("Middleware_Should_Invalidate_The_Route_On_Its_Mutations", func(t *testing.T) {
+		router, calls := newRouter(t, "")
+
+		serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{"Authorization": "first-user"})
+		assert.Equal(t, http.StatusNoContent, serve(router, http.MethodDelete, "/api/v1/payments/methods/card-1", nil).Code)
+		refreshed := serve(router, http.MethodGet, "/api/v1/payments/methods", map[string]string{"Authorization": "first-user"})
+
+		assert.Equal(t, "MISS", refreshed.Header().Get(CacheHeader))
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("MemoryStore_Should_Evict_The_Least_Recently_Used_Entry", func(t *testing.T) {
+		store := NewMemoryStore(2)
+		ctx := context.Background()
+		assert.NoError(t, store.Set(ctx, "GET /a", "first", &Entry{Status: http.StatusOK}, time.Minute))
+		assert.NoError(t, store.Set(ctx, "GET /a", "second", &Entry{Status: http.StatusOK}, time.Minute))
+		entry, _ := store.Get(ctx, "GET /a", "first")
+		assert.NotNil(t, entry)
+
+		assert.NoError(t, store.Set(ctx, "GET /b", "third", &Entry{Status: http.StatusOK}, time.Minute))
+
+		assert.Equal(t, 2, store.Len())
+		evicted, _ := store.Get(ctx, "GET /a", "second")
+		assert.Nil(t, evicted)
+		assert.NoError(t, store.Invalidate(ctx, "GET /a"))
+		assert.Equal(t, 1, store.Len())
+	})
+
+	t.Run("NewCache_Should_Reject_GET_Mutations", func(t *testing.T) {
+		_, err := NewCache(&config.Config{ResponseCache: config.ResponseCacheConfig{Routes: []config.ResponseCacheRouteConfig{{
+			Path:          "/api/v1/payments/methods",
+			InvalidatedBy: []config.ResponseCacheMutationConfig{{Method: "GET", Path: "/api/v1/payments/methods"}},
+		}}}})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,193 @@
+package responsecache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+)
+
+const (
+	entryKeyPrefix      = "response-cache:entry:"
+	generationKeyPrefix = "response-cache:generation:"
+)
+
+// Entry is a cached response
+type Entry struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// Storer keeps the cached responses of the routes, the keys of a route are dropped together on invalidation
+type Storer interface {
+	Get(ctx context.Context, route, key string) (*Entry, error)
+	Set(ctx context.Context, route, key string, entry *Entry, ttl time.Duration) error
+	Invalidate(ctx context.Context, route string) error
+}
+
+type memoryEntry struct {
+	route     string
+	key       string
+	entry     *Entry
+	expiresAt time.Time
+}
+
+// MemoryStore keeps the cached responses in the gateway memory, evicting the least recently used one
+// beyond the max entries
+type MemoryStore struct {
+	maxEntries int
+	recency    *list.List
+	routes     map[string]map[string]*list.Element
+	mtx        sync.Mutex
+}
+
+var _ Storer = &MemoryStore{}
+
+// NewMemoryStore creates an in memory store of at most the max entries
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		recency:    list.New(),
+		routes:     make(map[string]map[string]*list.Element),
+	}
+}
+
+// Get returns the cached response or nil when there is none
+func (store *MemoryStore) Get(ctx context.Context, route, key string) (*Entry, error) {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	element, exists := store.routes[route][key]
+	if !exists {
+		return nil, nil
+	}
+	cached := element.Value.(*memoryEntry)
+	if time.Now().After(cached.expiresAt) {
+		store.remove(element)
+		return nil, nil
+	}
+	store.recency.MoveToFront(element)
+	return cached.entry, nil
+}
+
+// Set caches the response for the given time
+func (store *MemoryStore) Set(ctx context.Context, route, key string, entry *Entry, ttl time.Duration) error {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	if element, exists := store.routes[route][key]; exists {
+		store.remove(element)
+	}
+	if store.routes[route] == nil {
+		store.routes[route] = make(map[string]*list.Element)
+	}
+	store.routes[route][key] = store.recency.PushFront(&memoryEntry{
+		route:     route,
+		key:       key,
+		entry:     entry,
+		expiresAt: time.Now().Add(ttl),
+	})
+	for store.recency.Len() > store.maxEntries {
+		store.remove(store.recency.Back())
+	}
+	return nil
+}
+
+// Invalidate drops the cached responses of the route
+func (store *MemoryStore) Invalidate(ctx context.Context, route string) error {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	for _, element := range store.routes[route] {
+		store.recency.Remove(element)
+	}
+	delete(store.routes, route)
+	return nil
+}
+
+// Len returns the number of cached responses, expired or not
+func (store *MemoryStore) Len() int {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	return store.recency.Len()
+}
+
+func (store *MemoryStore) remove(element *list.Element) {
+	cached := store.recency.Remove(element).(*memoryEntry)
+	delete(store.routes[cached.route], cached.key)
+	if len(store.routes[cached.route]) == 0 {
+		delete(store.routes, cached.route)
+	}
+}
+
+// RedisStore keeps the cached responses in redis so they are shared between gateway instances. The keys of
+// a route carry its generation, incremented on invalidation so the previous responses are no longer read
+// and expire with their TTL
+type RedisStore struct {
+	client redis.Clienter
+}
+
+var _ Storer = &RedisStore{}
+
+// NewRedisStore creates a store backed by redis
+func NewRedisStore(client redis.Clienter) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Get returns the cached response or nil when there is none
+func (store *RedisStore) Get(ctx context.Context, route, key string) (*Entry, error) {
+	entryKey, err := store.entryKey(ctx, route, key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := store.client.Get(ctx, entryKey)
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not get the cached response: %v", err)
+	}
+	entry := &Entry{}
+	if err := json.Unmarshal([]byte(value), entry); err != nil {
+		return nil, fmt.Errorf("Could not decode the cached response: %v", err)
+	}
+	return entry, nil
+}
+
+// Set caches the response for the given time
+func (store *RedisStore) Set(ctx context.Context, route, key string, entry *Entry, ttl time.Duration) error {
+	entryKey, err := store.entryKey(ctx, route, key)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Could not encode the cached response: %v", err)
+	}
+	if err := store.client.Set(ctx, entryKey, string(value), ttl); err != nil {
+		return fmt.Errorf("Could not cache the response: %v", err)
+	}
+	return nil
+}
+
+// Invalidate moves the route to its next generation
+func (store *RedisStore) Invalidate(ctx context.Context, route string) error {
+	if _, err := store.client.Do(ctx, "INCR", generationKeyPrefix+route); err != nil {
+		return fmt.Errorf("Could not invalidate the cached responses of %s: %v", route, err)
+	}
+	return nil
+}
+
+func (store *RedisStore) entryKey(ctx context.Context, route, key string) (string, error) {
+	generation, err := store.client.Get(ctx, generationKeyPrefix+route)
+	if err == redis.ErrNil {
+		generation = "0"
+	} else if err != nil {
+		return "", fmt.Errorf("Could not get the generation of the cached responses of %s: %v", route, err)
+	}
+	return entryKeyPrefix + route + ":" + generation + ":" + key, nil
+}
@@ -0,0 +1,239 @@
+package routeregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/grpcjson"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+var allowedMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// Route is a declared route registered on the API
+type Route struct {
+	Service    string
+	Method     string
+	Path       string
+	GRPCMethod string
+	Public     bool
+	Roles      []string
+	Audience   string
+}
+
+// Registry holds the declared routes of the upstream services
+type Registry struct {
+	routes []Route
+}
+
+// Validate checks the declared services and routes before any of them is registered
+func Validate(configurations *config.Config) error {
+	services := make(map[string]bool)
+	routes := make(map[string]bool)
+	for _, service := range configurations.UpstreamRoutes.Services {
+		if service.Name == "" || service.Host == "" || service.Port == "" {
+			return fmt.Errorf("The upstream service %q needs a name, a host and a port", service.Name)
+		}
+		if services[service.Name] {
+			return fmt.Errorf("The upstream service %s is declared twice", service.Name)
+		}
+		services[service.Name] = true
+		for _, route := range service.Routes {
+			method := strings.ToUpper(route.Method)
+			if !allowedMethods[method] {
+				return fmt.Errorf("The route %s %s of the %s service has an unsupported method", route.Method, route.Path, service.Name)
+			}
+			if !strings.HasPrefix(route.Path, "/") {
+				return fmt.Errorf("The route path %s of the %s service must start with /", route.Path, service.Name)
+			}
+			if !isGRPCMethod(route.GRPCMethod) {
+				return fmt.Errorf(
+					"The route %s %s of the %s service needs a full gRPC method such as /package.Service/Method",
+					method, route.Path, service.Name,
+				)
+			}
+			if route.Public && (len(route.Roles) > 0 || route.Audience != "") {
+				return fmt.Errorf("The public route %s %s of the %s service can not require roles or an audience", method, route.Path, service.Name)
+			}
+			key := method + " " + route.Path
+			if routes[key] {
+				return fmt.Errorf("The route %s is declared twice", key)
+			}
+			routes[key] = true
+		}
+	}
+	return nil
+}
+
+func isGRPCMethod(method string) bool {
+	parts := strings.Split(method, "/")
+	return len(parts) == 3 && parts[0] == "" && parts[1] != "" && parts[2] != "" && strings.Contains(parts[1], ".")
+}
+
+// RegisterRoutes connects to the declared upstream services and registers their routes, authenticated unless public
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*Registry, error) {
+	if err := Validate(configurations); err != nil {
+		return nil, err
+	}
+	registry := &Registry{}
+	for _, service := range configurations.UpstreamRoutes.Services {
+		connection, err := ConnectService(service.Name, fmt.Sprintf("%s:%s", service.Host, service.Port), centralConfig, configurations)
+		if err != nil {
+			return nil, err
+		}
+		for _, routeConfig := range service.Routes {
+			route := Route{
+				Service:    service.Name,
+				Method:     strings.ToUpper(routeConfig.Method),
+				Path:       routeConfig.Path,
+				GRPCMethod: routeConfig.GRPCMethod,
+				Public:     routeConfig.Public,
+				Roles:      routeConfig.Roles,
+				Audience:   routeConfig.Audience,
+			}
+			handlers := []gin.HandlerFunc{}
+			if !route.Public {
+				handlers = append(handlers, authenticationMiddleware.RequireAuthentication)
+			}
+			if route.Audience != "" {
+				handlers = append(handlers, authenticationMiddleware.RequireAudience(route.Audience))
+			}
+			if len(route.Roles) > 0 {
+				handlers = append(handlers, authenticationMiddleware.RequireRole(route.Roles...))
+			}
+			if err := handle(api, route, append(handlers, Handler(route, connection))); err != nil {
+				return nil, err
+			}
+			registry.routes = append(registry.routes, route)
+		}
+	}
+	return registry, nil
+}
+
+// handle registers the route, turning the panic of gin on a route conflicting with another into an error
+func handle(api *gin.RouterGroup, route Route, handlers []gin.HandlerFunc) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("The route %s %s of the %s service conflicts with another route: %v", route.Method, route.Path, route.Service, recovered)
+		}
+	}()
+	api.Handle(route.Method, route.Path, handlers...)
+	return nil
+}
+
+// ConnectService connects to a declared service through the egress, region, versioning, tracing, metrics,
+// retry, circuit breaker and hooks layers of the gateway services
+func ConnectService(
+	name string,
+	address string,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+) (grpc.ClientConnInterface, error) {
+	fmt.Println("Connecting to", name, "service at", address, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection(name, address, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc %s service: %v", name, err)
+	}
+	regionalConnection, err := region.RouteConnection(name, clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+	tenantConnection, err := tenancy.RouteConnection(name, regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+	routedConnection, err := versioning.RouteConnection(name, tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+	tracedConnection := tracing.TraceConnection(name, routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection(name, tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection(name, instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection(name, retryingConnection, configurations)
+	return hooks.HookConnection(name, breakerConnection, configurations), nil
+}
+
+// Handler calls the gRPC method of the route with the query parameters, the JSON body fields and the path
+// parameters of the request, the path parameters taking precedence, and answers the JSON response as is
+func Handler(route Route, connection grpc.ClientConnInterface) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		request, err := requestMessage(ctx)
+		if err != nil {
+			errors.Abort(ctx, errors.InvalidRequestBody)
+			return
+		}
+		response := json.RawMessage{}
+		if err := grpcjson.Invoke(ctx.Request.Context(), connection, route.GRPCMethod, request, &response); err != nil {
+			errors.HandleError(ctx, err)
+			return
+		}
+		if len(response) == 0 {
+			response = json.RawMessage("{}")
+		}
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", response)
+	}
+}
+
+// requestMessage merges the query parameters, the fields of the JSON object body and the path parameters
+func requestMessage(ctx *gin.Context) (map[string]interface{}, error) {
+	request := make(map[string]interface{})
+	for name, values := range ctx.Request.URL.Query() {
+		if len(values) == 1 {
+			request[name] = values[0]
+			continue
+		}
+		request[name] = values
+	}
+	if ctx.Request.Body != nil {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(strings.TrimSpace(string(body))) > 0 {
+			fields := make(map[string]interface{})
+			if err := json.Unmarshal(body, &fields); err != nil {
+				return nil, err
+			}
+			for name, value := range fields {
+				request[name] = value
+			}
+		}
+	}
+	for _, param := range ctx.Params {
+		request[param.Key] = param.Value
+	}
+	return request, nil
+}
+
+// Routes returns the registered routes
+func (registry *Registry) Routes() []Route {
+	return append([]Route{}, registry.routes...)
+}
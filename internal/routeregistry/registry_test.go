@@ -0,0 +1,116 @@
+package routeregistry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// fakeConnection records the calls and answers them with the response or the error
+type fakeConnection struct {
+	method   string
+	request  map[string]interface{}
+	response string
+	err      error
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	connection.method = method
+	connection.request = args.(map[string]interface{})
+	if connection.err != nil {
+		return connection.err
+	}
+	*reply.(*json.RawMessage) = json.RawMessage(connection.response)
+	return nil
+}
+
+func (connection *fakeConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Error(codes.Unimplemented, "streams are not supported")
+}
+
+func serveRoute(connection *fakeConnection, method, target, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	route := Route{Service: "ledger", Method: http.MethodPost, Path: "/ledger/accounts/:accountID/entries", GRPCMethod: "/pb_ledger.LedgerService/CreateEntry"}
+	router.Handle(route.Method, route.Path, Handler(route, connection))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return recorder
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("Handler_Should_Call_The_GRPC_Method_With_The_Merged_Request", func(t *testing.T) {
+		connection := &fakeConnection{response: `{"entryID":"entry-id"}`}
+
+		recorder := serveRoute(connection, http.MethodPost, "/ledger/accounts/account-id/entries?currency=EUR&tag=a&tag=b", `{"amount":10,"accountID":"other"}`)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"entryID":"entry-id"}`, recorder.Body.String())
+		assert.Equal(t, "/pb_ledger.LedgerService/CreateEntry", connection.method)
+		assert.Equal(t, map[string]interface{}{
+			"accountID": "account-id",
+			"amount":    float64(10),
+			"currency":  "EUR",
+			"tag":       []string{"a", "b"},
+		}, connection.request)
+	})
+
+	t.Run("Handler_Should_Map_The_GRPC_Errors", func(t *testing.T) {
+		connection := &fakeConnection{err: status.Error(codes.NotFound, "account not found")}
+
+		recorder := serveRoute(connection, http.MethodPost, "/ledger/accounts/account-id/entries", "")
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("Handler_Should_Reject_A_Body_Not_A_JSON_Object", func(t *testing.T) {
+		connection := &fakeConnection{}
+
+		recorder := serveRoute(connection, http.MethodPost, "/ledger/accounts/account-id/entries", `[1,2]`)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.JSONEq(t, `{"error":{"code":"invalid_request_body","message":"The request body is invalid"}}`, recorder.Body.String())
+		assert.Empty(t, connection.method)
+	})
+
+	t.Run("Validate_Should_Reject_The_Invalid_Declarations", func(t *testing.T) {
+		route := config.UpstreamRouteConfig{Method: "GET", Path: "/ledger/accounts", GRPCMethod: "/pb_ledger.LedgerService/ListAccounts"}
+		for name, services := range map[string][]config.UpstreamServiceConfig{
+			"missing host":   {{Name: "ledger", Port: "9097"}},
+			"duplicate name": {{Name: "ledger", Host: "localhost", Port: "9097"}, {Name: "ledger", Host: "localhost", Port: "9098"}},
+			"method":         {{Name: "ledger", Host: "localhost", Port: "9097", Routes: []config.UpstreamRouteConfig{{Method: "TRACE", Path: route.Path, GRPCMethod: route.GRPCMethod}}}},
+			"path":           {{Name: "ledger", Host: "localhost", Port: "9097", Routes: []config.UpstreamRouteConfig{{Method: "GET", Path: "ledger", GRPCMethod: route.GRPCMethod}}}},
+			"grpc method":    {{Name: "ledger", Host: "localhost", Port: "9097", Routes: []config.UpstreamRouteConfig{{Method: "GET", Path: route.Path, GRPCMethod: "ListAccounts"}}}},
+			"public roles":   {{Name: "ledger", Host: "localhost", Port: "9097", Routes: []config.UpstreamRouteConfig{{Method: "GET", Path: route.Path, GRPCMethod: route.GRPCMethod, Public: true, Roles: []string{"admin"}}}}},
+			"duplicate":      {{Name: "ledger", Host: "localhost", Port: "9097", Routes: []config.UpstreamRouteConfig{route, route}}},
+		} {
+			err := Validate(&config.Config{UpstreamRoutes: config.UpstreamRoutesConfig{Services: services}})
+
+			assert.Error(t, err, name)
+		}
+		assert.NoError(t, Validate(&config.Config{UpstreamRoutes: config.UpstreamRoutesConfig{Services: []config.UpstreamServiceConfig{
+			{Name: "ledger", Host: "localhost", Port: "9097", Routes: []config.UpstreamRouteConfig{route}},
+		}}}))
+	})
+
+	t.Run("Handle_Should_Return_The_Route_Conflicts", func(t *testing.T) {
+		router := gin.New()
+		api := router.Group("/api/v1")
+		api.GET("/ledger/accounts/:id", func(ctx *gin.Context) {})
+
+		err := handle(api, Route{Service: "ledger", Method: http.MethodGet, Path: "/ledger/accounts/:accountID"}, []gin.HandlerFunc{func(ctx *gin.Context) {}})
+
+		assert.ErrorContains(t, err, "The route GET /ledger/accounts/:accountID of the ledger service conflicts with another route")
+	})
+}
@@ -0,0 +1,131 @@
+package routetable
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// conflictPathPattern finds the path of the registration gin rejected in its panic message, and
+// conflictPrefixPattern the prefix of the registered routes it conflicts with
+var (
+	conflictPathPattern   = regexp.MustCompile(`(?:new path|for path|in path) '([^']*)'`)
+	conflictPrefixPattern = regexp.MustCompile(`existing prefix '([^']*)'`)
+)
+
+// Conflict is a route conflicting with the routes of the table
+type Conflict struct {
+	Method    string
+	Path      string
+	Reason    string
+	Conflicts []gin.RouteInfo
+}
+
+func (conflict Conflict) String() string {
+	route := conflict.Path
+	if conflict.Method != "" {
+		route = conflict.Method + " " + route
+	}
+	conflicting := make([]string, 0, len(conflict.Conflicts))
+	for _, existing := range conflict.Conflicts {
+		conflicting = append(conflicting, fmt.Sprintf("%s %s (%s)", existing.Method, existing.Path, existing.Handler))
+	}
+	if len(conflicting) == 0 {
+		return fmt.Sprintf("%s: %s", route, conflict.Reason)
+	}
+	return fmt.Sprintf("%s: %s, it overlaps %s", route, conflict.Reason, strings.Join(conflicting, ", "))
+}
+
+// ConflictError reports the conflicting routes found while building the route table
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (conflictError *ConflictError) Error() string {
+	lines := make([]string, 0, len(conflictError.Conflicts)+1)
+	lines = append(lines, fmt.Sprintf("The route table has %d conflicting routes:", len(conflictError.Conflicts)))
+	for _, conflict := range conflictError.Conflicts {
+		lines = append(lines, "  "+conflict.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Recovered turns the panic of gin on a registration conflicting with the registered routes into a conflict
+// error listing the routes it overlaps or sharing the conflicting wildcard, it returns nil when the panic is not
+// a route conflict
+func Recovered(routes gin.RoutesInfo, recovered interface{}) error {
+	message, ok := recovered.(string)
+	if !ok {
+		return nil
+	}
+	match := conflictPathPattern.FindStringSubmatch(message)
+	if match == nil {
+		return nil
+	}
+	conflict := Conflict{Path: match[1], Reason: message}
+	prefix := ""
+	if prefixMatch := conflictPrefixPattern.FindStringSubmatch(message); prefixMatch != nil {
+		prefix = prefixMatch[1]
+	}
+	for _, existing := range routes {
+		if Overlap(existing.Path, conflict.Path) || (prefix != "" && strings.HasPrefix(existing.Path, prefix)) {
+			conflict.Conflicts = append(conflict.Conflicts, existing)
+		}
+	}
+	return &ConflictError{Conflicts: []Conflict{conflict}}
+}
+
+// CheckConflicts returns the conflict error of the routes gin registers without complaining while they overlap:
+// the routes of a method only differing by a trailing slash, served by whichever the client happens to call
+func CheckConflicts(routes gin.RoutesInfo) error {
+	registered := make(map[string]gin.RouteInfo, len(routes))
+	for _, route := range routes {
+		registered[route.Method+" "+route.Path] = route
+	}
+	conflicts := []Conflict{}
+	for _, route := range routes {
+		if !strings.HasSuffix(route.Path, "/") || route.Path == "/" {
+			continue
+		}
+		if existing, exists := registered[route.Method+" "+strings.TrimSuffix(route.Path, "/")]; exists {
+			conflicts = append(conflicts, Conflict{
+				Method:    route.Method,
+				Path:      route.Path,
+				Reason:    "only differs by a trailing slash from a registered route",
+				Conflicts: []gin.RouteInfo{existing},
+			})
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Path != conflicts[j].Path {
+			return conflicts[i].Path < conflicts[j].Path
+		}
+		return conflicts[i].Method < conflicts[j].Method
+	})
+	return &ConflictError{Conflicts: conflicts}
+}
+
+// Overlap tells whether a request path could match both route paths, a parameter matching any segment
+// and a catch-all the rest of the path
+func Overlap(path, other string) bool {
+	segments, otherSegments := strings.Split(path, "/"), strings.Split(other, "/")
+	for index := 0; index < len(segments) && index < len(otherSegments); index++ {
+		segment, otherSegment := segments[index], otherSegments[index]
+		if strings.HasPrefix(segment, "*") || strings.HasPrefix(otherSegment, "*") {
+			return true
+		}
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(otherSegment, ":") {
+			continue
+		}
+		if segment != otherSegment {
+			return false
+		}
+	}
+	return len(segments) == len(otherSegments)
+}
@@ -0,0 +1,74 @@
+package routetable
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func registerRecovered(router *gin.Engine, method, path string) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = Recovered(router.Routes(), recovered)
+		}
+	}()
+	router.Handle(method, path, func(ctx *gin.Context) {})
+	return nil
+}
+
+func TestConflicts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Recovered_Should_Report_The_Duplicate_Routes", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/api/v1/payments/methods", func(ctx *gin.Context) {})
+
+		err := registerRecovered(router, http.MethodGet, "/api/v1/payments/methods")
+
+		assert.ErrorContains(t, err, "The route table has 1 conflicting routes:")
+		assert.ErrorContains(t, err, "/api/v1/payments/methods: handlers are already registered for path '/api/v1/payments/methods', it overlaps GET /api/v1/payments/methods")
+	})
+
+	t.Run("Recovered_Should_Report_The_Wildcard_Conflicts", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/api/v1/media/:mediaID", func(ctx *gin.Context) {})
+		router.GET("/api/v1/media/uploads", func(ctx *gin.Context) {})
+
+		err := registerRecovered(router, http.MethodGet, "/api/v1/media/:id/thumbnail")
+
+		conflictError, ok := err.(*ConflictError)
+		assert.True(t, ok)
+		assert.Equal(t, "/api/v1/media/:id/thumbnail", conflictError.Conflicts[0].Path)
+		assert.Contains(t, conflictError.Conflicts[0].Reason, "conflicts with existing wildcard ':mediaID'")
+		assert.Len(t, conflictError.Conflicts[0].Conflicts, 1)
+		assert.Equal(t, "/api/v1/media/:mediaID", conflictError.Conflicts[0].Conflicts[0].Path)
+	})
+
+	t.Run("Recovered_Should_Ignore_The_Other_Panics", func(t *testing.T) {
+		assert.Nil(t, Recovered(nil, "runtime error: invalid memory address"))
+		assert.Nil(t, Recovered(nil, 42))
+	})
+
+	t.Run("CheckConflicts_Should_Report_The_Routes_Only_Differing_By_A_Trailing_Slash", func(t *testing.T) {
+		router := gin.New()
+		handler := func(ctx *gin.Context) {}
+		router.POST("/api/v1/user/", handler)
+		router.DELETE("/api/v1/user", handler)
+		assert.NoError(t, CheckConflicts(router.Routes()))
+		router.POST("/api/v1/user", handler)
+
+		err := CheckConflicts(router.Routes())
+
+		assert.ErrorContains(t, err, "POST /api/v1/user/: only differs by a trailing slash from a registered route, it overlaps POST /api/v1/user")
+	})
+
+	t.Run("Overlap_Should_Match_The_Parameters_And_Catch_Alls", func(t *testing.T) {
+		assert.True(t, Overlap("/media/:mediaID/thumbnail", "/media/:id/thumbnail"))
+		assert.True(t, Overlap("/media/:mediaID", "/media/uploads"))
+		assert.True(t, Overlap("/static/*filepath", "/static/css/site.css"))
+		assert.False(t, Overlap("/media/:mediaID", "/media/:mediaID/thumbnail"))
+		assert.False(t, Overlap("/media/uploads", "/media/downloads"))
+	})
+}
@@ -0,0 +1,137 @@
+package routetable
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/deadline"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+)
+
+func newExporter(t *testing.T) (*gin.Engine, *Exporter) {
+	gin.SetMode(gin.TestMode)
+	configurations := &config.Config{
+		RateLimits: config.RateLimitsConfig{
+			Groups: []config.RateLimitGroupConfig{
+				{Name: "search", PathPrefix: apiPath + "/search", Key: ratelimit.KeyIP, RateLimit: 5, Burst: 10},
+			},
+			Costs: []config.RateLimitCostConfig{{Method: http.MethodGet, Path: apiPath + "/search/:index", Cost: 3}},
+		},
+		Timeouts: config.TimeoutsConfig{
+			Routes: []config.RouteTimeoutConfig{{Path: apiPath + "/search/:index", Timeout: 2 * time.Second}},
+		},
+		UpstreamRoutes: config.UpstreamRoutesConfig{Enabled: true, Services: []config.UpstreamServiceConfig{
+			{Name: "catalog", Routes: []config.UpstreamRouteConfig{{Method: "get", Path: "/catalog/:productID"}}},
+		}},
+	}
+	limiter, err := ratelimit.NewLimiter(configurations)
+	assert.NoError(t, err)
+	authenticationMiddleware := &fakeAuthentication{}
+	served := false
+
+	router := gin.New()
+	router.Use(Describe)
+	api := router.Group(apiPath)
+	exporter := NewExporter(router, apiPath, configurations)
+	exporter.UseRateLimiter(limiter)
+	exporter.UseTimeouts(deadline.NewTimeouts(configurations))
+	api.GET("/health", func(ctx *gin.Context) { served = true })
+	api.GET("/search/:index", authenticationMiddleware.RequireAuthentication,
+		authenticationMiddleware.RequireRole("admin"), func(ctx *gin.Context) { served = true })
+	api.GET("/catalog/:productID", func(ctx *gin.Context) { served = true })
+	router.GET("/admin/routes/export", exporter.ExportHandler)
+	t.Cleanup(func() { assert.False(t, served) })
+	return router, exporter
+}
+
+func TestExporter(t *testing.T) {
+	t.Run("Export_Should_Describe_The_Routes_Without_Serving_Them", func(t *testing.T) {
+		_, exporter := newExporter(t)
+
+		table := exporter.Export()
+
+		paths := []string{}
+		for _, route := range table.Routes {
+			paths = append(paths, route.Method+" "+route.Path)
+		}
+		assert.Equal(t, []string{
+			"GET /admin/routes/export",
+			"GET " + apiPath + "/catalog/:productID",
+			"GET " + apiPath + "/health",
+			"GET " + apiPath + "/search/:index",
+		}, paths)
+		catalog, health, search := table.Routes[1], table.Routes[2], table.Routes[3]
+		assert.Equal(t, AuthenticationPublic, health.Authentication)
+		assert.Equal(t, "catalog", catalog.Upstream)
+		assert.Equal(t, AuthenticationToken, search.Authentication)
+		assert.True(t, search.RolesRequired)
+		assert.Len(t, search.Handlers, 3)
+		assert.Equal(t, []ratelimit.RouteLimit{
+			{Group: "search", Key: ratelimit.KeyIP, Rate: 5, Burst: 10},
+		}, search.RateLimits)
+		assert.Equal(t, 3, search.Cost)
+		assert.Equal(t, "2s", search.Timeout)
+		assert.Nil(t, health.RateLimits)
+		assert.Empty(t, health.Timeout)
+	})
+
+	t.Run("Upstream_Should_Be_The_Service_Of_The_Service_Client_Handlers", func(t *testing.T) {
+		_, exporter := newExporter(t)
+
+		assert.Equal(t, "payment", exporter.upstream(gin.RouteInfo{
+			Handler: "github.com/quadev-ltd/qd-qpi-gateway/internal/payment.(*ServiceClient).ListPaymentMethods-fm",
+		}))
+		assert.Equal(t, "authentication", exporter.upstream(gin.RouteInfo{
+			Handler: "github.com/quadev-ltd/qd-qpi-gateway/internal/admin.(*ServiceClient).ListUsers-fm",
+		}))
+		assert.Empty(t, exporter.upstream(gin.RouteInfo{Handler: "main.handler"}))
+	})
+
+	t.Run("Write_Should_Render_A_Markdown_Table", func(t *testing.T) {
+		_, exporter := newExporter(t)
+		var output bytes.Buffer
+
+		assert.NoError(t, exporter.Export().Write(&output, FormatMarkdown))
+
+		assert.Contains(t, output.String(), "| Method | Path | Authentication | Rate limits | Upstream | Timeout |")
+		assert.Contains(t, output.String(),
+			"| GET | `/api/v1/search/:index` | token, roles | search (5/s per ip, burst 10), cost 3 | - | 2s |")
+		assert.Contains(t, output.String(), "| GET | `/api/v1/catalog/:productID` | public | - | catalog | - |")
+	})
+
+	t.Run("Import_Should_Read_The_Exported_JSON", func(t *testing.T) {
+		_, exporter := newExporter(t)
+		table := exporter.Export()
+		var output bytes.Buffer
+		assert.NoError(t, table.Write(&output, FormatJSON))
+
+		imported, err := Import(&output)
+
+		assert.NoError(t, err)
+		assert.True(t, table.GeneratedAt.Equal(imported.GeneratedAt))
+		assert.Equal(t, table.Routes, imported.Routes)
+		_, err = Import(bytes.NewBufferString(`{"routes": [], "unknown": true}`))
+		assert.ErrorContains(t, err, "Could not read the route table")
+	})
+
+	t.Run("ExportHandler_Should_Reject_The_Unknown_Formats", func(t *testing.T) {
+		router, _ := newExporter(t)
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/routes/export?format=yaml", nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/routes/export?format=markdown", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/markdown; charset=utf-8", recorder.Header().Get("Content-Type"))
+		assert.Contains(t, recorder.Body.String(), "# Route table")
+	})
+}
@@ -0,0 +1,279 @@
+package routetable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/deadline"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+)
+
+// Formats of the exported route table
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+// Authentications of the routes
+const (
+	AuthenticationPublic        = "public"
+	AuthenticationToken         = "token"
+	AuthenticationTokenOrAPIKey = "token_or_api_key"
+	AuthenticationRefreshToken  = "refresh_token"
+	AuthenticationAdminToken    = "admin_token"
+)
+
+// describeContextKey marks the requests of the exporter, only set in process so no client can describe a route
+type describeContextKey struct{}
+
+// servicePackages are the packages of the handlers calling a gateway service, by the service they call
+var servicePackages = map[string]string{
+	"admin":          "authentication",
+	"authentication": "authentication",
+	"media":          "media",
+	"notification":   "notification",
+	"payment":        "payment",
+	"reference":      "reference",
+	"search":         "search",
+	"support":        "support",
+}
+
+// Route is a route of the effective route table
+type Route struct {
+	Method         string                 `json:"method"`
+	Path           string                 `json:"path"`
+	Authentication string                 `json:"authentication"`
+	RolesRequired  bool                   `json:"rolesRequired,omitempty"`
+	RateLimits     []ratelimit.RouteLimit `json:"rateLimits,omitempty"`
+	Cost           int                    `json:"cost,omitempty"`
+	Upstream       string                 `json:"upstream,omitempty"`
+	Timeout        string                 `json:"timeout,omitempty"`
+	Handlers       []string               `json:"handlers"`
+}
+
+// Table is the effective route table of the gateway
+type Table struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Routes      []Route   `json:"routes"`
+}
+
+// Exporter describes the routes of the router with the authentication their handlers require, the rate limits and
+// the deadline applied to them and the upstream service they call
+type Exporter struct {
+	router    *gin.Engine
+	limiter   *ratelimit.Limiter
+	timeouts  *deadline.Timeouts
+	upstreams map[string]string
+}
+
+// NewExporter creates the exporter of the router, the declared upstream routes are registered under the API path
+func NewExporter(router *gin.Engine, apiPath string, configurations *config.Config) *Exporter {
+	exporter := &Exporter{router: router, upstreams: map[string]string{}}
+	if configurations.UpstreamRoutes.Enabled {
+		for _, service := range configurations.UpstreamRoutes.Services {
+			for _, route := range service.Routes {
+				exporter.upstreams[strings.ToUpper(route.Method)+" "+apiPath+route.Path] = service.Name
+			}
+		}
+	}
+	return exporter
+}
+
+// UseRateLimiter describes the rate limits of the routes
+func (exporter *Exporter) UseRateLimiter(limiter *ratelimit.Limiter) {
+	exporter.limiter = limiter
+}
+
+// UseTimeouts describes the deadlines of the routes
+func (exporter *Exporter) UseTimeouts(timeouts *deadline.Timeouts) {
+	exporter.timeouts = timeouts
+}
+
+// Describe answers the requests of the exporter with the handlers of their route before any of them runs,
+// it is the first middleware of the router
+func Describe(ctx *gin.Context) {
+	handlers, describing := ctx.Request.Context().Value(describeContextKey{}).(*[]string)
+	if !describing {
+		return
+	}
+	*handlers = ctx.HandlerNames()
+	ctx.Abort()
+}
+
+// handlerNames routes a request to the route through the router, stopped by Describe
+func (exporter *Exporter) handlerNames(routeInfo gin.RouteInfo) []string {
+	segments := strings.Split(routeInfo.Path, "/")
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[index] = "route-table-" + segment[1:]
+		}
+	}
+	handlers := []string{}
+	ctx := context.WithValue(context.Background(), describeContextKey{}, &handlers)
+	request, err := http.NewRequestWithContext(ctx, routeInfo.Method, strings.Join(segments, "/"), nil)
+	if err != nil {
+		return []string{routeInfo.Handler}
+	}
+	exporter.router.ServeHTTP(httptest.NewRecorder(), request)
+	if len(handlers) == 0 {
+		return []string{routeInfo.Handler}
+	}
+	// The middlewares of the router before Describe are not part of the route
+	return handlers[1:]
+}
+
+// Export describes every route of the router, sorted by path and method
+func (exporter *Exporter) Export() *Table {
+	table := &Table{GeneratedAt: time.Now().UTC(), Routes: []Route{}}
+	for _, routeInfo := range exporter.router.Routes() {
+		route := Route{Method: routeInfo.Method, Path: routeInfo.Path, Handlers: exporter.handlerNames(routeInfo)}
+		route.Authentication, route.RolesRequired = RequiredAuthentication(route.Handlers)
+		route.Upstream = exporter.upstream(routeInfo)
+		if exporter.limiter != nil {
+			route.RateLimits, route.Cost = exporter.limiter.RouteLimits(route.Method, route.Path)
+			if len(route.RateLimits) == 0 {
+				route.RateLimits, route.Cost = nil, 0
+			}
+		}
+		if exporter.timeouts != nil {
+			if timeout := exporter.timeouts.Timeout(route.Path); timeout > 0 {
+				route.Timeout = timeout.String()
+			}
+		}
+		table.Routes = append(table.Routes, route)
+	}
+	sort.Slice(table.Routes, func(i, j int) bool {
+		if table.Routes[i].Path != table.Routes[j].Path {
+			return table.Routes[i].Path < table.Routes[j].Path
+		}
+		return table.Routes[i].Method < table.Routes[j].Method
+	})
+	return table
+}
+
+// ExportHandler answers with the route table in the format of the format query parameter, JSON by default
+func (exporter *Exporter) ExportHandler(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", FormatJSON)
+	if format != FormatJSON && format != FormatMarkdown {
+		errors.AbortWithMessage(ctx, errors.InvalidArgument, fmt.Sprintf("Unknown route table format %s", format),
+			errors.FieldViolation("format", "must be json or markdown"))
+		return
+	}
+	contentType := "application/json; charset=utf-8"
+	if format == FormatMarkdown {
+		contentType = "text/markdown; charset=utf-8"
+	}
+	ctx.Header("Content-Type", contentType)
+	ctx.Status(http.StatusOK)
+	if err := exporter.Export().Write(ctx.Writer, format); err != nil {
+		ctx.Error(err)
+	}
+}
+
+// RequiredAuthentication tells the credentials the handlers of a route require from their names, and whether they
+// require roles
+func RequiredAuthentication(handlers []string) (string, bool) {
+	required := AuthenticationPublic
+	rolesRequired := false
+	for _, handler := range handlers {
+		switch {
+		case strings.Contains(handler, "(*apiKeyAuthentication).RequireAuthentication"),
+			strings.Contains(handler, ".RequireAuthenticationOrAPIKey"):
+			required = AuthenticationTokenOrAPIKey
+		case strings.Contains(handler, ".RequireAuthentication") && required == AuthenticationPublic:
+			required = AuthenticationToken
+		case strings.Contains(handler, ".RefreshAuthentication"):
+			required = AuthenticationRefreshToken
+		case strings.Contains(handler, "admin.(*Introspection).RequireToken"):
+			required = AuthenticationAdminToken
+		case strings.Contains(handler, ".RequireRole"):
+			rolesRequired = true
+		}
+	}
+	return required, rolesRequired
+}
+
+// upstream returns the service the route calls: the service of a declared upstream route, or the service
+// of the package of a service client handler
+func (exporter *Exporter) upstream(routeInfo gin.RouteInfo) string {
+	if service, exists := exporter.upstreams[routeInfo.Method+" "+routeInfo.Path]; exists {
+		return service
+	}
+	packagePath, receiver, found := strings.Cut(routeInfo.Handler, ".(*ServiceClient)")
+	if !found || receiver == "" {
+		return ""
+	}
+	return servicePackages[packagePath[strings.LastIndex(packagePath, "/")+1:]]
+}
+
+// Write writes the route table in the format
+func (table *Table) Write(writer io.Writer, format string) error {
+	switch format {
+	case "", FormatJSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(table)
+	case FormatMarkdown:
+		return table.writeMarkdown(writer)
+	}
+	return fmt.Errorf("Unknown route table format %s", format)
+}
+
+func (table *Table) writeMarkdown(writer io.Writer) error {
+	lines := []string{
+		fmt.Sprintf("# Route table\n\nGenerated at %s.\n", table.GeneratedAt.Format(time.RFC3339)),
+		"| Method | Path | Authentication | Rate limits | Upstream | Timeout |",
+		"| --- | --- | --- | --- | --- | --- |",
+	}
+	for _, route := range table.Routes {
+		required := route.Authentication
+		if route.RolesRequired {
+			required += ", roles"
+		}
+		rateLimits := make([]string, 0, len(route.RateLimits))
+		for _, limit := range route.RateLimits {
+			rateLimit := fmt.Sprintf("%s (%g/s per %s, burst %d)", limit.Group, limit.Rate, limit.Key, limit.Burst)
+			if len(limit.Tenants) > 0 {
+				rateLimit += " for " + strings.Join(limit.Tenants, ", ")
+			}
+			rateLimits = append(rateLimits, rateLimit)
+		}
+		if route.Cost > 1 {
+			rateLimits = append(rateLimits, fmt.Sprintf("cost %d", route.Cost))
+		}
+		lines = append(lines, fmt.Sprintf("| %s | `%s` | %s | %s | %s | %s |",
+			route.Method, route.Path, required, markdownCell(strings.Join(rateLimits, ", ")),
+			markdownCell(route.Upstream), markdownCell(route.Timeout)))
+	}
+	_, err := io.WriteString(writer, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func markdownCell(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(value, "|", "\\|")
+}
+
+// Import reads a route table exported as JSON, to render it in another format
+func Import(reader io.Reader) (*Table, error) {
+	table := &Table{}
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(table); err != nil {
+		return nil, fmt.Errorf("Could not read the route table: %v", err)
+	}
+	return table, nil
+}
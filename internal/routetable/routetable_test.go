@@ -0,0 +1,293 @@
+package routetable
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/admin"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/fakebackend"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/fallback"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/media"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/notification"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/payment"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/preferences"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/public"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/reference"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/search"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/support"
+)
+
+// The properties run against the route table the services register, the authentication routes are registered
+// with the real middleware, fetching the public key from the fake authentication service
+
+const apiPath = "/api/v1"
+
+// unauthenticatedError is answered by the fake authentication so the tests tell its rejections apart
+const unauthenticatedError = "route_table_unauthenticated"
+
+// publicRoutes are the routes served without authentication, any other route must require it
+var publicRoutes = map[string]bool{
+	"GET " + apiPath + "/health":                                                      true,
+	"POST " + apiPath + "/user/":                                                      true,
+	"POST " + apiPath + "/user/:userID/email/:verificationToken":                      true,
+	"POST " + apiPath + "/user/sessions":                                              true,
+	"POST " + apiPath + "/user/firebase/sessions":                                     true,
+	"POST " + apiPath + "/user/:userID/email/verification":                            true,
+	"POST " + apiPath + "/user/password/reset":                                        true,
+	"GET " + apiPath + "/user/:userID/password/reset-verification/:verificationToken": true,
+	"POST " + apiPath + "/user/:userID/password/reset/:verificationToken":             true,
+	"GET " + apiPath + "/reference/countries":                                         true,
+	"GET " + apiPath + "/reference/locales":                                           true,
+	"GET " + apiPath + "/reference/plans":                                             true,
+}
+
+// fakeAuthentication rejects every request reaching RequireAuthentication and lets the other checks through
+type fakeAuthentication struct{}
+
+var _ authentication.AutheticationMiddlewarer = &fakeAuthentication{}
+
+func (fake *fakeAuthentication) OnAuthenticated(hook authentication.AuthenticatedHook) {}
+
+func (fake *fakeAuthentication) RequireAuthentication(ctx *gin.Context) {
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": unauthenticatedError})
+}
+
+func (fake *fakeAuthentication) RefreshAuthentication(ctx *gin.Context) {
+	fake.RequireAuthentication(ctx)
+}
+
+func (fake *fakeAuthentication) TrackSession(ctx *gin.Context) { ctx.Next() }
+
+func (fake *fakeAuthentication) RequireVerifiedEmail(ctx *gin.Context) { ctx.Next() }
+
+func (fake *fakeAuthentication) RequireStepUp(maxAge time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) { ctx.Next() }
+}
+
+func (fake *fakeAuthentication) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) { ctx.Next() }
+}
+
+func (fake *fakeAuthentication) RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) { ctx.Next() }
+}
+
+func (fake *fakeAuthentication) RequireAudience(audiences ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) { ctx.Next() }
+}
+
+func (fake *fakeAuthentication) PublicKeyRefreshJob() scheduler.Job { return scheduler.Job{} }
+
+func (fake *fakeAuthentication) RevokeToken(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+
+func (fake *fakeAuthentication) TokenRevocationJob() scheduler.Job { return scheduler.Job{} }
+
+func (fake *fakeAuthentication) DebugToken(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+
+func (fake *fakeAuthentication) MintToken(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+
+// routedRequest is a request to a route of the table with generated parameter values and query
+type routedRequest struct {
+	Route  gin.RouteInfo
+	Path   string
+	Query  string
+	Header string
+}
+
+// Generate picks a route and fills its parameters, the values start with x- so they never spell a static
+// segment, which gin prefers to a parameter by design
+func (routedRequest) Generate(random *rand.Rand, size int) reflect.Value {
+	routes := routeTable.Routes()
+	route := routes[random.Intn(len(routes))]
+	segments := strings.Split(route.Path, "/")
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[index] = "x-" + randomToken(random, size)
+		}
+	}
+	return reflect.ValueOf(routedRequest{
+		Route:  route,
+		Path:   strings.Join(segments, "/"),
+		Query:  randomToken(random, size) + "=" + randomToken(random, size),
+		Header: randomToken(random, size),
+	})
+}
+
+func randomToken(random *rand.Rand, size int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.~"
+	token := make([]byte, 1+random.Intn(size+1))
+	for index := range token {
+		token[index] = alphabet[random.Intn(len(alphabet))]
+	}
+	return string(token)
+}
+
+// routeTable is the router the properties run against, matched records the route each request was matched to
+var routeTable, matched = newRouteTable()
+
+func newRouteTable() (*gin.Engine, func(request *http.Request) (string, *httptest.ResponseRecorder)) {
+	gin.SetMode(gin.TestMode)
+	serviceConfig := config.ServiceConfig{Enabled: true, Host: "127.0.0.1", Port: "1"}
+	configurations := &config.Config{
+		PublicRoutes:        config.PublicRoutesConfig{RateLimit: 1e6, RateLimitBurst: 1e6},
+		NotificationService: serviceConfig,
+		PaymentService:      config.PaymentServiceConfig{ServiceConfig: serviceConfig, RateLimit: 1e6, RateLimitBurst: 1e6},
+		MediaService:        config.MediaServiceConfig{ServiceConfig: serviceConfig, ThumbnailSizes: []int{128}},
+		SearchService:       config.SearchServiceConfig{ServiceConfig: serviceConfig, RateLimit: 1e6, RateLimitBurst: 1e6},
+		SupportService:      config.SupportServiceConfig{ServiceConfig: serviceConfig, RateLimit: 1e6, RateLimitBurst: 1e6},
+		ReferenceService:    config.ReferenceServiceConfig{ServiceConfig: serviceConfig, RefreshInterval: time.Hour},
+		Admin:               config.AdminConfig{Enabled: true, Roles: []string{"admin"}},
+	}
+	backends, err := fakebackend.Start(configurations)
+	if err != nil {
+		panic(err)
+	}
+	centralConfig := &commonConfig.Config{AuthenticationService: backends.AuthenticationService}
+	authenticationMiddleware := &fakeAuthentication{}
+
+	var fullPath string
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		fullPath = ctx.FullPath()
+		ctx.Next()
+	})
+	router.Use(middleware.CorrelationID)
+	router.Use(commonLogger.CreateGinLoggerMiddleware(commonLogger.NewLogFactory(configurations.Environment)))
+	fallback.Register(router, configurations)
+	api := router.Group(apiPath)
+	publicGroup := public.NewGroup(api, configurations)
+	registrations := []func() error{
+		func() error {
+			_, _, err := authentication.RegisterRoutes(api, publicGroup, centralConfig, configurations, preferences.NewResolver(nil, configurations))
+			return err
+		},
+		func() error {
+			_, err := notification.RegisterRoutes(api, centralConfig, configurations, authenticationMiddleware)
+			return err
+		},
+		func() error {
+			_, err := payment.RegisterRoutes(api, centralConfig, configurations, authenticationMiddleware)
+			return err
+		},
+		func() error {
+			_, err := media.RegisterRoutes(api, centralConfig, configurations, authenticationMiddleware)
+			return err
+		},
+		func() error {
+			_, err := search.RegisterRoutes(api, centralConfig, configurations, authenticationMiddleware)
+			return err
+		},
+		func() error {
+			_, err := support.RegisterRoutes(api, centralConfig, configurations, authenticationMiddleware)
+			return err
+		},
+		func() error {
+			_, err := reference.RegisterRoutes(api, centralConfig, configurations)
+			return err
+		},
+		func() error {
+			_, err := admin.RegisterRoutes(api, centralConfig, configurations, authenticationMiddleware)
+			return err
+		},
+	}
+	for _, register := range registrations {
+		if err := register(); err != nil {
+			panic(err)
+		}
+	}
+
+	return router, func(request *http.Request) (string, *httptest.ResponseRecorder) {
+		fullPath = ""
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return fullPath, recorder
+	}
+}
+
+func (request routedRequest) new() *http.Request {
+	httpRequest := httptest.NewRequest(request.Route.Method, request.Path+"?"+request.Query, nil)
+	httpRequest.Header.Set("X-Route-Table", request.Header)
+	httpRequest.Header.Set("Authorization", "Bearer "+request.Header)
+	return httpRequest
+}
+
+func TestRouteTable(t *testing.T) {
+	quickConfig := &quick.Config{MaxCount: 500}
+
+	t.Run("Routes_Should_Not_Be_Shadowed", func(t *testing.T) {
+		property := func(request routedRequest) bool {
+			fullPath, _ := matched(request.new())
+			if fullPath != request.Route.Path {
+				t.Logf("%s %s was matched to %q", request.Route.Method, request.Path, fullPath)
+				return false
+			}
+			return true
+		}
+
+		if err := quick.Check(property, quickConfig); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Matching_Should_Be_Deterministic", func(t *testing.T) {
+		property := func(request, other routedRequest) bool {
+			fullPath, recorder := matched(request.new())
+			matched(other.new())
+			againFullPath, againRecorder := matched(request.new())
+
+			return fullPath == againFullPath && recorder.Code == againRecorder.Code
+		}
+
+		if err := quick.Check(property, quickConfig); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Authentication_Should_Be_Required_Outside_The_Public_Routes", func(t *testing.T) {
+		property := func(request routedRequest) bool {
+			if publicRoutes[request.Route.Method+" "+request.Route.Path] {
+				return true
+			}
+			_, recorder := matched(request.new())
+			rejected := strings.Contains(recorder.Body.String(), unauthenticatedError) ||
+				strings.Contains(recorder.Body.String(), `"code":"`+errors.Unauthenticated+`"`)
+			if recorder.Code != http.StatusUnauthorized || !rejected {
+				t.Logf("%s %s was answered %d without authentication", request.Route.Method, request.Path, recorder.Code)
+				return false
+			}
+			return true
+		}
+
+		if err := quick.Check(property, quickConfig); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Public_Routes_Should_Be_Registered", func(t *testing.T) {
+		registered := make(map[string]bool)
+		for _, route := range routeTable.Routes() {
+			registered[route.Method+" "+route.Path] = true
+		}
+
+		for route := range publicRoutes {
+			if !registered[route] {
+				t.Errorf("The public route %s is not registered", route)
+			}
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCronSearch bounds the search of the next time a cron schedule matches
+const maxCronSearch = 5 * 366 * 24 * time.Hour
+
+// Schedule gives the next run of a job after a given time
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func (schedule intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(schedule.interval)
+}
+
+// cronSchedule matches the minute, hour, day of month, month and day of week fields of a cron expression
+type cronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	days        uint64
+	months      uint64
+	weekdays    uint64
+	anyDay      bool
+	anyWeekday  bool
+	description string
+}
+
+var shortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// ParseSchedule parses "@every <duration>", the @hourly, @daily, @weekly and @monthly shortcuts
+// or a five field cron expression supporting lists, ranges and steps
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("Invalid schedule interval: %s", spec)
+		}
+		return intervalSchedule{interval: interval}, nil
+	}
+	if expression, exists := shortcuts[spec]; exists {
+		spec = expression
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Invalid cron schedule, expected 5 fields: %s", spec)
+	}
+	schedule := &cronSchedule{description: spec}
+	bounds := []struct {
+		target   *uint64
+		min, max int
+	}{
+		{&schedule.minutes, 0, 59},
+		{&schedule.hours, 0, 23},
+		{&schedule.days, 1, 31},
+		{&schedule.months, 1, 12},
+		{&schedule.weekdays, 0, 7},
+	}
+	for index, field := range fields {
+		bits, err := parseField(field, bounds[index].min, bounds[index].max)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cron schedule %s: %v", spec, err)
+		}
+		*bounds[index].target = bits
+	}
+	// Sunday is both 0 and 7
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+	schedule.anyDay = fields[2] == "*"
+	schedule.anyWeekday = fields[4] == "*"
+	return schedule, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, hasStep := strings.Cut(part, "/"); hasStep {
+			parsedStep, err := strconv.Atoi(stepPart)
+			if err != nil || parsedStep <= 0 {
+				return 0, fmt.Errorf("invalid step %s", part)
+			}
+			part, step = rangePart, parsedStep
+		}
+		start, end := min, max
+		if part != "*" {
+			first, last, isRange := strings.Cut(part, "-")
+			var err error
+			if start, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("invalid value %s", part)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("invalid range %s", part)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("value out of range %s", part)
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first minute after the given time matching the expression
+func (schedule *cronSchedule) Next(after time.Time) time.Time {
+	next := after.Truncate(time.Minute).Add(time.Minute)
+	for limit := after.Add(maxCronSearch); next.Before(limit); next = next.Add(time.Minute) {
+		if schedule.months&(1<<uint(next.Month())) == 0 {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location()).Add(-time.Minute)
+			continue
+		}
+		if !schedule.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location()).Add(-time.Minute)
+			continue
+		}
+		if schedule.hours&(1<<uint(next.Hour())) == 0 {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location()).Add(-time.Minute)
+			continue
+		}
+		if schedule.minutes&(1<<uint(next.Minute())) != 0 {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay follows cron, when both day fields are restricted either of them matches
+func (schedule *cronSchedule) matchesDay(day time.Time) bool {
+	dayMatches := schedule.days&(1<<uint(day.Day())) != 0
+	weekdayMatches := schedule.weekdays&(1<<uint(day.Weekday())) != 0
+	if schedule.anyDay || schedule.anyWeekday {
+		return dayMatches && weekdayMatches
+	}
+	return dayMatches || weekdayMatches
+}
@@ -0,0 +1,262 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// JobFunc runs a job at the given time
+type JobFunc func(ctx context.Context, now time.Time) error
+
+// Job is a periodic gateway task, its schedule, jitter and timeout can be overridden in the configuration.
+// Singleton jobs only run on the leader replica.
+type Job struct {
+	Name       string
+	Schedule   string
+	Jitter     time.Duration
+	Timeout    time.Duration
+	RunOnStart bool
+	Singleton  bool
+	Run        JobFunc
+}
+
+// JobStats are the metrics of a job
+type JobStats struct {
+	Name           string    `json:"name"`
+	Schedule       string    `json:"schedule"`
+	Runs           int64     `json:"runs"`
+	Failures       int64     `json:"failures"`
+	Skipped        int64     `json:"skipped"`
+	Standby        int64     `json:"standby"`
+	Singleton      bool      `json:"singleton"`
+	Running        bool      `json:"running"`
+	LastRun        time.Time `json:"lastRun,omitempty"`
+	LastDurationMs int64     `json:"lastDurationMs"`
+	LastError      string    `json:"lastError,omitempty"`
+	NextRun        time.Time `json:"nextRun,omitempty"`
+}
+
+type scheduledJob struct {
+	job      Job
+	schedule Schedule
+	stats    JobStats
+	mtx      sync.Mutex
+}
+
+// Scheduler runs the registered jobs on their schedules, a run is skipped while the previous one is in progress
+type Scheduler struct {
+	jobs    map[string]*scheduledJob
+	configs map[string]config.JobConfig
+	leader  func() bool
+	logger  commonLogger.Loggerer
+	random  *rand.Rand
+	wait    sync.WaitGroup
+	mtx     sync.Mutex
+}
+
+// NewScheduler creates the scheduler of the configuration
+func NewScheduler(configurations *config.Config) *Scheduler {
+	return &Scheduler{
+		jobs:    make(map[string]*scheduledJob),
+		configs: configurations.Scheduler.Jobs,
+		logger:  commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Register adds a job with the overrides of its configuration, disabled jobs are ignored
+func (scheduler *Scheduler) Register(job Job) error {
+	if jobConfig, exists := scheduler.configs[job.Name]; exists {
+		if jobConfig.Disabled {
+			return nil
+		}
+		if jobConfig.Schedule != "" {
+			job.Schedule = jobConfig.Schedule
+		}
+		if jobConfig.Jitter > 0 {
+			job.Jitter = jobConfig.Jitter
+		}
+		if jobConfig.Timeout > 0 {
+			job.Timeout = jobConfig.Timeout
+		}
+		if jobConfig.Singleton != nil {
+			job.Singleton = *jobConfig.Singleton
+		}
+	}
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("Could not schedule job %s: %v", job.Name, err)
+	}
+	scheduler.mtx.Lock()
+	defer scheduler.mtx.Unlock()
+	if _, exists := scheduler.jobs[job.Name]; exists {
+		return fmt.Errorf("Job %s is already registered", job.Name)
+	}
+	scheduler.jobs[job.Name] = &scheduledJob{
+		job:      job,
+		schedule: schedule,
+		stats:    JobStats{Name: job.Name, Schedule: job.Schedule, Singleton: job.Singleton},
+	}
+	return nil
+}
+
+// UseLeader makes the singleton jobs run only while isLeader reports the replica leads,
+// without a leader check every replica runs them
+func (scheduler *Scheduler) UseLeader(isLeader func() bool) {
+	scheduler.mtx.Lock()
+	defer scheduler.mtx.Unlock()
+	scheduler.leader = isLeader
+}
+
+// Every returns the schedule spec running every interval
+func Every(interval time.Duration) string {
+	return fmt.Sprintf("@every %s", interval)
+}
+
+// Run runs the jobs until the context is cancelled and waits for the runs in progress
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	scheduler.mtx.Lock()
+	jobs := make([]*scheduledJob, 0, len(scheduler.jobs))
+	for _, job := range scheduler.jobs {
+		jobs = append(jobs, job)
+	}
+	scheduler.mtx.Unlock()
+
+	loops := sync.WaitGroup{}
+	for _, job := range jobs {
+		loops.Add(1)
+		go func(job *scheduledJob) {
+			defer loops.Done()
+			scheduler.loop(ctx, job)
+		}(job)
+	}
+	loops.Wait()
+	scheduler.wait.Wait()
+}
+
+func (scheduler *Scheduler) loop(ctx context.Context, job *scheduledJob) {
+	if job.job.RunOnStart {
+		scheduler.trigger(ctx, job, time.Now())
+	}
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			scheduler.logger.Error(nil, fmt.Sprintf("Job %s has no next run", job.job.Name))
+			return
+		}
+		next = next.Add(scheduler.jitter(job.job.Jitter))
+		job.mtx.Lock()
+		job.stats.NextRun = next
+		job.mtx.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			scheduler.trigger(ctx, job, now)
+		}
+	}
+}
+
+func (scheduler *Scheduler) jitter(maxJitter time.Duration) time.Duration {
+	if maxJitter <= 0 {
+		return 0
+	}
+	scheduler.mtx.Lock()
+	defer scheduler.mtx.Unlock()
+	return time.Duration(scheduler.random.Int63n(int64(maxJitter)))
+}
+
+// trigger starts a run unless the previous one is still in progress or another replica leads
+func (scheduler *Scheduler) trigger(ctx context.Context, job *scheduledJob, now time.Time) {
+	scheduler.mtx.Lock()
+	isLeader := scheduler.leader
+	scheduler.mtx.Unlock()
+	job.mtx.Lock()
+	if job.job.Singleton && isLeader != nil && !isLeader() {
+		job.stats.Standby++
+		job.mtx.Unlock()
+		return
+	}
+	if job.stats.Running {
+		job.stats.Skipped++
+		job.mtx.Unlock()
+		scheduler.logger.Warn(fmt.Sprintf("Skipped job %s, the previous run is still in progress", job.job.Name))
+		return
+	}
+	job.stats.Running = true
+	job.mtx.Unlock()
+
+	scheduler.wait.Add(1)
+	go func() {
+		defer scheduler.wait.Done()
+		scheduler.execute(ctx, job, now)
+	}()
+}
+
+func (scheduler *Scheduler) execute(ctx context.Context, job *scheduledJob, now time.Time) {
+	runContext := ctx
+	if job.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		runContext, cancel = context.WithTimeout(ctx, job.job.Timeout)
+		defer cancel()
+	}
+	startedAt := time.Now()
+	err := runJob(runContext, job.job.Run, now)
+
+	job.mtx.Lock()
+	defer job.mtx.Unlock()
+	job.stats.Running = false
+	job.stats.Runs++
+	job.stats.LastRun = startedAt
+	job.stats.LastDurationMs = time.Since(startedAt).Milliseconds()
+	job.stats.LastError = ""
+	if err != nil {
+		job.stats.Failures++
+		job.stats.LastError = err.Error()
+		scheduler.logger.Error(err, fmt.Sprintf("Job %s failed", job.job.Name))
+	}
+}
+
+// runJob recovers the panics of a job so a failing task does not stop the gateway
+func runJob(ctx context.Context, run JobFunc, now time.Time) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("Job panicked: %v", recovered)
+		}
+	}()
+	return run(ctx, now)
+}
+
+// Stats returns the metrics of every job sorted by name
+func (scheduler *Scheduler) Stats() []JobStats {
+	scheduler.mtx.Lock()
+	defer scheduler.mtx.Unlock()
+	stats := make([]JobStats, 0, len(scheduler.jobs))
+	for _, job := range scheduler.jobs {
+		job.mtx.Lock()
+		stats = append(stats, job.stats)
+		job.mtx.Unlock()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// StatsHandler answers with the metrics of every job
+func (scheduler *Scheduler) StatsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"jobs": scheduler.Stats()})
+}
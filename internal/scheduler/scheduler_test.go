@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2024, time.March, 15, 10, 7, 30, 0, time.UTC)
+
+	t.Run("ParseSchedule_Should_Parse_Intervals", func(t *testing.T) {
+		schedule, err := ParseSchedule("@every 90s")
+		assert.NoError(t, err)
+		assert.Equal(t, base.Add(90*time.Second), schedule.Next(base))
+	})
+
+	t.Run("ParseSchedule_Should_Parse_Cron_Expressions", func(t *testing.T) {
+		cases := map[string]time.Time{
+			"*/15 * * * *":  time.Date(2024, time.March, 15, 10, 15, 0, 0, time.UTC),
+			"0 3 * * *":     time.Date(2024, time.March, 16, 3, 0, 0, 0, time.UTC),
+			"30 9-17 * * *": time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC),
+			"0 0 1 * *":     time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC),
+			"0 12 * * 0":    time.Date(2024, time.March, 17, 12, 0, 0, 0, time.UTC),
+			"0 12 * * 7":    time.Date(2024, time.March, 17, 12, 0, 0, 0, time.UTC),
+			"0 0 29 2 *":    time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC),
+			"@hourly":       time.Date(2024, time.March, 15, 11, 0, 0, 0, time.UTC),
+		}
+		for spec, expected := range cases {
+			schedule, err := ParseSchedule(spec)
+			assert.NoError(t, err, spec)
+			assert.Equal(t, expected, schedule.Next(base), spec)
+		}
+	})
+
+	t.Run("ParseSchedule_Should_Match_Either_Restricted_Day_Field", func(t *testing.T) {
+		schedule, err := ParseSchedule("0 0 20 * 1")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC), schedule.Next(base))
+	})
+
+	t.Run("ParseSchedule_Should_Reject_Invalid_Specs", func(t *testing.T) {
+		for _, spec := range []string{"", "@every 0s", "@every soon", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+			_, err := ParseSchedule(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+}
+
+func newTestScheduler(jobs map[string]config.JobConfig) *Scheduler {
+	return NewScheduler(&config.Config{
+		Environment: "test",
+		Scheduler:   config.SchedulerConfig{Jobs: jobs},
+	})
+}
+
+func runFor(scheduler *Scheduler, duration time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	scheduler.Run(ctx)
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("Run_Should_Run_Jobs_On_Their_Schedule_And_Record_Metrics", func(t *testing.T) {
+		scheduler := newTestScheduler(nil)
+		runs := int32(0)
+		assert.NoError(t, scheduler.Register(Job{
+			Name:       "refresh",
+			Schedule:   Every(20 * time.Millisecond),
+			RunOnStart: true,
+			Run: func(ctx context.Context, now time.Time) error {
+				if atomic.AddInt32(&runs, 1) == 1 {
+					return errors.New("upstream unavailable")
+				}
+				return nil
+			},
+		}))
+
+		runFor(scheduler, 110*time.Millisecond)
+
+		stats := scheduler.Stats()
+		assert.Len(t, stats, 1)
+		assert.True(t, stats[0].Runs >= 3)
+		assert.Equal(t, int64(1), stats[0].Failures)
+		assert.Empty(t, stats[0].LastError)
+		assert.Equal(t, int64(atomic.LoadInt32(&runs)), stats[0].Runs)
+	})
+
+	t.Run("Run_Should_Skip_Runs_While_The_Previous_One_Is_In_Progress", func(t *testing.T) {
+		scheduler := newTestScheduler(nil)
+		concurrent, maxConcurrent := int32(0), int32(0)
+		assert.NoError(t, scheduler.Register(Job{
+			Name:     "export",
+			Schedule: Every(10 * time.Millisecond),
+			Run: func(ctx context.Context, now time.Time) error {
+				current := atomic.AddInt32(&concurrent, 1)
+				if current > atomic.LoadInt32(&maxConcurrent) {
+					atomic.StoreInt32(&maxConcurrent, current)
+				}
+				time.Sleep(45 * time.Millisecond)
+				atomic.AddInt32(&concurrent, -1)
+				return nil
+			},
+		}))
+
+		runFor(scheduler, 100*time.Millisecond)
+
+		stats := scheduler.Stats()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&maxConcurrent))
+		assert.True(t, stats[0].Skipped > 0)
+		assert.False(t, stats[0].Running)
+	})
+
+	t.Run("Run_Should_Run_Singleton_Jobs_Only_On_The_Leader", func(t *testing.T) {
+		scheduler := newTestScheduler(nil)
+		leading := int32(0)
+		scheduler.UseLeader(func() bool { return atomic.LoadInt32(&leading) == 1 })
+		singletonRuns, replicaRuns := int32(0), int32(0)
+		assert.NoError(t, scheduler.Register(Job{
+			Name:      "outbox",
+			Schedule:  Every(10 * time.Millisecond),
+			Singleton: true,
+			Run: func(ctx context.Context, now time.Time) error {
+				atomic.AddInt32(&singletonRuns, 1)
+				return nil
+			},
+		}))
+		assert.NoError(t, scheduler.Register(Job{
+			Name:     "export",
+			Schedule: Every(10 * time.Millisecond),
+			Run: func(ctx context.Context, now time.Time) error {
+				atomic.AddInt32(&replicaRuns, 1)
+				return nil
+			},
+		}))
+
+		runFor(scheduler, 55*time.Millisecond)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&singletonRuns))
+		assert.True(t, atomic.LoadInt32(&replicaRuns) > 0)
+
+		atomic.StoreInt32(&leading, 1)
+		runFor(scheduler, 55*time.Millisecond)
+		assert.True(t, atomic.LoadInt32(&singletonRuns) > 0)
+
+		stats := scheduler.Stats()
+		assert.Equal(t, "export", stats[0].Name)
+		assert.Equal(t, "outbox", stats[1].Name)
+		assert.True(t, stats[1].Singleton)
+		assert.True(t, stats[1].Standby > 0)
+		assert.Equal(t, int64(0), stats[0].Standby)
+	})
+
+	t.Run("Run_Should_Recover_Panicking_Jobs", func(t *testing.T) {
+		scheduler := newTestScheduler(nil)
+		assert.NoError(t, scheduler.Register(Job{
+			Name:       "cleanup",
+			Schedule:   Every(time.Hour),
+			RunOnStart: true,
+			Run: func(ctx context.Context, now time.Time) error {
+				panic("nil map")
+			},
+		}))
+
+		runFor(scheduler, 20*time.Millisecond)
+
+		stats := scheduler.Stats()
+		assert.Equal(t, int64(1), stats[0].Failures)
+		assert.Contains(t, stats[0].LastError, "nil map")
+	})
+
+	t.Run("Register_Should_Apply_The_Configuration_Overrides", func(t *testing.T) {
+		scheduler := newTestScheduler(map[string]config.JobConfig{
+			"certificates_check": {Schedule: "0 6 * * *", Jitter: time.Minute},
+			"dns_prune":          {Disabled: true},
+			"broken":             {Schedule: "not a schedule"},
+		})
+		run := func(ctx context.Context, now time.Time) error { return nil }
+
+		assert.NoError(t, scheduler.Register(Job{Name: "certificates_check", Schedule: Every(time.Hour), Run: run}))
+		assert.NoError(t, scheduler.Register(Job{Name: "dns_prune", Schedule: Every(time.Hour), Run: run}))
+		assert.Error(t, scheduler.Register(Job{Name: "broken", Schedule: Every(time.Hour), Run: run}))
+		assert.Error(t, scheduler.Register(Job{Name: "certificates_check", Schedule: Every(time.Hour), Run: run}))
+
+		stats := scheduler.Stats()
+		assert.Len(t, stats, 1)
+		assert.Equal(t, "0 6 * * *", stats[0].Schedule)
+		assert.Equal(t, time.Minute, scheduler.jobs["certificates_check"].job.Jitter)
+	})
+}
@@ -0,0 +1,139 @@
+package scripting
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// response is a response sent by a script in place of the handlers
+type response struct {
+	status int
+	body   string
+}
+
+// exchange is the request a script runs on
+type exchange struct {
+	ctx      *gin.Context
+	file     string
+	headers  *lua.LTable
+	response *response
+}
+
+// open sets the globals of the script:
+//
+//	request: method, path, route, client_ip, headers (lower case names), query and params
+//	set_header(name, value), a nil or empty value removes the request header
+//	set_query(name, value), a nil or empty value removes the query parameter
+//	set_response_header(name, value)
+//	respond(status, body), answers the request instead of the handlers
+//	log(message)
+func (exchange *exchange) open(state *lua.LState) {
+	request := exchange.ctx.Request
+	exchange.headers = state.NewTable()
+	for name := range request.Header {
+		exchange.headers.RawSetString(strings.ToLower(name), lua.LString(strings.Join(request.Header.Values(name), ", ")))
+	}
+	query := state.NewTable()
+	for name, values := range request.URL.Query() {
+		query.RawSetString(name, lua.LString(values[0]))
+	}
+	params := state.NewTable()
+	for _, param := range exchange.ctx.Params {
+		params.RawSetString(param.Key, lua.LString(param.Value))
+	}
+	requestTable := state.NewTable()
+	requestTable.RawSetString("method", lua.LString(request.Method))
+	requestTable.RawSetString("path", lua.LString(request.URL.Path))
+	requestTable.RawSetString("route", lua.LString(exchange.ctx.FullPath()))
+	requestTable.RawSetString("client_ip", lua.LString(exchange.ctx.ClientIP()))
+	requestTable.RawSetString("headers", exchange.headers)
+	requestTable.RawSetString("query", query)
+	requestTable.RawSetString("params", params)
+
+	state.SetGlobal("request", requestTable)
+	state.SetGlobal("set_header", state.NewFunction(exchange.setHeader))
+	state.SetGlobal("set_query", state.NewFunction(exchange.setQuery))
+	state.SetGlobal("set_response_header", state.NewFunction(exchange.setResponseHeader))
+	state.SetGlobal("respond", state.NewFunction(exchange.respond))
+	state.SetGlobal("log", state.NewFunction(exchange.log))
+}
+
+// stringArgs returns the arguments as strings, a missing or nil argument is empty
+func stringArgs(state *lua.LState, count int) []string {
+	values := make([]string, count)
+	for index := range values {
+		switch value := state.Get(index + 1).(type) {
+		case *lua.LNilType:
+		case lua.LString, lua.LNumber:
+			values[index] = value.String()
+		default:
+			state.ArgError(index+1, fmt.Sprintf("string expected, got %s", value.Type()))
+		}
+	}
+	return values
+}
+
+func (exchange *exchange) setHeader(state *lua.LState) int {
+	values := stringArgs(state, 2)
+	if values[0] == "" {
+		state.ArgError(1, "header name expected")
+	}
+	if values[1] == "" {
+		exchange.ctx.Request.Header.Del(values[0])
+		exchange.headers.RawSetString(strings.ToLower(values[0]), lua.LNil)
+		return 0
+	}
+	exchange.ctx.Request.Header.Set(values[0], values[1])
+	exchange.headers.RawSetString(strings.ToLower(values[0]), lua.LString(values[1]))
+	return 0
+}
+
+func (exchange *exchange) setQuery(state *lua.LState) int {
+	values := stringArgs(state, 2)
+	url := exchange.ctx.Request.URL
+	query := url.Query()
+	if values[1] == "" {
+		query.Del(values[0])
+	} else {
+		query.Set(values[0], values[1])
+	}
+	url.RawQuery = query.Encode()
+	exchange.ctx.Request.RequestURI = url.RequestURI()
+	return 0
+}
+
+func (exchange *exchange) setResponseHeader(state *lua.LState) int {
+	values := stringArgs(state, 2)
+	if values[0] == "" {
+		state.ArgError(1, "header name expected")
+	}
+	exchange.ctx.Header(values[0], values[1])
+	return 0
+}
+
+func (exchange *exchange) respond(state *lua.LState) int {
+	status, ok := state.Get(1).(lua.LNumber)
+	if !ok || status < 100 || status > 599 || status != lua.LNumber(int(status)) {
+		state.ArgError(1, "HTTP status expected")
+	}
+	values := stringArgs(state, 2)
+	exchange.response = &response{status: int(status), body: values[1]}
+	return 0
+}
+
+func (exchange *exchange) log(state *lua.LState) int {
+	logger, err := commonLogger.GetLoggerFromContext(exchange.ctx.Request.Context())
+	if err != nil {
+		return 0
+	}
+	messages := make([]string, state.GetTop())
+	for index := range messages {
+		messages[index] = state.ToStringMeta(state.Get(index + 1)).String()
+	}
+	logger.Info(fmt.Sprintf("Script %s: %s", exchange.file, strings.Join(messages, " ")))
+	return 0
+}
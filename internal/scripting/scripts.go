@@ -0,0 +1,234 @@
+package scripting
+
+import (
+	"bytes"
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// script is the script of a route with the last chunk compiled from its file
+type script struct {
+	file       string
+	chunk      atomic.Pointer[lua.FunctionProto]
+	modifiedAt time.Time
+}
+
+// Scripts run the Lua scripts of the routes before their handlers, so teams can transform and check
+// the requests of their routes without shipping Go code, every request runs in a fresh state
+type Scripts struct {
+	routes         map[string]*script
+	maxSteps       int
+	timeout        time.Duration
+	failOpen       bool
+	reloadInterval time.Duration
+	reloadMtx      sync.Mutex
+}
+
+// NewScripts creates the scripts of the configuration, compiling their files
+func NewScripts(configurations *config.Config) (*Scripts, error) {
+	scriptsConfig := configurations.Scripts
+	scripts := &Scripts{
+		routes:         map[string]*script{},
+		maxSteps:       scriptsConfig.MaxSteps,
+		timeout:        scriptsConfig.Timeout,
+		failOpen:       scriptsConfig.FailOpen,
+		reloadInterval: scriptsConfig.ReloadInterval,
+	}
+	for _, route := range scriptsConfig.Routes {
+		loaded := &script{file: route.File}
+		if err := loaded.load(); err != nil {
+			return nil, err
+		}
+		scripts.routes[routeKey(route.Method, route.Path)] = loaded
+	}
+	return scripts, nil
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// load compiles the file of the script when it changed
+func (loaded *script) load() error {
+	info, err := os.Stat(loaded.file)
+	if err != nil {
+		return fmt.Errorf("Could not read the script %s: %v", loaded.file, err)
+	}
+	if loaded.chunk.Load() != nil && info.ModTime().Equal(loaded.modifiedAt) {
+		return nil
+	}
+	source, err := os.ReadFile(loaded.file)
+	if err != nil {
+		return fmt.Errorf("Could not read the script %s: %v", loaded.file, err)
+	}
+	statements, err := parse.Parse(bytes.NewReader(source), loaded.file)
+	if err != nil {
+		return fmt.Errorf("Could not compile the script %s: %v", loaded.file, err)
+	}
+	chunk, err := lua.Compile(statements, loaded.file)
+	if err != nil {
+		return fmt.Errorf("Could not compile the script %s: %v", loaded.file, err)
+	}
+	loaded.chunk.Store(chunk)
+	loaded.modifiedAt = info.ModTime()
+	return nil
+}
+
+// Reload compiles the script files changed since they were compiled, a script keeps its chunk when its file is invalid
+func (scripts *Scripts) Reload() error {
+	scripts.reloadMtx.Lock()
+	defer scripts.reloadMtx.Unlock()
+	var errs []error
+	for _, loaded := range scripts.routes {
+		if err := loaded.load(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stdErrors.Join(errs...)
+}
+
+// Job reloads the changed scripts on every reload interval
+func (scripts *Scripts) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "scripts_reload",
+		Schedule: scheduler.Every(scripts.reloadInterval),
+		Run: func(ctx context.Context, now time.Time) error {
+			return scripts.Reload()
+		},
+	}
+}
+
+// script returns the script of the route for the method, else that of the route for any method
+func (scripts *Scripts) script(method, path string) *script {
+	if loaded, exists := scripts.routes[routeKey(method, path)]; exists {
+		return loaded
+	}
+	return scripts.routes[routeKey("", path)]
+}
+
+// Middleware runs the script of the route, which rejects the request by returning false
+// and answers it in place of the handlers by calling respond
+func (scripts *Scripts) Middleware(ctx *gin.Context) {
+	loaded := scripts.script(ctx.Request.Method, ctx.FullPath())
+	if loaded == nil {
+		ctx.Next()
+		return
+	}
+	exchange := &exchange{ctx: ctx, file: loaded.file}
+	rejected, err := scripts.run(exchange, loaded.chunk.Load())
+	if err != nil {
+		if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil {
+			logger.Error(err, fmt.Sprintf("The script %s failed", loaded.file))
+		}
+		if !scripts.failOpen {
+			errors.AbortWithError(ctx, errors.InternalError, err)
+			return
+		}
+		ctx.Next()
+		return
+	}
+	if exchange.response != nil {
+		ctx.Status(exchange.response.status)
+		ctx.Writer.WriteString(exchange.response.body)
+		ctx.Abort()
+		return
+	}
+	if rejected {
+		errors.Abort(ctx, errors.ScriptRejected)
+		return
+	}
+	ctx.Next()
+}
+
+// run runs the chunk in a fresh state and returns whether the script rejected the request
+func (scripts *Scripts) run(exchange *exchange, chunk *lua.FunctionProto) (bool, error) {
+	runCtx := exchange.ctx.Request.Context()
+	if scripts.timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, scripts.timeout)
+		defer cancel()
+	}
+	state := newState()
+	defer state.Close()
+	state.SetContext(&stepContext{Context: runCtx, maxSteps: scripts.maxSteps})
+	exchange.open(state)
+	state.Push(state.NewFunctionFromProto(chunk))
+	if err := state.PCall(0, 1, nil); err != nil {
+		return false, err
+	}
+	return state.Get(-1) == lua.LFalse, nil
+}
+
+// newState creates a state with the base, string, table and math libraries only, without the functions
+// loading code or reaching the process
+func newState() *lua.LState {
+	state := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, library := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(library.open))
+		state.Push(lua.LString(library.name))
+		state.Call(1, 0)
+	}
+	for _, name := range []string{
+		"collectgarbage", "dofile", "getfenv", "load", "loadfile", "loadstring",
+		"module", "newproxy", "print", "require", "setfenv", "_printregs",
+	} {
+		state.SetGlobal(name, lua.LNil)
+	}
+	state.SetField(state.GetGlobal(lua.StringLibName), "dump", lua.LNil)
+	return state
+}
+
+// closed is the channel of a run past its step limit
+var closed = func() chan struct{} {
+	channel := make(chan struct{})
+	close(channel)
+	return channel
+}()
+
+// stepContext is the context of a run, done once the script executed its steps, as gopher-lua checks
+// the context before every instruction
+type stepContext struct {
+	context.Context
+	steps    int
+	maxSteps int
+}
+
+func (ctx *stepContext) Done() <-chan struct{} {
+	if ctx.maxSteps > 0 {
+		ctx.steps++
+		if ctx.steps > ctx.maxSteps {
+			return closed
+		}
+	}
+	return ctx.Context.Done()
+}
+
+func (ctx *stepContext) Err() error {
+	if ctx.maxSteps > 0 && ctx.steps > ctx.maxSteps {
+		return fmt.Errorf("The script exceeded %d steps", ctx.maxSteps)
+	}
+	return ctx.Context.Err()
+}
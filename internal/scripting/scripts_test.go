@@ -0,0 +1,214 @@
+package scripting
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newScripts(t *testing.T, scriptsConfig config.ScriptsConfig) *Scripts {
+	scripts, err := NewScripts(&config.Config{Scripts: scriptsConfig})
+	assert.NoError(t, err)
+	return scripts
+}
+
+func newScriptRouter(scripts *Scripts, logger commonLogger.Loggerer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, logger))
+	}, scripts.Middleware)
+	router.GET("/api/v1/users/:id", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"tenant":   ctx.GetHeader("X-Tenant"),
+			"internal": ctx.GetHeader("X-Internal"),
+			"query":    ctx.Request.URL.RawQuery,
+		})
+	})
+	router.GET("/public", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	return router
+}
+
+func TestScripts(t *testing.T) {
+	routeConfig := config.ScriptRouteConfig{Method: http.MethodGet, Path: "/api/v1/users/:id", File: "testdata/route.lua"}
+
+	t.Run("Middleware_Should_Transform_The_Request", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		router := newScriptRouter(newScripts(t, config.ScriptsConfig{Routes: []config.ScriptRouteConfig{routeConfig}}), loggerMock)
+
+		loggerMock.EXPECT().Info("Script testdata/route.lua: tenant acme")
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/users/7?page=2", nil)
+		request.Header.Set("X-Tenant", "acme")
+		request.Header.Set("X-Internal", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"tenant":"ACME","internal":"","query":"page=2&source=gateway"}`, w.Body.String())
+		assert.Equal(t, "true", w.Header().Get("X-Scripted"))
+	})
+
+	t.Run("Middleware_Should_Reject_The_Request", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		router := newScriptRouter(newScripts(t, config.ScriptsConfig{Routes: []config.ScriptRouteConfig{routeConfig}}), commonLoggerMock.NewMockLoggerer(controller))
+
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/users/7", nil)
+		request.Header.Set("X-Tenant", "blocked")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"script_rejected","message":"The request was rejected by a route script"}}`, w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Send_The_Response_Of_The_Script", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		router := newScriptRouter(newScripts(t, config.ScriptsConfig{Routes: []config.ScriptRouteConfig{routeConfig}}), commonLoggerMock.NewMockLoggerer(controller))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/7?probe=1", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "alive 7", w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Fail_On_Script_Errors", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		router := newScriptRouter(newScripts(t, config.ScriptsConfig{Routes: []config.ScriptRouteConfig{routeConfig}}), loggerMock)
+
+		loggerMock.EXPECT().Error(gomock.Any(), "The script testdata/route.lua failed")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/7?fail=1", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Middleware_Should_Fail_Open_On_Script_Errors", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		router := newScriptRouter(newScripts(t, config.ScriptsConfig{Routes: []config.ScriptRouteConfig{routeConfig}, FailOpen: true}), loggerMock)
+
+		loggerMock.EXPECT().Error(gomock.Any(), "The script testdata/route.lua failed")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/7?fail=1", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Middleware_Should_Stop_Scripts_At_The_Step_Limit", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		file := filepath.Join(t.TempDir(), "spin.lua")
+		assert.NoError(t, os.WriteFile(file, []byte("while true do end"), 0o600))
+		scripts := newScripts(t, config.ScriptsConfig{
+			Routes:   []config.ScriptRouteConfig{{Path: "/public", File: file}},
+			MaxSteps: 1000,
+		})
+		router := newScriptRouter(scripts, loggerMock)
+
+		loggerMock.EXPECT().Error(gomock.Any(), "The script "+file+" failed")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Middleware_Should_Stop_Scripts_At_The_Timeout", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		file := filepath.Join(t.TempDir(), "spin.lua")
+		assert.NoError(t, os.WriteFile(file, []byte("while true do end"), 0o600))
+		scripts := newScripts(t, config.ScriptsConfig{
+			Routes:  []config.ScriptRouteConfig{{Path: "/public", File: file}},
+			Timeout: 20 * time.Millisecond,
+		})
+		router := newScriptRouter(scripts, loggerMock)
+
+		loggerMock.EXPECT().Error(gomock.Any(), "The script "+file+" failed")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Middleware_Should_Only_Open_The_Restricted_Libraries", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		file := filepath.Join(t.TempDir(), "sandbox.lua")
+		source := `return not (os or io or debug or package or require or load or loadstring or dofile or string.dump)
+			and string.upper("a") == "A" and math.floor(1.5) == 1 and table.concat({"a", "b"}) == "ab"`
+		assert.NoError(t, os.WriteFile(file, []byte(source), 0o600))
+		scripts := newScripts(t, config.ScriptsConfig{Routes: []config.ScriptRouteConfig{{Path: "/public", File: file}}})
+		router := newScriptRouter(scripts, commonLoggerMock.NewMockLoggerer(controller))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("Middleware_Should_Skip_The_Routes_Without_Script", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		router := newScriptRouter(newScripts(t, config.ScriptsConfig{Routes: []config.ScriptRouteConfig{routeConfig}}), commonLoggerMock.NewMockLoggerer(controller))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("NewScripts_Should_Reject_Invalid_Scripts", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "broken.lua")
+		assert.NoError(t, os.WriteFile(file, []byte("if then"), 0o600))
+
+		scripts, err := NewScripts(&config.Config{Scripts: config.ScriptsConfig{Routes: []config.ScriptRouteConfig{{Path: "/public", File: file}}}})
+
+		assert.Nil(t, scripts)
+		assert.Contains(t, err.Error(), "Could not compile the script")
+	})
+
+	t.Run("Reload_Should_Keep_The_Last_Valid_Script", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		file := filepath.Join(t.TempDir(), "reload.lua")
+		assert.NoError(t, os.WriteFile(file, []byte("return false"), 0o600))
+		scripts := newScripts(t, config.ScriptsConfig{Routes: []config.ScriptRouteConfig{{Path: "/public", File: file}}})
+		router := newScriptRouter(scripts, commonLoggerMock.NewMockLoggerer(controller))
+
+		assert.NoError(t, os.WriteFile(file, []byte("return true"), 0o600))
+		assert.NoError(t, os.Chtimes(file, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+		assert.NoError(t, scripts.Reload())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		assert.NoError(t, os.WriteFile(file, []byte("return ("), 0o600))
+		assert.NoError(t, os.Chtimes(file, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute)))
+		assert.Error(t, scripts.Reload())
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+}
@@ -0,0 +1,19 @@
+-- Tags the requests of the route, answers the health probes and rejects the blocked tenants
+local tenant = request.headers["x-tenant"]
+if tenant == "blocked" then
+  return false
+end
+if request.query.probe then
+  respond(200, "alive " .. request.params.id)
+  return
+end
+if tenant then
+  set_header("X-Tenant", string.upper(tenant))
+  log("tenant", tenant)
+end
+set_header("X-Internal", nil)
+set_query("source", "gateway")
+set_response_header("X-Scripted", "true")
+if request.query.fail then
+  error("failure requested")
+end
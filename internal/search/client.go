@@ -0,0 +1,88 @@
+package search
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/search/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_search"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// ServiceClienter is an interface for the search service client
+type ServiceClienter interface {
+	Search(ctx *gin.Context)
+}
+
+// ServiceClient is a struct for the search service client
+type ServiceClient struct {
+	client   pb_search.SearchServiceClient
+	cache    routes.ResultCacher
+	cacheTTL time.Duration
+}
+
+var _ ServiceClienter = &ServiceClient{}
+
+// Cache returns the cache of the search results
+func (service *ServiceClient) Cache() routes.ResultCacher {
+	return service.cache
+}
+
+// UseCache caches the search results in the given cache
+func (service *ServiceClient) UseCache(cache routes.ResultCacher) {
+	service.cache = cache
+}
+
+// InitServiceClient initializes the search service client
+func InitServiceClient(centralConfig *commonConfig.Config, configurations *config.Config) (pb_search.SearchServiceClient, error) {
+	grpcServiceAddress := fmt.Sprintf(
+		"%s:%s",
+		configurations.SearchService.Host,
+		configurations.SearchService.Port,
+	)
+
+	fmt.Println("Connecting to search service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection("search", grpcServiceAddress, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc search service: %v", err)
+	}
+
+	regionalConnection, err := region.RouteConnection("search", clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConnection, err := tenancy.RouteConnection("search", regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	routedConnection, err := versioning.RouteConnection("search", tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tracedConnection := tracing.TraceConnection("search", routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection("search", tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection("search", instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection("search", retryingConnection, configurations)
+	hookedConnection := hooks.HookConnection("search", breakerConnection, configurations)
+
+	return pb_search.NewSearchServiceClient(hookedConnection), nil
+}
+
+// Search redirects request to the search route
+func (service *ServiceClient) Search(ctx *gin.Context) {
+	routes.Search(ctx, service.client, service.cache, service.cacheTTL)
+}
@@ -0,0 +1,45 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	"golang.org/x/time/rate"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/search/routes"
+)
+
+// RegisterRoutes registers the search routes
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*ServiceClient, error) {
+	client, err := InitServiceClient(centralConfig, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not initialize search service client: %v", err)
+	}
+	service := &ServiceClient{
+		client:   client,
+		cache:    routes.NewResultCache(configurations),
+		cacheTTL: configurations.SearchService.CacheTTL,
+	}
+
+	rl := middleware.NewRateLimiter(
+		rate.Limit(configurations.SearchService.RateLimit),
+		configurations.SearchService.RateLimitBurst,
+	)
+	api.GET(
+		"/search",
+		authenticationMiddleware.RequireAuthentication,
+		middleware.UserRateLimitMiddleware(rl),
+		service.Search,
+	)
+
+	return service, nil
+}
@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+)
+
+const (
+	resultsKeyPrefix  = "search:results:"
+	defaultMaxEntries = 1000
+)
+
+// ResultCacher keeps serialized search responses for a short time
+type ResultCacher interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// NewResultCache creates a redis result cache when redis is configured and an in memory one otherwise
+func NewResultCache(configurations *config.Config) ResultCacher {
+	if configurations.Redis.Address == "" {
+		maxEntries := configurations.SearchService.CacheMaxEntries
+		if maxEntries <= 0 {
+			maxEntries = defaultMaxEntries
+		}
+		return NewMemoryResultCache(maxEntries)
+	}
+	return NewRedisResultCache(redis.NewClient(configurations))
+}
+
+// RedisResultCache stores search responses in redis so they are shared between gateway instances
+type RedisResultCache struct {
+	client redis.Clienter
+}
+
+var _ ResultCacher = &RedisResultCache{}
+
+// NewRedisResultCache creates a result cache backed by redis
+func NewRedisResultCache(client redis.Clienter) *RedisResultCache {
+	return &RedisResultCache{client: client}
+}
+
+// Get returns the cached response or nil when there is none
+func (cache *RedisResultCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := cache.client.Get(ctx, resultsKeyPrefix+key)
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not get cached search results: %v", err)
+	}
+	return []byte(value), nil
+}
+
+// Set caches the response for the given time
+func (cache *RedisResultCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := cache.client.Set(ctx, resultsKeyPrefix+key, string(value), ttl); err != nil {
+		return fmt.Errorf("Could not cache search results: %v", err)
+	}
+	return nil
+}
+
+type cachedResult struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryResultCache stores search responses in the gateway memory, evicting the least recently used one
+// beyond the max entries. The expired entries are dropped when read or evicted
+type MemoryResultCache struct {
+	maxEntries int
+	recency    *list.List
+	results    map[string]*list.Element
+	mtx        sync.Mutex
+}
+
+var _ ResultCacher = &MemoryResultCache{}
+
+// NewMemoryResultCache creates an in memory result cache of at most the max entries
+func NewMemoryResultCache(maxEntries int) *MemoryResultCache {
+	return &MemoryResultCache{
+		maxEntries: maxEntries,
+		recency:    list.New(),
+		results:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached response or nil when there is none
+func (cache *MemoryResultCache) Get(ctx context.Context, key string) ([]byte, error) {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	element, exists := cache.results[key]
+	if !exists {
+		return nil, nil
+	}
+	result := element.Value.(*cachedResult)
+	if time.Now().After(result.expiresAt) {
+		cache.remove(element)
+		return nil, nil
+	}
+	cache.recency.MoveToFront(element)
+	return result.value, nil
+}
+
+// Set caches the response for the given time
+func (cache *MemoryResultCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	if element, exists := cache.results[key]; exists {
+		cache.remove(element)
+	}
+	cache.results[key] = cache.recency.PushFront(&cachedResult{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	for cache.recency.Len() > cache.maxEntries {
+		cache.remove(cache.recency.Back())
+	}
+	return nil
+}
+
+// Len returns the number of cached responses, expired or not
+func (cache *MemoryResultCache) Len() int {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	return cache.recency.Len()
+}
+
+func (cache *MemoryResultCache) remove(element *list.Element) {
+	result := cache.recency.Remove(element).(*cachedResult)
+	delete(cache.results, result.key)
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_search"
+)
+
+func TestMemoryResultCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Set_Should_Evict_The_Least_Recently_Used_Result", func(t *testing.T) {
+		cache := NewMemoryResultCache(2)
+		assert.NoError(t, cache.Set(ctx, "first", []byte("1"), time.Minute))
+		assert.NoError(t, cache.Set(ctx, "second", []byte("2"), time.Minute))
+		value, _ := cache.Get(ctx, "first")
+		assert.Equal(t, []byte("1"), value)
+
+		assert.NoError(t, cache.Set(ctx, "third", []byte("3"), time.Minute))
+
+		assert.Equal(t, 2, cache.Len())
+		evicted, _ := cache.Get(ctx, "second")
+		assert.Nil(t, evicted)
+		kept, _ := cache.Get(ctx, "first")
+		assert.Equal(t, []byte("1"), kept)
+	})
+
+	t.Run("Get_Should_Drop_The_Expired_Result", func(t *testing.T) {
+		cache := NewMemoryResultCache(2)
+		assert.NoError(t, cache.Set(ctx, "expired", []byte("1"), -time.Second))
+
+		value, err := cache.Get(ctx, "expired")
+
+		assert.NoError(t, err)
+		assert.Nil(t, value)
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("Set_Should_Replace_The_Result_Of_The_Key", func(t *testing.T) {
+		cache := NewMemoryResultCache(2)
+		assert.NoError(t, cache.Set(ctx, "key", []byte("1"), time.Minute))
+		assert.NoError(t, cache.Set(ctx, "key", []byte("2"), time.Minute))
+
+		value, _ := cache.Get(ctx, "key")
+
+		assert.Equal(t, []byte("2"), value)
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("NewResultCache_Should_Default_The_Max_Entries", func(t *testing.T) {
+		cache := NewResultCache(&config.Config{})
+
+		assert.Equal(t, defaultMaxEntries, cache.(*MemoryResultCache).maxEntries)
+	})
+}
+
+type countingSearchClient struct {
+	calls int
+}
+
+func (client *countingSearchClient) Search(ctx context.Context, in *pb_search.SearchRequest, opts ...grpc.CallOption) (*pb_search.SearchResponse, error) {
+	client.calls++
+	return &pb_search.SearchResponse{Results: []*pb_search.SearchResult{}}, nil
+}
+
+func TestSearchCaching(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(t *testing.T, cache ResultCacher, cacheTTL time.Duration) (*countingSearchClient, []string) {
+		controller := gomock.NewController(t)
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		client := &countingSearchClient{}
+		router := gin.New()
+		router.Use(func(ctx *gin.Context) {
+			ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+		})
+		router.GET("/search", func(ctx *gin.Context) { Search(ctx, client, cache, cacheTTL) })
+		statuses := []string{}
+		for index := 0; index < 2; index++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?q=shoes", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			statuses = append(statuses, w.Header().Get(CacheStatusHeader))
+		}
+		return client, statuses
+	}
+
+	t.Run("Search_Should_Serve_The_Cached_Page", func(t *testing.T) {
+		client, statuses := serve(t, NewMemoryResultCache(10), time.Minute)
+
+		assert.Equal(t, 1, client.calls)
+		assert.Equal(t, []string{"MISS", "HIT"}, statuses)
+	})
+
+	t.Run("Search_Should_Not_Cache_Without_A_TTL", func(t *testing.T) {
+		cache := NewMemoryResultCache(10)
+		client, statuses := serve(t, cache, 0)
+
+		assert.Equal(t, 2, client.calls)
+		assert.Equal(t, []string{"", ""}, statuses)
+		assert.Equal(t, 0, cache.Len())
+	})
+}
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxQueryLength is the maximum number of characters of a normalized query
+const MaxQueryLength = 256
+
+// NormalizeQuery trims, lowercases and collapses the whitespace of a query, dropping control characters
+func NormalizeQuery(query string) (string, error) {
+	var builder strings.Builder
+	pendingSpace := false
+	length := 0
+	for _, character := range query {
+		if unicode.IsSpace(character) {
+			pendingSpace = builder.Len() > 0
+			continue
+		}
+		if unicode.IsControl(character) || character == unicode.ReplacementChar {
+			continue
+		}
+		if pendingSpace {
+			builder.WriteRune(' ')
+			length++
+			pendingSpace = false
+		}
+		builder.WriteRune(unicode.ToLower(character))
+		length++
+		if length > MaxQueryLength {
+			return "", fmt.Errorf("The query cannot be longer than %d characters", MaxQueryLength)
+		}
+	}
+	if builder.Len() == 0 {
+		return "", fmt.Errorf("The query cannot be empty")
+	}
+	return builder.String(), nil
+}
+
+// cursor is the opaque pagination state handed to clients, bound to the query it was issued for
+type cursor struct {
+	Offset    int32  `json:"o"`
+	QueryHash string `json:"q"`
+}
+
+func hashQuery(query, resultType string) string {
+	sum := sha256.Sum256([]byte(resultType + "\x00" + query))
+	return hex.EncodeToString(sum[:8])
+}
+
+// EncodeCursor creates the cursor of the page starting at the offset
+func EncodeCursor(offset int32, query, resultType string) string {
+	value, _ := json.Marshal(cursor{Offset: offset, QueryHash: hashQuery(query, resultType)})
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// DecodeCursor returns the offset of the cursor, rejecting cursors issued for other queries
+func DecodeCursor(encoded, query, resultType string) (int32, error) {
+	if encoded == "" {
+		return 0, nil
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid cursor: %v", err)
+	}
+	decoded := cursor{}
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return 0, fmt.Errorf("Invalid cursor: %v", err)
+	}
+	if decoded.Offset < 0 || decoded.QueryHash != hashQuery(query, resultType) {
+		return 0, fmt.Errorf("The cursor does not belong to the query")
+	}
+	return decoded.Offset, nil
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	t.Run("NormalizeQuery_Should_Trim_Lowercase_And_Collapse_Whitespace", func(t *testing.T) {
+		query, err := NormalizeQuery("  Hello \t  WORLD\n ")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "hello world", query)
+	})
+
+	t.Run("NormalizeQuery_Should_Drop_Control_Characters", func(t *testing.T) {
+		query, err := NormalizeQuery("foo\x00bar\x1b")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "foobar", query)
+	})
+
+	t.Run("NormalizeQuery_Empty_Error", func(t *testing.T) {
+		query, err := NormalizeQuery(" \t\n")
+
+		assert.Error(t, err)
+		assert.Equal(t, "", query)
+	})
+
+	t.Run("NormalizeQuery_Too_Long_Error", func(t *testing.T) {
+		query, err := NormalizeQuery(strings.Repeat("a", MaxQueryLength+1))
+
+		assert.Error(t, err)
+		assert.Equal(t, "", query)
+	})
+}
+
+func TestCursor(t *testing.T) {
+	t.Run("Cursor_Should_Round_Trip_Offset", func(t *testing.T) {
+		encoded := EncodeCursor(40, "hello world", "users")
+
+		offset, err := DecodeCursor(encoded, "hello world", "users")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(40), offset)
+	})
+
+	t.Run("Cursor_Empty_Should_Start_At_First_Page", func(t *testing.T) {
+		offset, err := DecodeCursor("", "hello world", "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(0), offset)
+	})
+
+	t.Run("Cursor_Of_Another_Query_Error", func(t *testing.T) {
+		encoded := EncodeCursor(40, "hello world", "")
+
+		_, err := DecodeCursor(encoded, "another query", "")
+
+		assert.Error(t, err)
+		assert.Equal(t, "The cursor does not belong to the query", err.Error())
+	})
+
+	t.Run("Cursor_Malformed_Error", func(t *testing.T) {
+		_, err := DecodeCursor("not a cursor", "hello world", "")
+
+		assert.Error(t, err)
+	})
+}
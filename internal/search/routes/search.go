@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_search"
+)
+
+// CacheStatusHeader tells whether the search response was served from the gateway cache
+const CacheStatusHeader = "X-Cache"
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 50
+)
+
+// SearchResponse is the response of the search route
+type SearchResponse struct {
+	Results    []*pb_search.SearchResult `json:"results"`
+	Total      int32                     `json:"total"`
+	NextCursor string                    `json:"nextCursor,omitempty"`
+}
+
+// Search queries the search service mapping its offsets to opaque cursors and caching the pages briefly, the pages
+// are not cached when the cache TTL is not set
+func Search(ctx *gin.Context, client pb_search.SearchServiceClient, cache ResultCacher, cacheTTL time.Duration) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	query, err := NormalizeQuery(ctx.Query("q"))
+	if err != nil {
+		errors.Abort(ctx, errors.InvalidArgument, errors.FieldViolation("q", err.Error()))
+		return
+	}
+	resultType := ctx.Query("type")
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize <= 0 || pageSize > maxPageSize {
+		errors.Abort(
+			ctx,
+			errors.InvalidArgument,
+			errors.FieldViolation("pageSize", fmt.Sprintf("pageSize must be a number between 1 and %d", maxPageSize)),
+		)
+		return
+	}
+	offset, err := DecodeCursor(ctx.Query("cursor"), query, resultType)
+	if err != nil {
+		errors.Abort(ctx, errors.InvalidArgument, errors.FieldViolation("cursor", err.Error()))
+		return
+	}
+
+	// a TTL of zero would keep the pages forever in redis and expire them at once in memory
+	caching := cacheTTL > 0
+	cacheKey := searchCacheKey(ctx, query, resultType, offset, pageSize)
+	if caching {
+		if cached, err := cache.Get(ctx.Request.Context(), cacheKey); err != nil {
+			logger.Error(err, "Could not read the search cache")
+		} else if cached != nil {
+			ctx.Header(CacheStatusHeader, "HIT")
+			ctx.Data(http.StatusOK, "application/json; charset=utf-8", cached)
+			return
+		}
+	}
+
+	res, err := client.Search(
+		ctx.Request.Context(),
+		&pb_search.SearchRequest{
+			Query:  query,
+			Type:   resultType,
+			Offset: offset,
+			Limit:  int32(pageSize),
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	response := SearchResponse{Results: res.Results, Total: res.Total}
+	if response.Results == nil {
+		response.Results = []*pb_search.SearchResult{}
+	}
+	nextOffset := offset + int32(len(res.Results))
+	if len(res.Results) > 0 && nextOffset < res.Total {
+		response.NextCursor = EncodeCursor(nextOffset, query, resultType)
+	}
+	body, err := json.Marshal(&response)
+	if err != nil {
+		logger.Error(err, "Could not serialize the search response")
+		errors.Abort(ctx, errors.InternalError)
+		return
+	}
+	if caching {
+		if err := cache.Set(ctx.Request.Context(), cacheKey, body, cacheTTL); err != nil {
+			logger.Error(err, "Could not write the search cache")
+		}
+		ctx.Header(CacheStatusHeader, "MISS")
+	}
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// searchCacheKey scopes the cached pages to the user since results may depend on their permissions
+func searchCacheKey(ctx *gin.Context, query, resultType string, offset int32, pageSize int) string {
+	userID := ""
+	if claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey)); exists {
+		if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+			userID = claims.UserID
+		}
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%d", userID, resultType, query, offset, pageSize)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,170 @@
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const payloadVersion2 = "2.0"
+
+// NewRequest translates an API Gateway event into the HTTP request served by the router
+func NewRequest(ctx context.Context, event *APIGatewayRequest) (*http.Request, error) {
+	method, path := event.HTTPMethod, event.Path
+	query := url.Values(event.MultiValueQueryStringParameters).Encode()
+	if query == "" && len(event.QueryStringParameters) > 0 {
+		values := url.Values{}
+		for name, value := range event.QueryStringParameters {
+			values.Set(name, value)
+		}
+		query = values.Encode()
+	}
+	if event.Version == payloadVersion2 {
+		method, path, query = event.RequestContext.HTTP.Method, event.RawPath, event.RawQueryString
+		// Named stages prefix the raw path of HTTP APIs
+		stage := event.RequestContext.Stage
+		if stage != "" && stage != "$default" && strings.HasPrefix(path, "/"+stage+"/") {
+			path = strings.TrimPrefix(path, "/"+stage)
+		}
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode the event body: %v", err)
+		}
+		body = decoded
+	}
+
+	target := &url.URL{Path: path, RawQuery: query}
+	request, err := http.NewRequestWithContext(ctx, method, target.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Could not create the request of the event: %v", err)
+	}
+	for name, values := range event.MultiValueHeaders {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	for name, value := range event.Headers {
+		if len(request.Header.Values(name)) == 0 {
+			request.Header.Set(name, value)
+		}
+	}
+	if len(event.Cookies) > 0 {
+		request.Header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+	if request.Header.Get("X-Request-Id") == "" && event.RequestContext.RequestID != "" {
+		request.Header.Set("X-Request-Id", event.RequestContext.RequestID)
+	}
+	request.Host = request.Header.Get("Host")
+	if request.Host == "" {
+		request.Host = event.RequestContext.DomainName
+	}
+	request.ContentLength = int64(len(body))
+	request.RequestURI = target.RequestURI()
+
+	sourceIP := event.RequestContext.Identity.SourceIP
+	if event.Version == payloadVersion2 {
+		sourceIP = event.RequestContext.HTTP.SourceIP
+	}
+	if sourceIP != "" {
+		request.RemoteAddr = net.JoinHostPort(sourceIP, "0")
+	}
+	return request, nil
+}
+
+// responseWriter buffers the response of the router until it is returned to API Gateway
+type responseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func newResponseWriter() *responseWriter {
+	return &responseWriter{header: make(http.Header)}
+}
+
+func (writer *responseWriter) Header() http.Header {
+	return writer.header
+}
+
+func (writer *responseWriter) Write(data []byte) (int, error) {
+	if writer.status == 0 {
+		writer.WriteHeader(http.StatusOK)
+	}
+	return writer.body.Write(data)
+}
+
+func (writer *responseWriter) WriteHeader(status int) {
+	if writer.status == 0 {
+		writer.status = status
+	}
+}
+
+// Flush is a no-op, API Gateway receives the whole response at once
+func (writer *responseWriter) Flush() {}
+
+// response translates the buffered response into the API Gateway response of the payload version
+func (writer *responseWriter) response(version string) *APIGatewayResponse {
+	status := writer.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := &APIGatewayResponse{StatusCode: status}
+	body := writer.body.Bytes()
+	if isText(writer.header.Get("Content-Type")) && utf8.Valid(body) {
+		response.Body = string(body)
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(body)
+		response.IsBase64Encoded = true
+	}
+
+	if version == payloadVersion2 {
+		response.Headers = make(map[string]string)
+		for name, values := range writer.header {
+			if http.CanonicalHeaderKey(name) == "Set-Cookie" {
+				response.Cookies = append(response.Cookies, values...)
+				continue
+			}
+			response.Headers[name] = strings.Join(values, ",")
+		}
+		return response
+	}
+	response.MultiValueHeaders = map[string][]string(writer.header.Clone())
+	return response
+}
+
+func isText(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if contentType == "" || strings.HasPrefix(contentType, "text/") {
+		return true
+	}
+	for _, textual := range []string{"json", "xml", "javascript", "x-www-form-urlencoded", "event-stream"} {
+		if strings.Contains(contentType, textual) {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve serves an API Gateway event with the handler
+func Serve(ctx context.Context, handler http.Handler, event *APIGatewayRequest) (*APIGatewayResponse, error) {
+	request, err := NewRequest(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	writer := newResponseWriter()
+	handler.ServeHTTP(writer, request)
+	return writer.response(event.Version), nil
+}
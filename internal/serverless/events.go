@@ -0,0 +1,51 @@
+package serverless
+
+// APIGatewayRequest is the proxy integration event of API Gateway REST APIs (payload 1.0)
+// and HTTP APIs (payload 2.0), the fields of both versions are decoded into it
+type APIGatewayRequest struct {
+	Version                         string              `json:"version"`
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	RawPath                         string              `json:"rawPath"`
+	RawQueryString                  string              `json:"rawQueryString"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	Cookies                         []string            `json:"cookies"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+	RequestContext                  RequestContext      `json:"requestContext"`
+}
+
+// RequestContext is the request context of the API Gateway event
+type RequestContext struct {
+	RequestID  string          `json:"requestId"`
+	Stage      string          `json:"stage"`
+	DomainName string          `json:"domainName"`
+	Identity   RequestIdentity `json:"identity"`
+	HTTP       RequestHTTP     `json:"http"`
+}
+
+// RequestIdentity is the caller identity of payload 1.0 events
+type RequestIdentity struct {
+	SourceIP string `json:"sourceIp"`
+}
+
+// RequestHTTP is the HTTP description of payload 2.0 events
+type RequestHTTP struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	SourceIP string `json:"sourceIp"`
+}
+
+// APIGatewayResponse is the proxy integration response, payload 1.0 uses the multi value headers
+// and payload 2.0 the cookies
+type APIGatewayResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Cookies           []string            `json:"cookies,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
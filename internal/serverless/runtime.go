@@ -0,0 +1,151 @@
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Platforms the router can run on
+const (
+	PlatformServer   = ""
+	PlatformAuto     = "auto"
+	PlatformLambda   = "lambda"
+	PlatformCloudRun = "cloud_run"
+)
+
+// Lambda runtime API path and headers
+const (
+	runtimeAPIEnv       = "AWS_LAMBDA_RUNTIME_API"
+	runtimeAPIVersion   = "2018-06-01"
+	requestIDHeader     = "Lambda-Runtime-Aws-Request-Id"
+	deadlineHeader      = "Lambda-Runtime-Deadline-Ms"
+	cloudRunServiceEnv  = "K_SERVICE"
+	cloudRunPortEnv     = "PORT"
+	maxErrorBodyLength  = 4096
+	errorTypeBadRequest = "Runtime.InvalidEvent"
+)
+
+// DetectPlatform resolves the configured platform, auto detects Lambda and Cloud Run from their environment
+func DetectPlatform(platform string) string {
+	if platform != PlatformAuto {
+		return platform
+	}
+	if os.Getenv(runtimeAPIEnv) != "" {
+		return PlatformLambda
+	}
+	if os.Getenv(cloudRunServiceEnv) != "" {
+		return PlatformCloudRun
+	}
+	return PlatformServer
+}
+
+// ListenAddress returns the address given by the Cloud Run contract, or the fallback outside Cloud Run
+func ListenAddress(fallback string) string {
+	if port := os.Getenv(cloudRunPortEnv); port != "" {
+		return ":" + port
+	}
+	return fallback
+}
+
+// Runtime serves the Lambda invocations with the handler through the Lambda runtime API
+type Runtime struct {
+	handler http.Handler
+	baseURL string
+	client  *http.Client
+}
+
+// NewRuntime creates the Lambda runtime of the handler
+func NewRuntime(handler http.Handler) (*Runtime, error) {
+	runtimeAPI := os.Getenv(runtimeAPIEnv)
+	if runtimeAPI == "" {
+		return nil, fmt.Errorf("The %s environment variable is not set", runtimeAPIEnv)
+	}
+	return &Runtime{
+		handler: handler,
+		baseURL: fmt.Sprintf("http://%s/%s/runtime/invocation/", runtimeAPI, runtimeAPIVersion),
+		client:  &http.Client{},
+	}, nil
+}
+
+// Run serves invocations until the context is done or the runtime API fails
+func (runtime *Runtime) Run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		if err := runtime.next(ctx); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// next waits for the next invocation and posts its response
+func (runtime *Runtime) next(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, runtime.baseURL+"next", nil)
+	if err != nil {
+		return err
+	}
+	invocation, err := runtime.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Could not get the next invocation: %v", err)
+	}
+	payload, err := io.ReadAll(invocation.Body)
+	invocation.Body.Close()
+	if err != nil {
+		return fmt.Errorf("Could not read the invocation: %v", err)
+	}
+	if invocation.StatusCode != http.StatusOK {
+		return fmt.Errorf("The runtime API failed to return the next invocation: %d", invocation.StatusCode)
+	}
+	requestID := invocation.Header.Get(requestIDHeader)
+
+	invocationContext := ctx
+	if deadline, err := strconv.ParseInt(invocation.Header.Get(deadlineHeader), 10, 64); err == nil {
+		var cancel context.CancelFunc
+		invocationContext, cancel = context.WithDeadline(ctx, time.UnixMilli(deadline))
+		defer cancel()
+	}
+
+	event := &APIGatewayRequest{}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return runtime.post(ctx, requestID+"/error", map[string]string{
+			"errorType":    errorTypeBadRequest,
+			"errorMessage": fmt.Sprintf("Could not decode the invocation event: %v", err),
+		})
+	}
+	response, err := Serve(invocationContext, runtime.handler, event)
+	if err != nil {
+		return runtime.post(ctx, requestID+"/error", map[string]string{
+			"errorType":    errorTypeBadRequest,
+			"errorMessage": err.Error(),
+		})
+	}
+	return runtime.post(ctx, requestID+"/response", response)
+}
+
+func (runtime *Runtime) post(ctx context.Context, path string, body interface{}) error {
+	serializedBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("Could not encode the invocation response: %v", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, runtime.baseURL+path, bytes.NewReader(serializedBody))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := runtime.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Could not post the invocation response: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyLength))
+		return fmt.Errorf("The runtime API rejected the invocation response: %d %s", response.StatusCode, message)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package serverless
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/users/:id", func(ctx *gin.Context) {
+		body, _ := io.ReadAll(ctx.Request.Body)
+		cookie, _ := ctx.Cookie("session")
+		ctx.SetCookie("seen", "1", 60, "/", "", true, true)
+		ctx.JSON(http.StatusCreated, gin.H{
+			"id":        ctx.Param("id"),
+			"tags":      ctx.QueryArray("tag"),
+			"body":      string(body),
+			"cookie":    cookie,
+			"client":    ctx.ClientIP(),
+			"requestID": ctx.GetHeader("X-Request-Id"),
+		})
+	})
+	router.GET("/api/v1/avatar", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "image/png", []byte{0x89, 0x50, 0x4e, 0x47})
+	})
+	return router
+}
+
+func decodeBody(t *testing.T, response *APIGatewayResponse) map[string]interface{} {
+	body := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(response.Body), &body))
+	return body
+}
+
+func TestServe(t *testing.T) {
+	t.Run("Serve_Should_Translate_REST_API_Events", func(t *testing.T) {
+		event := &APIGatewayRequest{
+			HTTPMethod:                      http.MethodPost,
+			Path:                            "/api/v1/users/42",
+			MultiValueQueryStringParameters: map[string][]string{"tag": {"a", "b"}},
+			MultiValueHeaders:               map[string][]string{"Cookie": {"session=abc"}},
+			Headers:                         map[string]string{"Content-Type": "application/json"},
+			Body:                            `{"name":"Ada"}`,
+			RequestContext:                  RequestContext{RequestID: "apigw-1", Identity: RequestIdentity{SourceIP: "203.0.113.7"}},
+		}
+
+		response, err := Serve(context.Background(), newTestRouter(), event)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+		assert.False(t, response.IsBase64Encoded)
+		assert.NotEmpty(t, response.MultiValueHeaders["Set-Cookie"])
+		body := decodeBody(t, response)
+		assert.Equal(t, "42", body["id"])
+		assert.Equal(t, []interface{}{"a", "b"}, body["tags"])
+		assert.Equal(t, `{"name":"Ada"}`, body["body"])
+		assert.Equal(t, "abc", body["cookie"])
+		assert.Equal(t, "203.0.113.7", body["client"])
+		assert.Equal(t, "apigw-1", body["requestID"])
+	})
+
+	t.Run("Serve_Should_Translate_HTTP_API_Events", func(t *testing.T) {
+		event := &APIGatewayRequest{
+			Version:         "2.0",
+			RawPath:         "/prod/api/v1/users/7",
+			RawQueryString:  "tag=x",
+			Cookies:         []string{"session=xyz"},
+			Body:            base64.StdEncoding.EncodeToString([]byte("payload")),
+			IsBase64Encoded: true,
+			RequestContext: RequestContext{
+				Stage: "prod",
+				HTTP:  RequestHTTP{Method: http.MethodPost, SourceIP: "198.51.100.1"},
+			},
+		}
+
+		response, err := Serve(context.Background(), newTestRouter(), event)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+		assert.Len(t, response.Cookies, 1)
+		assert.True(t, strings.HasPrefix(response.Cookies[0], "seen=1"))
+		assert.Empty(t, response.Headers["Set-Cookie"])
+		body := decodeBody(t, response)
+		assert.Equal(t, "7", body["id"])
+		assert.Equal(t, "payload", body["body"])
+		assert.Equal(t, "xyz", body["cookie"])
+	})
+
+	t.Run("Serve_Should_Encode_Binary_Responses", func(t *testing.T) {
+		event := &APIGatewayRequest{Version: "2.0", RawPath: "/api/v1/avatar", RequestContext: RequestContext{HTTP: RequestHTTP{Method: http.MethodGet}}}
+
+		response, err := Serve(context.Background(), newTestRouter(), event)
+		assert.NoError(t, err)
+		assert.True(t, response.IsBase64Encoded)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte{0x89, 0x50, 0x4e, 0x47}), response.Body)
+	})
+
+	t.Run("Serve_Should_Fail_On_Invalid_Bodies", func(t *testing.T) {
+		event := &APIGatewayRequest{HTTPMethod: http.MethodPost, Path: "/", Body: "%%%", IsBase64Encoded: true}
+
+		_, err := Serve(context.Background(), newTestRouter(), event)
+		assert.Error(t, err)
+	})
+}
+
+func TestRuntime(t *testing.T) {
+	t.Run("Run_Should_Post_The_Responses_Of_The_Invocations", func(t *testing.T) {
+		responses := make(chan *APIGatewayResponse, 1)
+		invocations := 0
+		runtimeAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/invocation/next"):
+				invocations++
+				if invocations > 1 {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set(requestIDHeader, "invocation-1")
+				w.Header().Set(deadlineHeader, strconvMillis(time.Now().Add(time.Minute)))
+				json.NewEncoder(w).Encode(&APIGatewayRequest{
+					Version:        "2.0",
+					RawPath:        "/api/v1/users/1",
+					RequestContext: RequestContext{HTTP: RequestHTTP{Method: http.MethodPost}},
+				})
+			case strings.HasSuffix(r.URL.Path, "/invocation/invocation-1/response"):
+				response := &APIGatewayResponse{}
+				json.NewDecoder(r.Body).Decode(response)
+				responses <- response
+				w.WriteHeader(http.StatusAccepted)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer runtimeAPI.Close()
+		t.Setenv(runtimeAPIEnv, strings.TrimPrefix(runtimeAPI.URL, "http://"))
+
+		lambdaRuntime, err := NewRuntime(newTestRouter())
+		assert.NoError(t, err)
+		err = lambdaRuntime.Run(context.Background())
+		assert.Error(t, err)
+
+		response := <-responses
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+	})
+
+	t.Run("DetectPlatform_Should_Detect_The_Environment", func(t *testing.T) {
+		t.Setenv(runtimeAPIEnv, "")
+		t.Setenv(cloudRunServiceEnv, "gateway")
+		t.Setenv(cloudRunPortEnv, "8081")
+
+		assert.Equal(t, PlatformCloudRun, DetectPlatform(PlatformAuto))
+		assert.Equal(t, PlatformServer, DetectPlatform(PlatformServer))
+		assert.Equal(t, ":8081", ListenAddress("0.0.0.0:8080"))
+	})
+}
+
+func strconvMillis(deadline time.Time) string {
+	return strconv.FormatInt(deadline.UnixMilli(), 10)
+}
@@ -0,0 +1,223 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+)
+
+const (
+	lastSeenKeyPrefix     = "session:last_seen:"
+	tokenSessionKeyPrefix = "session:token:"
+	activitySweepInterval = time.Minute
+)
+
+// ActivityStorer keeps track of the last time a session was seen at the gateway and of the session each access
+// token was issued for
+type ActivityStorer interface {
+	LastSeen(ctx context.Context, sessionID string) (*time.Time, error)
+	Touch(ctx context.Context, sessionID string, at time.Time, ttl time.Duration) error
+	Clear(ctx context.Context, sessionID string) error
+	Link(ctx context.Context, tokenID, sessionID string, ttl time.Duration) error
+	SessionOf(ctx context.Context, tokenID string) (string, error)
+}
+
+// NewActivityStore creates a redis activity store when redis is configured and an in memory one otherwise
+func NewActivityStore(configurations *config.Config) ActivityStorer {
+	if configurations.Redis.Address == "" {
+		return NewMemoryActivityStore()
+	}
+	return NewRedisActivityStore(redis.NewClient(configurations))
+}
+
+// RedisActivityStore stores last seen timestamps in redis so they are shared between gateway instances
+type RedisActivityStore struct {
+	client redis.Clienter
+}
+
+var _ ActivityStorer = &RedisActivityStore{}
+
+// NewRedisActivityStore creates an activity store backed by redis
+func NewRedisActivityStore(client redis.Clienter) *RedisActivityStore {
+	return &RedisActivityStore{client: client}
+}
+
+// LastSeen returns the last seen time of the session or nil if it was never seen
+func (store *RedisActivityStore) LastSeen(ctx context.Context, sessionID string) (*time.Time, error) {
+	value, err := store.client.Get(ctx, lastSeenKeyPrefix+sessionID)
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not get last seen time of session: %v", err)
+	}
+	unixMilli, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid last seen time of session: %v", err)
+	}
+	lastSeen := time.UnixMilli(unixMilli)
+	return &lastSeen, nil
+}
+
+// Touch records the session as seen at the given time
+func (store *RedisActivityStore) Touch(ctx context.Context, sessionID string, at time.Time, ttl time.Duration) error {
+	err := store.client.Set(ctx, lastSeenKeyPrefix+sessionID, strconv.FormatInt(at.UnixMilli(), 10), ttl)
+	if err != nil {
+		return fmt.Errorf("Could not set last seen time of session: %v", err)
+	}
+	return nil
+}
+
+// Clear removes the activity record of the session
+func (store *RedisActivityStore) Clear(ctx context.Context, sessionID string) error {
+	if err := store.client.Del(ctx, lastSeenKeyPrefix+sessionID); err != nil {
+		return fmt.Errorf("Could not clear last seen time of session: %v", err)
+	}
+	return nil
+}
+
+// Link records the session the access token was issued for until the token expires
+func (store *RedisActivityStore) Link(ctx context.Context, tokenID, sessionID string, ttl time.Duration) error {
+	if err := store.client.Set(ctx, tokenSessionKeyPrefix+tokenID, sessionID, ttl); err != nil {
+		return fmt.Errorf("Could not link token to session: %v", err)
+	}
+	return nil
+}
+
+// SessionOf returns the session the access token was issued for or an empty string if it was never linked
+func (store *RedisActivityStore) SessionOf(ctx context.Context, tokenID string) (string, error) {
+	sessionID, err := store.client.Get(ctx, tokenSessionKeyPrefix+tokenID)
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Could not get session of token: %v", err)
+	}
+	return sessionID, nil
+}
+
+type activityRecord struct {
+	lastSeen  time.Time
+	expiresAt time.Time
+}
+
+type tokenLink struct {
+	sessionID string
+	expiresAt time.Time
+}
+
+// MemoryActivityStore keeps last seen timestamps in process, used when redis is not configured. The expired
+// records are swept on writes so the sessions never seen again do not stay in memory
+type MemoryActivityStore struct {
+	records map[string]activityRecord
+	links   map[string]tokenLink
+	sweptAt time.Time
+	mtx     sync.Mutex
+}
+
+var _ ActivityStorer = &MemoryActivityStore{}
+
+// NewMemoryActivityStore creates an in memory activity store
+func NewMemoryActivityStore() *MemoryActivityStore {
+	return &MemoryActivityStore{
+		records: make(map[string]activityRecord),
+		links:   make(map[string]tokenLink),
+		sweptAt: time.Now(),
+	}
+}
+
+// Len returns the number of activity records and token links held in memory
+func (store *MemoryActivityStore) Len() int {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	return len(store.records) + len(store.links)
+}
+
+// LastSeen returns the last seen time of the session or nil if it was never seen
+func (store *MemoryActivityStore) LastSeen(ctx context.Context, sessionID string) (*time.Time, error) {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	record, exists := store.records[sessionID]
+	if !exists {
+		return nil, nil
+	}
+	if !record.expiresAt.IsZero() && time.Now().After(record.expiresAt) {
+		delete(store.records, sessionID)
+		return nil, nil
+	}
+	lastSeen := record.lastSeen
+	return &lastSeen, nil
+}
+
+// Touch records the session as seen at the given time
+func (store *MemoryActivityStore) Touch(ctx context.Context, sessionID string, at time.Time, ttl time.Duration) error {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	record := activityRecord{lastSeen: at}
+	if ttl > 0 {
+		record.expiresAt = time.Now().Add(ttl)
+	}
+	store.records[sessionID] = record
+	store.sweep()
+	return nil
+}
+
+// Clear removes the activity record of the session
+func (store *MemoryActivityStore) Clear(ctx context.Context, sessionID string) error {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	delete(store.records, sessionID)
+	return nil
+}
+
+// Link records the session the access token was issued for until the token expires
+func (store *MemoryActivityStore) Link(ctx context.Context, tokenID, sessionID string, ttl time.Duration) error {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	link := tokenLink{sessionID: sessionID}
+	if ttl > 0 {
+		link.expiresAt = time.Now().Add(ttl)
+	}
+	store.links[tokenID] = link
+	store.sweep()
+	return nil
+}
+
+// SessionOf returns the session the access token was issued for or an empty string if it was never linked
+func (store *MemoryActivityStore) SessionOf(ctx context.Context, tokenID string) (string, error) {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	link, exists := store.links[tokenID]
+	if !exists {
+		return "", nil
+	}
+	if !link.expiresAt.IsZero() && time.Now().After(link.expiresAt) {
+		delete(store.links, tokenID)
+		return "", nil
+	}
+	return link.sessionID, nil
+}
+
+// sweep drops the expired records and links at most once per sweep interval, the caller holds the lock
+func (store *MemoryActivityStore) sweep() {
+	now := time.Now()
+	if now.Sub(store.sweptAt) < activitySweepInterval {
+		return
+	}
+	store.sweptAt = now
+	for sessionID, record := range store.records {
+		if !record.expiresAt.IsZero() && now.After(record.expiresAt) {
+			delete(store.records, sessionID)
+		}
+	}
+	for tokenID, link := range store.links {
+		if !link.expiresAt.IsZero() && now.After(link.expiresAt) {
+			delete(store.links, tokenID)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryActivityStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Touch_Should_Sweep_The_Expired_Records", func(t *testing.T) {
+		store := NewMemoryActivityStore()
+		assert.NoError(t, store.Touch(ctx, "expired-session", time.Now(), time.Nanosecond))
+		assert.NoError(t, store.Link(ctx, "expired-token", "expired-session", time.Nanosecond))
+		store.sweptAt = time.Now().Add(-activitySweepInterval)
+
+		assert.NoError(t, store.Touch(ctx, "active-session", time.Now(), time.Hour))
+
+		assert.Equal(t, 1, store.Len())
+	})
+
+	t.Run("SessionOf_Should_Return_The_Linked_Session", func(t *testing.T) {
+		store := NewMemoryActivityStore()
+		assert.NoError(t, store.Link(ctx, "token", "session", time.Hour))
+
+		sessionID, err := store.SessionOf(ctx, "token")
+		assert.NoError(t, err)
+		assert.Equal(t, "session", sessionID)
+
+		unknown, err := store.SessionOf(ctx, "unknown-token")
+		assert.NoError(t, err)
+		assert.Empty(t, unknown)
+	})
+}
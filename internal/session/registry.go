@@ -0,0 +1,221 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+)
+
+const (
+	activeKeyPrefix  = "session:active:"
+	revokedKeyPrefix = "session:revoked:"
+)
+
+// Entry is an active session of a user
+type Entry struct {
+	ID     string
+	Expiry time.Time
+}
+
+// Registrier keeps track of the active sessions of every user
+type Registrier interface {
+	Active(ctx context.Context, userID string, now time.Time) ([]Entry, error)
+	Add(ctx context.Context, userID string, entry Entry) error
+	Remove(ctx context.Context, userID, sessionID string) error
+	Revoke(ctx context.Context, userID string, entry Entry) error
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// IDFromToken derives the session identifier from the refresh token issued for the session
+func IDFromToken(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(hash[:])
+}
+
+// NewRegistry creates a redis session registry when redis is configured and an in memory one otherwise
+func NewRegistry(configurations *config.Config) Registrier {
+	if configurations.Redis.Address == "" {
+		return NewMemoryRegistry()
+	}
+	return NewRedisRegistry(redis.NewClient(configurations))
+}
+
+// RedisRegistry stores the active sessions of a user in a sorted set scored by expiry
+type RedisRegistry struct {
+	client redis.Clienter
+}
+
+var _ Registrier = &RedisRegistry{}
+
+// NewRedisRegistry creates a session registry backed by redis
+func NewRedisRegistry(client redis.Clienter) *RedisRegistry {
+	return &RedisRegistry{client: client}
+}
+
+// Active prunes the expired sessions of the user and returns the remaining ones, soonest to expire first
+func (registry *RedisRegistry) Active(ctx context.Context, userID string, now time.Time) ([]Entry, error) {
+	key := activeKeyPrefix + userID
+	_, err := registry.client.Do(ctx, "ZREMRANGEBYSCORE", key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+	if err != nil {
+		return nil, fmt.Errorf("Could not prune expired sessions: %v", err)
+	}
+	reply, err := registry.client.Do(ctx, "ZRANGE", key, "0", "-1", "WITHSCORES")
+	if err != nil {
+		return nil, fmt.Errorf("Could not list active sessions: %v", err)
+	}
+	values, err := redis.Strings(reply)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse active sessions: %v", err)
+	}
+	entries := make([]Entry, 0, len(values)/2)
+	for index := 0; index+1 < len(values); index += 2 {
+		score, err := strconv.ParseFloat(values[index+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid session expiry: %v", err)
+		}
+		entries = append(entries, Entry{ID: values[index], Expiry: time.UnixMilli(int64(score))})
+	}
+	return entries, nil
+}
+
+// Add registers an active session of the user
+func (registry *RedisRegistry) Add(ctx context.Context, userID string, entry Entry) error {
+	key := activeKeyPrefix + userID
+	_, err := registry.client.Do(ctx, "ZADD", key, strconv.FormatInt(entry.Expiry.UnixMilli(), 10), entry.ID)
+	if err != nil {
+		return fmt.Errorf("Could not register session: %v", err)
+	}
+	ttl := time.Until(entry.Expiry)
+	if ttl <= 0 {
+		return nil
+	}
+	_, err = registry.client.Do(ctx, "PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return fmt.Errorf("Could not set expiry of sessions: %v", err)
+	}
+	return nil
+}
+
+// Remove unregisters a session of the user
+func (registry *RedisRegistry) Remove(ctx context.Context, userID, sessionID string) error {
+	if _, err := registry.client.Do(ctx, "ZREM", activeKeyPrefix+userID, sessionID); err != nil {
+		return fmt.Errorf("Could not unregister session: %v", err)
+	}
+	return nil
+}
+
+// Revoke unregisters the session and flags it as revoked until it would have expired
+func (registry *RedisRegistry) Revoke(ctx context.Context, userID string, entry Entry) error {
+	if err := registry.Remove(ctx, userID, entry.ID); err != nil {
+		return err
+	}
+	ttl := time.Until(entry.Expiry)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := registry.client.Set(ctx, revokedKeyPrefix+entry.ID, "1", ttl); err != nil {
+		return fmt.Errorf("Could not revoke session: %v", err)
+	}
+	return nil
+}
+
+// IsRevoked returns whether the session was revoked
+func (registry *RedisRegistry) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	_, err := registry.client.Get(ctx, revokedKeyPrefix+sessionID)
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Could not check session revocation: %v", err)
+	}
+	return true, nil
+}
+
+// MemoryRegistry keeps the active sessions in process, used when redis is not configured
+type MemoryRegistry struct {
+	active  map[string]map[string]time.Time
+	revoked map[string]time.Time
+	mtx     sync.Mutex
+}
+
+var _ Registrier = &MemoryRegistry{}
+
+// NewMemoryRegistry creates an in memory session registry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		active:  make(map[string]map[string]time.Time),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Active prunes the expired sessions of the user and returns the remaining ones, soonest to expire first
+func (registry *MemoryRegistry) Active(ctx context.Context, userID string, now time.Time) ([]Entry, error) {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	entries := []Entry{}
+	for sessionID, expiry := range registry.active[userID] {
+		if !expiry.After(now) {
+			delete(registry.active[userID], sessionID)
+			continue
+		}
+		entries = append(entries, Entry{ID: sessionID, Expiry: expiry})
+	}
+	if len(registry.active[userID]) == 0 {
+		delete(registry.active, userID)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Expiry.Before(entries[j].Expiry)
+	})
+	return entries, nil
+}
+
+// Add registers an active session of the user
+func (registry *MemoryRegistry) Add(ctx context.Context, userID string, entry Entry) error {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	if registry.active[userID] == nil {
+		registry.active[userID] = make(map[string]time.Time)
+	}
+	registry.active[userID][entry.ID] = entry.Expiry
+	return nil
+}
+
+// Remove unregisters a session of the user
+func (registry *MemoryRegistry) Remove(ctx context.Context, userID, sessionID string) error {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	delete(registry.active[userID], sessionID)
+	return nil
+}
+
+// Revoke unregisters the session and flags it as revoked until it would have expired
+func (registry *MemoryRegistry) Revoke(ctx context.Context, userID string, entry Entry) error {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	delete(registry.active[userID], entry.ID)
+	registry.revoked[entry.ID] = entry.Expiry
+	return nil
+}
+
+// IsRevoked returns whether the session was revoked
+func (registry *MemoryRegistry) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	expiry, exists := registry.revoked[sessionID]
+	if !exists {
+		return false, nil
+	}
+	if time.Now().After(expiry) {
+		delete(registry.revoked, sessionID)
+		return false, nil
+	}
+	return true, nil
+}
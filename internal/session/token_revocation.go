@@ -0,0 +1,199 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Stores of the token revocation list
+const (
+	TokenRevocationStoreMemory = "memory"
+	TokenRevocationStoreRedis  = "redis"
+)
+
+const (
+	revokedTokenKeyPrefix          = "token:revoked:"
+	defaultTokenRevocationChannel  = "token_revocations"
+	defaultTokenRevocationInterval = 30 * time.Second
+)
+
+// TokenRevocation is a revoked token, as published on the revocation channel
+type TokenRevocation struct {
+	TokenID   string `json:"jti"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// TokenRevocations lists the revoked access tokens until they would have expired, either in redis
+// or in memory with the revocations of the authentication service received through the redis channel
+type TokenRevocations struct {
+	client          *redis.Client
+	shared          bool
+	channel         string
+	interval        time.Duration
+	revoked         map[string]time.Time
+	subscribing     atomic.Bool
+	subscriptionErr error
+	revokedMtx      sync.Mutex
+}
+
+// NewTokenRevocations creates the token revocation list of the configuration
+func NewTokenRevocations(configurations *config.Config) (*TokenRevocations, error) {
+	revocationConfig := configurations.TokenRevocation
+	revocations := &TokenRevocations{
+		channel:  revocationConfig.Channel,
+		interval: revocationConfig.Interval,
+		revoked:  make(map[string]time.Time),
+	}
+	if revocations.channel == "" {
+		revocations.channel = defaultTokenRevocationChannel
+	}
+	if revocations.interval <= 0 {
+		revocations.interval = defaultTokenRevocationInterval
+	}
+	if configurations.Redis.Address != "" {
+		revocations.client = redis.NewClient(configurations)
+	}
+	switch revocationConfig.Store {
+	case "", TokenRevocationStoreMemory:
+	case TokenRevocationStoreRedis:
+		if revocations.client == nil {
+			return nil, fmt.Errorf("The redis token revocation store requires a redis address")
+		}
+		revocations.shared = true
+	default:
+		return nil, fmt.Errorf("Unknown token revocation store %s", revocationConfig.Store)
+	}
+	return revocations, nil
+}
+
+// NewMemoryTokenRevocations creates a token revocation list kept in process only
+func NewMemoryTokenRevocations() *TokenRevocations {
+	return &TokenRevocations{
+		channel:  defaultTokenRevocationChannel,
+		interval: defaultTokenRevocationInterval,
+		revoked:  make(map[string]time.Time),
+	}
+}
+
+// Revoke lists the token until it expires, publishing the revocation to the other instances keeping the list in memory
+func (revocations *TokenRevocations) Revoke(ctx context.Context, tokenID string, expiry time.Time) error {
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return nil
+	}
+	if revocations.shared {
+		if err := revocations.client.Set(ctx, revokedTokenKeyPrefix+tokenID, "1", ttl); err != nil {
+			return fmt.Errorf("Could not revoke token: %v", err)
+		}
+		return nil
+	}
+	revocations.record(tokenID, expiry)
+	if revocations.client == nil {
+		return nil
+	}
+	message, err := json.Marshal(TokenRevocation{TokenID: tokenID, ExpiresAt: expiry.Unix()})
+	if err != nil {
+		return err
+	}
+	if err := revocations.client.Publish(ctx, revocations.channel, string(message)); err != nil {
+		return fmt.Errorf("Could not publish token revocation: %v", err)
+	}
+	return nil
+}
+
+// IsRevoked returns whether the token was revoked
+func (revocations *TokenRevocations) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if revocations.shared {
+		_, err := revocations.client.Get(ctx, revokedTokenKeyPrefix+tokenID)
+		if err == redis.ErrNil {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("Could not check token revocation: %v", err)
+		}
+		return true, nil
+	}
+	revocations.revokedMtx.Lock()
+	defer revocations.revokedMtx.Unlock()
+	expiry, exists := revocations.revoked[tokenID]
+	if !exists {
+		return false, nil
+	}
+	if time.Now().After(expiry) {
+		delete(revocations.revoked, tokenID)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (revocations *TokenRevocations) record(tokenID string, expiry time.Time) {
+	revocations.revokedMtx.Lock()
+	defer revocations.revokedMtx.Unlock()
+	if current, exists := revocations.revoked[tokenID]; !exists || expiry.After(current) {
+		revocations.revoked[tokenID] = expiry
+	}
+}
+
+// receive records a revocation published on the channel, ignoring the malformed ones
+func (revocations *TokenRevocations) receive(channel, message string) {
+	revocation := TokenRevocation{}
+	if err := json.Unmarshal([]byte(message), &revocation); err != nil || revocation.TokenID == "" {
+		return
+	}
+	expiry := time.Unix(revocation.ExpiresAt, 0)
+	if expiry.After(time.Now()) {
+		revocations.record(revocation.TokenID, expiry)
+	}
+}
+
+// Prune drops the revoked tokens that expired
+func (revocations *TokenRevocations) Prune(now time.Time) {
+	revocations.revokedMtx.Lock()
+	defer revocations.revokedMtx.Unlock()
+	for tokenID, expiry := range revocations.revoked {
+		if !expiry.After(now) {
+			delete(revocations.revoked, tokenID)
+		}
+	}
+}
+
+// subscribe keeps a subscription to the revocation channel running, returning why the previous one stopped
+func (revocations *TokenRevocations) subscribe(ctx context.Context) error {
+	if revocations.shared || revocations.client == nil || !revocations.subscribing.CompareAndSwap(false, true) {
+		return nil
+	}
+	revocations.revokedMtx.Lock()
+	err := revocations.subscriptionErr
+	revocations.subscriptionErr = nil
+	revocations.revokedMtx.Unlock()
+
+	go func() {
+		defer revocations.subscribing.Store(false)
+		err := revocations.client.Subscribe(ctx, revocations.receive, revocations.channel)
+		revocations.revokedMtx.Lock()
+		revocations.subscriptionErr = err
+		revocations.revokedMtx.Unlock()
+	}()
+	return err
+}
+
+// Job prunes the expired revocations kept in memory and renews the subscription to the revocation channel when it stopped
+func (revocations *TokenRevocations) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:       "token_revocations",
+		Schedule:   scheduler.Every(revocations.interval),
+		RunOnStart: true,
+		Run: func(ctx context.Context, now time.Time) error {
+			revocations.Prune(now)
+			return revocations.subscribe(ctx)
+		},
+	}
+}
@@ -0,0 +1,194 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// DefaultSignatureHeader is the response header carrying the detached JWS of the body
+const DefaultSignatureHeader = "X-Response-Signature"
+
+// KeysPath is the route publishing the JSON Web Key Set of the response signing key
+const KeysPath = "/.well-known/response-signing-keys.json"
+
+// Header is the protected header of the detached JWS
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Key is the JSON Web Key of the public key verifying the signatures
+type Key struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// Signer signs the response bodies of the configured routes with a detached RS256 JWS as RFC 7515 defines it,
+// so the clients can verify them offline with the published key
+type Signer struct {
+	privateKey *rsa.PrivateKey
+	keyID      string
+	header     string
+	routes     map[string]bool
+}
+
+// NewSigner loads the signing key of the keys directory, generating it when the directory holds none
+func NewSigner(configurations *config.Config) (*Signer, error) {
+	signingConfig := configurations.ResponseSigning
+	if signingConfig.KeysDirectory == "" || signingConfig.KeyID == "" {
+		return nil, fmt.Errorf("The response signing needs a keys directory and a key ID")
+	}
+	keyManager, err := commonJWT.NewKeyManager(signingConfig.KeysDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load the response signing key: %v", err)
+	}
+	signer := &Signer{
+		privateKey: keyManager.GetRSAPrivateKey(),
+		keyID:      signingConfig.KeyID,
+		header:     signingConfig.Header,
+		routes:     make(map[string]bool),
+	}
+	if signer.header == "" {
+		signer.header = DefaultSignatureHeader
+	}
+	for _, route := range signingConfig.Routes {
+		if route.Method == "" || !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("The signed route %s %s needs a method and a path starting with /", route.Method, route.Path)
+		}
+		signer.routes[routeKey(route.Method, route.Path)] = true
+	}
+	return signer, nil
+}
+
+// RegisterRoutes creates the signer and publishes its public key
+func RegisterRoutes(router *gin.Engine, configurations *config.Config) (*Signer, error) {
+	signer, err := NewSigner(configurations)
+	if err != nil {
+		return nil, err
+	}
+	router.GET(KeysPath, signer.Keys)
+	return signer, nil
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// Sign returns the detached JWS of the body, its payload left out as header..signature
+func (signer *Signer) Sign(body []byte) (string, error) {
+	header, err := json.Marshal(Header{Algorithm: jwt.SigningMethodRS256.Alg(), KeyID: signer.keyID})
+	if err != nil {
+		return "", err
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+	signature, err := jwt.SigningMethodRS256.Sign(encodedHeader+"."+base64.RawURLEncoding.EncodeToString(body), signer.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return encodedHeader + ".." + signature, nil
+}
+
+// Verify checks the detached JWS of the body against the public key
+func Verify(detached string, body []byte, publicKey *rsa.PublicKey) error {
+	parts := strings.Split(detached, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return fmt.Errorf("The signature is not a detached JWS")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("The signature header is not base64url encoded: %v", err)
+	}
+	protected := Header{}
+	if err := json.Unmarshal(header, &protected); err != nil {
+		return fmt.Errorf("The signature header is invalid: %v", err)
+	}
+	if protected.Algorithm != jwt.SigningMethodRS256.Alg() {
+		return fmt.Errorf("The signature algorithm %s is not supported", protected.Algorithm)
+	}
+	return jwt.SigningMethodRS256.Verify(parts[0]+"."+base64.RawURLEncoding.EncodeToString(body), parts[2], publicKey)
+}
+
+// PublicKey returns the public key verifying the signatures
+func (signer *Signer) PublicKey() *rsa.PublicKey {
+	return &signer.privateKey.PublicKey
+}
+
+// Keys answers the JSON Web Key Set of the public key verifying the signatures
+func (signer *Signer) Keys(ctx *gin.Context) {
+	publicKey := signer.PublicKey()
+	ctx.Header("Cache-Control", "public, max-age=3600")
+	ctx.JSON(http.StatusOK, gin.H{"keys": []Key{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		KeyID:     signer.keyID,
+		Modulus:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}}})
+}
+
+// Middleware holds back the response of a signed route until it is complete, then answers it with the
+// signature of its body in the signature header
+func (signer *Signer) Middleware(ctx *gin.Context) {
+	if !signer.routes[routeKey(ctx.Request.Method, ctx.FullPath())] {
+		ctx.Next()
+		return
+	}
+	original := ctx.Writer
+	writer := &bufferingWriter{ResponseWriter: original}
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = original
+
+	body := writer.body.Bytes()
+	if len(body) > 0 {
+		signature, err := signer.Sign(body)
+		if err != nil {
+			if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil {
+				logger.Error(err, "Could not sign the response")
+			}
+			original.Header().Del("Content-Length")
+			errors.Abort(ctx, errors.InternalError)
+			return
+		}
+		original.Header().Set(signer.header, signature)
+	}
+	original.WriteHeaderNow()
+	original.Write(body)
+}
+
+// bufferingWriter keeps the status and the body of the response until the signer writes them
+type bufferingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *bufferingWriter) WriteHeaderNow() {}
+
+func (writer *bufferingWriter) Write(data []byte) (int, error) {
+	return writer.body.Write(data)
+}
+
+func (writer *bufferingWriter) WriteString(data string) (int, error) {
+	return writer.body.WriteString(data)
+}
+
+func (writer *bufferingWriter) Flush() {}
@@ -0,0 +1,97 @@
+package signing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	signer, err := NewSigner(&config.Config{ResponseSigning: config.ResponseSigningConfig{
+		Enabled:       true,
+		KeysDirectory: t.TempDir(),
+		KeyID:         "response-signing-1",
+		Routes:        []config.ResponseSigningRouteConfig{{Method: "get", Path: "/licenses/:licenseID"}},
+	}})
+	assert.NoError(t, err)
+	return signer
+}
+
+func TestSigner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Middleware_Should_Sign_The_Body_Of_The_Configured_Routes", func(t *testing.T) {
+		signer := newTestSigner(t)
+		router := gin.New()
+		router.Use(signer.Middleware)
+		router.GET("/licenses/:licenseID", func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{"licenseID": ctx.Param("licenseID"), "seats": 5})
+		})
+		router.GET("/status", func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/licenses/license-1", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"licenseID":"license-1","seats":5}`, w.Body.String())
+		signature := w.Header().Get(DefaultSignatureHeader)
+		assert.NoError(t, Verify(signature, w.Body.Bytes(), signer.PublicKey()))
+		assert.Error(t, Verify(signature, []byte(`{"licenseID":"license-1","seats":50}`), signer.PublicKey()))
+
+		unsigned := httptest.NewRecorder()
+		router.ServeHTTP(unsigned, httptest.NewRequest(http.MethodGet, "/status", nil))
+		assert.Empty(t, unsigned.Header().Get(DefaultSignatureHeader))
+		assert.Equal(t, "ok", unsigned.Body.String())
+	})
+
+	t.Run("Middleware_Should_Keep_The_Status_Of_The_Response", func(t *testing.T) {
+		signer := newTestSigner(t)
+		router := gin.New()
+		router.Use(signer.Middleware)
+		router.GET("/licenses/:licenseID", func(ctx *gin.Context) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"licenseID": ctx.Param("licenseID")})
+		})
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/licenses/license-1", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NoError(t, Verify(w.Header().Get(DefaultSignatureHeader), w.Body.Bytes(), signer.PublicKey()))
+	})
+
+	t.Run("Keys_Should_Publish_The_Public_Key", func(t *testing.T) {
+		signer := newTestSigner(t)
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+
+		signer.Keys(ctx)
+
+		keySet := struct {
+			Keys []Key `json:"keys"`
+		}{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &keySet))
+		assert.Len(t, keySet.Keys, 1)
+		assert.Equal(t, "response-signing-1", keySet.Keys[0].KeyID)
+		modulus, err := base64.RawURLEncoding.DecodeString(keySet.Keys[0].Modulus)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, new(big.Int).SetBytes(modulus).Cmp(signer.PublicKey().N))
+	})
+
+	t.Run("Verify_Should_Reject_An_Attached_Signature", func(t *testing.T) {
+		assert.Error(t, Verify("header.payload.signature", []byte("{}"), newTestSigner(t).PublicKey()))
+	})
+
+	t.Run("NewSigner_Should_Require_A_Key_ID", func(t *testing.T) {
+		_, err := NewSigner(&config.Config{ResponseSigning: config.ResponseSigningConfig{KeysDirectory: t.TempDir()}})
+
+		assert.Error(t, err)
+	})
+}
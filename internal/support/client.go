@@ -0,0 +1,76 @@
+package support
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/support/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_support"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// ServiceClienter is an interface for the support service client
+type ServiceClienter interface {
+	CreateTicket(ctx *gin.Context)
+}
+
+// ServiceClient is a struct for the support service client
+type ServiceClient struct {
+	client       pb_support.SupportServiceClient
+	ticketPolicy routes.TicketPolicy
+}
+
+var _ ServiceClienter = &ServiceClient{}
+
+// InitServiceClient initializes the support service client
+func InitServiceClient(centralConfig *commonConfig.Config, configurations *config.Config) (pb_support.SupportServiceClient, error) {
+	grpcServiceAddress := fmt.Sprintf(
+		"%s:%s",
+		configurations.SupportService.Host,
+		configurations.SupportService.Port,
+	)
+
+	fmt.Println("Connecting to support service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection("support", grpcServiceAddress, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc support service: %v", err)
+	}
+
+	regionalConnection, err := region.RouteConnection("support", clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConnection, err := tenancy.RouteConnection("support", regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	routedConnection, err := versioning.RouteConnection("support", tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	tracedConnection := tracing.TraceConnection("support", routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection("support", tracedConnection, configurations)
+	retryingConnection := resilience.RetryConnection("support", instrumentedConnection, configurations)
+	breakerConnection := resilience.BreakConnection("support", retryingConnection, configurations)
+	hookedConnection := hooks.HookConnection("support", breakerConnection, configurations)
+
+	return pb_support.NewSupportServiceClient(hookedConnection), nil
+}
+
+// CreateTicket redirects request to the create ticket route
+func (service *ServiceClient) CreateTicket(ctx *gin.Context) {
+	routes.CreateTicket(ctx, service.client, service.ticketPolicy)
+}
@@ -0,0 +1,50 @@
+package support
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	"golang.org/x/time/rate"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/support/routes"
+)
+
+// RegisterRoutes registers the support routes
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*ServiceClient, error) {
+	client, err := InitServiceClient(centralConfig, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not initialize support service client: %v", err)
+	}
+	supportConfig := configurations.SupportService
+	service := &ServiceClient{
+		client: client,
+		ticketPolicy: routes.TicketPolicy{
+			MaxAttachments:      supportConfig.MaxAttachments,
+			MaxAttachmentSize:   supportConfig.MaxAttachmentSize,
+			AllowedContentTypes: supportConfig.AllowedAttachmentTypes,
+			SpamThreshold:       supportConfig.SpamThreshold,
+			SpamChecker:         routes.NewSpamChecker(supportConfig.DuplicateWindow),
+		},
+	}
+
+	rl := middleware.NewRateLimiter(rate.Limit(supportConfig.RateLimit), supportConfig.RateLimitBurst)
+
+	supportRoutes := api.Group("/support")
+	supportRoutes.POST(
+		"/tickets",
+		authenticationMiddleware.RequireAuthentication,
+		middleware.UserRateLimitMiddleware(rl),
+		service.CreateTicket,
+	)
+
+	return service, nil
+}
@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/pb/gen/go/pb_support"
+)
+
+const formOverhead = 64 * 1024
+
+// TicketPolicy limits the attachments of a ticket and the spam score it may have
+type TicketPolicy struct {
+	MaxAttachments      int
+	MaxAttachmentSize   int64
+	AllowedContentTypes []string
+	SpamThreshold       float64
+	SpamChecker         *SpamChecker
+}
+
+// CreateTicketRequestBody is the multipart form of the CreateTicket route; website is a honeypot left empty by humans
+type CreateTicketRequestBody struct {
+	Subject  string `form:"subject" binding:"required,max=200"`
+	Message  string `form:"message" binding:"required,max=10000"`
+	Category string `form:"category" binding:"omitempty,oneof=account billing technical feedback other"`
+	Website  string `form:"website"`
+}
+
+// CreateTicket submits a support ticket of the authenticated user with its attachments
+func CreateTicket(ctx *gin.Context, client pb_support.SupportServiceClient, policy TicketPolicy) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	ctx.Request.Body = http.MaxBytesReader(
+		ctx.Writer,
+		ctx.Request.Body,
+		int64(policy.MaxAttachments)*policy.MaxAttachmentSize+formOverhead,
+	)
+	body := CreateTicketRequestBody{}
+	if err := ctx.ShouldBind(&body); err != nil {
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+		return
+	}
+
+	attachments, err := readAttachments(ctx, policy)
+	if err != nil {
+		errors.AbortWithMessage(ctx, errors.InvalidArgument, err.Error())
+		return
+	}
+
+	userID := ""
+	if claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey)); exists {
+		if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+			userID = claims.UserID
+		}
+	}
+	spamScore := policy.SpamChecker.Score(userID, body.Subject, body.Message, body.Website)
+	if spamScore >= policy.SpamThreshold {
+		logger.Warn(fmt.Sprintf("Support ticket rejected as spam with score %.2f", spamScore))
+		errors.Abort(ctx, errors.SpamDetected)
+		return
+	}
+
+	res, err := client.CreateTicket(
+		ctx.Request.Context(),
+		&pb_support.CreateTicketRequest{
+			Subject:     body.Subject,
+			Message:     body.Message,
+			Category:    body.Category,
+			Attachments: attachments,
+			SpamScore:   spamScore,
+		},
+	)
+
+	if err != nil {
+		errors.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, &res)
+}
+
+// readAttachments reads the attachments of the form, sniffing their content type instead of trusting the client
+func readAttachments(ctx *gin.Context, policy TicketPolicy) ([]*pb_support.Attachment, error) {
+	form, err := ctx.MultipartForm()
+	if err != nil || form.File == nil {
+		return nil, nil
+	}
+	files := form.File["attachments"]
+	if len(files) > policy.MaxAttachments {
+		return nil, fmt.Errorf("No more than %d attachments are allowed", policy.MaxAttachments)
+	}
+
+	attachments := make([]*pb_support.Attachment, 0, len(files))
+	for _, fileHeader := range files {
+		if fileHeader.Size > policy.MaxAttachmentSize {
+			return nil, fmt.Errorf("The attachment %s is larger than %d bytes", fileHeader.Filename, policy.MaxAttachmentSize)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the attachment %s", fileHeader.Filename)
+		}
+		data, err := io.ReadAll(io.LimitReader(file, policy.MaxAttachmentSize+1))
+		file.Close()
+		if err != nil || int64(len(data)) > policy.MaxAttachmentSize {
+			return nil, fmt.Errorf("Could not read the attachment %s", fileHeader.Filename)
+		}
+		contentType := strings.Split(http.DetectContentType(data), ";")[0]
+		if !isAllowedContentType(contentType, policy.AllowedContentTypes) {
+			return nil, fmt.Errorf("The attachment %s has a not allowed type %s", fileHeader.Filename, contentType)
+		}
+		attachments = append(attachments, &pb_support.Attachment{
+			Filename:    fileHeader.Filename,
+			ContentType: contentType,
+			Data:        data,
+		})
+	}
+	return attachments, nil
+}
+
+func isAllowedContentType(contentType string, allowedContentTypes []string) bool {
+	for _, allowed := range allowedContentTypes {
+		if strings.EqualFold(contentType, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+
+// SpamChecker scores ticket submissions with simple heuristics and remembers recent messages to catch duplicates
+type SpamChecker struct {
+	duplicateWindow time.Duration
+	recent          map[string]time.Time
+	mtx             sync.Mutex
+}
+
+// NewSpamChecker creates a spam checker flagging repeated messages within the window
+func NewSpamChecker(duplicateWindow time.Duration) *SpamChecker {
+	return &SpamChecker{
+		duplicateWindow: duplicateWindow,
+		recent:          make(map[string]time.Time),
+	}
+}
+
+// Score returns a spam score between 0 and 1 for the submission of the user
+func (checker *SpamChecker) Score(userID, subject, message, honeypot string) float64 {
+	if honeypot != "" {
+		return 1
+	}
+	score := 0.0
+	text := subject + " " + message
+
+	links := len(linkPattern.FindAllString(text, -1))
+	if links > 3 {
+		score += 0.4
+	} else if links > 0 {
+		score += 0.1 * float64(links)
+	}
+	if uppercaseRatio(linkPattern.ReplaceAllString(text, "")) > 0.7 {
+		score += 0.2
+	}
+	if hasLongRepetition(text, 10) {
+		score += 0.2
+	}
+	if checker.isDuplicate(userID, message, time.Now()) {
+		score += 0.5
+	}
+	if score > 1 {
+		score = 1
+	}
+	return score
+}
+
+func (checker *SpamChecker) isDuplicate(userID, message string, now time.Time) bool {
+	sum := sha256.Sum256([]byte(userID + "\x00" + strings.ToLower(strings.TrimSpace(message))))
+	key := hex.EncodeToString(sum[:])
+
+	checker.mtx.Lock()
+	defer checker.mtx.Unlock()
+	for recentKey, seenAt := range checker.recent {
+		if now.Sub(seenAt) > checker.duplicateWindow {
+			delete(checker.recent, recentKey)
+		}
+	}
+	_, duplicate := checker.recent[key]
+	checker.recent[key] = now
+	return duplicate
+}
+
+func uppercaseRatio(text string) float64 {
+	letters, uppercase := 0, 0
+	for _, character := range text {
+		if unicode.IsLetter(character) {
+			letters++
+			if unicode.IsUpper(character) {
+				uppercase++
+			}
+		}
+	}
+	if letters < 20 {
+		return 0
+	}
+	return float64(uppercase) / float64(letters)
+}
+
+func hasLongRepetition(text string, length int) bool {
+	repeated := 0
+	var previous rune
+	for _, character := range text {
+		if character == previous {
+			repeated++
+			if repeated >= length {
+				return true
+			}
+		} else {
+			repeated = 1
+			previous = character
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpamChecker(t *testing.T) {
+	t.Run("Score_Regular_Message_Should_Be_Low", func(t *testing.T) {
+		checker := NewSpamChecker(time.Hour)
+
+		score := checker.Score("user-id", "Cannot log in", "I reset my password but the login still fails.", "")
+
+		assert.Equal(t, 0.0, score)
+	})
+
+	t.Run("Score_Honeypot_Should_Be_Spam", func(t *testing.T) {
+		checker := NewSpamChecker(time.Hour)
+
+		score := checker.Score("user-id", "Hello", "Hello there", "https://example.com")
+
+		assert.Equal(t, 1.0, score)
+	})
+
+	t.Run("Score_Many_Links_And_Shouting_Should_Be_High", func(t *testing.T) {
+		checker := NewSpamChecker(time.Hour)
+		message := "BUY NOW CHEAP OFFER http://a.example http://b.example http://c.example http://d.example"
+
+		score := checker.Score("user-id", "BEST DEALS EVER", message, "")
+
+		assert.GreaterOrEqual(t, score, 0.6)
+	})
+
+	t.Run("Score_Duplicate_Message_Should_Increase", func(t *testing.T) {
+		checker := NewSpamChecker(time.Hour)
+
+		first := checker.Score("user-id", "Help", "The app crashes on start", "")
+		second := checker.Score("user-id", "Help", "  The app crashes on START ", "")
+		otherUser := checker.Score("other-user-id", "Help", "The app crashes on start", "")
+
+		assert.Equal(t, 0.0, first)
+		assert.Equal(t, 0.5, second)
+		assert.Equal(t, 0.0, otherUser)
+	})
+
+	t.Run("Score_Long_Repetition_Should_Increase", func(t *testing.T) {
+		checker := NewSpamChecker(time.Hour)
+
+		score := checker.Score("user-id", "Help", "please "+strings.Repeat("!", 20), "")
+
+		assert.Equal(t, 0.2, score)
+	})
+}
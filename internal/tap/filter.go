@@ -0,0 +1,142 @@
+package tap
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Fields that can be used in the filter expressions
+const (
+	FieldRoute  = "route"
+	FieldPath   = "path"
+	FieldMethod = "method"
+	FieldUser   = "user"
+	FieldStatus = "status"
+)
+
+type term struct {
+	field    string
+	operator string
+	value    string
+	status   int
+}
+
+// Filter selects the events matching every term of an expression like "route=/api/v1/search*,status>=500"
+type Filter struct {
+	terms []term
+}
+
+// ParseFilter parses a comma separated list of terms, strings support = and != with * wildcards
+// and the status supports =, !=, <, <=, > and >=, or a class like 5xx
+func ParseFilter(expression string) (*Filter, error) {
+	filter := &Filter{}
+	if strings.TrimSpace(expression) == "" {
+		return filter, nil
+	}
+	for _, part := range strings.Split(expression, ",") {
+		part = strings.TrimSpace(part)
+		index := strings.IndexAny(part, "=!<>")
+		if index <= 0 {
+			return nil, fmt.Errorf("Invalid filter term: %s", part)
+		}
+		field := strings.ToLower(strings.TrimSpace(part[:index]))
+		operator := ""
+		for _, candidate := range []string{">=", "<=", "!=", "=", ">", "<"} {
+			if strings.HasPrefix(part[index:], candidate) {
+				operator = candidate
+				break
+			}
+		}
+		if operator == "" {
+			return nil, fmt.Errorf("Invalid filter operator: %s", part)
+		}
+		value := strings.TrimSpace(part[index+len(operator):])
+		parsed := term{field: field, operator: operator, value: value}
+		switch field {
+		case FieldRoute, FieldPath, FieldMethod, FieldUser:
+			if operator != "=" && operator != "!=" {
+				return nil, fmt.Errorf("Invalid filter operator for %s: %s", field, operator)
+			}
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("Invalid filter pattern %s: %v", value, err)
+			}
+		case FieldStatus:
+			if isStatusClass(value) {
+				if operator != "=" && operator != "!=" {
+					return nil, fmt.Errorf("Invalid filter operator for a status class: %s", operator)
+				}
+				break
+			}
+			status, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid filter status: %s", value)
+			}
+			parsed.status = status
+		default:
+			return nil, fmt.Errorf("Unknown filter field: %s", field)
+		}
+		filter.terms = append(filter.terms, parsed)
+	}
+	return filter, nil
+}
+
+// Matches tells whether the event satisfies every term of the filter
+func (filter *Filter) Matches(event *Event) bool {
+	for _, term := range filter.terms {
+		if !term.matches(event) {
+			return false
+		}
+	}
+	return true
+}
+
+func (term term) matches(event *Event) bool {
+	switch term.field {
+	case FieldRoute:
+		return term.matchesString(event.Route)
+	case FieldPath:
+		return term.matchesString(event.Path)
+	case FieldMethod:
+		return term.matchesString(strings.ToUpper(event.Method))
+	case FieldUser:
+		return term.matchesString(event.UserID)
+	}
+	if isStatusClass(term.value) {
+		matches := event.Status/100 == int(term.value[0]-'0')
+		return matches == (term.operator == "=")
+	}
+	switch term.operator {
+	case ">=":
+		return event.Status >= term.status
+	case "<=":
+		return event.Status <= term.status
+	case ">":
+		return event.Status > term.status
+	case "<":
+		return event.Status < term.status
+	case "!=":
+		return event.Status != term.status
+	}
+	return event.Status == term.status
+}
+
+func (term term) matchesString(value string) bool {
+	pattern := term.value
+	if term.field == FieldMethod {
+		pattern = strings.ToUpper(pattern)
+	}
+	// path.Match does not let * cross slashes, a trailing * matches the rest of the value
+	matches := false
+	if strings.HasSuffix(pattern, "*") && !strings.ContainsAny(pattern[:len(pattern)-1], "*?[") {
+		matches = strings.HasPrefix(value, pattern[:len(pattern)-1])
+	} else {
+		matches, _ = path.Match(pattern, value)
+	}
+	return matches == (term.operator == "=")
+}
+
+func isStatusClass(value string) bool {
+	return len(value) == 3 && value[0] >= '1' && value[0] <= '5' && strings.ToLower(value[1:]) == "xx"
+}
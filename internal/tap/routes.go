@@ -0,0 +1,35 @@
+package tap
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/admin"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+)
+
+// RegisterRoutes registers the admin traffic stream, audited before the role check like the other admin routes
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	tap *Tap,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) error {
+	eventPublisher, err := events.NewPublisher(configurations)
+	if err != nil {
+		return fmt.Errorf("Failed to initiate event publisher: %v", err)
+	}
+	auditor := admin.NewAuditor(eventPublisher)
+
+	api.GET(
+		"/admin/traffic",
+		authenticationMiddleware.RequireAuthentication,
+		auditor.Record("traffic.tap"),
+		authenticationMiddleware.RequireRole(configurations.Admin.Roles...),
+		tap.Stream,
+	)
+	return nil
+}
@@ -0,0 +1,186 @@
+package tap
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+)
+
+const (
+	defaultKeepAlive   = 15 * time.Second
+	defaultMaxDuration = 30 * time.Minute
+)
+
+// Event is the sanitized metadata of a request, it never carries headers, query strings or bodies
+type Event struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Method        string    `json:"method"`
+	Route         string    `json:"route"`
+	Path          string    `json:"path"`
+	Status        int       `json:"status"`
+	LatencyMs     int64     `json:"latencyMs"`
+	UserID        string    `json:"userID,omitempty"`
+	ClientVersion string    `json:"clientVersion,omitempty"`
+	CorrelationID string    `json:"correlationID,omitempty"`
+}
+
+type subscriber struct {
+	events  chan *Event
+	filter  *Filter
+	dropped int64
+}
+
+// Tap fans the metadata of the live traffic out to the subscribed admins
+type Tap struct {
+	subscribers     map[*subscriber]bool
+	subscriberCount int32
+	maxSubscribers  int
+	bufferSize      int
+	keepAlive       time.Duration
+	maxDuration     time.Duration
+	mtx             sync.RWMutex
+}
+
+// NewTap creates the traffic tap of the configuration, the keep alive and the max duration of the streams
+// default when not set
+func NewTap(configurations *config.Config) *Tap {
+	tap := &Tap{
+		subscribers:    make(map[*subscriber]bool),
+		maxSubscribers: configurations.TrafficTap.MaxSubscribers,
+		bufferSize:     configurations.TrafficTap.BufferSize,
+		keepAlive:      configurations.TrafficTap.KeepAlive,
+		maxDuration:    configurations.TrafficTap.MaxDuration,
+	}
+	if tap.keepAlive <= 0 {
+		tap.keepAlive = defaultKeepAlive
+	}
+	if tap.maxDuration <= 0 {
+		tap.maxDuration = defaultMaxDuration
+	}
+	return tap
+}
+
+// Middleware publishes the metadata of every request once it is answered, only while someone is listening
+func (tap *Tap) Middleware(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+	if atomic.LoadInt32(&tap.subscriberCount) == 0 {
+		return
+	}
+	event := &Event{
+		Timestamp: start.UTC(),
+		Method:    ctx.Request.Method,
+		Route:     ctx.FullPath(),
+		Path:      ctx.Request.URL.Path,
+		Status:    ctx.Writer.Status(),
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if claimsValue, exists := ctx.Get(string(commonJWT.ClaimsContextKey)); exists {
+		if claims, ok := claimsValue.(*commonJWT.TokenClaims); ok {
+			event.UserID = claims.UserID
+		}
+	}
+	if version := versioning.VersionFromContext(ctx.Request.Context()); version != nil {
+		event.ClientVersion = version.String()
+	}
+	if correlationID, err := commonLogger.GetCorrelationIDFromContext(ctx.Request.Context()); err == nil {
+		event.CorrelationID = *correlationID
+	}
+	tap.Publish(event)
+}
+
+// Publish sends the event to the matching subscribers, dropping it for the ones falling behind
+func (tap *Tap) Publish(event *Event) {
+	tap.mtx.RLock()
+	defer tap.mtx.RUnlock()
+	for subscriber := range tap.subscribers {
+		if !subscriber.filter.Matches(event) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			atomic.AddInt64(&subscriber.dropped, 1)
+		}
+	}
+}
+
+func (tap *Tap) subscribe(filter *Filter) (*subscriber, bool) {
+	tap.mtx.Lock()
+	defer tap.mtx.Unlock()
+	if tap.maxSubscribers > 0 && len(tap.subscribers) >= tap.maxSubscribers {
+		return nil, false
+	}
+	subscriber := &subscriber{events: make(chan *Event, tap.bufferSize), filter: filter}
+	tap.subscribers[subscriber] = true
+	atomic.StoreInt32(&tap.subscriberCount, int32(len(tap.subscribers)))
+	return subscriber, true
+}
+
+func (tap *Tap) unsubscribe(subscriber *subscriber) {
+	tap.mtx.Lock()
+	defer tap.mtx.Unlock()
+	delete(tap.subscribers, subscriber)
+	atomic.StoreInt32(&tap.subscriberCount, int32(len(tap.subscribers)))
+}
+
+// Stream sends the matching traffic as server-sent events until the client leaves or the session expires
+func (tap *Tap) Stream(ctx *gin.Context) {
+	logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+	if err != nil {
+		errors.AbortWithError(ctx, errors.InternalError, err)
+		return
+	}
+	filter, err := ParseFilter(ctx.Query("filter"))
+	if err != nil {
+		logger.Error(err, "Invalid traffic tap filter")
+		errors.Abort(ctx, errors.InvalidArgument, errors.FieldViolation("filter", err.Error()))
+		return
+	}
+	subscriber, subscribed := tap.subscribe(filter)
+	if !subscribed {
+		logger.Warn("The traffic tap reached the maximum number of subscribers")
+		errors.Abort(ctx, errors.ServiceUnavailable)
+		return
+	}
+	defer tap.unsubscribe(subscriber)
+	logger.Info(fmt.Sprintf("Traffic tap opened with filter %q", ctx.Query("filter")))
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	keepAlive := time.NewTicker(tap.keepAlive)
+	defer keepAlive.Stop()
+	expiry := time.NewTimer(tap.maxDuration)
+	defer expiry.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-expiry.C:
+			ctx.SSEvent("expired", gin.H{"dropped": atomic.LoadInt64(&subscriber.dropped)})
+			ctx.Writer.Flush()
+			return
+		case <-keepAlive.C:
+			ctx.SSEvent("keepalive", gin.H{"dropped": atomic.LoadInt64(&subscriber.dropped)})
+			ctx.Writer.Flush()
+		case event := <-subscriber.events:
+			ctx.SSEvent("request", event)
+			ctx.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package tap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func TestFilter(t *testing.T) {
+	event := &Event{Method: "GET", Route: "/api/v1/search", Path: "/api/v1/search", Status: 503, UserID: "user-1"}
+
+	t.Run("Matches_Should_Require_Every_Term", func(t *testing.T) {
+		filter, err := ParseFilter("route=/api/v1/*, status>=500, user=user-1, method=get")
+
+		assert.NoError(t, err)
+		assert.True(t, filter.Matches(event))
+		assert.False(t, filter.Matches(&Event{Method: "GET", Route: "/api/v1/search", Status: 200, UserID: "user-1"}))
+	})
+
+	t.Run("Matches_Should_Support_Status_Classes_And_Negation", func(t *testing.T) {
+		filter, err := ParseFilter("status=5xx,user!=user-2")
+
+		assert.NoError(t, err)
+		assert.True(t, filter.Matches(event))
+		assert.False(t, filter.Matches(&Event{Status: 404}))
+	})
+
+	t.Run("Empty_Filter_Should_Match_Everything", func(t *testing.T) {
+		filter, err := ParseFilter("")
+
+		assert.NoError(t, err)
+		assert.True(t, filter.Matches(event))
+	})
+
+	t.Run("ParseFilter_Should_Reject_Invalid_Terms", func(t *testing.T) {
+		for _, expression := range []string{"route", "body=secret", "route>=/a", "status=abc", "status>=5xx"} {
+			_, err := ParseFilter(expression)
+			assert.Error(t, err, expression)
+		}
+	})
+}
+
+func TestTap(t *testing.T) {
+	t.Run("Stream_Should_Send_Matching_Requests_Until_Expiry", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		loggerMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+		tap := NewTap(&config.Config{
+			TrafficTap: config.TrafficTapConfig{BufferSize: 10, KeepAlive: time.Hour, MaxDuration: 200 * time.Millisecond},
+		})
+		router := gin.New()
+		router.Use(func(ctx *gin.Context) {
+			ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+		}, tap.Middleware)
+		router.GET("/traffic", tap.Stream)
+		router.GET("/ok", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		router.GET("/fail", func(ctx *gin.Context) { ctx.Status(http.StatusBadGateway) })
+
+		stream := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			router.ServeHTTP(stream, httptest.NewRequest(http.MethodGet, "/traffic?filter=status%3E%3D500", nil))
+			close(done)
+		}()
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&tap.subscriberCount) == 1
+		}, time.Second, 5*time.Millisecond)
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail?token=secret", nil))
+		<-done
+
+		body := stream.Body.String()
+		assert.Equal(t, "text/event-stream", stream.Header().Get("Content-Type"))
+		assert.Contains(t, body, "event:request")
+		assert.Contains(t, body, `"path":"/fail"`)
+		assert.NotContains(t, body, "secret")
+		assert.NotContains(t, body, `"path":"/ok"`)
+		assert.Contains(t, body, "event:expired")
+		assert.Equal(t, int32(0), atomic.LoadInt32(&tap.subscriberCount))
+	})
+
+	t.Run("Stream_Should_Reject_Subscribers_Over_The_Limit", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		loggerMock.EXPECT().Warn(gomock.Any())
+
+		tap := NewTap(&config.Config{TrafficTap: config.TrafficTapConfig{MaxSubscribers: 1}})
+		_, subscribed := tap.subscribe(&Filter{})
+		assert.True(t, subscribed)
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/traffic", nil)
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+
+		tap.Stream(ctx)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("NewTap_Should_Default_The_Keep_Alive_And_The_Max_Duration", func(t *testing.T) {
+		tap := NewTap(&config.Config{})
+
+		assert.Equal(t, defaultKeepAlive, tap.keepAlive)
+		assert.Equal(t, defaultMaxDuration, tap.maxDuration)
+	})
+}
@@ -0,0 +1,73 @@
+package tenancy
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/unixsocket"
+)
+
+// TenantConnection calls the dedicated upstream of the tenant of the request, or the shared one for the
+// tenants without a dedicated upstream
+type TenantConnection struct {
+	shared  grpc.ClientConnInterface
+	tenants map[string]grpc.ClientConnInterface
+}
+
+var _ grpc.ClientConnInterface = &TenantConnection{}
+
+func (connection *TenantConnection) target(ctx context.Context) grpc.ClientConnInterface {
+	if tenantConnection, exists := connection.tenants[FromContext(ctx)]; exists {
+		return tenantConnection
+	}
+	return connection.shared
+}
+
+// Invoke calls the upstream of the tenant of the request
+func (connection *TenantConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	return connection.target(ctx).Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream opens the stream with the upstream of the tenant of the request
+func (connection *TenantConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return connection.target(ctx).NewStream(ctx, desc, method, opts...)
+}
+
+// RouteConnection wraps the connection of the service with the dedicated upstreams the tenants configure for it
+func RouteConnection(
+	service string,
+	connection grpc.ClientConnInterface,
+	configurations *config.Config,
+	tlsEnabled bool,
+) (grpc.ClientConnInterface, error) {
+	if !configurations.Tenancy.Enabled {
+		return connection, nil
+	}
+	tenants := make(map[string]grpc.ClientConnInterface)
+	for _, tenant := range configurations.Tenancy.Tenants {
+		for _, upstream := range tenant.Upstreams {
+			if upstream.Service != service {
+				continue
+			}
+			address := fmt.Sprintf("%s:%s", upstream.Host, upstream.Port)
+			upstreamTLSEnabled := tlsEnabled
+			if upstream.Socket != "" {
+				address, upstreamTLSEnabled = unixsocket.Target(upstream.Socket), false
+			}
+			fmt.Println("Adding", service, "endpoint", address, "of tenant", tenant.ID)
+			tenantConnection, err := egress.CreateGRPCConnection(address, upstreamTLSEnabled)
+			if err != nil {
+				return nil, fmt.Errorf("Could not connect to %s endpoint of tenant %s: %v", service, tenant.ID, err)
+			}
+			tenants[tenant.ID] = tenantConnection
+		}
+	}
+	if len(tenants) == 0 {
+		return connection, nil
+	}
+	return &TenantConnection{shared: connection, tenants: tenants}, nil
+}
@@ -0,0 +1,90 @@
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// DefaultClaim is the access token claim carrying the tenant of the user when no claim is configured
+const DefaultClaim = "tenant_id"
+
+// MetadataKey is the gRPC metadata key forwarding the tenant of the request to the backends
+const MetadataKey = "tenant_id"
+
+// ContextKey is the gin context key of the tenant of the request
+const ContextKey = "tenantID"
+
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a context of the tenant, forwarding it in the outgoing metadata of the upstream calls
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	outgoingMD, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		outgoingMD = metadata.New(map[string]string{})
+	}
+	newOutgoingMD := outgoingMD.Copy()
+	newOutgoingMD.Set(MetadataKey, tenantID)
+	return context.WithValue(metadata.NewOutgoingContext(ctx, newOutgoingMD), tenantContextKey{}, tenantID)
+}
+
+// FromContext returns the tenant of the context or an empty string when the request has none
+func FromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenantID
+}
+
+// Claim returns the access token claim carrying the tenant
+func Claim(configurations *config.Config) string {
+	if configurations.Tenancy.Claim != "" {
+		return configurations.Tenancy.Claim
+	}
+	return DefaultClaim
+}
+
+// Overrides applies the route overrides of the tenants to their authenticated requests
+type Overrides struct {
+	disabledRoutes map[string]map[string]bool
+}
+
+// NewOverrides creates the route overrides of the configured tenants
+func NewOverrides(configurations *config.Config) (*Overrides, error) {
+	overrides := &Overrides{disabledRoutes: make(map[string]map[string]bool)}
+	for _, tenant := range configurations.Tenancy.Tenants {
+		if tenant.ID == "" {
+			return nil, fmt.Errorf("Every tenant requires an ID")
+		}
+		if _, exists := overrides.disabledRoutes[tenant.ID]; exists {
+			return nil, fmt.Errorf("The tenant %s is defined twice", tenant.ID)
+		}
+		routes := make(map[string]bool)
+		for _, route := range tenant.DisabledRoutes {
+			if route.Method == "" || !strings.HasPrefix(route.Path, "/") {
+				return nil, fmt.Errorf("The disabled route %s %s of the tenant %s needs a method and a path starting with /", route.Method, route.Path, tenant.ID)
+			}
+			routes[routeKey(route.Method, route.Path)] = true
+		}
+		overrides.disabledRoutes[tenant.ID] = routes
+	}
+	return overrides, nil
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// OnAuthenticated answers the routes disabled for the tenant of the request as missing
+func (overrides *Overrides) OnAuthenticated(ctx *gin.Context, claims *commonJWT.TokenClaims) {
+	tenantID := FromContext(ctx.Request.Context())
+	if tenantID == "" || !overrides.disabledRoutes[tenantID][routeKey(ctx.Request.Method, ctx.FullPath())] {
+		return
+	}
+	errors.Abort(ctx, errors.RouteNotFound)
+}
@@ -0,0 +1,105 @@
+package tenancy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type fakeConnection struct {
+	name    string
+	invoked *[]string
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	*connection.invoked = append(*connection.invoked, connection.name)
+	return nil
+}
+
+func (connection *fakeConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	*connection.invoked = append(*connection.invoked, connection.name)
+	return nil, nil
+}
+
+func TestTenancy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("ContextWithTenant_Should_Forward_The_Tenant_In_The_Outgoing_Metadata", func(t *testing.T) {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer token"))
+
+		ctx = ContextWithTenant(ctx, "acme")
+
+		outgoingMD, _ := metadata.FromOutgoingContext(ctx)
+		assert.Equal(t, "acme", FromContext(ctx))
+		assert.Equal(t, []string{"acme"}, outgoingMD.Get(MetadataKey))
+		assert.Equal(t, []string{"Bearer token"}, outgoingMD.Get("authorization"))
+	})
+
+	t.Run("OnAuthenticated_Should_Hide_The_Routes_Disabled_For_The_Tenant", func(t *testing.T) {
+		overrides, err := NewOverrides(&config.Config{Tenancy: config.TenancyConfig{Tenants: []config.TenantConfig{{
+			ID:             "acme",
+			DisabledRoutes: []config.TenantRouteConfig{{Method: "post", Path: "/media"}},
+		}}}})
+		assert.NoError(t, err)
+		router := gin.New()
+		router.POST("/media", func(ctx *gin.Context) {
+			tenantID := ctx.GetHeader("X-Tenant")
+			if tenantID != "" {
+				ctx.Request = ctx.Request.WithContext(ContextWithTenant(ctx.Request.Context(), tenantID))
+			}
+			overrides.OnAuthenticated(ctx, &commonJWT.TokenClaims{UserID: "user-id"})
+		}, func(ctx *gin.Context) { ctx.Status(http.StatusCreated) })
+		serve := func(tenantID string) int {
+			request := httptest.NewRequest(http.MethodPost, "/media", nil)
+			request.Header.Set("X-Tenant", tenantID)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			return recorder.Code
+		}
+
+		assert.Equal(t, http.StatusNotFound, serve("acme"))
+		assert.Equal(t, http.StatusCreated, serve("globex"))
+		assert.Equal(t, http.StatusCreated, serve(""))
+	})
+
+	t.Run("NewOverrides_Should_Reject_Tenants_Defined_Twice", func(t *testing.T) {
+		_, err := NewOverrides(&config.Config{Tenancy: config.TenancyConfig{Tenants: []config.TenantConfig{{ID: "acme"}, {ID: "acme"}}}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Invoke_Should_Call_The_Upstream_Of_The_Tenant", func(t *testing.T) {
+		invoked := []string{}
+		connection := &TenantConnection{
+			shared:  &fakeConnection{name: "shared", invoked: &invoked},
+			tenants: map[string]grpc.ClientConnInterface{"acme": &fakeConnection{name: "acme", invoked: &invoked}},
+		}
+
+		assert.NoError(t, connection.Invoke(ContextWithTenant(context.Background(), "acme"), "/method", nil, nil))
+		assert.NoError(t, connection.Invoke(ContextWithTenant(context.Background(), "globex"), "/method", nil, nil))
+		assert.NoError(t, connection.Invoke(context.Background(), "/method", nil, nil))
+
+		assert.Equal(t, []string{"acme", "shared", "shared"}, invoked)
+	})
+
+	t.Run("RouteConnection_Should_Keep_The_Connection_Without_Tenant_Upstreams", func(t *testing.T) {
+		shared := &fakeConnection{name: "shared"}
+
+		connection, err := RouteConnection("payment", shared, &config.Config{Tenancy: config.TenancyConfig{
+			Enabled: true,
+			Tenants: []config.TenantConfig{{ID: "acme", Upstreams: []config.TenantUpstreamConfig{{Service: "media", Host: "localhost", Port: "9095"}}}},
+		}}, false)
+
+		assert.NoError(t, err)
+		assert.Same(t, shared, connection)
+	})
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// TracingConnection starts a client span per call to a service and propagates it in the traceparent metadata,
+// replacing the traceparent set by the mesh propagator so the upstream spans are children of the call
+type TracingConnection struct {
+	service    string
+	connection grpc.ClientConnInterface
+}
+
+var _ grpc.ClientConnInterface = &TracingConnection{}
+
+// Invoke calls the service within a client span of the tracer in use
+func (connection *TracingConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	tracer := Current()
+	if tracer == nil {
+		return connection.connection.Invoke(ctx, method, args, reply, opts...)
+	}
+	ctx, span := connection.start(ctx, tracer, method)
+	err := connection.connection.Invoke(ctx, method, args, reply, opts...)
+	connection.end(span, err)
+	return err
+}
+
+// NewStream opens the stream with the service, the span only covers the opening of the stream
+func (connection *TracingConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	tracer := Current()
+	if tracer == nil {
+		return connection.connection.NewStream(ctx, desc, method, opts...)
+	}
+	ctx, span := connection.start(ctx, tracer, method)
+	stream, err := connection.connection.NewStream(ctx, desc, method, opts...)
+	connection.end(span, err)
+	return stream, err
+}
+
+func (connection *TracingConnection) start(ctx context.Context, tracer *Tracer, method string) (context.Context, *Span) {
+	ctx, span := tracer.Start(ctx, method, SpanKindClient, SpanContext{})
+	span.SetAttribute("rpc.system", "grpc")
+	span.SetAttribute("rpc.service", connection.service)
+	span.SetAttribute("rpc.method", method)
+	outgoingMD, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		outgoingMD = metadata.New(map[string]string{})
+	}
+	newMD := outgoingMD.Copy()
+	newMD.Set(TraceparentHeader, span.Context.Traceparent())
+	return metadata.NewOutgoingContext(ctx, newMD), span
+}
+
+func (connection *TracingConnection) end(span *Span, err error) {
+	code := status.Code(err)
+	span.SetAttribute("rpc.grpc.status_code", code.String())
+	if err != nil {
+		span.SetError(err.Error())
+	}
+	span.End()
+}
+
+// TraceConnection traces the calls to the service when the tracing is enabled
+func TraceConnection(service string, connection grpc.ClientConnInterface, configurations *config.Config) grpc.ClientConnInterface {
+	if !configurations.Tracing.Enabled {
+		return connection
+	}
+	return &TracingConnection{service: service, connection: connection}
+}
@@ -0,0 +1,171 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// instrumentationScope names the tracer in the exported spans
+const instrumentationScope = "github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+
+// OTLP status codes of the spans
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// Flush exports the queued spans to the collector, the spans of a failed export are dropped
+func (tracer *Tracer) Flush(ctx context.Context, now time.Time) error {
+	spans, dropped := tracer.drain()
+	if dropped > 0 {
+		tracer.logger.Warn(fmt.Sprintf("Dropped %d spans, the tracing queue was full", dropped))
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(tracer.exportRequest(spans))
+	if err != nil {
+		return fmt.Errorf("Error encoding the spans: %v", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, tracer.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Error creating the span export request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range tracer.headers {
+		request.Header.Set(name, value)
+	}
+	response, err := tracer.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Error exporting %d spans: %v", len(spans), err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("Error exporting %d spans: collector answered %d", len(spans), response.StatusCode)
+	}
+	return nil
+}
+
+func (tracer *Tracer) exportRequest(spans []*Span) otlpRequest {
+	exported := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		exported = append(exported, exportSpan(span))
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: exportAttributes(map[string]string{"service.name": tracer.serviceName})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: instrumentationScope},
+			Spans: exported,
+		}},
+	}}}
+}
+
+func exportSpan(span *Span) otlpSpan {
+	span.mtx.Lock()
+	defer span.mtx.Unlock()
+	exported := otlpSpan{
+		TraceID:           span.Context.TraceID.String(),
+		SpanID:            span.Context.SpanID.String(),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: unixNano(span.StartTime),
+		EndTimeUnixNano:   unixNano(span.EndTime),
+		Attributes:        exportAttributes(span.Attributes),
+		Status:            otlpStatus{Code: statusUnset},
+	}
+	if span.ParentSpanID != (SpanID{}) {
+		exported.ParentSpanID = span.ParentSpanID.String()
+	}
+	for _, event := range span.Events {
+		exported.Events = append(exported.Events, otlpEvent{
+			TimeUnixNano: unixNano(event.Time),
+			Name:         event.Name,
+			Attributes:   exportAttributes(event.Attributes),
+		})
+	}
+	if span.Failed {
+		exported.Status = otlpStatus{Code: statusError, Message: span.StatusMessage}
+	}
+	return exported
+}
+
+// exportAttributes lists the attributes sorted by key so the payloads are stable
+func exportAttributes(attributes map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	exported := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		exported = append(exported, otlpAttribute{Key: key, Value: otlpValue{StringValue: attributes[key]}})
+	}
+	return exported
+}
+
+func unixNano(moment time.Time) string {
+	return strconv.FormatInt(moment.UnixNano(), 10)
+}
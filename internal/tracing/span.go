@@ -0,0 +1,174 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader carries the W3C trace context of a request or a gRPC call
+const TraceparentHeader = "traceparent"
+
+// Kinds of the spans, numbered as in OTLP
+const (
+	SpanKindServer = 2
+	SpanKindClient = 3
+)
+
+// TraceID identifies a trace across the services
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the lowercase hex form of the trace id
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// String returns the lowercase hex form of the span id
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span propagated to the other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid tells whether the ids are set, the W3C trace context forbids all zero ids
+func (spanContext SpanContext) IsValid() bool {
+	return spanContext.TraceID != TraceID{} && spanContext.SpanID != SpanID{}
+}
+
+// Traceparent returns the traceparent header of the span context
+func (spanContext SpanContext) Traceparent() string {
+	flags := "00"
+	if spanContext.Sampled {
+		flags = "01"
+	}
+	return "00-" + spanContext.TraceID.String() + "-" + spanContext.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header, the later versions are read as version 00 as the standard asks
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	spanContext := SpanContext{}
+	if _, err := hex.Decode(spanContext.TraceID[:], []byte(parts[1])); err != nil || parts[1] != strings.ToLower(parts[1]) {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(spanContext.SpanID[:], []byte(parts[2])); err != nil || parts[2] != strings.ToLower(parts[2]) {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || !spanContext.IsValid() {
+		return SpanContext{}, false
+	}
+	spanContext.Sampled = flags[0]&1 == 1
+	return spanContext, true
+}
+
+// Event is a timestamped step recorded in a span
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]string
+}
+
+// Span is a timed operation of a trace
+type Span struct {
+	Name          string
+	Kind          int
+	Context       SpanContext
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    map[string]string
+	Events        []Event
+	Failed        bool
+	StatusMessage string
+	tracer        *Tracer
+	mtx           sync.Mutex
+}
+
+// SetAttribute sets an attribute of the span
+func (span *Span) SetAttribute(key, value string) {
+	span.mtx.Lock()
+	defer span.mtx.Unlock()
+	span.Attributes[key] = value
+}
+
+// AddEvent records a step of the span, the attributes are given as key and value pairs
+func (span *Span) AddEvent(name string, attributes ...string) {
+	event := Event{Name: name, Time: time.Now(), Attributes: make(map[string]string, len(attributes)/2)}
+	for index := 0; index+1 < len(attributes); index += 2 {
+		event.Attributes[attributes[index]] = attributes[index+1]
+	}
+	span.mtx.Lock()
+	defer span.mtx.Unlock()
+	span.Events = append(span.Events, event)
+}
+
+// SetError marks the span as failed
+func (span *Span) SetError(message string) {
+	span.mtx.Lock()
+	defer span.mtx.Unlock()
+	span.Failed, span.StatusMessage = true, message
+}
+
+// End ends the span and queues it for the export when it is sampled
+func (span *Span) End() {
+	span.mtx.Lock()
+	span.EndTime = time.Now()
+	span.mtx.Unlock()
+	if span.Context.Sampled && span.tracer != nil {
+		span.tracer.enqueue(span)
+	}
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a context carrying the span, the spans started from it are its children
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the span of the context, nil when there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// AddEvent records a step in the span of the context, if any
+func AddEvent(ctx context.Context, name string, attributes ...string) {
+	if span := SpanFromContext(ctx); span != nil {
+		span.AddEvent(name, attributes...)
+	}
+}
+
+func newTraceID() TraceID {
+	id := TraceID{}
+	for id == (TraceID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	id := SpanID{}
+	for id == (SpanID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
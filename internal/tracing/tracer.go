@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Defaults of the tracing configuration
+const (
+	DefaultServiceName   = "qd-qpi-gateway"
+	DefaultFlushInterval = 5 * time.Second
+	DefaultMaxQueueSize  = 2048
+)
+
+// defaultEndpoints are the OTLP/HTTP trace endpoints of the exporters, Jaeger ingests OTLP natively
+var defaultEndpoints = map[string]string{
+	"otlp":   "http://localhost:4318/v1/traces",
+	"jaeger": "http://localhost:4318/v1/traces",
+}
+
+// Tracer starts the spans and queues the sampled ones until they are exported
+type Tracer struct {
+	serviceName   string
+	endpoint      string
+	headers       map[string]string
+	sampleRatio   float64
+	flushInterval time.Duration
+	maxQueueSize  int
+	client        *http.Client
+	logger        commonLogger.Loggerer
+	queue         []*Span
+	dropped       int64
+	mtx           sync.Mutex
+}
+
+// NewTracer creates the tracer of the configured exporter, exporting with the given client
+func NewTracer(configurations *config.Config, client *http.Client) (*Tracer, error) {
+	tracingConfig := configurations.Tracing
+	exporter := tracingConfig.Exporter
+	if exporter == "" {
+		exporter = "otlp"
+	}
+	endpoint, exists := defaultEndpoints[exporter]
+	if !exists {
+		return nil, fmt.Errorf("Unknown tracing exporter: %s", exporter)
+	}
+	if tracingConfig.Endpoint != "" {
+		endpoint = tracingConfig.Endpoint
+	}
+	if tracingConfig.SampleRatio < 0 || tracingConfig.SampleRatio > 1 {
+		return nil, fmt.Errorf("Tracing sample ratio out of range: %v", tracingConfig.SampleRatio)
+	}
+	tracer := &Tracer{
+		serviceName:   tracingConfig.ServiceName,
+		endpoint:      endpoint,
+		headers:       tracingConfig.Headers,
+		sampleRatio:   tracingConfig.SampleRatio,
+		flushInterval: tracingConfig.FlushInterval,
+		maxQueueSize:  tracingConfig.MaxQueueSize,
+		client:        client,
+		logger:        commonLogger.NewLogFactory(configurations.Environment).NewLogger(),
+	}
+	if tracer.serviceName == "" {
+		tracer.serviceName = DefaultServiceName
+	}
+	if tracer.flushInterval <= 0 {
+		tracer.flushInterval = DefaultFlushInterval
+	}
+	if tracer.maxQueueSize <= 0 {
+		tracer.maxQueueSize = DefaultMaxQueueSize
+	}
+	return tracer, nil
+}
+
+var current atomic.Pointer[Tracer]
+
+// Use records the spans of the requests and upstream calls made afterwards with the tracer
+func Use(tracer *Tracer) {
+	current.Store(tracer)
+}
+
+// Current returns the tracer in use, nil when the tracing is disabled
+func Current() *Tracer {
+	return current.Load()
+}
+
+// Start starts a span, child of the span of the context or of the remote parent when valid.
+// A root span is sampled at the configured ratio, the others follow their parent.
+func (tracer *Tracer) Start(ctx context.Context, name string, kind int, remoteParent SpanContext) (context.Context, *Span) {
+	span := &Span{
+		Name:       name,
+		Kind:       kind,
+		StartTime:  time.Now(),
+		Attributes: make(map[string]string),
+		tracer:     tracer,
+	}
+	parent := remoteParent
+	if parentSpan := SpanFromContext(ctx); parentSpan != nil {
+		parent = parentSpan.Context
+	}
+	if parent.IsValid() {
+		span.Context = SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+		span.ParentSpanID = parent.SpanID
+	} else {
+		span.Context = SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: rand.Float64() < tracer.sampleRatio}
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Middleware starts a server span per request, continuing the trace of the traceparent header if any
+func (tracer *Tracer) Middleware(ctx *gin.Context) {
+	remoteParent, _ := ParseTraceparent(ctx.GetHeader(TraceparentHeader))
+	name := ctx.Request.Method
+	if route := ctx.FullPath(); route != "" {
+		name += " " + route
+	}
+	requestContext, span := tracer.Start(ctx.Request.Context(), name, SpanKindServer, remoteParent)
+	span.SetAttribute("http.request.method", ctx.Request.Method)
+	span.SetAttribute("http.route", ctx.FullPath())
+	ctx.Request = ctx.Request.WithContext(requestContext)
+
+	ctx.Next()
+
+	// Recorded once the handlers ran, as the public routes and the query redaction hide the tokens of the URLs
+	span.SetAttribute("url.path", middleware.LoggedPath(ctx))
+	if query := middleware.LoggedQuery(ctx); query != "" {
+		span.SetAttribute("url.query", query)
+	}
+	statusCode := ctx.Writer.Status()
+	span.SetAttribute("http.response.status_code", strconv.Itoa(statusCode))
+	if statusCode >= http.StatusInternalServerError {
+		span.SetError(http.StatusText(statusCode))
+	}
+	span.End()
+}
+
+func (tracer *Tracer) enqueue(span *Span) {
+	tracer.mtx.Lock()
+	defer tracer.mtx.Unlock()
+	if len(tracer.queue) >= tracer.maxQueueSize {
+		tracer.dropped++
+		return
+	}
+	tracer.queue = append(tracer.queue, span)
+}
+
+// drain takes the queued spans and the count of the spans dropped since the last drain
+func (tracer *Tracer) drain() ([]*Span, int64) {
+	tracer.mtx.Lock()
+	defer tracer.mtx.Unlock()
+	spans, dropped := tracer.queue, tracer.dropped
+	tracer.queue, tracer.dropped = nil, 0
+	return spans, dropped
+}
+
+// FlushJob exports the queued spans periodically
+func (tracer *Tracer) FlushJob() scheduler.Job {
+	return scheduler.Job{
+		Name:     "tracing_flush",
+		Schedule: scheduler.Every(tracer.flushInterval),
+		Run:      tracer.Flush,
+	}
+}
@@ -0,0 +1,247 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+)
+
+const incomingTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// fakeConnection captures the outgoing metadata of the calls and answers with its error
+type fakeConnection struct {
+	err      error
+	outgoing metadata.MD
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	connection.outgoing, _ = metadata.FromOutgoingContext(ctx)
+	return connection.err
+}
+
+func (connection *fakeConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	connection.outgoing, _ = metadata.FromOutgoingContext(ctx)
+	return nil, connection.err
+}
+
+func newTestTracer(t *testing.T, sampleRatio float64, endpoint string) *Tracer {
+	tracer, err := NewTracer(&config.Config{Tracing: config.TracingConfig{
+		Enabled:     true,
+		Endpoint:    endpoint,
+		Headers:     map[string]string{"Authorization": "Bearer collector-token"},
+		SampleRatio: sampleRatio,
+	}}, http.DefaultClient)
+	assert.NoError(t, err)
+	return tracer
+}
+
+func TestTraceparent(t *testing.T) {
+	t.Run("ParseTraceparent_Should_Read_The_Ids_And_The_Sampled_Flag", func(t *testing.T) {
+		spanContext, ok := ParseTraceparent(incomingTraceparent)
+
+		assert.True(t, ok)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", spanContext.SpanID.String())
+		assert.True(t, spanContext.Sampled)
+		assert.Equal(t, incomingTraceparent, spanContext.Traceparent())
+	})
+
+	t.Run("ParseTraceparent_Should_Reject_The_Invalid_Headers", func(t *testing.T) {
+		for _, header := range []string{
+			"",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		} {
+			_, ok := ParseTraceparent(header)
+			assert.False(t, ok, header)
+		}
+	})
+
+	t.Run("ParseTraceparent_Should_Accept_The_Later_Versions_With_Extra_Fields", func(t *testing.T) {
+		_, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+
+		assert.True(t, ok)
+	})
+}
+
+func TestTracer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Middleware_Should_Continue_The_Incoming_Trace", func(t *testing.T) {
+		tracer := newTestTracer(t, 0, "")
+		var requestSpan *Span
+		router := gin.New()
+		router.Use(tracer.Middleware)
+		router.GET("/api/v1/user/:userID", func(ctx *gin.Context) {
+			requestSpan = SpanFromContext(ctx.Request.Context())
+			AddEvent(ctx.Request.Context(), "authentication.authenticated")
+			ctx.Status(http.StatusOK)
+		})
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/user/first", nil)
+		request.Header.Set(TraceparentHeader, incomingTraceparent)
+
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		assert.NotNil(t, requestSpan)
+		assert.Equal(t, "GET /api/v1/user/:userID", requestSpan.Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", requestSpan.Context.TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", requestSpan.ParentSpanID.String())
+		assert.Equal(t, "200", requestSpan.Attributes["http.response.status_code"])
+		assert.Equal(t, "authentication.authenticated", requestSpan.Events[0].Name)
+		spans, _ := tracer.drain()
+		assert.Len(t, spans, 1)
+	})
+
+	t.Run("Middleware_Should_Record_The_Redacted_Query", func(t *testing.T) {
+		tracer := newTestTracer(t, 1, "")
+		queryRedactor, err := middleware.NewQueryRedactor(&config.Config{QueryRedaction: config.QueryRedactionConfig{
+			Routes: []config.QueryRedactionRouteConfig{{PathPrefix: "/api/v1/user/", Mode: middleware.QueryRedactionDrop}},
+		}})
+		assert.NoError(t, err)
+		router := gin.New()
+		router.Use(queryRedactor.Middleware, tracer.Middleware)
+		router.GET("/api/v1/user/:userID", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		router.GET("/api/v1/media", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/user/first?token=secret", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/media?page=2", nil))
+
+		spans, _ := tracer.drain()
+		assert.Len(t, spans, 2)
+		assert.Equal(t, "/api/v1/user/first", spans[0].Attributes["url.path"])
+		assert.NotContains(t, spans[0].Attributes, "url.query")
+		assert.Equal(t, "page=2", spans[1].Attributes["url.query"])
+	})
+
+	t.Run("Start_Should_Not_Queue_The_Unsampled_Root_Spans", func(t *testing.T) {
+		tracer := newTestTracer(t, 0, "")
+
+		_, span := tracer.Start(context.Background(), "GET", SpanKindServer, SpanContext{})
+		span.End()
+
+		assert.False(t, span.Context.Sampled)
+		spans, _ := tracer.drain()
+		assert.Len(t, spans, 0)
+	})
+
+	t.Run("Enqueue_Should_Drop_The_Spans_Beyond_The_Queue_Size", func(t *testing.T) {
+		tracer := newTestTracer(t, 1, "")
+		tracer.maxQueueSize = 1
+
+		for index := 0; index < 3; index++ {
+			_, span := tracer.Start(context.Background(), "GET", SpanKindServer, SpanContext{})
+			span.End()
+		}
+
+		spans, dropped := tracer.drain()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, int64(2), dropped)
+	})
+
+	t.Run("NewTracer_Should_Reject_An_Unknown_Exporter", func(t *testing.T) {
+		_, err := NewTracer(&config.Config{Tracing: config.TracingConfig{Exporter: "zipkin"}}, http.DefaultClient)
+
+		assert.EqualError(t, err, "Unknown tracing exporter: zipkin")
+	})
+
+	t.Run("Flush_Should_Export_The_Spans_Over_OTLP", func(t *testing.T) {
+		var body []byte
+		var authorization string
+		collector := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			body, _ = io.ReadAll(request.Body)
+			authorization = request.Header.Get("Authorization")
+			writer.WriteHeader(http.StatusOK)
+		}))
+		defer collector.Close()
+		tracer := newTestTracer(t, 1, collector.URL+"/v1/traces")
+		ctx, span := tracer.Start(context.Background(), "GET /api/v1/user/:userID", SpanKindServer, SpanContext{})
+		AddEvent(ctx, "authentication.rejected", "reason", "expired_token")
+		span.SetError("Unauthorized")
+		span.End()
+
+		assert.NoError(t, tracer.Flush(context.Background(), time.Now()))
+
+		assert.Equal(t, "Bearer collector-token", authorization)
+		exported := otlpRequest{}
+		assert.NoError(t, json.Unmarshal(body, &exported))
+		assert.Equal(t, DefaultServiceName, exported.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+		exportedSpan := exported.ResourceSpans[0].ScopeSpans[0].Spans[0]
+		assert.Equal(t, span.Context.TraceID.String(), exportedSpan.TraceID)
+		assert.Equal(t, SpanKindServer, exportedSpan.Kind)
+		assert.Equal(t, "authentication.rejected", exportedSpan.Events[0].Name)
+		assert.Equal(t, otlpAttribute{Key: "reason", Value: otlpValue{StringValue: "expired_token"}}, exportedSpan.Events[0].Attributes[0])
+		assert.Equal(t, otlpStatus{Code: statusError, Message: "Unauthorized"}, exportedSpan.Status)
+	})
+
+	t.Run("Flush_Should_Fail_When_The_Collector_Rejects_The_Spans", func(t *testing.T) {
+		collector := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer collector.Close()
+		tracer := newTestTracer(t, 1, collector.URL)
+		_, span := tracer.Start(context.Background(), "GET", SpanKindServer, SpanContext{})
+		span.End()
+
+		assert.EqualError(t, tracer.Flush(context.Background(), time.Now()), "Error exporting 1 spans: collector answered 503")
+	})
+}
+
+func TestTracingConnection(t *testing.T) {
+	t.Run("Invoke_Should_Propagate_The_Client_Span_In_The_Metadata", func(t *testing.T) {
+		tracer := newTestTracer(t, 1, "")
+		Use(tracer)
+		defer Use(nil)
+		upstream := &fakeConnection{err: status.Error(codes.Unavailable, "connection refused")}
+		connection := TraceConnection("payment", upstream, &config.Config{Tracing: config.TracingConfig{Enabled: true}})
+		ctx, requestSpan := tracer.Start(context.Background(), "GET", SpanKindServer, SpanContext{})
+		ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(TraceparentHeader, incomingTraceparent))
+
+		connection.Invoke(ctx, "/payment.PaymentService/GetCharge", nil, nil)
+
+		traceparent := upstream.outgoing.Get(TraceparentHeader)
+		assert.Len(t, traceparent, 1)
+		spanContext, ok := ParseTraceparent(traceparent[0])
+		assert.True(t, ok)
+		assert.Equal(t, requestSpan.Context.TraceID, spanContext.TraceID)
+		spans, _ := tracer.drain()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, spanContext.SpanID, spans[0].Context.SpanID)
+		assert.Equal(t, requestSpan.Context.SpanID, spans[0].ParentSpanID)
+		assert.Equal(t, codes.Unavailable.String(), spans[0].Attributes["rpc.grpc.status_code"])
+		assert.True(t, spans[0].Failed)
+	})
+
+	t.Run("TraceConnection_Should_Keep_The_Connection_When_Disabled", func(t *testing.T) {
+		upstream := &fakeConnection{}
+
+		assert.Equal(t, upstream, TraceConnection("payment", upstream, &config.Config{}))
+	})
+
+	t.Run("Invoke_Should_Pass_Through_Without_A_Tracer", func(t *testing.T) {
+		upstream := &fakeConnection{}
+		connection := TraceConnection("payment", upstream, &config.Config{Tracing: config.TracingConfig{Enabled: true}})
+
+		connection.Invoke(context.Background(), "/payment.PaymentService/GetCharge", nil, nil)
+
+		assert.True(t, strings.Join(upstream.outgoing.Get(TraceparentHeader), "") == "")
+	})
+}
@@ -0,0 +1,233 @@
+package transcoding
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// httpRuleField is the number of the google.api.http extension of the method options, read from the unknown
+// fields of the options as the annotations are not compiled in
+const httpRuleField protowire.Number = 72295728
+
+// Fields of the google.api.HttpRule message
+const (
+	ruleGet                protowire.Number = 2
+	rulePut                protowire.Number = 3
+	rulePost               protowire.Number = 4
+	ruleDelete             protowire.Number = 5
+	rulePatch              protowire.Number = 6
+	ruleBody               protowire.Number = 7
+	ruleCustom             protowire.Number = 8
+	ruleAdditionalBindings protowire.Number = 11
+	ruleResponseBody       protowire.Number = 12
+	customKind             protowire.Number = 1
+	customPath             protowire.Number = 2
+)
+
+var ruleMethods = map[protowire.Number]string{
+	ruleGet:    "GET",
+	rulePut:    "PUT",
+	rulePost:   "POST",
+	ruleDelete: "DELETE",
+	rulePatch:  "PATCH",
+}
+
+// Binding is an HTTP route of a method declared by its google.api.http annotation
+type Binding struct {
+	Method       string
+	Path         string
+	Body         string
+	ResponseBody string
+}
+
+// LoadDescriptors reads the descriptor set of the file, built with protoc --include_imports so its files resolve
+func LoadDescriptors(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the descriptor set %s: %v", path, err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("Could not decode the descriptor set %s: %v", path, err)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve the descriptor set %s: %v", path, err)
+	}
+	return files, nil
+}
+
+// FindService returns the service of the descriptors by full name
+func FindService(files *protoregistry.Files, name string) (protoreflect.ServiceDescriptor, error) {
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("The service %s is not in the descriptor set: %v", name, err)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service of the descriptor set", name)
+	}
+	return service, nil
+}
+
+// HTTPBindings returns the HTTP routes of the google.api.http annotation of the method, none without it
+func HTTPBindings(method protoreflect.MethodDescriptor) ([]Binding, error) {
+	options, ok := method.Options().(*descriptorpb.MethodOptions)
+	if !ok || options == nil {
+		return nil, nil
+	}
+	unknown := options.ProtoReflect().GetUnknown()
+	bindings := []Binding{}
+	for len(unknown) > 0 {
+		number, wireType, length := protowire.ConsumeTag(unknown)
+		if length < 0 {
+			return nil, protowire.ParseError(length)
+		}
+		unknown = unknown[length:]
+		if number != httpRuleField || wireType != protowire.BytesType {
+			length = protowire.ConsumeFieldValue(number, wireType, unknown)
+			if length < 0 {
+				return nil, protowire.ParseError(length)
+			}
+			unknown = unknown[length:]
+			continue
+		}
+		rule, length := protowire.ConsumeBytes(unknown)
+		if length < 0 {
+			return nil, protowire.ParseError(length)
+		}
+		unknown = unknown[length:]
+		ruleBindings, err := parseHTTPRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid google.api.http annotation on %s: %v", method.FullName(), err)
+		}
+		bindings = append(bindings, ruleBindings...)
+	}
+	return bindings, nil
+}
+
+// parseHTTPRule decodes a google.api.HttpRule into its binding followed by its additional bindings
+func parseHTTPRule(data []byte) ([]Binding, error) {
+	binding := Binding{}
+	additional := []Binding{}
+	for len(data) > 0 {
+		number, wireType, length := protowire.ConsumeTag(data)
+		if length < 0 {
+			return nil, protowire.ParseError(length)
+		}
+		data = data[length:]
+		if wireType != protowire.BytesType {
+			length = protowire.ConsumeFieldValue(number, wireType, data)
+			if length < 0 {
+				return nil, protowire.ParseError(length)
+			}
+			data = data[length:]
+			continue
+		}
+		value, length := protowire.ConsumeBytes(data)
+		if length < 0 {
+			return nil, protowire.ParseError(length)
+		}
+		data = data[length:]
+		switch number {
+		case ruleGet, rulePut, rulePost, ruleDelete, rulePatch:
+			binding.Method, binding.Path = ruleMethods[number], string(value)
+		case ruleCustom:
+			method, path, err := parseCustomPattern(value)
+			if err != nil {
+				return nil, err
+			}
+			binding.Method, binding.Path = method, path
+		case ruleBody:
+			binding.Body = string(value)
+		case ruleResponseBody:
+			binding.ResponseBody = string(value)
+		case ruleAdditionalBindings:
+			nested, err := parseHTTPRule(value)
+			if err != nil {
+				return nil, err
+			}
+			additional = append(additional, nested...)
+		}
+	}
+	if binding.Method == "" || binding.Path == "" {
+		return nil, fmt.Errorf("The rule has no method and path")
+	}
+	return append([]Binding{binding}, additional...), nil
+}
+
+// parseCustomPattern decodes a google.api.CustomHttpPattern into its method and path
+func parseCustomPattern(data []byte) (string, string, error) {
+	method, path := "", ""
+	for len(data) > 0 {
+		number, wireType, length := protowire.ConsumeTag(data)
+		if length < 0 {
+			return "", "", protowire.ParseError(length)
+		}
+		data = data[length:]
+		if wireType != protowire.BytesType {
+			length = protowire.ConsumeFieldValue(number, wireType, data)
+			if length < 0 {
+				return "", "", protowire.ParseError(length)
+			}
+			data = data[length:]
+			continue
+		}
+		value, length := protowire.ConsumeBytes(data)
+		if length < 0 {
+			return "", "", protowire.ParseError(length)
+		}
+		data = data[length:]
+		switch number {
+		case customKind:
+			method = strings.ToUpper(string(value))
+		case customPath:
+			path = string(value)
+		}
+	}
+	return method, path, nil
+}
+
+// GinPath converts the path template of a binding to a gin path and the field paths of its parameters.
+// The variables match a segment, {name} or {name=*}, or the rest of the path as the last one, {name=**},
+// the templates with custom verbs or multi-segment variables are not supported.
+func GinPath(template string) (string, []string, error) {
+	if !strings.HasPrefix(template, "/") {
+		return "", nil, fmt.Errorf("The path template %s must start with /", template)
+	}
+	segments := strings.Split(template[1:], "/")
+	fields := []string{}
+	for index, segment := range segments {
+		if !strings.HasPrefix(segment, "{") {
+			if strings.ContainsAny(segment, "{}*:") {
+				return "", nil, fmt.Errorf("The path template %s is not supported", template)
+			}
+			continue
+		}
+		if !strings.HasSuffix(segment, "}") {
+			return "", nil, fmt.Errorf("The path template %s is not supported", template)
+		}
+		field, pattern, _ := strings.Cut(segment[1:len(segment)-1], "=")
+		if field == "" {
+			return "", nil, fmt.Errorf("The path template %s has a variable without field", template)
+		}
+		switch {
+		case pattern == "" || pattern == "*":
+			segments[index] = ":" + field
+		case pattern == "**" && index == len(segments)-1:
+			segments[index] = "*" + field
+		default:
+			return "", nil, fmt.Errorf("The variable %s of the path template %s is not supported", segment, template)
+		}
+		fields = append(fields, field)
+	}
+	return "/" + strings.Join(segments, "/"), fields, nil
+}
@@ -0,0 +1,120 @@
+package transcoding
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// setField sets the field of the message at the dotted path to the values of a path or query parameter,
+// the repeated fields take every value and the other fields the first one
+func setField(message protoreflect.Message, path string, values []string) error {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		field := message.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if field == nil || field.Kind() != protoreflect.MessageKind || field.IsList() || field.IsMap() {
+			return fmt.Errorf("The field %s of %s is not a message", name, path)
+		}
+		message = message.Mutable(field).Message()
+	}
+	name := names[len(names)-1]
+	field := message.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if field == nil {
+		return fmt.Errorf("%s has no field %s", message.Descriptor().FullName(), path)
+	}
+	if field.IsMap() {
+		return fmt.Errorf("The map field %s can not be set from a parameter", path)
+	}
+	if field.IsList() {
+		list := message.Mutable(field).List()
+		for _, value := range values {
+			parsed, err := parseScalar(field, value)
+			if err != nil {
+				return err
+			}
+			list.Append(parsed)
+		}
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	parsed, err := parseScalar(field, values[0])
+	if err != nil {
+		return err
+	}
+	message.Set(field, parsed)
+	return nil
+}
+
+// parseScalar parses the value of a parameter as the kind of the field
+func parseScalar(field protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			decoded, err = base64.URLEncoding.DecodeString(value)
+		}
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs base64 bytes: %v", field.Name(), err)
+		}
+		return protoreflect.ValueOfBytes(decoded), nil
+	case protoreflect.BoolKind:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs a boolean: %v", field.Name(), err)
+		}
+		return protoreflect.ValueOfBool(parsed), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs an integer: %v", field.Name(), err)
+		}
+		return protoreflect.ValueOfInt32(int32(parsed)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs an integer: %v", field.Name(), err)
+		}
+		return protoreflect.ValueOfInt64(parsed), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs an unsigned integer: %v", field.Name(), err)
+		}
+		return protoreflect.ValueOfUint32(uint32(parsed)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs an unsigned integer: %v", field.Name(), err)
+		}
+		return protoreflect.ValueOfUint64(parsed), nil
+	case protoreflect.FloatKind:
+		parsed, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs a number: %v", field.Name(), err)
+		}
+		return protoreflect.ValueOfFloat32(float32(parsed)), nil
+	case protoreflect.DoubleKind:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs a number: %v", field.Name(), err)
+		}
+		return protoreflect.ValueOfFloat64(parsed), nil
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByName(protoreflect.Name(value)); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("The field %s needs a value of %s", field.Name(), field.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(parsed)), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("The %s field %s can not be set from a parameter", field.Kind(), field.Name())
+}
@@ -0,0 +1,137 @@
+package transcoding
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Content types of the gRPC-Web requests, the text ones carry base64 encoded frames for the browsers
+// unable to read binary responses
+const (
+	GRPCWebContentType     = "application/grpc-web+proto"
+	GRPCWebTextContentType = "application/grpc-web-text+proto"
+)
+
+// Flags of the gRPC-Web frames
+const (
+	dataFrame       byte = 0x00
+	compressedFrame byte = 0x01
+	trailersFrame   byte = 0x80
+)
+
+var grpcWebContentTypes = map[string]string{
+	"application/grpc-web":      GRPCWebContentType,
+	GRPCWebContentType:          GRPCWebContentType,
+	"application/grpc-web-text": GRPCWebTextContentType,
+	GRPCWebTextContentType:      GRPCWebTextContentType,
+}
+
+// GRPCWebHandler calls the method with the message of the gRPC-Web request and answers the response message
+// followed by the status in a trailers frame, the calls are answered with 200 whatever their status
+func GRPCWebHandler(method protoreflect.MethodDescriptor, connection grpc.ClientConnInterface) gin.HandlerFunc {
+	fullMethod := FullMethod(method)
+	return func(ctx *gin.Context) {
+		contentType, ok := grpcWebContentTypes[ctx.ContentType()]
+		if !ok {
+			errors.Abort(ctx, errors.GRPCWebRequired)
+			return
+		}
+		text := contentType == GRPCWebTextContentType
+		request := dynamicpb.NewMessage(method.Input())
+		var response proto.Message
+		err := readGRPCWebMessage(ctx.Request.Body, text, request)
+		if err == nil {
+			reply := dynamicpb.NewMessage(method.Output())
+			if err = connection.Invoke(ctx.Request.Context(), fullMethod, request, reply); err == nil {
+				response = reply
+			}
+		}
+		body, err := grpcWebBody(response, err)
+		if err != nil {
+			errors.HandleError(ctx, err)
+			return
+		}
+		if text {
+			body = []byte(base64.StdEncoding.EncodeToString(body))
+		}
+		ctx.Data(http.StatusOK, contentType, body)
+	}
+}
+
+// readGRPCWebMessage decodes the data frame of the request body into the message
+func readGRPCWebMessage(body io.Reader, text bool, message proto.Message) error {
+	if body == nil {
+		return status.Error(codes.InvalidArgument, "The gRPC-Web request has no body")
+	}
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Could not read the gRPC-Web request: %v", err)
+	}
+	if len(data) < 5 {
+		return status.Error(codes.InvalidArgument, "The gRPC-Web request has no message frame")
+	}
+	flags, length := data[0], binary.BigEndian.Uint32(data[1:5])
+	if flags&compressedFrame != 0 {
+		return status.Error(codes.Unimplemented, "Compressed gRPC-Web messages are not supported")
+	}
+	if flags&trailersFrame != 0 || uint64(len(data)-5) < uint64(length) {
+		return status.Error(codes.InvalidArgument, "The gRPC-Web request has an invalid message frame")
+	}
+	if err := proto.Unmarshal(data[5:5+length], message); err != nil {
+		return status.Errorf(codes.InvalidArgument, "Could not decode the gRPC-Web request message: %v", err)
+	}
+	return nil
+}
+
+// grpcWebBody frames the response message, when there is one, and the status of the call as trailers
+func grpcWebBody(response proto.Message, callErr error) ([]byte, error) {
+	body := []byte{}
+	if response != nil {
+		payload, err := proto.Marshal(response)
+		if err != nil {
+			return nil, err
+		}
+		body = appendFrame(body, dataFrame, payload)
+	}
+	callStatus := status.Convert(callErr)
+	trailers := fmt.Sprintf("grpc-status: %d\r\ngrpc-message: %s\r\n", callStatus.Code(), encodeGRPCMessage(callStatus.Message()))
+	return appendFrame(body, trailersFrame, []byte(trailers)), nil
+}
+
+func appendFrame(body []byte, flags byte, payload []byte) []byte {
+	header := make([]byte, 5)
+	header[0] = flags
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	return append(append(body, header...), payload...)
+}
+
+// encodeGRPCMessage percent-encodes the status message as the grpc-message header requires
+func encodeGRPCMessage(message string) string {
+	var encoded strings.Builder
+	for index := 0; index < len(message); index++ {
+		character := message[index]
+		if character >= ' ' && character <= '~' && character != '%' {
+			encoded.WriteByte(character)
+			continue
+		}
+		fmt.Fprintf(&encoded, "%%%02X", character)
+	}
+	return encoded.String()
+}
@@ -0,0 +1,281 @@
+package transcoding
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/routeregistry"
+)
+
+// Transcoder holds the routes registered for the methods of the transcoded services
+type Transcoder struct {
+	routes []routeregistry.Route
+}
+
+// Validate checks the transcoded services before the descriptor set is read
+func Validate(configurations *config.Config) error {
+	transcoding := configurations.Transcoding
+	if transcoding.DescriptorSet == "" {
+		return fmt.Errorf("The transcoding needs a descriptor set")
+	}
+	if !transcoding.HTTPRules && !transcoding.GRPCWeb {
+		return fmt.Errorf("The transcoding enables neither the HTTP rules nor gRPC-Web")
+	}
+	services := make(map[string]bool)
+	for _, service := range transcoding.Services {
+		if service.Name == "" || service.Host == "" || service.Port == "" {
+			return fmt.Errorf("The transcoded service %q needs a name, a host and a port", service.Name)
+		}
+		if services[service.Name] {
+			return fmt.Errorf("The transcoded service %s is declared twice", service.Name)
+		}
+		services[service.Name] = true
+		if service.Public && (len(service.Roles) > 0 || service.Audience != "") {
+			return fmt.Errorf("The public transcoded service %s can not require roles or an audience", service.Name)
+		}
+	}
+	return nil
+}
+
+// RegisterRoutes connects to the transcoded services and registers the routes of their unary methods, the routes
+// of their google.api.http annotations and the gRPC-Web route of every method, authenticated unless public
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*Transcoder, error) {
+	if err := Validate(configurations); err != nil {
+		return nil, err
+	}
+	files, err := LoadDescriptors(configurations.Transcoding.DescriptorSet)
+	if err != nil {
+		return nil, err
+	}
+	transcoder := &Transcoder{}
+	for _, serviceConfig := range configurations.Transcoding.Services {
+		service, err := FindService(files, serviceConfig.Name)
+		if err != nil {
+			return nil, err
+		}
+		connection, err := routeregistry.ConnectService(
+			serviceConfig.Name,
+			fmt.Sprintf("%s:%s", serviceConfig.Host, serviceConfig.Port),
+			centralConfig,
+			configurations,
+		)
+		if err != nil {
+			return nil, err
+		}
+		methods := service.Methods()
+		for index := 0; index < methods.Len(); index++ {
+			method := methods.Get(index)
+			if method.IsStreamingClient() || method.IsStreamingServer() {
+				continue
+			}
+			route := routeregistry.Route{
+				Service:    serviceConfig.Name,
+				GRPCMethod: FullMethod(method),
+				Public:     serviceConfig.Public,
+				Roles:      serviceConfig.Roles,
+				Audience:   serviceConfig.Audience,
+			}
+			if configurations.Transcoding.GRPCWeb {
+				route.Method, route.Path = http.MethodPost, route.GRPCMethod
+				if err := transcoder.handle(api, route, authenticationMiddleware, GRPCWebHandler(method, connection)); err != nil {
+					return nil, err
+				}
+			}
+			if !configurations.Transcoding.HTTPRules {
+				continue
+			}
+			bindings, err := HTTPBindings(method)
+			if err != nil {
+				return nil, err
+			}
+			for _, binding := range bindings {
+				path, fields, err := GinPath(binding.Path)
+				if err != nil {
+					return nil, fmt.Errorf("The HTTP rule of %s can not be transcoded: %v", method.FullName(), err)
+				}
+				if err := validateBinding(method, binding, fields); err != nil {
+					return nil, fmt.Errorf("The HTTP rule %s %s of %s can not be transcoded: %v", binding.Method, binding.Path, method.FullName(), err)
+				}
+				route.Method, route.Path = binding.Method, path
+				if err := transcoder.handle(api, route, authenticationMiddleware, HTTPHandler(method, binding, fields, connection)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return transcoder, nil
+}
+
+// handle registers the route behind the authentication of its service, turning the panic of gin on a route
+// conflicting with another into an error
+func (transcoder *Transcoder) handle(
+	api *gin.RouterGroup,
+	route routeregistry.Route,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+	handler gin.HandlerFunc,
+) (err error) {
+	handlers := []gin.HandlerFunc{}
+	if !route.Public {
+		handlers = append(handlers, authenticationMiddleware.RequireAuthentication)
+	}
+	if route.Audience != "" {
+		handlers = append(handlers, authenticationMiddleware.RequireAudience(route.Audience))
+	}
+	if len(route.Roles) > 0 {
+		handlers = append(handlers, authenticationMiddleware.RequireRole(route.Roles...))
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("The route %s %s of %s conflicts with another route: %v", route.Method, route.Path, route.GRPCMethod, recovered)
+		}
+	}()
+	api.Handle(route.Method, route.Path, append(handlers, handler)...)
+	transcoder.routes = append(transcoder.routes, route)
+	return nil
+}
+
+// FullMethod returns the gRPC method name of the method, as /package.Service/Method
+func FullMethod(method protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name())
+}
+
+// validateBinding checks the fields of the path, the body and the response body of the binding exist in the messages
+func validateBinding(method protoreflect.MethodDescriptor, binding Binding, fields []string) error {
+	for _, field := range fields {
+		if !hasField(method.Input(), field) {
+			return fmt.Errorf("%s has no field %s", method.Input().FullName(), field)
+		}
+	}
+	if binding.Body != "" && binding.Body != "*" && !isMessageField(method.Input(), binding.Body) {
+		return fmt.Errorf("The body %s is not a message field of %s", binding.Body, method.Input().FullName())
+	}
+	if binding.ResponseBody != "" && !isMessageField(method.Output(), binding.ResponseBody) {
+		return fmt.Errorf("The response body %s is not a message field of %s", binding.ResponseBody, method.Output().FullName())
+	}
+	return nil
+}
+
+// hasField tells whether the dotted path leads to a field of the message
+func hasField(message protoreflect.MessageDescriptor, path string) bool {
+	names := strings.Split(path, ".")
+	for index, name := range names {
+		field := message.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			return false
+		}
+		if index == len(names)-1 {
+			return true
+		}
+		if field.Kind() != protoreflect.MessageKind || field.IsList() || field.IsMap() {
+			return false
+		}
+		message = field.Message()
+	}
+	return false
+}
+
+// isMessageField tells whether the top-level field of the message is a singular message
+func isMessageField(message protoreflect.MessageDescriptor, name string) bool {
+	field := message.Fields().ByName(protoreflect.Name(name))
+	return field != nil && field.Kind() == protoreflect.MessageKind && !field.IsList() && !field.IsMap()
+}
+
+// HTTPHandler calls the method with the request message built from the body, the path parameters and the query
+// parameters as the binding declares them, the path parameters taking precedence, and answers the JSON response
+func HTTPHandler(
+	method protoreflect.MethodDescriptor,
+	binding Binding,
+	fields []string,
+	connection grpc.ClientConnInterface,
+) gin.HandlerFunc {
+	fullMethod := FullMethod(method)
+	return func(ctx *gin.Context) {
+		request := dynamicpb.NewMessage(method.Input())
+		if err := bindRequest(ctx, request, binding, fields); err != nil {
+			errors.Abort(ctx, errors.InvalidRequestBody)
+			return
+		}
+		response := dynamicpb.NewMessage(method.Output())
+		if err := connection.Invoke(ctx.Request.Context(), fullMethod, request, response); err != nil {
+			errors.HandleError(ctx, err)
+			return
+		}
+		var answer proto.Message = response
+		if binding.ResponseBody != "" {
+			field := method.Output().Fields().ByName(protoreflect.Name(binding.ResponseBody))
+			answer = response.Get(field).Message().Interface()
+		}
+		body, err := protojson.Marshal(answer)
+		if err != nil {
+			errors.HandleError(ctx, err)
+			return
+		}
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+// bindRequest fills the request message, the query parameters naming no field of the message are ignored
+func bindRequest(ctx *gin.Context, request *dynamicpb.Message, binding Binding, fields []string) error {
+	if binding.Body != "" && ctx.Request.Body != nil {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			var target proto.Message = request
+			if binding.Body != "*" {
+				field := request.Descriptor().Fields().ByName(protoreflect.Name(binding.Body))
+				target = request.Mutable(field).Message().Interface()
+			}
+			if err := protojson.Unmarshal(body, target); err != nil {
+				return err
+			}
+		}
+	}
+	pathFields := make(map[string]bool)
+	for _, field := range fields {
+		pathFields[field] = true
+		if err := setField(request, field, []string{strings.TrimPrefix(ctx.Param(field), "/")}); err != nil {
+			return err
+		}
+	}
+	if binding.Body == "*" {
+		return nil
+	}
+	for name, values := range ctx.Request.URL.Query() {
+		if pathFields[name] || !hasField(request.Descriptor(), name) {
+			continue
+		}
+		if binding.Body != "" && strings.Split(name, ".")[0] == binding.Body {
+			continue
+		}
+		if err := setField(request, name, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Routes returns the registered routes
+func (transcoder *Transcoder) Routes() []routeregistry.Route {
+	return append([]routeregistry.Route{}, transcoder.routes...)
+}
@@ -0,0 +1,296 @@
+package transcoding
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// fakeConnection records the calls and answers them with an entry or the error
+type fakeConnection struct {
+	method  string
+	request protoreflect.Message
+	err     error
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	connection.method = method
+	connection.request = args.(proto.Message).ProtoReflect()
+	if connection.err != nil {
+		return connection.err
+	}
+	entry := reply.(*dynamicpb.Message)
+	entry.Set(entry.Descriptor().Fields().ByName("id"), protoreflect.ValueOfString("entry-id"))
+	entry.Set(entry.Descriptor().Fields().ByName("amount"), protoreflect.ValueOfInt64(12))
+	return nil
+}
+
+func (connection *fakeConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Error(codes.Unimplemented, "streams are not supported")
+}
+
+func appendStringField(data []byte, number protowire.Number, value string) []byte {
+	return protowire.AppendString(protowire.AppendTag(data, number, protowire.BytesType), value)
+}
+
+// writeDescriptorSet writes the descriptor set of a ledger service whose GetEntry method is annotated with
+// a GET rule and an additional POST binding
+func writeDescriptorSet(t *testing.T) string {
+	additional := appendStringField(nil, rulePost, "/v1/entries")
+	additional = appendStringField(additional, ruleBody, "*")
+	rule := appendStringField(nil, ruleGet, "/v1/accounts/{account.id}/entries/{id}")
+	rule = protowire.AppendBytes(protowire.AppendTag(rule, ruleAdditionalBindings, protowire.BytesType), additional)
+	options := &descriptorpb.MethodOptions{}
+	options.ProtoReflect().SetUnknown(protowire.AppendBytes(protowire.AppendTag(nil, httpRuleField, protowire.BytesType), rule))
+
+	field := func(name string, number int32, fieldType descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		descriptor := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   fieldType.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			descriptor.TypeName = proto.String(typeName)
+		}
+		return descriptor
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("ledger.proto"),
+		Package: proto.String("pb_ledger"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Account"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			}},
+			{Name: proto.String("GetEntryRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("account", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".pb_ledger.Account"),
+				field("limit", 3, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+			}},
+			{Name: proto.String("Entry"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("amount", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("LedgerService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("GetEntry"),
+				InputType:  proto.String(".pb_ledger.GetEntryRequest"),
+				OutputType: proto.String(".pb_ledger.Entry"),
+				Options:    options,
+			}},
+		}},
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "descriptors.pb")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func getEntryMethod(t *testing.T) protoreflect.MethodDescriptor {
+	files, err := LoadDescriptors(writeDescriptorSet(t))
+	assert.NoError(t, err)
+	service, err := FindService(files, "pb_ledger.LedgerService")
+	assert.NoError(t, err)
+	return service.Methods().ByName("GetEntry")
+}
+
+func serveBinding(t *testing.T, connection *fakeConnection, binding Binding, request *http.Request) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	path, fields, err := GinPath(binding.Path)
+	assert.NoError(t, err)
+	router.Handle(binding.Method, path, HTTPHandler(getEntryMethod(t), binding, fields, connection))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func grpcWebFrame(flags byte, payload []byte) []byte {
+	header := make([]byte, 5)
+	header[0] = flags
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestTranscoding(t *testing.T) {
+	t.Run("HTTPBindings_Should_Return_The_Rule_And_Its_Additional_Bindings", func(t *testing.T) {
+		bindings, err := HTTPBindings(getEntryMethod(t))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Binding{
+			{Method: http.MethodGet, Path: "/v1/accounts/{account.id}/entries/{id}"},
+			{Method: http.MethodPost, Path: "/v1/entries", Body: "*"},
+		}, bindings)
+	})
+
+	t.Run("GinPath_Should_Convert_The_Supported_Templates", func(t *testing.T) {
+		path, fields, err := GinPath("/v1/accounts/{account.id}/entries/{id=*}/files/{path=**}")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/v1/accounts/:account.id/entries/:id/files/*path", path)
+		assert.Equal(t, []string{"account.id", "id", "path"}, fields)
+		for _, template := range []string{"v1/entries", "/v1/entries:batchGet", "/v1/{name=accounts/*}", "/v1/{path=**}/entries", "/v1/{}"} {
+			_, _, err := GinPath(template)
+			assert.Error(t, err, template)
+		}
+	})
+
+	t.Run("HTTPHandler_Should_Call_The_Method_With_The_Path_And_Query_Parameters", func(t *testing.T) {
+		connection := &fakeConnection{}
+		binding := Binding{Method: http.MethodGet, Path: "/v1/accounts/{account.id}/entries/{id}"}
+
+		recorder := serveBinding(t, connection, binding, httptest.NewRequest(http.MethodGet, "/v1/accounts/account-id/entries/entry-id?limit=5&id=other&unknown=1", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"id":"entry-id","amount":"12"}`, recorder.Body.String())
+		assert.Equal(t, "/pb_ledger.LedgerService/GetEntry", connection.method)
+		fields := connection.request.Descriptor().Fields()
+		assert.Equal(t, "entry-id", connection.request.Get(fields.ByName("id")).String())
+		assert.Equal(t, int64(5), connection.request.Get(fields.ByName("limit")).Int())
+		account := connection.request.Get(fields.ByName("account")).Message()
+		assert.Equal(t, "account-id", account.Get(account.Descriptor().Fields().ByName("id")).String())
+	})
+
+	t.Run("HTTPHandler_Should_Decode_The_Body_Into_The_Request", func(t *testing.T) {
+		connection := &fakeConnection{}
+		binding := Binding{Method: http.MethodPost, Path: "/v1/entries", Body: "*"}
+
+		recorder := serveBinding(t, connection, binding, httptest.NewRequest(http.MethodPost, "/v1/entries", strings.NewReader(`{"id":"entry-id","limit":3}`)))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		fields := connection.request.Descriptor().Fields()
+		assert.Equal(t, "entry-id", connection.request.Get(fields.ByName("id")).String())
+		assert.Equal(t, int64(3), connection.request.Get(fields.ByName("limit")).Int())
+	})
+
+	t.Run("HTTPHandler_Should_Reject_The_Invalid_Parameters", func(t *testing.T) {
+		connection := &fakeConnection{}
+		binding := Binding{Method: http.MethodGet, Path: "/v1/accounts/{account.id}/entries/{id}"}
+
+		recorder := serveBinding(t, connection, binding, httptest.NewRequest(http.MethodGet, "/v1/accounts/account-id/entries/entry-id?limit=many", nil))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Empty(t, connection.method)
+	})
+
+	t.Run("HTTPHandler_Should_Convert_The_GRPC_Errors", func(t *testing.T) {
+		connection := &fakeConnection{err: status.Error(codes.NotFound, "entry not found")}
+		binding := Binding{Method: http.MethodGet, Path: "/v1/accounts/{account.id}/entries/{id}"}
+
+		recorder := serveBinding(t, connection, binding, httptest.NewRequest(http.MethodGet, "/v1/accounts/account-id/entries/entry-id", nil))
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("GRPCWebHandler_Should_Answer_The_Response_And_The_Status_Trailers", func(t *testing.T) {
+		method := getEntryMethod(t)
+		connection := &fakeConnection{}
+		request := dynamicpb.NewMessage(method.Input())
+		request.Set(method.Input().Fields().ByName("id"), protoreflect.ValueOfString("entry-id"))
+		payload, err := proto.Marshal(request)
+		assert.NoError(t, err)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST(FullMethod(method), GRPCWebHandler(method, connection))
+		httpRequest := httptest.NewRequest(
+			http.MethodPost,
+			"/pb_ledger.LedgerService/GetEntry",
+			strings.NewReader(base64.StdEncoding.EncodeToString(grpcWebFrame(0, payload))),
+		)
+		httpRequest.Header.Set("Content-Type", "application/grpc-web-text")
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httpRequest)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, GRPCWebTextContentType, recorder.Header().Get("Content-Type"))
+		body, err := base64.StdEncoding.DecodeString(recorder.Body.String())
+		assert.NoError(t, err)
+		length := binary.BigEndian.Uint32(body[1:5])
+		response := dynamicpb.NewMessage(method.Output())
+		assert.NoError(t, proto.Unmarshal(body[5:5+length], response))
+		assert.Equal(t, int64(12), response.Get(method.Output().Fields().ByName("amount")).Int())
+		assert.Equal(t, "entry-id", connection.request.Get(method.Input().Fields().ByName("id")).String())
+		trailers := body[5+length:]
+		assert.Equal(t, trailersFrame, trailers[0])
+		assert.True(t, bytes.Contains(trailers[5:], []byte("grpc-status: 0\r\n")))
+	})
+
+	t.Run("GRPCWebHandler_Should_Answer_The_Errors_In_The_Trailers", func(t *testing.T) {
+		method := getEntryMethod(t)
+		connection := &fakeConnection{err: status.Error(codes.NotFound, "entry 100% missing")}
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST(FullMethod(method), GRPCWebHandler(method, connection))
+		httpRequest := httptest.NewRequest(http.MethodPost, "/pb_ledger.LedgerService/GetEntry", bytes.NewReader(grpcWebFrame(0, nil)))
+		httpRequest.Header.Set("Content-Type", GRPCWebContentType)
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httpRequest)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		body := recorder.Body.Bytes()
+		assert.Equal(t, trailersFrame, body[0])
+		assert.Equal(t, "grpc-status: 5\r\ngrpc-message: entry 100%25 missing\r\n", string(body[5:]))
+	})
+
+	t.Run("GRPCWebHandler_Should_Reject_The_Other_Content_Types", func(t *testing.T) {
+		method := getEntryMethod(t)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST(FullMethod(method), GRPCWebHandler(method, &fakeConnection{}))
+		httpRequest := httptest.NewRequest(http.MethodPost, "/pb_ledger.LedgerService/GetEntry", strings.NewReader("{}"))
+		httpRequest.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httpRequest)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+	})
+
+	t.Run("Validate_Should_Reject_The_Invalid_Services", func(t *testing.T) {
+		for _, transcoding := range []config.TranscodingConfig{
+			{HTTPRules: true},
+			{DescriptorSet: "descriptors.pb"},
+			{DescriptorSet: "descriptors.pb", GRPCWeb: true, Services: []config.TranscodedServiceConfig{{Name: "pb_ledger.LedgerService"}}},
+			{DescriptorSet: "descriptors.pb", GRPCWeb: true, Services: []config.TranscodedServiceConfig{
+				{Name: "pb_ledger.LedgerService", Host: "localhost", Port: "9097"},
+				{Name: "pb_ledger.LedgerService", Host: "localhost", Port: "9098"},
+			}},
+			{DescriptorSet: "descriptors.pb", GRPCWeb: true, Services: []config.TranscodedServiceConfig{
+				{Name: "pb_ledger.LedgerService", Host: "localhost", Port: "9097", Public: true, Roles: []string{"admin"}},
+			}},
+		} {
+			assert.Error(t, Validate(&config.Config{Transcoding: transcoding}))
+		}
+		assert.NoError(t, Validate(&config.Config{Transcoding: config.TranscodingConfig{
+			DescriptorSet: "descriptors.pb",
+			HTTPRules:     true,
+			Services:      []config.TranscodedServiceConfig{{Name: "pb_ledger.LedgerService", Host: "localhost", Port: "9097"}},
+		}}))
+	})
+}
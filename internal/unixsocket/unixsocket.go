@@ -0,0 +1,52 @@
+package unixsocket
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// Target returns the gRPC target dialing the socket at the path
+func Target(path string) string {
+	return "unix:" + path
+}
+
+// ParseMode parses the octal permissions of a socket, an empty mode keeps the umask ones
+func ParseMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || parsed > 0777 {
+		return 0, fmt.Errorf("Invalid unix socket mode: %s", mode)
+	}
+	return os.FileMode(parsed), nil
+}
+
+// Listen listens on the socket at the path replacing a stale socket left by a previous process
+func Listen(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("Could not listen on %s: the path exists and is not a socket", path)
+		}
+		if connection, err := net.Dial("unix", path); err == nil {
+			connection.Close()
+			return nil, fmt.Errorf("Could not listen on %s: the socket is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("Could not remove stale socket %s: %v", path, err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not listen on %s: %v", path, err)
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("Could not set the permissions of %s: %v", path, err)
+		}
+	}
+	return listener, nil
+}
@@ -0,0 +1,58 @@
+package unixsocket
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListen(t *testing.T) {
+	t.Run("Listen_Should_Replace_Stale_Sockets", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gateway.sock")
+		stale, err := net.Listen("unix", path)
+		assert.NoError(t, err)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		listener, err := Listen(path, 0660)
+		assert.NoError(t, err)
+		defer listener.Close()
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+	})
+
+	t.Run("Listen_Should_Fail_When_The_Socket_Is_In_Use", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gateway.sock")
+		listener, err := Listen(path, 0)
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		_, err = Listen(path, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("Listen_Should_Not_Remove_Other_Files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gateway.sock")
+		assert.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+
+		_, err := Listen(path, 0)
+		assert.Error(t, err)
+		_, err = os.Stat(path)
+		assert.NoError(t, err)
+	})
+}
+
+func TestParseMode(t *testing.T) {
+	t.Run("ParseMode_Should_Parse_Octal_Permissions", func(t *testing.T) {
+		mode, err := ParseMode("0660")
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0660), mode)
+
+		_, err = ParseMode("999")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Modes of the response validation
+const (
+	// ResponseModeLog logs the contract violations of the responses and forwards them
+	ResponseModeLog = "log"
+	// ResponseModeFail answers a bad gateway in place of the responses violating their contract
+	ResponseModeFail = "fail"
+)
+
+// validateResponse validates the successful JSON response of the route against its response schema. The failing
+// mode holds the response back until it is validated, the logging mode forwards it as it is written
+func (validator *Validator) validateResponse(ctx *gin.Context, rules *routeRules) {
+	original := ctx.Writer
+	writer := &validatingWriter{ResponseWriter: original, hold: rules.responseMode == ResponseModeFail}
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = original
+
+	body := writer.body.Bytes()
+	violations := []FieldError{}
+	if validatedResponse(original) && len(bytes.TrimSpace(body)) > 0 {
+		violations = rules.responseSchema.ValidateDocument(body)
+	}
+	if len(violations) > 0 {
+		if logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context()); err == nil {
+			logger.Warn(fmt.Sprintf("The response of %s %s violates its contract: %s", ctx.Request.Method, ctx.FullPath(), describe(violations)))
+		}
+	}
+	if !writer.hold {
+		return
+	}
+	if len(violations) > 0 {
+		original.Header().Del("Content-Length")
+		errors.Abort(ctx, errors.BadGateway)
+		return
+	}
+	original.WriteHeaderNow()
+	original.Write(body)
+}
+
+// validatedResponse tells whether the response is described by the response schema, the error envelopes and
+// the encoded bodies are not
+func validatedResponse(writer gin.ResponseWriter) bool {
+	header := writer.Header()
+	return writer.Status() >= http.StatusOK && writer.Status() < http.StatusMultipleChoices &&
+		strings.Contains(strings.ToLower(header.Get("Content-Type")), "json") &&
+		(header.Get("Content-Encoding") == "" || strings.EqualFold(header.Get("Content-Encoding"), "identity"))
+}
+
+func describe(violations []FieldError) string {
+	descriptions := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		if violation.Field == "" {
+			descriptions = append(descriptions, violation.Error)
+			continue
+		}
+		descriptions = append(descriptions, violation.Field+" "+violation.Error)
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// validatingWriter keeps the response body for its validation, holding back the status and the body
+// until they are validated in the failing mode
+type validatingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+	hold bool
+}
+
+func (writer *validatingWriter) WriteHeaderNow() {
+	if !writer.hold {
+		writer.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (writer *validatingWriter) Write(data []byte) (int, error) {
+	writer.body.Write(data)
+	if writer.hold {
+		return len(data), nil
+	}
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *validatingWriter) WriteString(data string) (int, error) {
+	return writer.Write([]byte(data))
+}
+
+func (writer *validatingWriter) Flush() {
+	if !writer.hold {
+		writer.ResponseWriter.Flush()
+	}
+}
@@ -0,0 +1,365 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// FieldError is a violation of the schema, the field is the path of the value, empty for the whole body
+type FieldError struct {
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// schemaTypes are the accepted types of a value, a single type or a list in the schema document
+type schemaTypes []string
+
+func (types *schemaTypes) UnmarshalJSON(document []byte) error {
+	single := ""
+	if err := json.Unmarshal(document, &single); err == nil {
+		*types = schemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(document, (*[]string)(types))
+}
+
+// Schema is the subset of JSON Schema the gateway enforces: the types, the properties of the objects, the items
+// of the arrays, the enumerations and the bounds of the strings, numbers and arrays, other keywords are ignored
+type Schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Format               string             `json:"format"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+
+	pattern *regexp.Regexp
+	// denied is the false schema, no value is valid
+	denied bool
+}
+
+// UnmarshalJSON reads a schema document, the true and false schemas included, and compiles its pattern
+func (schema *Schema) UnmarshalJSON(document []byte) error {
+	switch string(bytes.TrimSpace(document)) {
+	case "true":
+		*schema = Schema{}
+		return nil
+	case "false":
+		*schema = Schema{denied: true}
+		return nil
+	}
+	type plainSchema Schema
+	if err := json.Unmarshal(document, (*plainSchema)(schema)); err != nil {
+		return err
+	}
+	if schema.Pattern != "" {
+		pattern, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid pattern %s: %v", schema.Pattern, err)
+		}
+		schema.pattern = pattern
+	}
+	return nil
+}
+
+// LoadSchema reads the JSON Schema file
+func LoadSchema(path string) (*Schema, error) {
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the schema %s: %v", path, err)
+	}
+	schema := &Schema{}
+	if err := json.Unmarshal(document, schema); err != nil {
+		return nil, fmt.Errorf("Could not parse the schema %s: %v", path, err)
+	}
+	return schema, nil
+}
+
+// ValidateDocument returns the violations of the JSON document, a single one when it is not valid JSON
+func (schema *Schema) ValidateDocument(document []byte) []FieldError {
+	value, violation := decodeDocument(document)
+	if violation != nil {
+		return []FieldError{*violation}
+	}
+	violations := []FieldError{}
+	schema.validate(value, "", &violations)
+	return violations
+}
+
+// UnknownFields returns the fields of the JSON document the schema does not declare, in the objects whose
+// properties are listed without an additional properties schema. It returns none when it is not valid JSON
+func (schema *Schema) UnknownFields(document []byte) []FieldError {
+	value, violation := decodeDocument(document)
+	if violation != nil {
+		return []FieldError{}
+	}
+	unknown := []FieldError{}
+	schema.unknownFields(value, "", &unknown)
+	return unknown
+}
+
+func decodeDocument(document []byte) (interface{}, *FieldError) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	// The numbers are kept as written so the integers are told apart from the other numbers
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, &FieldError{Error: "must be a JSON document"}
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, &FieldError{Error: "must be a single JSON document"}
+	}
+	return value, nil
+}
+
+func (schema *Schema) unknownFields(value interface{}, path string, unknown *[]FieldError) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(typedValue))
+		for name := range typedValue {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, exists := schema.Properties[name]; exists {
+				property.unknownFields(typedValue[name], childPath(path, name), unknown)
+			} else if schema.AdditionalProperties != nil {
+				schema.AdditionalProperties.unknownFields(typedValue[name], childPath(path, name), unknown)
+			} else if schema.Properties != nil {
+				*unknown = append(*unknown, FieldError{Field: childPath(path, name), Error: "is not a known field"})
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for index, item := range typedValue {
+				schema.Items.unknownFields(item, fmt.Sprintf("%s[%d]", path, index), unknown)
+			}
+		}
+	}
+}
+
+func (schema *Schema) validate(value interface{}, path string, violations *[]FieldError) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, FieldError{Field: path, Error: fmt.Sprintf(format, args...)})
+	}
+	if schema.denied {
+		report("is not allowed")
+		return
+	}
+	if len(schema.Type) > 0 && !schema.Type.accept(value) {
+		if len(schema.Type) == 1 {
+			report("must be %s", article(schema.Type[0]))
+		} else {
+			report("must be one of the types %s", strings.Join(schema.Type, ", "))
+		}
+		return
+	}
+	if len(schema.Enum) > 0 && !schema.enumerates(value) {
+		enumeration, _ := json.Marshal(schema.Enum)
+		report("must be one of %s", enumeration)
+		return
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		schema.validateObject(typedValue, path, violations)
+	case []interface{}:
+		if schema.MinItems != nil && len(typedValue) < *schema.MinItems {
+			report("must have at least %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(typedValue) > *schema.MaxItems {
+			report("must have at most %d items", *schema.MaxItems)
+		}
+		if schema.Items != nil {
+			for index, item := range typedValue {
+				schema.Items.validate(item, fmt.Sprintf("%s[%d]", path, index), violations)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(typedValue)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			report("must be at least %d characters", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			report("must be at most %d characters", *schema.MaxLength)
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(typedValue) {
+			report("must match the pattern %s", schema.Pattern)
+		}
+		if !validFormat(schema.Format, typedValue) {
+			report("must be a valid %s", schema.Format)
+		}
+	case json.Number:
+		number, _ := typedValue.Float64()
+		if schema.Minimum != nil && number < *schema.Minimum {
+			report("must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && number > *schema.Maximum {
+			report("must be at most %v", *schema.Maximum)
+		}
+	}
+}
+
+// validateObject reports the missing required properties, then validates the properties in name order
+func (schema *Schema) validateObject(object map[string]interface{}, path string, violations *[]FieldError) {
+	for _, name := range schema.Required {
+		if _, exists := object[name]; !exists {
+			*violations = append(*violations, FieldError{Field: childPath(path, name), Error: "is required"})
+		}
+	}
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, exists := schema.Properties[name]; exists {
+			property.validate(object[name], childPath(path, name), violations)
+		} else if schema.AdditionalProperties != nil {
+			schema.AdditionalProperties.validate(object[name], childPath(path, name), violations)
+		}
+	}
+}
+
+func (types schemaTypes) accept(value interface{}) bool {
+	for _, schemaType := range types {
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			if schemaType == "object" {
+				return true
+			}
+		case []interface{}:
+			if schemaType == "array" {
+				return true
+			}
+		case string:
+			if schemaType == "string" {
+				return true
+			}
+		case bool:
+			if schemaType == "boolean" {
+				return true
+			}
+		case nil:
+			if schemaType == "null" {
+				return true
+			}
+		case json.Number:
+			number, err := typedValue.Float64()
+			if schemaType == "number" || (schemaType == "integer" && err == nil && number == math.Trunc(number)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (schema *Schema) enumerates(value interface{}) bool {
+	for _, enumerated := range schema.Enum {
+		if equal(enumerated, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares the decoded values, the numbers by value since the schema and the body decode them differently
+func equal(left, right interface{}) bool {
+	switch typedLeft := left.(type) {
+	case map[string]interface{}:
+		typedRight, ok := right.(map[string]interface{})
+		if !ok || len(typedLeft) != len(typedRight) {
+			return false
+		}
+		for name, leftValue := range typedLeft {
+			rightValue, exists := typedRight[name]
+			if !exists || !equal(leftValue, rightValue) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		typedRight, ok := right.([]interface{})
+		if !ok || len(typedLeft) != len(typedRight) {
+			return false
+		}
+		for index := range typedLeft {
+			if !equal(typedLeft[index], typedRight[index]) {
+				return false
+			}
+		}
+		return true
+	}
+	leftNumber, leftIsNumber := toFloat(left)
+	rightNumber, rightIsNumber := toFloat(right)
+	if leftIsNumber || rightIsNumber {
+		return leftIsNumber && rightIsNumber && leftNumber == rightNumber
+	}
+	return left == right
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch typedValue := value.(type) {
+	case float64:
+		return typedValue, true
+	case json.Number:
+		number, err := typedValue.Float64()
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// validFormat checks the formats the gateway knows, the other formats are only annotations
+func validFormat(format, value string) bool {
+	switch format {
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, value)
+		return err == nil
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	}
+	return true
+}
+
+func childPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func article(schemaType string) string {
+	switch schemaType {
+	case "object", "array", "integer":
+		return "an " + schemaType
+	case "null":
+		return "null"
+	}
+	return "a " + schemaType
+}
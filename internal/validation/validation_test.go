@@ -0,0 +1,294 @@
+package validation
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func parseSchema(t *testing.T, document string) *Schema {
+	schema := &Schema{}
+	assert.NoError(t, json.Unmarshal([]byte(document), schema))
+	return schema
+}
+
+func TestSchema(t *testing.T) {
+	schema := parseSchema(t, `{
+		"type": "object",
+		"required": ["email", "age"],
+		"properties": {
+			"email": {"type": "string", "format": "email"},
+			"age": {"type": "integer", "minimum": 18},
+			"nickname": {"type": ["string", "null"], "minLength": 3, "pattern": "^[a-z]+$"},
+			"role": {"enum": ["user", "admin"]},
+			"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}}
+		},
+		"additionalProperties": false
+	}`)
+
+	t.Run("ValidateDocument_Should_Accept_A_Valid_Document", func(t *testing.T) {
+		violations := schema.ValidateDocument([]byte(`{"email":"user@example.com","age":18.0,"nickname":null,"role":"admin","tags":["a"]}`))
+
+		assert.Empty(t, violations)
+	})
+
+	t.Run("ValidateDocument_Should_List_The_Field_Violations", func(t *testing.T) {
+		violations := schema.ValidateDocument([]byte(`{"email":"not an email","nickname":"Al","role":"owner","tags":["a",2,"c"],"extra":true}`))
+
+		assert.Equal(t, []FieldError{
+			{Field: "age", Error: "is required"},
+			{Field: "email", Error: "must be a valid email"},
+			{Field: "extra", Error: "is not allowed"},
+			{Field: "nickname", Error: "must be at least 3 characters"},
+			{Field: "nickname", Error: "must match the pattern ^[a-z]+$"},
+			{Field: "role", Error: `must be one of ["user","admin"]`},
+			{Field: "tags", Error: "must have at most 2 items"},
+			{Field: "tags[1]", Error: "must be a string"},
+		}, violations)
+	})
+
+	t.Run("UnknownFields_Should_List_The_Undeclared_Fields", func(t *testing.T) {
+		schema := parseSchema(t, `{
+			"type": "object",
+			"properties": {
+				"email": {"type": "string"},
+				"address": {"type": "object", "properties": {"city": {"type": "string"}}},
+				"contacts": {"type": "array", "items": {"type": "object", "properties": {"phone": {"type": "string"}}}},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}}
+			}
+		}`)
+
+		unknown := schema.UnknownFields([]byte(`{"emial":"user@example.com","address":{"cty":"Madrid"},"contacts":[{"phone":"1"},{"fax":"2"}],"labels":{"team":"payments"}}`))
+
+		assert.Equal(t, []FieldError{
+			{Field: "address.cty", Error: "is not a known field"},
+			{Field: "contacts[1].fax", Error: "is not a known field"},
+			{Field: "emial", Error: "is not a known field"},
+		}, unknown)
+	})
+
+	t.Run("ValidateDocument_Should_Tell_The_Integers_Apart", func(t *testing.T) {
+		violations := schema.ValidateDocument([]byte(`{"email":"user@example.com","age":17.5}`))
+
+		assert.Equal(t, []FieldError{{Field: "age", Error: "must be an integer"}}, violations)
+	})
+
+	t.Run("ValidateDocument_Should_Reject_What_Is_Not_A_Single_JSON_Document", func(t *testing.T) {
+		assert.Equal(t, []FieldError{{Error: "must be a JSON document"}}, schema.ValidateDocument([]byte(`{"email":`)))
+		assert.Equal(t, []FieldError{{Error: "must be a single JSON document"}}, schema.ValidateDocument([]byte(`{} {}`)))
+		assert.Equal(t, []FieldError{{Error: "must be an object"}}, schema.ValidateDocument([]byte(`[]`)))
+	})
+
+	t.Run("LoadSchema_Should_Reject_An_Invalid_Pattern", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "schema.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"pattern": "["}`), 0o644))
+
+		_, err := LoadSchema(path)
+
+		assert.ErrorContains(t, err, "Could not parse the schema "+path+": Invalid pattern [")
+	})
+
+	t.Run("LoadSchema_Should_Load_The_Schemas_Of_The_Template", func(t *testing.T) {
+		schema, err := LoadSchema("../config/schemas/register.json")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []FieldError{{Field: "dateOfBirth", Error: "is required"}}, schema.ValidateDocument([]byte(
+			`{"email":"user@example.com","password":"password","firstName":"First","lastName":"Last"}`,
+		)))
+	})
+	t.Run("LoadSchema_Should_Load_The_Response_Schemas_Of_The_Template", func(t *testing.T) {
+		schema, err := LoadSchema("../config/schemas/countries.json")
+
+		assert.NoError(t, err)
+		assert.Empty(t, schema.ValidateDocument([]byte(`{"countries":[{"code":"ES","name":"Spain","currency":"EUR"}]}`)))
+		assert.Equal(t, []FieldError{{Field: "countries[0].name", Error: "is required"}}, schema.ValidateDocument([]byte(`{"countries":[{"code":"ES"}]}`)))
+	})
+}
+
+func TestValidator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	schemaPath := filepath.Join(t.TempDir(), "profile.json")
+	assert.NoError(t, os.WriteFile(schemaPath, []byte(`{"type":"object","required":["firstName"],"properties":{"firstName":{"type":"string"}}}`), 0o644))
+	validator, err := NewValidator(&config.Config{Validation: config.ValidationConfig{
+		Enabled:     true,
+		MaxBodySize: 64,
+		Routes: []config.ValidationRouteConfig{
+			{Method: "put", Path: "/user/profile", Schema: schemaPath},
+			{Method: "patch", Path: "/user/profile", Schema: schemaPath, Strict: true},
+			{Path: "/media", MaxBodySize: 1024},
+		},
+	}})
+	assert.NoError(t, err)
+	router := gin.New()
+	router.Use(validator.Middleware)
+	received := ""
+	handler := func(ctx *gin.Context) {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		received = string(body)
+		ctx.Status(http.StatusNoContent)
+	}
+	router.PUT("/user/profile", handler)
+	router.POST("/user/profile", handler)
+	router.PATCH("/user/profile", handler)
+	router.POST("/media", handler)
+	serve := func(method, path string, body io.Reader) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(method, path, body))
+		return recorder
+	}
+
+	t.Run("Middleware_Should_Forward_The_Valid_Body_To_The_Handler", func(t *testing.T) {
+		recorder := serve(http.MethodPut, "/user/profile", strings.NewReader(`{"firstName":"First"}`))
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.Equal(t, `{"firstName":"First"}`, received)
+	})
+
+	t.Run("Middleware_Should_Answer_The_Field_Violations", func(t *testing.T) {
+		recorder := serve(http.MethodPut, "/user/profile", strings.NewReader(`{"firstName":1}`))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.JSONEq(t, `{"error":{
+			"code":"invalid_request_body",
+			"message":"The request body is invalid",
+			"details":[{"type":"field_violation","field":"firstName","description":"must be a string"}]
+		}}`, recorder.Body.String())
+	})
+
+	t.Run("Middleware_Should_Answer_The_Unknown_Fields_Of_The_Strict_Routes", func(t *testing.T) {
+		lenient := serve(http.MethodPut, "/user/profile", strings.NewReader(`{"firstName":"First","lastNmae":"Last"}`))
+		strict := serve(http.MethodPatch, "/user/profile", strings.NewReader(`{"firstNmae":"First"}`))
+
+		assert.Equal(t, http.StatusNoContent, lenient.Code)
+		assert.Equal(t, http.StatusBadRequest, strict.Code)
+		assert.JSONEq(t, `{"error":{
+			"code":"invalid_request_body",
+			"message":"The request body is invalid",
+			"details":[
+				{"type":"field_violation","field":"firstNmae","description":"is not a known field"},
+				{"type":"field_violation","field":"firstName","description":"is required"}
+			]
+		}}`, strict.Body.String())
+	})
+
+	t.Run("Middleware_Should_Only_Validate_The_Configured_Method", func(t *testing.T) {
+		recorder := serve(http.MethodPost, "/user/profile", strings.NewReader(`{"firstName":1}`))
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+	})
+
+	t.Run("Middleware_Should_Reject_The_Bodies_Beyond_The_Limit", func(t *testing.T) {
+		recorder := serve(http.MethodPost, "/user/profile", strings.NewReader(strings.Repeat("a", 65)))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+		assert.JSONEq(t, `{"error":{"code":"payload_too_large","message":"The request body is too large"}}`, recorder.Body.String())
+	})
+
+	t.Run("Middleware_Should_Cut_The_Bodies_Without_Length_Beyond_The_Limit", func(t *testing.T) {
+		recorder := serve(http.MethodPut, "/user/profile", io.MultiReader(strings.NewReader(strings.Repeat("a", 65))))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+		assert.JSONEq(t, `{"error":{"code":"payload_too_large","message":"The request body is too large"}}`, recorder.Body.String())
+	})
+
+	t.Run("Middleware_Should_Apply_The_Limit_Of_The_Route", func(t *testing.T) {
+		recorder := serve(http.MethodPost, "/media", strings.NewReader(strings.Repeat("a", 1024)))
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+	})
+
+	t.Run("NewValidator_Should_Return_The_Schema_Errors", func(t *testing.T) {
+		_, err := NewValidator(&config.Config{Validation: config.ValidationConfig{
+			Routes: []config.ValidationRouteConfig{{Path: "/user/profile", Schema: "missing.json"}},
+		}})
+
+		assert.ErrorContains(t, err, "Could not read the schema missing.json")
+	})
+
+	t.Run("NewValidator_Should_Reject_The_Strict_Routes_Without_Schema", func(t *testing.T) {
+		_, err := NewValidator(&config.Config{Validation: config.ValidationConfig{
+			Routes: []config.ValidationRouteConfig{{Method: "PUT", Path: "/user/profile", Strict: true}},
+		}})
+
+		assert.ErrorContains(t, err, "The strict route PUT /user/profile needs a schema")
+	})
+}
+
+func TestResponseValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	schemaPath := filepath.Join(t.TempDir(), "profile.json")
+	assert.NoError(t, os.WriteFile(schemaPath, []byte(`{"type":"object","required":["firstName"],"properties":{"firstName":{"type":"string"}}}`), 0o644))
+	newRouter := func(t *testing.T, mode string, profile gin.H) *gin.Engine {
+		validator, err := NewValidator(&config.Config{Validation: config.ValidationConfig{
+			Enabled:      true,
+			ResponseMode: mode,
+			Routes:       []config.ValidationRouteConfig{{Method: "GET", Path: "/user/profile", ResponseSchema: schemaPath}},
+		}})
+		assert.NoError(t, err)
+		router := gin.New()
+		router.Use(validator.Middleware)
+		router.GET("/user/profile", func(ctx *gin.Context) {
+			if profile == nil {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "not_found"}})
+				return
+			}
+			ctx.JSON(http.StatusOK, profile)
+		})
+		return router
+	}
+	serve := func(router *gin.Engine) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user/profile", nil))
+		return recorder
+	}
+
+	t.Run("Middleware_Should_Forward_The_Valid_Responses", func(t *testing.T) {
+		recorder := serve(newRouter(t, ResponseModeFail, gin.H{"firstName": "First"}))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"firstName":"First"}`, recorder.Body.String())
+	})
+
+	t.Run("Middleware_Should_Answer_A_Bad_Gateway_For_The_Invalid_Responses_When_Failing", func(t *testing.T) {
+		recorder := serve(newRouter(t, ResponseModeFail, gin.H{"firstName": 1}))
+
+		assert.Equal(t, http.StatusBadGateway, recorder.Code)
+		assert.JSONEq(t, `{"error":{"code":"bad_gateway","message":"The service answered an invalid response"}}`, recorder.Body.String())
+	})
+
+	t.Run("Middleware_Should_Forward_The_Invalid_Responses_When_Logging", func(t *testing.T) {
+		recorder := serve(newRouter(t, "", gin.H{"firstName": 1}))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"firstName":1}`, recorder.Body.String())
+	})
+
+	t.Run("Middleware_Should_Not_Validate_The_Error_Responses", func(t *testing.T) {
+		recorder := serve(newRouter(t, ResponseModeFail, nil))
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("NewValidator_Should_Reject_Unknown_Response_Modes", func(t *testing.T) {
+		_, err := NewValidator(&config.Config{Validation: config.ValidationConfig{
+			ResponseMode: "drop",
+			Routes:       []config.ValidationRouteConfig{{Path: "/user/profile", ResponseSchema: schemaPath}},
+		}})
+
+		assert.Error(t, err)
+	})
+}
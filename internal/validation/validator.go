@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"bytes"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// routeRules are the body size limit and the schemas of a route, any of them may be unset
+type routeRules struct {
+	maxBodySize    int64
+	schema         *Schema
+	strict         bool
+	responseSchema *Schema
+	responseMode   string
+}
+
+// Validator rejects the request bodies beyond their size limit and the JSON bodies violating the schema
+// of their route before they reach the handlers, so the upstreams only receive well formed requests
+type Validator struct {
+	maxBodySize int64
+	routes      map[string]*routeRules
+}
+
+// NewValidator creates the validator of the configuration, loading the schemas of the routes
+func NewValidator(configurations *config.Config) (*Validator, error) {
+	validator := &Validator{
+		maxBodySize: configurations.Validation.MaxBodySize,
+		routes:      map[string]*routeRules{},
+	}
+	for _, route := range configurations.Validation.Routes {
+		rules := &routeRules{maxBodySize: route.MaxBodySize, strict: route.Strict, responseMode: route.ResponseMode}
+		if rules.responseMode == "" {
+			rules.responseMode = configurations.Validation.ResponseMode
+		}
+		if rules.responseMode == "" {
+			rules.responseMode = ResponseModeLog
+		}
+		if rules.responseMode != ResponseModeLog && rules.responseMode != ResponseModeFail {
+			return nil, fmt.Errorf("Unknown response validation mode %s of the route %s %s", rules.responseMode, route.Method, route.Path)
+		}
+		if route.Strict && route.Schema == "" {
+			return nil, fmt.Errorf("The strict route %s %s needs a schema declaring its fields", route.Method, route.Path)
+		}
+		if route.Schema != "" {
+			schema, err := LoadSchema(route.Schema)
+			if err != nil {
+				return nil, err
+			}
+			rules.schema = schema
+		}
+		if route.ResponseSchema != "" {
+			responseSchema, err := LoadSchema(route.ResponseSchema)
+			if err != nil {
+				return nil, err
+			}
+			rules.responseSchema = responseSchema
+		}
+		validator.routes[routeKey(route.Method, route.Path)] = rules
+	}
+	return validator, nil
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// rules returns the rules of the route for the method, else those of the route for any method
+func (validator *Validator) rules(method, path string) *routeRules {
+	if rules, exists := validator.routes[routeKey(method, path)]; exists {
+		return rules
+	}
+	return validator.routes[routeKey("", path)]
+}
+
+// Middleware bounds the body of the request and validates it against the schema of its route, then validates
+// the response against the response schema of the route
+func (validator *Validator) Middleware(ctx *gin.Context) {
+	rules := validator.rules(ctx.Request.Method, ctx.FullPath())
+	maxBodySize := validator.maxBodySize
+	if rules != nil && rules.maxBodySize > 0 {
+		maxBodySize = rules.maxBodySize
+	}
+	if maxBodySize > 0 {
+		if ctx.Request.ContentLength > maxBodySize {
+			errors.Abort(ctx, errors.PayloadTooLarge)
+			return
+		}
+		// Bodies without a length are cut once they exceed the limit while the handlers read them
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBodySize)
+	}
+	if rules == nil {
+		return
+	}
+	if rules.schema != nil && !validator.validateRequest(ctx, rules) {
+		return
+	}
+	if rules.responseSchema != nil {
+		validator.validateResponse(ctx, rules)
+	}
+}
+
+// validateRequest validates the body of the request, answering its field violations when it is invalid. The
+// unknown fields of the strict routes are listed first, they are likely the typos of the missing fields
+func (validator *Validator) validateRequest(ctx *gin.Context, rules *routeRules) bool {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if stdErrors.As(err, &maxBytesError) {
+			errors.Abort(ctx, errors.PayloadTooLarge)
+			return false
+		}
+		errors.AbortWithError(ctx, errors.InvalidRequestBody, err)
+		return false
+	}
+	// The handlers bind the body again once it is validated
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	violations := []FieldError{}
+	if rules.strict {
+		violations = rules.schema.UnknownFields(body)
+	}
+	violations = append(violations, rules.schema.ValidateDocument(body)...)
+	if len(violations) > 0 {
+		details := make([]errors.Detail, 0, len(violations))
+		for _, violation := range violations {
+			details = append(details, errors.FieldViolation(violation.Field, violation.Error))
+		}
+		errors.Abort(ctx, errors.InvalidRequestBody, details...)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package versioning
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Headers describing the client app
+const (
+	AppVersionHeader  = "X-App-Version"
+	AppPlatformHeader = "X-App-Platform"
+)
+
+type versionContextKey struct{}
+
+// ContextWithVersion returns a context carrying the app version of the request
+func ContextWithVersion(ctx context.Context, version Version) context.Context {
+	return context.WithValue(ctx, versionContextKey{}, version)
+}
+
+// VersionFromContext returns the app version of the request or nil when the client sent none
+func VersionFromContext(ctx context.Context) *Version {
+	version, ok := ctx.Value(versionContextKey{}).(Version)
+	if !ok {
+		return nil
+	}
+	return &version
+}
+
+// Enforcer rejects app versions below the configured minimum of their platform
+type Enforcer struct {
+	minimumVersion   *Version
+	minimumVersions  map[string]Version
+	upgradeURL       string
+	platformUpgrades map[string]string
+}
+
+// NewEnforcer creates the minimum version enforcer of the configuration
+func NewEnforcer(configurations *config.Config) (*Enforcer, error) {
+	enforcer := &Enforcer{
+		minimumVersions:  make(map[string]Version),
+		upgradeURL:       configurations.Versioning.UpgradeURL,
+		platformUpgrades: make(map[string]string),
+	}
+	if configurations.Versioning.MinimumVersion != "" {
+		minimumVersion, err := ParseVersion(configurations.Versioning.MinimumVersion)
+		if err != nil {
+			return nil, err
+		}
+		enforcer.minimumVersion = minimumVersion
+	}
+	for platform, value := range configurations.Versioning.MinimumVersions {
+		minimumVersion, err := ParseVersion(value)
+		if err != nil {
+			return nil, err
+		}
+		enforcer.minimumVersions[strings.ToLower(platform)] = *minimumVersion
+	}
+	for platform, upgradeURL := range configurations.Versioning.UpgradeURLs {
+		enforcer.platformUpgrades[strings.ToLower(platform)] = upgradeURL
+	}
+	return enforcer, nil
+}
+
+// Middleware records the app version of the request and requires an upgrade below the minimum
+func (enforcer *Enforcer) Middleware(ctx *gin.Context) {
+	versionHeader := ctx.GetHeader(AppVersionHeader)
+	if versionHeader == "" {
+		ctx.Next()
+		return
+	}
+	version, err := ParseVersion(versionHeader)
+	if err != nil {
+		errors.Abort(ctx, errors.InvalidArgument, errors.FieldViolation(AppVersionHeader, err.Error()))
+		return
+	}
+	platform := strings.ToLower(ctx.GetHeader(AppPlatformHeader))
+
+	minimumVersion := enforcer.minimumVersion
+	if platformMinimum, exists := enforcer.minimumVersions[platform]; exists {
+		minimumVersion = &platformMinimum
+	}
+	if minimumVersion != nil && version.Compare(*minimumVersion) < 0 {
+		if logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context()); err == nil {
+			logger.Warn("The app version is below the minimum supported version")
+		}
+		upgradeURL := enforcer.upgradeURL
+		if platformUpgradeURL, exists := enforcer.platformUpgrades[platform]; exists {
+			upgradeURL = platformUpgradeURL
+		}
+		metadata := map[string]string{
+			"current_version": version.String(),
+			"minimum_version": minimumVersion.String(),
+		}
+		if upgradeURL != "" {
+			metadata["upgrade_url"] = upgradeURL
+		}
+		errors.Abort(ctx, errors.UpgradeRequired, errors.Metadata(errors.HelpDetail, metadata))
+		return
+	}
+
+	ctx.Request = ctx.Request.WithContext(ContextWithVersion(ctx.Request.Context(), *version))
+	ctx.Next()
+}
@@ -0,0 +1,82 @@
+package versioning
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/unixsocket"
+)
+
+type upstreamRoute struct {
+	constraint *Constraint
+	connection grpc.ClientConnInterface
+}
+
+// RoutedConnection sends the calls of some app versions to alternate upstreams during staged migrations
+type RoutedConnection struct {
+	defaultConnection grpc.ClientConnInterface
+	routes            []upstreamRoute
+}
+
+var _ grpc.ClientConnInterface = &RoutedConnection{}
+
+// Invoke calls the upstream of the app version of the context
+func (connection *RoutedConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	return connection.upstream(ctx).Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream opens a stream with the upstream of the app version of the context
+func (connection *RoutedConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return connection.upstream(ctx).NewStream(ctx, desc, method, opts...)
+}
+
+func (connection *RoutedConnection) upstream(ctx context.Context) grpc.ClientConnInterface {
+	version := VersionFromContext(ctx)
+	if version == nil {
+		return connection.defaultConnection
+	}
+	for _, route := range connection.routes {
+		if route.constraint.Matches(*version) {
+			return route.connection
+		}
+	}
+	return connection.defaultConnection
+}
+
+// RouteConnection wraps the connection of the service with the alternate upstreams configured for it
+func RouteConnection(
+	service string,
+	connection grpc.ClientConnInterface,
+	configurations *config.Config,
+	tlsEnabled bool,
+) (grpc.ClientConnInterface, error) {
+	routes := []upstreamRoute{}
+	for _, upstream := range configurations.Versioning.Upstreams {
+		if upstream.Service != service {
+			continue
+		}
+		constraint, err := ParseConstraint(upstream.Versions)
+		if err != nil {
+			return nil, err
+		}
+		alternateAddress := fmt.Sprintf("%s:%s", upstream.Host, upstream.Port)
+		alternateTLSEnabled := tlsEnabled
+		if upstream.Socket != "" {
+			alternateAddress, alternateTLSEnabled = unixsocket.Target(upstream.Socket), false
+		}
+		fmt.Println("Routing", service, "versions", upstream.Versions, "to", alternateAddress)
+		alternateConnection, err := egress.CreateGRPCConnection(alternateAddress, alternateTLSEnabled)
+		if err != nil {
+			return nil, fmt.Errorf("Could not connect to alternate %s upstream: %v", service, err)
+		}
+		routes = append(routes, upstreamRoute{constraint: constraint, connection: alternateConnection})
+	}
+	if len(routes) == 0 {
+		return connection, nil
+	}
+	return &RoutedConnection{defaultConnection: connection, routes: routes}, nil
+}
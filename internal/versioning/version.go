@@ -0,0 +1,109 @@
+package versioning
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic app version, pre-release and build suffixes are ignored
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses versions like "2", "2.1" or "v2.1.3-beta"
+func ParseVersion(value string) (*Version, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if index := strings.IndexAny(trimmed, "-+ "); index >= 0 {
+		trimmed = trimmed[:index]
+	}
+	parts := strings.Split(trimmed, ".")
+	if trimmed == "" || len(parts) > 3 {
+		return nil, fmt.Errorf("Invalid version: %s", value)
+	}
+	numbers := [3]int{}
+	for index, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("Invalid version: %s", value)
+		}
+		numbers[index] = number
+	}
+	return &Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// Compare returns -1, 0 or 1 when the version is lower, equal or greater than the other
+func (version Version) Compare(other Version) int {
+	for _, difference := range []int{version.Major - other.Major, version.Minor - other.Minor, version.Patch - other.Patch} {
+		if difference < 0 {
+			return -1
+		}
+		if difference > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (version Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
+}
+
+type condition struct {
+	operator string
+	version  Version
+}
+
+// Constraint is a set of comparisons every version has to satisfy, like ">=2.0.0, <3.0.0"
+type Constraint struct {
+	conditions []condition
+}
+
+// ParseConstraint parses comma separated comparisons using =, !=, <, <=, > and >=
+func ParseConstraint(value string) (*Constraint, error) {
+	constraint := &Constraint{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		operator := "="
+		for _, candidate := range []string{">=", "<=", "!=", ">", "<", "="} {
+			if strings.HasPrefix(part, candidate) {
+				operator = candidate
+				break
+			}
+		}
+		version, err := ParseVersion(strings.TrimPrefix(part, operator))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid version constraint %s: %v", value, err)
+		}
+		constraint.conditions = append(constraint.conditions, condition{operator: operator, version: *version})
+	}
+	return constraint, nil
+}
+
+// Matches tells whether the version satisfies every comparison of the constraint
+func (constraint *Constraint) Matches(version Version) bool {
+	for _, condition := range constraint.conditions {
+		comparison := version.Compare(condition.version)
+		var matches bool
+		switch condition.operator {
+		case ">=":
+			matches = comparison >= 0
+		case "<=":
+			matches = comparison <= 0
+		case ">":
+			matches = comparison > 0
+		case "<":
+			matches = comparison < 0
+		case "!=":
+			matches = comparison != 0
+		default:
+			matches = comparison == 0
+		}
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
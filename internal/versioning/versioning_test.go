@@ -0,0 +1,176 @@
+package versioning
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type fakeConnection struct {
+	name    string
+	invoked *string
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	*connection.invoked = connection.name
+	return nil
+}
+
+func (connection *fakeConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	*connection.invoked = connection.name
+	return nil, nil
+}
+
+func newTestRouter(t *testing.T, versioning config.VersioningConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	enforcer, err := NewEnforcer(&config.Config{Versioning: versioning})
+	assert.NoError(t, err)
+	router := gin.New()
+	router.Use(enforcer.Middleware)
+	router.GET("/resource", func(ctx *gin.Context) {
+		version := VersionFromContext(ctx.Request.Context())
+		if version == nil {
+			ctx.String(http.StatusOK, "none")
+			return
+		}
+		ctx.String(http.StatusOK, version.String())
+	})
+	return router
+}
+
+func TestVersion(t *testing.T) {
+	t.Run("ParseVersion_Should_Complete_Missing_Parts_And_Ignore_Suffixes", func(t *testing.T) {
+		version, err := ParseVersion("v2.1-beta.3")
+
+		assert.NoError(t, err)
+		assert.Equal(t, Version{Major: 2, Minor: 1}, *version)
+	})
+
+	t.Run("ParseVersion_Should_Reject_Invalid_Versions", func(t *testing.T) {
+		for _, value := range []string{"", "a.b", "1.2.3.4", "1.-2"} {
+			_, err := ParseVersion(value)
+			assert.Error(t, err, value)
+		}
+	})
+
+	t.Run("Compare_Should_Order_Numerically", func(t *testing.T) {
+		assert.Equal(t, -1, Version{Major: 1, Minor: 9}.Compare(Version{Major: 1, Minor: 10}))
+		assert.Equal(t, 0, Version{Major: 1}.Compare(Version{Major: 1}))
+		assert.Equal(t, 1, Version{Major: 2}.Compare(Version{Major: 1, Minor: 99}))
+	})
+
+	t.Run("Constraint_Should_Match_Every_Comparison", func(t *testing.T) {
+		constraint, err := ParseConstraint(">=2.0.0, <2.3")
+
+		assert.NoError(t, err)
+		assert.True(t, constraint.Matches(Version{Major: 2, Minor: 2, Patch: 9}))
+		assert.False(t, constraint.Matches(Version{Major: 2, Minor: 3}))
+		assert.False(t, constraint.Matches(Version{Major: 1, Minor: 9}))
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	versioningConfig := config.VersioningConfig{
+		MinimumVersion:  "2.0.0",
+		MinimumVersions: map[string]string{"ios": "2.4.0"},
+		UpgradeURL:      "https://example.com/download",
+		UpgradeURLs:     map[string]string{"ios": "https://apps.apple.com/app/id1"},
+	}
+
+	t.Run("Middleware_Should_Allow_Requests_Without_Version", func(t *testing.T) {
+		router := newTestRouter(t, versioningConfig)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resource", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "none", w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Store_Supported_Version", func(t *testing.T) {
+		router := newTestRouter(t, versioningConfig)
+		request := httptest.NewRequest(http.MethodGet, "/resource", nil)
+		request.Header.Set(AppVersionHeader, "2.1")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2.1.0", w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Require_Upgrade_Below_Platform_Minimum", func(t *testing.T) {
+		router := newTestRouter(t, versioningConfig)
+		request := httptest.NewRequest(http.MethodGet, "/resource", nil)
+		request.Header.Set(AppVersionHeader, "2.1.0")
+		request.Header.Set(AppPlatformHeader, "iOS")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusUpgradeRequired, w.Code)
+		assert.JSONEq(t, `{"error": {
+			"code": "upgrade_required",
+			"message": "The app version is no longer supported, upgrade it",
+			"details": [{"type": "help", "metadata": {
+				"current_version": "2.1.0",
+				"minimum_version": "2.4.0",
+				"upgrade_url": "https://apps.apple.com/app/id1"
+			}}]
+		}}`, w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Reject_Invalid_Version", func(t *testing.T) {
+		router := newTestRouter(t, versioningConfig)
+		request := httptest.NewRequest(http.MethodGet, "/resource", nil)
+		request.Header.Set(AppVersionHeader, "latest")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRoutedConnection(t *testing.T) {
+	invoked := ""
+	constraint, _ := ParseConstraint("<2.0.0")
+	connection := &RoutedConnection{
+		defaultConnection: &fakeConnection{name: "default", invoked: &invoked},
+		routes: []upstreamRoute{
+			{constraint: constraint, connection: &fakeConnection{name: "legacy", invoked: &invoked}},
+		},
+	}
+
+	t.Run("Invoke_Should_Route_Matching_Versions_To_Alternate_Upstream", func(t *testing.T) {
+		ctx := ContextWithVersion(context.Background(), Version{Major: 1, Minor: 8})
+
+		assert.NoError(t, connection.Invoke(ctx, "/method", nil, nil))
+		assert.Equal(t, "legacy", invoked)
+	})
+
+	t.Run("Invoke_Should_Use_Default_Upstream_Otherwise", func(t *testing.T) {
+		assert.NoError(t, connection.Invoke(context.Background(), "/method", nil, nil))
+		assert.Equal(t, "default", invoked)
+
+		_, err := connection.NewStream(ContextWithVersion(context.Background(), Version{Major: 2}), &grpc.StreamDesc{}, "/method")
+		assert.NoError(t, err)
+		assert.Equal(t, "default", invoked)
+	})
+
+	t.Run("RouteConnection_Should_Return_Connection_Without_Routes", func(t *testing.T) {
+		defaultConnection := &fakeConnection{name: "default", invoked: &invoked}
+
+		routed, err := RouteConnection("search", defaultConnection, &config.Config{}, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, grpc.ClientConnInterface(defaultConnection), routed)
+	})
+}
@@ -0,0 +1,366 @@
+package wasmfilter
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// The exports called on the requests and the responses, a result other than 0 rejects the request
+const (
+	OnRequest  = "on_request"
+	OnResponse = "on_response"
+)
+
+// module is a module compiled from the file of a filter
+type module struct {
+	compiled   wazero.CompiledModule
+	onRequest  bool
+	onResponse bool
+}
+
+// filter is a configured filter with the last module compiled from its file
+type filter struct {
+	name       string
+	file       string
+	pathPrefix string
+	module     atomic.Pointer[module]
+	modifiedAt time.Time
+}
+
+// Filters run the WebAssembly filters on the requests and the responses with wazero, every request gets fresh
+// instances of its filters so the filters cannot leak state between requests. The runtime bounds the memory of
+// the instances and closes them once the context of a call is done, stopping the filters looping past the timeout
+type Filters struct {
+	filters        []*filter
+	runtime        wazero.Runtime
+	timeout        time.Duration
+	failOpen       bool
+	reloadInterval time.Duration
+	reloadMtx      sync.Mutex
+}
+
+// NewFilters creates the filters of the configuration, compiling their modules
+func NewFilters(configurations *config.Config) (*Filters, error) {
+	filtersConfig := configurations.WASMFilters
+	if filtersConfig.Timeout <= 0 {
+		return nil, fmt.Errorf("The WASM filters need a timeout")
+	}
+	runtimeConfig := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if filtersConfig.MaxMemoryPages > 0 {
+		runtimeConfig = runtimeConfig.WithMemoryLimitPages(filtersConfig.MaxMemoryPages)
+	}
+	ctx := context.Background()
+	filters := &Filters{
+		runtime:        wazero.NewRuntimeWithConfig(ctx, runtimeConfig),
+		timeout:        filtersConfig.Timeout,
+		failOpen:       filtersConfig.FailOpen,
+		reloadInterval: filtersConfig.ReloadInterval,
+	}
+	if err := instantiateHostModule(ctx, filters.runtime); err != nil {
+		filters.runtime.Close(ctx)
+		return nil, fmt.Errorf("Could not instantiate the host functions of the WASM filters: %v", err)
+	}
+	for _, filterConfig := range filtersConfig.Filters {
+		loaded := &filter{name: filterConfig.Name, file: filterConfig.File, pathPrefix: filterConfig.PathPrefix}
+		if loaded.name == "" {
+			loaded.name = filterConfig.File
+		}
+		if err := filters.load(loaded); err != nil {
+			filters.runtime.Close(ctx)
+			return nil, err
+		}
+		filters.filters = append(filters.filters, loaded)
+	}
+	return filters, nil
+}
+
+// Close closes the runtime with the modules of the filters
+func (filters *Filters) Close(ctx context.Context) error {
+	return filters.runtime.Close(ctx)
+}
+
+// load compiles the file of the filter when it changed, checking the module can be instantiated
+func (filters *Filters) load(loaded *filter) error {
+	info, err := os.Stat(loaded.file)
+	if err != nil {
+		return fmt.Errorf("Could not read the WASM filter %s: %v", loaded.name, err)
+	}
+	if loaded.module.Load() != nil && info.ModTime().Equal(loaded.modifiedAt) {
+		return nil
+	}
+	binaryModule, err := os.ReadFile(loaded.file)
+	if err != nil {
+		return fmt.Errorf("Could not read the WASM filter %s: %v", loaded.name, err)
+	}
+	ctx := context.Background()
+	compiled, err := filters.runtime.CompileModule(ctx, binaryModule)
+	if err != nil {
+		return fmt.Errorf("Could not compile the WASM filter %s: %v", loaded.name, err)
+	}
+	exports := compiled.ExportedFunctions()
+	_, hasOnRequest := exports[OnRequest]
+	_, hasOnResponse := exports[OnResponse]
+	if !hasOnRequest && !hasOnResponse {
+		compiled.Close(ctx)
+		return fmt.Errorf("The WASM filter %s exports neither %s nor %s", loaded.name, OnRequest, OnResponse)
+	}
+	instantiated, err := filters.runtime.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		compiled.Close(ctx)
+		return fmt.Errorf("Could not instantiate the WASM filter %s: %v", loaded.name, err)
+	}
+	instantiated.Close(ctx)
+	// The previous module is released with the runtime, the requests in flight may still be instantiating it
+	loaded.module.Store(&module{compiled: compiled, onRequest: hasOnRequest, onResponse: hasOnResponse})
+	loaded.modifiedAt = info.ModTime()
+	return nil
+}
+
+// Reload loads the filter files changed since they were loaded, a filter keeps its module when its file is invalid
+func (filters *Filters) Reload() error {
+	filters.reloadMtx.Lock()
+	defer filters.reloadMtx.Unlock()
+	var errs []error
+	for _, loaded := range filters.filters {
+		if err := filters.load(loaded); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stdErrors.Join(errs...)
+}
+
+// Job reloads the changed filters on every reload interval
+func (filters *Filters) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "wasm_filters_reload",
+		Schedule: scheduler.Every(filters.reloadInterval),
+		Run: func(ctx context.Context, now time.Time) error {
+			return filters.Reload()
+		},
+	}
+}
+
+// instance is a filter instantiated for a request
+type instance struct {
+	filter   *filter
+	module   *module
+	instance api.Module
+}
+
+// Middleware runs the on_request exports of the filters of the path before the handlers
+// and their on_response exports before the response headers are written
+func (filters *Filters) Middleware(ctx *gin.Context) {
+	exchange := &exchange{ctx: ctx, filters: filters}
+	defer exchange.close()
+	for _, loaded := range filters.filters {
+		if !strings.HasPrefix(ctx.Request.URL.Path, loaded.pathPrefix) {
+			continue
+		}
+		instantiated, err := filters.instantiate(ctx, loaded)
+		if err != nil {
+			if filters.fail(ctx, loaded, err) {
+				return
+			}
+			continue
+		}
+		exchange.instances = append(exchange.instances, instantiated)
+	}
+	if len(exchange.instances) == 0 {
+		ctx.Next()
+		return
+	}
+
+	for _, instantiated := range exchange.instances {
+		if !instantiated.module.onRequest {
+			continue
+		}
+		sent, verdict, err := exchange.invoke(instantiated, OnRequest)
+		if err != nil {
+			if filters.fail(ctx, instantiated.filter, err) {
+				return
+			}
+			continue
+		}
+		if sent != nil {
+			ctx.Status(sent.status)
+			ctx.Writer.Write(sent.body)
+			ctx.Abort()
+			return
+		}
+		if verdict != 0 {
+			errors.Abort(ctx, errors.FilterRejected)
+			return
+		}
+	}
+
+	writer := &filterWriter{ResponseWriter: ctx.Writer, exchange: exchange}
+	ctx.Writer = writer
+	ctx.Next()
+	// Responses without a body are written by gin after the handlers, bypassing the wrapper
+	writer.filter()
+}
+
+func (filters *Filters) instantiate(ctx *gin.Context, loaded *filter) (*instance, error) {
+	callCtx, cancel := context.WithTimeout(ctx.Request.Context(), filters.timeout)
+	defer cancel()
+	loadedModule := loaded.module.Load()
+	instantiated, err := filters.runtime.InstantiateModule(callCtx, loadedModule.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return nil, err
+	}
+	return &instance{filter: loaded, module: loadedModule, instance: instantiated}, nil
+}
+
+// fail logs the failure of the filter and answers 500 unless the filters fail open, telling whether it answered
+func (filters *Filters) fail(ctx *gin.Context, failed *filter, err error) bool {
+	if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil {
+		logger.Error(err, fmt.Sprintf("The WASM filter %s failed", failed.name))
+	}
+	if filters.failOpen {
+		return false
+	}
+	errors.AbortWithError(ctx, errors.InternalError, err)
+	return true
+}
+
+// response is a response sent by a filter in place of the handlers
+type response struct {
+	status int
+	body   []byte
+}
+
+// exchange is the state of the filters of a request, shared with the host functions through the context
+type exchange struct {
+	ctx       *gin.Context
+	filters   *Filters
+	instances []*instance
+	current   *filter
+	writer    *filterWriter
+	response  *response
+}
+
+type exchangeKey struct{}
+
+// invoke calls the export of the instance, returning the response it sent and its result. The runtime closes
+// the instance when the call outlives the timeout
+func (exchange *exchange) invoke(instantiated *instance, name string) (*response, uint32, error) {
+	callCtx, cancel := context.WithTimeout(exchange.ctx.Request.Context(), exchange.filters.timeout)
+	defer cancel()
+	exchange.current, exchange.response = instantiated.filter, nil
+	results, err := instantiated.instance.ExportedFunction(name).Call(context.WithValue(callCtx, exchangeKey{}, exchange))
+	if err != nil {
+		return nil, 0, err
+	}
+	var verdict uint32
+	if len(results) > 0 {
+		verdict = uint32(results[0])
+	}
+	return exchange.response, verdict, nil
+}
+
+// close releases the instances of the request
+func (exchange *exchange) close() {
+	for _, instantiated := range exchange.instances {
+		instantiated.instance.Close(context.Background())
+	}
+}
+
+// filterWriter runs the on_response exports once, before the status line is written,
+// the handlers' writes are dropped when a filter replaces the response
+type filterWriter struct {
+	gin.ResponseWriter
+	exchange *exchange
+	filtered bool
+	replaced bool
+}
+
+func (writer *filterWriter) filter() {
+	if writer.filtered || writer.ResponseWriter.Written() {
+		return
+	}
+	writer.filtered = true
+	writer.exchange.writer = writer
+	ctx, filters := writer.exchange.ctx, writer.exchange.filters
+	for _, instantiated := range writer.exchange.instances {
+		if !instantiated.module.onResponse {
+			continue
+		}
+		replacement, verdict, err := writer.exchange.invoke(instantiated, OnResponse)
+		if err != nil {
+			if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil {
+				logger.Error(err, fmt.Sprintf("The WASM filter %s failed", instantiated.filter.name))
+			}
+			if filters.failOpen {
+				continue
+			}
+			replacement = &response{status: http.StatusInternalServerError}
+		} else if replacement == nil && verdict != 0 {
+			definition := errors.Lookup(errors.FilterRejected)
+			body, _ := json.Marshal(errors.NewResponse(ctx, errors.FilterRejected, definition.Message))
+			replacement = &response{status: definition.Status, body: body}
+			writer.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+		}
+		if replacement != nil {
+			writer.replace(replacement)
+			return
+		}
+	}
+}
+
+func (writer *filterWriter) replace(replacement *response) {
+	writer.replaced = true
+	writer.ResponseWriter.Header().Del("Content-Length")
+	writer.ResponseWriter.WriteHeader(replacement.status)
+	writer.ResponseWriter.Write(replacement.body)
+}
+
+func (writer *filterWriter) WriteHeader(status int) {
+	if !writer.replaced {
+		writer.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (writer *filterWriter) WriteHeaderNow() {
+	writer.filter()
+	if !writer.replaced {
+		writer.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (writer *filterWriter) Write(data []byte) (int, error) {
+	writer.filter()
+	if writer.replaced {
+		return len(data), nil
+	}
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *filterWriter) WriteString(data string) (int, error) {
+	writer.filter()
+	if writer.replaced {
+		return len(data), nil
+	}
+	return writer.ResponseWriter.WriteString(data)
+}
+
+func (writer *filterWriter) Flush() {
+	writer.filter()
+	writer.ResponseWriter.Flush()
+}
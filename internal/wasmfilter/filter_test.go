@@ -0,0 +1,197 @@
+package wasmfilter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newFilters(t *testing.T, filtersConfig config.WASMFiltersConfig) *Filters {
+	if filtersConfig.Timeout == 0 {
+		filtersConfig.Timeout = time.Second
+	}
+	filters, err := NewFilters(&config.Config{WASMFilters: filtersConfig})
+	assert.NoError(t, err)
+	t.Cleanup(func() { filters.Close(context.Background()) })
+	return filters
+}
+
+func newFilterRouter(filters *Filters, logger commonLogger.Loggerer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, logger))
+	}, filters.Middleware)
+	router.GET("/api/v1/user", func(ctx *gin.Context) {
+		if ctx.GetHeader("X-Deny-Response") != "" {
+			ctx.Header("X-Deny", "true")
+		}
+		ctx.String(http.StatusCreated, ctx.GetHeader("X-Filtered"))
+	})
+	router.GET("/public", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	return router
+}
+
+func TestFilters(t *testing.T) {
+	filterConfig := config.WASMFilterConfig{Name: "test", File: "testdata/filter.wasm", PathPrefix: "/api/"}
+
+	t.Run("Middleware_Should_Run_The_Filter_On_The_Request_And_The_Response", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		router := newFilterRouter(newFilters(t, config.WASMFiltersConfig{Filters: []config.WASMFilterConfig{filterConfig}}), loggerMock)
+
+		loggerMock.EXPECT().Info("WASM filter test: request")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/user", nil))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "yes", w.Body.String())
+		assert.Equal(t, "on", w.Header().Get("X-Wasm"))
+		assert.Equal(t, "201", w.Header().Get("X-Upstream-Status"))
+	})
+
+	t.Run("Middleware_Should_Reject_The_Request", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		router := newFilterRouter(newFilters(t, config.WASMFiltersConfig{Filters: []config.WASMFilterConfig{filterConfig}}), commonLoggerMock.NewMockLoggerer(controller))
+
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/user", nil)
+		request.Header.Set("X-Block", "true")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"filter_rejected","message":"The request was rejected by a filter"}}`, w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Send_The_Response_Of_The_Filter", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		router := newFilterRouter(newFilters(t, config.WASMFiltersConfig{Filters: []config.WASMFilterConfig{filterConfig}}), commonLoggerMock.NewMockLoggerer(controller))
+
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/user", nil)
+		request.Header.Set("X-Teapot", "true")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusTeapot, w.Code)
+		assert.Equal(t, "short and stout", w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Replace_The_Rejected_Response", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		router := newFilterRouter(newFilters(t, config.WASMFiltersConfig{Filters: []config.WASMFilterConfig{filterConfig}}), loggerMock)
+
+		loggerMock.EXPECT().Info("WASM filter test: request")
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/user", nil)
+		request.Header.Set("X-Deny-Response", "true")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"filter_rejected","message":"The request was rejected by a filter"}}`, w.Body.String())
+	})
+
+	t.Run("Middleware_Should_Skip_The_Other_Paths", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		router := newFilterRouter(newFilters(t, config.WASMFiltersConfig{Filters: []config.WASMFilterConfig{filterConfig}}), commonLoggerMock.NewMockLoggerer(controller))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("X-Wasm"))
+	})
+
+	t.Run("Middleware_Should_Stop_The_Filters_After_The_Timeout", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		filters := newFilters(t, config.WASMFiltersConfig{
+			Timeout: 10 * time.Millisecond,
+			Filters: []config.WASMFilterConfig{{Name: "spin", File: "testdata/spin.wasm"}},
+		})
+		router := newFilterRouter(filters, loggerMock)
+
+		loggerMock.EXPECT().Error(gomock.Any(), "The WASM filter spin failed")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Middleware_Should_Fail_Open_After_The_Timeout", func(t *testing.T) {
+		controller := gomock.NewController(t)
+		defer controller.Finish()
+		loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+		filters := newFilters(t, config.WASMFiltersConfig{
+			Timeout:  10 * time.Millisecond,
+			FailOpen: true,
+			Filters:  []config.WASMFilterConfig{{Name: "spin", File: "testdata/spin.wasm"}},
+		})
+		router := newFilterRouter(filters, loggerMock)
+
+		loggerMock.EXPECT().Error(gomock.Any(), "The WASM filter spin failed")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("NewFilters_Should_Reject_Modules_Without_Filters", func(t *testing.T) {
+		_, err := NewFilters(&config.Config{WASMFilters: config.WASMFiltersConfig{
+			Timeout: time.Second,
+			Filters: []config.WASMFilterConfig{{Name: "empty", File: "testdata/empty.wasm"}},
+		}})
+
+		assert.EqualError(t, err, "The WASM filter empty exports neither on_request nor on_response")
+	})
+
+	t.Run("NewFilters_Should_Require_A_Timeout", func(t *testing.T) {
+		_, err := NewFilters(&config.Config{WASMFilters: config.WASMFiltersConfig{
+			Filters: []config.WASMFilterConfig{filterConfig},
+		}})
+
+		assert.EqualError(t, err, "The WASM filters need a timeout")
+	})
+
+	t.Run("Reload_Should_Load_The_Changed_Files_And_Keep_The_Valid_Modules", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "filter.wasm")
+		spin, _ := os.ReadFile("testdata/spin.wasm")
+		assert.NoError(t, os.WriteFile(file, spin, 0o600))
+		filters := newFilters(t, config.WASMFiltersConfig{
+			FailOpen:       true,
+			Filters:        []config.WASMFilterConfig{{Name: "test", File: file}},
+			ReloadInterval: time.Minute,
+		})
+		first := filters.filters[0].module.Load()
+
+		assert.NoError(t, os.WriteFile(file, []byte("broken"), 0o600))
+		assert.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+		assert.Error(t, filters.Reload())
+		assert.True(t, first == filters.filters[0].module.Load())
+
+		filter, _ := os.ReadFile("testdata/filter.wasm")
+		assert.NoError(t, os.WriteFile(file, filter, 0o600))
+		assert.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(2*time.Second)))
+		assert.NoError(t, filters.Reload())
+		assert.True(t, first != filters.filters[0].module.Load())
+		assert.Equal(t, "wasm_filters_reload", filters.Job().Name)
+	})
+}
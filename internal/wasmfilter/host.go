@@ -0,0 +1,142 @@
+package wasmfilter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// HostModule is the module of the functions the gateway offers to the filters
+const HostModule = "gateway"
+
+// Pseudo headers read by the filters, they cannot be set
+const (
+	methodHeader = ":method"
+	pathHeader   = ":path"
+	statusHeader = ":status"
+)
+
+// absent is the length returned for a missing header, -1 as an i32
+const absent = uint32(0xFFFFFFFF)
+
+// instantiateHostModule instantiates the gateway module in the runtime, the headers are those of the request
+// in on_request and those of the response in on_response:
+//
+//	get_header(name_ptr, name_len, value_ptr, value_cap) -> length, -1 when missing, the value is cut to the capacity
+//	set_header(name_ptr, name_len, value_ptr, value_len), an empty value removes the header
+//	send_response(status, body_ptr, body_len), answers the request instead of the handlers
+//	log(message_ptr, message_len)
+//
+// The errors of the host functions are raised as panics, which wazero turns into the error of the call
+func instantiateHostModule(ctx context.Context, runtime wazero.Runtime) error {
+	_, err := runtime.NewHostModuleBuilder(HostModule).
+		NewFunctionBuilder().WithFunc(getHeader).Export("get_header").
+		NewFunctionBuilder().WithFunc(setHeader).Export("set_header").
+		NewFunctionBuilder().WithFunc(sendResponse).Export("send_response").
+		NewFunctionBuilder().WithFunc(log).Export("log").
+		Instantiate(ctx)
+	return err
+}
+
+func exchangeFromContext(ctx context.Context) *exchange {
+	exchange, ok := ctx.Value(exchangeKey{}).(*exchange)
+	if !ok {
+		panic(fmt.Errorf("The host functions are only available to the filters of a request"))
+	}
+	return exchange
+}
+
+// read copies the bytes of the memory of the filter, the memory is reused by the next calls
+func read(module api.Module, offset, length uint32) []byte {
+	memory := module.Memory()
+	if memory == nil {
+		panic(fmt.Errorf("The filter has no memory"))
+	}
+	data, ok := memory.Read(offset, length)
+	if !ok {
+		panic(fmt.Errorf("The range %d+%d is out of the memory of the filter", offset, length))
+	}
+	return append([]byte{}, data...)
+}
+
+func write(module api.Module, offset uint32, data []byte) {
+	memory := module.Memory()
+	if memory == nil {
+		panic(fmt.Errorf("The filter has no memory"))
+	}
+	if !memory.Write(offset, data) {
+		panic(fmt.Errorf("The range %d+%d is out of the memory of the filter", offset, len(data)))
+	}
+}
+
+// headers returns the headers the filter works on in its current phase
+func (exchange *exchange) headers() http.Header {
+	if exchange.writer != nil {
+		return exchange.writer.ResponseWriter.Header()
+	}
+	return exchange.ctx.Request.Header
+}
+
+func getHeader(ctx context.Context, module api.Module, namePtr, nameLen, valuePtr, valueCap uint32) uint32 {
+	exchange := exchangeFromContext(ctx)
+	name := read(module, namePtr, nameLen)
+	var value string
+	switch string(name) {
+	case methodHeader:
+		value = exchange.ctx.Request.Method
+	case pathHeader:
+		value = exchange.ctx.Request.URL.RequestURI()
+	case statusHeader:
+		if exchange.writer == nil {
+			return absent
+		}
+		value = strconv.Itoa(exchange.writer.ResponseWriter.Status())
+	default:
+		values := exchange.headers().Values(string(name))
+		if len(values) == 0 {
+			return absent
+		}
+		value = values[0]
+	}
+	written := value
+	if len(written) > int(valueCap) {
+		written = written[:valueCap]
+	}
+	write(module, valuePtr, []byte(written))
+	return uint32(len(value))
+}
+
+func setHeader(ctx context.Context, module api.Module, namePtr, nameLen, valuePtr, valueLen uint32) {
+	exchange := exchangeFromContext(ctx)
+	name := read(module, namePtr, nameLen)
+	value := read(module, valuePtr, valueLen)
+	if len(name) == 0 || name[0] == ':' {
+		panic(fmt.Errorf("The header %q cannot be set", name))
+	}
+	if len(value) == 0 {
+		exchange.headers().Del(string(name))
+	} else {
+		exchange.headers().Set(string(name), string(value))
+	}
+}
+
+func sendResponse(ctx context.Context, module api.Module, status, bodyPtr, bodyLen uint32) {
+	exchange := exchangeFromContext(ctx)
+	if status < 100 || status > 999 {
+		panic(fmt.Errorf("Invalid status %d", status))
+	}
+	exchange.response = &response{status: int(status), body: read(module, bodyPtr, bodyLen)}
+}
+
+func log(ctx context.Context, module api.Module, messagePtr, messageLen uint32) {
+	exchange := exchangeFromContext(ctx)
+	message := read(module, messagePtr, messageLen)
+	if logger, loggerErr := commonLogger.GetLoggerFromContext(exchange.ctx.Request.Context()); loggerErr == nil {
+		logger.Info(fmt.Sprintf("WASM filter %s: %s", exchange.current.name, message))
+	}
+}
@@ -0,0 +1,5 @@
+;; Module without filter exports, assembled into empty.wasm
+(module
+  (func (export "other") (result i32)
+    i32.const 0)
+)
@@ -0,0 +1,90 @@
+;; Filter of the tests, assembled into filter.wasm
+(module
+  (import "gateway" "get_header" (func $get_header (param i32 i32 i32 i32) (result i32)))
+  (import "gateway" "set_header" (func $set_header (param i32 i32 i32 i32)))
+  (import "gateway" "send_response" (func $send_response (param i32 i32 i32)))
+  (import "gateway" "log" (func $log (param i32 i32)))
+  (memory 1)
+  (export "memory" (memory 0))
+  (data (i32.const 0) "x-block")
+  (data (i32.const 16) "x-teapot")
+  (data (i32.const 32) "short and stout")
+  (data (i32.const 64) "x-filtered")
+  (data (i32.const 80) "yes")
+  (data (i32.const 96) "x-wasm")
+  (data (i32.const 112) "on")
+  (data (i32.const 128) ":status")
+  (data (i32.const 144) "x-upstream-status")
+  (data (i32.const 176) "request")
+  (data (i32.const 192) "x-deny")
+
+  ;; on_request rejects the requests with x-block, answers those with x-teapot
+  ;; and marks the others with x-filtered
+  (func (export "on_request") (result i32)
+    i32.const 0
+    i32.const 7
+    i32.const 256
+    i32.const 64
+    call $get_header
+    i32.const -1
+    i32.ne
+    if
+      i32.const 1
+      return
+    end
+    i32.const 16
+    i32.const 8
+    i32.const 256
+    i32.const 64
+    call $get_header
+    i32.const -1
+    i32.ne
+    if
+      i32.const 418
+      i32.const 32
+      i32.const 15
+      call $send_response
+      i32.const 0
+      return
+    end
+    i32.const 64
+    i32.const 10
+    i32.const 80
+    i32.const 3
+    call $set_header
+    i32.const 176
+    i32.const 7
+    call $log
+    i32.const 0)
+
+  ;; on_response rejects the responses with x-deny, else adds x-wasm and copies the status to x-upstream-status
+  (func (export "on_response") (result i32) (local $length i32)
+    i32.const 192
+    i32.const 6
+    i32.const 256
+    i32.const 64
+    call $get_header
+    i32.const -1
+    i32.ne
+    if
+      i32.const 1
+      return
+    end
+    i32.const 96
+    i32.const 6
+    i32.const 112
+    i32.const 2
+    call $set_header
+    i32.const 128
+    i32.const 7
+    i32.const 256
+    i32.const 64
+    call $get_header
+    local.set $length
+    i32.const 144
+    i32.const 17
+    i32.const 256
+    local.get $length
+    call $set_header
+    i32.const 0)
+)
@@ -0,0 +1,8 @@
+;; Filter looping forever, assembled into spin.wasm
+(module
+  (func (export "on_request") (result i32)
+    loop
+      br 0
+    end
+    i32.const 0)
+)
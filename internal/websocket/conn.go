@@ -0,0 +1,261 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Opcodes of the frames
+const (
+	OpContinuation byte = 0x0
+	OpText         byte = 0x1
+	OpBinary       byte = 0x2
+	OpClose        byte = 0x8
+	OpPing         byte = 0x9
+	OpPong         byte = 0xA
+)
+
+// Status codes of the close frames
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// DefaultMaxMessageSize bounds the messages when no size is configured
+const DefaultMaxMessageSize = 64 * 1024
+
+const maxControlPayload = 125
+
+// CloseError is returned once the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (closeError *CloseError) Error() string {
+	return fmt.Sprintf("WebSocket closed with %d: %s", closeError.Code, closeError.Reason)
+}
+
+// Streamer exchanges messages with a peer, a WebSocket connection or a bridged backend stream
+type Streamer interface {
+	ReadMessage() (byte, []byte, error)
+	WriteMessage(opcode byte, payload []byte) error
+	Close() error
+}
+
+// Conn is a WebSocket connection, the client side masks its frames as RFC 6455 requires
+type Conn struct {
+	conn           net.Conn
+	reader         *bufio.Reader
+	client         bool
+	maxMessageSize int
+	readTimeout    time.Duration
+	closeSent      bool
+	writeMtx       sync.Mutex
+}
+
+var _ Streamer = &Conn{}
+
+func newConn(conn net.Conn, reader *bufio.Reader, client bool, maxMessageSize int) *Conn {
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+	return &Conn{conn: conn, reader: reader, client: client, maxMessageSize: maxMessageSize}
+}
+
+// SetReadTimeout closes the connection when no frame arrives within the timeout, zero disables it
+func (conn *Conn) SetReadTimeout(timeout time.Duration) {
+	conn.readTimeout = timeout
+}
+
+// ReadMessage returns the next text or binary message, answering the pings and the close of the peer
+func (conn *Conn) ReadMessage() (byte, []byte, error) {
+	messageOpcode := OpContinuation
+	var message []byte
+	for {
+		fin, opcode, payload, err := conn.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case OpPing:
+			if err := conn.writeFrame(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			closeError := &CloseError{Code: CloseNoStatus}
+			if len(payload) >= 2 {
+				closeError.Code, closeError.Reason = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+			}
+			conn.WriteClose(closeError.Code, "")
+			return 0, nil, closeError
+		case OpContinuation:
+			if messageOpcode == OpContinuation {
+				return 0, nil, conn.fail(CloseProtocolError, "Continuation frame without a message")
+			}
+		case OpText, OpBinary:
+			if messageOpcode != OpContinuation {
+				return 0, nil, conn.fail(CloseProtocolError, "New message before the end of the previous one")
+			}
+			messageOpcode = opcode
+		default:
+			return 0, nil, conn.fail(CloseProtocolError, fmt.Sprintf("Unknown opcode %d", opcode))
+		}
+		if len(message)+len(payload) > conn.maxMessageSize {
+			return 0, nil, conn.fail(CloseMessageTooBig, "Message too big")
+		}
+		message = append(message, payload...)
+		if fin {
+			return messageOpcode, message, nil
+		}
+	}
+}
+
+func (conn *Conn) readFrame() (bool, byte, []byte, error) {
+	if conn.readTimeout > 0 {
+		conn.conn.SetReadDeadline(time.Now().Add(conn.readTimeout))
+	}
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode, masked := header[0]&0x80 != 0, header[0]&0x0f, header[1]&0x80 != 0
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, conn.fail(CloseProtocolError, "Reserved bits set")
+	}
+	if masked == conn.client {
+		return false, 0, nil, conn.fail(CloseProtocolError, "Wrong frame masking")
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(conn.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(conn.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if opcode >= OpClose && (!fin || length > maxControlPayload) {
+		return false, 0, nil, conn.fail(CloseProtocolError, "Invalid control frame")
+	}
+	if length > uint64(conn.maxMessageSize) {
+		return false, 0, nil, conn.fail(CloseMessageTooBig, "Message too big")
+	}
+	mask := make([]byte, 4)
+	if masked {
+		if _, err := io.ReadFull(conn.reader, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for index := range payload {
+			payload[index] ^= mask[index%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// fail closes the connection on a protocol violation of the peer
+func (conn *Conn) fail(code int, reason string) error {
+	conn.WriteClose(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage sends a message in a single frame
+func (conn *Conn) WriteMessage(opcode byte, payload []byte) error {
+	return conn.writeFrame(opcode, payload)
+}
+
+// WritePing sends a ping, the peer answers with a pong
+func (conn *Conn) WritePing() error {
+	return conn.writeFrame(OpPing, nil)
+}
+
+// WriteClose starts the closing handshake, only the first close is sent
+func (conn *Conn) WriteClose(code int, reason string) error {
+	payload := []byte{}
+	if code != CloseNoStatus {
+		payload = binary.BigEndian.AppendUint16(payload, uint16(code))
+		if len(reason) > maxControlPayload-2 {
+			reason = reason[:maxControlPayload-2]
+		}
+		payload = append(payload, reason...)
+	}
+	conn.writeMtx.Lock()
+	defer conn.writeMtx.Unlock()
+	if conn.closeSent {
+		return nil
+	}
+	conn.closeSent = true
+	return conn.writeFrameLocked(OpClose, payload)
+}
+
+func (conn *Conn) writeFrame(opcode byte, payload []byte) error {
+	conn.writeMtx.Lock()
+	defer conn.writeMtx.Unlock()
+	if conn.closeSent {
+		return &CloseError{Code: CloseNormal, Reason: "Connection closing"}
+	}
+	return conn.writeFrameLocked(opcode, payload)
+}
+
+func (conn *Conn) writeFrameLocked(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	maskBit := byte(0)
+	if conn.client {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = binary.BigEndian.AppendUint16(append(frame, maskBit|126), uint16(len(payload)))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, maskBit|127), uint64(len(payload)))
+	}
+	if conn.client {
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		frame = append(frame, mask...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for index := range payload {
+			frame[start+index] ^= mask[index%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := conn.conn.Write(frame)
+	return err
+}
+
+// Close sends a normal close unless one was sent and closes the connection
+func (conn *Conn) Close() error {
+	conn.WriteClose(CloseNormal, "")
+	return conn.conn.Close()
+}
@@ -0,0 +1,172 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// acceptGUID is appended to the key of the client to compute the accept header of the handshake
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// BearerSubprotocol is offered by the browsers, which cannot set headers on WebSockets,
+// followed by their access token as a second subprotocol
+const BearerSubprotocol = "bearer"
+
+func acceptKey(key string) string {
+	digest := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, element := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(element), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUpgradeRequest tells whether the request is a valid WebSocket opening handshake
+func IsUpgradeRequest(request *http.Request) bool {
+	if request.Method != http.MethodGet ||
+		!headerContains(request.Header, "Connection", "upgrade") ||
+		!headerContains(request.Header, "Upgrade", "websocket") ||
+		request.Header.Get("Sec-WebSocket-Version") != "13" {
+		return false
+	}
+	key, err := base64.StdEncoding.DecodeString(request.Header.Get("Sec-WebSocket-Key"))
+	return err == nil && len(key) == 16
+}
+
+// Subprotocols returns the subprotocols offered by the client
+func Subprotocols(request *http.Request) []string {
+	subprotocols := []string{}
+	for _, value := range request.Header.Values("Sec-WebSocket-Protocol") {
+		for _, subprotocol := range strings.Split(value, ",") {
+			if subprotocol = strings.TrimSpace(subprotocol); subprotocol != "" {
+				subprotocols = append(subprotocols, subprotocol)
+			}
+		}
+	}
+	return subprotocols
+}
+
+// Upgrade completes the opening handshake of the request and takes over its connection,
+// the subprotocol, when not empty, is the one selected among those offered by the client
+func Upgrade(writer http.ResponseWriter, request *http.Request, subprotocol string, maxMessageSize int) (*Conn, error) {
+	if !IsUpgradeRequest(request) {
+		return nil, fmt.Errorf("Not a WebSocket handshake")
+	}
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("The response writer cannot be hijacked")
+	}
+	netConn, readWriter, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("Error hijacking the connection: %v", err)
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(request.Header.Get("Sec-WebSocket-Key")) + "\r\n"
+	if subprotocol != "" {
+		response += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	if _, err := netConn.Write([]byte(response + "\r\n")); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("Error writing the handshake response: %v", err)
+	}
+	return newConn(netConn, readWriter.Reader, false, maxMessageSize), nil
+}
+
+// Dial opens a WebSocket connection to a ws:// or wss:// URL with the given handshake headers
+func Dial(ctx context.Context, rawURL string, header http.Header, maxMessageSize int) (*Conn, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid WebSocket URL: %v", err)
+	}
+	secure := target.Scheme == "wss"
+	if !secure && target.Scheme != "ws" {
+		return nil, fmt.Errorf("Unsupported WebSocket scheme: %s", target.Scheme)
+	}
+	address := target.Host
+	if target.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		address = net.JoinHostPort(target.Hostname(), port)
+	}
+	dialer := &net.Dialer{}
+	netConn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to %s: %v", address, err)
+	}
+	if secure {
+		tlsConn := tls.Client(netConn, &tls.Config{ServerName: target.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("Error in the TLS handshake with %s: %v", address, err)
+		}
+		netConn = tlsConn
+	}
+	if deadline, exists := ctx.Deadline(); exists {
+		netConn.SetDeadline(deadline)
+	}
+	conn, err := handshake(netConn, target, header, maxMessageSize)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func handshake(netConn net.Conn, target *url.URL, header http.Header, maxMessageSize int) (*Conn, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	httpURL := *target
+	httpURL.Scheme = "http"
+	if target.Scheme == "wss" {
+		httpURL.Scheme = "https"
+	}
+	request, err := http.NewRequest(http.MethodGet, httpURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating the handshake request: %v", err)
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	if err := request.Write(netConn); err != nil {
+		return nil, fmt.Errorf("Error sending the handshake request: %v", err)
+	}
+	reader := bufio.NewReader(netConn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the handshake response: %v", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("The backend refused the WebSocket with status %d", response.StatusCode)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("The backend answered with a wrong accept key")
+	}
+	return newConn(netConn, reader, true, maxMessageSize), nil
+}
@@ -0,0 +1,287 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/grpcjson"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// DefaultHandshakeTimeout bounds the opening of the backend when no timeout is configured
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// Proxy upgrades the client connections of the WebSocket routes and bridges them to their backend
+type Proxy struct {
+	handshakeTimeout time.Duration
+	maxMessageSize   int
+	pingInterval     time.Duration
+}
+
+// NewProxy creates the WebSocket proxy of the configuration
+func NewProxy(configurations *config.Config) *Proxy {
+	proxy := &Proxy{
+		handshakeTimeout: configurations.WebSockets.HandshakeTimeout,
+		maxMessageSize:   configurations.WebSockets.MaxMessageSize,
+		pingInterval:     configurations.WebSockets.PingInterval,
+	}
+	if proxy.handshakeTimeout <= 0 {
+		proxy.handshakeTimeout = DefaultHandshakeTimeout
+	}
+	if proxy.maxMessageSize <= 0 {
+		proxy.maxMessageSize = DefaultMaxMessageSize
+	}
+	return proxy
+}
+
+// RegisterRoutes registers the configured WebSocket routes, authenticated during the handshake unless public
+func RegisterRoutes(
+	api *gin.RouterGroup,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+	authenticationMiddleware authentication.AutheticationMiddlewarer,
+) (*Proxy, error) {
+	proxy := NewProxy(configurations)
+	for _, route := range configurations.WebSockets.Routes {
+		var connection grpc.ClientConnInterface
+		switch {
+		case route.Backend != "" && route.Method == "":
+		case route.Backend == "" && route.Method != "":
+			serviceConnection, err := connectService(route, centralConfig, configurations)
+			if err != nil {
+				return nil, err
+			}
+			connection = serviceConnection
+		default:
+			return nil, fmt.Errorf("The WebSocket route %s needs either a backend or a gRPC method", route.Path)
+		}
+		handlers := []gin.HandlerFunc{}
+		if !route.Public {
+			handlers = append(handlers, BearerTokenFromSubprotocol, authenticationMiddleware.RequireAuthentication)
+		}
+		api.GET(route.Path, append(handlers, proxy.Handler(route, connection))...)
+	}
+	return proxy, nil
+}
+
+func connectService(
+	route config.WebSocketRouteConfig,
+	centralConfig *commonConfig.Config,
+	configurations *config.Config,
+) (grpc.ClientConnInterface, error) {
+	grpcServiceAddress := fmt.Sprintf("%s:%s", route.Host, route.Port)
+	fmt.Println("Connecting to", route.Service, "streaming service at", grpcServiceAddress, centralConfig.TLSEnabled)
+	clientConnection, err := egress.CreateServiceConnection(route.Service, grpcServiceAddress, centralConfig.TLSEnabled, configurations)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to grpc %s service: %v", route.Service, err)
+	}
+	regionalConnection, err := region.RouteConnection(route.Service, clientConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+	tenantConnection, err := tenancy.RouteConnection(route.Service, regionalConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+	routedConnection, err := versioning.RouteConnection(route.Service, tenantConnection, configurations, centralConfig.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+	tracedConnection := tracing.TraceConnection(route.Service, routedConnection, configurations)
+	instrumentedConnection := metrics.InstrumentConnection(route.Service, tracedConnection, configurations)
+	return hooks.HookConnection(route.Service, instrumentedConnection, configurations), nil
+}
+
+// BearerTokenFromSubprotocol moves the access token offered after the bearer subprotocol to the authorization
+// header, so the authentication middleware verifies the handshakes of the browsers
+func BearerTokenFromSubprotocol(ctx *gin.Context) {
+	if ctx.GetHeader("Authorization") != "" {
+		return
+	}
+	subprotocols := Subprotocols(ctx.Request)
+	for index, subprotocol := range subprotocols {
+		if subprotocol == BearerSubprotocol && index+1 < len(subprotocols) {
+			ctx.Request.Header.Set("Authorization", "Bearer "+subprotocols[index+1])
+			return
+		}
+	}
+}
+
+// Handler opens the backend of the route, upgrades the client connection and bridges them until either closes
+func (proxy *Proxy) Handler(route config.WebSocketRouteConfig, connection grpc.ClientConnInterface) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		logger, err := commonLogger.GetLoggerFromContext(ctx.Request.Context())
+		if err != nil {
+			errors.AbortWithError(ctx, errors.InternalError, err)
+			return
+		}
+		if !IsUpgradeRequest(ctx.Request) {
+			errors.Abort(ctx, errors.WebSocketRequired)
+			return
+		}
+		// The stream outlives the deadline of the API requests and keeps the authorization metadata of the request
+		streamContext, cancel := context.WithCancel(context.WithoutCancel(ctx.Request.Context()))
+		defer cancel()
+		backend, err := proxy.openBackend(streamContext, ctx, route, connection)
+		if err != nil {
+			logger.Error(err, fmt.Sprintf("Could not open the WebSocket backend of %s", route.Path))
+			errors.Abort(ctx, errors.BadGateway)
+			return
+		}
+		subprotocol := ""
+		for _, offered := range Subprotocols(ctx.Request) {
+			if offered == BearerSubprotocol {
+				subprotocol = BearerSubprotocol
+			}
+		}
+		// Set before the hijack so the access log and the metrics record the switch
+		ctx.Status(http.StatusSwitchingProtocols)
+		client, err := Upgrade(ctx.Writer, ctx.Request, subprotocol, proxy.maxMessageSize)
+		if err != nil {
+			backend.Close()
+			logger.Error(err, "Could not upgrade the connection to a WebSocket")
+			return
+		}
+		logger.Info(fmt.Sprintf("WebSocket opened on %s", route.Path))
+		err = proxy.bridge(client, backend)
+		closeError := &CloseError{}
+		if stdErrors.As(err, &closeError) && (closeError.Code == CloseNormal || closeError.Code == CloseGoingAway || closeError.Code == CloseNoStatus) {
+			logger.Info(fmt.Sprintf("WebSocket closed on %s", route.Path))
+			return
+		}
+		logger.Error(err, fmt.Sprintf("WebSocket failed on %s", route.Path))
+	}
+}
+
+func (proxy *Proxy) openBackend(
+	streamContext context.Context,
+	ctx *gin.Context,
+	route config.WebSocketRouteConfig,
+	connection grpc.ClientConnInterface,
+) (Streamer, error) {
+	if connection != nil {
+		backendContext, cancel := context.WithCancel(streamContext)
+		stream, err := grpcjson.NewStream(
+			backendContext,
+			connection,
+			&grpc.StreamDesc{StreamName: route.Method[strings.LastIndex(route.Method, "/")+1:], ClientStreams: true, ServerStreams: true},
+			route.Method,
+		)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &grpcStream{stream: stream, cancel: cancel}, nil
+	}
+	dialContext, cancel := context.WithTimeout(streamContext, proxy.handshakeTimeout)
+	defer cancel()
+	header := http.Header{}
+	if authorization := ctx.GetHeader("Authorization"); authorization != "" && !route.Public {
+		header.Set("Authorization", authorization)
+	}
+	if correlationID := ctx.GetHeader(commonLogger.CorrelationIDKey); correlationID != "" {
+		header.Set(commonLogger.CorrelationIDKey, correlationID)
+	}
+	return Dial(dialContext, route.Backend, header, proxy.maxMessageSize)
+}
+
+// bridge exchanges the messages of the client and the backend, pinging the client to detect dead connections,
+// and returns the error ending the exchange
+func (proxy *Proxy) bridge(client *Conn, backend Streamer) error {
+	done := make(chan error, 2)
+	go func() { done <- pump(client, backend) }()
+	go func() { done <- pump(backend, client) }()
+	var pings <-chan time.Time
+	if proxy.pingInterval > 0 {
+		client.SetReadTimeout(2 * proxy.pingInterval)
+		ticker := time.NewTicker(proxy.pingInterval)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+	var err error
+	for err == nil {
+		select {
+		case err = <-done:
+		case <-pings:
+			client.WritePing()
+		}
+	}
+	code := CloseInternalError
+	closeError := &CloseError{}
+	if stdErrors.As(err, &closeError) {
+		code = closeError.Code
+	}
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	client.WriteClose(code, "")
+	backend.Close()
+	client.Close()
+	<-done
+	return err
+}
+
+func pump(from, to Streamer) error {
+	for {
+		opcode, payload, err := from.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := to.WriteMessage(opcode, payload); err != nil {
+			return err
+		}
+	}
+}
+
+// grpcStream exchanges the messages of a bidirectional streaming gRPC method as JSON text messages
+type grpcStream struct {
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+var _ Streamer = &grpcStream{}
+
+func (stream *grpcStream) ReadMessage() (byte, []byte, error) {
+	message := json.RawMessage{}
+	if err := stream.stream.RecvMsg(&message); err != nil {
+		if err == io.EOF {
+			return 0, nil, &CloseError{Code: CloseNormal, Reason: "Stream ended"}
+		}
+		return 0, nil, err
+	}
+	return OpText, message, nil
+}
+
+func (stream *grpcStream) WriteMessage(opcode byte, payload []byte) error {
+	if opcode != OpText || !json.Valid(payload) {
+		return &CloseError{Code: CloseUnsupportedData, Reason: "Messages must be JSON text"}
+	}
+	return stream.stream.SendMsg(json.RawMessage(payload))
+}
+
+// Close ends the sending side and cancels the stream so a pending receive returns
+func (stream *grpcStream) Close() error {
+	err := stream.stream.CloseSend()
+	stream.cancel()
+	return err
+}
@@ -0,0 +1,190 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+	commonLoggerMock "github.com/quadev-ltd/qd-common/pkg/log/mock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// fakeClientStream answers the receives with its messages then ends the stream
+type fakeClientStream struct {
+	grpc.ClientStream
+	received []string
+	sent     []string
+}
+
+func (stream *fakeClientStream) SendMsg(message interface{}) error {
+	stream.sent = append(stream.sent, string(message.(json.RawMessage)))
+	return nil
+}
+
+func (stream *fakeClientStream) RecvMsg(message interface{}) error {
+	if len(stream.received) == 0 {
+		return io.EOF
+	}
+	*message.(*json.RawMessage), stream.received = json.RawMessage(stream.received[0]), stream.received[1:]
+	return nil
+}
+
+func (stream *fakeClientStream) CloseSend() error {
+	return nil
+}
+
+// newEchoBackend serves a WebSocket answering every message with its echo
+func newEchoBackend(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Authorization") != "Bearer access-token" {
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+		conn, err := Upgrade(writer, request, "", 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		for {
+			opcode, payload, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(opcode, append([]byte("echo: "), payload...))
+		}
+	}))
+}
+
+// newProxyServer serves the route, the handled channel receives once the handler returns
+func newProxyServer(t *testing.T, route config.WebSocketRouteConfig, maxMessageSize int) (*httptest.Server, chan struct{}) {
+	gin.SetMode(gin.TestMode)
+	controller := gomock.NewController(t)
+	loggerMock := commonLoggerMock.NewMockLoggerer(controller)
+	loggerMock.EXPECT().Info(gomock.Any()).AnyTimes()
+	loggerMock.EXPECT().Error(gomock.Any(), gomock.Any()).AnyTimes()
+	proxy := NewProxy(&config.Config{WebSockets: config.WebSocketsConfig{MaxMessageSize: maxMessageSize}})
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), commonLogger.LoggerKey, loggerMock))
+	})
+	handled := make(chan struct{}, 1)
+	router.GET("/stream", BearerTokenFromSubprotocol, proxy.Handler(route, nil), func(ctx *gin.Context) { handled <- struct{}{} })
+	return httptest.NewServer(router), handled
+}
+
+func dialProxy(t *testing.T, server *httptest.Server) *Conn {
+	header := http.Header{}
+	header.Set("Sec-WebSocket-Protocol", "bearer, access-token")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/stream", header, 0)
+	assert.NoError(t, err)
+	return conn
+}
+
+func TestProxy(t *testing.T) {
+	t.Run("Handler_Should_Bridge_The_Client_To_The_WebSocket_Backend", func(t *testing.T) {
+		backend := newEchoBackend(t)
+		defer backend.Close()
+		server, handled := newProxyServer(t, config.WebSocketRouteConfig{Path: "/stream", Backend: "ws" + strings.TrimPrefix(backend.URL, "http")}, 0)
+		defer server.Close()
+		client := dialProxy(t, server)
+
+		assert.NoError(t, client.WriteMessage(OpText, []byte("hello")))
+		opcode, payload, err := client.ReadMessage()
+
+		assert.NoError(t, err)
+		assert.Equal(t, OpText, opcode)
+		assert.Equal(t, "echo: hello", string(payload))
+		client.Close()
+		<-handled
+	})
+
+	t.Run("Handler_Should_Close_Messages_Beyond_The_Maximum_Size", func(t *testing.T) {
+		backend := newEchoBackend(t)
+		defer backend.Close()
+		server, handled := newProxyServer(t, config.WebSocketRouteConfig{Path: "/stream", Backend: "ws" + strings.TrimPrefix(backend.URL, "http")}, 4)
+		defer server.Close()
+		client := dialProxy(t, server)
+
+		assert.NoError(t, client.WriteMessage(OpBinary, []byte("too big")))
+		_, _, err := client.ReadMessage()
+
+		assert.Equal(t, &CloseError{Code: CloseMessageTooBig, Reason: "Message too big"}, err)
+		client.Close()
+		<-handled
+	})
+
+	t.Run("Handler_Should_Answer_Bad_Gateway_When_The_Backend_Refuses", func(t *testing.T) {
+		backend := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer backend.Close()
+		server, _ := newProxyServer(t, config.WebSocketRouteConfig{Path: "/stream", Backend: "ws" + strings.TrimPrefix(backend.URL, "http")}, 0)
+		defer server.Close()
+
+		_, err := Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http")+"/stream", http.Header{}, 0)
+
+		assert.EqualError(t, err, "The backend refused the WebSocket with status 502")
+	})
+
+	t.Run("Handler_Should_Require_A_WebSocket_Handshake", func(t *testing.T) {
+		server, _ := newProxyServer(t, config.WebSocketRouteConfig{Path: "/stream", Backend: "ws://localhost:1"}, 0)
+		defer server.Close()
+
+		response, err := http.Get(server.URL + "/stream")
+
+		assert.NoError(t, err)
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		assert.Equal(t, `{"error":{"code":"websocket_required","message":"The route requires a WebSocket upgrade"}}`, string(body))
+	})
+
+	t.Run("BearerTokenFromSubprotocol_Should_Keep_An_Authorization_Header", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/stream", nil)
+		ctx.Request.Header.Set("Authorization", "Bearer header-token")
+		ctx.Request.Header.Set("Sec-WebSocket-Protocol", "bearer, subprotocol-token")
+
+		BearerTokenFromSubprotocol(ctx)
+
+		assert.Equal(t, "Bearer header-token", ctx.Request.Header.Get("Authorization"))
+	})
+}
+
+func TestGRPCStream(t *testing.T) {
+	t.Run("ReadMessage_Should_Close_Normally_At_The_End_Of_The_Stream", func(t *testing.T) {
+		stream := &grpcStream{stream: &fakeClientStream{received: []string{`{"id":"notification-id"}`}}, cancel: func() {}}
+
+		opcode, payload, err := stream.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, OpText, opcode)
+		assert.Equal(t, `{"id":"notification-id"}`, string(payload))
+		_, _, err = stream.ReadMessage()
+
+		assert.Equal(t, &CloseError{Code: CloseNormal, Reason: "Stream ended"}, err)
+	})
+
+	t.Run("WriteMessage_Should_Only_Send_JSON_Text", func(t *testing.T) {
+		fakeStream := &fakeClientStream{}
+		stream := &grpcStream{stream: fakeStream, cancel: func() {}}
+
+		assert.NoError(t, stream.WriteMessage(OpText, []byte(`{"read":true}`)))
+		assert.Equal(t, &CloseError{Code: CloseUnsupportedData, Reason: "Messages must be JSON text"}, stream.WriteMessage(OpBinary, []byte{1}))
+		assert.Equal(t, &CloseError{Code: CloseUnsupportedData, Reason: "Messages must be JSON text"}, stream.WriteMessage(OpText, []byte("not json")))
+		assert.Equal(t, []string{`{"read":true}`}, fakeStream.sent)
+	})
+}
@@ -0,0 +1,127 @@
+package wellknown
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// App association paths, iOS also looks the association up at the root
+const (
+	AppleAppSiteAssociationPath       = "/.well-known/apple-app-site-association"
+	LegacyAppleAppSiteAssociationPath = "/apple-app-site-association"
+	AndroidAssetLinksPath             = "/.well-known/assetlinks.json"
+)
+
+const excludedPathPrefix = "NOT "
+
+var defaultAndroidRelations = []string{
+	"delegate_permission/common.handle_all_urls",
+	"delegate_permission/common.get_login_creds",
+}
+
+type appleAppSiteAssociation struct {
+	AppLinks       *appleAppLinks `json:"applinks,omitempty"`
+	WebCredentials *appleApps     `json:"webcredentials,omitempty"`
+	AppClips       *appleApps     `json:"appclips,omitempty"`
+}
+
+type appleAppLinks struct {
+	Details []appleAppLinkDetail `json:"details"`
+}
+
+type appleAppLinkDetail struct {
+	AppIDs     []string                `json:"appIDs"`
+	Components []appleAppLinkComponent `json:"components"`
+}
+
+type appleAppLinkComponent struct {
+	Path    string `json:"/"`
+	Exclude bool   `json:"exclude,omitempty"`
+}
+
+type appleApps struct {
+	Apps []string `json:"apps"`
+}
+
+type androidStatement struct {
+	Relation []string      `json:"relation"`
+	Target   androidTarget `json:"target"`
+}
+
+type androidTarget struct {
+	Namespace              string   `json:"namespace"`
+	PackageName            string   `json:"package_name"`
+	SHA256CertFingerprints []string `json:"sha256_cert_fingerprints"`
+}
+
+// renderAppleAppSiteAssociation builds the association, nil when no app is configured
+func renderAppleAppSiteAssociation(associationConfig config.AppleAppSiteAssociationConfig) ([]byte, error) {
+	association := appleAppSiteAssociation{}
+	if len(associationConfig.AppLinks) > 0 {
+		association.AppLinks = &appleAppLinks{Details: []appleAppLinkDetail{}}
+		for _, appLink := range associationConfig.AppLinks {
+			if len(appLink.AppIDs) == 0 {
+				return nil, fmt.Errorf("Every apple app link requires app ids")
+			}
+			detail := appleAppLinkDetail{AppIDs: appLink.AppIDs, Components: []appleAppLinkComponent{}}
+			for _, path := range appLink.Paths {
+				component := appleAppLinkComponent{Path: path}
+				if strings.HasPrefix(path, excludedPathPrefix) {
+					component = appleAppLinkComponent{Path: strings.TrimPrefix(path, excludedPathPrefix), Exclude: true}
+				}
+				detail.Components = append(detail.Components, component)
+			}
+			association.AppLinks.Details = append(association.AppLinks.Details, detail)
+		}
+	}
+	if len(associationConfig.WebCredentials) > 0 {
+		association.WebCredentials = &appleApps{Apps: associationConfig.WebCredentials}
+	}
+	if len(associationConfig.AppClips) > 0 {
+		association.AppClips = &appleApps{Apps: associationConfig.AppClips}
+	}
+	if association.AppLinks == nil && association.WebCredentials == nil && association.AppClips == nil {
+		return nil, nil
+	}
+	return json.Marshal(association)
+}
+
+// renderAndroidAssetLinks builds the asset links statements, nil when no app is configured
+func renderAndroidAssetLinks(assetLinksConfig []config.AndroidAssetLinkConfig) ([]byte, error) {
+	if len(assetLinksConfig) == 0 {
+		return nil, nil
+	}
+	statements := []androidStatement{}
+	for _, assetLink := range assetLinksConfig {
+		if assetLink.PackageName == "" || len(assetLink.SHA256CertFingerprints) == 0 {
+			return nil, fmt.Errorf("Every android asset link requires a package name and certificate fingerprints")
+		}
+		relations := assetLink.Relations
+		if len(relations) == 0 {
+			relations = defaultAndroidRelations
+		}
+		statements = append(statements, androidStatement{
+			Relation: relations,
+			Target: androidTarget{
+				Namespace:              "android_app",
+				PackageName:            assetLink.PackageName,
+				SHA256CertFingerprints: assetLink.SHA256CertFingerprints,
+			},
+		})
+	}
+	return json.Marshal(statements)
+}
+
+// serveJSON answers the prerendered document, the app associations must not be redirected
+func serveJSON(document []byte) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "public, max-age=3600")
+		ctx.Data(http.StatusOK, "application/json", document)
+	}
+}
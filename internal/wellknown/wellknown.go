@@ -0,0 +1,240 @@
+package wellknown
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+)
+
+// Well-known paths served by the gateway
+const (
+	SecurityTxtPath     = "/.well-known/security.txt"
+	LegacySecurityPath  = "/security.txt"
+	ChangePasswordPath  = "/.well-known/change-password"
+	OIDCDiscoveryPrefix = "/.well-known/"
+)
+
+const (
+	defaultSecurityTxtExpiry = 180 * 24 * time.Hour
+	defaultDiscoveryCacheTTL = time.Hour
+	maxDiscoveryBytes        = 1 << 20
+)
+
+// WellKnown serves the security.txt, the change-password redirection, the mobile app associations
+// and the OIDC discovery documents
+type WellKnown struct {
+	securityTxt       config.SecurityTxtConfig
+	expires           time.Time
+	changePasswordURL string
+	issuerURL         string
+	appleAssociation  []byte
+	androidAssetLinks []byte
+	client            *http.Client
+	cacheTTL          time.Duration
+	documents         map[string]*cachedDocument
+	mtx               sync.Mutex
+}
+
+type cachedDocument struct {
+	body      []byte
+	fetchedAt time.Time
+	ttl       time.Duration
+}
+
+// NewWellKnown creates the well-known endpoints of the configuration, the client fetches the OIDC documents
+func NewWellKnown(configurations *config.Config, client *http.Client) (*WellKnown, error) {
+	wellKnownConfig := configurations.WellKnown
+	expires := time.Time{}
+	if wellKnownConfig.SecurityTxt.Expires != "" {
+		parsedExpires, err := time.Parse(time.RFC3339, wellKnownConfig.SecurityTxt.Expires)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid security.txt expiry: %v", err)
+		}
+		expires = parsedExpires
+	}
+	appleAssociation, err := renderAppleAppSiteAssociation(wellKnownConfig.AppleAppSiteAssociation)
+	if err != nil {
+		return nil, err
+	}
+	androidAssetLinks, err := renderAndroidAssetLinks(wellKnownConfig.AndroidAssetLinks)
+	if err != nil {
+		return nil, err
+	}
+	wellKnown := &WellKnown{
+		securityTxt:       wellKnownConfig.SecurityTxt,
+		expires:           expires,
+		changePasswordURL: wellKnownConfig.ChangePasswordURL,
+		issuerURL:         strings.TrimSuffix(wellKnownConfig.OIDC.IssuerURL, "/"),
+		appleAssociation:  appleAssociation,
+		androidAssetLinks: androidAssetLinks,
+		client:            client,
+		cacheTTL:          wellKnownConfig.OIDC.CacheTTL,
+		documents:         map[string]*cachedDocument{},
+	}
+	if wellKnown.cacheTTL <= 0 {
+		wellKnown.cacheTTL = defaultDiscoveryCacheTTL
+	}
+	return wellKnown, nil
+}
+
+// RegisterRoutes registers the configured well-known endpoints at the root of the router
+func RegisterRoutes(router *gin.Engine, configurations *config.Config, client *http.Client) (*WellKnown, error) {
+	wellKnown, err := NewWellKnown(configurations, client)
+	if err != nil {
+		return nil, err
+	}
+	if len(wellKnown.securityTxt.Contacts) > 0 {
+		router.GET(SecurityTxtPath, wellKnown.SecurityTxt)
+		router.GET(LegacySecurityPath, wellKnown.SecurityTxt)
+	}
+	if wellKnown.changePasswordURL != "" {
+		router.GET(ChangePasswordPath, wellKnown.ChangePassword)
+	}
+	if wellKnown.appleAssociation != nil {
+		router.GET(AppleAppSiteAssociationPath, serveJSON(wellKnown.appleAssociation))
+		router.GET(LegacyAppleAppSiteAssociationPath, serveJSON(wellKnown.appleAssociation))
+	}
+	if wellKnown.androidAssetLinks != nil {
+		router.GET(AndroidAssetLinksPath, serveJSON(wellKnown.androidAssetLinks))
+	}
+	if wellKnown.issuerURL != "" {
+		for _, document := range configurations.WellKnown.OIDC.Documents {
+			router.GET(OIDCDiscoveryPrefix+document, wellKnown.Discovery(document))
+		}
+	}
+	return wellKnown, nil
+}
+
+// SecurityTxt answers the RFC 9116 security.txt, its expiry rolls forward when no fixed date is configured
+func (wellKnown *WellKnown) SecurityTxt(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=86400")
+	ctx.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(wellKnown.RenderSecurityTxt(time.Now())))
+}
+
+// RenderSecurityTxt builds the security.txt fields
+func (wellKnown *WellKnown) RenderSecurityTxt(now time.Time) string {
+	securityTxt := wellKnown.securityTxt
+	builder := strings.Builder{}
+	for _, contact := range securityTxt.Contacts {
+		fmt.Fprintf(&builder, "Contact: %s\n", contact)
+	}
+	expires := wellKnown.expires
+	if expires.IsZero() {
+		expiresIn := securityTxt.ExpiresIn
+		if expiresIn <= 0 {
+			expiresIn = defaultSecurityTxtExpiry
+		}
+		expires = now.Add(expiresIn).Truncate(24 * time.Hour)
+	}
+	fmt.Fprintf(&builder, "Expires: %s\n", expires.UTC().Format(time.RFC3339))
+	for _, encryption := range securityTxt.Encryption {
+		fmt.Fprintf(&builder, "Encryption: %s\n", encryption)
+	}
+	fields := []struct{ name, value string }{
+		{"Acknowledgments", securityTxt.Acknowledgments},
+		{"Preferred-Languages", strings.Join(securityTxt.PreferredLanguages, ", ")},
+		{"Canonical", securityTxt.Canonical},
+		{"Policy", securityTxt.Policy},
+		{"Hiring", securityTxt.Hiring},
+	}
+	for _, field := range fields {
+		if field.value != "" {
+			fmt.Fprintf(&builder, "%s: %s\n", field.name, field.value)
+		}
+	}
+	return builder.String()
+}
+
+// ChangePassword redirects password managers to the change password page
+func (wellKnown *WellKnown) ChangePassword(ctx *gin.Context) {
+	ctx.Redirect(http.StatusFound, wellKnown.changePasswordURL)
+}
+
+// Discovery passes the OIDC discovery document of the issuer through, cached for the configured TTL
+// or the max-age of the issuer; a stale copy is served while the issuer is unreachable
+func (wellKnown *WellKnown) Discovery(document string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body, ttl, err := wellKnown.document(ctx.Request.Context(), document, time.Now())
+		if err != nil {
+			if logger, loggerErr := commonLogger.GetLoggerFromContext(ctx.Request.Context()); loggerErr == nil {
+				logger.Error(err, "Could not fetch the OIDC discovery document")
+			}
+			errors.Abort(ctx, errors.DiscoveryUnavailable)
+			return
+		}
+		ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ttl.Seconds())))
+		ctx.Data(http.StatusOK, "application/json", body)
+	}
+}
+
+func (wellKnown *WellKnown) document(ctx context.Context, document string, now time.Time) ([]byte, time.Duration, error) {
+	wellKnown.mtx.Lock()
+	cached := wellKnown.documents[document]
+	wellKnown.mtx.Unlock()
+	if cached != nil && now.Sub(cached.fetchedAt) < cached.ttl {
+		return cached.body, cached.ttl - now.Sub(cached.fetchedAt), nil
+	}
+
+	body, ttl, err := wellKnown.fetch(ctx, document)
+	if err != nil {
+		if cached != nil {
+			return cached.body, 0, nil
+		}
+		return nil, 0, err
+	}
+	wellKnown.mtx.Lock()
+	wellKnown.documents[document] = &cachedDocument{body: body, fetchedAt: now, ttl: ttl}
+	wellKnown.mtx.Unlock()
+	return body, ttl, nil
+}
+
+func (wellKnown *WellKnown) fetch(ctx context.Context, document string) ([]byte, time.Duration, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown.issuerURL+OIDCDiscoveryPrefix+document, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := wellKnown.client.Do(request)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Could not reach the OIDC issuer: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("OIDC issuer responded with status %d", response.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxDiscoveryBytes))
+	if err != nil {
+		return nil, 0, fmt.Errorf("Could not read the OIDC discovery document: %v", err)
+	}
+	ttl := wellKnown.cacheTTL
+	if maxAge, ok := parseMaxAge(response.Header.Get("Cache-Control")); ok && maxAge < ttl {
+		ttl = maxAge
+	}
+	return body, ttl, nil
+}
+
+func parseMaxAge(cacheControl string) (time.Duration, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
@@ -0,0 +1,178 @@
+package wellknown
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+func newTestRouter(t *testing.T, wellKnownConfig config.WellKnownConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	_, err := RegisterRoutes(router, &config.Config{WellKnown: wellKnownConfig}, http.DefaultClient)
+	assert.NoError(t, err)
+	return router
+}
+
+func get(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+func TestWellKnown(t *testing.T) {
+	t.Run("SecurityTxt_Should_Render_The_Configured_Fields", func(t *testing.T) {
+		router := newTestRouter(t, config.WellKnownConfig{SecurityTxt: config.SecurityTxtConfig{
+			Contacts:           []string{"mailto:security@example.com", "https://example.com/report"},
+			Expires:            "2030-01-01T00:00:00Z",
+			PreferredLanguages: []string{"en", "es"},
+			Policy:             "https://example.com/disclosure",
+		}})
+
+		for _, path := range []string{SecurityTxtPath, LegacySecurityPath} {
+			recorder := get(router, path)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"))
+			assert.Equal(t, "Contact: mailto:security@example.com\n"+
+				"Contact: https://example.com/report\n"+
+				"Expires: 2030-01-01T00:00:00Z\n"+
+				"Preferred-Languages: en, es\n"+
+				"Policy: https://example.com/disclosure\n", recorder.Body.String())
+		}
+	})
+
+	t.Run("RenderSecurityTxt_Should_Roll_The_Expiry_Forward", func(t *testing.T) {
+		wellKnown, err := NewWellKnown(&config.Config{WellKnown: config.WellKnownConfig{SecurityTxt: config.SecurityTxtConfig{
+			Contacts:  []string{"mailto:security@example.com"},
+			ExpiresIn: 48 * time.Hour,
+		}}}, nil)
+		assert.NoError(t, err)
+
+		content := wellKnown.RenderSecurityTxt(time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC))
+
+		assert.Contains(t, content, "Expires: 2024-03-17T00:00:00Z\n")
+	})
+
+	t.Run("NewWellKnown_Should_Reject_Invalid_Expiry", func(t *testing.T) {
+		_, err := NewWellKnown(&config.Config{WellKnown: config.WellKnownConfig{SecurityTxt: config.SecurityTxtConfig{
+			Expires: "next year",
+		}}}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("ChangePassword_Should_Redirect_To_The_Configured_Page", func(t *testing.T) {
+		router := newTestRouter(t, config.WellKnownConfig{ChangePasswordURL: "https://example.com/account/password"})
+
+		recorder := get(router, ChangePasswordPath)
+
+		assert.Equal(t, http.StatusFound, recorder.Code)
+		assert.Equal(t, "https://example.com/account/password", recorder.Header().Get("Location"))
+		assert.Equal(t, http.StatusNotFound, get(router, SecurityTxtPath).Code)
+	})
+
+	t.Run("Discovery_Should_Pass_Through_And_Cache_The_Issuer_Document", func(t *testing.T) {
+		fetches := int32(0)
+		available := int32(1)
+		issuer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			atomic.AddInt32(&fetches, 1)
+			if atomic.LoadInt32(&available) == 0 || request.URL.Path != "/.well-known/openid-configuration" {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writer.Header().Set("Cache-Control", "public, max-age=0")
+			writer.Write([]byte(`{"issuer":"https://auth.example.com"}`))
+		}))
+		defer issuer.Close()
+		router := newTestRouter(t, config.WellKnownConfig{OIDC: config.OIDCDiscoveryConfig{
+			IssuerURL: issuer.URL + "/",
+			Documents: []string{"openid-configuration"},
+		}})
+
+		recorder := get(router, "/.well-known/openid-configuration")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `{"issuer":"https://auth.example.com"}`, recorder.Body.String())
+
+		atomic.StoreInt32(&available, 0)
+		recorder = get(router, "/.well-known/openid-configuration")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `{"issuer":"https://auth.example.com"}`, recorder.Body.String())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+	})
+
+	t.Run("Discovery_Should_Fail_Without_A_Cached_Document", func(t *testing.T) {
+		issuer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer issuer.Close()
+		router := newTestRouter(t, config.WellKnownConfig{OIDC: config.OIDCDiscoveryConfig{
+			IssuerURL: issuer.URL,
+			Documents: []string{"openid-configuration"},
+		}})
+
+		recorder := get(router, "/.well-known/openid-configuration")
+
+		assert.Equal(t, http.StatusBadGateway, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "discovery_unavailable")
+	})
+}
+
+func TestAppAssociations(t *testing.T) {
+	t.Run("AppleAppSiteAssociation_Should_Serve_App_Links_And_Credentials", func(t *testing.T) {
+		router := newTestRouter(t, config.WellKnownConfig{AppleAppSiteAssociation: config.AppleAppSiteAssociationConfig{
+			AppLinks: []config.AppleAppLinkConfig{
+				{AppIDs: []string{"TEAMID.com.quadev.app"}, Paths: []string{"NOT /api/*", "/invite/*"}},
+			},
+			WebCredentials: []string{"TEAMID.com.quadev.app"},
+		}})
+
+		for _, path := range []string{AppleAppSiteAssociationPath, LegacyAppleAppSiteAssociationPath} {
+			recorder := get(router, path)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			assert.JSONEq(t, `{
+				"applinks": {"details": [{
+					"appIDs": ["TEAMID.com.quadev.app"],
+					"components": [{"/": "/api/*", "exclude": true}, {"/": "/invite/*"}]
+				}]},
+				"webcredentials": {"apps": ["TEAMID.com.quadev.app"]}
+			}`, recorder.Body.String())
+		}
+		assert.Equal(t, http.StatusNotFound, get(router, AndroidAssetLinksPath).Code)
+	})
+
+	t.Run("AndroidAssetLinks_Should_Serve_The_Statements_With_Default_Relations", func(t *testing.T) {
+		router := newTestRouter(t, config.WellKnownConfig{AndroidAssetLinks: []config.AndroidAssetLinkConfig{
+			{PackageName: "com.quadev.app", SHA256CertFingerprints: []string{"AB:CD"}},
+		}})
+
+		recorder := get(router, AndroidAssetLinksPath)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		assert.JSONEq(t, `[{
+			"relation": ["delegate_permission/common.handle_all_urls", "delegate_permission/common.get_login_creds"],
+			"target": {"namespace": "android_app", "package_name": "com.quadev.app", "sha256_cert_fingerprints": ["AB:CD"]}
+		}]`, recorder.Body.String())
+	})
+
+	t.Run("NewWellKnown_Should_Reject_Incomplete_App_Associations", func(t *testing.T) {
+		_, err := NewWellKnown(&config.Config{WellKnown: config.WellKnownConfig{
+			AndroidAssetLinks: []config.AndroidAssetLinkConfig{{PackageName: "com.quadev.app"}},
+		}}, nil)
+		assert.Error(t, err)
+
+		_, err = NewWellKnown(&config.Config{WellKnown: config.WellKnownConfig{
+			AppleAppSiteAssociation: config.AppleAppSiteAssociationConfig{
+				AppLinks: []config.AppleAppLinkConfig{{Paths: []string{"/invite/*"}}},
+			},
+		}}, nil)
+		assert.Error(t, err)
+	})
+}
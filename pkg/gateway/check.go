@@ -0,0 +1,211 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	commonTLS "github.com/quadev-ltd/qd-common/pkg/tls"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/certificates"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/httpclient"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/lifecycle"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/oidc"
+)
+
+// Statuses of the startup checks
+const (
+	CheckPassed  = "passed"
+	CheckFailed  = "failed"
+	CheckSkipped = "skipped"
+)
+
+// DefaultCheckTimeout bounds every check dialing the upstream services when no timeout is given
+const DefaultCheckTimeout = 5 * time.Second
+
+// CheckResult is the outcome of a single startup check
+type CheckResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// CheckReport is the machine-readable report of the startup checks, it passes when no check failed
+type CheckReport struct {
+	Passed      bool          `json:"passed"`
+	Environment string        `json:"environment"`
+	Checks      []CheckResult `json:"checks"`
+}
+
+// run records the outcome of the check, a check returning a detail and no error passes
+func (report *CheckReport) run(name string, check func() (string, error)) bool {
+	start := time.Now()
+	detail, err := check()
+	result := CheckResult{Name: name, Status: CheckPassed, Detail: detail, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = CheckFailed
+		result.Error = err.Error()
+		report.Passed = false
+	}
+	report.Checks = append(report.Checks, result)
+	return err == nil
+}
+
+func (report *CheckReport) skip(name, reason string) {
+	report.Checks = append(report.Checks, CheckResult{Name: name, Status: CheckSkipped, Detail: reason})
+}
+
+// CheckConfig loads the configuration of the directory and checks it, see Check
+func CheckConfig(path string, timeout time.Duration, opts ...Option) *CheckReport {
+	var configuration *Config
+	report := &CheckReport{Passed: true}
+	loaded := report.run("config", func() (string, error) {
+		var err error
+		configuration, err = LoadConfig(path)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Loaded the %s configuration from %s", configuration.Environment, path), nil
+	})
+	if !loaded {
+		return report
+	}
+	checked := Check(configuration, timeout, opts...)
+	checked.Passed = checked.Passed && report.Passed
+	checked.Checks = append(report.Checks, checked.Checks...)
+	return checked
+}
+
+// Check dials the upstream services, fetches the public key, validates the TLS material and builds
+// the gateway without serving it, so a misconfiguration fails the deployment before the rollout
+func Check(configuration *Config, timeout time.Duration, opts ...Option) *CheckReport {
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	report := &CheckReport{Passed: true, Environment: configuration.Environment}
+	server := &Server{configuration: configuration}
+	for _, opt := range opts {
+		opt(&server.serverOptions)
+	}
+	server.ctx, server.cancel = context.WithCancel(context.Background())
+	server.lifecycle = lifecycle.NewManager(configuration)
+	defer server.Stop(context.Background())
+
+	if !report.run("central_config", func() (string, error) {
+		if err := server.loadCentralConfig(); err != nil {
+			return "", err
+		}
+		return "", server.setupEgress()
+	}) {
+		report.skip("tls", "The central configuration could not be loaded")
+		report.skip("public_key", "The central configuration could not be loaded")
+		report.skip("server", "The central configuration could not be loaded")
+		return report
+	}
+	report.run("tls", func() (string, error) {
+		return checkTLSMaterial(configuration, server.centralConfig.TLSEnabled, time.Now())
+	})
+	authenticationService := server.centralConfig.AuthenticationService
+	for _, upstreamService := range upstreams(configuration, fmt.Sprintf("%s:%s", authenticationService.Host, authenticationService.Port)) {
+		upstreamService := upstreamService
+		report.run("upstream:"+upstreamService.service, func() (string, error) {
+			return dialUpstream(upstreamService.service, upstreamService.address, server.centralConfig.TLSEnabled, configuration, timeout)
+		})
+	}
+	publicKeyFetched := report.run("public_key", func() (string, error) {
+		ctx, cancel := context.WithTimeout(server.ctx, timeout)
+		defer cancel()
+		publicKey, err := authentication.FetchPublicKey(ctx, &server.centralConfig, configuration)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Fetched a public key of %d bytes", len(publicKey)), nil
+	})
+
+	if configuration.OIDC.Enabled {
+		report.run("oidc", func() (string, error) {
+			oidcClient, err := httpclient.New("oidc", configuration)
+			if err != nil {
+				return "", err
+			}
+			federation, err := oidc.NewFederation(configuration, oidcClient)
+			if err != nil {
+				return "", err
+			}
+			ctx, cancel := context.WithTimeout(server.ctx, timeout)
+			defer cancel()
+			if err := federation.Refresh(ctx); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Fetched the keys of %d OIDC providers", federation.Providers()), nil
+		})
+	}
+
+	// The gateway fetches the public key while it is built, retrying with backoff, so it is only built once the key was fetched
+	server.Stop(context.Background())
+	if !publicKeyFetched {
+		report.skip("server", "The public key could not be fetched")
+		return report
+	}
+	report.run("server", func() (string, error) {
+		checkedServer, err := NewServer(configuration, opts...)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Built the gateway with %d jobs", len(checkedServer.jobScheduler.Stats())), checkedServer.Stop(context.Background())
+	})
+	return report
+}
+
+// checkTLSMaterial loads the CA certificate of the upstream connections and the certificate files monitored for
+// their expiry, failing on the expired ones
+func checkTLSMaterial(configuration *Config, tlsEnabled bool, now time.Time) (string, error) {
+	for _, socket := range configuration.UnixSockets.Upstreams {
+		tlsEnabled = tlsEnabled || socket.TLSEnabled
+	}
+	if tlsEnabled {
+		if _, err := commonTLS.CreateTLSConfig(); err != nil {
+			return "", err
+		}
+	}
+	checked := 0
+	for _, file := range certificates.MonitoredFiles(configuration) {
+		loaded, err := certificates.LoadCertificates(file)
+		if err != nil {
+			return "", err
+		}
+		for _, certificate := range loaded {
+			if now.After(certificate.NotAfter) {
+				return "", fmt.Errorf("Certificate %s in %s expired on %s", certificate.Subject, file, certificate.NotAfter.Format(time.RFC3339))
+			}
+		}
+		checked += len(loaded)
+	}
+	if !tlsEnabled && checked == 0 {
+		return "No TLS material is configured", nil
+	}
+	return fmt.Sprintf("Loaded the CA certificate: %t, checked %d certificates", tlsEnabled, checked), nil
+}
+
+// dialUpstream connects to the service as the gateway does and waits for the connection to be ready, the
+// connection is shared with the gateway built afterwards and closed when it stops
+func dialUpstream(service, address string, tlsEnabled bool, configuration *Config, timeout time.Duration) (string, error) {
+	connection, err := egress.CreateServiceConnection(service, address, tlsEnabled, configuration)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	connection.Connect()
+	for state := connection.GetState(); state != connectivity.Ready; state = connection.GetState() {
+		if !connection.WaitForStateChange(ctx, state) {
+			return "", fmt.Errorf("Could not connect to the %s service at %s within %s, the connection is %s", service, address, timeout, state)
+		}
+	}
+	return fmt.Sprintf("Connected to %s", address), nil
+}
@@ -0,0 +1,1078 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commontConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/admin"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/alerting"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/analytics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/attestation"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/captcha"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/certificates"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/cors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/crawler"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/deadline"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/dnscache"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/drift"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/entitlement"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/events"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/experiments"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/fakebackend"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/fallback"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/health"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/httpclient"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/journal"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/leader"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/lifecycle"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/media"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/mesh"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/metrics"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/middleware"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/noise"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/notification"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/oidc"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/payment"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/policy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/preferences"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/public"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/redis"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/reference"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/region"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/resilience"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/responsecache"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/routeregistry"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/routetable"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scripting"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/search"
+	searchRoutes "github.com/quadev-ltd/qd-qpi-gateway/internal/search/routes"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/serverless"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/session"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/signing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/support"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tap"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tenancy"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/tracing"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/transcoding"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/unixsocket"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/validation"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/versioning"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/wasmfilter"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/websocket"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/wellknown"
+	"github.com/quadev-ltd/qd-qpi-gateway/pkg/hooks"
+)
+
+// APIPath is the path of the API
+const APIPath = "/api/v1"
+
+// Config is the typed configuration of the gateway
+type Config = config.Config
+
+// LoadConfig loads the configuration of the environment from the yml files of the directory
+func LoadConfig(path string) (*Config, error) {
+	configuration := &Config{}
+	if err := configuration.Load(path); err != nil {
+		return nil, err
+	}
+	return configuration, nil
+}
+
+// Server is the gateway, its egress proxy, DNS cache and tracer are process wide
+// so a process runs a single server
+type Server struct {
+	configuration *Config
+	centralConfig commontConfig.Config
+	router        *gin.Engine
+	handler       http.Handler
+	jobScheduler  *scheduler.Scheduler
+	fakeBackends  *fakebackend.Backends
+	httpServer    *http.Server
+	socketServer  *http.Server
+	// httpRedirect listens for the plain HTTP requests when the gateway terminates the TLS
+	httpRedirect  *http.Server
+	listenAddress string
+	ctx           context.Context
+	cancel        context.CancelFunc
+	stopBackends  sync.Once
+	lifecycle     *lifecycle.Manager
+	routeTable    *routetable.Exporter
+	jobs          []Job
+	// flushes are the final flushes of the buffers of the router, run once the requests are drained
+	flushes       []lifecycle.Hook
+	serverOptions options
+	// root is the server a reloaded generation of the router is built for, nil for the server itself
+	root          *Server
+	serving       *handlerSwitch
+	stopJobs      context.CancelFunc
+	generationMtx sync.RWMutex
+	applied       *Config
+	reloadMtx     sync.Mutex
+	// rateLimiter is kept by the reloaded generations of the router so the clients keep their buckets
+	rateLimiter *ratelimit.Limiter
+	kept        keptComponents
+}
+
+// keptComponents are the components the reloaded generations of the router keep from the current one, their
+// sections needing a restart, so a reload drops neither the buffered records, the open streams nor the cached state
+type keptComponents struct {
+	tracer        *tracing.Tracer
+	trafficTap    *tap.Tap
+	journal       *journal.Journal
+	wasmFilters   *wasmfilter.Filters
+	responseStore responsecache.Storer
+	searchCache   searchRoutes.ResultCacher
+	activityStore session.ActivityStorer
+	localization  *preferences.Resolver
+}
+
+// keptFromRoot returns the components of the router a reloaded generation is built for, none for the server itself
+func (server *Server) keptFromRoot() keptComponents {
+	if server.root == nil {
+		return keptComponents{}
+	}
+	return server.root.kept
+}
+
+// NewServer creates the gateway of the configuration, registering its middlewares, routes and jobs
+func NewServer(configuration *Config, opts ...Option) (*Server, error) {
+	server := &Server{configuration: configuration}
+	for _, opt := range opts {
+		opt(&server.serverOptions)
+	}
+	server.ctx, server.cancel = context.WithCancel(context.Background())
+	server.lifecycle = lifecycle.NewManager(configuration)
+	if err := server.loadCentralConfig(); err != nil {
+		return nil, err
+	}
+	server.jobScheduler = scheduler.NewScheduler(configuration)
+	if err := server.build(); err != nil {
+		server.Stop(context.Background())
+		return nil, err
+	}
+	if err := server.scheduleJobs(); err != nil {
+		server.Stop(context.Background())
+		return nil, err
+	}
+	server.serving = newHandlerSwitch(server.handler)
+	server.listenAddress = fmt.Sprintf("%s:%s", server.centralConfig.GatewayService.Host, server.centralConfig.GatewayService.Port)
+	if serverless.DetectPlatform(configuration.Serverless.Platform) == serverless.PlatformCloudRun {
+		server.listenAddress = serverless.ListenAddress(server.listenAddress)
+	}
+	server.httpServer = &http.Server{Addr: server.listenAddress, Handler: server.serving}
+	server.socketServer = &http.Server{Handler: server.serving}
+	server.lifecycle.Serve(server.httpServer)
+	server.lifecycle.Serve(server.socketServer)
+	if configuration.HTTPS.Enabled {
+		if err := server.setupHTTPS(); err != nil {
+			server.Stop(context.Background())
+			return nil, err
+		}
+	}
+	return server, nil
+}
+
+// setupHTTPS terminates the TLS on the listen address and listens for the plain HTTP requests to redirect them
+func (server *Server) setupHTTPS() error {
+	https, err := certificates.NewHTTPS(server.configuration)
+	if err != nil {
+		return fmt.Errorf("Failed to create HTTPS: %v", err)
+	}
+	server.httpServer.TLSConfig = https.TLSConfig()
+	redirectConfig := server.configuration.HTTPS.Redirect
+	if redirectConfig.Enabled && redirectConfig.Address != "" {
+		server.httpRedirect = &http.Server{Addr: redirectConfig.Address, Handler: https.Handler()}
+		server.lifecycle.Serve(server.httpRedirect)
+	}
+	return nil
+}
+
+// scheduleJobs registers the jobs of the built server and the extra jobs
+func (server *Server) scheduleJobs() error {
+	for _, job := range append(server.jobs, server.serverOptions.jobs...) {
+		if err := server.jobScheduler.Register(job); err != nil {
+			return fmt.Errorf("Failed to schedule job: %v", err)
+		}
+	}
+	return nil
+}
+
+func (server *Server) loadCentralConfig() error {
+	configuration := server.configuration
+	switch {
+	case server.serverOptions.centralConfig != nil:
+		server.centralConfig = *server.serverOptions.centralConfig
+	case configuration.FakeBackends.Enabled:
+		fakeBackends, err := fakebackend.Start(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to start fake backends: %v", err)
+		}
+		server.fakeBackends = fakeBackends
+		server.centralConfig.AuthenticationService = fakeBackends.AuthenticationService
+		server.centralConfig.GatewayService = commontConfig.Address{
+			Host: configuration.FakeBackends.GatewayHost,
+			Port: configuration.FakeBackends.GatewayPort,
+		}
+		fmt.Println("Running against fake backends, authentication service at", fakeBackends.AuthenticationService)
+	default:
+		if err := server.centralConfig.Load(
+			configuration.Environment,
+			configuration.AWS.Key,
+			configuration.AWS.Secret,
+		); err != nil {
+			return fmt.Errorf("Failed to load central configuration: %v", err)
+		}
+	}
+	return nil
+}
+
+// upstream is the address of an upstream service
+type upstream struct {
+	service string
+	address string
+}
+
+// upstreams returns the enabled upstream services, the authentication service is always used
+func upstreams(configuration *Config, authenticationAddress string) []upstream {
+	enabled := []upstream{{service: "authentication", address: authenticationAddress}}
+	for _, service := range []struct {
+		name   string
+		config config.ServiceConfig
+	}{
+		{name: "notification", config: configuration.NotificationService},
+		{name: "payment", config: configuration.PaymentService.ServiceConfig},
+		{name: "media", config: configuration.MediaService.ServiceConfig},
+		{name: "search", config: configuration.SearchService.ServiceConfig},
+		{name: "support", config: configuration.SupportService.ServiceConfig},
+		{name: "reference", config: configuration.ReferenceService.ServiceConfig},
+		{name: "subscription", config: configuration.Entitlements.ServiceConfig},
+	} {
+		if service.config.Enabled {
+			enabled = append(enabled, upstream{service: service.name, address: fmt.Sprintf("%s:%s", service.config.Host, service.config.Port)})
+		}
+	}
+	if configuration.UpstreamRoutes.Enabled {
+		for _, service := range configuration.UpstreamRoutes.Services {
+			enabled = append(enabled, upstream{service: service.Name, address: fmt.Sprintf("%s:%s", service.Host, service.Port)})
+		}
+	}
+	if configuration.Transcoding.Enabled {
+		for _, service := range configuration.Transcoding.Services {
+			enabled = append(enabled, upstream{service: service.Name, address: fmt.Sprintf("%s:%s", service.Host, service.Port)})
+		}
+	}
+	return enabled
+}
+
+// setupEgress resolves the upstream addresses through the DNS cache, routes the outbound traffic through the proxy
+// and loads the TLS material of the upstream services
+func (server *Server) setupEgress() error {
+	configuration := server.configuration
+	if configuration.DNS.Enabled {
+		dnsCache := dnscache.NewCache(configuration)
+		dnscache.Register(dnsCache)
+		server.jobs = append(server.jobs, dnsCache.Job())
+	}
+	if configuration.EgressProxy.Enabled {
+		egressProxy, err := egress.NewProxy(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create egress proxy: %v", err)
+		}
+		egress.Use(egressProxy)
+	}
+	if len(configuration.UpstreamTLS.Services) > 0 {
+		upstreamTLS, err := egress.NewUpstreamTLS(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to load the upstream TLS: %v", err)
+		}
+		egress.UseTLS(upstreamTLS)
+		server.jobs = append(server.jobs, upstreamTLS.Job())
+	}
+	return nil
+}
+
+// createRateLimiter creates the limiter of the configured groups. A reloaded generation keeps the limiter of the
+// current router with its buckets, only replacing its groups and costs
+func (server *Server) createRateLimiter() (*ratelimit.Limiter, error) {
+	configuration := server.configuration
+	if server.root != nil && server.root.rateLimiter != nil {
+		rateLimiter := server.root.rateLimiter
+		if err := rateLimiter.SetGroups(configuration.RateLimits.Groups); err != nil {
+			return nil, fmt.Errorf("Failed to update rate limiter: %v", err)
+		}
+		if err := rateLimiter.SetCosts(configuration.RateLimits.Costs); err != nil {
+			return nil, fmt.Errorf("Failed to update rate limiter: %v", err)
+		}
+		return rateLimiter, nil
+	}
+	rateLimiter, err := ratelimit.NewLimiter(configuration)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create rate limiter: %v", err)
+	}
+	if configuration.RateLimits.WarningPercentage > 0 {
+		warningPublisher, err := events.NewPublisher(configuration)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create rate limit warning event publisher: %v", err)
+		}
+		rateLimiter.UsePublisher(warningPublisher)
+	}
+	return rateLimiter, nil
+}
+
+// build builds the router of the configuration, failing with the report of the conflicting routes where gin
+// would panic on them or silently serve one of them
+func (server *Server) build() (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		var routes gin.RoutesInfo
+		if server.router != nil {
+			routes = server.router.Routes()
+		}
+		if err = routetable.Recovered(routes, recovered); err == nil {
+			panic(recovered)
+		}
+	}()
+	if err := server.buildRouter(); err != nil {
+		return err
+	}
+	return routetable.CheckConflicts(server.router.Routes())
+}
+
+func (server *Server) buildRouter() error {
+	configuration := server.configuration
+	if err := server.setupEgress(); err != nil {
+		return err
+	}
+	var hookChain *hooks.Chain
+	if configuration.Hooks.Enabled {
+		var err error
+		hookChain, err = hooks.Load(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to load hooks: %v", err)
+		}
+		hooks.Use(hookChain)
+	}
+
+	router := gin.New()
+	server.router = router
+	// Handlers passing the gin context as a context still cancel their upstream calls when the client disconnects
+	router.ContextWithFallback = true
+	// Registered first so the route table export sees the handlers of the routes without running any of them
+	router.Use(routetable.Describe)
+	if configuration.QueryRedaction.Enabled {
+		// Registered first so the requests aborted by any later middleware are still recorded redacted
+		queryRedactor, err := middleware.NewQueryRedactor(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create query redactor: %v", err)
+		}
+		router.Use(queryRedactor.Middleware)
+	}
+	if configuration.Metrics.Enabled {
+		// Registered ahead of the recovery so the requests ending in a panic are counted with their 500
+		router.Use(metrics.DefaultMetrics.Middleware)
+		metrics.DefaultMetrics.Register(router, configuration)
+	}
+	if configuration.Tracing.Enabled {
+		tracer := server.keptFromRoot().tracer
+		if tracer == nil {
+			tracingClient, err := httpclient.New("tracing", configuration)
+			if err != nil {
+				return fmt.Errorf("Failed to create tracing HTTP client: %v", err)
+			}
+			tracer, err = tracing.NewTracer(configuration, tracingClient)
+			if err != nil {
+				return fmt.Errorf("Failed to create tracer: %v", err)
+			}
+		}
+		server.kept.tracer = tracer
+		tracing.Use(tracer)
+		router.Use(tracer.Middleware)
+		server.jobs = append(server.jobs, tracer.FlushJob())
+		server.flushes = append(server.flushes, lifecycle.Hook{Name: "tracing", Run: func(ctx context.Context) error {
+			return tracer.Flush(ctx, time.Now())
+		}})
+	}
+	router.Use(gin.LoggerWithFormatter(middleware.AccessLogFormatter), gin.Recovery())
+	if configuration.ResponseHeaders.Enabled {
+		router.Use(middleware.NewHeaderStripper(configuration).Middleware)
+	}
+	router.Use(middleware.CorrelationID)
+	if configuration.HTTPS.Redirect.Enabled {
+		router.Use(certificates.NewRedirect(configuration).Middleware)
+	}
+	if configuration.HTTPS.HSTS.Enabled {
+		router.Use(certificates.NewHSTS(configuration).Middleware)
+	}
+	if configuration.Mesh.Enabled {
+		router.Use(mesh.NewPropagator(configuration).Middleware)
+	}
+	logger := commonLogger.NewLogFactory(configuration.Environment)
+	router.Use(commonLogger.CreateGinLoggerMiddleware(logger))
+	if configuration.RequestLog.Enabled {
+		// Registered once the request logger is in the context, the user is read after the authentication ran
+		requestLogger, err := middleware.NewRequestLogger(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create request logger: %v", err)
+		}
+		router.Use(requestLogger.Middleware)
+	}
+	if configuration.ResponseSigning.Enabled {
+		responseSigner, err := signing.RegisterRoutes(router, configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create response signer: %v", err)
+		}
+		router.Use(responseSigner.Middleware)
+	}
+	if configuration.CORS.Enabled {
+		corsMiddleware, err := cors.NewCORS(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create CORS middleware: %v", err)
+		}
+		router.Use(corsMiddleware.Middleware)
+	}
+	if hookChain != nil {
+		router.Use(hookChain.PostResponse)
+	}
+	fallback.Register(router, configuration)
+	if configuration.Crawlers.Throttle.Enabled {
+		crawlerThrottler, err := crawler.NewThrottler(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create crawler throttler: %v", err)
+		}
+		router.Use(crawlerThrottler.Middleware)
+	}
+	crawler.RegisterRoutes(router, configuration)
+	wellKnownClient, err := httpclient.New("well_known", configuration)
+	if err != nil {
+		return fmt.Errorf("Failed to create well-known HTTP client: %v", err)
+	}
+	if _, err := wellknown.RegisterRoutes(router, configuration, wellKnownClient); err != nil {
+		return fmt.Errorf("Failed to register well-known routes: %v", err)
+	}
+	if configuration.Analytics.Enabled {
+		analyticsStore, err := analytics.NewS3Store(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create analytics report store: %v", err)
+		}
+		analyticsCollector := analytics.NewCollector(configuration)
+		analyticsExporter, err := analytics.NewExporter(analyticsCollector, analyticsStore, configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create analytics report exporter: %v", err)
+		}
+		router.Use(analyticsCollector.Middleware)
+		server.jobs = append(server.jobs, analyticsExporter.Job())
+	}
+	trafficTap := server.keptFromRoot().trafficTap
+	if trafficTap == nil {
+		trafficTap = tap.NewTap(configuration)
+	}
+	server.kept.trafficTap = trafficTap
+	if configuration.TrafficTap.Enabled {
+		router.Use(trafficTap.Middleware)
+	}
+	if configuration.Journal.Enabled {
+		requestJournal := server.keptFromRoot().journal
+		if requestJournal == nil {
+			requestJournal, err = journal.NewJournal(configuration)
+			if err != nil {
+				return fmt.Errorf("Failed to create request journal: %v", err)
+			}
+		}
+		server.kept.journal = requestJournal
+		router.Use(requestJournal.Middleware)
+		server.jobs = append(server.jobs, requestJournal.FlushJob(), requestJournal.RetentionJob())
+		server.flushes = append(server.flushes, lifecycle.Hook{Name: "journal", Run: func(ctx context.Context) error {
+			return requestJournal.Flush(ctx, time.Now())
+		}})
+	}
+
+	alertingClient, err := httpclient.New("alerting", configuration)
+	if err != nil {
+		return fmt.Errorf("Failed to create alerting HTTP client: %v", err)
+	}
+	alerter := alerting.NewAlerter(configuration, alertingClient)
+	if configuration.Alerting.Enabled {
+		authErrorRateCondition := alerting.NewAuthErrorRateCondition(
+			configuration.Alerting.AuthErrorRateThreshold,
+			configuration.Alerting.AuthErrorRateWindow,
+		)
+		alerter.RegisterCondition(authErrorRateCondition)
+		router.Use(alerting.AuthFailureMonitorMiddleware(authErrorRateCondition))
+		alerter.RegisterCondition(alerting.NewBreakerOpenCondition(
+			resilience.DefaultBreakers,
+			configuration.Alerting.BreakerOpenDuration,
+		))
+	}
+
+	certificateMonitor := certificates.NewMonitor(configuration)
+	server.jobs = append(server.jobs, certificateMonitor.Job())
+	if configuration.Alerting.Enabled {
+		alerter.RegisterCondition(certificateMonitor)
+		server.jobs = append(server.jobs, alerter.Job())
+	}
+	router.Use(server.serverOptions.middlewares...)
+
+	api := router.Group(APIPath)
+	routeTable := routetable.NewExporter(router, APIPath, configuration)
+	server.routeTable = routeTable
+	versionEnforcer, err := versioning.NewEnforcer(configuration)
+	if err != nil {
+		return fmt.Errorf("Failed to create app version enforcer: %v", err)
+	}
+	api.Use(versionEnforcer.Middleware)
+	if configuration.Region.AllowOverride {
+		api.Use(region.NewSelector(configuration).Middleware)
+	}
+	if configuration.Timeouts.Enabled {
+		timeouts := deadline.NewTimeouts(configuration)
+		routeTable.UseTimeouts(timeouts)
+		api.Use(timeouts.Middleware)
+	}
+	var rateLimiter *ratelimit.Limiter
+	if configuration.RateLimits.Enabled {
+		rateLimiter, err = server.createRateLimiter()
+		if err != nil {
+			return err
+		}
+		server.rateLimiter = rateLimiter
+		routeTable.UseRateLimiter(rateLimiter)
+		api.Use(rateLimiter.Middleware)
+		server.jobs = append(server.jobs, rateLimiter.Job())
+	}
+	if configuration.Validation.Enabled {
+		validator, err := validation.NewValidator(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create request validator: %v", err)
+		}
+		api.Use(validator.Middleware)
+	}
+	localization := server.keptFromRoot().localization
+	if localization == nil {
+		localization = preferences.NewResolver(nil, configuration)
+	}
+	server.kept.localization = localization
+	if len(configuration.Preferences.Labels) > 0 {
+		api.Use(preferences.NewLabeler(localization, configuration).Middleware)
+	}
+	if configuration.Attestation.Enabled {
+		attestationClient, err := httpclient.New("attestation", configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create attestation HTTP client: %v", err)
+		}
+		attestationVerifiers := attestation.NewVerifiers(configuration, attestationClient)
+		api.Use(attestation.NewGate(attestationVerifiers, configuration).RequireAttestation)
+	}
+
+	var experimentAssigner *experiments.Assigner
+	if configuration.Experiments.Enabled {
+		eventPublisher, err := events.NewPublisher(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create experiments event publisher: %v", err)
+		}
+		experimentAssigner, err = experiments.NewAssigner(eventPublisher, configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create experiment assigner: %v", err)
+		}
+		api.Use(experimentAssigner.Middleware)
+		if configuration.Experiments.ExposureTTL > 0 {
+			server.jobs = append(server.jobs, experimentAssigner.Job())
+		}
+	}
+	api.Use(server.serverOptions.apiMiddlewares...)
+	if hookChain != nil {
+		api.Use(hookChain.PreAuth)
+	}
+	if configuration.WASMFilters.Enabled {
+		wasmFilters := server.keptFromRoot().wasmFilters
+		if wasmFilters == nil {
+			wasmFilters, err = wasmfilter.NewFilters(configuration)
+			if err != nil {
+				return fmt.Errorf("Failed to create WASM filters: %v", err)
+			}
+		}
+		server.kept.wasmFilters = wasmFilters
+		api.Use(wasmFilters.Middleware)
+		server.flushes = append(server.flushes, lifecycle.Hook{Name: "wasm_filters", Run: wasmFilters.Close})
+		if configuration.WASMFilters.ReloadInterval > 0 {
+			server.jobs = append(server.jobs, wasmFilters.Job())
+		}
+	}
+	if configuration.Scripts.Enabled {
+		scripts, err := scripting.NewScripts(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create route scripts: %v", err)
+		}
+		api.Use(scripts.Middleware)
+		if configuration.Scripts.ReloadInterval > 0 {
+			server.jobs = append(server.jobs, scripts.Job())
+		}
+	}
+	var policyBundles *policy.Bundles
+	if configuration.PolicyBundles.Enabled {
+		policyBundles, err = policy.NewBundles(configuration, rateLimiter)
+		if err != nil {
+			return fmt.Errorf("Failed to create policy bundles: %v", err)
+		}
+		api.Use(policyBundles.Middleware)
+	}
+	var responseCache *responsecache.Cache
+	if configuration.ResponseCache.Enabled {
+		if responseStore := server.keptFromRoot().responseStore; responseStore != nil {
+			responseCache, err = responsecache.NewCacheWithStore(responseStore, configuration)
+		} else {
+			responseCache, err = responsecache.NewCache(configuration)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to create response cache: %v", err)
+		}
+		server.kept.responseStore = responseCache.Store()
+		api.Use(responseCache.Middleware)
+	}
+	var driftDetector *drift.Detector
+	if configuration.ConfigDrift.Enabled {
+		driftDetector, err = drift.NewDetector(redis.NewClient(configuration), configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create config drift detector: %v", err)
+		}
+		if policyBundles != nil {
+			driftDetector.Track("policy_bundle", func() string { return policyBundles.Active().Version })
+		}
+		server.jobs = append(server.jobs, driftDetector.Job())
+	}
+
+	publicRoutes := public.NewGroup(api, configuration)
+	if configuration.Captcha.Enabled {
+		captchaClient, err := httpclient.New("captcha", configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create captcha HTTP client: %v", err)
+		}
+		captchaVerifier, err := captcha.NewVerifier(configuration, captchaClient)
+		if err != nil {
+			return fmt.Errorf("Failed to create captcha verifier: %v", err)
+		}
+		captchaMiddleware, err := captcha.NewMiddleware(captchaVerifier, configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create captcha middleware: %v", err)
+		}
+		publicRoutes.UseCaptcha(captchaMiddleware.Check)
+	}
+
+	centralConfig := &server.centralConfig
+	if configuration.Health.Enabled {
+		healthChecker := health.NewChecker(configuration)
+		authenticationService := centralConfig.AuthenticationService
+		for _, upstreamService := range upstreams(configuration, fmt.Sprintf("%s:%s", authenticationService.Host, authenticationService.Port)) {
+			if err := healthChecker.Dial(upstreamService.service, upstreamService.address, centralConfig.TLSEnabled); err != nil {
+				return fmt.Errorf("Failed to connect the health checks to the %s service: %v", upstreamService.service, err)
+			}
+		}
+		for name, connection := range server.serverOptions.healthChecks {
+			healthChecker.Add(name, connection)
+		}
+		healthChecker.UseDrainer(server.reloader().lifecycle)
+		healthChecker.Register(router)
+	}
+	_, authenticationMiddleware, err := authentication.RegisterRoutes(api, publicRoutes, centralConfig, configuration, localization)
+	if err != nil {
+		return fmt.Errorf("Failed to register authentication routes: %v", err)
+	}
+	// The API keys wrap the middleware, the public key is described by the middleware verifying the tokens
+	publicKeyDescriber, _ := authenticationMiddleware.(authentication.PublicKeyDescriber)
+	if activityTracker, ok := authenticationMiddleware.(authentication.ActivityTracker); ok {
+		if activityStore := server.keptFromRoot().activityStore; activityStore != nil {
+			activityTracker.UseActivityStore(activityStore)
+		}
+		server.kept.activityStore = activityTracker.ActivityStore()
+	}
+	if configuration.OIDC.Enabled {
+		tokenFederator, ok := authenticationMiddleware.(authentication.TokenFederator)
+		if !ok {
+			return fmt.Errorf("The authentication middleware can not verify the tokens of the OIDC providers")
+		}
+		oidcClient, err := httpclient.New("oidc", configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create OIDC HTTP client: %v", err)
+		}
+		federation, err := oidc.NewFederation(configuration, oidcClient)
+		if err != nil {
+			return fmt.Errorf("Failed to create OIDC federation: %v", err)
+		}
+		tokenFederator.UseFederation(federation)
+		if configuration.OIDC.RefreshInterval > 0 {
+			server.jobs = append(server.jobs, federation.Job())
+		}
+	}
+	if experimentAssigner != nil {
+		authenticationMiddleware.OnAuthenticated(experimentAssigner.OnAuthenticated)
+	}
+	if configuration.Tenancy.Enabled {
+		tenantOverrides, err := tenancy.NewOverrides(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create tenant overrides: %v", err)
+		}
+		authenticationMiddleware.OnAuthenticated(tenantOverrides.OnAuthenticated)
+	}
+	if rateLimiter != nil {
+		authenticationMiddleware.OnAuthenticated(rateLimiter.OnAuthenticated)
+	}
+	if hookChain != nil {
+		authenticationMiddleware.OnAuthenticated(hookChain.PostAuth)
+	}
+	if policyBundles != nil {
+		policyBundles.UseAuthentication(authenticationMiddleware)
+		authenticationMiddleware.OnAuthenticated(policyBundles.OnAuthenticated)
+	}
+	if configuration.Entitlements.Enabled {
+		if _, err := entitlement.RegisterChecker(centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to create entitlement checker: %v", err)
+		}
+	}
+	if responseCache != nil {
+		authenticationMiddleware.OnAuthenticated(responseCache.OnAuthenticated)
+	}
+	if configuration.Authentication.PublicKeyRefreshInterval > 0 {
+		server.jobs = append(server.jobs, authenticationMiddleware.PublicKeyRefreshJob())
+	}
+	if configuration.TokenRevocation.Enabled {
+		server.jobs = append(server.jobs, authenticationMiddleware.TokenRevocationJob())
+	}
+	if configuration.APIKeys.Enabled {
+		var apiKeysClient *http.Client
+		if configuration.APIKeys.Store == authentication.APIKeyStoreService {
+			apiKeysClient, err = httpclient.New("api_keys", configuration)
+			if err != nil {
+				return fmt.Errorf("Failed to create API keys HTTP client: %v", err)
+			}
+		}
+		apiKeyStore, err := authentication.NewAPIKeyStore(configuration, apiKeysClient)
+		if err != nil {
+			return fmt.Errorf("Failed to create API key store: %v", err)
+		}
+		if fileStore, ok := apiKeyStore.(*authentication.FileAPIKeyStore); ok && configuration.APIKeys.ReloadInterval > 0 {
+			server.jobs = append(server.jobs, fileStore.Job())
+		}
+		apiKeyMiddleware := authentication.NewAPIKeyMiddleware(apiKeyStore, configuration)
+		if responseCache != nil {
+			apiKeyMiddleware.OnAuthenticated(responseCache.OnAPIKeyAuthenticated)
+		}
+		authenticationMiddleware = authentication.WithAPIKeys(authenticationMiddleware, apiKeyMiddleware)
+	}
+	if configuration.NotificationService.Enabled {
+		if _, err := notification.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register notification routes: %v", err)
+		}
+	}
+	if configuration.PaymentService.Enabled {
+		if _, err := payment.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register payment routes: %v", err)
+		}
+	}
+	if configuration.MediaService.Enabled {
+		if _, err := media.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register media routes: %v", err)
+		}
+	}
+	if configuration.SearchService.Enabled {
+		searchService, err := search.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware)
+		if err != nil {
+			return fmt.Errorf("Failed to register search routes: %v", err)
+		}
+		if searchCache := server.keptFromRoot().searchCache; searchCache != nil {
+			searchService.UseCache(searchCache)
+		}
+		server.kept.searchCache = searchService.Cache()
+	}
+	if configuration.SupportService.Enabled {
+		if _, err := support.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register support routes: %v", err)
+		}
+	}
+	if configuration.ReferenceService.Enabled {
+		referenceService, err := reference.RegisterRoutes(api, centralConfig, configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to register reference data routes: %v", err)
+		}
+		server.jobs = append(server.jobs, referenceService.Job())
+	}
+	if configuration.UpstreamRoutes.Enabled {
+		if _, err := routeregistry.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register upstream routes: %v", err)
+		}
+	}
+	if configuration.Transcoding.Enabled {
+		if _, err := transcoding.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register transcoded routes: %v", err)
+		}
+	}
+	if configuration.DebugToken.Enabled {
+		if configuration.Environment == commontConfig.ProductionEnvironment {
+			return fmt.Errorf("The token debugging endpoint can not be enabled in production")
+		}
+		api.POST("/debug/token", authenticationMiddleware.DebugToken)
+	}
+	if configuration.TokenMinting.Enabled {
+		api.POST("/staging/tokens", authenticationMiddleware.MintToken)
+	}
+	if configuration.Admin.Enabled {
+		if _, err := admin.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register admin routes: %v", err)
+		}
+		api.GET(
+			"/admin/outbound-http",
+			authenticationMiddleware.RequireAuthentication,
+			authenticationMiddleware.RequireRole(configuration.Admin.Roles...),
+			httpclient.DefaultMetrics.StatsHandler,
+		)
+		api.GET(
+			"/admin/jobs",
+			authenticationMiddleware.RequireAuthentication,
+			authenticationMiddleware.RequireRole(configuration.Admin.Roles...),
+			server.jobScheduler.StatsHandler,
+		)
+		api.GET(
+			"/admin/circuit-breakers",
+			authenticationMiddleware.RequireAuthentication,
+			authenticationMiddleware.RequireRole(configuration.Admin.Roles...),
+			resilience.DefaultBreakers.StatsHandler,
+		)
+		api.GET(
+			"/admin/upstream-retries",
+			authenticationMiddleware.RequireAuthentication,
+			authenticationMiddleware.RequireRole(configuration.Admin.Roles...),
+			resilience.DefaultRetryMetrics.StatsHandler,
+		)
+		if configuration.TokenRevocation.Enabled {
+			api.POST(
+				"/admin/tokens/revocations",
+				authenticationMiddleware.RequireAuthentication,
+				authenticationMiddleware.RequireRole(configuration.Admin.Roles...),
+				authenticationMiddleware.RevokeToken,
+			)
+		}
+		if rateLimiter != nil {
+			api.GET(
+				"/admin/rate-limits",
+				authenticationMiddleware.RequireAuthentication,
+				authenticationMiddleware.RequireRole(configuration.Admin.Roles...),
+				rateLimiter.StatsHandler,
+			)
+		}
+		if driftDetector != nil {
+			api.GET(
+				"/admin/config/drift",
+				authenticationMiddleware.RequireAuthentication,
+				authenticationMiddleware.RequireRole(configuration.Admin.Roles...),
+				driftDetector.StatusHandler,
+			)
+		}
+		if configuration.ConfigReload.Enabled {
+			api.POST(
+				"/admin/config/reload",
+				authenticationMiddleware.RequireAuthentication,
+				authenticationMiddleware.RequireRole(configuration.Admin.Roles...),
+				server.reloader().ReloadHandler,
+			)
+		}
+	}
+	if configuration.Admin.Introspection.Enabled {
+		introspection, err := admin.NewIntrospection(router, configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create admin introspection: %v", err)
+		}
+		if rateLimiter != nil {
+			introspection.UseRateLimiter(rateLimiter)
+		}
+		if publicKeyDescriber != nil {
+			introspection.UsePublicKey(publicKeyDescriber)
+		}
+		introspection.UseRouteTable(routeTable)
+		introspection.Register(router)
+	}
+	if policyBundles != nil {
+		if err := policy.RegisterRoutes(api, policyBundles, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register policy bundle routes: %v", err)
+		}
+	}
+	if configuration.TrafficTap.Enabled {
+		if err := tap.RegisterRoutes(api, trafficTap, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register traffic tap routes: %v", err)
+		}
+	}
+	if configuration.WebSockets.Enabled {
+		if _, err := websocket.RegisterRoutes(api, centralConfig, configuration, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register WebSocket routes: %v", err)
+		}
+	}
+	for _, registerRoutes := range server.serverOptions.routes {
+		if err := registerRoutes(api, authenticationMiddleware); err != nil {
+			return fmt.Errorf("Failed to register extra routes: %v", err)
+		}
+	}
+	server.handler = noise.NewFilter(router, configuration)
+
+	if configuration.Events.Enabled && configuration.Events.Outbox.Enabled {
+		eventOutbox, err := events.NewOutboxPublisher(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create event outbox: %v", err)
+		}
+		server.jobs = append(server.jobs, eventOutbox.Job())
+	}
+	if configuration.LeaderElection.Enabled {
+		leaser, err := leader.NewLeaser(configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create leader election lease: %v", err)
+		}
+		elector, err := leader.NewElector(leaser, configuration)
+		if err != nil {
+			return fmt.Errorf("Failed to create leader elector: %v", err)
+		}
+		server.jobScheduler.UseLeader(elector.IsLeader)
+		server.jobs = append(server.jobs, elector.Job())
+	}
+	if configuration.ConfigReload.Enabled {
+		if server.serverOptions.configLoader == nil {
+			return fmt.Errorf("The configuration reload needs the configuration loader given by WithConfigLoader")
+		}
+		if configuration.ConfigReload.Interval > 0 {
+			server.jobs = append(server.jobs, server.reloader().ReloadJob())
+		}
+	}
+	return nil
+}
+
+// Router returns the router of the gateway, the last one built when the configuration was reloaded
+func (server *Server) Router() *gin.Engine {
+	server.generationMtx.RLock()
+	defer server.generationMtx.RUnlock()
+	return server.router
+}
+
+// Handler returns the handler serving the gateway requests, to serve them from another server
+func (server *Server) Handler() http.Handler {
+	return server.serving
+}
+
+// Start runs the jobs and serves the requests until the server is stopped or fails serving.
+// On Lambda the requests are served by the runtime instead of a listener.
+func (server *Server) Start() error {
+	server.generationMtx.Lock()
+	server.runJobs()
+	server.generationMtx.Unlock()
+	server.lifecycle.Serving()
+
+	if serverless.DetectPlatform(server.configuration.Serverless.Platform) == serverless.PlatformLambda {
+		lambdaRuntime, err := serverless.NewRuntime(server.serving)
+		if err != nil {
+			return fmt.Errorf("Failed to create Lambda runtime: %v", err)
+		}
+		fmt.Println("Serving API requests as a Lambda function")
+		if err := lambdaRuntime.Run(server.ctx); err != nil && server.ctx.Err() == nil {
+			return fmt.Errorf("Lambda runtime stopped: %v", err)
+		}
+		return nil
+	}
+
+	serveErrors := make(chan error, 3)
+	if socketPath := server.configuration.UnixSockets.Listen; socketPath != "" {
+		socketListener, err := server.listenUnixSocket(socketPath)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Listening API requests on unix socket: ", socketPath)
+		go func() {
+			if err := server.socketServer.Serve(socketListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErrors <- fmt.Errorf("Failed serving on unix socket: %v", err)
+				return
+			}
+			serveErrors <- nil
+		}()
+	}
+	if server.httpRedirect != nil {
+		fmt.Println("Redirecting HTTP requests to HTTPS on: ", server.httpRedirect.Addr)
+		go func() {
+			if err := server.httpRedirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErrors <- fmt.Errorf("Failed serving the HTTPS redirect: %v", err)
+				return
+			}
+			serveErrors <- nil
+		}()
+	}
+	fmt.Println("Listening API requests on URL: ", fmt.Sprintf("%s%s", server.listenAddress, APIPath))
+	go func() {
+		listenAndServe := server.httpServer.ListenAndServe
+		if server.httpServer.TLSConfig != nil {
+			// The certificates are in the TLS configuration
+			listenAndServe = func() error { return server.httpServer.ListenAndServeTLS("", "") }
+		}
+		if err := listenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErrors <- fmt.Errorf("Failed serving API requests: %v", err)
+			return
+		}
+		serveErrors <- nil
+	}()
+	return <-serveErrors
+}
+
+func (server *Server) listenUnixSocket(socketPath string) (net.Listener, error) {
+	socketMode, err := unixsocket.ParseMode(server.configuration.UnixSockets.ListenMode)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse unix socket mode: %v", err)
+	}
+	socketListener, err := unixsocket.Listen(socketPath, socketMode)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to listen on unix socket: %v", err)
+	}
+	return socketListener, nil
+}
+
+// Run starts the gateway and shuts it down gracefully once the context is done, within the grace period
+func (server *Server) Run(ctx context.Context) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Start()
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	fmt.Println("Shutting down the gateway, draining the requests in flight")
+	stopContext, cancel := context.WithTimeout(context.Background(), server.lifecycle.GracePeriod())
+	defer cancel()
+	err := server.Stop(stopContext)
+	return errors.Join(err, <-served)
+}
+
+// Stop drains the requests in flight within the context, flushes the buffers of the router and closes the
+// backend connections, then stops the jobs and the fake backends
+func (server *Server) Stop(ctx context.Context) error {
+	server.generationMtx.RLock()
+	hooks := append([]lifecycle.Hook{}, server.flushes...)
+	server.generationMtx.RUnlock()
+	hooks = append(hooks, lifecycle.Hook{Name: "backend_connections", Run: egress.CloseConnections})
+	err := server.lifecycle.Shutdown(ctx, hooks...)
+	server.cancel()
+	server.stopBackends.Do(func() {
+		if server.fakeBackends != nil {
+			server.fakeBackends.Stop()
+		}
+	})
+	return err
+}
@@ -0,0 +1,221 @@
+package gateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commontConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/egress"
+)
+
+func TestServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Options_Should_Accumulate", func(t *testing.T) {
+		serverOptions := options{}
+		centralConfig := &commontConfig.Config{}
+		middleware := func(ctx *gin.Context) {}
+		for _, opt := range []Option{
+			WithMiddleware(middleware, middleware),
+			WithAPIMiddleware(middleware),
+			WithRoutes(func(api *gin.RouterGroup, authentication Authentication) error { return nil }),
+			WithJob(Job{Name: "first", Run: func(ctx context.Context, now time.Time) error { return nil }}),
+			WithJob(Job{Name: "second", Run: func(ctx context.Context, now time.Time) error { return nil }}),
+			WithCentralConfig(centralConfig),
+			WithHealthCheck("ledger", nil),
+			WithConfigLoader(func() (*Config, error) { return &Config{}, nil }),
+		} {
+			opt(&serverOptions)
+		}
+
+		assert.Len(t, serverOptions.middlewares, 2)
+		assert.Len(t, serverOptions.apiMiddlewares, 1)
+		assert.Len(t, serverOptions.routes, 1)
+		assert.Equal(t, "second", serverOptions.jobs[1].Name)
+		assert.True(t, serverOptions.centralConfig == centralConfig)
+		assert.Contains(t, serverOptions.healthChecks, "ledger")
+		assert.NotNil(t, serverOptions.configLoader)
+	})
+
+	t.Run("NewServer_Should_Return_The_Wiring_Errors", func(t *testing.T) {
+		configuration := &Config{Tracing: config.TracingConfig{Enabled: true, Exporter: "zipkin"}}
+
+		server, err := NewServer(configuration, WithCentralConfig(&commontConfig.Config{}))
+
+		assert.Nil(t, server)
+		assert.EqualError(t, err, "Failed to create tracer: Unknown tracing exporter: zipkin")
+	})
+
+	t.Run("CheckTLSMaterial_Should_Fail_On_The_Expired_Certificates", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "example.com"},
+			NotBefore:    time.Now().Add(-48 * time.Hour),
+			NotAfter:     time.Now().Add(-24 * time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		assert.NoError(t, err)
+		file := filepath.Join(t.TempDir(), "cert.pem")
+		assert.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+		configuration := &Config{Certificates: config.CertificatesConfig{Files: []string{file}}}
+
+		_, expiredErr := checkTLSMaterial(configuration, false, time.Now())
+		detail, err := checkTLSMaterial(configuration, false, time.Now().Add(-36*time.Hour))
+
+		assert.ErrorContains(t, expiredErr, "Certificate CN=example.com in "+file+" expired")
+		assert.NoError(t, err)
+		assert.Equal(t, "Loaded the CA certificate: false, checked 1 certificates", detail)
+	})
+
+	t.Run("Check_Should_Fail_When_The_Upstream_Is_Unreachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		host, port, err := net.SplitHostPort(listener.Addr().String())
+		assert.NoError(t, err)
+		listener.Close()
+		configuration := &Config{
+			Environment:  "test",
+			Certificates: config.CertificatesConfig{Files: []string{"missing.pem"}},
+		}
+		centralConfig := &commontConfig.Config{AuthenticationService: commontConfig.Address{Host: host, Port: port}}
+
+		report := Check(configuration, 200*time.Millisecond, WithCentralConfig(centralConfig))
+
+		assert.False(t, report.Passed)
+		assert.Len(t, report.Checks, 5)
+		assert.Equal(t, CheckFailed, report.Checks[1].Status)
+		assert.Contains(t, report.Checks[1].Error, "missing.pem")
+		assert.Equal(t, "upstream:authentication", report.Checks[2].Name)
+		assert.Equal(t, CheckFailed, report.Checks[2].Status)
+		assert.Equal(t, CheckFailed, report.Checks[3].Status)
+		assert.Equal(t, CheckResult{Name: "server", Status: CheckSkipped, Detail: "The public key could not be fetched"}, report.Checks[4])
+	})
+
+	t.Run("ReloadedConfig_Should_Only_Take_The_Reloadable_Sections", func(t *testing.T) {
+		applied := &Config{Environment: "test", CORS: config.CORSConfig{AllowedOrigins: []string{"https://example.com"}}}
+		loaded := &Config{
+			Environment: "dev",
+			CORS:        config.CORSConfig{AllowedOrigins: []string{"https://example.com"}},
+			RateLimits:  config.RateLimitsConfig{Enabled: true},
+		}
+
+		next, changed := reloadedConfig(applied, loaded)
+
+		assert.Equal(t, []string{RateLimitsSection}, changed)
+		assert.Equal(t, "test", next.Environment)
+		assert.True(t, next.RateLimits.Enabled)
+		assert.False(t, applied.RateLimits.Enabled)
+	})
+
+	t.Run("Reload_Should_Keep_The_Router_When_The_Configuration_Is_Invalid", func(t *testing.T) {
+		configuration := &Config{Environment: "test"}
+		loaded := &Config{CORS: config.CORSConfig{Enabled: true, AllowedOrigins: []string{"example.com"}}}
+		serving := newHandlerSwitch(http.NotFoundHandler())
+		server := &Server{
+			configuration: configuration,
+			serving:       serving,
+			serverOptions: options{configLoader: func() (*Config, error) { return loaded, nil }},
+		}
+
+		changed, err := server.Reload()
+
+		assert.Nil(t, changed)
+		assert.ErrorContains(t, err, "Invalid CORS settings")
+		assert.Nil(t, server.applied)
+		recorder := httptest.NewRecorder()
+		serving.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+		loaded = &Config{}
+		changed, err = server.Reload()
+
+		assert.NoError(t, err)
+		assert.Empty(t, changed)
+	})
+
+	t.Run("Reload_Should_Keep_The_Connections_And_The_Rate_Limiter", func(t *testing.T) {
+		configuration := &Config{Environment: "test", FakeBackends: config.FakeBackendsConfig{Enabled: true}}
+		group := config.RateLimitGroupConfig{Name: "api", PathPrefix: "/api/v1", RateLimit: 5, Burst: 10}
+		loaded := &Config{RateLimits: config.RateLimitsConfig{Enabled: true, Groups: []config.RateLimitGroupConfig{group}}}
+		server, err := NewServer(configuration, WithConfigLoader(func() (*Config, error) { return loaded, nil }))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer server.Stop(context.Background())
+		connections := len(egress.ConnectionStates())
+
+		changed, err := server.Reload()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{RateLimitsSection}, changed)
+		rateLimiter := server.rateLimiter
+		assert.NotNil(t, rateLimiter)
+
+		group.Burst = 20
+		loaded = &Config{RateLimits: config.RateLimitsConfig{Enabled: true, Groups: []config.RateLimitGroupConfig{group}}}
+		changed, err = server.Reload()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{RateLimitsSection}, changed)
+		assert.NotZero(t, connections)
+		assert.Len(t, egress.ConnectionStates(), connections)
+		assert.True(t, server.rateLimiter == rateLimiter)
+	})
+
+	t.Run("Reload_Should_Keep_The_Tap_The_Caches_And_The_Session_Activity", func(t *testing.T) {
+		configuration := &Config{
+			Environment:   "test",
+			FakeBackends:  config.FakeBackendsConfig{Enabled: true},
+			ResponseCache: config.ResponseCacheConfig{Enabled: true},
+		}
+		loaded := &Config{CORS: config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}}
+		server, err := NewServer(configuration, WithConfigLoader(func() (*Config, error) { return loaded, nil }))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer server.Stop(context.Background())
+		kept := server.kept
+
+		changed, err := server.Reload()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{CORSSection}, changed)
+		assert.NotNil(t, kept.trafficTap)
+		assert.NotNil(t, kept.responseStore)
+		assert.NotNil(t, kept.activityStore)
+		assert.True(t, server.kept.trafficTap == kept.trafficTap)
+		assert.True(t, server.kept.responseStore == kept.responseStore)
+		assert.True(t, server.kept.activityStore == kept.activityStore)
+	})
+
+	t.Run("HandlerSwitch_Should_Serve_The_Swapped_Handler", func(t *testing.T) {
+		serving := newHandlerSwitch(http.NotFoundHandler())
+		serving.swap(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusNoContent)
+		}))
+		recorder := httptest.NewRecorder()
+
+		serving.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+	})
+}
@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"github.com/gin-gonic/gin"
+	commontConfig "github.com/quadev-ltd/qd-common/pkg/config"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/authentication"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Authentication is the authentication middleware given to the extra routes, to protect them as the gateway routes
+type Authentication = authentication.AutheticationMiddlewarer
+
+// Job is a periodic task run by the gateway scheduler
+type Job = scheduler.Job
+
+// RouteRegisterer registers extra routes on the API group
+type RouteRegisterer func(api *gin.RouterGroup, authentication Authentication) error
+
+// Option customizes the server created by NewServer
+type Option func(*options)
+
+type options struct {
+	middlewares    []gin.HandlerFunc
+	apiMiddlewares []gin.HandlerFunc
+	routes         []RouteRegisterer
+	jobs           []Job
+	centralConfig  *commontConfig.Config
+	healthChecks   map[string]grpc.ClientConnInterface
+	configLoader   func() (*Config, error)
+}
+
+// WithMiddleware adds middlewares to every request, they run after the gateway middlewares
+func WithMiddleware(middlewares ...gin.HandlerFunc) Option {
+	return func(serverOptions *options) {
+		serverOptions.middlewares = append(serverOptions.middlewares, middlewares...)
+	}
+}
+
+// WithAPIMiddleware adds middlewares to the API requests, they run after the gateway API middlewares
+// and before the authentication of the routes
+func WithAPIMiddleware(middlewares ...gin.HandlerFunc) Option {
+	return func(serverOptions *options) {
+		serverOptions.apiMiddlewares = append(serverOptions.apiMiddlewares, middlewares...)
+	}
+}
+
+// WithRoutes registers extra routes on the API group once the gateway routes are registered
+func WithRoutes(registerer RouteRegisterer) Option {
+	return func(serverOptions *options) {
+		serverOptions.routes = append(serverOptions.routes, registerer)
+	}
+}
+
+// WithJob schedules an extra periodic task
+func WithJob(job Job) Option {
+	return func(serverOptions *options) {
+		serverOptions.jobs = append(serverOptions.jobs, job)
+	}
+}
+
+// WithCentralConfig uses the given addresses of the services instead of loading the central configuration
+func WithCentralConfig(centralConfig *commontConfig.Config) Option {
+	return func(serverOptions *options) {
+		serverOptions.centralConfig = centralConfig
+	}
+}
+
+// WithHealthCheck adds a backend to the readiness checks, checked over the connection with the gRPC health protocol
+func WithHealthCheck(name string, connection grpc.ClientConnInterface) Option {
+	return func(serverOptions *options) {
+		if serverOptions.healthChecks == nil {
+			serverOptions.healthChecks = make(map[string]grpc.ClientConnInterface)
+		}
+		serverOptions.healthChecks[name] = connection
+	}
+}
+
+// WithConfigLoader loads the configuration again when it is reloaded, needed by the configuration reload
+func WithConfigLoader(load func() (*Config, error)) Option {
+	return func(serverOptions *options) {
+		serverOptions.configLoader = load
+	}
+}
@@ -0,0 +1,187 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonLogger "github.com/quadev-ltd/qd-common/pkg/log"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/cors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/errors"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/ratelimit"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/routeregistry"
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/scheduler"
+)
+
+// Sections of the configuration applied when it is reloaded, the other sections need a restart
+const (
+	CORSSection           = "cors"
+	RateLimitsSection     = "rate_limits"
+	UpstreamRoutesSection = "upstream_routes"
+)
+
+// handlerSwitch serves the requests with the handler of the last router built, swapped at once on a reload
+// while the requests in flight finish on the previous one
+type handlerSwitch struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+func newHandlerSwitch(handler http.Handler) *handlerSwitch {
+	serving := &handlerSwitch{}
+	serving.swap(handler)
+	return serving
+}
+
+func (serving *handlerSwitch) swap(handler http.Handler) {
+	serving.handler.Store(&handler)
+}
+
+func (serving *handlerSwitch) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	(*serving.handler.Load()).ServeHTTP(writer, request)
+}
+
+// reloader returns the server reloading the configuration, the one a reloaded generation was built for
+func (server *Server) reloader() *Server {
+	if server.root != nil {
+		return server.root
+	}
+	return server
+}
+
+// runJobs runs the jobs of the current scheduler until the server stops or the router is reloaded,
+// called with the generation lock held
+func (server *Server) runJobs() {
+	ctx, cancel := context.WithCancel(server.ctx)
+	server.stopJobs = cancel
+	go server.jobScheduler.Run(ctx)
+}
+
+// reloadedConfig returns the applied configuration with the reloadable sections of the loaded one and the changed sections
+func reloadedConfig(applied, loaded *Config) (*Config, []string) {
+	next := *applied
+	next.CORS = loaded.CORS
+	next.RateLimits = loaded.RateLimits
+	next.UpstreamRoutes = loaded.UpstreamRoutes
+	changed := []string{}
+	if !reflect.DeepEqual(applied.CORS, loaded.CORS) {
+		changed = append(changed, CORSSection)
+	}
+	if !reflect.DeepEqual(applied.RateLimits, loaded.RateLimits) {
+		changed = append(changed, RateLimitsSection)
+	}
+	if !reflect.DeepEqual(applied.UpstreamRoutes, loaded.UpstreamRoutes) {
+		changed = append(changed, UpstreamRoutesSection)
+	}
+	return &next, changed
+}
+
+// validateReload checks the reloadable sections before a router is built with them
+func validateReload(configuration *Config) error {
+	if configuration.CORS.Enabled {
+		if _, err := cors.NewCORS(configuration); err != nil {
+			return fmt.Errorf("Invalid CORS settings: %v", err)
+		}
+	}
+	if configuration.RateLimits.Enabled {
+		if err := ratelimit.ValidateGroups(configuration.RateLimits.Groups); err != nil {
+			return fmt.Errorf("Invalid rate limits: %v", err)
+		}
+		if err := ratelimit.ValidateCosts(configuration.RateLimits.Costs); err != nil {
+			return fmt.Errorf("Invalid rate limit costs: %v", err)
+		}
+	}
+	if configuration.UpstreamRoutes.Enabled {
+		if err := routeregistry.Validate(configuration); err != nil {
+			return fmt.Errorf("Invalid upstream routes: %v", err)
+		}
+	}
+	return nil
+}
+
+// Reload loads the configuration again and applies its route definitions, rate limits and CORS settings.
+// A router is built with them and swapped in at once along with its jobs, the current router keeps serving
+// when the configuration is invalid. The router keeps the components of the other sections, so their buffers
+// are flushed by the jobs of the new router and their streams and caches stay open. It returns the changed
+// sections, none when nothing changed.
+func (server *Server) Reload() ([]string, error) {
+	if server.serverOptions.configLoader == nil {
+		return nil, fmt.Errorf("No configuration loader was given with WithConfigLoader")
+	}
+	server.reloadMtx.Lock()
+	defer server.reloadMtx.Unlock()
+
+	loaded, err := server.serverOptions.configLoader()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the configuration: %v", err)
+	}
+	applied := server.applied
+	if applied == nil {
+		applied = server.configuration
+	}
+	next, changed := reloadedConfig(applied, loaded)
+	if len(changed) == 0 {
+		return changed, nil
+	}
+	if err := validateReload(next); err != nil {
+		return nil, err
+	}
+	generation := &Server{
+		configuration: next,
+		centralConfig: server.centralConfig,
+		ctx:           server.ctx,
+		jobScheduler:  scheduler.NewScheduler(next),
+		serverOptions: server.serverOptions,
+		root:          server,
+	}
+	if err := generation.build(); err != nil {
+		return nil, fmt.Errorf("Failed to build the reloaded router: %v", err)
+	}
+	if err := generation.scheduleJobs(); err != nil {
+		return nil, err
+	}
+
+	server.generationMtx.Lock()
+	server.router, server.handler, server.jobs = generation.router, generation.handler, generation.jobs
+	server.flushes, server.routeTable, server.rateLimiter = generation.flushes, generation.routeTable, generation.rateLimiter
+	server.kept = generation.kept
+	server.jobScheduler = generation.jobScheduler
+	if server.stopJobs != nil {
+		server.stopJobs()
+		server.runJobs()
+	}
+	server.generationMtx.Unlock()
+	server.serving.swap(generation.handler)
+	server.applied = next
+
+	logger := commonLogger.NewLogFactory(server.configuration.Environment).NewLogger()
+	logger.Info(fmt.Sprintf("Reloaded the configuration sections: %s", strings.Join(changed, ", ")))
+	return changed, nil
+}
+
+// ReloadJob reloads the configuration on every reload interval, used when the configuration reload is enabled
+func (server *Server) ReloadJob() scheduler.Job {
+	return scheduler.Job{
+		Name:     "config_reload",
+		Schedule: scheduler.Every(server.configuration.ConfigReload.Interval),
+		Run: func(ctx context.Context, now time.Time) error {
+			_, err := server.Reload()
+			return err
+		},
+	}
+}
+
+// ReloadHandler reloads the configuration and answers the changed sections
+func (server *Server) ReloadHandler(ctx *gin.Context) {
+	changed, err := server.Reload()
+	if err != nil {
+		errors.AbortWithMessage(ctx, errors.InternalError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"reloaded": len(changed) > 0, "sections": changed})
+}
@@ -0,0 +1,28 @@
+package gateway
+
+import (
+	"io"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/routetable"
+)
+
+// Formats of the exported route table
+const (
+	RouteTableJSON     = routetable.FormatJSON
+	RouteTableMarkdown = routetable.FormatMarkdown
+)
+
+// RouteTable is the effective route table of the gateway, written as JSON or Markdown
+type RouteTable = routetable.Table
+
+// RouteTable returns the effective route table of the gateway, of the last router built
+func (server *Server) RouteTable() *RouteTable {
+	server.generationMtx.RLock()
+	defer server.generationMtx.RUnlock()
+	return server.routeTable.Export()
+}
+
+// ImportRouteTable reads a route table exported as JSON, to write it in another format
+func ImportRouteTable(reader io.Reader) (*RouteTable, error) {
+	return routetable.Import(reader)
+}
@@ -0,0 +1,189 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+// PreProxyHook runs before every call to an upstream, the returned context is the one of the call.
+// An error, preferably a gRPC status, rejects the call.
+type PreProxyHook func(ctx context.Context, service, method string) (context.Context, error)
+
+// Hooks are the hooks of a module, each is optional.
+// PreAuth runs on the API requests before their authentication and may abort them,
+// PostAuth runs once an access token is verified and may abort the request,
+// PreProxy runs before the calls to the upstreams and PostResponse after the response is written.
+type Hooks struct {
+	PreAuth      gin.HandlerFunc
+	PostAuth     func(ctx *gin.Context, claims *commonJWT.TokenClaims)
+	PreProxy     PreProxyHook
+	PostResponse gin.HandlerFunc
+}
+
+// Factory creates the hooks of a module from its settings
+type Factory func(settings map[string]string) (*Hooks, error)
+
+var (
+	factories    = map[string]Factory{}
+	factoriesMtx sync.RWMutex
+)
+
+// Register makes a module available under the name, it panics when the name is taken.
+// Modules compiled into the gateway register from their init function, like the database/sql drivers,
+// and are enabled by name in the hooks configuration.
+func Register(name string, factory Factory) {
+	factoriesMtx.Lock()
+	defer factoriesMtx.Unlock()
+	if factory == nil {
+		panic("hooks: Register factory is nil for " + name)
+	}
+	if _, exists := factories[name]; exists {
+		panic("hooks: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// Modules returns the names of the registered modules
+func Modules() []string {
+	factoriesMtx.RLock()
+	defer factoriesMtx.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Chain runs the hooks of the enabled modules in their configured order
+type Chain struct {
+	hooks []*Hooks
+}
+
+// Load creates the hooks of the modules enabled in the configuration
+func Load(configurations *config.Config) (*Chain, error) {
+	chain := &Chain{}
+	for _, module := range configurations.Hooks.Modules {
+		factoriesMtx.RLock()
+		factory, exists := factories[module.Name]
+		factoriesMtx.RUnlock()
+		if !exists {
+			return nil, fmt.Errorf("Unknown hook module: %s", module.Name)
+		}
+		moduleHooks, err := factory(module.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating the hooks of %s: %v", module.Name, err)
+		}
+		chain.hooks = append(chain.hooks, moduleHooks)
+	}
+	return chain, nil
+}
+
+var current atomic.Pointer[Chain]
+
+// Use runs the pre-proxy hooks of the chain on the calls of the connections created with HookConnection
+func Use(chain *Chain) {
+	current.Store(chain)
+}
+
+// Current returns the chain in use, nil when the hooks are disabled
+func Current() *Chain {
+	return current.Load()
+}
+
+// PreAuth runs the pre-auth hooks until one aborts the request
+func (chain *Chain) PreAuth(ctx *gin.Context) {
+	for _, moduleHooks := range chain.hooks {
+		if moduleHooks.PreAuth == nil {
+			continue
+		}
+		moduleHooks.PreAuth(ctx)
+		if ctx.IsAborted() {
+			return
+		}
+	}
+}
+
+// PostAuth runs the post-auth hooks until one aborts the request
+func (chain *Chain) PostAuth(ctx *gin.Context, claims *commonJWT.TokenClaims) {
+	for _, moduleHooks := range chain.hooks {
+		if moduleHooks.PostAuth == nil {
+			continue
+		}
+		moduleHooks.PostAuth(ctx, claims)
+		if ctx.IsAborted() {
+			return
+		}
+	}
+}
+
+// PreProxy runs the pre-proxy hooks, threading the context through them, until one rejects the call
+func (chain *Chain) PreProxy(ctx context.Context, service, method string) (context.Context, error) {
+	for _, moduleHooks := range chain.hooks {
+		if moduleHooks.PreProxy == nil {
+			continue
+		}
+		var err error
+		if ctx, err = moduleHooks.PreProxy(ctx, service, method); err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// PostResponse runs the rest of the handlers then the post-response hooks
+func (chain *Chain) PostResponse(ctx *gin.Context) {
+	ctx.Next()
+	for _, moduleHooks := range chain.hooks {
+		if moduleHooks.PostResponse != nil {
+			moduleHooks.PostResponse(ctx)
+		}
+	}
+}
+
+// HookedConnection runs the pre-proxy hooks of the chain in use before the calls to a service
+type HookedConnection struct {
+	service    string
+	connection grpc.ClientConnInterface
+}
+
+var _ grpc.ClientConnInterface = &HookedConnection{}
+
+// Invoke runs the pre-proxy hooks then calls the service
+func (connection *HookedConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	if chain := Current(); chain != nil {
+		var err error
+		if ctx, err = chain.PreProxy(ctx, connection.service, method); err != nil {
+			return err
+		}
+	}
+	return connection.connection.Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream runs the pre-proxy hooks then opens the stream with the service
+func (connection *HookedConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if chain := Current(); chain != nil {
+		var err error
+		if ctx, err = chain.PreProxy(ctx, connection.service, method); err != nil {
+			return nil, err
+		}
+	}
+	return connection.connection.NewStream(ctx, desc, method, opts...)
+}
+
+// HookConnection runs the pre-proxy hooks on the calls to the service when the hooks are enabled
+func HookConnection(service string, connection grpc.ClientConnInterface, configurations *config.Config) grpc.ClientConnInterface {
+	if !configurations.Hooks.Enabled {
+		return connection
+	}
+	return &HookedConnection{service: service, connection: connection}
+}
@@ -0,0 +1,161 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	commonJWT "github.com/quadev-ltd/qd-common/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/quadev-ltd/qd-qpi-gateway/internal/config"
+)
+
+type contextKey string
+
+// fakeConnection records the context of the calls
+type fakeConnection struct {
+	grpc.ClientConnInterface
+	calls []context.Context
+}
+
+func (connection *fakeConnection) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	connection.calls = append(connection.calls, ctx)
+	return nil
+}
+
+// recordingModule registers a module appending its name to the calls of every hook
+func recordingModule(name string, calls *[]string, abort bool) {
+	Register(name, func(settings map[string]string) (*Hooks, error) {
+		if settings["fail"] == "true" {
+			return nil, fmt.Errorf("Missing settings")
+		}
+		return &Hooks{
+			PreAuth: func(ctx *gin.Context) {
+				*calls = append(*calls, name+" pre-auth")
+				if abort {
+					ctx.AbortWithStatus(http.StatusForbidden)
+				}
+			},
+			PostAuth: func(ctx *gin.Context, claims *commonJWT.TokenClaims) {
+				*calls = append(*calls, name+" post-auth "+claims.Email)
+			},
+			PreProxy: func(ctx context.Context, service, method string) (context.Context, error) {
+				*calls = append(*calls, name+" pre-proxy "+service+method)
+				if abort {
+					return nil, fmt.Errorf("Rejected by %s", name)
+				}
+				return context.WithValue(ctx, contextKey(name), settings["value"]), nil
+			},
+			PostResponse: func(ctx *gin.Context) {
+				*calls = append(*calls, fmt.Sprintf("%s post-response %d", name, ctx.Writer.Status()))
+			},
+		}, nil
+	})
+}
+
+func hooksConfig(modules ...config.HookModuleConfig) *config.Config {
+	return &config.Config{Hooks: config.HooksConfig{Enabled: true, Modules: modules}}
+}
+
+func TestHooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := []string{}
+	recordingModule("first", &calls, false)
+	recordingModule("second", &calls, false)
+	recordingModule("blocking", &calls, true)
+
+	t.Run("Register_Should_Panic_On_A_Taken_Name", func(t *testing.T) {
+		assert.Panics(t, func() { recordingModule("first", &calls, false) })
+		assert.Equal(t, []string{"blocking", "first", "second"}, Modules())
+	})
+
+	t.Run("Load_Should_Return_The_Module_Errors", func(t *testing.T) {
+		_, err := Load(hooksConfig(config.HookModuleConfig{Name: "unknown"}))
+		assert.EqualError(t, err, "Unknown hook module: unknown")
+
+		_, err = Load(hooksConfig(config.HookModuleConfig{Name: "first", Settings: map[string]string{"fail": "true"}}))
+		assert.EqualError(t, err, "Error creating the hooks of first: Missing settings")
+	})
+
+	t.Run("Chain_Should_Run_The_Hooks_In_The_Configured_Order", func(t *testing.T) {
+		calls = calls[:0]
+		chain, err := Load(hooksConfig(config.HookModuleConfig{Name: "second"}, config.HookModuleConfig{Name: "first"}))
+		assert.NoError(t, err)
+		router := gin.New()
+		router.Use(chain.PostResponse, chain.PreAuth)
+		router.GET("/users", func(ctx *gin.Context) {
+			chain.PostAuth(ctx, &commonJWT.TokenClaims{Email: "user@example.com"})
+			ctx.Status(http.StatusNoContent)
+		})
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+		assert.Equal(t, []string{
+			"second pre-auth",
+			"first pre-auth",
+			"second post-auth user@example.com",
+			"first post-auth user@example.com",
+			"second post-response 204",
+			"first post-response 204",
+		}, calls)
+	})
+
+	t.Run("PreAuth_Should_Stop_Once_The_Request_Is_Aborted", func(t *testing.T) {
+		calls = calls[:0]
+		chain, err := Load(hooksConfig(config.HookModuleConfig{Name: "blocking"}, config.HookModuleConfig{Name: "first"}))
+		assert.NoError(t, err)
+		router := gin.New()
+		router.Use(chain.PostResponse, chain.PreAuth)
+		router.GET("/users", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Equal(t, []string{"blocking pre-auth", "blocking post-response 403", "first post-response 403"}, calls)
+	})
+
+	t.Run("HookedConnection_Should_Call_With_The_Context_Of_The_PreProxy_Hooks", func(t *testing.T) {
+		calls = calls[:0]
+		chain, err := Load(hooksConfig(
+			config.HookModuleConfig{Name: "first", Settings: map[string]string{"value": "first-value"}},
+			config.HookModuleConfig{Name: "second", Settings: map[string]string{"value": "second-value"}},
+		))
+		assert.NoError(t, err)
+		Use(chain)
+		defer Use(nil)
+		upstream := &fakeConnection{}
+		connection := HookConnection("search", upstream, hooksConfig())
+
+		assert.NoError(t, connection.Invoke(context.Background(), "/search.SearchService/Search", nil, nil))
+
+		assert.Equal(t, []string{"first pre-proxy search/search.SearchService/Search", "second pre-proxy search/search.SearchService/Search"}, calls)
+		assert.Equal(t, "first-value", upstream.calls[0].Value(contextKey("first")))
+		assert.Equal(t, "second-value", upstream.calls[0].Value(contextKey("second")))
+	})
+
+	t.Run("HookedConnection_Should_Not_Call_Once_A_PreProxy_Hook_Rejects", func(t *testing.T) {
+		chain, err := Load(hooksConfig(config.HookModuleConfig{Name: "blocking"}))
+		assert.NoError(t, err)
+		Use(chain)
+		defer Use(nil)
+		upstream := &fakeConnection{}
+		connection := HookConnection("search", upstream, hooksConfig())
+
+		err = connection.Invoke(context.Background(), "/search.SearchService/Search", nil, nil)
+
+		assert.EqualError(t, err, "Rejected by blocking")
+		assert.Empty(t, upstream.calls)
+	})
+
+	t.Run("HookConnection_Should_Return_The_Connection_When_Disabled", func(t *testing.T) {
+		upstream := &fakeConnection{}
+
+		assert.True(t, HookConnection("search", upstream, &config.Config{}) == grpc.ClientConnInterface(upstream))
+	})
+}